@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tsenart/vegeta/v12/internal/hoststat"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const agentUsage = `Usage: vegeta agent [options]
+
+Runs an agent on the host of a target, which samples its CPU, memory, network
+throughput and established TCP connections and streams them back to attacks
+started with --agent. Attacks annotate their results with the samples of
+each agent, so that reports and plots show the resource usage of the target
+hosts alongside the latencies of their requests.
+
+Samples are timestamped when attacks receive them, so the clocks of agents
+needn't be synchronized with those of attacks. Sampling is only supported on
+Linux.
+
+Agents listen on localhost by default, and require a --token, which the attack
+command sends with --agent-token, to listen on other addresses.
+
+Options:
+  --listen  Address to listen on [default: localhost:8098]
+  --token   Token required from attacks, unless listening on localhost
+            [default: none]
+
+Examples:
+  vegeta agent -listen :8098 -token "$TOKEN"
+  echo "GET http://target/" | vegeta attack -agent target:8098 -agent-token "$TOKEN" \
+    -rate 500 -duration 60s | tee results.bin | vegeta report -type timeline`
+
+func agentCmd() command {
+	fs := flag.NewFlagSet("vegeta agent", flag.ExitOnError)
+	listen := fs.String("listen", "localhost:8098", "Address to listen on")
+	token := fs.String("token", "", "Token required from attacks, unless listening on localhost")
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, agentUsage)
+	}
+
+	return command{fs, func(args []string) error {
+		fs.Parse(args)
+		return agent(*listen, *token)
+	}}
+}
+
+func agent(listen, token string) error {
+	if token == "" && !loopback(listen) {
+		return fmt.Errorf("-token is required to listen on %s, which isn't localhost", listen)
+	}
+
+	// Fail early on systems where the host can't be sampled.
+	if _, err := hoststat.NewSampler(); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/samples", &agentHandler{token: token})
+	log.Printf("vegeta agent listening on %s", listen)
+	return http.ListenAndServe(listen, mux)
+}
+
+// agentSample is a sample of the resource usage of the host of an agent,
+// streamed as a line of JSON.
+type agentSample struct {
+	Time time.Time        `json:"time"`
+	Host vegeta.HostUsage `json:"host"`
+
+	// source is the address of the agent, set by the attacks receiving it.
+	source string
+}
+
+// agentHandler streams samples of the resource usage of its host at the
+// interval requested by each attack, until the attack disconnects.
+type agentHandler struct {
+	token string
+}
+
+func (h *agentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	auth := []byte(r.Header.Get("Authorization"))
+	if h.token != "" && subtle.ConstantTimeCompare(auth, []byte("Bearer "+h.token)) != 1 {
+		http.Error(w, "bad agent token", http.StatusUnauthorized)
+		return
+	}
+
+	interval := time.Second
+	if s := r.URL.Query().Get("interval"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 100*time.Millisecond {
+			http.Error(w, fmt.Sprintf("bad interval %q", s), http.StatusBadRequest)
+			return
+		}
+		interval = d
+	}
+
+	sampler, err := hoststat.NewSampler()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("streaming samples every %s to %s", interval, r.RemoteAddr)
+
+	fw := &flushWriter{w: w}
+	fw.f, _ = w.(http.Flusher)
+	enc := json.NewEncoder(fw)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	if fw.f != nil {
+		fw.f.Flush()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Printf("stopped streaming samples to %s", r.RemoteAddr)
+			return
+		case now := <-ticker.C:
+			u, err := sampler.Sample()
+			if err != nil {
+				log.Printf("error sampling the resources of the host: %v", err)
+				continue
+			}
+
+			// The process of the agent isn't the target's, so only the usage
+			// of the host is of interest.
+			u.RSS, u.FDs, u.FDLimit = 0, 0, 0
+			if err = enc.Encode(&agentSample{Time: now, Host: u}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// streamAgents streams the samples of the given agents at the given interval
+// to the returned channel until the context is done, reconnecting to those
+// which fail after an interval.
+func streamAgents(ctx context.Context, addrs []string, token string, interval time.Duration) <-chan agentSample {
+	samples := make(chan agentSample)
+	for _, addr := range addrs {
+		go func(addr string) {
+			for {
+				err := streamAgent(ctx, addr, token, interval, samples)
+				if ctx.Err() != nil {
+					return
+				} else if err != nil {
+					log.Printf("error streaming samples of agent %s: %v", addr, err)
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(interval):
+				}
+			}
+		}(addr)
+	}
+	return samples
+}
+
+// streamAgent streams the samples of the agent at addr to the given channel,
+// labeled with it as their source.
+func streamAgent(ctx context.Context, addr, token string, interval time.Duration, samples chan<- agentSample) error {
+	u := addr
+	if !strings.Contains(u, "://") {
+		u = "http://" + u
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(u, "/")+"/samples?interval="+interval.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	req = req.WithContext(ctx)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+
+	sc := bufio.NewScanner(res.Body)
+	for sc.Scan() {
+		var s agentSample
+		if err = json.Unmarshal(sc.Bytes(), &s); err != nil {
+			return fmt.Errorf("bad sample: %v", err)
+		}
+		s.source = addr
+
+		select {
+		case samples <- s:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	if err = sc.Err(); err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -2,8 +2,17 @@
 
 package main
 
-import "flag"
+import (
+	"flag"
+	"os"
+	"syscall"
+)
 
 func systemSpecificFlags(fs *flag.FlagSet, opts *attackOpts) {
-	fs.Var(&opts.resolvers, "resolvers", "List of addresses (ip:port) to use for DNS resolution. Disables use of local system DNS. (comma separated list)")
+	fs.Var(&opts.resolvers, "resolvers", "List of addresses (ip:port), DNS over TLS servers (tls://host:port) or DNS over HTTPS URLs (https://) to use for DNS resolution. Disables use of local system DNS. (comma separated list)")
+}
+
+// pauseSignals returns the signals which pause and resume attacks.
+func pauseSignals() (pause, resume os.Signal) {
+	return syscall.SIGUSR1, syscall.SIGUSR2
 }
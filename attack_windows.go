@@ -1,5 +1,11 @@
 package main
 
-import "flag"
+import (
+	"flag"
+	"os"
+)
 
 func systemSpecificFlags(fs *flag.FlagSet, opts *attackOpts) {}
+
+// pauseSignals returns nil signals since Windows has no user signals.
+func pauseSignals() (pause, resume os.Signal) { return nil, nil }
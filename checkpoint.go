@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// checkpointInterval is how often the progress of attacks is written to their
+// -checkpoint file.
+const checkpointInterval = time.Second
+
+// checkpoint is the progress of an attack, which it can be resumed from with
+// -resume if interrupted.
+type checkpoint struct {
+	Output  string        `json:"output"`
+	Targets string        `json:"targets,omitempty"` // Hash of the targets, as in the metadata.
+	Began   time.Time     `json:"began"`
+	Elapsed time.Duration `json:"elapsed"`
+	Hits    uint64        `json:"hits"`
+}
+
+// readCheckpoint reads the checkpoint in the given file.
+func readCheckpoint(name string) (*checkpoint, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var cp checkpoint
+	if err = json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("bad checkpoint %s: %v", name, err)
+	}
+
+	return &cp, nil
+}
+
+// write writes the checkpoint to the given file, replacing it at once so that
+// it's never left half written.
+func (cp *checkpoint) write(name string) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmp := name + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, name)
+}
+
+// checkpointable returns an error unless the -output of an attack can be
+// checkpointed, which requires a local file without rotation.
+func checkpointable(opts *attackOpts) error {
+	if opts.distributing() {
+		return errors.New("distributed attacks can't be checkpointed")
+	} else if opts.encoding == encodingParquet {
+		return errors.New("attacks with -encoding=parquet can't be checkpointed")
+	} else if opts.rotateSize > 0 {
+		return errors.New("attacks with -rotate-size can't be checkpointed")
+	} else if opts.outputf == "stdout" || opts.out != nil {
+		return errors.New("attacks can only be checkpointed with an -output file")
+	} else if _, _, _, ok := objectURL(opts.outputf); ok {
+		return errors.New("attacks can only be checkpointed with a local -output file")
+	} else if u, err := url.Parse(opts.outputf); err == nil && sinks[u.Scheme] != nil {
+		return errors.New("attacks can only be checkpointed with a local -output file")
+	}
+	return nil
+}
+
+// resumeOutput returns an Encoder of the -output of the attack of the given
+// checkpoint, which it rewrites with the results it has up to the checkpoint,
+// and the metadata of its attack, if any. Results of hits after the checkpoint
+// are dropped since they're sent again, as is the truncated last record the
+// output may have been left with when the attack was interrupted. Other
+// records which fail to decode fail the resumption.
+func resumeOutput(cp *checkpoint, encoding string) (vegeta.Encoder, io.Closer, *vegeta.Metadata, error) {
+	rc, err := open(cp.Output)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer rc.Close()
+
+	r, zc, err := decompress(rc)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("decompress %q: %v", cp.Output, err)
+	}
+	defer zc.Close()
+
+	dec := vegeta.DecoderFor(r)
+	if dec == nil {
+		return nil, nil, nil, fmt.Errorf("can't detect encoding of %q", cp.Output)
+	}
+
+	// The temporary file keeps the extension of the output, which decides
+	// its compression.
+	ext := filepath.Ext(cp.Output)
+	tmp := strings.TrimSuffix(cp.Output, ext) + ".resume" + ext
+
+	enc, closer, err := output(tmp, encoding, 0, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var (
+		md   *vegeta.Metadata
+		kept int
+	)
+
+	for {
+		var res vegeta.Result
+		if err = dec.Decode(&res); err == io.EOF {
+			break
+		} else if err == io.ErrUnexpectedEOF {
+			// Interrupted attacks may leave a truncated result behind.
+			log.Printf("dropping the truncated last record of %s", cp.Output)
+			break
+		} else if err != nil {
+			closer.Close()
+			os.Remove(tmp)
+			return nil, nil, nil, fmt.Errorf("decode %q: %v", cp.Output, err)
+		}
+
+		if res.Metadata != nil && res.Metadata.Annotation == nil && md == nil {
+			md = res.Metadata
+		} else if res.Metadata == nil && res.Seq >= cp.Hits {
+			continue
+		}
+
+		if err = enc.Encode(&res); err != nil {
+			closer.Close()
+			os.Remove(tmp)
+			return nil, nil, nil, err
+		}
+		kept++
+	}
+
+	log.Printf("resuming %s with the %d records it has up to hit %d", cp.Output, kept, cp.Hits)
+
+	if err = os.Rename(tmp, cp.Output); err != nil {
+		closer.Close()
+		os.Remove(tmp)
+		return nil, nil, nil, err
+	}
+
+	return enc, closer, md, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestResumeOutput(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "vegeta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ts := time.Date(2020, 3, 14, 15, 9, 26, 0, time.UTC)
+	results := []vegeta.Result{{Metadata: &vegeta.Metadata{Attack: "checkout", Began: ts}}}
+	for i := 0; i < 5; i++ {
+		results = append(results, vegeta.Result{Attack: "checkout", Seq: uint64(i), Code: 200, Timestamp: ts})
+	}
+
+	encode := func(enc func(io.Writer) vegeta.Encoder) []byte {
+		var buf bytes.Buffer
+		e := enc(&buf)
+		for i := range results {
+			if err := e.Encode(&results[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return buf.Bytes()
+	}
+
+	gob, js := encode(vegeta.NewEncoder), encode(vegeta.NewJSONEncoder)
+	lines := strings.SplitAfter(string(js), "\n")
+
+	for _, tc := range []struct {
+		name     string
+		encoding string
+		data     []byte
+		seqs     []uint64
+		err      string
+	}{
+		{"complete", encodingGob, gob, []uint64{0, 1, 2}, ""},
+		{"truncated", encodingGob, gob[:len(gob)-3], []uint64{0, 1, 2}, ""},
+		{"corrupt", encodingJSON, []byte(lines[0] + lines[1] + "garbage\n" + lines[2]), nil, "decode"},
+	} {
+		name := filepath.Join(dir, tc.name+".bin")
+		if err = ioutil.WriteFile(name, tc.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, closer, md, err := resumeOutput(&checkpoint{Output: name, Hits: 3}, tc.encoding)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: got error %v, want %q", tc.name, err, tc.err)
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		if md == nil || md.Attack != "checkout" {
+			t.Errorf("%s: got metadata %+v", tc.name, md)
+		}
+
+		if err = closer.Close(); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		// The output is rewritten with the results up to the checkpoint.
+		dec, dc, err := decoder([]string{name}, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		var seqs []uint64
+		for {
+			var r vegeta.Result
+			if err = dec.Decode(&r); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			seqs = append(seqs, r.Seq)
+		}
+		dc.Close()
+
+		if !reflect.DeepEqual(seqs, tc.seqs) {
+			t.Errorf("%s: got seqs %v, want %v", tc.name, seqs, tc.seqs)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsenart/vegeta/v12/internal/aws"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// newCloudWatchReporter returns a Reporter that publishes the key Metrics to
+// AWS CloudWatch under the given namespace and dimensions with the
+// PutMetricData API, and writes out a summary of what was published.
+//
+// Credentials and region are loaded from the standard AWS environment
+// variables and shared configuration files. The API endpoint can be
+// overridden with the AWS_ENDPOINT_URL_CLOUDWATCH environment variable.
+func newCloudWatchReporter(m *vegeta.Metrics, namespace string, dimensions []string) (vegeta.Reporter, error) {
+	creds, err := aws.LoadCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	region := aws.Region()
+	if region == "" {
+		return nil, errors.New("cloudwatch: no region set in AWS_REGION or shared config file")
+	}
+
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_CLOUDWATCH")
+	if endpoint == "" {
+		endpoint = "https://monitoring." + region + ".amazonaws.com/"
+	}
+
+	dims := make([][2]string, 0, len(dimensions))
+	for _, d := range dimensions {
+		kv := strings.SplitN(d, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("cloudwatch: bad dimension %q, must be name=value", d)
+		}
+		dims = append(dims, [2]string{kv[0], kv[1]})
+	}
+
+	signer := aws.Signer{Credentials: creds, Region: region, Service: "monitoring"}
+	client := http.Client{Timeout: 30 * time.Second}
+
+	return func(w io.Writer) error {
+		data := cloudWatchMetricData(m)
+
+		form := url.Values{
+			"Action":    {"PutMetricData"},
+			"Version":   {"2010-08-01"},
+			"Namespace": {namespace},
+		}
+
+		ts := time.Now().UTC().Format(time.RFC3339)
+		for i, d := range data {
+			member := "MetricData.member." + strconv.Itoa(i+1) + "."
+			form.Set(member+"MetricName", d.name)
+			form.Set(member+"Value", strconv.FormatFloat(d.value, 'f', -1, 64))
+			form.Set(member+"Unit", d.unit)
+			form.Set(member+"Timestamp", ts)
+			for j, dim := range dims {
+				prefix := member + "Dimensions.member." + strconv.Itoa(j+1) + "."
+				form.Set(prefix+"Name", dim[0])
+				form.Set(prefix+"Value", dim[1])
+			}
+		}
+
+		body := []byte(form.Encode())
+		req, err := http.NewRequest(http.MethodPost, endpoint, nil)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+		signer.Sign(req, body, time.Now())
+
+		if err = post(&client, endpoint, body, nil, req.Header); err != nil {
+			return fmt.Errorf("cloudwatch: %v", err)
+		}
+
+		_, err = fmt.Fprintf(w, "Published %d metrics to CloudWatch namespace %s in %s\n",
+			len(data), namespace, region)
+		return err
+	}, nil
+}
+
+type cloudWatchDatum struct {
+	name  string
+	value float64
+	unit  string
+}
+
+func cloudWatchMetricData(m *vegeta.Metrics) []cloudWatchDatum {
+	ms := func(d time.Duration) float64 { return d.Seconds() * 1000 }
+	return []cloudWatchDatum{
+		{"Requests", float64(m.Requests), "Count"},
+		{"Rate", m.Rate, "Count/Second"},
+		{"Throughput", m.Throughput, "Count/Second"},
+		{"ErrorRate", (1 - m.Success) * 100, "Percent"},
+		{"LatencyMean", ms(m.Latencies.Mean), "Milliseconds"},
+		{"LatencyP50", ms(m.Latencies.P50), "Milliseconds"},
+		{"LatencyP90", ms(m.Latencies.P90), "Milliseconds"},
+		{"LatencyP95", ms(m.Latencies.P95), "Milliseconds"},
+		{"LatencyP99", ms(m.Latencies.P99), "Milliseconds"},
+		{"LatencyMax", ms(m.Latencies.Max), "Milliseconds"},
+		{"BytesIn", float64(m.BytesIn.Total), "Bytes"},
+		{"BytesOut", float64(m.BytesOut.Total), "Bytes"},
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestCloudWatchMetricData(t *testing.T) {
+	t.Parallel()
+
+	m := &vegeta.Metrics{
+		Requests:   100,
+		Rate:       50,
+		Throughput: 45,
+		Success:    0.75,
+		Latencies: vegeta.LatencyMetrics{
+			Mean: 10 * time.Millisecond,
+			P50:  8 * time.Millisecond,
+			P90:  20 * time.Millisecond,
+			P95:  25 * time.Millisecond,
+			P99:  1500 * time.Microsecond,
+			Max:  2 * time.Second,
+		},
+		BytesIn:  vegeta.ByteMetrics{Total: 1024},
+		BytesOut: vegeta.ByteMetrics{Total: 512},
+	}
+
+	got := cloudWatchMetricData(m)
+	want := []cloudWatchDatum{
+		{"Requests", 100, "Count"},
+		{"Rate", 50, "Count/Second"},
+		{"Throughput", 45, "Count/Second"},
+		{"ErrorRate", 25, "Percent"},
+		{"LatencyMean", 10, "Milliseconds"},
+		{"LatencyP50", 8, "Milliseconds"},
+		{"LatencyP90", 20, "Milliseconds"},
+		{"LatencyP95", 25, "Milliseconds"},
+		{"LatencyP99", 1.5, "Milliseconds"},
+		{"LatencyMax", 2000, "Milliseconds"},
+		{"BytesIn", 1024, "Bytes"},
+		{"BytesOut", 512, "Bytes"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCloudWatchReporter(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		forms  []url.Values
+		auths  []string
+		status int
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		form, err := url.ParseQuery(string(body))
+		if err != nil || r.Header.Get("Content-Type") != "application/x-www-form-urlencoded; charset=utf-8" {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		forms, auths = append(forms, form), append(auths, r.Header.Get("Authorization"))
+
+		if status != 0 {
+			http.Error(w, "<Error><Code>Throttling</Code></Error>", status)
+		}
+	}))
+	defer srv.Close()
+
+	defer setenv(map[string]string{
+		"AWS_ENDPOINT_URL_CLOUDWATCH": srv.URL,
+		"AWS_ACCESS_KEY_ID":           "AKID",
+		"AWS_SECRET_ACCESS_KEY":       "SECRET",
+		"AWS_REGION":                  "eu-west-1",
+	})()
+
+	m := &vegeta.Metrics{Requests: 10, Success: 1, Latencies: vegeta.LatencyMetrics{P99: time.Millisecond}}
+
+	for _, tc := range []struct {
+		name       string
+		dimensions []string
+		status     int
+		err        string
+	}{
+		{"without dimensions", nil, 0, ""},
+		{"with dimensions", []string{"Service=checkout", "Env=staging"}, 0, ""},
+		{"bad dimension", []string{"Service"}, 0, `cloudwatch: bad dimension "Service", must be name=value`},
+		{"empty dimension value", []string{"Service="}, 0, `cloudwatch: bad dimension "Service="`},
+		{"failed request", nil, http.StatusBadRequest, "cloudwatch: POST " + srv.URL + ": 400 Bad Request: <Error><Code>Throttling</Code></Error>"},
+	} {
+		mu.Lock()
+		forms, auths, status = nil, nil, tc.status
+		mu.Unlock()
+
+		rep, err := newCloudWatchReporter(m, "Vegeta/Test", tc.dimensions)
+		var out bytes.Buffer
+		if err == nil {
+			err = rep.Report(&out)
+		}
+
+		if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.HasPrefix(err.Error(), tc.err)) {
+			t.Errorf("%s: got error %v, want %q", tc.name, err, tc.err)
+		}
+
+		if tc.err != "" {
+			continue
+		}
+
+		if got, want := out.String(), "Published 12 metrics to CloudWatch namespace Vegeta/Test in eu-west-1\n"; got != want {
+			t.Errorf("%s: got output %q, want %q", tc.name, got, want)
+		}
+
+		mu.Lock()
+		if len(forms) != 1 {
+			t.Fatalf("%s: got %d requests, want 1", tc.name, len(forms))
+		}
+
+		if !strings.HasPrefix(auths[0], "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auths[0], "/eu-west-1/monitoring/aws4_request") {
+			t.Errorf("%s: got authorization %q", tc.name, auths[0])
+		}
+
+		form := forms[0]
+		for k, want := range map[string]string{
+			"Action":                         "PutMetricData",
+			"Version":                        "2010-08-01",
+			"Namespace":                      "Vegeta/Test",
+			"MetricData.member.1.MetricName": "Requests",
+			"MetricData.member.1.Value":      "10",
+			"MetricData.member.1.Unit":       "Count",
+			"MetricData.member.4.MetricName": "ErrorRate",
+			"MetricData.member.4.Value":      "0",
+			"MetricData.member.9.MetricName": "LatencyP99",
+			"MetricData.member.9.Value":      "1",
+			"MetricData.member.9.Unit":       "Milliseconds",
+		} {
+			if got := form.Get(k); got != want {
+				t.Errorf("%s: got %s %q, want %q", tc.name, k, got, want)
+			}
+		}
+
+		for i := 1; i <= 12; i++ {
+			member := "MetricData.member." + strconv.Itoa(i) + "."
+			if _, err := time.Parse(time.RFC3339, form.Get(member+"Timestamp")); err != nil {
+				t.Errorf("%s: %v", tc.name, err)
+			}
+
+			// Every metric has all the dimensions.
+			for j, d := range tc.dimensions {
+				prefix := member + "Dimensions.member." + strconv.Itoa(j+1) + "."
+				if got := form.Get(prefix+"Name") + "=" + form.Get(prefix+"Value"); got != d {
+					t.Errorf("%s: got dimension %s %q, want %q", tc.name, prefix, got, d)
+				}
+			}
+		}
+
+		if form.Get("MetricData.member.13.MetricName") != "" || form.Get("MetricData.member.1.Dimensions.member."+strconv.Itoa(len(tc.dimensions)+1)+".Name") != "" {
+			t.Errorf("%s: got extra members in %v", tc.name, form)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestCloudWatchReporterNoRegion(t *testing.T) {
+	defer setenv(map[string]string{
+		"AWS_ACCESS_KEY_ID":     "AKID",
+		"AWS_SECRET_ACCESS_KEY": "SECRET",
+		"AWS_REGION":            "",
+		"AWS_DEFAULT_REGION":    "",
+		"AWS_CONFIG_FILE":       "testdata/missing",
+	})()
+
+	_, err := newCloudWatchReporter(&vegeta.Metrics{}, "Vegeta", nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "cloudwatch: no region set") {
+		t.Errorf("got error %v, want one of the missing region", err)
+	}
+}
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const collectUsage = `Usage: vegeta collect [options]
+
+Collects the results streamed by attacks with a tcp://host:port -output and
+writes them to its output, so that attacking nodes don't need local disk.
+
+Attacks keep the results the collector hasn't acknowledged yet, up to the
+buffer query parameter of their output URL (default 10000), and block when
+it's full, which slows them down rather than losing results. They reconnect
+when their connection is lost, and resend the unacknowledged results, which
+the collector only writes once.
+
+Options:
+  --listen    Address to listen on [default: :9999]
+  --output    Output file [default: stdout]
+  --encoding  Output encoding [csv, gob, json, influx, msgpack, parquet, protobuf]
+              [default: gob]
+
+Examples:
+  vegeta collect -listen :9999 -output results.bin
+  echo "GET http://target/" | vegeta attack -duration 60s -output tcp://collector:9999`
+
+func collectCmd() command {
+	fs := flag.NewFlagSet("vegeta collect", flag.ExitOnError)
+	listen := fs.String("listen", ":9999", "Address to listen on")
+	output := fs.String("output", "stdout", "Output file")
+	encoding := fs.String("encoding", encodingGob, "Output encoding ["+strings.Join(encodings, ", ")+"]")
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, collectUsage)
+	}
+
+	return command{fs, func(args []string) error {
+		fs.Parse(args)
+		return collect(*listen, *output, *encoding)
+	}}
+}
+
+// collectMagic starts the hello of every connection of a tcp -output to its
+// collector, followed by its session ID and the index of the first Result it
+// streams. The collector acknowledges the number of Results of the session it
+// wrote with big endian uint64s.
+const collectMagic = "VGTACOL1"
+
+const (
+	collectAckInterval  = 100 * time.Millisecond
+	collectSessionTTL   = 10 * time.Minute
+	tcpDialTimeout      = 10 * time.Second
+	tcpWriteTimeout     = 30 * time.Second
+	tcpCloseTimeout     = 30 * time.Second
+	tcpMaxBackoff       = 5 * time.Second
+	tcpDefaultBufferLen = 10000
+)
+
+func collect(listen, outputf, encoding string) (err error) {
+	enc, closer, err := output(outputf, encoding, 0, nil)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %s", outputf, err)
+	}
+	defer func() {
+		if cerr := closer.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+
+	go func() {
+		<-sig
+		ln.Close()
+	}()
+
+	log.Printf("vegeta collect listening on %s", ln.Addr())
+
+	c := &collector{enc: enc, sessions: map[string]*collectSession{}}
+	return c.serve(ln)
+}
+
+// serve writes the Results streamed by the connections accepted by the given
+// listener until it's closed.
+func (c *collector) serve(ln net.Listener) error {
+	var wg sync.WaitGroup
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			c.closeAll()
+			wg.Wait()
+			if err = c.error(); err != nil {
+				return err
+			}
+			return nil
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.handle(conn); err != nil && !c.isClosed() {
+				log.Printf("collect from %s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// collector writes the Results streamed by the sessions of tcp outputs.
+type collector struct {
+	mu       sync.Mutex
+	enc      vegeta.Encoder
+	err      error
+	closed   bool
+	sessions map[string]*collectSession
+}
+
+var errCollectorClosed = errors.New("collector closed")
+
+// collectSession is the state of a tcp output, which outlives its
+// connections.
+type collectSession struct {
+	mu    sync.Mutex // Held by the connection streaming the session.
+	count uint64     // Number of Results written, accessed atomically.
+	conn  net.Conn
+	seen  time.Time
+}
+
+// session returns the session with the given ID, closing its previous
+// connection, if any, in favor of the given one.
+func (c *collector) session(id string, conn net.Conn) *collectSession {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for id, s := range c.sessions {
+		if s.conn == nil && now.Sub(s.seen) > collectSessionTTL {
+			delete(c.sessions, id)
+		}
+	}
+
+	s, ok := c.sessions[id]
+	if !ok {
+		s = &collectSession{}
+		c.sessions[id] = s
+	} else if s.conn != nil {
+		s.conn.Close()
+	}
+
+	s.conn, s.seen = conn, now
+	return s
+}
+
+// release detaches the given connection from the session, unless it was
+// replaced.
+func (c *collector) release(s *collectSession, conn net.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s.conn == conn {
+		s.conn, s.seen = nil, time.Now()
+	}
+}
+
+// closeAll stops writing Results and closes the connections of all sessions
+// once they acknowledge all their written Results, which aren't resent to the
+// next collector.
+func (c *collector) closeAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	for _, s := range c.sessions {
+		if s.conn != nil {
+			var buf [8]byte
+			binary.BigEndian.PutUint64(buf[:], atomic.LoadUint64(&s.count))
+			s.conn.SetWriteDeadline(time.Now().Add(time.Second))
+			s.conn.Write(buf[:])
+			s.conn.Close()
+		}
+	}
+}
+
+// encode writes the given Result of the given session.
+func (c *collector) encode(s *collectSession, r *vegeta.Result) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return errCollectorClosed
+	} else if c.err == nil {
+		c.err = c.enc.Encode(r)
+	}
+
+	if c.err == nil {
+		atomic.AddUint64(&s.count, 1)
+	}
+	return c.err
+}
+
+func (c *collector) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *collector) error() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// handle writes the Results streamed by the given connection, skipping those
+// of its session which were already written, and acknowledges them.
+func (c *collector) handle(conn net.Conn) error {
+	defer conn.Close()
+
+	hello := make([]byte, len(collectMagic)+16+8)
+	conn.SetReadDeadline(time.Now().Add(tcpDialTimeout))
+	if _, err := io.ReadFull(conn, hello); err != nil {
+		return err
+	} else if !bytes.HasPrefix(hello, []byte(collectMagic)) {
+		return errors.New("not a vegeta tcp output")
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	s := c.session(string(hello[len(collectMagic):len(collectMagic)+16]), conn)
+	defer c.release(s, conn)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Sessions resumed from a previous collector, e.g. after a restart,
+	// continue from their first unacknowledged Result.
+	i := binary.BigEndian.Uint64(hello[len(collectMagic)+16:])
+	if i > atomic.LoadUint64(&s.count) {
+		atomic.StoreUint64(&s.count, i)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go c.ack(conn, &s.count, done)
+
+	dec := vegeta.NewDecoder(bufio.NewReader(conn))
+	for ; ; i++ {
+		r := &vegeta.Result{}
+		if err := dec.Decode(r); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if i < atomic.LoadUint64(&s.count) {
+			continue // Resent after a lost acknowledgment.
+		}
+
+		if err := c.encode(s, r); err == errCollectorClosed {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// ack writes the given count to the connection whenever it changes, until done
+// is closed.
+func (c *collector) ack(conn net.Conn, count *uint64, done <-chan struct{}) {
+	ticker := time.NewTicker(collectAckInterval)
+	defer ticker.Stop()
+
+	var (
+		buf   [8]byte
+		acked uint64
+	)
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		if n := atomic.LoadUint64(count); n != acked {
+			binary.BigEndian.PutUint64(buf[:], n)
+			conn.SetWriteDeadline(time.Now().Add(tcpWriteTimeout))
+			if _, err := conn.Write(buf[:]); err != nil {
+				return
+			}
+			acked = n
+		}
+	}
+}
+
+// tcpSink streams Results, including metadata records, to the vegeta collect
+// command listening on the address given by the URL host. The buffer query
+// parameter is the maximum number of Results which weren't acknowledged by
+// the collector yet, beyond which Encode blocks.
+// e.g. tcp://collector:9999?buffer=10000
+func tcpSink(u *url.URL, _ http.Header) (vegeta.Encoder, io.Closer, error) {
+	if u.Host == "" {
+		return nil, nil, fmt.Errorf("tcp: missing address in %s", u)
+	}
+
+	w := &tcpWriter{addr: u.Host, buffer: tcpDefaultBufferLen, done: make(chan struct{}), quit: make(chan struct{})}
+	if b := u.Query().Get("buffer"); b != "" {
+		n, err := strconv.Atoi(b)
+		if err != nil || n <= 0 {
+			return nil, nil, fmt.Errorf("tcp: bad buffer %q", b)
+		}
+		w.buffer = n
+	}
+
+	if _, err := rand.Read(w.session[:]); err != nil {
+		return nil, nil, err
+	}
+
+	w.cond = sync.NewCond(&w.mu)
+	go w.loop()
+
+	return w.Encode, w, nil
+}
+
+// tcpWriter streams Results to a collector, keeping those it didn't
+// acknowledge to resend them on new connections.
+type tcpWriter struct {
+	addr    string
+	buffer  int
+	session [16]byte
+	done    chan struct{} // Closed once all Results are acknowledged.
+	quit    chan struct{} // Closed when Close times out.
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []*vegeta.Result // Results from the acked-th on.
+	acked   uint64
+	closed  bool
+	conn    net.Conn
+	connErr error // Error of conn.
+}
+
+// Encode adds a copy of the given Result to the ones to stream, blocking
+// while the buffer is full.
+func (w *tcpWriter) Encode(r *vegeta.Result) error {
+	cp := *r
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for len(w.pending) >= w.buffer && !w.closed {
+		w.cond.Wait()
+	}
+
+	if w.closed {
+		return errors.New("tcp: output closed")
+	}
+
+	w.pending = append(w.pending, &cp)
+	w.cond.Broadcast()
+	return nil
+}
+
+// Close waits for the collector to acknowledge all Results, up to
+// tcpCloseTimeout.
+func (w *tcpWriter) Close() error {
+	w.mu.Lock()
+	w.closed = true
+	w.cond.Broadcast()
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-time.After(tcpCloseTimeout):
+		close(w.quit)
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return fmt.Errorf("tcp: %d results weren't acknowledged by %s", len(w.pending), w.addr)
+	}
+}
+
+// loop connects to the collector and streams Results to it, reconnecting
+// with an exponential backoff whenever its connection is lost.
+func (w *tcpWriter) loop() {
+	backoff := collectAckInterval
+	for {
+		w.mu.Lock()
+		finished := w.closed && len(w.pending) == 0
+		w.mu.Unlock()
+
+		if finished {
+			close(w.done)
+			return
+		}
+
+		conn, err := net.DialTimeout("tcp", w.addr, tcpDialTimeout)
+		if err == nil {
+			if err = w.stream(conn); err == nil {
+				conn.Close()
+				continue
+			}
+			conn.Close()
+			backoff = collectAckInterval
+		}
+
+		log.Printf("tcp output to %s: %v, reconnecting in %s", w.addr, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-w.quit:
+			return
+		}
+
+		if backoff *= 2; backoff > tcpMaxBackoff {
+			backoff = tcpMaxBackoff
+		}
+	}
+}
+
+// stream streams the Results the collector didn't acknowledge to the given
+// connection until it fails, or they're all acknowledged once closed.
+func (w *tcpWriter) stream(conn net.Conn) error {
+	w.mu.Lock()
+	next := w.acked
+	w.conn, w.connErr = conn, nil
+	w.mu.Unlock()
+
+	hello := make([]byte, 0, len(collectMagic)+16+8)
+	hello = append(append([]byte(collectMagic), w.session[:]...), make([]byte, 8)...)
+	binary.BigEndian.PutUint64(hello[len(collectMagic)+16:], next)
+
+	conn.SetWriteDeadline(time.Now().Add(tcpWriteTimeout))
+	if _, err := conn.Write(hello); err != nil {
+		return err
+	}
+
+	go w.readAcks(conn)
+
+	bw := bufio.NewWriter(conn)
+	enc := vegeta.NewEncoder(bw)
+	for {
+		w.mu.Lock()
+		for w.connErr == nil && next == w.acked+uint64(len(w.pending)) && !(w.closed && len(w.pending) == 0) {
+			w.cond.Wait()
+		}
+
+		if w.connErr != nil {
+			err := w.connErr
+			w.mu.Unlock()
+			return err
+		} else if len(w.pending) == 0 && w.closed {
+			w.mu.Unlock()
+			return nil
+		}
+
+		batch := w.pending[next-w.acked:]
+		w.mu.Unlock()
+
+		conn.SetWriteDeadline(time.Now().Add(tcpWriteTimeout))
+		for _, r := range batch {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+			next++
+		}
+
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// readAcks reads the acknowledgments of the collector from the given
+// connection, dropping the acknowledged Results, until it fails.
+func (w *tcpWriter) readAcks(conn net.Conn) {
+	var buf [8]byte
+	for {
+		_, err := io.ReadFull(conn, buf[:])
+
+		w.mu.Lock()
+		if err != nil && w.conn != conn {
+			// The connection was replaced after failing to write.
+		} else if err != nil {
+			if err == io.EOF {
+				err = errors.New("connection closed by collector")
+			}
+			w.connErr = err
+		} else if n := binary.BigEndian.Uint64(buf[:]); n > w.acked {
+			drop := n - w.acked
+			if drop > uint64(len(w.pending)) {
+				drop = uint64(len(w.pending))
+			}
+			w.pending = append(w.pending[:0:0], w.pending[drop:]...)
+			w.acked += drop
+		}
+		w.cond.Broadcast()
+		w.mu.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// flakyProxy forwards connections to addr, dropping the first one once it
+// forwarded the given number of bytes to it.
+type flakyProxy struct {
+	ln    net.Listener
+	addr  string
+	after int64
+	conns int32
+}
+
+func (p *flakyProxy) serve() {
+	for {
+		client, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		server, err := net.Dial("tcp", p.addr)
+		if err != nil {
+			client.Close()
+			continue
+		}
+
+		drop := atomic.AddInt32(&p.conns, 1) == 1
+		go func() {
+			defer client.Close()
+			defer server.Close()
+			if drop {
+				io.CopyN(server, client, p.after)
+			} else {
+				io.Copy(server, client)
+			}
+		}()
+
+		go func() {
+			defer client.Close()
+			io.Copy(client, server)
+		}()
+	}
+}
+
+func TestCollect(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu   sync.Mutex
+		seqs []uint64
+	)
+
+	c := &collector{
+		enc: func(r *vegeta.Result) error {
+			mu.Lock()
+			defer mu.Unlock()
+			seqs = append(seqs, r.Seq)
+			return nil
+		},
+		sessions: map[string]*collectSession{},
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(chan error)
+	go func() { served <- c.serve(ln) }()
+
+	pln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pln.Close()
+
+	// The first connection is dropped in the middle of the stream, after some
+	// results were written and acknowledged, and before others were.
+	proxy := &flakyProxy{ln: pln, addr: ln.Addr().String(), after: 32 << 10}
+	go proxy.serve()
+
+	enc, closer, err := tcpSink(&url.URL{Scheme: "tcp", Host: pln.Addr().String(), RawQuery: "buffer=500"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 5000
+	for i := 0; i < n; i++ {
+		r := vegeta.Result{
+			Seq:       uint64(i),
+			Code:      200,
+			URL:       "http://127.0.0.1/",
+			Method:    "GET",
+			Timestamp: time.Unix(0, int64(i)),
+			Latency:   time.Millisecond,
+		}
+
+		if err = enc.Encode(&r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ln.Close()
+	if err = <-served; err != nil {
+		t.Fatal(err)
+	}
+
+	if conns := atomic.LoadInt32(&proxy.conns); conns < 2 {
+		t.Fatalf("got %d connections, want the first one to be dropped", conns)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(seqs) != n {
+		t.Errorf("got %d results, want %d", len(seqs), n)
+	}
+
+	for i, seq := range seqs {
+		if seq != uint64(i) {
+			t.Fatalf("got result %d at %d, want results in order without losses or duplicates", seq, i)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// controlServer serves the HTTP API controlling a running attack:
+//   GET  /metrics  JSON metrics of the results so far.
+//   GET  /rate     Current rate, e.g. 50/1s.
+//   PUT  /rate     Change the rate to the one in the request body.
+//   POST /pause    Pause the attack.
+//   POST /resume   Resume the attack.
+//   POST /stop     Stop the attack once in-flight requests complete.
+type controlServer struct {
+	atk    *vegeta.Attacker
+	srv    *http.Server
+	stop   chan struct{}
+	once   sync.Once
+	pauses chan bool
+	closed chan struct{}
+
+	mu   sync.Mutex
+	rate vegeta.Rate
+	m    vegeta.Metrics
+}
+
+// newControlServer starts serving the control API of the given attack, at the
+// given rate, on the given address, to requests with the given bearer token,
+// which is required unless the address is on localhost.
+func newControlServer(addr, token string, atk *vegeta.Attacker, rate vegeta.Rate) (*controlServer, error) {
+	if token == "" && !loopback(addr) {
+		return nil, fmt.Errorf("-control-token is required to serve the control API on %s, which isn't localhost", addr)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &controlServer{
+		atk:    atk,
+		rate:   rate,
+		stop:   make(chan struct{}),
+		pauses: make(chan bool),
+		closed: make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", c.metrics)
+	mux.HandleFunc("/rate", c.setRate)
+	mux.HandleFunc("/pause", c.post(func() { c.pause(true) }))
+	mux.HandleFunc("/resume", c.post(func() { c.pause(false) }))
+	mux.HandleFunc("/stop", c.post(func() { c.once.Do(func() { close(c.stop) }) }))
+
+	c.srv = &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := []byte(r.Header.Get("Authorization"))
+		if token != "" && subtle.ConstantTimeCompare(auth, []byte("Bearer "+token)) != 1 {
+			http.Error(w, "bad control token", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})}
+	go c.srv.Serve(ln)
+
+	log.Printf("attack control API listening on %s", ln.Addr())
+	return c, nil
+}
+
+// currentRate returns the rate of the attack, as last set.
+func (c *controlServer) currentRate() vegeta.Rate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rate
+}
+
+// add adds the given Result to the metrics of the attack.
+func (c *controlServer) add(r *vegeta.Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.m.Add(r)
+}
+
+// stopped returns a channel which is closed when the attack is stopped.
+func (c *controlServer) stopped() <-chan struct{} {
+	return c.stop
+}
+
+// pause requests the attack to be paused or resumed, unless the control API
+// is closed.
+func (c *controlServer) pause(paused bool) {
+	select {
+	case c.pauses <- paused:
+	case <-c.closed:
+	}
+}
+
+// paused returns a channel of the requests to pause (true) or resume (false)
+// the attack, which the attack carries out so that it can annotate its
+// results.
+func (c *controlServer) paused() <-chan bool {
+	return c.pauses
+}
+
+// Close stops serving the control API.
+func (c *controlServer) Close() error {
+	close(c.closed)
+	return c.srv.Close()
+}
+
+func (c *controlServer) metrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.m.Close()
+	w.Header().Set("Content-Type", "application/json")
+	vegeta.NewJSONReporter(&c.m).Report(w)
+}
+
+func (c *controlServer) setRate(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var rate vegeta.Rate
+		if err = (&rateFlag{&rate}).Set(strings.TrimSpace(string(body))); err == nil && (rate.Freq <= 0 || rate.Per <= 0) {
+			err = errZeroRate
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		c.rate = rate
+		c.atk.SetPacer(rate)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fmt.Fprintln(w, (&rateFlag{&c.rate}).String())
+}
+
+// post returns a handler of POST requests calling fn.
+func (c *controlServer) post(fn func()) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		fn()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestControlServerToken(t *testing.T) {
+	t.Parallel()
+
+	rate := vegeta.Rate{Freq: 50, Per: 1e9}
+
+	// Addresses other than localhost require a token.
+	_, err := newControlServer(":0", "", vegeta.NewAttacker(), rate)
+	if want := "-control-token is required to serve the control API on :0, which isn't localhost"; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+
+	c, err := newControlServer("127.0.0.1:0", "secret", vegeta.NewAttacker(), rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for _, tc := range []struct {
+		auth string
+		code int
+		body string
+	}{
+		{"", http.StatusUnauthorized, "bad control token\n"},
+		{"Bearer wrong", http.StatusUnauthorized, "bad control token\n"},
+		{"Bearer secret", http.StatusOK, "50/1s\n"},
+	} {
+		req := httptest.NewRequest("GET", "/rate", nil)
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+
+		w := httptest.NewRecorder()
+		c.srv.Handler.ServeHTTP(w, req)
+
+		if w.Code != tc.code || w.Body.String() != tc.body {
+			t.Errorf("%q: got %d %q, want %d %q", tc.auth, w.Code, w.Body, tc.code, tc.body)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const diffUsage = `Usage: vegeta diff [options] <baseline> <candidate>
+
+Outputs a report of the per-metric differences, absolute and in percentage
+of the baseline, between the results of two attacks. The compared metrics
+are the request count, rate and throughput, the success and error rates
+and the min, mean, 50th, 90th, 95th, 99th percentile and max latencies.
+
+A two-sided Mann-Whitney U test of the latencies tells whether the
+candidate's latencies differ significantly from the baseline's or whether
+the difference is likely to be noise, at the --alpha significance level.
+
+The command exits with status 4 when the candidate's latencies are
+significantly greater than the baseline's, or when a metric of the
+candidate regresses by more than its --threshold, in percentage of the
+baseline. Metrics whose baseline is zero regress beyond any threshold when
+they get any worse.
+
+Arguments:
+  <baseline>   A file with the baseline attack results encoded with one of
+               the supported encodings (gob | json | csv | msgpack | parquet | protobuf)
+  <candidate>  A file with the candidate attack results encoded with one of
+               the supported encodings (gob | json | csv | msgpack | parquet | protobuf)
+
+Options:
+  --type       Which report type to generate (text | json) [default: text]
+  --alpha      Significance level of the latency Mann-Whitney U test.
+               0 disables the test. [default: 0.05]
+  --threshold  Maximum regression of a metric in percentage of the baseline,
+               as metric=percent, e.g. p99=10% (repeatable) [default: none]
+  --output     Output file [default: stdout]
+
+Examples:
+  echo "GET http://:80" | vegeta attack -duration=30s > baseline.bin
+  echo "GET http://:80" | vegeta attack -duration=30s > candidate.bin
+  vegeta diff baseline.bin candidate.bin
+  vegeta diff -threshold p99=10% -threshold error_rate=0% baseline.bin candidate.bin`
+
+func diffCmd() command {
+	fs := flag.NewFlagSet("vegeta diff", flag.ExitOnError)
+	typ := fs.String("type", "text", "Report type to generate [text, json]")
+	output := fs.String("output", "stdout", "Output file")
+	alpha := fs.Float64("alpha", 0.05, "Significance level of the latency Mann-Whitney U test (0 disables it)")
+	thresholds := diffThresholds{}
+	fs.Var(thresholds, "threshold", "Maximum regression of a metric in percentage of the baseline, e.g. p99=10% (repeatable)")
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, diffUsage)
+	}
+
+	return command{fs, func(args []string) error {
+		fs.Parse(args)
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return fmt.Errorf("diff: want a baseline and a candidate file, got %d files", fs.NArg())
+		}
+		return diff(fs.Arg(0), fs.Arg(1), *typ, *alpha, thresholds, *output)
+	}}
+}
+
+// regressedExitCode is the exit code of diffs whose candidate regressed
+// significantly or beyond a -threshold.
+const regressedExitCode = 4
+
+func diff(baseline, candidate, typ string, alpha float64, thresholds diffThresholds, output string) error {
+	var c vegeta.Comparison
+
+	var rep vegeta.Reporter
+	switch typ {
+	case "text":
+		rep = vegeta.NewDiffTextReporter(&c)
+	case "json":
+		rep = vegeta.NewDiffJSONReporter(&c)
+	default:
+		return fmt.Errorf("unknown diff report type: %q", typ)
+	}
+
+	var (
+		ms   [2]vegeta.Metrics
+		lats [2][]time.Duration
+	)
+
+	for i, name := range []string{baseline, candidate} {
+		if err := metricsOf(name, &ms[i], &lats[i]); err != nil {
+			return err
+		}
+	}
+
+	c.Deltas = vegeta.Diff(&ms[0], &ms[1])
+	if alpha > 0 {
+		c.Significance = vegeta.MannWhitneyU(lats[0], lats[1], alpha)
+	}
+
+	out, err := file(output, true)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err = rep.Report(out); err != nil {
+		return err
+	}
+
+	regressed := regressions(c.Deltas, thresholds)
+	if s := c.Significance; s != nil && s.Significant && s.Effect > 0.5 {
+		regressed = append(regressed, fmt.Sprintf("latencies significantly (p=%.4f, alpha=%s)",
+			s.P, strconv.FormatFloat(s.Alpha, 'f', -1, 64)))
+	}
+
+	if len(regressed) > 0 {
+		return &exitError{regressedExitCode, fmt.Errorf("candidate regressed: %s", strings.Join(regressed, ", "))}
+	}
+	return nil
+}
+
+// higherIsBetter are the DiffMetrics which regress when they decrease.
+var higherIsBetter = map[string]bool{
+	"requests":   true,
+	"rate":       true,
+	"throughput": true,
+	"success":    true,
+}
+
+// regressions describes the given Deltas which regressed beyond their
+// thresholds.
+func regressions(ds []vegeta.Delta, thresholds diffThresholds) []string {
+	var regressed []string
+	for _, d := range ds {
+		max, ok := thresholds[d.Metric]
+		if !ok || d.Delta == 0 || (d.Delta > 0) == higherIsBetter[d.Metric] {
+			continue // Unchanged or improved.
+		}
+
+		if d.Percent == nil {
+			regressed = append(regressed, d.Metric+" from a zero baseline")
+		} else if pct := math.Abs(*d.Percent); pct > max {
+			regressed = append(regressed, fmt.Sprintf("%s by %.2f%% (threshold %s%%)",
+				d.Metric, pct, strconv.FormatFloat(max, 'f', -1, 64)))
+		}
+	}
+	return regressed
+}
+
+// metricsOf adds all results in the given file to the given Metrics
+// and appends their latencies to lats.
+func metricsOf(name string, m *vegeta.Metrics, lats *[]time.Duration) error {
+	dec, mc, err := decoder([]string{name}, nil)
+	defer mc.Close()
+	if err != nil {
+		return err
+	}
+
+	for {
+		var r vegeta.Result
+		if err = dec.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		m.Add(&r)
+		*lats = append(*lats, r.Latency)
+	}
+
+	m.Close()
+	return nil
+}
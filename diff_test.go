@@ -0,0 +1,153 @@
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestDiffThresholds(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		in   string
+		want diffThresholds
+		err  bool
+	}{
+		{in: "p99=10%", want: diffThresholds{"p99": 10}},
+		{in: " Error_Rate = 0.5 ", want: diffThresholds{"error_rate": 0.5}},
+		{in: "success=0%", want: diffThresholds{"success": 0}},
+		{in: "p99", err: true},
+		{in: "p99.9=10%", err: true},
+		{in: "latency=10%", err: true},
+		{in: "p99=-1%", err: true},
+		{in: "p99=ten", err: true},
+	} {
+		got := diffThresholds{}
+		if err := got.Set(tc.in); tc.err != (err != nil) {
+			t.Errorf("Set(%q): got error %v, want error %v", tc.in, err, tc.err)
+		} else if !tc.err && !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Set(%q): got %v, want %v", tc.in, got, tc.want)
+		}
+	}
+
+	ts := diffThresholds{}
+	for _, v := range []string{"p99=10%", "success=1", "p99=5%"} {
+		if err := ts.Set(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := ts.String(), "p99=5%, success=1%"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRegressions(t *testing.T) {
+	t.Parallel()
+
+	delta := func(metric string, baseline, candidate float64) vegeta.Delta {
+		d := vegeta.Delta{Metric: metric, Baseline: baseline, Candidate: candidate, Delta: candidate - baseline}
+		if baseline != 0 {
+			pct := d.Delta / math.Abs(baseline) * 100
+			d.Percent = &pct
+		}
+		return d
+	}
+
+	thresholds := diffThresholds{"p99": 10, "success": 1, "error_rate": 0, "rate": 5}
+	for _, tc := range []struct {
+		name  string
+		delta vegeta.Delta
+		want  []string
+	}{
+		{"within threshold", delta("p99", 100, 110), nil},
+		{"beyond threshold", delta("p99", 100, 120), []string{"p99 by 20.00% (threshold 10%)"}},
+		{"improved", delta("p99", 100, 50), nil},
+		{"lower is worse", delta("success", 1, 0.95), []string{"success by 5.00% (threshold 1%)"}},
+		{"higher is better", delta("rate", 100, 200), nil},
+		{"zero baseline", delta("error_rate", 0, 0.02), []string{"error_rate from a zero baseline"}},
+		{"zero baseline unchanged", delta("error_rate", 0, 0), nil},
+		{"without threshold", delta("max", 100, 1000), nil},
+	} {
+		if got := regressions([]vegeta.Delta{tc.delta}, thresholds); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDiffExitCode(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "vegeta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// writeResults writes results with the given latencies to the named file.
+	writeResults := func(name string, latency time.Duration) string {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		enc := vegeta.NewEncoder(f)
+		for i := 0; i < 100; i++ {
+			r := vegeta.Result{
+				Code:      200,
+				Timestamp: time.Unix(int64(i), 0),
+				Latency:   latency + time.Duration(i)*time.Microsecond,
+			}
+			if err = enc.Encode(&r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return f.Name()
+	}
+
+	baseline := writeResults("baseline.bin", 10*time.Millisecond)
+	candidate := writeResults("candidate.bin", 12*time.Millisecond)
+	output := filepath.Join(dir, "diff.txt")
+
+	for _, tc := range []struct {
+		name       string
+		baseline   string
+		candidate  string
+		alpha      float64
+		thresholds diffThresholds
+		code       int
+	}{
+		{"without thresholds", baseline, candidate, 0, diffThresholds{}, 0},
+		{"within thresholds", baseline, candidate, 0, diffThresholds{"p99": 25, "requests": 0}, 0},
+		{"beyond a threshold", baseline, candidate, 0, diffThresholds{"p99": 10, "requests": 0}, regressedExitCode},
+		{"significantly slower", baseline, candidate, 0.05, diffThresholds{}, regressedExitCode},
+		{"significantly faster", candidate, baseline, 0.05, diffThresholds{}, 0},
+		{"not significantly slower", baseline, baseline, 0.05, diffThresholds{}, 0},
+	} {
+		err := diff(tc.baseline, tc.candidate, "text", tc.alpha, tc.thresholds, output)
+
+		code := 0
+		if e, ok := err.(*exitError); ok {
+			code = e.code
+		} else if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		if code != tc.code {
+			t.Errorf("%s: got exit code %d (%v), want %d", tc.name, code, err, tc.code)
+		}
+
+		// The report is written either way.
+		if bs, err := ioutil.ReadFile(output); err != nil || len(bs) == 0 {
+			t.Errorf("%s: got report %q, %v", tc.name, bs, err)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// distributedStartDelay is the delay after which distributed attacks start on
+// all their workers at once, which leaves time to send them their plans.
+const distributedStartDelay = 2 * time.Second
+
+// workerPlan is the plan of a distributed attack sent by its coordinator to
+// each of its workers.
+type workerPlan struct {
+	// Args are the attack command arguments of the worker, with its share
+	// of the rate.
+	Args []string `json:"args"`
+	// Targets and Body are the contents of the -targets and -body files.
+	Targets []byte `json:"targets"`
+	Body    []byte `json:"body,omitempty"`
+	// Start is the time at which all workers start attacking.
+	Start time.Time `json:"start"`
+}
+
+// coordinatorFlags are the flags of the attack command which only apply to
+// the coordinator of a distributed attack, and aren't passed to its workers.
+var coordinatorFlags = map[string]bool{
+	"distributed":    true,
+	"worker-token":   true,
+	"agent":          true,
+	"agent-token":    true,
+	"agent-interval": true,
+	"kubernetes":     true,
+	"rate":           true,
+	"targets":        true,
+	"body":           true,
+	"lazy":           true,
+	"output":         true,
+	"encoding":       true,
+	"rotate-size":    true,
+	"sink-header":    true,
+	"webhook":        true,
+	"webhook-format": true,
+	"slo":            true,
+	"start-at":       true,
+	"stop-if":        true,
+	"stop-window":    true,
+	"breaker":        true,
+	"breaker-window": true,
+	"breaker-rate":   true,
+}
+
+// workerArgs returns the given attack command arguments without the
+// coordinatorFlags.
+func workerArgs(fs *flag.FlagSet, args []string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" || !strings.HasPrefix(args[i], "-") {
+			break
+		}
+
+		name := strings.TrimLeft(args[i], "-")
+		value := strings.Contains(name, "=")
+		if value {
+			name = name[:strings.IndexByte(name, '=')]
+		}
+
+		// Flags other than boolean ones take the next argument as their
+		// value unless it's given after an =.
+		n := 1
+		if f := fs.Lookup(name); f != nil && !value && !isBoolFlag(f) && i+1 < len(args) {
+			n = 2
+		}
+
+		if !coordinatorFlags[name] {
+			out = append(out, args[i:i+n]...)
+		}
+
+		i += n - 1
+	}
+	return out
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// workerRate returns the share of the given rate of the i-th of n workers.
+func workerRate(rate vegeta.Rate, n, i int) vegeta.Rate {
+	share := rate.Freq / n
+	if i < rate.Freq%n {
+		share++
+	}
+	return vegeta.Rate{Freq: share, Per: rate.Per}
+}
+
+// distributedAttack is an attack distributed across workers, each attacking
+// at its share of the rate, whose results are merged.
+type distributedAttack struct {
+	results chan *vegeta.Result
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mu       sync.Mutex
+	firstErr error
+}
+
+// runDistributed runs the given function for each of n workers with their
+// share of the given rate, except those without any, and returns the attack.
+// Its results channel is closed once they all return and cleanup, if not
+// nil, is done.
+func runDistributed(n int, rate vegeta.Rate, run func(ctx context.Context, d *distributedAttack, i int, rate vegeta.Rate) error, cleanup func()) *distributedAttack {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &distributedAttack{
+		results: make(chan *vegeta.Result),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		share := workerRate(rate, n, i)
+		if rate.Freq > 0 && share.Freq == 0 {
+			continue // There are more workers than hits per time unit.
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := run(ctx, d, i, share); err != nil && ctx.Err() == nil {
+				d.fail(err)
+			}
+		}(i)
+	}
+
+	go func() {
+		wg.Wait()
+		if cleanup != nil {
+			cleanup()
+		}
+		close(d.done)
+		close(d.results)
+	}()
+
+	return d
+}
+
+// distribute starts the attack described by the given options on all its
+// -distributed workers at the given time, with the given targets and body,
+// and returns it. The results of each worker are labeled with its address as
+// their node.
+func distribute(opts *attackOpts, start time.Time, targets, body []byte) *distributedAttack {
+	return runDistributed(len(opts.distributed), opts.rate, func(ctx context.Context, d *distributedAttack, i int, rate vegeta.Rate) error {
+		plan := workerPlan{
+			Args:    append(append([]string{}, opts.workerArgs...), "-rate="+(&rateFlag{&rate}).String()),
+			Targets: targets,
+			Body:    body,
+			Start:   start,
+		}
+
+		addr := opts.distributed[i]
+		if err := d.run(ctx, addr, opts.workerToken, &plan); err != nil {
+			return fmt.Errorf("worker %s: %v", addr, err)
+		}
+		return nil
+	}, nil)
+}
+
+// run sends the given plan to the worker at addr and streams back its results.
+func (d *distributedAttack) run(ctx context.Context, addr, token string, plan *workerPlan) error {
+	bs, err := json.Marshal(plan)
+	if err != nil {
+		return err
+	}
+
+	u := addr
+	if !strings.Contains(u, "://") {
+		u = "http://" + u
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(u, "/")+"/attack", bytes.NewReader(bs))
+	if err != nil {
+		return err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+
+	// The coordinator writes the metadata record of the whole attack.
+	dec := vegeta.NewMetadataDecoder(vegeta.NewDecoder(res.Body), nil)
+	for {
+		r := &vegeta.Result{}
+		if err = dec.Decode(r); err == io.EOF || ctx.Err() != nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err = d.send(ctx, addr, r); err != nil {
+			return nil
+		}
+	}
+}
+
+// send sends the given result of a worker to the attack's results, labeled
+// with the worker as its node, unless the attack is stopped.
+func (d *distributedAttack) send(ctx context.Context, node string, r *vegeta.Result) error {
+	if r.Labels == nil {
+		r.Labels = map[string]string{}
+	}
+	r.Labels["node"] = node
+
+	select {
+	case d.results <- r:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fail records the first error of the attack's workers and stops the others.
+func (d *distributedAttack) fail(err error) {
+	d.mu.Lock()
+	if d.firstErr == nil {
+		d.firstErr = err
+	}
+	d.mu.Unlock()
+	d.cancel()
+}
+
+// stop stops the attack on all its workers and waits for its cleanup.
+func (d *distributedAttack) stop() {
+	d.cancel()
+	<-d.done
+}
+
+// err returns the first error of the attack's workers, once its results
+// channel is closed.
+func (d *distributedAttack) err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.firstErr
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestWorkerRate(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		rate vegeta.Rate
+		n    int
+		want []int
+	}{
+		{vegeta.Rate{Freq: 5, Per: time.Second}, 2, []int{3, 2}},
+		{vegeta.Rate{Freq: 1, Per: time.Second}, 3, []int{1, 0, 0}},
+		{vegeta.Rate{Freq: 60000, Per: time.Second}, 3, []int{20000, 20000, 20000}},
+		{vegeta.Rate{Freq: 10, Per: time.Minute}, 4, []int{3, 3, 2, 2}},
+		{vegeta.Rate{Freq: 0, Per: time.Second}, 2, []int{0, 0}},
+	} {
+		var sum int
+		got := make([]int, tc.n)
+		for i := range got {
+			r := workerRate(tc.rate, tc.n, i)
+			if r.Per != tc.rate.Per {
+				t.Errorf("%v over %d workers: got per %s, want %s", tc.rate, tc.n, r.Per, tc.rate.Per)
+			}
+			got[i], sum = r.Freq, sum+r.Freq
+		}
+
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v over %d workers: got shares %v, want %v", tc.rate, tc.n, got, tc.want)
+		} else if sum != tc.rate.Freq {
+			t.Errorf("%v over %d workers: got shares adding up to %d", tc.rate, tc.n, sum)
+		}
+	}
+}
+
+func TestWorkerArgs(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name string
+		args string
+		want string
+	}{
+		{"empty", "", ""},
+		{"flag value pair", "-duration 10s -rate 50 -timeout 5s", "-duration 10s -timeout 5s"},
+		{"flag with =", "-duration=10s -rate=50", "-duration=10s"},
+		{"double dashes", "--duration 10s --output results.bin", "--duration 10s"},
+		{"bool flags", "-http2 -insecure -output results.bin -keepalive=false", "-http2 -insecure -keepalive=false"},
+		{"coordinator flags", "-distributed a:8099,b:8099 -worker-token s3cret -name checkout", "-name checkout"},
+		{"repeated flags", "-header A:1 -targets t.txt -header B:2", "-header A:1 -header B:2"},
+		{"terminator", "-duration 10s -- -rate 50", "-duration 10s"},
+		{"arguments", "-duration 10s extra -rate 50", "-duration 10s"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs, _ := attackFlags(flag.ContinueOnError)
+			got := strings.Join(workerArgs(fs, strings.Fields(tc.args)), " ")
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWorkerFlags(t *testing.T) {
+	t.Parallel()
+
+	// Flags which workers refuse since they run commands, load code, serve,
+	// read or write files on their hosts.
+	refused := map[string]bool{
+		"cert":            true,
+		"cert-pool":       true,
+		"checkpoint":      true,
+		"control":         true,
+		"control-token":   true,
+		"hook-command":    true,
+		"key":             true,
+		"key-signer":      true,
+		"plugin":          true,
+		"profile-cpu":     true,
+		"profile-dir":     true,
+		"profile-if":      true,
+		"profile-webhook": true,
+		"resume":          true,
+		"root-certs":      true,
+		"rotate-header":   true,
+		"script":          true,
+		"tls-keylog":      true,
+		"unix-socket":     true,
+	}
+
+	fs, _ := attackFlags(flag.ContinueOnError)
+	fs.VisitAll(func(f *flag.Flag) {
+		n := 0
+		for _, set := range []map[string]bool{workerFlags, refused, coordinatorFlags} {
+			if set[f.Name] {
+				n++
+			}
+		}
+
+		// -rate is set by coordinators for each worker.
+		if f.Name == "rate" && n != 2 || f.Name != "rate" && n != 1 {
+			t.Errorf("flag -%s isn't either accepted by workers, refused by them or of coordinators only", f.Name)
+		}
+	})
+
+	for name := range workerFlags {
+		if fs.Lookup(name) == nil && name != "resolvers" {
+			t.Errorf("worker flag -%s isn't an attack flag", name)
+		}
+	}
+}
+
+func TestWorkerHandler(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(&workerHandler{token: "s3cret"})
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		args  []string
+		code  int
+		msg   string
+	}{
+		{"bad token", "wrong", []string{"-duration=1s"}, http.StatusUnauthorized, "bad worker token"},
+		{"hook command", "s3cret", []string{"-hook-command", "rm -rf /"}, http.StatusBadRequest, "flags not allowed: -hook-command"},
+		{"key signer", "s3cret", []string{"-key-signer=./sign.sh"}, http.StatusBadRequest, "flags not allowed: -key-signer"},
+		{"coordinator flags", "s3cret", []string{"-output=/etc/passwd", "-plugin=x.so"}, http.StatusBadRequest, "flags not allowed: -output, -plugin"},
+		{"file reading flags", "s3cret", []string{"-rotate-header", "X:/etc/shadow", "-cert=/etc/ssl/private/host.pem"}, http.StatusBadRequest, "flags not allowed: -cert, -rotate-header"},
+		{"file writing flags", "s3cret", []string{"-profile-if=p99>1ms", "-profile-cpu=1s"}, http.StatusBadRequest, "flags not allowed: -profile-cpu, -profile-if"},
+	} {
+		bs, _ := json.Marshal(&workerPlan{Args: tc.args, Targets: []byte("GET http://localhost/\n")})
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/attack", bytes.NewReader(bs))
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var body bytes.Buffer
+		body.ReadFrom(res.Body)
+		res.Body.Close()
+
+		if res.StatusCode != tc.code || !strings.Contains(body.String(), tc.msg) {
+			t.Errorf("%s: got %d %q, want %d %q", tc.name, res.StatusCode, body.String(), tc.code, tc.msg)
+		}
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	t.Parallel()
+
+	for addr, want := range map[string]bool{
+		"localhost:8099": true,
+		"127.0.0.1:8099": true,
+		"[::1]:8099":     true,
+		":8099":          false,
+		"0.0.0.0:8099":   false,
+		"10.0.1.1:8099":  false,
+		"worker:8099":    false,
+		"8099":           false,
+	} {
+		if got := loopback(addr); got != want {
+			t.Errorf("loopback(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
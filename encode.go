@@ -1,28 +1,127 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 
 	vegeta "github.com/tsenart/vegeta/v12/lib"
 )
 
 const (
-	encodingCSV  = "csv"
-	encodingGob  = "gob"
-	encodingJSON = "json"
+	encodingCSV      = "csv"
+	encodingGob      = "gob"
+	encodingJSON     = "json"
+	encodingInflux   = "influx"
+	encodingMsgpack  = "msgpack"
+	encodingParquet  = "parquet"
+	encodingProtobuf = "protobuf"
 )
 
 const encodeUsage = `Usage: vegeta encode [options] [<file>...]
 
 Encodes vegeta attack results from one encoding to another.
-The supported encodings are Gob (binary), CSV and JSON.
+The supported encodings are Gob (binary), CSV, JSON, MessagePack,
+Parquet and Protocol Buffers (protobuf).
 Each input file may have a different encoding which is detected
-automatically.
+automatically, as is gzip or zstd compression of input files. Output
+files with a .gz or .zst extension are compressed with gzip or zstd.
+Input and output files can also be s3://bucket/key or gs://bucket/object
+URLs of objects in Amazon S3 or Google Cloud Storage. Inputs which are globs
+like results-*.bin, e.g. of rotated attack outputs, are read file by file in
+numeric order.
+
+The InfluxDB line protocol encoding (influx) can only be written, not read.
+
+Gob encoded files end with an index of the time ranges of their blocks of
+results. Uncompressed local files with an index are memory mapped by the
+report and plot commands, which seek directly to the results in the time
+ranges of RFC3339 --from and --to timestamps.
+
+Gob encoded files begin with a header with the version of the encoding,
+so that files written by newer versions of vegeta fail to be read by older
+ones, instead of losing the result fields they don't know. Files without a
+header, written by versions of vegeta before the header was introduced, are
+version 1. All versions up to the current one are read. With --migrate, the
+given gob encoded files are rewritten in place in the current version, with
+an index and the same compression, and files already in it are left as is.
+
+The Parquet encoding writes a columnar file with one column per result
+field (timestamp, attack, seq, code, latency, bytes_out, bytes_in, error,
+body, method, url, headers, weight, request_body, request_headers, labels,
+remote_addr, local_addr, tls_version, tls_cipher_suite, tls_protocol,
+tls_resumed, metadata, worker, conn_wait, ip_family, negotiation, attempt,
+retried, retry_delay, continued, tunnel, trace_id) which can be queried
+directly with tools like DuckDB, Spark or Athena. Latencies, connection
+waits, negotiation times, retry delays and tunnel times are in nanoseconds,
+headers are in their HTTP wire format, labels are URL query strings,
+tls_resumed, retried and continued are 0 or 1 and metadata is JSON encoded.
+
+Result streams written by the attack command begin with a metadata record,
+which describes the attack: its name, rate, duration, start time, a hash of
+its targets, the vegeta version and its arguments. It's a result with only
+its timestamp and metadata set, which is kept when encoding, shown by the
+report command with --metadata and skipped by all other commands.
+
+The MessagePack encoding (msgpack) writes a map per result with the same
+keys as the JSON encoding, timestamps with the MessagePack timestamp
+extension type and latencies in nanoseconds.
+
+The Protocol Buffers encoding writes a stream of Result messages, each
+prefixed by its size as a varint. Its schema is defined in lib/result.proto.
+
+With --filter, only the results matching a filter expression are encoded.
+Expressions compare result fields to values, e.g. code >= 500, with the
+operators ==, !=, <, <=, > and >=, or match string fields against regular
+expressions with =~ and !~. Comparisons are combined with && and ||,
+negated with ! and grouped with parentheses. The fields are:
+
+  code, seq, bytes_in, bytes_out, weight,    integers
+  worker, attempt
+  latency, conn_wait, negotiation,           durations, e.g. 250ms
+  retry_delay, tunnel
+  timestamp                                  RFC3339 timestamps
+  attack, error, body, method, url,          strings
+  request_body, remote_addr, local_addr,
+  ip_family, tls_version, tls_cipher_suite,
+  tls_protocol, trace_id
+  header.<name>, request_header.<name>,      strings
+  label.<key>
+  tls_resumed, retried, continued            true or false
+  JSONPath expressions, e.g. $.items[0].id   values of JSON bodies, compared
+                                             to strings or numbers
+
+Values with spaces, parentheses, quotes or any of !=<>&|~ must be double
+quoted. Metadata records are always kept.
+
+With --split-by and --to-dir, results are demultiplexed into one file per
+value of a field in the output directory, which may also be an object store
+URL. The field is either attack, the attack name, or label.<key>, the value
+of a label (see attack -label). Files are named after the field values, with
+an extension of their encoding, e.g. checkout.json or _.bin for results
+without a value. Metadata records are written to the files of their attack
+when splitting by attack, and to every file otherwise.
+
+With --redact and --hash, sensitive values of results are scrubbed before
+encoding, so that result files can be shared. The values of the given fields
+are replaced with REDACTED or with their SHA-256 hash (sha256:<hex>), which
+keeps equal values correlated, but can be reversed for guessable values.
+The fields are:
+
+  url.query.<param>, url.query               query parameter values in URLs
+                                             and errors
+  header.<name>, request_header.<name>       header values
+  body, request_body                         response and request bodies
+  label.<key>                                label values
+  metadata.args                              attack arguments in metadata
+                                             records, e.g. -header values
 
 The CSV encoder doesn't write a header. The columns written by it are:
 
@@ -38,25 +137,80 @@ The CSV encoder doesn't write a header. The columns written by it are:
   10. Method
   11. URL
   12. Base64 encoded response headers
+  13. Sampling weight (see attack -sample)
+  14. Base64 encoded request body (see attack -capture-request)
+  15. Base64 encoded request headers (see attack -capture-request)
+  16. Labels as a URL query string, e.g. build=1234&region=eu (see attack -label)
+  17. Remote address of the connection (see attack -record-connections)
+  18. Local address of the connection (see attack -record-connections)
+  19. TLS version (see attack -record-tls)
+  20. TLS cipher suite
+  21. TLS negotiated protocol
+  22. Whether the TLS session was resumed (true or false)
+  23. JSON encoded attack metadata, only in metadata records
+  24. ID of the attacker worker which sent the request, from 1
+  25. Time waited for a connection in ns (see attack -record-connections)
+  26. IP family of the connection, ipv4 or ipv6 (see attack -record-connections)
+  27. Time spent authenticating the connection in ns (see attack -ntlm)
+  28. Attempt of the request, from 0 (see attack -retry)
+  29. Whether the attempt was retried (true or false)
+  30. Time the attempt began after the first one of its request in ns
+  31. Whether the interim 100 Continue response arrived (see attack -expect-continue)
+  32. Time spent establishing a proxy tunnel in ns (see attack -proxy)
+  33. W3C trace ID of the request (see attack -traceparent)
 
 Arguments:
   <file>  A file with vegeta attack results encoded with one of
-          the supported encodings (gob | json | csv) [default: stdin]
+          the supported encodings (gob | json | csv | msgpack | parquet | protobuf) [default: stdin]
 
 Options:
-  --to      Output encoding (gob | json | csv) [default: json]
+  --to      Output encoding (gob | json | csv | influx | msgpack | parquet | protobuf) [default: json]
   --output  Output file [default: stdout]
+  --filter  Only encode results matching this expression, e.g. 'code >= 500 && latency > 250ms'
+  --split-by  Split results into one file per value of this field
+              (attack | label.<key>) in the --to-dir directory
+  --to-dir    Output directory of split results
+  --redact    Redact the values of these fields (comma separated list)
+  --hash      Hash the values of these fields (comma separated list)
+  --migrate   Rewrite the given gob encoded files in place in the current
+              version of the encoding [default: false]
 
 Examples:
   echo "GET http://:80" | vegeta attack -rate=1/s > results.gob
   cat results.gob | vegeta encode | jq -c 'del(.body)' | vegeta encode -to gob
+  vegeta encode -to parquet -output results.parquet results.gob
+  vegeta encode -to gob -output results.gob.zst results.gob
+  vegeta encode -filter 'code >= 500 && latency > 250ms' results.gob
+  vegeta encode -to gob -split-by attack -to-dir out/ results.gob
+  vegeta encode -redact url.query.token,request_header.Authorization -hash body results.gob
+  vegeta encode -migrate results-*.bin
+  duckdb -c "SELECT code, count(*) FROM 'results.parquet' GROUP BY code"
 `
 
+// encodings are the supported output encodings.
+var encodings = []string{
+	encodingCSV,
+	encodingGob,
+	encodingJSON,
+	encodingInflux,
+	encodingMsgpack,
+	encodingParquet,
+	encodingProtobuf,
+}
+
 func encodeCmd() command {
-	encs := "[" + strings.Join([]string{encodingCSV, encodingGob, encodingJSON}, ", ") + "]"
+	encs := "[" + strings.Join(encodings, ", ") + "]"
 	fs := flag.NewFlagSet("vegeta encode", flag.ExitOnError)
-	to := fs.String("to", encodingJSON, "Output encoding "+encs)
-	output := fs.String("output", "stdout", "Output file")
+
+	var opts encodeOpts
+	fs.StringVar(&opts.to, "to", encodingJSON, "Output encoding "+encs)
+	fs.StringVar(&opts.output, "output", "stdout", "Output file")
+	fs.StringVar(&opts.filter, "filter", "", "Only encode results matching this expression")
+	fs.StringVar(&opts.splitBy, "split-by", "", "Split results into one file per value of this field (attack | label.<key>)")
+	fs.StringVar(&opts.toDir, "to-dir", "", "Output directory of split results")
+	fs.Var(&opts.redact, "redact", "Redact the values of these fields (comma separated list)")
+	fs.Var(&opts.hash, "hash", "Hash the values of these fields (comma separated list)")
+	fs.BoolVar(&opts.migrate, "migrate", false, "Rewrite the given gob encoded files in place in the current version of the encoding")
 
 	fs.Usage = func() {
 		fmt.Fprintln(os.Stderr, encodeUsage)
@@ -64,37 +218,96 @@ func encodeCmd() command {
 
 	return command{fs, func(args []string) error {
 		fs.Parse(args)
-		files := fs.Args()
-		if len(files) == 0 {
-			files = append(files, "stdin")
+		opts.files = fs.Args()
+		if len(opts.files) == 0 {
+			opts.files = append(opts.files, "stdin")
+		}
+
+		if opts.migrate {
+			return migrate(opts.files)
 		}
-		return encode(files, *to, *output)
+
+		if (opts.splitBy == "") != (opts.toDir == "") {
+			return errors.New("-split-by and -to-dir must be used together")
+		} else if opts.splitBy != "" && opts.output != "stdout" {
+			return errors.New("-output can't be used with -split-by")
+		}
+
+		return encode(&opts)
 	}}
 }
 
-func encode(files []string, to, output string) error {
-	dec, mc, err := decoder(files)
+// encodeOpts aggregates the encode function command options
+type encodeOpts struct {
+	files   []string
+	to      string
+	output  string
+	filter  string
+	splitBy string
+	toDir   string
+	redact  csl
+	hash    csl
+	migrate bool
+}
+
+func encode(opts *encodeOpts) (err error) {
+	// Metadata records are encoded as they're decoded, ahead of the results
+	// of their input.
+	var (
+		enc  vegeta.Encoder
+		merr error
+	)
+
+	dec, mc, err := decoder(opts.files, func(md *vegeta.Metadata) {
+		if ts := md.Began; merr == nil {
+			if md.Annotation != nil {
+				ts = md.Annotation.Time
+			}
+			merr = enc.Encode(&vegeta.Result{Timestamp: ts, Metadata: md})
+		}
+	})
 	defer mc.Close()
 	if err != nil {
 		return err
 	}
 
-	out, err := file(output, true)
-	if err != nil {
-		return err
+	if opts.filter != "" {
+		keep, err := vegeta.ParseFilter(opts.filter)
+		if err != nil {
+			return err
+		}
+		dec = vegeta.NewFilterDecoder(dec, keep)
 	}
-	defer out.Close()
 
-	var enc vegeta.Encoder
-	switch to {
-	case encodingCSV:
-		enc = vegeta.NewCSVEncoder(out)
-	case encodingGob:
-		enc = vegeta.NewEncoder(out)
-	case encodingJSON:
-		enc = vegeta.NewJSONEncoder(out)
-	default:
-		return fmt.Errorf("encode: unknown encoding %q", to)
+	var closer io.Closer
+	if opts.splitBy != "" {
+		se, err := newSplitEncoder(opts.splitBy, opts.toDir, opts.to)
+		if err != nil {
+			return fmt.Errorf("encode: %v", err)
+		}
+		enc, closer = se.Encode, se
+	} else {
+		var out io.WriteCloser
+		if out, err = create(opts.output); err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := out.Close(); err == nil {
+				err = cerr
+			}
+		}()
+
+		if enc, closer, err = encoder(opts.to, out); err != nil {
+			return fmt.Errorf("encode: %v", err)
+		}
+	}
+
+	if len(opts.redact) > 0 || len(opts.hash) > 0 {
+		scrub, err := vegeta.NewScrubber(opts.redact, opts.hash)
+		if err != nil {
+			return err
+		}
+		enc = scrubbing(enc, scrub)
 	}
 
 	sigch := make(chan os.Signal, 1)
@@ -103,7 +316,7 @@ func encode(files []string, to, output string) error {
 	for {
 		select {
 		case <-sigch:
-			return nil
+			return closer.Close()
 		default:
 		}
 
@@ -113,10 +326,132 @@ func encode(files []string, to, output string) error {
 				break
 			}
 			return err
+		} else if merr != nil {
+			return merr
 		} else if err = enc.Encode(&r); err != nil {
 			return err
 		}
 	}
 
+	if merr != nil {
+		return merr
+	}
+
+	return closer.Close()
+}
+
+// migrate rewrites the given gob encoded results files in place in the
+// current version of the encoding, with an index footer. Files already in
+// the current version are left untouched.
+func migrate(files []string) error {
+	for _, name := range files {
+		names, err := shards(name)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range names {
+			if err := migrateFile(f); err != nil {
+				return fmt.Errorf("migrate %s: %v", f, err)
+			}
+		}
+	}
 	return nil
 }
+
+func migrateFile(name string) (err error) {
+	if _, _, _, ok := objectURL(name); ok || name == "stdin" {
+		return errors.New("only local files can be migrated in place")
+	}
+
+	rc, err := open(name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	r, zc, err := decompress(rc)
+	if err != nil {
+		return err
+	}
+	defer zc.Close()
+
+	br := bufio.NewReader(r)
+	if v, err := vegeta.PeekGobVersion(br); err != nil {
+		return err
+	} else if v == vegeta.GobVersion {
+		return nil
+	}
+
+	// The migrated file keeps the extension of the original one, and with it
+	// its compression.
+	tmp := filepath.Join(filepath.Dir(name), ".migrate-"+filepath.Base(name))
+	out, err := create(tmp)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(tmp)
+		}
+	}()
+
+	dec := vegeta.NewDecoder(br)
+	enc, closer := vegeta.NewIndexedEncoder(out)
+	for n := 0; ; n++ {
+		var r vegeta.Result
+		if err = dec.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil && n == 0 {
+			return fmt.Errorf("not gob encoded results: %v", err)
+		} else if err != nil {
+			return err
+		} else if err = enc.Encode(&r); err != nil {
+			return err
+		}
+	}
+
+	if err = closer.Close(); err != nil {
+		return err
+	} else if err = out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, name)
+}
+
+// scrubbing returns an Encoder which scrubs Results before encoding them.
+func scrubbing(enc vegeta.Encoder, scrub vegeta.Scrubber) vegeta.Encoder {
+	return func(r *vegeta.Result) error {
+		scrub(r)
+		return enc.Encode(r)
+	}
+}
+
+// encoder returns an Encoder of the given encoding writing to w, and an
+// io.Closer which must be called once done to write out buffered Results.
+func encoder(encoding string, w io.Writer) (vegeta.Encoder, io.Closer, error) {
+	closer := ioutil.NopCloser(nil)
+	switch encoding {
+	case encodingCSV:
+		return vegeta.NewCSVEncoder(w), closer, nil
+	case encodingGob:
+		enc, closer := vegeta.NewIndexedEncoder(w)
+		return enc, closer, nil
+	case encodingJSON:
+		return vegeta.NewJSONEncoder(w), closer, nil
+	case encodingInflux:
+		return withoutMetadata(vegeta.NewInfluxEncoder(w)), closer, nil
+	case encodingMsgpack:
+		return vegeta.NewMsgpackEncoder(w), closer, nil
+	case encodingParquet:
+		enc, closer := vegeta.NewParquetEncoder(w)
+		return enc, closer, nil
+	case encodingProtobuf:
+		return vegeta.NewProtobufEncoder(w), closer, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+}
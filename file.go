@@ -1,12 +1,18 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/klauspost/compress/zstd"
 	vegeta "github.com/tsenart/vegeta/v12/lib"
 )
 
@@ -24,24 +30,198 @@ func file(name string, create bool) (*os.File, error) {
 	}
 }
 
-func decoder(files []string) (vegeta.Decoder, io.Closer, error) {
+// create creates the given results output file, or object store object
+// if it's an s3:// or gs:// URL, compressing what's written to it with gzip
+// or zstd when its name ends with .gz or .zst respectively.
+func create(name string) (io.WriteCloser, error) {
+	var (
+		f   io.WriteCloser
+		err error
+	)
+
+	if store, bucket, key, ok := objectURL(name); ok {
+		u, err := store.create(bucket, key)
+		if err != nil {
+			return nil, err
+		}
+		f = newObjectWriter(u)
+	} else if f, err = file(name, true); err != nil {
+		return nil, err
+	}
+
+	var zw io.WriteCloser
+	switch filepath.Ext(name) {
+	case ".gz":
+		zw = gzip.NewWriter(f)
+	case ".zst":
+		if zw, err = zstd.NewWriter(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+	default:
+		return f, nil
+	}
+
+	return &compressedFile{zw, multiCloser{zw, f}}, nil
+}
+
+// compressedFile writes to a compressor of a file and closes both.
+type compressedFile struct {
+	io.Writer
+	io.Closer
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// decompress returns a reader of the decompressed contents of r if it's
+// gzip or zstd compressed, or r itself otherwise, and an io.Closer which
+// releases the resources of the decompressor.
+func decompress(r io.Reader) (io.Reader, io.Closer, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zstdMagic))
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		return zr, zr, err
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, closerFunc(func() error { zr.Close(); return nil }), nil
+	default:
+		return br, multiCloser{}, nil
+	}
+}
+
+// open opens the given results input file, or object store object if it's
+// an s3:// or gs:// URL.
+func open(name string) (io.ReadCloser, error) {
+	if store, bucket, key, ok := objectURL(name); ok {
+		return store.open(bucket, key)
+	}
+	return file(name, false)
+}
+
+// openIndexed returns a Decoder of the given results file if it's a local gob
+// file with an index footer, which it memory maps, or nil otherwise.
+func openIndexed(name string, from, to time.Time) (vegeta.Decoder, io.Closer, error) {
+	if name == "stdin" {
+		return nil, nil, nil
+	} else if _, _, _, ok := objectURL(name); ok {
+		return nil, nil, nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() < 2*int64(len(vegeta.IndexMagic)) {
+		return nil, nil, err
+	}
+
+	tail := make([]byte, len(vegeta.IndexMagic))
+	if _, err = f.ReadAt(tail, fi.Size()-int64(len(tail))); err != nil || string(tail) != vegeta.IndexMagic {
+		return nil, nil, err
+	}
+
+	data, mc, err := mmap(f, fi.Size())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dec, err := vegeta.NewIndexedDecoder(data, from, to)
+	if err != nil {
+		mc.Close()
+		return nil, nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	return dec, mc, nil
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+// decoder returns a Decoder which round robins across the given input files.
+// Inputs which are globs or rotated output patterns are decoded shard by shard.
+// The metadata records of each input are skipped and passed to meta, unless
+// it's nil.
+func decoder(files []string, meta func(*vegeta.Metadata)) (vegeta.Decoder, io.Closer, error) {
+	inputs, closer, err := inputDecoders(files, time.Time{}, time.Time{}, meta)
+	if err != nil {
+		return nil, closer, err
+	}
+	return roundRobin(inputs), closer, nil
+}
+
+// roundRobin returns a Decoder which round robins across the given inputs,
+// decoding the shards of each in turn.
+func roundRobin(inputs [][]vegeta.Decoder) vegeta.Decoder {
+	decs := make([]vegeta.Decoder, 0, len(inputs))
+	for _, shards := range inputs {
+		decs = append(decs, vegeta.NewChainDecoder(shards...))
+	}
+	return vegeta.NewRoundRobinDecoder(decs...)
+}
+
+// inputDecoders returns the Decoders of the shards of each given input file.
+// Indexed gob files are memory mapped and only their blocks of results in the
+// given time range, if any, are decoded. The metadata records of each shard
+// are skipped and passed to meta, unless it's nil, which may be called
+// concurrently when shards are decoded concurrently.
+func inputDecoders(files []string, from, to time.Time, meta func(*vegeta.Metadata)) ([][]vegeta.Decoder, io.Closer, error) {
 	closer := make(multiCloser, 0, len(files))
-	decs := make([]vegeta.Decoder, 0, len(files))
-	for _, f := range files {
-		rc, err := file(f, false)
+	inputs := make([][]vegeta.Decoder, 0, len(files))
+	for _, name := range files {
+		names, err := shards(name)
 		if err != nil {
 			return nil, closer, err
 		}
 
-		dec := vegeta.DecoderFor(rc)
-		if dec == nil {
-			return nil, closer, fmt.Errorf("encode: can't detect encoding of %q", f)
+		decs := make([]vegeta.Decoder, 0, len(names))
+		for _, f := range names {
+			idx, ic, err := openIndexed(f, from, to)
+			if err != nil {
+				return nil, closer, err
+			} else if idx != nil {
+				closer = append(closer, ic)
+				decs = append(decs, vegeta.NewMetadataDecoder(idx, meta))
+				continue
+			}
+
+			rc, err := open(f)
+			if err != nil {
+				return nil, closer, err
+			}
+
+			closer = append(closer, rc)
+
+			r, zc, err := decompress(rc)
+			if err != nil {
+				return nil, closer, fmt.Errorf("decompress %q: %v", f, err)
+			}
+
+			closer = append(closer, zc)
+
+			dec := vegeta.DecoderFor(r)
+			if dec == nil {
+				return nil, closer, fmt.Errorf("encode: can't detect encoding of %q", f)
+			}
+
+			decs = append(decs, vegeta.NewMetadataDecoder(dec, meta))
 		}
 
-		decs = append(decs, dec)
-		closer = append(closer, rc)
+		inputs = append(inputs, decs)
 	}
-	return vegeta.NewRoundRobinDecoder(decs...), closer, nil
+	return inputs, closer, nil
 }
 
 type multiCloser []io.Closer
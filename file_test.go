@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestCreateDecompress(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "vegeta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ts := time.Date(2020, 3, 14, 15, 9, 26, 0, time.UTC)
+	results := make([]vegeta.Result, 100)
+	for i := range results {
+		results[i] = vegeta.Result{
+			Attack:    "checkout",
+			Seq:       uint64(i),
+			Code:      200,
+			Timestamp: ts.Add(time.Duration(i) * time.Millisecond),
+			Latency:   time.Duration(i) * time.Microsecond,
+			Body:      bytes.Repeat([]byte("vegeta"), i),
+		}
+	}
+
+	for _, tc := range []struct {
+		name  string
+		enc   func(io.Writer) vegeta.Encoder
+		magic []byte
+	}{
+		{"results.bin", vegeta.NewEncoder, nil},
+		{"results.bin.gz", vegeta.NewEncoder, gzipMagic},
+		{"results.bin.zst", vegeta.NewEncoder, zstdMagic},
+		{"results.json.gz", vegeta.NewJSONEncoder, gzipMagic},
+		{"results.csv.zst", vegeta.NewCSVEncoder, zstdMagic},
+	} {
+		name := filepath.Join(dir, tc.name)
+
+		w, err := create(name)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		enc := tc.enc(w)
+		for i := range results {
+			if err = enc.Encode(&results[i]); err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+		}
+
+		if err = w.Close(); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		raw, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		// Compressed files start with their format's magic number and are
+		// decompressed whatever their name.
+		if tc.magic != nil && !bytes.HasPrefix(raw, tc.magic) {
+			t.Errorf("%s: got header % x, want % x", tc.name, raw[:4], tc.magic)
+		}
+
+		r, zc, err := decompress(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		dec := vegeta.DecoderFor(r)
+		if dec == nil {
+			t.Fatalf("%s: can't detect encoding of the decompressed data", tc.name)
+		}
+
+		for i := range results {
+			var got vegeta.Result
+			if err = dec.Decode(&got); err != nil {
+				t.Fatalf("%s: result %d: %v", tc.name, i, err)
+			} else if !got.Equal(results[i]) {
+				t.Errorf("%s: got result %+v, want %+v", tc.name, got, results[i])
+			}
+		}
+
+		var extra vegeta.Result
+		if err = dec.Decode(&extra); err != io.EOF {
+			t.Errorf("%s: got error %v after the last result, want EOF", tc.name, err)
+		}
+
+		if err = zc.Close(); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+
+		// Inputs are decompressed in the same way.
+		in, closer, err := decoder([]string{name}, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		var n int
+		for {
+			var got vegeta.Result
+			if err = in.Decode(&got); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			n++
+		}
+
+		if n != len(results) {
+			t.Errorf("%s: decoded %d results, want %d", tc.name, n, len(results))
+		}
+
+		if err = closer.Close(); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+	}
+}
+
+func TestDecompressUncompressed(t *testing.T) {
+	t.Parallel()
+
+	for _, in := range []string{"", "{", "\x1f", "\x28\xb5\x2f", `{"attack":"checkout"}`} {
+		r, zc, err := decompress(strings.NewReader(in))
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+			continue
+		}
+
+		if got, err := ioutil.ReadAll(r); err != nil || string(got) != in {
+			t.Errorf("%q: got %q, %v", in, got, err)
+		}
+
+		if err = zc.Close(); err != nil {
+			t.Errorf("%q: %v", in, err)
+		}
+	}
+}
+
+func TestDecompressCorrupt(t *testing.T) {
+	t.Parallel()
+
+	// A truncated gzip header fails to decompress.
+	if _, _, err := decompress(bytes.NewReader(append(gzipMagic, 0x08))); err == nil {
+		t.Error("got no error decompressing a truncated gzip header")
+	}
+}
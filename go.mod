@@ -8,6 +8,7 @@ require (
 	github.com/c2h5oh/datasize v0.0.0-20171227191756-4eba002a5eae
 	github.com/dgryski/go-gk v0.0.0-20140819190930-201884a44051
 	github.com/dgryski/go-lttb v0.0.0-20180810165845-318fcdf10a77
+	github.com/golang/snappy v0.0.1
 	github.com/gonum/blas v0.0.0-20181208220705-f22b278b28ac // indirect
 	github.com/gonum/diff v0.0.0-20181124234638-500114f11e71 // indirect
 	github.com/gonum/floats v0.0.0-20181209220543-c233463c7e82 // indirect
@@ -19,10 +20,15 @@ require (
 	github.com/gonum/stat v0.0.0-20181125101827-41a0da705a5b // indirect
 	github.com/google/go-cmp v0.2.0
 	github.com/influxdata/tdigest v0.0.0-20180711151920-a7d76c6f093a
+	github.com/klauspost/compress v1.9.8
 	github.com/mailru/easyjson v0.7.0
 	github.com/miekg/dns v1.1.17
+	github.com/segmentio/kafka-go v0.4.8
 	github.com/streadway/quantile v0.0.0-20150917103942-b0c588724d25
 	github.com/tsenart/go-tsz v0.0.0-20180814232043-cdeb9e1e981e
+	github.com/yuin/gopher-lua v0.0.0-20191220021717-ab39c6098bdb
+	golang.org/x/crypto v0.0.0-20190829043050-9756ffdc2472
 	golang.org/x/net v0.0.0-20190827160401-ba9fcec4b297
+	golang.org/x/sys v0.0.0-20190904154756-749cb33beabd
 	pgregory.net/rapid v0.3.3
 )
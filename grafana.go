@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const grafanaUsage = `Usage: vegeta grafana [options]
+
+Outputs a Grafana dashboard JSON model with panels for the request rate,
+latency percentiles, errors and bytes of attacks whose results are
+streamed live by the attack command's prometheus+http(s):// or
+influx+http(s):// -output sinks. Importing the dashboard in Grafana
+prompts for the data source to use.
+
+Options:
+  --datasource  Data source type of the dashboard (prometheus | influx)
+                [default: prometheus]
+  --title       Title of the dashboard [default: Vegeta]
+  --output      Output file [default: stdout]
+
+Examples:
+  vegeta grafana -datasource=prometheus > vegeta-dashboard.json
+  echo "GET http://:80" | vegeta attack -output=prometheus+http://localhost:9090/api/v1/write`
+
+func grafanaCmd() command {
+	fs := flag.NewFlagSet("vegeta grafana", flag.ExitOnError)
+	datasource := fs.String("datasource", "prometheus", "Data source type of the dashboard [prometheus, influx]")
+	title := fs.String("title", "Vegeta", "Title of the dashboard")
+	output := fs.String("output", "stdout", "Output file")
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, grafanaUsage)
+	}
+
+	return command{fs, func(args []string) error {
+		fs.Parse(args)
+		return grafana(*datasource, *title, *output)
+	}}
+}
+
+func grafana(datasource, title, output string) error {
+	d, err := grafanaDashboard(datasource, title)
+	if err != nil {
+		return err
+	}
+
+	out, err := file(output, true)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+type grafanaInput struct {
+	Name       string `json:"name"`
+	Label      string `json:"label"`
+	Type       string `json:"type"`
+	PluginID   string `json:"pluginId"`
+	PluginName string `json:"pluginName"`
+}
+
+type grafanaDatasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type grafanaPanel struct {
+	ID          int                    `json:"id"`
+	Type        string                 `json:"type"`
+	Title       string                 `json:"title"`
+	Datasource  grafanaDatasource      `json:"datasource"`
+	GridPos     map[string]int         `json:"gridPos"`
+	FieldConfig map[string]interface{} `json:"fieldConfig"`
+	Targets     []grafanaTarget        `json:"targets"`
+}
+
+// grafanaTarget is a panel query, with Expr and LegendFormat set for
+// Prometheus and Query and Alias set for InfluxDB.
+type grafanaTarget struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr,omitempty"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	Query        string `json:"query,omitempty"`
+	RawQuery     bool   `json:"rawQuery,omitempty"`
+	ResultFormat string `json:"resultFormat,omitempty"`
+	Alias        string `json:"alias,omitempty"`
+}
+
+// grafanaDashboard returns the dashboard model for the given data source type.
+func grafanaDashboard(datasource, title string) (map[string]interface{}, error) {
+	var (
+		input  grafanaInput
+		panels []grafanaPanel
+	)
+
+	switch datasource {
+	case "prometheus":
+		input = grafanaInput{"DS_PROMETHEUS", "Prometheus", "datasource", "prometheus", "Prometheus"}
+		panels = prometheusPanels()
+	case "influx":
+		input = grafanaInput{"DS_INFLUXDB", "InfluxDB", "datasource", "influxdb", "InfluxDB"}
+		panels = influxPanels()
+	default:
+		return nil, fmt.Errorf("unknown grafana datasource: %q", datasource)
+	}
+
+	ds := grafanaDatasource{Type: input.PluginID, UID: "${" + input.Name + "}"}
+	for i := range panels {
+		p := &panels[i]
+		p.ID = i + 1
+		p.Type = "timeseries"
+		p.Datasource = ds
+		p.GridPos = map[string]int{"x": (i % 2) * 12, "y": (i / 2) * 8, "w": 12, "h": 8}
+		for j := range p.Targets {
+			p.Targets[j].RefID = string(rune('A' + j))
+		}
+	}
+
+	return map[string]interface{}{
+		"__inputs":      []grafanaInput{input},
+		"title":         title,
+		"tags":          []string{"vegeta"},
+		"editable":      true,
+		"refresh":       "5s",
+		"schemaVersion": 27,
+		"time":          map[string]string{"from": "now-15m", "to": "now"},
+		"panels":        panels,
+	}, nil
+}
+
+func grafanaUnit(u string) map[string]interface{} {
+	return map[string]interface{}{"defaults": map[string]string{"unit": u}}
+}
+
+// prometheusPanels returns the panels querying the metrics pushed
+// by the prometheus+http(s):// attack -output sink.
+func prometheusPanels() []grafanaPanel {
+	const interval = "[$__rate_interval]"
+
+	latencies := make([]grafanaTarget, 0, 3)
+	for _, q := range []float64{0.5, 0.9, 0.99} {
+		qs := strconv.FormatFloat(q, 'f', -1, 64)
+		latencies = append(latencies, grafanaTarget{
+			Expr:         "histogram_quantile(" + qs + ", sum by (attack, le) (rate(vegeta_request_duration_seconds_bucket" + interval + ")))",
+			LegendFormat: "{{attack}} p" + strconv.FormatFloat(q*100, 'f', -1, 64),
+		})
+	}
+
+	return []grafanaPanel{
+		{
+			Title:       "Request rate",
+			FieldConfig: grafanaUnit("reqps"),
+			Targets: []grafanaTarget{{
+				Expr:         "sum by (attack) (rate(vegeta_requests_total" + interval + "))",
+				LegendFormat: "{{attack}}",
+			}},
+		},
+		{
+			Title:       "Latency percentiles",
+			FieldConfig: grafanaUnit("s"),
+			Targets:     latencies,
+		},
+		{
+			Title:       "Errors",
+			FieldConfig: grafanaUnit("reqps"),
+			Targets: []grafanaTarget{{
+				Expr:         `sum by (attack, code) (rate(vegeta_requests_total{code!~"2..|3.."}` + interval + "))",
+				LegendFormat: "{{attack}} {{code}}",
+			}},
+		},
+		{
+			Title:       "Bytes",
+			FieldConfig: grafanaUnit("Bps"),
+			Targets: []grafanaTarget{
+				{
+					Expr:         "sum by (attack) (rate(vegeta_bytes_in_total" + interval + "))",
+					LegendFormat: "{{attack}} in",
+				},
+				{
+					Expr:         "sum by (attack) (rate(vegeta_bytes_out_total" + interval + "))",
+					LegendFormat: "{{attack}} out",
+				},
+			},
+		},
+	}
+}
+
+// influxPanels returns the panels querying the points written
+// by the influx+http(s):// attack -output sink.
+func influxPanels() []grafanaPanel {
+	query := func(alias, q string) grafanaTarget {
+		return grafanaTarget{Query: q, RawQuery: true, ResultFormat: "time_series", Alias: alias}
+	}
+
+	from := ` FROM "` + vegeta.InfluxMeasurement + `" WHERE $timeFilter`
+
+	latencies := make([]grafanaTarget, 0, 3)
+	for _, p := range []string{"50", "90", "99"} {
+		latencies = append(latencies, query("$tag_attack p"+p,
+			`SELECT percentile("latency", `+p+`)`+from+` GROUP BY time($__interval), "attack" fill(none)`))
+	}
+
+	return []grafanaPanel{
+		{
+			Title:       "Request rate",
+			FieldConfig: grafanaUnit("reqps"),
+			Targets: []grafanaTarget{query("$tag_attack",
+				`SELECT count("latency")`+from+` GROUP BY time(1s), "attack" fill(0)`)},
+		},
+		{
+			Title:       "Latency percentiles",
+			FieldConfig: grafanaUnit("ns"),
+			Targets:     latencies,
+		},
+		{
+			Title:       "Errors",
+			FieldConfig: grafanaUnit("reqps"),
+			Targets: []grafanaTarget{query("$tag_attack $tag_code",
+				`SELECT count("latency")`+from+` AND "code" !~ /^[23]/ GROUP BY time(1s), "attack", "code" fill(none)`)},
+		},
+		{
+			Title:       "Bytes",
+			FieldConfig: grafanaUnit("Bps"),
+			Targets: []grafanaTarget{
+				query("$tag_attack in", `SELECT sum("bytes_in")`+from+` GROUP BY time(1s), "attack" fill(0)`),
+				query("$tag_attack out", `SELECT sum("bytes_out")`+from+` GROUP BY time(1s), "attack" fill(0)`),
+			},
+		},
+	}
+}
@@ -0,0 +1,309 @@
+// Package aws implements the small subset of the AWS SDK vegeta needs:
+// loading credentials and region from the standard environment variables and
+// shared configuration files, and signing HTTP requests with Signature
+// Version 4.
+package aws
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Credentials are AWS security credentials.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// ErrNoCredentials is returned by LoadCredentials when no credentials
+// could be found.
+var ErrNoCredentials = errors.New("aws: no credentials found in environment or shared credentials file")
+
+// LoadCredentials loads credentials from the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables,
+// falling back to the AWS_PROFILE (or default) profile of the shared
+// credentials file.
+func LoadCredentials() (Credentials, error) {
+	c := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+
+	if c.AccessKeyID != "" && c.SecretAccessKey != "" {
+		return c, nil
+	}
+
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		path = filepath.Join(homeDir(), ".aws", "credentials")
+	}
+
+	section, err := readProfile(path, profile(), false)
+	if err != nil {
+		return c, err
+	}
+
+	c = Credentials{
+		AccessKeyID:     section["aws_access_key_id"],
+		SecretAccessKey: section["aws_secret_access_key"],
+		SessionToken:    section["aws_session_token"],
+	}
+
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return c, ErrNoCredentials
+	}
+
+	return c, nil
+}
+
+// Region returns the region given by the AWS_REGION or AWS_DEFAULT_REGION
+// environment variables, falling back to the AWS_PROFILE (or default) profile
+// of the shared config file. It returns an empty string if none is found.
+func Region() string {
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(env); region != "" {
+			return region
+		}
+	}
+
+	path := os.Getenv("AWS_CONFIG_FILE")
+	if path == "" {
+		path = filepath.Join(homeDir(), ".aws", "config")
+	}
+
+	section, _ := readProfile(path, profile(), true)
+	return section["region"]
+}
+
+func profile() string {
+	if p := os.Getenv("AWS_PROFILE"); p != "" {
+		return p
+	}
+	return "default"
+}
+
+func homeDir() string {
+	if home, err := os.UserHomeDir(); err == nil {
+		return home
+	}
+	return ""
+}
+
+// readProfile returns the key value pairs of the given profile in the INI
+// formatted file at path. Profiles other than default are prefixed with
+// "profile " in the shared config file, but not in the credentials file.
+func readProfile(path, name string, config bool) (map[string]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNoCredentials
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if config && name != "default" {
+		name = "profile " + name
+	}
+
+	var (
+		section = map[string]string{}
+		current string
+		sc      = bufio.NewScanner(f)
+	)
+
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			continue
+		case line[0] == '[' && line[len(line)-1] == ']':
+			current = strings.TrimSpace(line[1 : len(line)-1])
+		case current == name:
+			if kv := strings.SplitN(line, "=", 2); len(kv) == 2 {
+				section[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+			}
+		}
+	}
+
+	return section, sc.Err()
+}
+
+// A Signer signs HTTP requests to a given AWS service and region with
+// Signature Version 4.
+type Signer struct {
+	Credentials Credentials
+	Region      string
+	Service     string
+}
+
+// Sign signs the given request, whose body must be given separately, at the
+// given time by setting its X-Amz-Date, X-Amz-Security-Token (if needed) and
+// Authorization headers. Requests to S3 are additionally signed with the
+// X-Amz-Content-Sha256 header.
+//
+// See https://docs.aws.amazon.com/general/latest/gr/sigv4_signing.html
+func (s *Signer) Sign(req *http.Request, body []byte, t time.Time) {
+	t = t.UTC()
+	date := t.Format("20060102")
+	amzDate := t.Format("20060102T150405Z")
+
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.Credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.Credentials.SessionToken)
+	}
+	if s.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	headers, signed := canonicalHeaders(req)
+	canonical := strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req),
+		canonicalQuery(req),
+		headers,
+		signed,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.Region, s.Service, "aws4_request"}, "/")
+	toSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonical)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.Credentials.SecretAccessKey), date)
+	for _, v := range []string{s.Region, s.Service, "aws4_request"} {
+		key = hmacSHA256(key, v)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.Credentials.AccessKeyID, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign)),
+	))
+}
+
+func (s *Signer) canonicalURI(req *http.Request) string {
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	// All services but S3 expect the path to be escaped twice.
+	if s.Service != "s3" {
+		path = escape(path, false)
+	}
+	return path
+}
+
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		vs := append([]string(nil), query[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			pairs = append(pairs, escape(k, true)+"="+escape(v, true))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// unsignedHeaders are left out of signatures since proxies and the
+// HTTP client itself may modify them after signing.
+var unsignedHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+	"expect":          true,
+	"connection":      true,
+	"content-length":  true,
+}
+
+func canonicalHeaders(req *http.Request) (canonical, signed string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	values := map[string][]string{"host": {host}}
+	for k, vs := range req.Header {
+		if k = strings.ToLower(k); !unsignedHeaders[k] && k != "host" {
+			values[k] = append(values[k], vs...)
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for k := range values {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, k := range names {
+		vs := make([]string, len(values[k]))
+		for i, v := range values[k] {
+			vs[i] = strings.Join(strings.Fields(v), " ")
+		}
+		b.WriteString(k)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(vs, ","))
+		b.WriteByte('\n')
+	}
+
+	return b.String(), strings.Join(names, ";")
+}
+
+// escape URI encodes every byte of s except for the unreserved characters
+// and, unless slash is true, forward slashes.
+func escape(s string, slash bool) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !slash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,114 @@
+package aws
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test cases from the AWS Signature Version 4 test suite.
+func TestSignerSign(t *testing.T) {
+	t.Parallel()
+
+	s := Signer{
+		Credentials: Credentials{
+			AccessKeyID:     "AKIDEXAMPLE",
+			SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		},
+		Region:  "us-east-1",
+		Service: "service",
+	}
+
+	ts := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name   string
+		method string
+		url    string
+		want   string
+	}{
+		{
+			name:   "get-vanilla",
+			method: "GET",
+			url:    "https://example.amazonaws.com/",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=host;x-amz-date, " +
+				"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:   "get-vanilla-query-order-key-case",
+			method: "GET",
+			url:    "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=host;x-amz-date, " +
+				"Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:   "post-vanilla",
+			method: "POST",
+			url:    "https://example.amazonaws.com/",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=host;x-amz-date, " +
+				"Signature=5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s.Sign(req, nil, ts)
+
+			if got := req.Header.Get("Authorization"); got != tc.want {
+				t.Errorf("\ngot:  %s\nwant: %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "credentials")
+	err = ioutil.WriteFile(path, []byte(`
+[default]
+aws_access_key_id = AKIDDEFAULT
+aws_secret_access_key = secret
+
+[other]
+aws_access_key_id = AKIDOTHER
+aws_secret_access_key = other-secret
+aws_session_token = token
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range map[string]string{
+		"AWS_ACCESS_KEY_ID":           "",
+		"AWS_SECRET_ACCESS_KEY":       "",
+		"AWS_SHARED_CREDENTIALS_FILE": path,
+		"AWS_PROFILE":                 "other",
+	} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Setenv(k, v)
+	}
+
+	c, err := LoadCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Credentials{AccessKeyID: "AKIDOTHER", SecretAccessKey: "other-secret", SessionToken: "token"}
+	if c != want {
+		t.Errorf("got: %+v, want: %+v", c, want)
+	}
+}
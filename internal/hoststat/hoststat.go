@@ -0,0 +1,208 @@
+// Package hoststat samples the resource usage of the host running an attack,
+// and of its process, so that attacks can tell when the load generator itself
+// was saturated, which invalidates their results. Agents on the hosts of
+// targets sample them too, so that their usage can be correlated with the
+// latencies of the attack. It reads the proc filesystem, and is only
+// supported on Linux.
+package hoststat
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// ErrUnsupported is returned by NewSampler on systems other than Linux.
+var ErrUnsupported = errors.New("hoststat: sampling host resources isn't supported on this system")
+
+// Sampler samples the resource usage of the host and of the process it runs
+// in. CPU time and network throughput are sampled over the interval since the
+// previous sample.
+type Sampler struct {
+	cpu   cpuTimes
+	net   netCounters
+	taken time.Time
+}
+
+// Sample returns the resource usage of the host since the previous sample.
+func (s *Sampler) Sample() (vegeta.HostUsage, error) {
+	cur, err := sample()
+	if err != nil {
+		return vegeta.HostUsage{}, err
+	}
+
+	u := cur.usage
+	if total := cur.cpu.total - s.cpu.total; total > 0 && cur.cpu.idle >= s.cpu.idle {
+		u.CPU = 1 - float64(cur.cpu.idle-s.cpu.idle)/float64(total)
+	}
+
+	if secs := cur.taken.Sub(s.taken).Seconds(); secs > 0 {
+		if cur.net.rx >= s.net.rx {
+			u.NetIn = uint64(float64(cur.net.rx-s.net.rx) / secs)
+		}
+		if cur.net.tx >= s.net.tx {
+			u.NetOut = uint64(float64(cur.net.tx-s.net.tx) / secs)
+		}
+	}
+
+	s.cpu, s.net, s.taken = cur.cpu, cur.net, cur.taken
+	return u, nil
+}
+
+// snapshot is the state of the resources of the host at a point in time, of
+// which those measured over intervals are counters.
+type snapshot struct {
+	usage vegeta.HostUsage // Usage of the resources not measured over intervals.
+	cpu   cpuTimes
+	net   netCounters
+	taken time.Time
+}
+
+// cpuTimes are the CPU times of the host, in clock ticks.
+type cpuTimes struct {
+	total uint64
+	idle  uint64 // Including the time waiting for I/O.
+}
+
+// netCounters are the bytes received and sent by the network interfaces of
+// the host, other than loopback ones.
+type netCounters struct {
+	rx, tx uint64
+}
+
+// parseStat parses the CPU times of the host in the format of /proc/stat.
+func parseStat(r io.Reader) (cpuTimes, error) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+
+		// user nice system idle iowait irq softirq steal guest guest_nice,
+		// of which guest times are included in user ones.
+		var t cpuTimes
+		for i, f := range fields[1:] {
+			if i >= 8 {
+				break
+			}
+
+			n, err := strconv.ParseUint(f, 10, 64)
+			if err != nil {
+				return t, fmt.Errorf("hoststat: bad cpu time %q", f)
+			}
+
+			if t.total += n; i == 3 || i == 4 {
+				t.idle += n
+			}
+		}
+		return t, nil
+	}
+
+	if err := sc.Err(); err != nil {
+		return cpuTimes{}, err
+	}
+	return cpuTimes{}, errors.New("hoststat: no cpu line")
+}
+
+// parseMeminfo returns the ratio of the memory of the host in use, given in
+// the format of /proc/meminfo.
+func parseMeminfo(r io.Reader) (float64, error) {
+	var total, available uint64
+	err := scanKB(r, map[string]*uint64{"MemTotal:": &total, "MemAvailable:": &available})
+	if err != nil {
+		return 0, err
+	} else if total == 0 || available > total {
+		return 0, errors.New("hoststat: no memory totals")
+	}
+	return 1 - float64(available)/float64(total), nil
+}
+
+// parseStatus returns the resident memory of a process in bytes, given its
+// status in the format of /proc/[pid]/status.
+func parseStatus(r io.Reader) (uint64, error) {
+	var rss uint64
+	return rss, scanKB(r, map[string]*uint64{"VmRSS:": &rss})
+}
+
+// scanKB scans the lines of the given reader for the given keys of values in
+// kB, which it sets in bytes.
+func scanKB(r io.Reader, keys map[string]*uint64) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		v, ok := keys[fields[0]]
+		if !ok {
+			continue
+		}
+
+		n, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("hoststat: bad %s %q", strings.TrimSuffix(fields[0], ":"), fields[1])
+		}
+		*v = n << 10
+	}
+	return sc.Err()
+}
+
+// parseNetDev parses the network counters of the host in the format of
+// /proc/net/dev.
+func parseNetDev(r io.Reader) (netCounters, error) {
+	var c netCounters
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		i := strings.IndexByte(line, ':')
+		if i < 0 || strings.TrimSpace(line[:i]) == "lo" {
+			continue
+		}
+
+		// Receive bytes are the first field, and transmit bytes the ninth.
+		fields := strings.Fields(line[i+1:])
+		if len(fields) < 9 {
+			continue
+		}
+
+		rx, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return c, fmt.Errorf("hoststat: bad received bytes %q", fields[0])
+		}
+
+		tx, err := strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			return c, fmt.Errorf("hoststat: bad transmitted bytes %q", fields[8])
+		}
+
+		c.rx += rx
+		c.tx += tx
+	}
+	return c, sc.Err()
+}
+
+// tcpEstablished is the state of established connections in /proc/net/tcp.
+const tcpEstablished = "01"
+
+// parseNetTCP returns the number of established TCP connections of the host,
+// given in the format of /proc/net/tcp and /proc/net/tcp6.
+func parseNetTCP(r io.Reader) (uint64, error) {
+	var n uint64
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		// sl local_address rem_address st ..., after a header line.
+		fields := strings.Fields(sc.Text())
+		if len(fields) > 3 && fields[3] == tcpEstablished {
+			n++
+		}
+	}
+	return n, sc.Err()
+}
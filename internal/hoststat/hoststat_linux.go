@@ -0,0 +1,87 @@
+package hoststat
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// NewSampler returns a Sampler with the first sample of the host taken, or
+// an error if it couldn't be.
+func NewSampler() (*Sampler, error) {
+	cur, err := sample()
+	if err != nil {
+		return nil, err
+	}
+	return &Sampler{cpu: cur.cpu, net: cur.net, taken: cur.taken}, nil
+}
+
+// sample takes a snapshot of the host from the proc filesystem.
+func sample() (s snapshot, err error) {
+	s.taken = time.Now()
+
+	if err = parseFile("/proc/stat", func(f *os.File) (err error) {
+		s.cpu, err = parseStat(f)
+		return err
+	}); err != nil {
+		return s, err
+	}
+
+	if err = parseFile("/proc/meminfo", func(f *os.File) (err error) {
+		s.usage.Memory, err = parseMeminfo(f)
+		return err
+	}); err != nil {
+		return s, err
+	}
+
+	if err = parseFile("/proc/self/status", func(f *os.File) (err error) {
+		s.usage.RSS, err = parseStatus(f)
+		return err
+	}); err != nil {
+		return s, err
+	}
+
+	// Hosts without network interfaces in their namespace only lack them.
+	parseFile("/proc/net/dev", func(f *os.File) (err error) {
+		s.net, err = parseNetDev(f)
+		return err
+	})
+
+	// Hosts without IPv6 only lack tcp6.
+	for _, name := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		parseFile(name, func(f *os.File) error {
+			n, err := parseNetTCP(f)
+			s.usage.Connections += n
+			return err
+		})
+	}
+
+	fds, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return s, err
+	}
+	defer fds.Close()
+
+	names, err := fds.Readdirnames(-1)
+	if err != nil {
+		return s, err
+	}
+	// Less the one of the directory read.
+	s.usage.FDs = uint64(len(names) - 1)
+
+	var lim syscall.Rlimit
+	if err = syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err == nil {
+		s.usage.FDLimit = lim.Cur
+	}
+
+	return s, nil
+}
+
+func parseFile(name string, parse func(*os.File) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return parse(f)
+}
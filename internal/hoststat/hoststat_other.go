@@ -0,0 +1,12 @@
+// +build !linux
+
+package hoststat
+
+// NewSampler returns ErrUnsupported on systems other than Linux.
+func NewSampler() (*Sampler, error) {
+	return nil, ErrUnsupported
+}
+
+func sample() (snapshot, error) {
+	return snapshot{}, ErrUnsupported
+}
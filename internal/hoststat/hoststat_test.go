@@ -0,0 +1,116 @@
+package hoststat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStat(t *testing.T) {
+	t.Parallel()
+
+	stat := `cpu  100 10 50 800 40 0 0 0 20 0
+cpu0 50 5 25 400 20 0 0 0 10 0
+intr 12345
+`
+	got, err := parseStat(strings.NewReader(stat))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (cpuTimes{total: 1000, idle: 840}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if _, err = parseStat(strings.NewReader("intr 12345\n")); err == nil {
+		t.Error("got no error without a cpu line")
+	}
+}
+
+func TestParseMeminfo(t *testing.T) {
+	t.Parallel()
+
+	meminfo := `MemTotal:       16000000 kB
+MemFree:         1000000 kB
+MemAvailable:    4000000 kB
+`
+	got, err := parseMeminfo(strings.NewReader(meminfo))
+	if err != nil {
+		t.Fatal(err)
+	} else if got != 0.75 {
+		t.Errorf("got memory %v, want 0.75", got)
+	}
+
+	if _, err = parseMeminfo(strings.NewReader("MemFree: 1000 kB\n")); err == nil {
+		t.Error("got no error without memory totals")
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	t.Parallel()
+
+	status := `Name:	vegeta
+VmPeak:	  200000 kB
+VmRSS:	   51200 kB
+Threads:	12
+`
+	if got, err := parseStatus(strings.NewReader(status)); err != nil {
+		t.Fatal(err)
+	} else if got != 50<<20 {
+		t.Errorf("got rss %d, want %d", got, 50<<20)
+	}
+}
+
+func TestParseNetDev(t *testing.T) {
+	t.Parallel()
+
+	dev := `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: 9000000    1000    0    0    0     0          0         0  9000000    1000    0    0    0     0       0          0
+  eth0: 1000       10      0    0    0     0          0         0  2000       20      0    0    0     0       0          0
+  eth1: 500        5       0    0    0     0          0         0  250        2       0    0    0     0       0          0
+`
+	got, err := parseNetDev(strings.NewReader(dev))
+	if err != nil {
+		t.Fatal(err)
+	} else if want := (netCounters{rx: 1500, tx: 2250}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseNetTCP(t *testing.T) {
+	t.Parallel()
+
+	tcp := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1000 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 0100007F:C350 01 00000000:00000000 00:00000000 00000000     0        0 1001 1 0000000000000000 20 4 30 10 -1
+   2: 0100007F:C350 0100007F:1F90 01 00000000:00000000 00:00000000 00000000     0        0 1002 1 0000000000000000 20 4 30 10 -1
+   3: 0100007F:C351 0100007F:1F90 06 00000000:00000000 03:00000F9A 00000000     0        0 0 3 0000000000000000
+`
+	if got, err := parseNetTCP(strings.NewReader(tcp)); err != nil {
+		t.Fatal(err)
+	} else if got != 2 {
+		t.Errorf("got %d established connections, want 2", got)
+	}
+}
+
+func TestSampler(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSampler()
+	if err == ErrUnsupported {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := s.Sample()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.CPU < 0 || u.CPU > 1 || u.Memory <= 0 || u.Memory > 1 {
+		t.Errorf("got cpu %v and memory %v, want ratios", u.CPU, u.Memory)
+	} else if u.RSS == 0 || u.FDs == 0 || u.FDLimit < u.FDs {
+		t.Errorf("got rss %d and fds %d of %d", u.RSS, u.FDs, u.FDLimit)
+	}
+}
@@ -0,0 +1,105 @@
+// Package jsonpath implements the subset of JSONPath which selects a single
+// value of a JSON document: the root $, followed by the members of objects,
+// as .name or ['name'], and the elements of arrays, as [index], where
+// negative indices count from the end, e.g. $.items[-1]['unit price'].
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Path selects a value of a JSON document.
+type Path []step
+
+// step selects a member of an object, by name, or else an element of an
+// array, by index.
+type step struct {
+	name  string
+	index int
+	elem  bool
+}
+
+// Parse parses a Path.
+func Parse(expr string) (Path, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("jsonpath %q: must start with $", expr)
+	}
+
+	var p Path
+	for s := expr[1:]; s != ""; {
+		switch {
+		case s[0] == '.':
+			end := strings.IndexAny(s[1:], ".[")
+			if end == -1 {
+				end = len(s) - 1
+			}
+
+			name := s[1 : 1+end]
+			if name == "" {
+				return nil, fmt.Errorf("jsonpath %q: empty member name", expr)
+			}
+
+			p, s = append(p, step{name: name}), s[1+end:]
+		case strings.HasPrefix(s, "['"):
+			end := strings.Index(s[2:], "']")
+			if end == -1 {
+				return nil, fmt.Errorf("jsonpath %q: unterminated member name", expr)
+			}
+			p, s = append(p, step{name: s[2 : 2+end]}), s[2+end+2:]
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("jsonpath %q: missing ]", expr)
+			}
+
+			i, err := strconv.Atoi(strings.TrimSpace(s[1:end]))
+			if err != nil {
+				return nil, fmt.Errorf("jsonpath %q: bad index %q", expr, s[1:end])
+			}
+
+			p, s = append(p, step{index: i, elem: true}), s[end+1:]
+		default:
+			return nil, fmt.Errorf("jsonpath %q: unexpected %q", expr, s)
+		}
+	}
+
+	return p, nil
+}
+
+// Get returns the value of the given document, as decoded by encoding/json
+// into an interface{}, which the Path selects, if it has one.
+func (p Path) Get(doc interface{}) (interface{}, bool) {
+	v := doc
+	for _, s := range p {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			if s.elem {
+				return nil, false
+			}
+
+			var ok bool
+			if v, ok = t[s.name]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			if !s.elem {
+				return nil, false
+			}
+
+			i := s.index
+			if i < 0 {
+				i += len(t)
+			}
+
+			if i < 0 || i >= len(t) {
+				return nil, false
+			}
+			v = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,64 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	t.Parallel()
+
+	var doc interface{}
+	err := json.Unmarshal([]byte(`{
+		"status": "ok",
+		"data": {"items": [{"id": 1, "unit price": 9.5}, {"id": 2, "tags": ["a", "b"]}]},
+		"empty": null
+	}`), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		expr string
+		want interface{}
+		ok   bool
+		err  bool
+	}{
+		{expr: "$", want: doc, ok: true},
+		{expr: "$.status", want: "ok", ok: true},
+		{expr: "$.data.items[0].id", want: 1.0, ok: true},
+		{expr: "$.data.items[0]['unit price']", want: 9.5, ok: true},
+		{expr: "$['data'].items[-1].tags[1]", want: "b", ok: true},
+		{expr: "$.empty", want: nil, ok: true},
+		{expr: "$.missing", ok: false},
+		{expr: "$.data.items[2]", ok: false},
+		{expr: "$.data.items[-3]", ok: false},
+		{expr: "$.data.items.id", ok: false},
+		{expr: "$.status[0]", ok: false},
+		{expr: "$[0]", ok: false},
+		{expr: "status", err: true},
+		{expr: "$.", err: true},
+		{expr: "$..id", err: true},
+		{expr: "$.items[x]", err: true},
+		{expr: "$.items[0", err: true},
+		{expr: "$['unit price", err: true},
+		{expr: "$items", err: true},
+	} {
+		p, err := Parse(tc.expr)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: got no error", tc.expr)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%s: %v", tc.expr, err)
+			continue
+		}
+
+		got, ok := p.Get(doc)
+		if ok != tc.ok || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got (%v, %t), want (%v, %t)", tc.expr, got, ok, tc.want, tc.ok)
+		}
+	}
+}
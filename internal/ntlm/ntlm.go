@@ -0,0 +1,191 @@
+// Package ntlm implements the client side of NTLMv2 authentication (MS-NLMP):
+// the NEGOTIATE_MESSAGE it starts with, and the AUTHENTICATE_MESSAGE which
+// answers the CHALLENGE_MESSAGE of servers. Session security, i.e. signing and
+// sealing, isn't supported, as HTTP authentication doesn't use it.
+package ntlm
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+var signature = []byte("NTLMSSP\x00")
+
+// Negotiate flags.
+const (
+	negotiateUnicode     = 0x00000001
+	negotiateOEM         = 0x00000002
+	requestTarget        = 0x00000004
+	negotiateNTLM        = 0x00000200
+	negotiateAlwaysSign  = 0x00008000
+	negotiateExtendedSec = 0x00080000
+	negotiateTargetInfo  = 0x00800000
+	negotiate128         = 0x20000000
+	negotiateKeyExchange = 0x40000000
+	negotiate56          = 0x80000000
+)
+
+const negotiateFlags = negotiateUnicode | negotiateOEM | requestTarget | negotiateNTLM |
+	negotiateAlwaysSign | negotiateExtendedSec | negotiateTargetInfo | negotiate128 | negotiate56
+
+// AV_PAIR IDs of the target info of challenges.
+const (
+	avEOL       = 0
+	avTimestamp = 7
+)
+
+// ErrInvalidChallenge is returned by Authenticate when the challenge isn't a
+// valid CHALLENGE_MESSAGE.
+var ErrInvalidChallenge = errors.New("ntlm: invalid challenge message")
+
+// Negotiate returns a NEGOTIATE_MESSAGE, without domain nor workstation.
+func Negotiate() []byte {
+	msg := make([]byte, 32)
+	copy(msg, signature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], negotiateFlags)
+	return msg
+}
+
+// Authenticate returns the AUTHENTICATE_MESSAGE answering the given
+// CHALLENGE_MESSAGE with the NTLMv2 responses of the given credentials.
+func Authenticate(challenge []byte, domain, user, password string) ([]byte, error) {
+	var client [8]byte
+	if _, err := rand.Read(client[:]); err != nil {
+		return nil, err
+	}
+	return authenticate(challenge, domain, user, password, client[:], fileTime(time.Now()))
+}
+
+func authenticate(challenge []byte, domain, user, password string, client, timestamp []byte) ([]byte, error) {
+	if len(challenge) < 48 || !bytes.Equal(challenge[:8], signature) ||
+		binary.LittleEndian.Uint32(challenge[8:]) != 2 {
+		return nil, ErrInvalidChallenge
+	}
+
+	flags := binary.LittleEndian.Uint32(challenge[20:])
+	server := challenge[24:32]
+
+	info, ok := field(challenge, 40)
+	if !ok {
+		return nil, ErrInvalidChallenge
+	}
+
+	// The timestamp of the server, if given, must be used instead of ours,
+	// in which case the LMv2 response is left empty.
+	lm := make([]byte, 24)
+	if ts, ok := avPair(info, avTimestamp); ok && len(ts) == 8 {
+		timestamp = ts
+	} else {
+		lm = append(hmacMD5(ntowfv2(domain, user, password), server, client), client...)
+	}
+
+	temp := make([]byte, 0, 28+len(info)+4)
+	temp = append(temp, 1, 1, 0, 0, 0, 0, 0, 0)
+	temp = append(temp, timestamp...)
+	temp = append(temp, client...)
+	temp = append(temp, 0, 0, 0, 0)
+	temp = append(temp, info...)
+	temp = append(temp, 0, 0, 0, 0)
+
+	proof := hmacMD5(ntowfv2(domain, user, password), server, temp)
+	nt := append(proof, temp...)
+
+	encode := oem
+	if flags&negotiateUnicode != 0 {
+		encode = unicode
+	}
+
+	fields := [][]byte{lm, nt, encode(domain), encode(user), encode(""), nil}
+
+	const header = 64
+	msg := make([]byte, header)
+	copy(msg, signature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+	binary.LittleEndian.PutUint32(msg[60:], flags&^negotiateKeyExchange)
+
+	for i, f := range fields {
+		off := 12 + 8*i
+		binary.LittleEndian.PutUint16(msg[off:], uint16(len(f)))
+		binary.LittleEndian.PutUint16(msg[off+2:], uint16(len(f)))
+		binary.LittleEndian.PutUint32(msg[off+4:], uint32(len(msg)))
+		msg = append(msg, f...)
+	}
+
+	return msg, nil
+}
+
+// ntowfv2 returns the NTLMv2 one-way function of the given credentials.
+func ntowfv2(domain, user, password string) []byte {
+	h := md4.New()
+	h.Write(unicode(password))
+	return hmacMD5(h.Sum(nil), unicode(strings.ToUpper(user)+domain))
+}
+
+// field returns the payload referenced by the security buffer at the given
+// offset of the given message.
+func field(msg []byte, off int) ([]byte, bool) {
+	n := int(binary.LittleEndian.Uint16(msg[off:]))
+	start := int(binary.LittleEndian.Uint32(msg[off+4:]))
+	if n == 0 {
+		return nil, true
+	} else if start < 0 || start+n > len(msg) {
+		return nil, false
+	}
+	return msg[start : start+n], true
+}
+
+// avPair returns the value of the AV_PAIR with the given ID in the given
+// target info.
+func avPair(info []byte, id uint16) ([]byte, bool) {
+	for len(info) >= 4 {
+		avID := binary.LittleEndian.Uint16(info)
+		n := int(binary.LittleEndian.Uint16(info[2:]))
+		if avID == avEOL || len(info) < 4+n {
+			break
+		} else if avID == id {
+			return info[4 : 4+n], true
+		}
+		info = info[4+n:]
+	}
+	return nil, false
+}
+
+// fileTime returns the given time as a little-endian Windows FILETIME: the
+// number of 100ns intervals since January 1, 1601.
+func fileTime(t time.Time) []byte {
+	const epochDelta = 116444736000000000
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(t.UnixNano()/100+epochDelta))
+	return b
+}
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	h := hmac.New(md5.New, key)
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+func unicode(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(u))
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(b[2*i:], c)
+	}
+	return b
+}
+
+func oem(s string) []byte {
+	return []byte(s)
+}
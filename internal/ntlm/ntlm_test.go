@@ -0,0 +1,118 @@
+package ntlm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+// challenge returns a CHALLENGE_MESSAGE with the given flags, server
+// challenge and target info.
+func challenge(flags uint32, server, info []byte) []byte {
+	msg := make([]byte, 48)
+	copy(msg, signature)
+	binary.LittleEndian.PutUint32(msg[8:], 2)
+	binary.LittleEndian.PutUint32(msg[20:], flags)
+	copy(msg[24:], server)
+	binary.LittleEndian.PutUint16(msg[40:], uint16(len(info)))
+	binary.LittleEndian.PutUint16(msg[42:], uint16(len(info)))
+	binary.LittleEndian.PutUint32(msg[44:], 48)
+	return append(msg, info...)
+}
+
+func avPairs(pairs ...interface{}) []byte {
+	var b []byte
+	for i := 0; i < len(pairs); i += 2 {
+		v := pairs[i+1].([]byte)
+		b = append(b, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint16(b[len(b)-4:], uint16(pairs[i].(int)))
+		binary.LittleEndian.PutUint16(b[len(b)-2:], uint16(len(v)))
+		b = append(b, v...)
+	}
+	return append(b, 0, 0, 0, 0)
+}
+
+func unhex(t testing.TB, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestAuthenticate checks the NTLMv2 responses against the example of
+// section 4.2.4 of MS-NLMP.
+func TestAuthenticate(t *testing.T) {
+	t.Parallel()
+
+	server := unhex(t, "0123456789abcdef")
+	client := unhex(t, "aaaaaaaaaaaaaaaa")
+	info := avPairs(2, unicode("Domain"), 1, unicode("Server"))
+	chal := challenge(negotiateUnicode|negotiateNTLM|negotiateTargetInfo|negotiateKeyExchange, server, info)
+
+	msg, err := authenticate(chal, "Domain", "User", "Password", client, make([]byte, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := binary.LittleEndian.Uint32(msg[8:]); got != 3 {
+		t.Fatalf("got message type %d, want 3", got)
+	} else if flags := binary.LittleEndian.Uint32(msg[60:]); flags&negotiateKeyExchange != 0 {
+		t.Errorf("got key exchange flag in %#x, without a session key", flags)
+	}
+
+	for _, tc := range []struct {
+		name string
+		off  int
+		want []byte
+	}{
+		{"LMv2 response", 12, unhex(t, "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa")},
+		{"NTProofStr", 20, unhex(t, "68cd0ab851e51c96aabc927bebef6a1c")},
+		{"domain", 28, unicode("Domain")},
+		{"user", 36, unicode("User")},
+		{"workstation", 44, nil},
+	} {
+		got, ok := field(msg, tc.off)
+		if !ok {
+			t.Errorf("%s: invalid security buffer", tc.name)
+		} else if !bytes.HasPrefix(got, tc.want) || tc.want == nil && got != nil {
+			t.Errorf("%s: got %x, want %x", tc.name, got, tc.want)
+		}
+	}
+
+	// The timestamp of the server is used instead, without an LMv2 response.
+	ts := unhex(t, "0090d336b734c301")
+	chal = challenge(0, server, avPairs(avTimestamp, ts))
+	if msg, err = Authenticate(chal, "Domain", "User", "Password"); err != nil {
+		t.Fatal(err)
+	}
+
+	if lm, _ := field(msg, 12); !bytes.Equal(lm, make([]byte, 24)) {
+		t.Errorf("got LMv2 response %x, want zeros", lm)
+	} else if nt, _ := field(msg, 20); !bytes.Equal(nt[24:32], ts) {
+		t.Errorf("got timestamp %x, want the server's %x", nt[24:32], ts)
+	} else if user, _ := field(msg, 36); string(user) != "User" {
+		t.Errorf("got OEM user %q, want %q", user, "User")
+	}
+
+	overflow := challenge(0, server, info)
+	binary.LittleEndian.PutUint16(overflow[40:], 0xffff)
+
+	for _, chal := range [][]byte{nil, Negotiate(), challenge(0, server, info)[:47], overflow} {
+		if _, err := Authenticate(chal, "", "", ""); err != ErrInvalidChallenge {
+			t.Errorf("got error %v with challenge %x, want %v", err, chal, ErrInvalidChallenge)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+
+	msg := Negotiate()
+	if !bytes.HasPrefix(msg, signature) || binary.LittleEndian.Uint32(msg[8:]) != 1 {
+		t.Errorf("got invalid negotiate message %x", msg)
+	} else if flags := binary.LittleEndian.Uint32(msg[12:]); flags&negotiateNTLM == 0 {
+		t.Errorf("got flags %#x without NTLM", flags)
+	}
+}
@@ -0,0 +1,235 @@
+// Package oauth2 implements the small subset of OAuth 2.0 (RFC 6749) vegeta
+// needs to authorize attacks with access tokens: obtaining them with the
+// client credentials or refresh token grants, and refreshing them in the
+// background before they expire, so that long attacks outlive them.
+package oauth2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config is the configuration of a TokenSource.
+type Config struct {
+	// TokenURL is the URL of the token endpoint of the authorization server.
+	TokenURL string
+	// ClientID and ClientSecret authenticate the client with HTTP basic
+	// authentication. The ClientSecret of public clients is empty.
+	ClientID     string
+	ClientSecret string
+	// Scopes are the scopes of the requested tokens, if any.
+	Scopes []string
+	// RefreshToken, if set, is exchanged for access tokens with the refresh
+	// token grant instead of the client credentials grant.
+	RefreshToken string
+}
+
+// Token is an access token.
+type Token struct {
+	AccessToken string
+	TokenType   string
+	// Expiry is when the token expires, or zero if it doesn't.
+	Expiry time.Time
+}
+
+// Authorization returns the value of the Authorization header of requests
+// authorized with the Token.
+func (t Token) Authorization() string {
+	typ := t.TokenType
+	if typ == "" || strings.EqualFold(typ, "bearer") {
+		typ = "Bearer"
+	}
+	return typ + " " + t.AccessToken
+}
+
+// Retry intervals of failed refreshes, which go on with the current token
+// until it expires.
+const (
+	minRetryInterval = time.Second
+	maxRetryInterval = time.Minute
+)
+
+// TokenSource holds an access token which it refreshes in the background,
+// once three quarters of its lifetime passed, so that requests are never
+// held up by the token endpoint.
+type TokenSource struct {
+	cfg    Config
+	client *http.Client
+
+	mu      sync.RWMutex
+	tok     Token
+	refresh string // Rotated by the authorization server, if it issues new ones.
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewTokenSource returns a TokenSource with the first token obtained with
+// the given configuration and client, or an error if that failed.
+func NewTokenSource(cfg Config, client *http.Client) (*TokenSource, error) {
+	if cfg.TokenURL == "" {
+		return nil, errors.New("oauth2: missing token URL")
+	}
+
+	s := &TokenSource{cfg: cfg, client: client, refresh: cfg.RefreshToken, stop: make(chan struct{})}
+
+	tok, lifetime, err := s.fetch()
+	if err != nil {
+		return nil, err
+	}
+	s.tok = tok
+
+	if lifetime > 0 {
+		go s.refreshLoop(lifetime)
+	}
+
+	return s, nil
+}
+
+// Token returns the current token.
+func (s *TokenSource) Token() Token {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tok
+}
+
+// Authorize sets the Authorization header of the given request with the
+// current token.
+func (s *TokenSource) Authorize(req *http.Request) error {
+	req.Header.Set("Authorization", s.Token().Authorization())
+	return nil
+}
+
+// Close stops refreshing the token.
+func (s *TokenSource) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	return nil
+}
+
+func (s *TokenSource) refreshLoop(lifetime time.Duration) {
+	retry := minRetryInterval
+	timer := time.NewTimer(lifetime * 3 / 4)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-timer.C:
+		}
+
+		tok, next, err := s.fetch()
+		if err != nil {
+			// The current token is kept until it expires.
+			timer.Reset(retry)
+			if retry *= 2; retry > maxRetryInterval {
+				retry = maxRetryInterval
+			}
+			continue
+		}
+
+		s.mu.Lock()
+		s.tok = tok
+		s.mu.Unlock()
+
+		if next <= 0 {
+			return
+		}
+
+		retry = minRetryInterval
+		timer.Reset(next * 3 / 4)
+	}
+}
+
+// fetch requests a token from the token endpoint, and returns it with its
+// lifetime, or zero if it doesn't expire.
+func (s *TokenSource) fetch() (Token, time.Duration, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+
+	s.mu.RLock()
+	if s.refresh != "" {
+		form = url.Values{"grant_type": {"refresh_token"}, "refresh_token": {s.refresh}}
+	}
+	s.mu.RUnlock()
+
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+
+	// Public clients identify themselves in the request body.
+	if s.cfg.ClientSecret == "" && s.cfg.ClientID != "" {
+		form.Set("client_id", s.cfg.ClientID)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+	}
+
+	started := time.Now()
+	res, err := s.client.Do(req)
+	if err != nil {
+		return Token{}, 0, fmt.Errorf("oauth2: %v", err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return Token{}, 0, fmt.Errorf("oauth2: %v", err)
+	}
+
+	var r struct {
+		AccessToken  string      `json:"access_token"`
+		TokenType    string      `json:"token_type"`
+		ExpiresIn    json.Number `json:"expires_in"`
+		RefreshToken string      `json:"refresh_token"`
+		Error        string      `json:"error"`
+		Description  string      `json:"error_description"`
+	}
+
+	if err = json.Unmarshal(body, &r); err != nil && res.StatusCode == http.StatusOK {
+		return Token{}, 0, fmt.Errorf("oauth2: invalid token response: %v", err)
+	}
+
+	switch {
+	case r.Error != "" && r.Description != "":
+		return Token{}, 0, fmt.Errorf("oauth2: %s: %s", r.Error, r.Description)
+	case r.Error != "":
+		return Token{}, 0, fmt.Errorf("oauth2: %s", r.Error)
+	case res.StatusCode != http.StatusOK:
+		return Token{}, 0, fmt.Errorf("oauth2: token endpoint responded with %s", res.Status)
+	case r.AccessToken == "":
+		return Token{}, 0, errors.New("oauth2: no access token in token response")
+	}
+
+	tok := Token{AccessToken: r.AccessToken, TokenType: r.TokenType}
+
+	var lifetime time.Duration
+	if secs, err := r.ExpiresIn.Int64(); err == nil && secs > 0 {
+		lifetime = time.Duration(secs) * time.Second
+		tok.Expiry = started.Add(lifetime)
+	}
+
+	if r.RefreshToken != "" {
+		s.mu.Lock()
+		if s.refresh != "" {
+			s.refresh = r.RefreshToken
+		}
+		s.mu.Unlock()
+	}
+
+	return tok, lifetime, nil
+}
@@ -0,0 +1,127 @@
+package oauth2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenSource(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu     sync.Mutex
+		issued int
+		grants []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+
+		grant := r.PostForm.Get("grant_type")
+		switch grant {
+		case "client_credentials":
+			if id, secret, _ := r.BasicAuth(); id != "goku" || secret != "kamehameha" {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"error":"invalid_client","error_description":"bad secret"}`)
+				return
+			} else if scope := r.PostForm.Get("scope"); scope != "read write" {
+				t.Errorf("got scope %q, want %q", scope, "read write")
+			}
+		case "refresh_token":
+			grant += ":" + r.PostForm.Get("refresh_token")
+			if id := r.PostForm.Get("client_id"); id != "goku" {
+				t.Errorf("got client_id %q, want %q", id, "goku")
+			}
+		}
+
+		grants = append(grants, grant)
+		issued++
+		fmt.Fprintf(w, `{"access_token":"tok-%d","token_type":"bearer","expires_in":"1","refresh_token":"ref-%d"}`, issued, issued)
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name   string
+		cfg    Config
+		grants []string
+	}{
+		{
+			name:   "client credentials",
+			cfg:    Config{ClientID: "goku", ClientSecret: "kamehameha", Scopes: []string{"read", "write"}},
+			grants: []string{"client_credentials", "client_credentials"},
+		},
+		{
+			name:   "refresh token",
+			cfg:    Config{ClientID: "goku", RefreshToken: "ref-0"},
+			grants: []string{"refresh_token:ref-0", "refresh_token:ref-1"},
+		},
+	} {
+		mu.Lock()
+		issued, grants = 0, nil
+		mu.Unlock()
+
+		tc.cfg.TokenURL = server.URL
+		ts, err := NewTokenSource(tc.cfg, server.Client())
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		req, _ := http.NewRequest("GET", "http://goku", nil)
+		if err = ts.Authorize(req); err != nil {
+			t.Fatal(err)
+		} else if got, want := req.Header.Get("Authorization"), "Bearer tok-1"; got != want {
+			t.Errorf("%s: got Authorization %q, want %q", tc.name, got, want)
+		}
+
+		// Tokens are refreshed after three quarters of their lifetime.
+		deadline := time.Now().Add(3 * time.Second)
+		for ts.Token().AccessToken == "tok-1" && time.Now().Before(deadline) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		ts.Close()
+
+		mu.Lock()
+		if got := ts.Token().AccessToken; got != "tok-2" {
+			t.Errorf("%s: got refreshed token %q, want %q", tc.name, got, "tok-2")
+		} else if strings.Join(grants[:2], ",") != strings.Join(tc.grants, ",") {
+			t.Errorf("%s: got grants %v, want %v", tc.name, grants[:2], tc.grants)
+		}
+		mu.Unlock()
+	}
+
+	_, err := NewTokenSource(Config{TokenURL: server.URL, ClientID: "goku", ClientSecret: "genkidama"}, server.Client())
+	if want := "oauth2: invalid_client: bad secret"; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+
+	if _, err = NewTokenSource(Config{}, server.Client()); err == nil {
+		t.Error("got no error without a token URL")
+	}
+}
+
+func TestToken_Authorization(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		tok  Token
+		want string
+	}{
+		{Token{AccessToken: "a"}, "Bearer a"},
+		{Token{AccessToken: "b", TokenType: "bearer"}, "Bearer b"},
+		{Token{AccessToken: "c", TokenType: "MAC"}, "MAC c"},
+	} {
+		if got := tc.tok.Authorization(); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+// Package otlp implements the small subset of the OpenTelemetry protocol
+// (OTLP) vegeta needs to export the spans of traced hits: batching them in
+// the background and posting them to an OTLP/HTTP traces endpoint, such as
+// that of an OpenTelemetry collector, encoded as JSON.
+//
+// See https://opentelemetry.io/docs/specs/otlp/#otlphttp
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// Spans are posted once this many are buffered, or every flushInterval,
+// and dropped while more than maxBuffered are, i.e. while the endpoint
+// doesn't keep up.
+const (
+	batchSize     = 512
+	maxBuffered   = 64 * batchSize
+	flushInterval = time.Second
+)
+
+// TracesPath is the path of the traces endpoint of OTLP/HTTP receivers.
+const TracesPath = "/v1/traces"
+
+// Exporter exports spans to an OTLP/HTTP traces endpoint in the background.
+type Exporter struct {
+	url      string
+	header   http.Header
+	client   *http.Client
+	resource []attribute
+
+	mu      sync.Mutex
+	spans   []vegeta.Span
+	dropped int
+	err     error // First export error.
+
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewExporter returns an Exporter posting spans with the given header and
+// client to the given endpoint, with TracesPath appended to it unless it has a
+// path, as the spans of the given service. It returns an error if the
+// endpoint isn't an HTTP(S) URL.
+func NewExporter(endpoint, service string, header http.Header, client *http.Client) (*Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("otlp: bad endpoint: %v", err)
+	} else if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("otlp: bad endpoint %q: not an http(s) URL", endpoint)
+	}
+
+	if u.Path == "" || u.Path == "/" {
+		u.Path = TracesPath
+	}
+
+	e := &Exporter{
+		url:      u.String(),
+		header:   header,
+		client:   client,
+		resource: []attribute{{Key: "service.name", Value: value(service)}},
+		flush:    make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go e.loop()
+
+	return e, nil
+}
+
+// Export buffers the given spans of a hit to be exported. It's safe for
+// concurrent use, and it doesn't block on the endpoint.
+func (e *Exporter) Export(spans []vegeta.Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.spans)+len(spans) > maxBuffered {
+		e.dropped += len(spans)
+		return
+	}
+
+	if e.spans = append(e.spans, spans...); len(e.spans) >= batchSize {
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Close exports the buffered spans and stops the Exporter. It returns the
+// first error exporting spans, if any, including that of spans dropped.
+func (e *Exporter) Close() error {
+	close(e.stop)
+	<-e.done
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.err == nil && e.dropped > 0 {
+		e.err = fmt.Errorf("otlp: dropped %d spans while the endpoint lagged", e.dropped)
+	}
+
+	return e.err
+}
+
+func (e *Exporter) loop() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.flush:
+		case <-e.stop:
+			e.export()
+			return
+		}
+		e.export()
+	}
+}
+
+// export posts the buffered spans in batches.
+func (e *Exporter) export() {
+	for {
+		e.mu.Lock()
+		batch := e.spans
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		e.spans = e.spans[len(batch):]
+		e.mu.Unlock()
+
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := e.post(batch); err != nil {
+			e.mu.Lock()
+			if e.err == nil {
+				e.err = err
+			}
+			e.mu.Unlock()
+		}
+	}
+}
+
+func (e *Exporter) post(spans []vegeta.Span) error {
+	body, err := json.Marshal(encode(e.resource, spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for k, vs := range e.header {
+		req.Header[k] = append(req.Header[k], vs...)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("otlp: error exporting spans: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("otlp: error exporting spans: %s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+
+	_, err = io.Copy(ioutil.Discard, res.Body)
+	return err
+}
+
+// The JSON encoding of an ExportTraceServiceRequest.
+type (
+	request struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}
+
+	resourceSpans struct {
+		Resource   resource     `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+
+	resource struct {
+		Attributes []attribute `json:"attributes"`
+	}
+
+	scopeSpans struct {
+		Scope scope  `json:"scope"`
+		Spans []span `json:"spans"`
+	}
+
+	scope struct {
+		Name string `json:"name"`
+	}
+
+	span struct {
+		TraceID           string      `json:"traceId"`
+		SpanID            string      `json:"spanId"`
+		ParentSpanID      string      `json:"parentSpanId,omitempty"`
+		Name              string      `json:"name"`
+		Kind              int         `json:"kind"`
+		StartTimeUnixNano string      `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+		Attributes        []attribute `json:"attributes,omitempty"`
+		Status            *status     `json:"status,omitempty"`
+	}
+
+	attribute struct {
+		Key   string      `json:"key"`
+		Value interface{} `json:"value"`
+	}
+
+	status struct {
+		Message string `json:"message,omitempty"`
+		Code    int    `json:"code"`
+	}
+)
+
+// Span kinds and status codes.
+const (
+	kindInternal = 1
+	kindClient   = 3
+	statusError  = 2
+)
+
+func encode(res []attribute, spans []vegeta.Span) request {
+	ss := make([]span, 0, len(spans))
+	for _, s := range spans {
+		out := span{
+			TraceID:           s.TraceID,
+			SpanID:            s.SpanID,
+			ParentSpanID:      s.ParentID,
+			Name:              s.Name,
+			Kind:              kindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+		}
+
+		if s.Client {
+			out.Kind = kindClient
+		}
+
+		keys := make([]string, 0, len(s.Attributes))
+		for k := range s.Attributes {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			out.Attributes = append(out.Attributes, attribute{Key: k, Value: value(s.Attributes[k])})
+		}
+
+		if s.Error != "" {
+			out.Status = &status{Message: s.Error, Code: statusError}
+		}
+
+		ss = append(ss, out)
+	}
+
+	return request{ResourceSpans: []resourceSpans{{
+		Resource:   resource{Attributes: res},
+		ScopeSpans: []scopeSpans{{Scope: scope{Name: "vegeta"}, Spans: ss}},
+	}}}
+}
+
+// value returns the JSON encoding of an AnyValue, in which 64 bit integers are
+// strings.
+func value(v interface{}) map[string]interface{} {
+	switch v := v.(type) {
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprint(v)}
+	}
+}
@@ -0,0 +1,130 @@
+package otlp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestExporter(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu    sync.Mutex
+		posts []request
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != TracesPath || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Api-Key") != "secret" {
+			t.Errorf("got %s %s with header %v", r.Method, r.URL, r.Header)
+		}
+
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+
+		mu.Lock()
+		posts = append(posts, req)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	e, err := NewExporter(server.URL, "vegeta", http.Header{"Api-Key": {"secret"}}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	begin := time.Unix(0, 1e9)
+	e.Export([]vegeta.Span{
+		{
+			TraceID:    "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanID:     "00f067aa0ba902b7",
+			Name:       "GET",
+			Client:     true,
+			Start:      begin,
+			End:        begin.Add(time.Second),
+			Attributes: map[string]interface{}{"http.url": "http://goku", "http.status_code": int64(500)},
+			Error:      "500 Internal Server Error",
+		},
+		{
+			TraceID:  "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanID:   "00f067aa0ba902b8",
+			ParentID: "00f067aa0ba902b7",
+			Name:     "ttfb",
+			Start:    begin,
+			End:      begin.Add(time.Millisecond),
+		},
+	})
+
+	if err = e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(posts) != 1 {
+		t.Fatalf("got %d posts, want 1", len(posts))
+	}
+
+	got, _ := json.Marshal(posts[0])
+	want := `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"vegeta"}}]},` +
+		`"scopeSpans":[{"scope":{"name":"vegeta"},"spans":[` +
+		`{"traceId":"4bf92f3577b34da6a3ce929d0e0e4736","spanId":"00f067aa0ba902b7","name":"GET","kind":3,` +
+		`"startTimeUnixNano":"1000000000","endTimeUnixNano":"2000000000","attributes":[` +
+		`{"key":"http.status_code","value":{"intValue":"500"}},{"key":"http.url","value":{"stringValue":"http://goku"}}],` +
+		`"status":{"message":"500 Internal Server Error","code":2}},` +
+		`{"traceId":"4bf92f3577b34da6a3ce929d0e0e4736","spanId":"00f067aa0ba902b8","parentSpanId":"00f067aa0ba902b7",` +
+		`"name":"ttfb","kind":1,"startTimeUnixNano":"1000000000","endTimeUnixNano":"1001000000"}]}]}]}`
+
+	if string(got) != want {
+		t.Errorf("got request\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExporterErrors(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "over quota", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	e, err := NewExporter(server.URL+"/custom", "vegeta", nil, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.Export([]vegeta.Span{{Name: "GET"}})
+	if err = e.Close(); err == nil || !strings.Contains(err.Error(), "over quota") {
+		t.Errorf("got error %v, want the endpoint's", err)
+	}
+
+	for _, endpoint := range []string{"", "collector:4318", "grpc://collector:4317"} {
+		if _, err := NewExporter(endpoint, "vegeta", nil, http.DefaultClient); err == nil {
+			t.Errorf("NewExporter(%q): got no error", endpoint)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		in   interface{}
+		want map[string]interface{}
+	}{
+		{"goku", map[string]interface{}{"stringValue": "goku"}},
+		{int64(9001), map[string]interface{}{"intValue": "9001"}},
+		{true, map[string]interface{}{"boolValue": true}},
+	} {
+		if got := value(tc.in); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("value(%v) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Package prompb implements the protobuf encoding of the Prometheus
+// remote write WriteRequest message, which is all vegeta needs to push
+// metrics without depending on the Prometheus code base.
+//
+// See https://github.com/prometheus/prometheus/blob/master/prompb/remote.proto
+package prompb
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// A WriteRequest is a batch of TimeSeries to be written.
+type WriteRequest struct {
+	Timeseries []TimeSeries
+}
+
+// A TimeSeries is a set of Samples identified by its Labels.
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+// A Label is a name value pair identifying a TimeSeries.
+// The metric name is given by the special __name__ label.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// A Sample is a value at a given Unix timestamp in milliseconds.
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// Marshal returns the protobuf encoding of the WriteRequest.
+func (w *WriteRequest) Marshal() []byte {
+	var buf, ts, msg []byte
+	for i := range w.Timeseries {
+		ts = ts[:0]
+		for _, l := range w.Timeseries[i].Labels {
+			msg = appendString(msg[:0], 1, l.Name)
+			msg = appendString(msg, 2, l.Value)
+			ts = appendBytes(ts, 1, msg)
+		}
+		for _, s := range w.Timeseries[i].Samples {
+			msg = appendKey(msg[:0], 1, wireFixed64)
+			msg = appendFixed64(msg, math.Float64bits(s.Value))
+			msg = appendKey(msg, 2, wireVarint)
+			msg = appendUvarint(msg, uint64(s.Timestamp))
+			ts = appendBytes(ts, 2, msg)
+		}
+		buf = appendBytes(buf, 1, ts)
+	}
+	return buf
+}
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+func appendKey(b []byte, field, wire uint64) []byte {
+	return appendUvarint(b, field<<3|wire)
+}
+
+func appendFixed64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendBytes(b []byte, field uint64, v []byte) []byte {
+	b = appendKey(b, field, wireBytes)
+	b = appendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendString(b []byte, field uint64, v string) []byte {
+	b = appendKey(b, field, wireBytes)
+	b = appendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
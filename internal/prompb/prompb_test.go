@@ -0,0 +1,29 @@
+package prompb
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteRequestMarshal(t *testing.T) {
+	t.Parallel()
+
+	req := WriteRequest{Timeseries: []TimeSeries{{
+		Labels:  []Label{{Name: "__name__", Value: "up"}},
+		Samples: []Sample{{Value: 1, Timestamp: 1000}},
+	}}}
+
+	want := []byte{
+		0x0a, 0x1e, // timeseries, 30 bytes
+		0x0a, 0x0e, // label, 14 bytes
+		0x0a, 0x08, '_', '_', 'n', 'a', 'm', 'e', '_', '_',
+		0x12, 0x02, 'u', 'p',
+		0x12, 0x0c, // sample, 12 bytes
+		0x09, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f, // 1.0
+		0x10, 0xe8, 0x07, // 1000
+	}
+
+	if got := req.Marshal(); !bytes.Equal(got, want) {
+		t.Errorf("\ngot:  % x\nwant: % x", got, want)
+	}
+}
@@ -1,18 +1,28 @@
 package resolver
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync/atomic"
+	"time"
 )
 
 type resolver struct {
 	addrs  []string
 	dialer *net.Dialer
+	tlsc   *tls.Config
+	client *http.Client
 	idx    uint64
 }
 
@@ -20,8 +30,17 @@ type resolver struct {
 // into net.DefaultResolver.  Addresses should be a list of
 // ip addrs and optional port numbers, separated by colon.
 // For example: 1.2.3.4:53 and 1.2.3.4 are both valid.  In the absence
-// of a port number, 53 will be used instead.
+// of a port number, 53 will be used instead. DNS over TLS servers are
+// given as tls://host with an optional port, 853 by default, and DNS over
+// HTTPS ones as the https:// URL of their endpoint, e.g.
+// https://dns.google/dns-query.
 func NewResolver(addrs []string) (*net.Resolver, error) {
+	return newResolver(addrs, nil)
+}
+
+// newResolver returns a resolver which verifies the certificates of DNS over
+// TLS and HTTPS servers with the given config, if any.
+func newResolver(addrs []string, tlsc *tls.Config) (*net.Resolver, error) {
 	if len(addrs) == 0 {
 		return nil, errors.New("must specify at least resolver address")
 	}
@@ -29,15 +48,42 @@ func NewResolver(addrs []string) (*net.Resolver, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &net.Resolver{
-		PreferGo: true,
-		Dial:     (&resolver{addrs: cleanAddrs, dialer: &net.Dialer{}}).dial,
-	}, nil
+
+	// Resolvers' own host names are resolved by the system.
+	r := &resolver{
+		addrs:  cleanAddrs,
+		dialer: &net.Dialer{},
+		tlsc:   tlsc,
+		client: &http.Client{Transport: &http.Transport{TLSClientConfig: tlsc, ForceAttemptHTTP2: true}},
+	}
+
+	return &net.Resolver{PreferGo: true, Dial: r.dial}, nil
 }
 
 func normalizeAddrs(addrs []string) ([]string, error) {
 	normal := make([]string, len(addrs))
 	for i, addr := range addrs {
+		switch {
+		case strings.HasPrefix(addr, "https://"):
+			u, err := url.Parse(addr)
+			if err != nil {
+				return nil, err
+			} else if u.Host == "" {
+				return nil, fmt.Errorf("DNS over HTTPS URL %s has no host", addr)
+			}
+			normal[i] = addr
+			continue
+		case strings.HasPrefix(addr, "tls://"):
+			hostport := strings.TrimPrefix(addr, "tls://")
+			if _, _, err := net.SplitHostPort(hostport); err != nil {
+				hostport = net.JoinHostPort(hostport, "853")
+			}
+			if _, _, err := net.SplitHostPort(hostport); err != nil {
+				return nil, err
+			}
+			normal[i] = "tls://" + hostport
+			continue
+		}
 
 		// if addr has no port, give it 53
 		if !strings.Contains(addr, ":") {
@@ -70,9 +116,140 @@ func normalizeAddrs(addrs []string) ([]string, error) {
 // ignore the third parameter, as this represents the dns server address that
 // we are overriding.
 func (r *resolver) dial(ctx context.Context, network, _ string) (net.Conn, error) {
-	return r.dialer.DialContext(ctx, network, r.address())
+	addr := r.address()
+	switch {
+	case strings.HasPrefix(addr, "https://"):
+		return &dohConn{ctx: ctx, client: r.client, url: addr}, nil
+	case strings.HasPrefix(addr, "tls://"):
+		return r.dialTLS(ctx, strings.TrimPrefix(addr, "tls://"))
+	}
+	return r.dialer.DialContext(ctx, network, addr)
+}
+
+// dialTLS dials the DNS over TLS server at the given address. Since the
+// returned connection isn't a net.PacketConn, queries are sent over it as
+// over TCP, which is what DNS over TLS does (RFC 7858).
+func (r *resolver) dialTLS(ctx context.Context, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := r.dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &tls.Config{}
+	if r.tlsc != nil {
+		c = r.tlsc.Clone()
+	}
+	if c.ServerName == "" {
+		c.ServerName = host
+	}
+
+	tc := tls.Client(conn, c)
+	if deadline, ok := ctx.Deadline(); ok {
+		tc.SetDeadline(deadline)
+	}
+
+	if err = tc.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	tc.SetDeadline(time.Time{})
+	return tc, nil
 }
 
 func (r *resolver) address() string {
 	return r.addrs[atomic.AddUint64(&r.idx, 1)%uint64(len(r.addrs))]
 }
+
+// dohConn is a connection to a DNS over HTTPS server (RFC 8484). Each query
+// written to it, framed as over TCP, is POSTed to the URL of the server, and
+// its answer read back framed the same way.
+type dohConn struct {
+	ctx      context.Context
+	client   *http.Client
+	url      string
+	deadline time.Time
+	wbuf     bytes.Buffer
+	rbuf     bytes.Buffer
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	c.wbuf.Write(b)
+	for c.wbuf.Len() >= 2 {
+		n := int(binary.BigEndian.Uint16(c.wbuf.Bytes()))
+		if c.wbuf.Len() < 2+n {
+			break
+		}
+
+		if err := c.exchange(c.wbuf.Next(2 + n)[2:]); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// exchange POSTs the given query to the server and buffers its answer.
+func (c *dohConn) exchange(query []byte) error {
+	ctx := c.ctx
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(query))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("DNS over HTTPS server %s responded with %s", c.url, resp.Status)
+	}
+
+	answer, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return err
+	} else if len(answer) >= 1<<16 {
+		return fmt.Errorf("DNS over HTTPS server %s answered with a too large message", c.url)
+	}
+
+	var size [2]byte
+	binary.BigEndian.PutUint16(size[:], uint16(len(answer)))
+	c.rbuf.Write(size[:])
+	c.rbuf.Write(answer)
+	return nil
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	if c.rbuf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.rbuf.Read(b)
+}
+
+func (c *dohConn) Close() error                       { return nil }
+func (c *dohConn) LocalAddr() net.Addr                { return dohAddr("") }
+func (c *dohConn) RemoteAddr() net.Addr               { return dohAddr(c.url) }
+func (c *dohConn) SetDeadline(t time.Time) error      { c.deadline = t; return nil }
+func (c *dohConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(t time.Time) error { c.deadline = t; return nil }
+
+// dohAddr is the net.Addr of a DNS over HTTPS server, its URL.
+type dohAddr string
+
+func (a dohAddr) Network() string { return "https" }
+func (a dohAddr) String() string  { return string(a) }
@@ -1,6 +1,8 @@
 package resolver
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -138,6 +140,21 @@ func TestNormalizeAddrs(t *testing.T) {
 			in:   []string{"127.0.0.500:53"},
 			err:  errors.New(`host 127.0.0.500 is not an IP address`),
 		},
+		{
+			name: "DNS over TLS",
+			in:   []string{"tls://dns.google", "tls://1.1.1.1:8853"},
+			out:  []string{"tls://dns.google:853", "tls://1.1.1.1:8853"},
+		},
+		{
+			name: "DNS over HTTPS",
+			in:   []string{"https://dns.google/dns-query"},
+			out:  []string{"https://dns.google/dns-query"},
+		},
+		{
+			name: "DNS over HTTPS without host",
+			in:   []string{"https:///dns-query"},
+			err:  errors.New("DNS over HTTPS URL https:///dns-query has no host"),
+		},
 		{
 			name: "normalized",
 			in:   []string{"127.0.0.1", "8.8.8.8:9000", "1.1.1.1"},
@@ -160,3 +177,98 @@ func TestNormalizeAddrs(t *testing.T) {
 	}
 
 }
+
+// answer answers the given query for fakeDomain with 127.0.0.1.
+func answer(r *dns.Msg) *dns.Msg {
+	m := &dns.Msg{}
+	m.SetReply(r)
+	if len(r.Question) == 0 || r.Question[0].Name != fakeDomain+"." {
+		m.SetRcode(r, dns.RcodeNameError)
+	} else if q := r.Question[0]; q.Qtype == dns.TypeA {
+		m.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 1},
+			A:   net.ParseIP("127.0.0.1"),
+		}}
+	}
+	return m
+}
+
+func TestResolverOverTLS(t *testing.T) {
+	t.Parallel()
+
+	// The certificate of the TLS test server is reused by the DNS server.
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	done := make(chan struct{})
+	ds := dns.Server{
+		Addr:              "127.0.0.1:0",
+		Net:               "tcp-tls",
+		TLSConfig:         &tls.Config{Certificates: ts.TLS.Certificates},
+		NotifyStartedFunc: func() { close(done) },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			w.WriteMsg(answer(r))
+		}),
+	}
+
+	go ds.ListenAndServe()
+	defer ds.Shutdown()
+	<-done
+
+	tlsc := ts.Client().Transport.(*http.Transport).TLSClientConfig
+	res, err := newResolver([]string{"tls://" + ds.Listener.Addr().String()}, tlsc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if addrs, err := res.LookupHost(ctx, fakeDomain); err != nil {
+		t.Fatal(err)
+	} else if want := []string{"127.0.0.1"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("got addresses %v, want %v", addrs, want)
+	}
+}
+
+func TestResolverOverHTTPS(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil || r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+
+		var q dns.Msg
+		if err = q.Unpack(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		msg, _ := answer(&q).Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(msg)
+	}))
+	defer ts.Close()
+
+	tlsc := ts.Client().Transport.(*http.Transport).TLSClientConfig
+	res, err := newResolver([]string{ts.URL + "/dns-query"}, tlsc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if addrs, err := res.LookupHost(ctx, fakeDomain); err != nil {
+		t.Fatal(err)
+	} else if want := []string{"127.0.0.1"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("got addresses %v, want %v", addrs, want)
+	}
+
+	if _, err := res.LookupHost(ctx, "unknown."+fakeDomain); err == nil {
+		t.Error("got no error looking up an unknown host")
+	}
+}
@@ -0,0 +1,223 @@
+// Package script implements hooks which delegate to an external command run
+// as a long-lived coprocess, so that attacks can be scripted in any language,
+// e.g. Lua or JavaScript with their standalone interpreters, without embedding
+// one. The command isn't sandboxed: it runs with the privileges of the attack.
+//
+// The process is sent a call per line on its standard input, as a JSON object
+// with an id, the name of the hook and its JSON value, e.g.
+//
+//	{"id":1,"hook":"beforeRequest","value":{"method":"GET","url":"http://a"}}
+//
+// and must reply to each with a line on its standard output, as a JSON object
+// with the id of the call and either the resulting value or an error, e.g.
+//
+//	{"id":1,"value":{"method":"GET","url":"http://b"}}
+//	{"id":1,"error":"no session left"}
+//
+// Calls are pipelined: replies may be written in any order. Whatever the
+// process writes on its standard error is passed through. A process which
+// doesn't reply to a call within its timeout is killed, failing all calls.
+package script
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Process is a running script.
+type Process struct {
+	cmd     *exec.Cmd
+	timeout time.Duration
+
+	wmu   sync.Mutex
+	stdin io.WriteCloser
+	enc   *json.Encoder
+
+	mu      sync.Mutex
+	id      uint64
+	pending map[uint64]chan reply
+	err     error // set once the process exits or is killed
+	done    chan struct{}
+}
+
+type call struct {
+	ID    uint64          `json:"id"`
+	Hook  string          `json:"hook"`
+	Value json.RawMessage `json:"value"`
+}
+
+type reply struct {
+	ID    uint64          `json:"id"`
+	Value json.RawMessage `json:"value"`
+	Error string          `json:"error"`
+}
+
+// Start starts a Process with the given command line, split on spaces, which
+// is killed when it doesn't reply to a call within the given timeout, unless
+// zero.
+func Start(cmdline string, timeout time.Duration) (*Process, error) {
+	fields := strings.Fields(cmdline)
+	if len(fields) == 0 {
+		return nil, errors.New("script: empty command")
+	}
+
+	cmd := exec.Command(fields[0], fields[1:]...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("script: %v", err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("script: %v", err)
+	}
+
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("script: %v", err)
+	}
+
+	p := &Process{
+		cmd:     cmd,
+		timeout: timeout,
+		stdin:   stdin,
+		enc:     json.NewEncoder(stdin),
+		pending: map[uint64]chan reply{},
+		done:    make(chan struct{}),
+	}
+
+	go p.read(stdout)
+	return p, nil
+}
+
+// read dispatches the replies of the process to their calls until it exits,
+// which fails the calls left.
+func (p *Process) read(stdout io.Reader) {
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	var err error
+	for sc.Scan() {
+		var r reply
+		if err = json.Unmarshal(sc.Bytes(), &r); err != nil {
+			err = fmt.Errorf("script: bad reply %q: %v", sc.Bytes(), err)
+			break
+		}
+
+		p.mu.Lock()
+		ch, ok := p.pending[r.ID]
+		delete(p.pending, r.ID)
+		p.mu.Unlock()
+
+		if ok {
+			ch <- r
+		}
+	}
+
+	if err == nil {
+		if err = sc.Err(); err == nil {
+			err = errors.New("script: process exited")
+		}
+	}
+
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	for id, ch := range p.pending {
+		delete(p.pending, id)
+		close(ch)
+	}
+	p.mu.Unlock()
+
+	close(p.done)
+}
+
+// Call calls the given hook of the script with the given value and returns
+// the value it replies with, or the error it replies with or it exited with.
+func (p *Process) Call(hook string, value json.RawMessage) (json.RawMessage, error) {
+	ch := make(chan reply, 1)
+
+	p.mu.Lock()
+	if p.err != nil {
+		err := p.err
+		p.mu.Unlock()
+		return nil, err
+	}
+	p.id++
+	id := p.id
+	p.pending[id] = ch
+	p.mu.Unlock()
+
+	// A process which hangs would block every call, and its writes.
+	if p.timeout > 0 {
+		t := time.AfterFunc(p.timeout, func() {
+			p.kill(fmt.Errorf("script: %s call timed out after %s", hook, p.timeout))
+		})
+		defer t.Stop()
+	}
+
+	p.wmu.Lock()
+	err := p.enc.Encode(call{ID: id, Hook: hook, Value: value})
+	p.wmu.Unlock()
+
+	if err != nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.pending, id)
+		if p.err != nil {
+			return nil, p.err
+		}
+		return nil, fmt.Errorf("script: %v", err)
+	}
+
+	r, ok := <-ch
+	if !ok {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return nil, p.err
+	} else if r.Error != "" {
+		return nil, errors.New(r.Error)
+	}
+
+	return r.Value, nil
+}
+
+// kill kills the process, failing all calls with the given error.
+func (p *Process) kill(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+
+	p.cmd.Process.Kill()
+}
+
+// Close closes the standard input of the script, which should make it exit,
+// and waits for it to, killing it if it doesn't within the timeout.
+func (p *Process) Close() error {
+	p.wmu.Lock()
+	p.stdin.Close()
+	p.wmu.Unlock()
+
+	if p.timeout > 0 {
+		select {
+		case <-p.done:
+		case <-time.After(p.timeout):
+			p.kill(errors.New("script: process didn't exit"))
+		}
+	}
+
+	<-p.done
+	return p.cmd.Wait()
+}
@@ -0,0 +1,147 @@
+package script
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestHelperProcess is the script run by the tests, which replies to calls
+// of the echo hook with their values, out of order, fails those of the fail
+// hook, exits on those of the exit hook, hangs on those of the hang hook and
+// hangs after replying to those of the detach hook, ignoring its input.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("SCRIPT_TEST_HELPER") == "" {
+		return
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	sc := bufio.NewScanner(os.Stdin)
+
+	var held *call
+	for sc.Scan() {
+		var c call
+		if err := json.Unmarshal(sc.Bytes(), &c); err != nil {
+			fmt.Fprint(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		switch c.Hook {
+		case "echo":
+			// Hold every other call back until the next one is replied to.
+			if held == nil {
+				held = &c
+				continue
+			}
+			enc.Encode(reply{ID: c.ID, Value: c.Value})
+			enc.Encode(reply{ID: held.ID, Value: held.Value})
+			held = nil
+		case "fail":
+			enc.Encode(reply{ID: c.ID, Error: "failed " + string(c.Value)})
+		case "exit":
+			os.Exit(0)
+		case "hang":
+			time.Sleep(time.Hour)
+		case "detach":
+			enc.Encode(reply{ID: c.ID, Value: c.Value})
+			time.Sleep(time.Hour)
+		}
+	}
+	os.Exit(0)
+}
+
+func start(t *testing.T, timeout time.Duration) *Process {
+	os.Setenv("SCRIPT_TEST_HELPER", "1")
+	defer os.Unsetenv("SCRIPT_TEST_HELPER")
+
+	p, err := Start(os.Args[0]+" -test.run=TestHelperProcess", timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestProcess(t *testing.T) {
+	p := start(t, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			want := fmt.Sprintf(`{"n":%d}`, i)
+			got, err := p.Call("echo", json.RawMessage(want))
+			if err != nil {
+				t.Error(err)
+			} else if string(got) != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if _, err := p.Call("fail", json.RawMessage(`1`)); err == nil || err.Error() != "failed 1" {
+		t.Errorf("got error %v, want failed 1", err)
+	}
+
+	if _, err := p.Call("exit", nil); err == nil {
+		t.Error("got no error from an exited script")
+	}
+
+	if _, err := p.Call("echo", json.RawMessage(`1`)); err == nil {
+		t.Error("got no error from an exited script")
+	}
+
+	if err := p.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := Start(" ", 0); err == nil {
+		t.Error("got no error with an empty command")
+	}
+}
+
+func TestProcessTimeout(t *testing.T) {
+	p := start(t, 100*time.Millisecond)
+
+	// The hung process is killed, failing the calls after it too.
+	began := time.Now()
+	want := "script: hang call timed out after 100ms"
+	if _, err := p.Call("hang", nil); err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+
+	if _, err := p.Call("fail", json.RawMessage(`1`)); err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+
+	if err := p.Close(); err == nil {
+		t.Error("got no error closing a killed script")
+	}
+
+	if elapsed := time.Since(began); elapsed > 5*time.Second {
+		t.Errorf("took %s to time out", elapsed)
+	}
+}
+
+func TestProcessCloseTimeout(t *testing.T) {
+	p := start(t, 100*time.Millisecond)
+
+	// The process hangs after replying, so closing its input doesn't make it exit.
+	if _, err := p.Call("detach", json.RawMessage(`1`)); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- p.Close() }()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close didn't kill the hung script")
+	}
+}
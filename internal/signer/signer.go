@@ -0,0 +1,87 @@
+// Package signer implements a crypto.Signer which delegates signing to an
+// external command, so that TLS client keys held in hardware security modules,
+// e.g. through PKCS#11 tools, or key management services can be used without
+// being exported.
+//
+// The command is run once per signature, with the digest to sign on its
+// standard input, and must write the signature on its standard output: an
+// ASN.1 DER encoded one for ECDSA keys, and a raw one for RSA and Ed25519
+// keys. The environment variable VEGETA_SIGN_HASH holds the name of the hash
+// function of the digest, e.g. SHA-256, or is empty for Ed25519 keys, which
+// sign whole messages, and VEGETA_SIGN_PADDING holds the padding of RSA
+// signatures, PSS or PKCS1v15.
+package signer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// hashNames are the names of the hash functions of digests TLS signs.
+var hashNames = map[crypto.Hash]string{
+	crypto.MD5SHA1: "MD5+SHA1",
+	crypto.SHA1:    "SHA-1",
+	crypto.SHA256:  "SHA-256",
+	crypto.SHA384:  "SHA-384",
+	crypto.SHA512:  "SHA-512",
+}
+
+// Command is a crypto.Signer running an external command to sign digests.
+type Command struct {
+	pub  crypto.PublicKey
+	name string
+	args []string
+}
+
+// NewCommand returns a Command signing for the given public key with the
+// given command line, split on spaces.
+func NewCommand(pub crypto.PublicKey, cmdline string) (*Command, error) {
+	fields := strings.Fields(cmdline)
+	if len(fields) == 0 {
+		return nil, errors.New("signer: empty command")
+	}
+	return &Command{pub: pub, name: fields[0], args: fields[1:]}, nil
+}
+
+// Public returns the public key of the Command.
+func (c *Command) Public() crypto.PublicKey {
+	return c.pub
+}
+
+// Sign signs the given digest by running the command.
+func (c *Command) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var padding string
+	hash := hashNames[opts.HashFunc()]
+
+	if _, ok := c.pub.(*rsa.PublicKey); ok {
+		if _, pss := opts.(*rsa.PSSOptions); pss {
+			padding = "PSS"
+		} else {
+			padding = "PKCS1v15"
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(c.name, c.args...)
+	cmd.Env = append(os.Environ(), "VEGETA_SIGN_HASH="+hash, "VEGETA_SIGN_PADDING="+padding)
+	cmd.Stdin = bytes.NewReader(digest)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("signer: %v: %s", err, msg)
+		}
+		return nil, fmt.Errorf("signer: %v", err)
+	} else if stdout.Len() == 0 {
+		return nil, errors.New("signer: empty signature")
+	}
+
+	return stdout.Bytes(), nil
+}
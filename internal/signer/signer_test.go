@@ -0,0 +1,144 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestHelperProcess is the signing command run by the tests, which signs its
+// input with the PKCS#8 key in SIGNER_TEST_KEY.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("SIGNER_TEST_KEY") == "" {
+		return
+	}
+
+	der, _ := base64.StdEncoding.DecodeString(os.Getenv("SIGNER_TEST_KEY"))
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		fmt.Fprint(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if os.Getenv("VEGETA_SIGN_HASH") != "SHA-256" {
+		fmt.Fprint(os.Stderr, "unexpected hash ", os.Getenv("VEGETA_SIGN_HASH"))
+		os.Exit(1)
+	}
+
+	digest, _ := ioutil.ReadAll(os.Stdin)
+
+	var opts crypto.SignerOpts = crypto.SHA256
+	if os.Getenv("VEGETA_SIGN_PADDING") == "PSS" {
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+	}
+
+	sig, err := key.(crypto.Signer).Sign(rand.Reader, digest, opts)
+	if err != nil {
+		fmt.Fprint(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	os.Stdout.Write(sig)
+	os.Exit(0)
+}
+
+func TestCommand(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256([]byte("vegeta"))
+	pss := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+
+	for _, tc := range []struct {
+		name   string
+		key    crypto.Signer
+		opts   crypto.SignerOpts
+		verify func(sig []byte) bool
+	}{
+		{
+			name: "ecdsa",
+			key:  ecKey,
+			opts: crypto.SHA256,
+			verify: func(sig []byte) bool {
+				var rs struct{ R, S *big.Int }
+				if _, err := asn1.Unmarshal(sig, &rs); err != nil {
+					return false
+				}
+				return ecdsa.Verify(&ecKey.PublicKey, digest[:], rs.R, rs.S)
+			},
+		},
+		{
+			name: "rsa-pss",
+			key:  rsaKey,
+			opts: pss,
+			verify: func(sig []byte) bool {
+				return rsa.VerifyPSS(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig, pss) == nil
+			},
+		},
+		{
+			name: "rsa-pkcs1v15",
+			key:  rsaKey,
+			opts: crypto.SHA256,
+			verify: func(sig []byte) bool {
+				return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig) == nil
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			der, err := x509.MarshalPKCS8PrivateKey(tc.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			os.Setenv("SIGNER_TEST_KEY", base64.StdEncoding.EncodeToString(der))
+			defer os.Unsetenv("SIGNER_TEST_KEY")
+
+			c, err := NewCommand(tc.key.Public(), os.Args[0]+" -test.run=TestHelperProcess")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sig, err := c.Sign(rand.Reader, digest[:], tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			} else if !tc.verify(sig) {
+				t.Error("got an invalid signature")
+			}
+		})
+	}
+
+	c, err := NewCommand(ecKey.Public(), os.Args[0]+" -test.run=TestHelperProcess")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("SIGNER_TEST_KEY", "bm90IGEga2V5")
+	defer os.Unsetenv("SIGNER_TEST_KEY")
+
+	if _, err = c.Sign(rand.Reader, digest[:], crypto.SHA256); err == nil || !strings.Contains(err.Error(), "signer:") {
+		t.Errorf("got error %v, want one of the failed command", err)
+	}
+
+	if _, err = NewCommand(ecKey.Public(), " "); err == nil {
+		t.Error("got no error with an empty command")
+	}
+}
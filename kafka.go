@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// kafkaSink publishes each Result as a message to the Kafka topic given by
+// the URL path on the comma separated list of brokers given by the URL host.
+// Messages are keyed by attack name and their value is the Result encoded with
+// the encoding given by the encoding query parameter (json, csv or gob),
+// which defaults to json.
+// e.g. kafka://broker1:9092,broker2:9092/vegeta?encoding=json
+func kafkaSink(u *url.URL, _ http.Header) (vegeta.Encoder, io.Closer, error) {
+	topic := strings.Trim(u.Path, "/")
+	if topic == "" {
+		return nil, nil, fmt.Errorf("kafka: missing topic in %s", u)
+	}
+
+	encoding := u.Query().Get("encoding")
+	if encoding == "" {
+		encoding = encodingJSON
+	}
+
+	var newEncoder func(io.Writer) vegeta.Encoder
+	switch encoding {
+	case encodingJSON:
+		newEncoder = vegeta.NewJSONEncoder
+	case encodingCSV:
+		newEncoder = vegeta.NewCSVEncoder
+	case encodingGob:
+		newEncoder = vegeta.NewEncoder
+	default:
+		return nil, nil, fmt.Errorf("kafka: unknown encoding %q", encoding)
+	}
+
+	s := &kafkaWriter{}
+	s.w = &kafka.Writer{
+		Addr:         kafka.TCP(strings.Split(u.Host, ",")...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: 100 * time.Millisecond,
+		Async:        true,
+		Completion:   s.completion,
+	}
+
+	return func(r *vegeta.Result) error {
+		if err := s.error(); err != nil {
+			return err
+		}
+
+		// A new Encoder per message guarantees that every message can be
+		// decoded on its own, which isn't the case of a shared gob Encoder.
+		var buf bytes.Buffer
+		if err := newEncoder(&buf).Encode(r); err != nil {
+			return err
+		}
+
+		return s.w.WriteMessages(context.Background(), kafka.Message{
+			Key:   []byte(r.Attack),
+			Value: buf.Bytes(),
+			Time:  r.Timestamp,
+		})
+	}, s, nil
+}
+
+// kafkaWriter wraps an asynchronous kafka.Writer to keep the first
+// error reported by its Completion callback.
+type kafkaWriter struct {
+	w   *kafka.Writer
+	mu  sync.Mutex
+	err error
+}
+
+func (s *kafkaWriter) completion(_ []kafka.Message, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil && err != nil {
+		s.err = fmt.Errorf("kafka: %v", err)
+	}
+}
+
+func (s *kafkaWriter) error() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close flushes all pending messages and closes the underlying writer.
+func (s *kafkaWriter) Close() error {
+	if err := s.w.Close(); err != nil {
+		return err
+	}
+	return s.error()
+}
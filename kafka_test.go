@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol/metadata"
+	"github.com/segmentio/kafka-go/protocol/produce"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// fakeKafka is a kafka.RoundTripper of a fake single broker cluster with
+// the given number of partitions of every topic, which keeps the messages
+// produced to each of them.
+type fakeKafka struct {
+	partitions int
+
+	mu       sync.Mutex
+	messages map[int][]kafka.Message
+}
+
+func (k *fakeKafka) RoundTrip(_ context.Context, _ net.Addr, req kafka.Request) (kafka.Response, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	switch req := req.(type) {
+	case *metadata.Request:
+		res := &metadata.Response{Brokers: []metadata.ResponseBroker{{NodeID: 1, Host: "localhost", Port: 9092}}, ControllerID: 1}
+		for _, topic := range req.TopicNames {
+			t := metadata.ResponseTopic{Name: topic}
+			for i := 0; i < k.partitions; i++ {
+				t.Partitions = append(t.Partitions, metadata.ResponsePartition{PartitionIndex: int32(i), LeaderID: 1})
+			}
+			res.Topics = append(res.Topics, t)
+		}
+		return res, nil
+	case *produce.Request:
+		res := &produce.Response{}
+		for _, t := range req.Topics {
+			rt := produce.ResponseTopic{Topic: t.Topic}
+			for _, p := range t.Partitions {
+				for {
+					r, err := p.RecordSet.Records.ReadRecord()
+					if err == io.EOF {
+						break
+					} else if err != nil {
+						return nil, err
+					}
+
+					key, _ := ioutil.ReadAll(r.Key)
+					value, _ := ioutil.ReadAll(r.Value)
+					k.messages[int(p.Partition)] = append(k.messages[int(p.Partition)], kafka.Message{
+						Topic:     t.Topic,
+						Partition: int(p.Partition),
+						Key:       key,
+						Value:     value,
+						Time:      r.Time,
+					})
+				}
+				rt.Partitions = append(rt.Partitions, produce.ResponsePartition{Partition: p.Partition})
+			}
+			res.Topics = append(res.Topics, rt)
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unexpected %T request", req)
+	}
+}
+
+func TestKafkaSink(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		encoding string
+		query    string
+		decoder  func(io.Reader) vegeta.Decoder
+	}{
+		{encodingJSON, "", vegeta.NewJSONDecoder},
+		{encodingCSV, "?encoding=csv", vegeta.NewCSVDecoder},
+		{encodingGob, "?encoding=gob", vegeta.NewDecoder},
+	} {
+		tc := tc
+		t.Run(tc.encoding, func(t *testing.T) {
+			t.Parallel()
+
+			enc, closer, err := output("kafka://broker1:9092,broker2:9092/vegeta"+tc.query, "", 0, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			k := &fakeKafka{partitions: 8, messages: map[int][]kafka.Message{}}
+			w := closer.(*kafkaWriter)
+			w.w.Transport = k
+
+			if got, want := w.w.Addr.String(), "broker1:9092,broker2:9092"; got != want {
+				t.Errorf("got brokers %q, want %q", got, want)
+			}
+
+			// Metadata records aren't published.
+			ts := time.Date(2020, 3, 14, 15, 9, 26, 0, time.UTC)
+			if err = enc.Encode(&vegeta.Result{Timestamp: ts, Metadata: &vegeta.Metadata{Began: ts}}); err != nil {
+				t.Fatal(err)
+			}
+
+			want := map[string][]vegeta.Result{}
+			for i := 0; i < 30; i++ {
+				r := vegeta.Result{
+					Attack:    []string{"checkout", "search", "login"}[i%3],
+					Seq:       uint64(i),
+					Code:      200,
+					Method:    "GET",
+					URL:       "http://localhost/",
+					Timestamp: ts.Add(time.Duration(i) * time.Millisecond),
+					Latency:   time.Millisecond,
+				}
+
+				if err = enc.Encode(&r); err != nil {
+					t.Fatal(err)
+				}
+				want[r.Attack] = append(want[r.Attack], r)
+			}
+
+			if err = closer.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			k.mu.Lock()
+			defer k.mu.Unlock()
+
+			var balancer kafka.Hash
+			partitions := []int{0, 1, 2, 3, 4, 5, 6, 7}
+
+			got := map[string][]vegeta.Result{}
+			for partition, msgs := range k.messages {
+				for _, msg := range msgs {
+					attack := string(msg.Key)
+
+					// Messages are partitioned by attack name.
+					if p := balancer.Balance(kafka.Message{Key: msg.Key}, partitions...); p != partition {
+						t.Errorf("got message of attack %q in partition %d, want %d", attack, partition, p)
+					}
+
+					if msg.Topic != "vegeta" {
+						t.Errorf("got message in topic %q", msg.Topic)
+					}
+
+					// Every message is decoded on its own.
+					var r vegeta.Result
+					if err := tc.decoder(bytes.NewReader(msg.Value)).Decode(&r); err != nil {
+						t.Fatalf("bad message %q: %v", msg.Value, err)
+					}
+
+					if !msg.Time.Equal(r.Timestamp.Truncate(time.Millisecond)) {
+						t.Errorf("got message time %s, want %s", msg.Time, r.Timestamp)
+					}
+					got[attack] = append(got[attack], r)
+				}
+			}
+
+			for attack, rs := range want {
+				if len(got[attack]) != len(rs) {
+					t.Errorf("got %d messages of attack %q, want %d", len(got[attack]), attack, len(rs))
+					continue
+				}
+
+				// Messages of an attack are in order in their partition.
+				for i := range rs {
+					if r := got[attack][i]; !r.Equal(rs[i]) {
+						t.Errorf("got message %+v, want %+v", r, rs[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestKafkaSinkErrors(t *testing.T) {
+	t.Parallel()
+
+	for out, want := range map[string]string{
+		"kafka://broker:9092":                        "kafka: missing topic",
+		"kafka://broker:9092/vegeta?encoding=influx": `kafka: unknown encoding "influx"`,
+	} {
+		if _, _, err := output(out, "", 0, nil); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got error %v, want %q", out, err, want)
+		}
+	}
+}
@@ -0,0 +1,580 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// kubePlan is the plan file of an attack distributed across Kubernetes Jobs.
+type kubePlan struct {
+	// API is the URL of the Kubernetes API server. It defaults to the one of
+	// the cluster vegeta runs in, or else to a `kubectl proxy` on localhost.
+	API string `json:"api"`
+	// Namespace is the namespace of the Jobs.
+	Namespace string `json:"namespace"`
+	// Image is the container image of the Jobs, which must have vegeta in
+	// its PATH.
+	Image string `json:"image"`
+	// Jobs is the number of Jobs the attack's rate is divided across.
+	Jobs int `json:"jobs"`
+	// Results is the s3:// or gs:// URL of the prefix of the objects the Jobs
+	// write their results to. Their results are streamed from their logs
+	// otherwise.
+	Results string `json:"results"`
+	// ServiceAccount is the service account of the Jobs' pods.
+	ServiceAccount string `json:"service_account"`
+	// Env are environment variables of the Jobs' containers.
+	Env map[string]string `json:"env"`
+	// Resources are the resource requirements of the Jobs' containers.
+	Resources json.RawMessage `json:"resources"`
+	// Keep keeps the Jobs and their ConfigMap once the attack ends.
+	Keep bool `json:"keep"`
+}
+
+// readKubePlan reads and validates the given plan file.
+func readKubePlan(name string) (*kubePlan, error) {
+	bs, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := kubePlan{Namespace: "default"}
+	if err = json.Unmarshal(bs, &plan); err != nil {
+		return nil, fmt.Errorf("bad kubernetes plan %s: %v", name, err)
+	}
+
+	switch {
+	case plan.Image == "":
+		return nil, fmt.Errorf("bad kubernetes plan %s: missing image", name)
+	case plan.Jobs <= 0:
+		return nil, fmt.Errorf("bad kubernetes plan %s: jobs must be bigger than zero", name)
+	}
+
+	if plan.Results != "" {
+		if _, _, _, ok := objectURL(plan.Results); !ok {
+			return nil, fmt.Errorf("bad kubernetes plan %s: results must be an s3:// or gs:// URL", name)
+		}
+	}
+
+	return &plan, nil
+}
+
+// kubePollInterval is the interval at which the pods and Jobs of an attack
+// are polled for their status.
+const kubePollInterval = time.Second
+
+// kubePendingTimeout is how long after the start of an attack the pods of
+// its Jobs may still be Pending, e.g. unschedulable, before it fails.
+const kubePendingTimeout = time.Minute
+
+// kubeWaitingErrors are the reasons containers wait for, which they won't
+// start after, unless their pod or cluster are changed.
+var kubeWaitingErrors = map[string]bool{
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"ErrImageNeverPull":          true,
+	"ErrImagePull":               true,
+	"ImageInspectError":          true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+}
+
+// kubeMountPath is where the ConfigMap of the targets and body of an attack
+// is mounted in the containers of its Jobs.
+const kubeMountPath = "/etc/vegeta"
+
+// kubeName matches the characters which aren't allowed in Kubernetes names.
+var kubeName = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// kubeStartDelay is the delay after which attacks distributed across
+// Kubernetes Jobs start on all of them at once, which leaves time to schedule
+// their pods and pull their image.
+const kubeStartDelay = time.Minute
+
+// kubernetes starts the attack described by the given options on the Jobs of
+// its -kubernetes plan at the given time, with the given targets and body,
+// and returns it. The
+// results of each Job are labeled with its name as their node. The Jobs and
+// their ConfigMap are deleted once the attack ends, unless the plan keeps them.
+func kubernetes(opts *attackOpts, start time.Time, targets, body []byte) (*distributedAttack, error) {
+	plan, err := readKubePlan(opts.kubernetes)
+	if err != nil {
+		return nil, err
+	}
+
+	kc, err := newKubeClient(plan.API)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.Trim(kubeName.ReplaceAllString(strings.ToLower(opts.name), "-"), "-")
+	if name == "" {
+		name = "attack"
+	} else if len(name) > 32 {
+		name = strings.TrimRight(name[:32], "-")
+	}
+	name = "vegeta-" + name + "-" + strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 36)
+
+	if body == nil {
+		body = []byte{}
+	}
+
+	ns := "/namespaces/" + url.PathEscape(plan.Namespace)
+	ctx := context.Background()
+
+	cm := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": name},
+		"binaryData": map[string][]byte{"targets": targets, "body": body},
+	}
+
+	if err = kc.do(ctx, "POST", "/api/v1"+ns+"/configmaps", cm, nil); err != nil {
+		return nil, fmt.Errorf("error creating configmap %s: %v", name, err)
+	}
+
+	cleanup := func() {
+		if plan.Keep {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		paths := []string{"/api/v1" + ns + "/configmaps/" + name}
+		for i := 0; i < plan.Jobs; i++ {
+			paths = append(paths, "/apis/batch/v1"+ns+"/jobs/"+name+"-"+strconv.Itoa(i))
+		}
+
+		// Jobs without a share of the rate were never created.
+		del := map[string]string{"propagationPolicy": "Background"}
+		for _, path := range paths {
+			err := kc.do(ctx, "DELETE", path, del, nil)
+			if se, ok := err.(*kubeStatusError); err != nil && !(ok && se.code == http.StatusNotFound) {
+				log.Printf("error deleting %s: %v", path, err)
+			}
+		}
+	}
+
+	return runDistributed(plan.Jobs, opts.rate, func(ctx context.Context, d *distributedAttack, i int, rate vegeta.Rate) error {
+		job := &kubeJob{
+			client: kc,
+			plan:   plan,
+			ns:     ns,
+			name:   name + "-" + strconv.Itoa(i),
+			config: name,
+			args:   append(append([]string{}, opts.workerArgs...), "-rate="+(&rateFlag{&rate}).String()),
+			start:  start,
+		}
+
+		if err := job.run(ctx, d); err != nil {
+			return fmt.Errorf("job %s: %v", job.name, err)
+		}
+		return nil
+	}, cleanup), nil
+}
+
+// kubeJob is a Kubernetes Job running a share of a distributed attack.
+type kubeJob struct {
+	client *kubeClient
+	plan   *kubePlan
+	ns     string
+	name   string
+	config string
+	args   []string
+	start  time.Time
+}
+
+// run creates the Job and sends its results to the given attack once they're
+// streamed from its logs, or written to the plan's object store.
+func (j *kubeJob) run(ctx context.Context, d *distributedAttack) error {
+	job, output := j.manifest()
+	if err := j.client.do(ctx, "POST", "/apis/batch/v1"+j.ns+"/jobs", job, nil); err != nil {
+		return fmt.Errorf("error creating job: %v", err)
+	}
+
+	pod, err := j.pod(ctx)
+	if err != nil {
+		return err
+	}
+
+	if output == "" && pod != "" {
+		if err := j.streamLogs(ctx, pod, d); err != nil {
+			return err
+		}
+	}
+
+	if err := j.wait(ctx); err != nil || output == "" {
+		return err
+	}
+
+	f, err := open(output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := vegeta.NewMetadataDecoder(vegeta.NewDecoder(f), nil)
+	for {
+		r := &vegeta.Result{}
+		if err = dec.Decode(r); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		} else if err = d.send(ctx, j.name, r); err != nil {
+			return nil
+		}
+	}
+}
+
+// manifest returns the manifest of the Job and the URL of the object its
+// results are written to, if any.
+func (j *kubeJob) manifest() (map[string]interface{}, string) {
+	args := append([]string{"attack"}, j.args...)
+	args = append(args, "-targets="+kubeMountPath+"/targets", "-body="+kubeMountPath+"/body")
+	args = append(args, "-start-at="+j.start.Format(time.RFC3339Nano))
+
+	var output string
+	if j.plan.Results != "" {
+		output = strings.TrimSuffix(j.plan.Results, "/") + "/" + j.name + ".bin"
+		args = append(args, "-output="+output)
+	} else {
+		args = append(args, "-encoding=json")
+	}
+
+	env := []map[string]string{}
+	for k, v := range j.plan.Env {
+		env = append(env, map[string]string{"name": k, "value": v})
+	}
+
+	container := map[string]interface{}{
+		"name":         "vegeta",
+		"image":        j.plan.Image,
+		"command":      []string{"vegeta"},
+		"args":         args,
+		"env":          env,
+		"volumeMounts": []interface{}{map[string]string{"name": "plan", "mountPath": kubeMountPath}},
+	}
+
+	if len(j.plan.Resources) > 0 {
+		container["resources"] = j.plan.Resources
+	}
+
+	pod := map[string]interface{}{
+		"restartPolicy": "Never",
+		"containers":    []interface{}{container},
+		"volumes": []interface{}{map[string]interface{}{
+			"name":      "plan",
+			"configMap": map[string]string{"name": j.config},
+		}},
+	}
+
+	if j.plan.ServiceAccount != "" {
+		pod["serviceAccountName"] = j.plan.ServiceAccount
+	}
+
+	// Jobs aren't retried, which would attack at more than their rate.
+	job := map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata": map[string]interface{}{
+			"name":   j.name,
+			"labels": map[string]string{"app.kubernetes.io/name": "vegeta", "vegeta/attack": j.config},
+		},
+		"spec": map[string]interface{}{
+			"backoffLimit": 0,
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"labels": map[string]string{"app.kubernetes.io/name": "vegeta", "vegeta/attack": j.config},
+				},
+				"spec": pod,
+			},
+		},
+	}
+
+	return job, output
+}
+
+// streamLogs sends the JSON encoded results the given pod of the Job logs to
+// the given attack. Other log lines are written to stderr.
+func (j *kubeJob) streamLogs(ctx context.Context, pod string, d *distributedAttack) error {
+	logs, err := j.client.stream(ctx, "/api/v1"+j.ns+"/pods/"+pod+"/log?container=vegeta&follow=true")
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+
+	br := bufio.NewReader(logs)
+	for {
+		line, err := br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			r := &vegeta.Result{}
+			dec := vegeta.NewJSONDecoder(bytes.NewReader(append(line, '\n')))
+			if line[0] != '{' || dec.Decode(r) != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", j.name, line)
+			} else if r.Metadata == nil {
+				if err := d.send(ctx, j.name, r); err != nil {
+					return nil
+				}
+			}
+		}
+
+		if err == io.EOF || ctx.Err() != nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// pod waits for the pod of the Job to start and returns its name, or an empty
+// one if ctx is done first. It fails if a container of the pod waits for a
+// reason it won't start after, like an image which can't be pulled, or if
+// the pod is still Pending kubePendingTimeout after the attack's start.
+func (j *kubeJob) pod(ctx context.Context) (string, error) {
+	path := "/api/v1" + j.ns + "/pods?labelSelector=" + url.QueryEscape("job-name="+j.name)
+	deadline := j.start.Add(kubePendingTimeout)
+	for {
+		var pods struct {
+			Items []struct {
+				Metadata struct {
+					Name string `json:"name"`
+				} `json:"metadata"`
+				Status struct {
+					Phase      string `json:"phase"`
+					Conditions []struct {
+						Type    string `json:"type"`
+						Status  string `json:"status"`
+						Message string `json:"message"`
+					} `json:"conditions"`
+					ContainerStatuses []struct {
+						State struct {
+							Waiting *struct {
+								Reason  string `json:"reason"`
+								Message string `json:"message"`
+							} `json:"waiting"`
+						} `json:"state"`
+					} `json:"containerStatuses"`
+				} `json:"status"`
+			} `json:"items"`
+		}
+
+		if err := j.client.do(ctx, "GET", path, nil, &pods); err != nil {
+			return "", err
+		}
+
+		pending := "no pod created"
+		for _, p := range pods.Items {
+			if p.Status.Phase != "Pending" {
+				return p.Metadata.Name, nil
+			}
+
+			for _, c := range p.Status.ContainerStatuses {
+				if w := c.State.Waiting; w != nil && kubeWaitingErrors[w.Reason] {
+					return "", fmt.Errorf("pod %s: %s: %s", p.Metadata.Name, w.Reason, w.Message)
+				}
+			}
+
+			pending = "pod " + p.Metadata.Name
+			for _, c := range p.Status.Conditions {
+				if c.Type == "PodScheduled" && c.Status == "False" && c.Message != "" {
+					pending += ": " + c.Message
+				}
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("still pending %s after the start of the attack: %s", kubePendingTimeout, pending)
+		}
+
+		select {
+		case <-time.After(kubePollInterval):
+		case <-ctx.Done():
+			return "", nil
+		}
+	}
+}
+
+// wait waits for the Job to complete, returning an error if it failed.
+func (j *kubeJob) wait(ctx context.Context) error {
+	for {
+		var job struct {
+			Status struct {
+				Succeeded int `json:"succeeded"`
+				Failed    int `json:"failed"`
+			} `json:"status"`
+		}
+
+		if err := j.client.do(ctx, "GET", "/apis/batch/v1"+j.ns+"/jobs/"+j.name, nil, &job); err != nil {
+			return err
+		}
+
+		switch {
+		case job.Status.Succeeded > 0:
+			return nil
+		case job.Status.Failed > 0:
+			return errors.New("failed")
+		}
+
+		select {
+		case <-time.After(kubePollInterval):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// kubeClient is a minimal client of the Kubernetes API.
+type kubeClient struct {
+	api    string
+	token  string
+	client *http.Client
+}
+
+// kubeServiceAccount is the directory of the credentials of the service
+// account of pods.
+const kubeServiceAccount = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// newKubeClient returns a kubeClient of the given API server URL or, if
+// empty, of the cluster vegeta runs in, authorized with its pod's service
+// account, or else of a `kubectl proxy` on localhost.
+func newKubeClient(api string) (*kubeClient, error) {
+	kc := &kubeClient{api: strings.TrimSuffix(api, "/"), client: http.DefaultClient}
+
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	switch {
+	case api != "":
+		return kc, nil
+	case host == "" || port == "":
+		kc.api = "http://127.0.0.1:8001"
+		return kc, nil
+	}
+
+	token, err := ioutil.ReadFile(kubeServiceAccount + "/token")
+	if err != nil {
+		return nil, err
+	}
+
+	ca, err := ioutil.ReadFile(kubeServiceAccount + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca) {
+		return nil, errors.New("bad kubernetes service account CA certificate")
+	}
+
+	kc.api = "https://" + net.JoinHostPort(host, port)
+	kc.token = strings.TrimSpace(string(token))
+	kc.client = &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: roots},
+	}}
+
+	return kc, nil
+}
+
+// do sends a request with the given JSON body, if not nil, to the given API
+// path and decodes its JSON response into out, if not nil.
+func (c *kubeClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		bs, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(bs)
+	}
+
+	req, err := http.NewRequest(method, c.api+path, body)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// stream sends a GET request to the given API path and returns its response
+// body.
+func (c *kubeClient) stream(ctx context.Context, path string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", c.api+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// send sends the given request, returning a kubeStatusError with the message
+// of the API's Status if its response isn't successful.
+func (c *kubeClient) send(ctx context.Context, req *http.Request) (*http.Response, error) {
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+
+		var status struct {
+			Message string `json:"message"`
+		}
+
+		bs, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		if json.Unmarshal(bs, &status) != nil || status.Message == "" {
+			status.Message = string(bytes.TrimSpace(bs))
+		}
+
+		return nil, &kubeStatusError{code: res.StatusCode, status: res.Status, msg: status.Message}
+	}
+
+	return res, nil
+}
+
+// kubeStatusError is an unsuccessful response of the Kubernetes API.
+type kubeStatusError struct {
+	code   int
+	status string
+	msg    string
+}
+
+func (e *kubeStatusError) Error() string { return e.status + ": " + e.msg }
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestKubeJobManifest(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name   string
+		plan   kubePlan
+		args   []string
+		output string
+	}{
+		{
+			name: "streamed results",
+			plan: kubePlan{Image: "vegeta:12"},
+			args: []string{
+				"attack", "-duration=10s", "-rate=50/1s",
+				"-targets=/etc/vegeta/targets", "-body=/etc/vegeta/body",
+				"-start-at=2026-03-01T12:00:00Z", "-encoding=json",
+			},
+		},
+		{
+			name: "stored results",
+			plan: kubePlan{
+				Image:          "vegeta:12",
+				Results:        "s3://bucket/attacks/",
+				ServiceAccount: "vegeta",
+				Env:            map[string]string{"AWS_REGION": "eu-west-1"},
+				Resources:      json.RawMessage(`{"requests":{"cpu":"2"}}`),
+			},
+			args: []string{
+				"attack", "-duration=10s", "-rate=50/1s",
+				"-targets=/etc/vegeta/targets", "-body=/etc/vegeta/body",
+				"-start-at=2026-03-01T12:00:00Z", "-output=s3://bucket/attacks/vegeta-test-0.bin",
+			},
+			output: "s3://bucket/attacks/vegeta-test-0.bin",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			j := &kubeJob{
+				plan:   &tc.plan,
+				name:   "vegeta-test-0",
+				config: "vegeta-test",
+				args:   []string{"-duration=10s", "-rate=50/1s"},
+				start:  start,
+			}
+
+			manifest, output := j.manifest()
+			if output != tc.output {
+				t.Errorf("got output %q, want %q", output, tc.output)
+			}
+
+			// Round-trip the manifest through JSON, as sent to the API.
+			bs, err := json.Marshal(manifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var job struct {
+				Metadata struct {
+					Name   string            `json:"name"`
+					Labels map[string]string `json:"labels"`
+				} `json:"metadata"`
+				Spec struct {
+					BackoffLimit *int `json:"backoffLimit"`
+					Template     struct {
+						Spec struct {
+							RestartPolicy      string `json:"restartPolicy"`
+							ServiceAccountName string `json:"serviceAccountName"`
+							Containers         []struct {
+								Image     string              `json:"image"`
+								Command   []string            `json:"command"`
+								Args      []string            `json:"args"`
+								Env       []map[string]string `json:"env"`
+								Resources json.RawMessage     `json:"resources"`
+							} `json:"containers"`
+							Volumes []struct {
+								ConfigMap struct {
+									Name string `json:"name"`
+								} `json:"configMap"`
+							} `json:"volumes"`
+						} `json:"spec"`
+					} `json:"template"`
+				} `json:"spec"`
+			}
+
+			if err = json.Unmarshal(bs, &job); err != nil {
+				t.Fatal(err)
+			}
+
+			pod := job.Spec.Template.Spec
+			switch {
+			case job.Metadata.Name != "vegeta-test-0":
+				t.Errorf("got name %q", job.Metadata.Name)
+			case job.Metadata.Labels["vegeta/attack"] != "vegeta-test":
+				t.Errorf("got labels %v", job.Metadata.Labels)
+			case job.Spec.BackoffLimit == nil || *job.Spec.BackoffLimit != 0:
+				t.Errorf("got backoff limit %v, want 0", job.Spec.BackoffLimit)
+			case pod.RestartPolicy != "Never":
+				t.Errorf("got restart policy %q", pod.RestartPolicy)
+			case pod.ServiceAccountName != tc.plan.ServiceAccount:
+				t.Errorf("got service account %q, want %q", pod.ServiceAccountName, tc.plan.ServiceAccount)
+			case len(pod.Volumes) != 1 || pod.Volumes[0].ConfigMap.Name != "vegeta-test":
+				t.Errorf("got volumes %+v", pod.Volumes)
+			case len(pod.Containers) != 1:
+				t.Fatalf("got %d containers", len(pod.Containers))
+			}
+
+			c := pod.Containers[0]
+			if c.Image != "vegeta:12" || !reflect.DeepEqual(c.Command, []string{"vegeta"}) {
+				t.Errorf("got image %q and command %v", c.Image, c.Command)
+			}
+
+			if !reflect.DeepEqual(c.Args, tc.args) {
+				t.Errorf("got args %q, want %q", c.Args, tc.args)
+			}
+
+			env := []map[string]string{}
+			for k, v := range tc.plan.Env {
+				env = append(env, map[string]string{"name": k, "value": v})
+			}
+
+			if !reflect.DeepEqual(c.Env, env) {
+				t.Errorf("got env %v, want %v", c.Env, env)
+			}
+
+			if string(c.Resources) != string(tc.plan.Resources) {
+				t.Errorf("got resources %s, want %s", c.Resources, tc.plan.Resources)
+			}
+		})
+	}
+}
+
+// fakeKubeAPI is a fake Kubernetes API server whose Jobs log the given
+// number of results each.
+type fakeKubeAPI struct {
+	results int
+
+	mu      sync.Mutex
+	jobs    map[string][]string // Args by name.
+	deleted []string
+}
+
+func (f *fakeKubeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	const ns = "/namespaces/default"
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch path := r.URL.Path; {
+	case r.Method == "DELETE":
+		f.deleted = append(f.deleted, path)
+	case r.Method == "POST" && path == "/api/v1"+ns+"/configmaps":
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "POST" && path == "/apis/batch/v1"+ns+"/jobs":
+		var job struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Spec struct {
+				Template struct {
+					Spec struct {
+						Containers []struct {
+							Args []string `json:"args"`
+						} `json:"containers"`
+					} `json:"spec"`
+				} `json:"template"`
+			} `json:"spec"`
+		}
+		json.NewDecoder(r.Body).Decode(&job)
+		f.jobs[job.Metadata.Name] = job.Spec.Template.Spec.Containers[0].Args
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "GET" && path == "/api/v1"+ns+"/pods":
+		name := strings.TrimPrefix(r.URL.Query().Get("labelSelector"), "job-name=")
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": []interface{}{
+			map[string]interface{}{
+				"metadata": map[string]string{"name": name + "-pod"},
+				"status":   map[string]string{"phase": "Running"},
+			},
+		}})
+	case r.Method == "GET" && strings.HasSuffix(path, "-pod/log"):
+		// Jobs log their metadata record and other lines besides results.
+		enc := vegeta.NewJSONEncoder(w)
+		enc.Encode(&vegeta.Result{Metadata: &vegeta.Metadata{Began: time.Now()}})
+		w.Write([]byte("2026/03/01 12:00:00 starting attack\n"))
+		for i := 0; i < f.results; i++ {
+			enc.Encode(&vegeta.Result{Seq: uint64(i), Code: 200, Timestamp: time.Now()})
+		}
+	case r.Method == "GET" && strings.HasPrefix(path, "/apis/batch/v1"+ns+"/jobs/"):
+		w.Write([]byte(`{"status":{"succeeded":1}}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestKubernetes(t *testing.T) {
+	t.Parallel()
+
+	api := &fakeKubeAPI{results: 5, jobs: map[string][]string{}}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "vegeta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	plan := filepath.Join(dir, "plan.json")
+	bs, _ := json.Marshal(map[string]interface{}{"api": srv.URL, "image": "vegeta:12", "jobs": 3})
+	if err = ioutil.WriteFile(plan, bs, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rate leaves the third Job without a share of it.
+	opts := &attackOpts{
+		name:       "Big Bang",
+		kubernetes: plan,
+		rate:       vegeta.Rate{Freq: 2, Per: time.Second},
+		workerArgs: []string{"-duration=10s"},
+	}
+
+	start := time.Now().Add(kubeStartDelay)
+	d, err := kubernetes(opts, start, []byte("GET http://target/\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seqs := map[string][]uint64{}
+	for r := range d.results {
+		seqs[r.Labels["node"]] = append(seqs[r.Labels["node"]], r.Seq)
+	}
+
+	if err = d.err(); err != nil {
+		t.Fatal(err)
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	var names []string
+	for name := range api.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) != 2 {
+		t.Fatalf("got jobs %v, want 2", names)
+	}
+
+	for i, name := range names {
+		if !strings.HasPrefix(name, "vegeta-big-bang-") {
+			t.Errorf("got job name %q", name)
+		}
+
+		args := strings.Join(api.jobs[name], " ")
+		for _, arg := range []string{"-duration=10s", "-rate=1/1s", "-start-at=" + start.Format(time.RFC3339Nano)} {
+			if !strings.Contains(args, arg) {
+				t.Errorf("job %d: got args %q, want them to contain %q", i, args, arg)
+			}
+		}
+
+		// Results of each Job are merged in order, without the metadata
+		// records and other lines of their logs.
+		if want := []uint64{0, 1, 2, 3, 4}; !reflect.DeepEqual(seqs[name], want) {
+			t.Errorf("job %d: got results %v, want %v", i, seqs[name], want)
+		}
+	}
+
+	if len(seqs) != 2 {
+		t.Errorf("got results of nodes %v, want those of 2 jobs", seqs)
+	}
+
+	// The Job without a share of the rate is deleted in case it exists.
+	if len(api.deleted) != 4 {
+		t.Errorf("got deleted %v, want the configmap and 3 jobs", api.deleted)
+	}
+}
+
+func TestKubeJobPod(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name  string
+		start time.Time
+		pod   string
+		want  string
+		err   string
+	}{
+		{
+			name:  "running",
+			start: time.Now(),
+			pod:   `{"metadata":{"name":"p"},"status":{"phase":"Running"}}`,
+			want:  "p",
+		},
+		{
+			name:  "image pull failed",
+			start: time.Now(),
+			pod: `{"metadata":{"name":"p"},"status":{"phase":"Pending","containerStatuses":[
+				{"state":{"waiting":{"reason":"ImagePullBackOff","message":"Back-off pulling image \"vegeta:13\""}}}]}}`,
+			err: `pod p: ImagePullBackOff: Back-off pulling image "vegeta:13"`,
+		},
+		{
+			name:  "unschedulable",
+			start: time.Now().Add(-2 * kubePendingTimeout),
+			pod: `{"metadata":{"name":"p"},"status":{"phase":"Pending","conditions":[
+				{"type":"PodScheduled","status":"False","message":"0/3 nodes are available: 3 Insufficient cpu."}]}}`,
+			err: "still pending 1m0s after the start of the attack: pod p: 0/3 nodes are available: 3 Insufficient cpu.",
+		},
+		{
+			name:  "not created",
+			start: time.Now().Add(-2 * kubePendingTimeout),
+			err:   "still pending 1m0s after the start of the attack: no pod created",
+		},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"items":[` + tc.pod + `]}`))
+		}))
+
+		j := &kubeJob{client: &kubeClient{api: srv.URL, client: http.DefaultClient}, ns: "/namespaces/default", name: "job", start: tc.start}
+		got, err := j.pod(context.Background())
+		srv.Close()
+
+		if tc.err == "" && err != nil || tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("%s: got error %v, want %q", tc.name, err, tc.err)
+		} else if got != tc.want {
+			t.Errorf("%s: got pod %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package vegeta
+
+import (
+	"fmt"
+	"strings"
+)
+
+// An Assertion is a condition which responses must meet, given as a filter
+// expression on their Results, e.g. `code == 200 && $.status == ok`, which
+// can check status codes, headers, bodies, with regular expressions, and the
+// values of JSON bodies, with JSONPath expressions. See ParseFilter.
+//
+// The Results of responses which fail an Assertion fail with its error, e.g.
+// "status assertion failed: code == 200", which names the kind of check, so
+// that functional failures are told apart from each other and from transport
+// errors in the errors of Metrics, and aren't counted as successes, whatever
+// their status code.
+type Assertion struct {
+	// Expr is the filter expression the Results of responses must match.
+	Expr string
+	// Kind is the kind of check of the first comparison of Expr: status,
+	// header, body or jsonpath, or else the name of the compared field.
+	Kind string
+
+	match Filter
+}
+
+// ParseAssertion parses an Assertion from its filter expression.
+func ParseAssertion(expr string) (Assertion, error) {
+	match, err := ParseFilter(expr)
+	if err != nil {
+		return Assertion{}, fmt.Errorf("assertion: %v", err)
+	}
+
+	// The kind is that of the first field compared.
+	p := filterParser{s: expr}
+	for p.accept("!") || p.accept("(") {
+	}
+	name, _ := p.value()
+
+	kind := name
+	switch {
+	case name == "code":
+		kind = "status"
+	case strings.HasPrefix(name, "header."):
+		kind = "header"
+	case strings.HasPrefix(name, "$"):
+		kind = "jsonpath"
+	}
+
+	return Assertion{Expr: expr, Kind: kind, match: match}, nil
+}
+
+// Check returns whether the given Result meets the Assertion.
+func (a Assertion) Check(r *Result) bool { return a.match(r) }
+
+// assertionFailed separates the kind and expression of failed Assertions in
+// the errors of their Results.
+const assertionFailed = " assertion failed: "
+
+// Error returns the error of the Results which fail the Assertion.
+func (a Assertion) Error() string {
+	return a.Kind + assertionFailed + a.Expr
+}
+
+// failedAssertion returns whether the given Result failed an Assertion.
+func failedAssertion(r *Result) bool {
+	return strings.Contains(r.Error, assertionFailed)
+}
+
+// Assertions returns a functional option which makes an Attacker check the
+// Results of all responses against the given Assertions, before those of
+// their Target, failing them with the error of the first one they fail.
+// Responses are checked as read, up to MaxBody, before bodies are dropped or
+// truncated by KeepBody. Passing Assertions don't make responses with error
+// status codes successful.
+func Assertions(as ...Assertion) func(*Attacker) {
+	return func(a *Attacker) { a.assertions = as }
+}
+
+// assert checks the Result of the response to the given Target against the
+// Assertions of the Attacker and Target, setting its error to that of the
+// first one it fails, if any, or to that of parsing those of the Target.
+func (a *Attacker) assert(tgt *Target, res *Result) {
+	for _, as := range a.assertions {
+		if !as.Check(res) {
+			res.Error = as.Error()
+			return
+		}
+	}
+
+	for _, expr := range tgt.Assert {
+		as, err := a.targetAssertion(expr)
+		if err != nil {
+			res.Error = err.Error()
+			return
+		} else if !as.Check(res) {
+			res.Error = as.Error()
+			return
+		}
+	}
+}
+
+// targetAssertion returns the Assertion with the given expression of a
+// Target, parsed once per Attacker.
+func (a *Attacker) targetAssertion(expr string) (Assertion, error) {
+	a.assertmu.Lock()
+	defer a.assertmu.Unlock()
+
+	if as, ok := a.tgtAsserts[expr]; ok {
+		return as, nil
+	}
+
+	as, err := ParseAssertion(expr)
+	if err != nil {
+		return Assertion{}, err
+	}
+
+	if a.tgtAsserts == nil {
+		a.tgtAsserts = map[string]Assertion{}
+	}
+	a.tgtAsserts[expr] = as
+
+	return as, nil
+}
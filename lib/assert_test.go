@@ -0,0 +1,99 @@
+package vegeta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseAssertion(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		in   string
+		kind string
+		err  bool
+	}{
+		{in: "code == 200", kind: "status"},
+		{in: "!(code >= 500) && body =~ ok", kind: "status"},
+		{in: "header.Content-Type =~ json", kind: "header"},
+		{in: `body =~ "\"id\": ?\\d+"`, kind: "body"},
+		{in: "$.items[0].id == 1", kind: "jsonpath"},
+		{in: "latency < 100ms", kind: "latency"},
+		{in: "code = 200", err: true},
+		{in: "$.items[ == 1", err: true},
+	} {
+		as, err := ParseAssertion(tc.in)
+		if tc.err {
+			if err == nil {
+				t.Errorf("ParseAssertion(%q): want error", tc.in)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseAssertion(%q): %v", tc.in, err)
+		} else if as.Kind != tc.kind {
+			t.Errorf("ParseAssertion(%q): got kind %q, want %q", tc.in, as.Kind, tc.kind)
+		}
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte(`{"status": "ok", "items": [{"id": 1}]}`))
+		case "/degraded":
+			w.Write([]byte(`{"status": "degraded", "items": []}`))
+		case "/html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<p>ok</p>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var as []Assertion
+	for _, expr := range []string{"code == 200", "header.Content-Type =~ json"} {
+		a, err := ParseAssertion(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		as = append(as, a)
+	}
+
+	atk := NewAttacker(Assertions(as...), KeepBody(func(*Result) bool { return false }, -1))
+	for _, tc := range []struct {
+		path   string
+		assert []string
+		err    string
+	}{
+		{path: "/ok", assert: []string{"$.status == ok", "$.items[0].id >= 1"}},
+		{path: "/degraded", assert: []string{"$.status == ok"}, err: "jsonpath assertion failed: $.status == ok"},
+		{path: "/html", assert: []string{"$.status == ok"}, err: "header assertion failed: header.Content-Type =~ json"},
+		{path: "/missing", err: "status assertion failed: code == 200"},
+		{path: "/ok", assert: []string{"$.status ="}, err: `assertion: filter "$.status =": at offset 9: missing comparison operator after $.status (one of ==, !=, <=, >=, =~, !~, <, >)`},
+	} {
+		tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL + tc.path, Assert: tc.assert})
+		if res := atk.hit(tr, "", 1); res.Error != tc.err {
+			t.Errorf("%s %v: got error %q, want %q", tc.path, tc.assert, res.Error, tc.err)
+		} else if len(res.Body) > 0 {
+			t.Errorf("%s: got body kept, want it dropped after the assertions", tc.path)
+		}
+	}
+
+	// Failed assertions aren't successes, whatever their status code.
+	var m Metrics
+	m.Add(&Result{Code: 200, Error: as[0].Error()})
+	m.Add(&Result{Code: 200})
+	m.Close()
+
+	if m.Success != 0.5 {
+		t.Errorf("got success ratio %v, want 0.5", m.Success)
+	}
+}
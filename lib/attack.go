@@ -3,15 +3,20 @@ package vegeta
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"golang.org/x/net/http2"
@@ -20,7 +25,31 @@ import (
 // Attacker is an attack executor which wraps an http.Client
 type Attacker struct {
 	dialer     *net.Dialer
+	laddrs     []*net.TCPAddr // Bound to by dialed connections in turn.
+	laddrIdx   uint64
+	lports     []int // Bound to by dialed connections in turn.
+	lportIdx   uint64
+	aliases    map[string]string // Addresses dialed in place of hosts.
+	connectTo  map[string]string // Addresses dialed in place of host:port ones.
+	hosts      *hostCache
+	family     string // "ipv4" or "ipv6" if only hosts' addresses of it are dialed.
+	delay      bool   // Disables TCP_NODELAY on dialed connections.
+	sndbuf     int    // SO_SNDBUF of dialed connections, if positive.
+	rcvbuf     int    // SO_RCVBUF of dialed connections, if positive.
+	netem      *netem // Network conditions emulated on dialed connections.
+	warmmu     sync.Mutex
+	warm       map[warmHost][]net.Conn // Prewarmed connections.
+	certs      []tls.Certificate       // Client certificates presented in turn.
+	certIdx    uint64
+	certmu     sync.Mutex
+	clients    map[string]*http.Client // Clients presenting client certificates.
+	ntlm       *ntlmTransport
+	excludeNeg bool // Excludes negotiation times from latencies.
+	jarmu      sync.Mutex
+	jars       map[string]http.CookieJar // Cookie jars of sessions, if kept.
 	client     http.Client
+	base       http.RoundTripper // Transport of the client, before wrap wrapped it.
+	wrap       func(http.RoundTripper) http.RoundTripper
 	stopch     chan struct{}
 	workers    uint64
 	maxWorkers uint64
@@ -28,8 +57,48 @@ type Attacker struct {
 	redirects  int
 	seqmu      sync.Mutex
 	seq        uint64
+	seqHeader  string // Name of the header requests are sent with their Seq in.
+	atkHeader  string // Name of the header requests are sent with their attack in.
 	began      time.Time
 	chunked    bool
+	expect     bool // Sends requests with bodies with Expect: 100-continue.
+	h2c        bool // Sends requests with cleartext HTTP/2.
+	keepBody   func(*Result) bool
+	maxKept    int64
+	captureReq bool
+	acceptEnc  string // Accept-Encoding of requests, which decodes responses.
+	bodyCoding string // Content coding request bodies are compressed with.
+	wireBytes  bool   // Counts the bytes of bodies before they're decoded.
+	authorize  func(*http.Request) error
+	beforeHit  func(*Target, *http.Request) error
+	afterHit   func(*Result)
+	assertions []Assertion
+	assertmu   sync.Mutex
+	tgtAsserts map[string]Assertion // Parsed assertions of targets.
+	retries    uint64
+	retry      func(*Result, error) bool
+	retryWait  time.Duration // Backoff before the first retry, doubled for each next one.
+	labels     map[string]string
+	recordConn bool
+	recordTLS  bool
+	recHeaders []string // Names of the only response headers recorded, if any.
+	validators *validatorCache
+	traces     *traceIDs // Generates the traceparent headers of requests.
+	spans      *traceIDs // Samples the hits traced with spans.
+	export     func([]Span)
+
+	ctlmu  sync.Mutex
+	resume chan struct{} // Closed by Resume, nil unless paused.
+	pacer  Pacer         // Set by SetPacer until the attack paces with it.
+
+	rampDown time.Duration
+	rampch   chan struct{} // Closed by RampDown.
+
+	resumeElapsed time.Duration
+	resumeHits    uint64
+	progBegan     time.Time // When the attack would have begun without pauses.
+	progHits      uint64
+	pausedAt      time.Time
 }
 
 const (
@@ -52,6 +121,12 @@ const (
 	// DefaultMaxBody is the default max number of bytes to be read from response bodies.
 	// Defaults to no limit.
 	DefaultMaxBody = int64(-1)
+	// DefaultSeqHeader is the default name of the header requests are sent
+	// with their Seq in.
+	DefaultSeqHeader = "X-Vegeta-Seq"
+	// DefaultAttackHeader is the default name of the header requests are sent
+	// with the name of their attack in.
+	DefaultAttackHeader = "X-Vegeta-Attack"
 	// NoFollow is the value when redirects are not followed but marked successful
 	NoFollow = -1
 )
@@ -68,9 +143,13 @@ var (
 func NewAttacker(opts ...func(*Attacker)) *Attacker {
 	a := &Attacker{
 		stopch:     make(chan struct{}),
+		rampch:     make(chan struct{}),
 		workers:    DefaultWorkers,
 		maxWorkers: DefaultMaxWorkers,
 		maxBody:    DefaultMaxBody,
+		maxKept:    -1,
+		seqHeader:  DefaultSeqHeader,
+		atkHeader:  DefaultAttackHeader,
 		began:      time.Now(),
 	}
 
@@ -83,7 +162,7 @@ func NewAttacker(opts ...func(*Attacker)) *Attacker {
 		Timeout: DefaultTimeout,
 		Transport: &http.Transport{
 			Proxy:               http.ProxyFromEnvironment,
-			Dial:                a.dialer.Dial,
+			DialContext:         a.dial,
 			TLSClientConfig:     DefaultTLSConfig,
 			MaxIdleConnsPerHost: DefaultConnections,
 			MaxConnsPerHost:     DefaultMaxConnections,
@@ -94,6 +173,16 @@ func NewAttacker(opts ...func(*Attacker)) *Attacker {
 		opt(a)
 	}
 
+	if tr, ok := a.client.Transport.(*http.Transport); ok && a.h2c {
+		a.client.Transport = a.h2cTransport(tr)
+	}
+
+	// The transport is wrapped once the other options configured it.
+	if a.wrap != nil {
+		a.base = a.client.Transport
+		a.client.Transport = a.wrap(a.base)
+	}
+
 	return a
 }
 
@@ -134,26 +223,103 @@ func ChunkedBody(b bool) func(*Attacker) {
 	return func(a *Attacker) { a.chunked = b }
 }
 
+// ExpectContinue returns a functional option which makes the attacker send
+// requests with bodies with the Expect: 100-continue header, waiting up to the
+// given timeout for the interim 100 Continue response before sending their
+// bodies anyway, as large upload clients do. Whether it arrived is recorded in
+// the Continued field of Results. H2C requests don't wait for it. A zero
+// timeout disables it.
+func ExpectContinue(timeout time.Duration) func(*Attacker) {
+	return func(a *Attacker) {
+		a.expect = timeout > 0
+		if tr, ok := a.transport(); ok {
+			tr.ExpectContinueTimeout = timeout
+		}
+	}
+}
+
+// SeqHeaders returns a functional option which sets the names of the headers
+// each request is sent with its Seq and the name of its attack in, so that the
+// logs of the targets can be joined one to one with the Results of an attack,
+// along with their Attempt if retried. They default to DefaultSeqHeader and
+// DefaultAttackHeader. Empty names disable them.
+func SeqHeaders(seq, attack string) func(*Attacker) {
+	return func(a *Attacker) { a.seqHeader, a.atkHeader = seq, attack }
+}
+
+// CaptureRequest returns a functional option which makes the attacker record
+// the body and headers of each request, as it's sent, in its Result.
+func CaptureRequest(b bool) func(*Attacker) {
+	return func(a *Attacker) { a.captureReq = b }
+}
+
+// Labels returns a functional option which labels the Results of the attacker
+// with the given key value pairs. The map is shared by all Results and must
+// not be modified afterwards.
+func Labels(labels map[string]string) func(*Attacker) {
+	return func(a *Attacker) { a.labels = labels }
+}
+
+// RecordConnections returns a functional option which makes the attacker
+// record the remote and local addresses of the connection each request is
+// sent on in its Result, so that Results can be attributed to the backends
+// and connections which served them, as well as its IP family, how long it
+// waited for it and how long establishing its tunnel through a proxy took.
+func RecordConnections(b bool) func(*Attacker) {
+	return func(a *Attacker) { a.recordConn = b }
+}
+
+// RecordTLS returns a functional option which makes the attacker record the
+// version, cipher suite, negotiated protocol and session resumption of the
+// TLS connection each response is received on in its Result.
+func RecordTLS(b bool) func(*Attacker) {
+	return func(a *Attacker) { a.recordTLS = b }
+}
+
+// RecordHeaders returns a functional option which makes the attacker record
+// only the response headers with the given names in the Headers of its
+// Results, e.g. X-Cache or Server-Timing, rather than all of them, to keep
+// results small while they can still be told apart by those headers. No
+// names record all headers.
+func RecordHeaders(names ...string) func(*Attacker) {
+	return func(a *Attacker) {
+		a.recHeaders = nil
+		for _, name := range names {
+			a.recHeaders = append(a.recHeaders, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
 // Redirects returns a functional option which sets the maximum
-// number of redirects an Attacker will follow.
+// number of redirects an Attacker will follow. Targets with their own
+// Redirects follow those instead.
 func Redirects(n int) func(*Attacker) {
 	return func(a *Attacker) {
 		a.redirects = n
-		a.client.CheckRedirect = func(_ *http.Request, via []*http.Request) error {
-			switch {
-			case n == NoFollow:
-				return http.ErrUseLastResponse
-			case n < len(via):
-				return fmt.Errorf("stopped after %d redirects", n)
-			default:
-				return nil
-			}
+		a.client.CheckRedirect = checkRedirect(n)
+	}
+}
+
+// checkRedirect returns the CheckRedirect function of clients following up
+// to the given number of redirects.
+func checkRedirect(n int) func(*http.Request, []*http.Request) error {
+	return func(_ *http.Request, via []*http.Request) error {
+		switch {
+		case n == NoFollow:
+			return http.ErrUseLastResponse
+		case n < len(via):
+			return fmt.Errorf("stopped after %d redirects", n)
+		default:
+			return nil
 		}
 	}
 }
 
 // Proxy returns a functional option which sets the `Proxy` field on
-// the http.Client's Transport
+// the http.Client's Transport. Proxies authenticate CONNECT requests with
+// the user info of their URLs, with Basic authentication, or else with the
+// Proxy-Authorization header set with ProxyHeader. The time spent tunneling
+// through them is recorded with RecordConnections.
 func Proxy(proxy func(*http.Request) (*url.URL, error)) func(*Attacker) {
 	return func(a *Attacker) {
 		tr := a.client.Transport.(*http.Transport)
@@ -161,6 +327,18 @@ func Proxy(proxy func(*http.Request) (*url.URL, error)) func(*Attacker) {
 	}
 }
 
+// tunneled returns whether the given request is sent through a tunnel to its
+// host, established with CONNECT, through the proxy of the Attacker, as
+// HTTPS requests are.
+func (a *Attacker) tunneled(req *http.Request) bool {
+	tr, ok := a.transport()
+	if !ok || tr.Proxy == nil || req.URL.Scheme != "https" {
+		return false
+	}
+	proxy, err := tr.Proxy(req)
+	return err == nil && proxy != nil
+}
+
 // Timeout returns a functional option which sets the maximum amount of time
 // an Attacker will wait for a request to be responded to and completely read.
 func Timeout(d time.Duration) func(*Attacker) {
@@ -175,7 +353,179 @@ func LocalAddr(addr net.IPAddr) func(*Attacker) {
 	return func(a *Attacker) {
 		tr := a.client.Transport.(*http.Transport)
 		a.dialer.LocalAddr = &net.TCPAddr{IP: addr.IP, Zone: addr.Zone}
-		tr.Dial = a.dialer.Dial
+		a.laddrs = nil
+		tr.DialContext = a.dial
+	}
+}
+
+// LocalAddrs returns a functional option which sets the local addresses an
+// Attacker binds the connections it dials to, in turn, e.g. to open more
+// connections than the ephemeral ports of a single address allow, or to
+// spread requests across client IPs. The addresses must be of the same IP
+// family as the ones of the targets.
+func LocalAddrs(addrs ...net.IPAddr) func(*Attacker) {
+	return func(a *Attacker) {
+		if len(addrs) == 1 {
+			LocalAddr(addrs[0])(a)
+			return
+		}
+
+		tr := a.client.Transport.(*http.Transport)
+		a.laddrs = make([]*net.TCPAddr, len(addrs))
+		for i, addr := range addrs {
+			a.laddrs[i] = &net.TCPAddr{IP: addr.IP, Zone: addr.Zone}
+		}
+		tr.DialContext = a.dial
+	}
+}
+
+// LocalPorts returns a functional option which sets the local ports an
+// Attacker binds the connections it dials to, in turn, instead of ephemeral
+// ones chosen by the operating system, e.g. when firewalls only let certain
+// source ports through. Ports in use, e.g. by connections in TIME_WAIT, are
+// skipped, and dialing fails if all are.
+func LocalPorts(ports ...int) func(*Attacker) {
+	return func(a *Attacker) {
+		tr := a.client.Transport.(*http.Transport)
+		a.lports = ports
+		tr.DialContext = a.dial
+	}
+}
+
+// Resolver returns a functional option which sets the resolver an Attacker
+// looks the hosts of its targets up with, e.g. one querying specific DNS
+// servers, instead of the system's.
+func Resolver(r *net.Resolver) func(*Attacker) {
+	return func(a *Attacker) { a.dialer.Resolver = r }
+}
+
+// HostAliases returns a functional option which makes an Attacker connect to
+// the given addresses, IPs or host names, in place of the hosts they're keyed
+// by, e.g. {"api.example.com": "10.1.2.3"}, like entries of /etc/hosts. The
+// URLs of requests, their Host headers and TLS server names are unchanged.
+func HostAliases(aliases map[string]string) func(*Attacker) {
+	return func(a *Attacker) {
+		tr := a.client.Transport.(*http.Transport)
+		a.aliases = make(map[string]string, len(aliases))
+		for host, alias := range aliases {
+			a.aliases[strings.ToLower(host)] = alias
+		}
+		tr.DialContext = a.dial
+	}
+}
+
+// ConnectTo returns a functional option which makes an Attacker connect to the
+// given addresses (host:port) in place of the ones they're keyed by, like
+// curl's --connect-to, e.g. {"api.example.com:443": "10.1.2.3:8443"}, to test
+// the backends behind a virtual host one by one. Keys without a host match
+// any host, e.g. ":443", and keys without a port any port, while values
+// without a host or port keep the dialed one. The URLs of requests, their Host
+// headers and TLS server names are unchanged. HostAliases apply to the hosts
+// connected to in turn.
+func ConnectTo(addrs map[string]string) func(*Attacker) {
+	return func(a *Attacker) {
+		tr := a.client.Transport.(*http.Transport)
+		a.connectTo = make(map[string]string, len(addrs))
+		for from, to := range addrs {
+			if host, port, err := net.SplitHostPort(from); err == nil {
+				from = net.JoinHostPort(strings.ToLower(host), port)
+			}
+			a.connectTo[from] = to
+		}
+		tr.DialContext = a.dial
+	}
+}
+
+// DNSRefresh returns a functional option which makes an Attacker resolve the
+// hosts it connects to again at the given interval, caching their addresses
+// in between, and spread its new connections across all of them in turn,
+// rather than connecting to the first one that works. When the addresses of
+// a host change, idle connections are closed so that new ones are made to
+// them, e.g. to DNS load balanced or autoscaled services.
+func DNSRefresh(interval time.Duration) func(*Attacker) {
+	return func(a *Attacker) {
+		if interval <= 0 {
+			a.hosts = nil
+			return
+		}
+
+		tr := a.client.Transport.(*http.Transport)
+		a.hosts = newHostCache(interval)
+		tr.DialContext = a.dial
+	}
+}
+
+// IPVersion returns a functional option which makes an Attacker only connect
+// to the IPv4 addresses, with version 4, or the IPv6 addresses, with version
+// 6, of the hosts of its targets, rather than to either, with any other
+// version, e.g. to measure both of dual-stack hosts separately.
+func IPVersion(version int) func(*Attacker) {
+	return func(a *Attacker) {
+		tr := a.client.Transport.(*http.Transport)
+		switch version {
+		case 4:
+			a.family = "ipv4"
+		case 6:
+			a.family = "ipv6"
+		default:
+			a.family = ""
+		}
+		tr.DialContext = a.dial
+	}
+}
+
+// FallbackDelay returns a functional option which sets how long an Attacker
+// waits for a connection to the addresses of the first IP family a
+// dual-stack host resolves to, usually IPv6, before racing one to those of
+// the other, as per Happy Eyeballs (RFC 6555). Zero is the default of 300ms,
+// while a negative delay disables racing, so that the addresses of the other
+// family are only dialed if those of the first fail. Addresses are never
+// raced with DNSRefresh, which dials them in turn.
+func FallbackDelay(d time.Duration) func(*Attacker) {
+	return func(a *Attacker) {
+		tr := a.client.Transport.(*http.Transport)
+		a.dialer.FallbackDelay = d
+		tr.DialContext = a.dial
+	}
+}
+
+// NoDelay returns a functional option which sets whether the connections an
+// Attacker dials have TCP_NODELAY set, which they do by default, sending small
+// writes right away, or delay them to coalesce them, as per Nagle's algorithm,
+// like some client stacks do.
+func NoDelay(enabled bool) func(*Attacker) {
+	return func(a *Attacker) {
+		tr := a.client.Transport.(*http.Transport)
+		a.delay = !enabled
+		tr.DialContext = a.dial
+	}
+}
+
+// SocketBuffers returns a functional option which sets the sizes of the send
+// (SO_SNDBUF) and receive (SO_RCVBUF) buffers of the connections an Attacker
+// dials, in bytes, e.g. to reproduce the throughput of constrained clients.
+// Sizes which aren't positive leave the operating system's default, which
+// may also round or cap them.
+func SocketBuffers(send, recv int) func(*Attacker) {
+	return func(a *Attacker) {
+		tr := a.client.Transport.(*http.Transport)
+		a.sndbuf, a.rcvbuf = send, recv
+		tr.DialContext = a.dial
+	}
+}
+
+// TCPKeepAlive returns a functional option which sets the interval between
+// the TCP keep-alive probes of the idle connections an Attacker dials. Zero
+// keeps the default of 30s, while a negative interval disables them.
+func TCPKeepAlive(interval time.Duration) func(*Attacker) {
+	return func(a *Attacker) {
+		if interval == 0 {
+			return
+		}
+
+		tr := a.client.Transport.(*http.Transport)
+		a.dialer.KeepAlive = interval
+		tr.DialContext = a.dial
 	}
 }
 
@@ -187,13 +537,15 @@ func KeepAlive(keepalive bool) func(*Attacker) {
 		tr.DisableKeepAlives = !keepalive
 		if !keepalive {
 			a.dialer.KeepAlive = 0
-			tr.Dial = a.dialer.Dial
+			tr.DialContext = a.dial
 		}
 	}
 }
 
 // TLSConfig returns a functional option which sets the *tls.Config for a
-// Attacker to use with its requests.
+// Attacker to use with its requests. The private keys of its client
+// certificates can be any crypto.Signer, e.g. one backed by a hardware
+// security module, so that they needn't be exported.
 func TLSConfig(c *tls.Config) func(*Attacker) {
 	return func(a *Attacker) {
 		tr := a.client.Transport.(*http.Transport)
@@ -201,6 +553,79 @@ func TLSConfig(c *tls.Config) func(*Attacker) {
 	}
 }
 
+// TLSResumption returns a functional option which makes an Attacker resume the
+// TLS sessions of previous connections to the hosts of its targets on new
+// ones, with session tickets, rather than always performing full handshakes,
+// as clients with a session cache do. The sessions of up to 64 hosts are
+// cached. Whether a session was resumed is recorded with RecordTLS. TLS 1.3
+// early data (0-RTT) isn't supported.
+func TLSResumption(enabled bool) func(*Attacker) {
+	return func(a *Attacker) {
+		c := a.cloneTLSConfig()
+		c.ClientSessionCache, c.SessionTicketsDisabled = nil, !enabled
+		if enabled {
+			c.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+		}
+	}
+}
+
+// TLSVersions returns a functional option which sets the minimum and maximum
+// TLS versions an Attacker negotiates, e.g. tls.VersionTLS12, where zero
+// leaves the default of crypto/tls. See ParseTLSVersion.
+func TLSVersions(min, max uint16) func(*Attacker) {
+	return func(a *Attacker) {
+		c := a.cloneTLSConfig()
+		c.MinVersion, c.MaxVersion = min, max
+	}
+}
+
+// TLSCipherSuites returns a functional option which sets the cipher suites an
+// Attacker offers in TLS 1.2 and lower handshakes, in order of preference, or
+// the defaults of crypto/tls if none are given. The cipher suites of TLS 1.3
+// aren't configurable. See ParseTLSCipherSuite.
+func TLSCipherSuites(ids ...uint16) func(*Attacker) {
+	return func(a *Attacker) {
+		c := a.cloneTLSConfig()
+		c.CipherSuites = ids
+	}
+}
+
+// TLSKeyLog returns a functional option which makes an Attacker write the
+// secrets of its TLS connections to the given writer in NSS key log format,
+// so that packet captures of an attack can be decrypted, e.g. by Wireshark.
+// It compromises the security of the connections, so use it for debugging
+// only.
+func TLSKeyLog(w io.Writer) func(*Attacker) {
+	return func(a *Attacker) {
+		c := a.cloneTLSConfig()
+		c.KeyLogWriter = w
+	}
+}
+
+// ServerName returns a functional option which makes an Attacker send the
+// given server name in TLS handshakes (SNI), and verify server certificates
+// against it, in place of the hosts of target URLs, unless empty. Targets with
+// their own server name send it instead.
+func ServerName(name string) func(*Attacker) {
+	return func(a *Attacker) {
+		c := a.cloneTLSConfig()
+		c.ServerName = name
+	}
+}
+
+// cloneTLSConfig replaces the TLS config of the transport of the Attacker,
+// which may be shared, e.g. DefaultTLSConfig, with a copy which it returns to
+// be changed.
+func (a *Attacker) cloneTLSConfig() *tls.Config {
+	tr := a.client.Transport.(*http.Transport)
+	c := &tls.Config{}
+	if tr.TLSClientConfig != nil {
+		c = tr.TLSClientConfig.Clone()
+	}
+	tr.TLSClientConfig = c
+	return c
+}
+
 // HTTP2 returns a functional option which enables or disables HTTP/2 support
 // on requests performed by an Attacker.
 func HTTP2(enabled bool) func(*Attacker) {
@@ -213,18 +638,29 @@ func HTTP2(enabled bool) func(*Attacker) {
 	}
 }
 
-// H2C returns a functional option which enables H2C support on requests
-// performed by an Attacker
+// H2C returns a functional option which makes an Attacker send its requests
+// with cleartext HTTP/2 with prior knowledge (h2c), without TLS nor an HTTP/1.1
+// upgrade, e.g. to services which never terminate TLS, like gRPC gateways in a
+// cluster. Connections are dialed like those of HTTP/1.1 requests, as the
+// other options configure, regardless of their order, and within the Timeout,
+// and multiplex requests up to the limit of concurrent streams of servers.
 func H2C(enabled bool) func(*Attacker) {
-	return func(a *Attacker) {
-		if tr := a.client.Transport.(*http.Transport); enabled {
-			a.client.Transport = &http2.Transport{
-				AllowHTTP: true,
-				DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
-					return tr.Dial(network, addr)
-				},
+	return func(a *Attacker) { a.h2c = enabled }
+}
+
+// h2cTransport returns an HTTP/2 transport dialing cleartext connections
+// with the given transport.
+func (a *Attacker) h2cTransport(tr *http.Transport) *http2.Transport {
+	return &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			ctx, cancel := context.Background(), func() {}
+			if a.client.Timeout > 0 {
+				ctx, cancel = context.WithTimeout(ctx, a.client.Timeout)
 			}
-		}
+			defer cancel()
+			return tr.DialContext(ctx, network, addr)
+		},
 	}
 }
 
@@ -234,6 +670,15 @@ func MaxBody(n int64) func(*Attacker) {
 	return func(a *Attacker) { a.maxBody = n }
 }
 
+// KeepBody returns a functional option which sets a function deciding whether
+// the response body of each Result is kept, e.g. only when the request failed,
+// and the max number of bytes of kept bodies, beyond which they're truncated.
+// Set max to -1 to disable any limits. Discarded bodies are still read, up to
+// MaxBody, to count the bytes received.
+func KeepBody(keep func(*Result) bool, max int64) func(*Attacker) {
+	return func(a *Attacker) { a.keepBody, a.maxKept = keep, max }
+}
+
 // UnixSocket changes the dialer for the attacker to use the specified unix socket file
 func UnixSocket(socket string) func(*Attacker) {
 	return func(a *Attacker) {
@@ -260,10 +705,252 @@ func ProxyHeader(h http.Header) func(*Attacker) {
 	}
 }
 
+// Authorizer returns a functional option which makes an Attacker call the
+// given function with each request right before sending it, e.g. to set its
+// Authorization header, failing its hit with the error it returns, if any.
+// Since it delays requests, it shouldn't block on the network. The functions
+// of several Authorizer options are called in the order they're given.
+func Authorizer(authorize func(*http.Request) error) func(*Attacker) {
+	return func(a *Attacker) {
+		prev := a.authorize
+		if prev == nil {
+			a.authorize = authorize
+			return
+		}
+
+		a.authorize = func(r *http.Request) error {
+			if err := prev(r); err != nil {
+				return err
+			}
+			return authorize(r)
+		}
+	}
+}
+
+// RampDownPeriod returns a functional option which sets the period over which
+// attacks ramp their rate down to zero, from the one they were at, when their
+// duration ends or RampDown is called, before they stop.
+func RampDownPeriod(d time.Duration) func(*Attacker) {
+	return func(a *Attacker) { a.rampDown = d }
+}
+
+// ResumeAt returns a functional option which resumes attacks from the given
+// elapsed time and number of hits, e.g. those of the Progress of an
+// interrupted attack: they're paced and count towards their duration from
+// there, and their hits are numbered on from the given one. Their Targeter
+// should skip as many targets.
+func ResumeAt(elapsed time.Duration, hits uint64) func(*Attacker) {
+	return func(a *Attacker) {
+		a.resumeElapsed, a.resumeHits = elapsed, hits
+		a.seq = hits
+	}
+}
+
+// dial dials the given address, or the one it's mapped to with ConnectTo, or
+// its host's alias, with the dialer of the Attacker, bound to the next of its
+// local addresses and ports, if it has several, unless it has a prewarmed
+// connection to it. With DNSRefresh, the
+// addresses of hosts are cached and dialed in turn. With IPVersion, only those
+// of its IP family are. The socket options of the Attacker are set on the
+// connections it dials.
+func (a *Attacker) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	if conn := a.warmConn("http", addr); conn != nil {
+		return conn, nil
+	}
+
+	conn, err := a.dialHost(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if tc, ok := conn.(*net.TCPConn); ok {
+		if err = a.setSocketOptions(tc); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if a.netem != nil {
+		return a.netem.emulate(ctx, conn)
+	}
+
+	return conn, nil
+}
+
+// setSocketOptions sets the socket options of the Attacker on the given
+// connection.
+func (a *Attacker) setSocketOptions(conn *net.TCPConn) error {
+	if a.delay {
+		if err := conn.SetNoDelay(false); err != nil {
+			return err
+		}
+	}
+
+	if a.sndbuf > 0 {
+		if err := conn.SetWriteBuffer(a.sndbuf); err != nil {
+			return err
+		}
+	}
+
+	if a.rcvbuf > 0 {
+		return conn.SetReadBuffer(a.rcvbuf)
+	}
+
+	return nil
+}
+
+// dialHost dials the given address, or its host's alias, as dial does.
+func (a *Attacker) dialHost(ctx context.Context, network, addr string) (net.Conn, error) {
+	if network == "tcp" {
+		switch a.family {
+		case "ipv4":
+			network = "tcp4"
+		case "ipv6":
+			network = "tcp6"
+		}
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	host, port = a.connectAddr(host, port)
+	if alias, ok := a.aliases[strings.ToLower(host)]; ok {
+		host = alias
+	}
+
+	d := a.dialer
+	if n := uint64(len(a.laddrs)); n > 0 {
+		bound := *a.dialer
+		bound.LocalAddr = a.laddrs[(atomic.AddUint64(&a.laddrIdx, 1)-1)%n]
+		d = &bound
+	}
+
+	if a.hosts == nil || net.ParseIP(host) != nil {
+		return a.dialPort(ctx, d, network, net.JoinHostPort(host, port))
+	}
+
+	addrs, changed, err := a.hosts.lookup(ctx, a.dialer.Resolver, host)
+	if err != nil {
+		return nil, err
+	} else if changed {
+		// Connections to the new addresses replace idle ones.
+		a.closeIdleConnections()
+	}
+
+	// Like the dialer does with the addresses of a host, the next ones are
+	// tried if one fails.
+	var conn net.Conn
+	err = &net.AddrError{Err: "no suitable address found", Addr: host}
+	for _, ip := range addrs {
+		if a.family != "" && ipFamily(net.ParseIP(ip)) != a.family {
+			continue
+		}
+		if conn, err = a.dialPort(ctx, d, network, net.JoinHostPort(ip, port)); err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	return conn, err
+}
+
+// connectAddr returns the host and port to connect to in place of the given
+// ones, as per ConnectTo.
+func (a *Attacker) connectAddr(host, port string) (string, string) {
+	if len(a.connectTo) == 0 {
+		return host, port
+	}
+
+	lower := strings.ToLower(host)
+	for _, key := range []string{net.JoinHostPort(lower, port), net.JoinHostPort(lower, ""), net.JoinHostPort("", port), ":"} {
+		to, ok := a.connectTo[key]
+		if !ok {
+			continue
+		}
+
+		toHost, toPort, err := net.SplitHostPort(to)
+		if err != nil {
+			toHost, toPort = to, ""
+		}
+
+		if toHost != "" {
+			host = toHost
+		}
+
+		if toPort != "" {
+			port = toPort
+		}
+
+		break
+	}
+
+	return host, port
+}
+
+// dialPort dials the given address with the given dialer, bound to the next
+// of the local ports of the Attacker, if it has any, or to the following ones
+// while they're in use.
+func (a *Attacker) dialPort(ctx context.Context, d *net.Dialer, network, addr string) (net.Conn, error) {
+	n := uint64(len(a.lports))
+	if n == 0 {
+		return d.DialContext(ctx, network, addr)
+	}
+
+	var laddr net.TCPAddr
+	if l, ok := d.LocalAddr.(*net.TCPAddr); ok && l != nil {
+		laddr = *l
+	}
+
+	var err error
+	for i := uint64(0); i < n; i++ {
+		bound, la := *d, laddr
+		la.Port = a.lports[(atomic.AddUint64(&a.lportIdx, 1)-1)%n]
+		bound.LocalAddr = &la
+
+		var conn net.Conn
+		conn, err = bound.DialContext(ctx, network, addr)
+		if err == nil || ctx.Err() != nil || !errors.Is(err, syscall.EADDRINUSE) && !errors.Is(err, syscall.EADDRNOTAVAIL) {
+			return conn, err
+		}
+	}
+
+	return nil, fmt.Errorf("all %d local ports are in use: %v", n, err)
+}
+
+// requestBody returns a copy of the body of the given request, read from its
+// GetBody, which bodies streamed from a BodySource are opened again by.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.GetBody == nil {
+		return nil, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	bs, err := ioutil.ReadAll(body)
+	if len(bs) == 0 {
+		bs = nil
+	}
+	return bs, err
+}
+
+// ipFamily returns the IP family of the given IP, "ipv4" or "ipv6".
+func ipFamily(ip net.IP) string {
+	if ip.To4() != nil {
+		return "ipv4"
+	}
+	return "ipv6"
+}
+
 // Attack reads its Targets from the passed Targeter and attacks them at
-// the rate specified by the Pacer. When the duration is zero the attack
-// runs until Stop is called. Results are sent to the returned channel as soon
-// as they arrive and will have their Attack field set to the given name.
+// the rate specified by the Pacer, until it's changed with SetPacer. When the
+// duration is zero the attack runs until Stop is called. Time spent paused
+// doesn't count towards the duration, while the ramp-down period set with
+// RampDownPeriod adds to it. Results are sent to the returned channel
+// as soon as they arrive and will have their Attack field set to the given name.
 func (a *Attacker) Attack(tr Targeter, p Pacer, du time.Duration, name string) <-chan *Result {
 	var wg sync.WaitGroup
 
@@ -276,7 +963,7 @@ func (a *Attacker) Attack(tr Targeter, p Pacer, du time.Duration, name string) <
 	ticks := make(chan struct{})
 	for i := uint64(0); i < workers; i++ {
 		wg.Add(1)
-		go a.attack(tr, name, &wg, ticks, results)
+		go a.attack(tr, name, i+1, &wg, ticks, results)
 	}
 
 	go func() {
@@ -284,14 +971,33 @@ func (a *Attacker) Attack(tr Targeter, p Pacer, du time.Duration, name string) <
 		defer wg.Wait()
 		defer close(ticks)
 
-		began, count := time.Now(), uint64(0)
+		// Pacers pace from when they're set, with the hits since then.
+		began, count := time.Now().Add(-a.resumeElapsed), a.resumeHits
+		paced, pacedCount := began, uint64(0)
+		ramping := false
 		for {
-			elapsed := time.Since(began)
-			if du > 0 && elapsed > du {
+			paused, stopped := a.waitResume()
+			if stopped {
 				return
 			}
+			began, paced = began.Add(paused), paced.Add(paused)
+			a.setProgress(began, count)
+
+			if next := a.takePacer(); next != nil && !ramping {
+				p, paced, pacedCount = next, time.Now(), count
+			}
+
+			elapsed := time.Since(began)
+			if !ramping && (du > 0 && elapsed > du || a.rampingDown()) {
+				rate := p.Rate(time.Since(paced))
+				if a.rampDown <= 0 || !(rate > 0) || math.IsInf(rate, 1) {
+					return
+				}
+				p, paced, pacedCount = rampDownPacer{rate, a.rampDown}, time.Now(), count
+				ramping = true
+			}
 
-			wait, stop := p.Pace(elapsed, count)
+			wait, stop := p.Pace(time.Since(paced), count-pacedCount)
 			if stop {
 				return
 			}
@@ -309,7 +1015,7 @@ func (a *Attacker) Attack(tr Targeter, p Pacer, du time.Duration, name string) <
 					// all workers are blocked. start one more and try again
 					workers++
 					wg.Add(1)
-					go a.attack(tr, name, &wg, ticks, results)
+					go a.attack(tr, name, workers, &wg, ticks, results)
 				}
 			}
 
@@ -335,36 +1041,191 @@ func (a *Attacker) Stop() {
 	}
 }
 
-func (a *Attacker) attack(tr Targeter, name string, workers *sync.WaitGroup, ticks <-chan struct{}, results chan<- *Result) {
+// RampDown stops the current attack after ramping its rate down over the
+// period set with RampDownPeriod, or at once without one, like Stop.
+func (a *Attacker) RampDown() {
+	if a.rampDown <= 0 {
+		a.Stop()
+		return
+	}
+
+	a.ctlmu.Lock()
+	defer a.ctlmu.Unlock()
+	if !a.rampingDown() {
+		close(a.rampch)
+	}
+}
+
+func (a *Attacker) rampingDown() bool {
+	select {
+	case <-a.rampch:
+		return true
+	default:
+		return false
+	}
+}
+
+// Pause pauses the current attack, until Resume is called, without closing
+// the open connections of its workers.
+func (a *Attacker) Pause() {
+	a.ctlmu.Lock()
+	defer a.ctlmu.Unlock()
+	if a.resume == nil {
+		a.resume = make(chan struct{})
+		a.pausedAt = time.Now()
+	}
+}
+
+// Resume resumes the current attack if paused.
+func (a *Attacker) Resume() {
+	a.ctlmu.Lock()
+	defer a.ctlmu.Unlock()
+	if a.resume != nil {
+		close(a.resume)
+		a.resume = nil
+	}
+}
+
+// Paused returns true if the current attack is paused.
+func (a *Attacker) Paused() bool {
+	a.ctlmu.Lock()
+	defer a.ctlmu.Unlock()
+	return a.resume != nil
+}
+
+// Progress returns the elapsed time of the current attack, without the time
+// spent paused, and its number of hits so far, which it can be resumed from
+// with ResumeAt.
+func (a *Attacker) Progress() (elapsed time.Duration, hits uint64) {
+	a.ctlmu.Lock()
+	defer a.ctlmu.Unlock()
+
+	if a.progBegan.IsZero() {
+		return a.resumeElapsed, a.resumeHits
+	}
+
+	now := time.Now()
+	if a.resume != nil {
+		now = a.pausedAt
+	}
+	return now.Sub(a.progBegan), a.progHits
+}
+
+func (a *Attacker) setProgress(began time.Time, hits uint64) {
+	a.ctlmu.Lock()
+	defer a.ctlmu.Unlock()
+	a.progBegan, a.progHits = began, hits
+}
+
+// SetPacer changes the Pacer of the current attack, which paces its hits from
+// when it's set on.
+func (a *Attacker) SetPacer(p Pacer) {
+	a.ctlmu.Lock()
+	defer a.ctlmu.Unlock()
+	a.pacer = p
+}
+
+func (a *Attacker) takePacer() Pacer {
+	a.ctlmu.Lock()
+	defer a.ctlmu.Unlock()
+	p := a.pacer
+	a.pacer = nil
+	return p
+}
+
+// waitResume waits for the attack to be resumed if paused, returning for how
+// long, or whether it was stopped or ramped down meanwhile, since paused
+// attacks have no rate to ramp down from.
+func (a *Attacker) waitResume() (time.Duration, bool) {
+	a.ctlmu.Lock()
+	resume := a.resume
+	a.ctlmu.Unlock()
+
+	if resume == nil {
+		return 0, false
+	}
+
+	began := time.Now()
+	select {
+	case <-resume:
+		return time.Since(began), false
+	case <-a.stopch:
+		return 0, true
+	case <-a.rampch:
+		return 0, true
+	}
+}
+
+// attack is the loop of the worker with the given ID, which hits the targets
+// at every tick.
+func (a *Attacker) attack(tr Targeter, name string, worker uint64, workers *sync.WaitGroup, ticks <-chan struct{}, results chan<- *Result) {
 	defer workers.Done()
 	for range ticks {
-		results <- a.hit(tr, name)
+		var tgt Target
+		res, err := a.try(tr, &tgt, name, worker, nil)
+		for a.retry != nil && res.Attempt < a.retries && a.retry(res, err) {
+			if !a.backoff(res.Attempt) {
+				break
+			}
+			res.Retried = true
+			results <- res
+			res, err = a.try(tr, &tgt, name, worker, res)
+		}
+		results <- res
 	}
 }
 
-func (a *Attacker) hit(tr Targeter, name string) *Result {
+// hit hits the next target of the given Targeter once, without retries.
+func (a *Attacker) hit(tr Targeter, name string, worker uint64) *Result {
+	var tgt Target
+	res, _ := a.try(tr, &tgt, name, worker, nil)
+	return res
+}
+
+// try makes an attempt at hitting the next target of the given Targeter,
+// which it reads into tgt, or at hitting tgt again, if it retries the given
+// previous attempt. It returns the Result of the attempt and the error it
+// failed with, if any.
+func (a *Attacker) try(tr Targeter, tgt *Target, name string, worker uint64, prev *Result) (*Result, error) {
 	var (
-		res = Result{Attack: name}
-		tgt Target
-		err error
+		res   = Result{Attack: name, Labels: a.labels, Worker: worker}
+		err   error
+		spans *hitSpans // Of the hit, if traced.
 	)
 
 	a.seqmu.Lock()
 	res.Timestamp = a.began.Add(time.Since(a.began))
-	res.Seq = a.seq
-	a.seq++
+	if prev == nil {
+		res.Seq = a.seq
+		a.seq++
+	}
 	a.seqmu.Unlock()
 
+	if prev != nil {
+		res.Seq, res.Attempt = prev.Seq, prev.Attempt+1
+		res.RetryDelay = res.Timestamp.Sub(prev.Timestamp) + prev.RetryDelay
+	}
+
 	defer func() {
-		res.Latency = time.Since(res.Timestamp)
+		if res.Latency = time.Since(res.Timestamp); a.excludeNeg {
+			res.Latency -= res.Negotiation
+		}
 		if err != nil {
 			res.Error = err.Error()
 		}
+		if spans != nil {
+			a.export(spans.finish(&res))
+		}
+		if a.afterHit != nil {
+			a.afterHit(&res)
+		}
 	}()
 
-	if err = tr(&tgt); err != nil {
-		a.Stop()
-		return &res
+	if prev == nil {
+		if err = tr(tgt); err != nil {
+			a.Stop()
+			return &res, err
+		}
 	}
 
 	res.Method = tgt.Method
@@ -372,39 +1233,184 @@ func (a *Attacker) hit(tr Targeter, name string) *Result {
 
 	req, err := tgt.Request()
 	if err != nil {
-		return &res
+		return &res, err
+	}
+
+	// Bodies streamed from sources hold open files until closed, which the
+	// client does with the requests it sends, but not with those failing first.
+	if tgt.BodySource != "" {
+		defer req.Body.Close()
+	}
+
+	if err = a.compress(tgt, req); err != nil {
+		return &res, err
 	}
 
-	if name != "" {
-		req.Header.Set("X-Vegeta-Attack", name)
+	if name != "" && a.atkHeader != "" {
+		req.Header.Set(a.atkHeader, name)
 	}
 
-	req.Header.Set("X-Vegeta-Seq", strconv.FormatUint(res.Seq, 10))
+	if a.seqHeader != "" {
+		req.Header.Set(a.seqHeader, strconv.FormatUint(res.Seq, 10))
+	}
+
+	if a.acceptEnc != "" && req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", a.acceptEnc)
+	}
 
 	if a.chunked {
 		req.TransferEncoding = append(req.TransferEncoding, "chunked")
 	}
 
-	r, err := a.client.Do(req)
+	if a.expect && req.Body != nil && req.ContentLength != 0 {
+		req.Header.Set("Expect", "100-continue")
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+			Got100Continue: func() { res.Continued = true },
+		}))
+	}
+
+	if a.traces != nil {
+		res.TraceID = a.traces.inject(req)
+	}
+
+	// Requests are revalidated by their URLs before hooks change them, e.g.
+	// to bust caches.
+	var revalidated string
+	if a.validators != nil {
+		revalidated = a.validators.revalidated(req)
+	}
+
+	if a.beforeHit != nil {
+		if err = a.beforeHit(tgt, req); err != nil {
+			return &res, err
+		}
+	}
+
+	if a.authorize != nil {
+		if err = a.authorize(req); err != nil {
+			return &res, err
+		}
+	}
+
+	if a.spans != nil {
+		if spans, req = startSpans(a.spans, req); spans != nil {
+			res.TraceID = spans.req.TraceID
+		}
+	}
+
+	// Requests are captured as sent, once compressed and changed by hooks.
+	if a.captureReq {
+		if res.RequestBody, err = requestBody(req); err != nil {
+			return &res, err
+		}
+		res.RequestHeaders = req.Header.Clone()
+	}
+
+	if a.recordConn {
+		var wait connWait
+		tunneled := a.tunneled(req)
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+			GetConn:      func(string) { wait.get() },
+			DNSStart:     func(httptrace.DNSStartInfo) { wait.dialStart() },
+			DNSDone:      func(httptrace.DNSDoneInfo) { wait.dialDone() },
+			ConnectStart: func(string, string) { wait.dialStart() },
+			ConnectDone: func(_, _ string, err error) {
+				wait.dialDone()
+				if tunneled && err == nil {
+					wait.tunnelStart()
+				}
+			},
+			TLSHandshakeStart: func() {
+				wait.tunnelDone()
+				wait.dialStart()
+			},
+			TLSHandshakeDone: func(tls.ConnectionState, error) { wait.dialDone() },
+			GotConn: func(info httptrace.GotConnInfo) {
+				res.RemoteAddr = info.Conn.RemoteAddr().String()
+				res.LocalAddr = info.Conn.LocalAddr().String()
+				res.ConnWait = wait.got()
+				res.Tunnel = wait.tunneled()
+				if addr, ok := info.Conn.RemoteAddr().(*net.TCPAddr); ok {
+					res.IPFamily = ipFamily(addr.IP)
+				}
+			},
+		}))
+	}
+
+	if a.ntlm != nil {
+		req = req.WithContext(context.WithValue(req.Context(), negotiationKey{}, &res.Negotiation))
+	}
+
+	client, err := a.clientFor(tgt)
 	if err != nil {
-		return &res
+		return &res, err
+	}
+
+	if a.jars != nil {
+		c := *client
+		if c.Jar, err = a.jarFor(tgt, worker); err != nil {
+			return &res, err
+		}
+		client = &c
+	}
+
+	if tgt.Redirects != nil {
+		c := *client
+		c.CheckRedirect = checkRedirect(*tgt.Redirects)
+		client = &c
+	}
+
+	var streamed *countingBody
+	if req.Body != nil && req.ContentLength == -1 {
+		streamed = &countingBody{ReadCloser: req.Body}
+		req.Body = streamed
+	}
+
+	r, err := client.Do(req)
+	if err != nil {
+		return &res, err
 	}
 	defer r.Body.Close()
 
-	body := io.Reader(r.Body)
+	var (
+		wire = &countingReader{r: r.Body}
+		body = io.Reader(wire)
+		dec  io.ReadCloser
+	)
+
+	if a.acceptEnc != "" {
+		var ok bool
+		if dec, ok, err = decoder(r.Header.Get("Content-Encoding"), wire); err != nil {
+			return &res, err
+		} else if ok {
+			body = dec
+		}
+	}
+
 	if a.maxBody >= 0 {
-		body = io.LimitReader(r.Body, a.maxBody)
+		body = io.LimitReader(body, a.maxBody)
+	}
+
+	res.Body, err = ioutil.ReadAll(body)
+
+	// Decoders may read ahead in the background until closed.
+	if dec != nil {
+		dec.Close()
 	}
 
-	if res.Body, err = ioutil.ReadAll(body); err != nil {
-		return &res
-	} else if _, err = io.Copy(ioutil.Discard, r.Body); err != nil {
-		return &res
+	if err != nil {
+		return &res, err
+	} else if _, err = io.Copy(ioutil.Discard, wire); err != nil {
+		return &res, err
 	}
 
-	res.BytesIn = uint64(len(res.Body))
+	if res.BytesIn = uint64(len(res.Body)); a.wireBytes {
+		res.BytesIn = wire.n
+	}
 
-	if req.ContentLength != -1 {
+	if streamed != nil {
+		res.BytesOut = streamed.count()
+	} else if req.ContentLength != -1 {
 		res.BytesOut = uint64(req.ContentLength)
 	}
 
@@ -414,5 +1420,202 @@ func (a *Attacker) hit(tr Targeter, name string) *Result {
 
 	res.Headers = r.Header
 
-	return &res
+	if a.validators != nil {
+		a.validators.store(revalidated, req, r)
+	}
+
+	a.assert(tgt, &res)
+
+	if a.recordTLS && r.TLS != nil {
+		res.TLSVersion = tlsVersionName(r.TLS.Version)
+		res.TLSCipherSuite = tlsCipherSuiteName(r.TLS.CipherSuite)
+		res.TLSProtocol = r.TLS.NegotiatedProtocol
+		res.TLSResumed = r.TLS.DidResume
+	}
+
+	if a.keepBody != nil && !a.keepBody(&res) {
+		res.Body = nil
+	} else if a.maxKept >= 0 && int64(len(res.Body)) > a.maxKept {
+		res.Body = res.Body[:a.maxKept:a.maxKept]
+	}
+
+	// Assertions and body conditions see all headers.
+	if len(a.recHeaders) > 0 {
+		res.Headers = make(http.Header, len(a.recHeaders))
+		for _, name := range a.recHeaders {
+			if vs, ok := r.Header[name]; ok {
+				res.Headers[name] = vs
+			}
+		}
+	}
+
+	return &res, err
+}
+
+// connWait measures how long a request waits for a connection: from when it
+// asks the pool of its host for one to when it gets one, less the time spent
+// dialing, resolving, tunneling and handshaking one for it, which may overlap,
+// e.g. when dual-stack addresses are raced.
+type connWait struct {
+	mu       sync.Mutex
+	asked    time.Time
+	dialing  int       // Number of dialing phases in progress.
+	since    time.Time // When the dialing phases in progress began.
+	dialed   time.Duration
+	tunnelAt time.Time // When the tunnel in progress through a proxy began.
+	tunnel   time.Duration
+}
+
+func (w *connWait) get() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.asked, w.dialed = time.Now(), 0
+}
+
+func (w *connWait) dialStart() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.dialing++; w.dialing == 1 {
+		w.since = time.Now()
+	}
+}
+
+func (w *connWait) dialDone() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.dialing == 0 {
+		return
+	} else if w.dialing--; w.dialing == 0 {
+		w.dialed += time.Since(w.since)
+	}
+}
+
+// tunnelStart begins the dialing phase of establishing a tunnel through a
+// proxy, from when the connection to it is made.
+func (w *connWait) tunnelStart() {
+	w.dialStart()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.tunnelAt = time.Now()
+}
+
+// tunnelDone ends the tunnel in progress, if any, when the TLS handshake
+// through it begins.
+func (w *connWait) tunnelDone() {
+	w.mu.Lock()
+	at := w.tunnelAt
+	if !at.IsZero() {
+		w.tunnel, w.tunnelAt = time.Since(at), time.Time{}
+	}
+	w.mu.Unlock()
+
+	if !at.IsZero() {
+		w.dialDone()
+	}
+}
+
+// tunneled returns how long establishing the tunnel of the connection just
+// got took, which is zero if it didn't establish one.
+func (w *connWait) tunneled() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.tunnel
+}
+
+// got returns the time waited for the connection just got, which is zero
+// if it wasn't asked for from the pool, e.g. with HTTP/2.
+func (w *connWait) got() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.asked.IsZero() {
+		return 0
+	}
+
+	now := time.Now()
+	wait := now.Sub(w.asked) - w.dialed
+	if w.dialing > 0 {
+		wait -= now.Sub(w.since)
+	}
+
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// ParseTLSVersion parses a TLS version, as recorded with RecordTLS, e.g.
+// "TLS 1.2", or only its number, e.g. "1.2".
+func ParseTLSVersion(s string) (uint16, error) {
+	num := strings.TrimSpace(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "TLS"))
+	for v, name := range tlsVersions {
+		if num == strings.TrimPrefix(name, "TLS ") {
+			return v, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown TLS version %q", s)
+}
+
+func tlsVersionName(v uint16) string {
+	if name, ok := tlsVersions[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04X", v)
+}
+
+// tlsCipherSuites are the names of the cipher suites implemented by crypto/tls,
+// as registered by IANA.
+var tlsCipherSuites = map[uint16]string{
+	tls.TLS_RSA_WITH_RC4_128_SHA:                "TLS_RSA_WITH_RC4_128_SHA",
+	tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA:           "TLS_RSA_WITH_3DES_EDE_CBC_SHA",
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA:            "TLS_RSA_WITH_AES_128_CBC_SHA",
+	tls.TLS_RSA_WITH_AES_256_CBC_SHA:            "TLS_RSA_WITH_AES_256_CBC_SHA",
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA256:         "TLS_RSA_WITH_AES_128_CBC_SHA256",
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256:         "TLS_RSA_WITH_AES_128_GCM_SHA256",
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384:         "TLS_RSA_WITH_AES_256_GCM_SHA384",
+	tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA:        "TLS_ECDHE_ECDSA_WITH_RC4_128_SHA",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA:    "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA:    "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA",
+	tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA:          "TLS_ECDHE_RSA_WITH_RC4_128_SHA",
+	tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA:     "TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA",
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA:      "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA",
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA:      "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256: "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256",
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256:   "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256",
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:   "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384:   "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384: "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305:    "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305:  "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+	tls.TLS_AES_128_GCM_SHA256:                  "TLS_AES_128_GCM_SHA256",
+	tls.TLS_AES_256_GCM_SHA384:                  "TLS_AES_256_GCM_SHA384",
+	tls.TLS_CHACHA20_POLY1305_SHA256:            "TLS_CHACHA20_POLY1305_SHA256",
+}
+
+// ParseTLSCipherSuite parses the IANA name of a cipher suite implemented by
+// crypto/tls, as recorded with RecordTLS, e.g.
+// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256".
+func ParseTLSCipherSuite(name string) (uint16, error) {
+	for id, n := range tlsCipherSuites {
+		if strings.EqualFold(n, strings.TrimSpace(name)) {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown TLS cipher suite %q", name)
+}
+
+func tlsCipherSuiteName(id uint16) string {
+	if name, ok := tlsCipherSuites[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04X", id)
 }
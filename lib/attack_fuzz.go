@@ -61,7 +61,7 @@ func FuzzAttackerTCP(fuzz []byte) int {
 		Timeout(time.Second),
 		KeepAlive(false),
 	)
-	result := attacker.hit(targeter, "fuzz")
+	result := attacker.hit(targeter, "fuzz", 1)
 	if result.Error != "" {
 		return 0
 	}
@@ -121,7 +121,7 @@ func FuzzAttackerHTTP(fuzz []byte) int {
 		Timeout(time.Second),
 		KeepAlive(false),
 	)
-	result := attacker.hit(targeter, "fuzz")
+	result := attacker.hit(targeter, "fuzz", 1)
 	if result.Error != "" {
 		return 0
 	}
@@ -2,8 +2,11 @@ package vegeta
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -16,8 +19,12 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 func TestAttackRate(t *testing.T) {
@@ -62,6 +69,193 @@ func TestAttackDuration(t *testing.T) {
 	}
 }
 
+func TestAttackPause(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+	defer server.Close()
+
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+	atk := NewAttacker()
+	rate := Rate{Freq: 100, Per: time.Second}
+
+	var (
+		hits   uint64
+		last   time.Time
+		maxGap time.Duration
+	)
+
+	began := time.Now()
+	for res := range atk.Attack(tr, rate, 500*time.Millisecond, "") {
+		if hits++; hits == 10 {
+			atk.Pause()
+			if !atk.Paused() {
+				t.Fatal("attack isn't paused")
+			}
+			time.AfterFunc(300*time.Millisecond, atk.Resume)
+		}
+		if gap := res.Timestamp.Sub(last); !last.IsZero() && gap > maxGap {
+			maxGap = gap
+		}
+		last = res.Timestamp
+	}
+
+	if got, want := hits, uint64(50); got != want {
+		t.Errorf("got %v hits, want: %v", got, want)
+	} else if maxGap < 250*time.Millisecond {
+		t.Errorf("got max gap of %s between hits, want one of the pause", maxGap)
+	} else if took := time.Since(began); took < 750*time.Millisecond {
+		t.Errorf("attack took %s, want its duration and pause", took)
+	}
+}
+
+func TestAttackPauseRampDown(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+	defer server.Close()
+
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+	atk := NewAttacker(RampDownPeriod(time.Second))
+	rate := Rate{Freq: 100, Per: time.Second}
+
+	var hits uint64
+	began := time.Now()
+	for range atk.Attack(tr, rate, 10*time.Second, "") {
+		if hits++; hits == 10 {
+			atk.Pause()
+			time.AfterFunc(100*time.Millisecond, atk.RampDown)
+		}
+	}
+
+	if took := time.Since(began); took > time.Second {
+		t.Errorf("paused attack took %s to ramp down, want it stopped", took)
+	} else if hits > 11 {
+		t.Errorf("got %d hits, want none after the pause", hits)
+	}
+}
+
+func TestAttackSetPacer(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+	defer server.Close()
+
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+	atk := NewAttacker()
+
+	var hits uint64
+	time.AfterFunc(500*time.Millisecond, func() {
+		atk.SetPacer(Rate{Freq: 200, Per: time.Second})
+	})
+
+	for range atk.Attack(tr, Rate{Freq: 20, Per: time.Second}, time.Second, "") {
+		hits++
+	}
+
+	// 10 hits in the first half second and 100 in the second one.
+	if hits < 100 || hits > 115 {
+		t.Errorf("got %v hits, want about 110", hits)
+	}
+}
+
+func TestAttackRampDown(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+	defer server.Close()
+
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+	rate := Rate{Freq: 100, Per: time.Second}
+
+	// Ramping down from 100/s over 1s adds 50 hits.
+	t.Run("duration", func(t *testing.T) {
+		t.Parallel()
+		atk := NewAttacker(RampDownPeriod(time.Second))
+
+		var hits uint64
+		for range atk.Attack(tr, rate, 500*time.Millisecond, "") {
+			hits++
+		}
+
+		if hits < 95 || hits > 105 {
+			t.Errorf("got %v hits, want about 100", hits)
+		}
+	})
+
+	t.Run("RampDown", func(t *testing.T) {
+		t.Parallel()
+		atk := NewAttacker(RampDownPeriod(time.Second))
+		time.AfterFunc(500*time.Millisecond, atk.RampDown)
+
+		var hits uint64
+		began := time.Now()
+		for range atk.Attack(tr, rate, 0, "") {
+			hits++
+		}
+
+		if hits < 95 || hits > 105 {
+			t.Errorf("got %v hits, want about 100", hits)
+		} else if took := time.Since(began); took < 1400*time.Millisecond {
+			t.Errorf("attack took %s, want about 1.5s", took)
+		}
+	})
+
+	t.Run("no period", func(t *testing.T) {
+		t.Parallel()
+		atk := NewAttacker()
+		time.AfterFunc(500*time.Millisecond, atk.RampDown)
+
+		var hits uint64
+		for range atk.Attack(tr, rate, 0, "") {
+			hits++
+		}
+
+		if hits < 45 || hits > 55 {
+			t.Errorf("got %v hits, want about 50", hits)
+		}
+	})
+}
+
+func TestAttackResumeAt(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+	defer server.Close()
+
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+	atk := NewAttacker(ResumeAt(500*time.Millisecond, 50))
+	rate := Rate{Freq: 100, Per: time.Second}
+
+	if elapsed, hits := atk.Progress(); elapsed != 500*time.Millisecond || hits != 50 {
+		t.Errorf("got progress of %s and %d hits before the attack, want 500ms and 50", elapsed, hits)
+	}
+
+	var seqs []uint64
+	began := time.Now()
+	for res := range atk.Attack(tr, rate, time.Second, "") {
+		seqs = append(seqs, res.Seq)
+	}
+
+	// The remaining half of the attack at 100/s, numbered on from 50.
+	if n := len(seqs); n < 48 || n > 52 {
+		t.Errorf("got %d hits, want about 50", n)
+	} else if seqs[0] != 50 {
+		t.Errorf("got first seq %d, want 50", seqs[0])
+	} else if took := time.Since(began); took > 700*time.Millisecond {
+		t.Errorf("attack took %s, want about 500ms", took)
+	}
+
+	if elapsed, hits := atk.Progress(); elapsed < time.Second || hits < 98 {
+		t.Errorf("got progress of %s and %d hits after the attack, want about 1s and 100", elapsed, hits)
+	}
+}
+
 func TestTLSConfig(t *testing.T) {
 	t.Parallel()
 	atk := NewAttacker()
@@ -82,7 +276,7 @@ func TestRedirects(t *testing.T) {
 	redirects := 2
 	atk := NewAttacker(Redirects(redirects))
 	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
-	res := atk.hit(tr, "")
+	res := atk.hit(tr, "", 1)
 	want := fmt.Sprintf("stopped after %d redirects", redirects)
 	if got := res.Error; !strings.HasSuffix(got, want) {
 		t.Fatalf("want: '%v' in '%v'", want, got)
@@ -99,7 +293,7 @@ func TestNoFollow(t *testing.T) {
 	defer server.Close()
 	atk := NewAttacker(Redirects(NoFollow))
 	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
-	res := atk.hit(tr, "")
+	res := atk.hit(tr, "", 1)
 	if res.Error != "" {
 		t.Fatalf("got err: %v", res.Error)
 	}
@@ -108,6 +302,91 @@ func TestNoFollow(t *testing.T) {
 	}
 }
 
+func TestTargetRedirects(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/login" {
+				http.Redirect(w, r, "/", 302)
+			}
+		}),
+	)
+	defer server.Close()
+
+	atk := NewAttacker(Redirects(NoFollow))
+	hit := func(redirects *int) *Result {
+		return atk.hit(NewStaticTargeter(Target{Method: "GET", URL: server.URL + "/login", Redirects: redirects}), "", 1)
+	}
+
+	follow, fail := 1, 0
+	if res := hit(nil); res.Code != 302 || res.Error != "" {
+		t.Errorf("got code %d (%s), want the Attacker's NoFollow", res.Code, res.Error)
+	}
+	if res := hit(&follow); res.Code != 200 || res.Error != "" {
+		t.Errorf("got code %d (%s), want the redirect followed", res.Code, res.Error)
+	}
+	if res := hit(&fail); !strings.HasSuffix(res.Error, "stopped after 0 redirects") {
+		t.Errorf("got error %q, want the redirect failed", res.Error)
+	}
+}
+
+func TestProxyTunnel(t *testing.T) {
+	t.Parallel()
+
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	const delay = 50 * time.Millisecond
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "CONNECT" {
+			http.Error(w, "want CONNECT", http.StatusMethodNotAllowed)
+			return
+		} else if r.Header.Get("Proxy-Authorization") != "Basic dXNlcjpzZWNyZXQ=" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer upstream.Close()
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		time.Sleep(delay)
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go io.Copy(upstream, conn)
+		io.Copy(conn, upstream)
+	}))
+	defer proxy.Close()
+
+	hit := func(user *url.Userinfo) *Result {
+		u, _ := url.Parse(proxy.URL)
+		u.User = user
+		atk := NewAttacker(Proxy(http.ProxyURL(u)), RecordConnections(true))
+		return atk.hit(NewStaticTargeter(Target{Method: "GET", URL: target.URL}), "", 1)
+	}
+
+	res := hit(url.UserPassword("user", "secret"))
+	if res.Error != "" || res.Code != http.StatusOK {
+		t.Fatalf("got code %d (%s), want the target's response through the tunnel", res.Code, res.Error)
+	} else if res.Tunnel < delay {
+		t.Errorf("got tunnel time %s, want at least %s", res.Tunnel, delay)
+	} else if res.ConnWait >= delay {
+		t.Errorf("got connection wait %s, want it without the tunnel time", res.ConnWait)
+	}
+
+	if res = hit(url.UserPassword("user", "wrong")); res.Error == "" || res.Tunnel != 0 {
+		t.Errorf("got error %q with tunnel time %s, want the proxy's rejection", res.Error, res.Tunnel)
+	}
+}
+
 func TestTimeout(t *testing.T) {
 	t.Parallel()
 	server := httptest.NewServer(
@@ -118,7 +397,7 @@ func TestTimeout(t *testing.T) {
 	defer server.Close()
 	atk := NewAttacker(Timeout(10 * time.Millisecond))
 	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
-	res := atk.hit(tr, "")
+	res := atk.hit(tr, "", 1)
 
 	want := "Client.Timeout exceeded while awaiting headers"
 	if got := res.Error; !strings.Contains(got, want) {
@@ -148,7 +427,228 @@ func TestLocalAddr(t *testing.T) {
 	defer server.Close()
 	atk := NewAttacker(LocalAddr(*addr))
 	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
-	atk.hit(tr, "")
+	atk.hit(tr, "", 1)
+}
+
+func TestLocalAddrs(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu    sync.Mutex
+		hosts []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		mu.Lock()
+		hosts = append(hosts, host)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	addrs := []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}, {IP: net.ParseIP("127.0.0.2")}}
+	atk := NewAttacker(LocalAddrs(addrs...), KeepAlive(false))
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+
+	for i := 0; i < 4; i++ {
+		if res := atk.hit(tr, "", 1); strings.Contains(res.Error, "bind") {
+			t.Skipf("can't bind to loopback addresses: %s", res.Error)
+		} else if res.Error != "" {
+			t.Fatal(res.Error)
+		}
+	}
+
+	want := []string{"127.0.0.1", "127.0.0.2", "127.0.0.1", "127.0.0.2"}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Errorf("got connections from %v, want %v", hosts, want)
+	}
+}
+
+func TestLocalPorts(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// The port of a listener is in use, while the one of a closed one is free.
+	used, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer used.Close()
+
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	free.Close()
+
+	usedPort := used.Addr().(*net.TCPAddr).Port
+	freePort := free.Addr().(*net.TCPAddr).Port
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+
+	atk := NewAttacker(LocalPorts(usedPort, freePort), RecordConnections(true))
+	res := atk.hit(tr, "", 1)
+	if res.Error != "" {
+		t.Fatal(res.Error)
+	} else if _, port, _ := net.SplitHostPort(res.LocalAddr); port != strconv.Itoa(freePort) {
+		t.Errorf("got local port %s, want %d", port, freePort)
+	}
+
+	atk = NewAttacker(LocalPorts(usedPort))
+	if res = atk.hit(tr, "", 1); !strings.Contains(res.Error, "in use") {
+		t.Errorf("got error %q, want one about ports in use", res.Error)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+
+	res := &net.Resolver{
+		PreferGo: true,
+		Dial: func(context.Context, string, string) (net.Conn, error) {
+			return nil, errors.New("custom resolver")
+		},
+	}
+
+	atk := NewAttacker(Resolver(res))
+	tr := NewStaticTargeter(Target{Method: "GET", URL: "http://vegeta.invalid"})
+	if res := atk.hit(tr, "", 1); !strings.Contains(res.Error, "custom resolver") {
+		t.Errorf("got error %q, want one of the custom resolver", res.Error)
+	}
+}
+
+func TestHostAliases(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	atk := NewAttacker(HostAliases(map[string]string{"API.example.com": "127.0.0.1"}))
+	host := "api.example.com:" + port
+	tr := NewStaticTargeter(Target{Method: "GET", URL: "http://" + host})
+
+	res := atk.hit(tr, "", 1)
+	if res.Error != "" {
+		t.Fatal(res.Error)
+	} else if got := string(res.Body); got != host {
+		t.Errorf("got Host header %q, want %q", got, host)
+	}
+}
+
+func TestConnectTo(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		opts []func(*Attacker)
+		host string
+	}{
+		{opts: []func(*Attacker){ConnectTo(map[string]string{"API.example.com:80": "127.0.0.1:" + port})}, host: "api.example.com:80"},
+		{opts: []func(*Attacker){ConnectTo(map[string]string{":81": "127.0.0.1:" + port})}, host: "api.example.com:81"},
+		{opts: []func(*Attacker){ConnectTo(map[string]string{"api.example.com:": "127.0.0.1:" + port})}, host: "api.example.com:82"},
+		{
+			opts: []func(*Attacker){
+				ConnectTo(map[string]string{"api.example.com:83": "backend.example.com:" + port}),
+				HostAliases(map[string]string{"backend.example.com": "127.0.0.1"}),
+			},
+			host: "api.example.com:83",
+		},
+		{
+			opts: []func(*Attacker){
+				ConnectTo(map[string]string{"api.example.com:84": ":" + port}),
+				HostAliases(map[string]string{"api.example.com": "127.0.0.1"}),
+			},
+			host: "api.example.com:84",
+		},
+	} {
+		atk := NewAttacker(tc.opts...)
+		res := atk.hit(NewStaticTargeter(Target{Method: "GET", URL: "http://" + tc.host}), "", 1)
+		if res.Error != "" {
+			t.Errorf("%s: %s", tc.host, res.Error)
+		} else if got := string(res.Body); got != tc.host {
+			t.Errorf("got Host header %q, want %q", got, tc.host)
+		}
+	}
+}
+
+func TestIPVersion(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		opts []func(*Attacker)
+		url  string
+		err  bool
+	}{
+		{opts: []func(*Attacker){IPVersion(4)}, url: "http://localhost:" + port},
+		{opts: []func(*Attacker){IPVersion(4), DNSRefresh(time.Minute)}, url: "http://localhost:" + port},
+		{opts: []func(*Attacker){IPVersion(6)}, url: "http://127.0.0.1:" + port, err: true},
+		{opts: []func(*Attacker){IPVersion(6), DNSRefresh(time.Minute)}, url: "http://127.0.0.1:" + port, err: true},
+	} {
+		atk := NewAttacker(append(tc.opts, RecordConnections(true))...)
+		res := atk.hit(NewStaticTargeter(Target{Method: "GET", URL: tc.url}), "", 1)
+		if tc.err {
+			if res.Error == "" {
+				t.Errorf("%s: got no error connecting over IPv6", tc.url)
+			}
+		} else if res.Error != "" {
+			t.Errorf("%s: %s", tc.url, res.Error)
+		} else if got, want := res.IPFamily, "ipv4"; got != want {
+			t.Errorf("%s: got IP family %q, want %q", tc.url, got, want)
+		}
+	}
+
+	atk := NewAttacker(FallbackDelay(-1))
+	if got, want := atk.dialer.FallbackDelay, time.Duration(-1); got != want {
+		t.Errorf("got fallback delay %v, want %v", got, want)
+	}
+}
+
+func TestSocketOptions(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	atk := NewAttacker(NoDelay(false), SocketBuffers(64<<10, 32<<10), TCPKeepAlive(time.Minute))
+	if !atk.delay || atk.sndbuf != 64<<10 || atk.rcvbuf != 32<<10 {
+		t.Fatalf("got delay %t, send buffer %d, receive buffer %d", atk.delay, atk.sndbuf, atk.rcvbuf)
+	} else if got, want := atk.dialer.KeepAlive, time.Minute; got != want {
+		t.Fatalf("got keep-alive interval %v, want %v", got, want)
+	}
+
+	res := atk.hit(NewStaticTargeter(Target{Method: "GET", URL: server.URL}), "", 1)
+	if res.Error != "" {
+		t.Fatal(res.Error)
+	}
+
+	if got, want := NewAttacker(TCPKeepAlive(0)).dialer.KeepAlive, 30*time.Second; got != want {
+		t.Errorf("got default keep-alive interval %v, want %v", got, want)
+	}
 }
 
 func TestKeepAlive(t *testing.T) {
@@ -182,7 +682,7 @@ func TestStatusCodeErrors(t *testing.T) {
 	defer server.Close()
 	atk := NewAttacker()
 	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
-	res := atk.hit(tr, "")
+	res := atk.hit(tr, "", 1)
 	if got, want := res.Error, "400 Bad Request"; got != want {
 		t.Fatalf("got: %v, want: %v", got, want)
 	}
@@ -192,7 +692,7 @@ func TestBadTargeterError(t *testing.T) {
 	t.Parallel()
 	atk := NewAttacker()
 	tr := func(*Target) error { return io.EOF }
-	res := atk.hit(tr, "")
+	res := atk.hit(tr, "", 1)
 	if got, want := res.Error, io.EOF.Error(); got != want {
 		t.Fatalf("got: %v, want: %v", got, want)
 	}
@@ -210,12 +710,436 @@ func TestResponseBodyCapture(t *testing.T) {
 	defer server.Close()
 	atk := NewAttacker()
 	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
-	res := atk.hit(tr, "")
+	res := atk.hit(tr, "", 1)
 	if got := res.Body; !bytes.Equal(got, want) {
 		t.Fatalf("got: %v, want: %v", got, want)
 	}
 }
 
+func TestKeepBody(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			code, _ := strconv.Atoi(r.URL.Query().Get("code"))
+			w.WriteHeader(code)
+			w.Write([]byte("VEGETA"))
+		}),
+	)
+	defer server.Close()
+
+	failed := func(r *Result) bool { return r.Error != "" }
+	for _, tc := range []struct {
+		code int
+		max  int64
+		want []byte
+	}{
+		{200, -1, nil},
+		{500, -1, []byte("VEGETA")},
+		{500, 3, []byte("VEG")},
+	} {
+		atk := NewAttacker(KeepBody(failed, tc.max))
+		tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL + "?code=" + strconv.Itoa(tc.code)})
+		res := atk.hit(tr, "", 1)
+		if got := res.Body; !bytes.Equal(got, tc.want) {
+			t.Errorf("code %d, max %d: got body %q, want %q", tc.code, tc.max, got, tc.want)
+		}
+		if got, want := res.BytesIn, uint64(6); got != want {
+			t.Errorf("code %d, max %d: got bytes in %d, want %d", tc.code, tc.max, got, want)
+		}
+	}
+}
+
+func TestCaptureRequest(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	body := []byte(`{"id":1}`)
+	hdr := http.Header{"Content-Type": {"application/json"}}
+	tr := NewStaticTargeter(Target{Method: "POST", URL: server.URL, Body: body, Header: hdr})
+
+	res := NewAttacker(CaptureRequest(true)).hit(tr, "capture", 1)
+	if got := res.RequestBody; !bytes.Equal(got, body) {
+		t.Errorf("got request body %q, want %q", got, body)
+	}
+
+	for k, want := range map[string]string{
+		"Content-Type":    "application/json",
+		"X-Vegeta-Attack": "capture",
+		"X-Vegeta-Seq":    "0",
+	} {
+		if got := res.RequestHeaders.Get(k); got != want {
+			t.Errorf("got request header %s: %q, want %q", k, got, want)
+		}
+	}
+
+	// Bodies are captured as sent, once compressed.
+	res = NewAttacker(CaptureRequest(true), CompressBody("gzip")).hit(tr, "", 1)
+	zr, err := gzip.NewReader(bytes.NewReader(res.RequestBody))
+	if err != nil {
+		t.Fatalf("got request body %q, want it gzipped: %v", res.RequestBody, err)
+	}
+	if got, _ := ioutil.ReadAll(zr); !bytes.Equal(got, body) {
+		t.Errorf("got decompressed request body %q, want %q", got, body)
+	}
+
+	if res = NewAttacker().hit(tr, "", 1); res.RequestBody != nil || res.RequestHeaders != nil {
+		t.Errorf("got captured request without CaptureRequest: %q %v", res.RequestBody, res.RequestHeaders)
+	}
+}
+
+func TestAuthorizer(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+	atk := NewAttacker(Authorizer(func(req *http.Request) error {
+		if req.Header.Get("X-Vegeta-Seq") == "1" {
+			return errors.New("no token")
+		}
+		req.Header.Set("Authorization", "Bearer tok")
+		return nil
+	}))
+
+	if res := atk.hit(tr, "", 1); string(res.Body) != "Bearer tok" {
+		t.Errorf("got Authorization %q, want %q", res.Body, "Bearer tok")
+	}
+
+	if res := atk.hit(tr, "", 1); res.Error != "no token" || res.Code != 0 {
+		t.Errorf("got error %q with code %d, want the unsent request's error", res.Error, res.Code)
+	}
+
+	atk = NewAttacker(
+		Authorizer(func(req *http.Request) error {
+			req.Header.Set("Authorization", "Bearer")
+			return nil
+		}),
+		Authorizer(func(req *http.Request) error {
+			req.Header.Set("Authorization", req.Header.Get("Authorization")+" tok")
+			return nil
+		}),
+	)
+
+	if res := atk.hit(tr, "", 1); string(res.Body) != "Bearer tok" {
+		t.Errorf("got Authorization %q from chained Authorizers, want %q", res.Body, "Bearer tok")
+	}
+}
+
+func TestLabels(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	labels := map[string]string{"build": "1234", "region": "eu-west-1"}
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+
+	res := NewAttacker(Labels(labels)).hit(tr, "", 1)
+	if !reflect.DeepEqual(res.Labels, labels) {
+		t.Errorf("got labels %v, want %v", res.Labels, labels)
+	}
+}
+
+func TestRecordHeaders(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Cache", "HIT")
+		w.Header().Add("Server-Timing", "db;dur=53")
+		w.Header().Add("Server-Timing", "app;dur=47")
+		w.Header().Set("X-Backend", "b1")
+	}))
+	defer server.Close()
+
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+
+	// Assertions see all headers.
+	backend, err := ParseAssertion("header.X-Backend == b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	atk := NewAttacker(RecordHeaders("x-cache", "Server-Timing", "X-Missing"), Assertions(backend))
+	res := atk.hit(tr, "", 1)
+	want := http.Header{"X-Cache": {"HIT"}, "Server-Timing": {"db;dur=53", "app;dur=47"}}
+	if res.Error != "" || !reflect.DeepEqual(res.Headers, want) {
+		t.Errorf("got headers %v (%s), want %v", res.Headers, res.Error, want)
+	}
+
+	if res = NewAttacker().hit(tr, "", 1); res.Headers.Get("X-Backend") != "b1" {
+		t.Errorf("got headers %v without RecordHeaders, want all of them", res.Headers)
+	}
+}
+
+func TestExpectContinue(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Reading the body sends the interim response.
+		if r.URL.Path == "/upload" {
+			ioutil.ReadAll(r.Body)
+		} else {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		}
+		w.Write([]byte(r.Header.Get("Expect")))
+	}))
+	defer server.Close()
+
+	hit := func(atk *Attacker, path string, body []byte) *Result {
+		return atk.hit(NewStaticTargeter(Target{Method: "POST", URL: server.URL + path, Body: body}), "", 1)
+	}
+
+	atk := NewAttacker(ExpectContinue(time.Second))
+	if res := hit(atk, "/upload", []byte("big")); !res.Continued || string(res.Body) != "100-continue" {
+		t.Errorf("got continued %t with Expect %q, want the interim response", res.Continued, res.Body)
+	}
+
+	if res := hit(atk, "/reject", []byte("big")); res.Continued || res.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got continued %t with code %d, want the final response only", res.Continued, res.Code)
+	}
+
+	if res := hit(atk, "/upload", nil); res.Continued || len(res.Body) != 0 {
+		t.Errorf("got continued %t with Expect %q, want no Expect without a body", res.Continued, res.Body)
+	}
+
+	if res := hit(NewAttacker(), "/upload", []byte("big")); res.Continued || len(res.Body) != 0 {
+		t.Errorf("got continued %t with Expect %q without ExpectContinue", res.Continued, res.Body)
+	}
+}
+
+func TestRecordConnections(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+	atk := NewAttacker(RecordConnections(true), KeepAlive(true))
+
+	first, second := atk.hit(tr, "", 1), atk.hit(tr, "", 1)
+	if got, want := first.RemoteAddr, server.Listener.Addr().String(); got != want {
+		t.Errorf("got remote address %q, want %q", got, want)
+	}
+
+	if first.LocalAddr == "" || first.LocalAddr != second.LocalAddr {
+		t.Errorf("got local addresses %q and %q, want the same reused connection", first.LocalAddr, second.LocalAddr)
+	}
+}
+
+func TestConnWait(t *testing.T) {
+	t.Parallel()
+
+	const delay = 100 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+	}))
+	defer server.Close()
+
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+	atk := NewAttacker(RecordConnections(true), MaxConnections(1))
+
+	// With a single connection, one of two concurrent requests waits for the
+	// other to complete.
+	results := make(chan *Result, 2)
+	for i := 0; i < 2; i++ {
+		go func() { results <- atk.hit(tr, "", 1) }()
+	}
+
+	first, second := <-results, <-results
+	if first.Error != "" || second.Error != "" {
+		t.Fatalf("got errors %q and %q", first.Error, second.Error)
+	}
+
+	if first.ConnWait >= delay/2 {
+		t.Errorf("got connection wait %v of the first request, want one shorter than %v", first.ConnWait, delay/2)
+	}
+
+	if second.ConnWait < delay/2 {
+		t.Errorf("got connection wait %v of the second request, want one of at least %v", second.ConnWait, delay/2)
+	}
+}
+
+func TestRecordTLS(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+	res := NewAttacker(RecordTLS(true)).hit(tr, "", 1)
+	if res.Error != "" {
+		t.Fatal(res.Error)
+	}
+
+	if got, want := res.TLSVersion, "TLS 1.3"; got != want {
+		t.Errorf("got TLS version %q, want %q", got, want)
+	}
+
+	if got := res.TLSCipherSuite; !strings.HasPrefix(got, "TLS_") {
+		t.Errorf("got TLS cipher suite %q, want a known name", got)
+	}
+
+	if res = NewAttacker().hit(tr, "", 1); res.TLSVersion != "" || res.TLSCipherSuite != "" {
+		t.Errorf("got TLS details without RecordTLS: %q %q", res.TLSVersion, res.TLSCipherSuite)
+	}
+}
+
+func TestTLSVersions(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	suite, err := ParseTLSCipherSuite("tls_ecdhe_rsa_with_aes_256_gcm_sha384")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	max, err := ParseTLSVersion("1.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+	atk := NewAttacker(TLSVersions(0, max), TLSCipherSuites(suite), RecordTLS(true))
+	if res := atk.hit(tr, "", 1); res.Error != "" {
+		t.Fatal(res.Error)
+	} else if res.TLSVersion != "TLS 1.2" || res.TLSCipherSuite != "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384" {
+		t.Errorf("got %s with %s, want TLS 1.2 with the given cipher suite", res.TLSVersion, res.TLSCipherSuite)
+	}
+
+	min, err := ParseTLSVersion("TLS 1.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	atk = NewAttacker(TLSVersions(min, 0), RecordTLS(true))
+	if res := atk.hit(tr, "", 1); res.TLSVersion != "TLS 1.3" {
+		t.Errorf("got %s, want TLS 1.3", res.TLSVersion)
+	}
+
+	if DefaultTLSConfig.MinVersion != 0 || DefaultTLSConfig.CipherSuites != nil {
+		t.Error("TLSVersions or TLSCipherSuites changed the default TLS config")
+	}
+
+	for _, v := range []string{"tls1.0", "TLS 1.1", "1.2"} {
+		if _, err := ParseTLSVersion(v); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if _, err := ParseTLSVersion("1.4"); err == nil {
+		t.Error("got no error parsing TLS 1.4")
+	}
+
+	if _, err := ParseTLSCipherSuite("TLS_NULL"); err == nil {
+		t.Error("got no error parsing an unknown cipher suite")
+	}
+}
+
+func TestTLSKeyLog(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var keyLog bytes.Buffer
+	atk := NewAttacker(TLSConfig(&tls.Config{InsecureSkipVerify: true}), TLSKeyLog(&keyLog))
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+	if res := atk.hit(tr, "", 1); res.Error != "" {
+		t.Fatal(res.Error)
+	}
+
+	if !strings.HasPrefix(keyLog.String(), "CLIENT_HANDSHAKE_TRAFFIC_SECRET ") {
+		t.Errorf("got key log %q, want TLS 1.3 secrets", keyLog.String())
+	}
+}
+
+func TestServerName(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.ServerName))
+	}))
+	defer server.Close()
+
+	atk := NewAttacker(TLSConfig(&tls.Config{InsecureSkipVerify: true}), ServerName("goku"), HTTP2(true))
+	for _, tc := range []struct {
+		tgt  Target
+		want string
+	}{
+		{tgt: Target{Method: "GET", URL: server.URL}, want: "goku"},
+		{tgt: Target{Method: "GET", URL: server.URL, SNI: "vegeta"}, want: "vegeta"},
+		{tgt: Target{Method: "GET", URL: server.URL, SNI: "gohan"}, want: "gohan"},
+		{tgt: Target{Method: "GET", URL: server.URL}, want: "goku"},
+	} {
+		res := atk.hit(NewStaticTargeter(tc.tgt), "", 1)
+		if res.Error != "" {
+			t.Fatal(res.Error)
+		} else if got := string(res.Body); got != tc.want {
+			t.Errorf("got server name %q, want %q", got, tc.want)
+		}
+	}
+
+	if DefaultTLSConfig.ServerName != "" {
+		t.Error("ServerName changed the default TLS config")
+	}
+}
+
+func TestTLSResumption(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+	for _, enabled := range []bool{false, true} {
+		atk := NewAttacker(TLSResumption(enabled), KeepAlive(false), RecordTLS(true))
+		for i, want := range []bool{false, enabled, enabled} {
+			res := atk.hit(tr, "", 1)
+			if res.Error != "" {
+				t.Fatal(res.Error)
+			} else if res.TLSResumed != want {
+				t.Errorf("resumption %t: hit %d: got resumed %t, want %t", enabled, i, res.TLSResumed, want)
+			}
+		}
+	}
+
+	if DefaultTLSConfig.ClientSessionCache != nil {
+		t.Error("TLSResumption changed the default TLS config")
+	}
+}
+
+func TestWorkerIDs(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+	atk := NewAttacker(Workers(2), MaxWorkers(4))
+
+	seen := map[uint64]bool{}
+	for res := range atk.Attack(tr, Rate{Freq: 200, Per: time.Second}, 500*time.Millisecond, "") {
+		if res.Worker < 1 || res.Worker > 4 {
+			t.Fatalf("got worker %d, want one between 1 and 4", res.Worker)
+		}
+		seen[res.Worker] = true
+	}
+
+	if len(seen) < 2 {
+		t.Errorf("got results of workers %v, want at least 2", seen)
+	}
+}
+
 func TestProxyOption(t *testing.T) {
 	t.Parallel()
 
@@ -237,7 +1161,7 @@ func TestProxyOption(t *testing.T) {
 	}))
 
 	tr := NewStaticTargeter(Target{Method: "GET", URL: "http://127.0.0.2"})
-	res := atk.hit(tr, "")
+	res := atk.hit(tr, "", 1)
 	if got, want := res.Error, ""; got != want {
 		t.Errorf("got error: %q, want %q", got, want)
 	}
@@ -263,7 +1187,7 @@ func TestMaxBody(t *testing.T) {
 		t.Run(fmt.Sprint(maxBody), func(t *testing.T) {
 			atk := NewAttacker(MaxBody(maxBody))
 			tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
-			res := atk.hit(tr, "")
+			res := atk.hit(tr, "", 1)
 
 			want := body
 			if maxBody >= 0 {
@@ -277,6 +1201,28 @@ func TestMaxBody(t *testing.T) {
 	}
 }
 
+func TestH2C(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}), &http2.Server{}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	tr := NewStaticTargeter(Target{Method: "GET", URL: "http://h2c.test:" + u.Port()})
+
+	// Options configuring the dialing of connections apply in any order.
+	atk := NewAttacker(H2C(true), Connections(1), HostAliases(map[string]string{"h2c.test": u.Hostname()}))
+	if res := atk.hit(tr, "", 1); res.Error != "" || string(res.Body) != "HTTP/2.0" {
+		t.Errorf("got %q (%s), want HTTP/2.0", res.Body, res.Error)
+	}
+
+	if res := NewAttacker(H2C(false)).hit(NewStaticTargeter(Target{Method: "GET", URL: server.URL}), "", 1); string(res.Body) != "HTTP/1.1" {
+		t.Errorf("got %q without H2C, want HTTP/1.1", res.Body)
+	}
+}
+
 func TestUnixSocket(t *testing.T) {
 	t.Parallel()
 	body := []byte("IT'S A UNIX SYSTEM, I KNOW THIS")
@@ -321,7 +1267,7 @@ func TestUnixSocket(t *testing.T) {
 	atk := NewAttacker(UnixSocket(socketFile))
 
 	tr := NewStaticTargeter(Target{Method: "GET", URL: "http://anyserver/"})
-	res := atk.hit(tr, "")
+	res := atk.hit(tr, "", 1)
 	if !bytes.Equal(res.Body, body) {
 		t.Fatalf("got: %s, want: %s", string(res.Body), string(body))
 	}
@@ -355,7 +1301,7 @@ func TestClient(t *testing.T) {
 	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
 
 	atk := NewAttacker(Client(client))
-	resp := atk.hit(tr, "TEST")
+	resp := atk.hit(tr, "TEST", 1)
 	if !strings.Contains(resp.Error, "Client.Timeout exceeded while awaiting headers") {
 		t.Errorf("Expected timeout error")
 	}
@@ -377,7 +1323,7 @@ func TestVegetaHeaders(t *testing.T) {
 
 	for seq := 0; seq < 5; seq++ {
 		attack := "big-bang"
-		res := atk.hit(tr, attack)
+		res := atk.hit(tr, attack, 1)
 
 		var hdr http.Header
 		if err := json.Unmarshal(res.Body, &hdr); err != nil {
@@ -393,3 +1339,37 @@ func TestVegetaHeaders(t *testing.T) {
 		}
 	}
 }
+
+func TestSeqHeaders(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode(r.Header)
+		}),
+	)
+
+	defer server.Close()
+
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+	atk := NewAttacker(SeqHeaders("X-Request-Id", ""))
+
+	for seq := 0; seq < 3; seq++ {
+		res := atk.hit(tr, "big-bang", 1)
+
+		var hdr http.Header
+		if err := json.Unmarshal(res.Body, &hdr); err != nil {
+			t.Fatal(err)
+		}
+
+		if have, want := hdr.Get("X-Request-Id"), strconv.Itoa(seq); have != want {
+			t.Errorf("X-Request-Id: have %q, want %q", have, want)
+		}
+
+		for _, name := range []string{"X-Vegeta-Seq", "X-Vegeta-Attack"} {
+			if have := hdr.Get(name); have != "" {
+				t.Errorf("%s: have %q, want none", name, have)
+			}
+		}
+	}
+}
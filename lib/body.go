@@ -0,0 +1,56 @@
+package vegeta
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/c2h5oh/datasize"
+)
+
+// openBodySource opens the given BodySource of a Target: a file, given as
+// file:path, read as it's sent, or a number of random bytes, given as
+// random:size, e.g. random:1GB.
+func openBodySource(src string) (io.ReadCloser, error) {
+	kind := src
+	arg := ""
+	if i := strings.IndexByte(src, ':'); i != -1 {
+		kind, arg = src[:i], src[i+1:]
+	}
+
+	switch kind {
+	case "file":
+		return os.Open(arg)
+	case "random":
+		var size datasize.ByteSize
+		if err := size.UnmarshalText([]byte(arg)); err != nil {
+			return nil, fmt.Errorf("bad body source %q: %v", src, err)
+		}
+		rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+		return ioutil.NopCloser(io.LimitReader(rnd, int64(size.Bytes()))), nil
+	default:
+		return nil, fmt.Errorf("bad body source %q: want file:path or random:size", src)
+	}
+}
+
+// countingBody counts the bytes read from a request body, which the
+// transport may be sending while they're counted.
+type countingBody struct {
+	io.ReadCloser
+	n uint64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddUint64(&b.n, uint64(n))
+	return n, err
+}
+
+func (b *countingBody) count() uint64 {
+	return atomic.LoadUint64(&b.n)
+}
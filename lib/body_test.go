@@ -0,0 +1,63 @@
+package vegeta
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBodySource(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, err := io.Copy(ioutil.Discard, r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, "%s %d", strings.Join(r.TransferEncoding, ","), n)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "body")
+	if err = ioutil.WriteFile(file, []byte(strings.Repeat("final flash ", 1000)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	atk := NewAttacker()
+	for _, tc := range []struct {
+		src  string
+		size uint64
+	}{
+		{"file:" + file, 12000},
+		{"random:1MB", 1 << 20},
+		{"random:0", 0},
+	} {
+		tr := NewStaticTargeter(Target{Method: "POST", URL: server.URL, BodySource: tc.src})
+		res := atk.hit(tr, "", 1)
+		if want := fmt.Sprintf("chunked %d", tc.size); res.Error != "" || string(res.Body) != want {
+			t.Errorf("%s: got %q (%s), want %q", tc.src, res.Body, res.Error, want)
+		} else if res.BytesOut != tc.size {
+			t.Errorf("%s: got %d bytes out, want %d", tc.src, res.BytesOut, tc.size)
+		}
+	}
+
+	for _, src := range []string{"random:lots", "stdin", "file:" + filepath.Join(dir, "missing")} {
+		tr := NewStaticTargeter(Target{Method: "POST", URL: server.URL, BodySource: src})
+		if res := atk.hit(tr, "", 1); res.Error == "" || res.Code != 0 {
+			t.Errorf("%s: got no error with code %d, want the unsent request's error", src, res.Code)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package vegeta
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// CacheBust returns a functional option which makes an Attacker add a query
+// parameter with the given name and a unique value to each request, or set a
+// header with it if header is true, so that caches and CDNs in front of the
+// targets miss on every request. Values are unique across attacks too, so
+// that the entries cached by earlier ones don't hit either. Attacks without
+// it exercise their hits. It's applied like a BeforeHit function, and it's
+// ignored with an empty name.
+func CacheBust(name string, header bool) func(*Attacker) {
+	if name == "" {
+		return func(*Attacker) {}
+	}
+
+	param := url.QueryEscape(name)
+	run := strconv.FormatUint(uint64(rand.New(rand.NewSource(time.Now().UnixNano())).Int63()), 36)
+	var n uint64
+	return BeforeHit(func(_ *Target, req *http.Request) error {
+		value := run + "-" + strconv.FormatUint(atomic.AddUint64(&n, 1), 36)
+		if header {
+			req.Header.Set(name, value)
+			return nil
+		}
+
+		// The query of the target is kept as is, rather than re-encoded.
+		if req.URL.RawQuery != "" {
+			req.URL.RawQuery += "&"
+		}
+		req.URL.RawQuery += param + "=" + value
+		return nil
+	})
+}
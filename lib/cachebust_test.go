@@ -0,0 +1,54 @@
+package vegeta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCacheBust(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RawQuery + " " + r.Header.Get("X-Bust")))
+	}))
+	defer server.Close()
+
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL + "/?b=2&a=1"})
+	hits := func(atk *Attacker) (bodies []string) {
+		for i := 0; i < 2; i++ {
+			bodies = append(bodies, string(atk.hit(tr, "", 1).Body))
+		}
+		return bodies
+	}
+
+	query := hits(NewAttacker(CacheBust("_cb", false)))
+	for _, body := range query {
+		if !strings.HasPrefix(body, "b=2&a=1&_cb=") || strings.HasSuffix(body, "_cb= ") {
+			t.Errorf("got query %q, want the target's one with a cache busting parameter", body)
+		}
+	}
+
+	header := hits(NewAttacker(CacheBust("X-Bust", true)))
+	for _, body := range header {
+		if !strings.HasPrefix(body, "b=2&a=1 ") || strings.HasSuffix(body, " ") {
+			t.Errorf("got %q, want the target's query with a cache busting header", body)
+		}
+	}
+
+	// Values are unique across requests and attacks.
+	seen := map[string]bool{}
+	for _, body := range append(append(query, header...), hits(NewAttacker(CacheBust("_cb", false)))...) {
+		body = strings.TrimSpace(body)
+		value := body[strings.LastIndexAny(body, "= ")+1:]
+		if seen[value] {
+			t.Errorf("got cache busting value %q twice", value)
+		}
+		seen[value] = true
+	}
+
+	if got := hits(NewAttacker(CacheBust("", false)))[0]; got != "b=2&a=1 " {
+		t.Errorf("got %q without a name, want the request as is", got)
+	}
+}
@@ -0,0 +1,127 @@
+package vegeta
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"golang.org/x/net/http2"
+)
+
+// ClientCertificates returns a functional option which makes an Attacker
+// present the given TLS client certificates in turn, one per hit, e.g. to
+// test mutually authenticated services with the load of many clients rather
+// than one. Since the certificate of a connection is set by its handshake,
+// each certificate has its own pool of connections to each target host.
+// Targets with their own certificate present it instead.
+func ClientCertificates(certs ...tls.Certificate) func(*Attacker) {
+	return func(a *Attacker) { a.certs = certs }
+}
+
+// clientFor returns the client to hit the given Target with: the one of its
+// certificate, if it has one, or the one of the next of the client
+// certificates of the Attacker, if it has any, and of its server name, if it
+// has one, or else the Attacker's own.
+func (a *Attacker) clientFor(tgt *Target) (*http.Client, error) {
+	if a.ntlm != nil {
+		return a.ntlmClient()
+	}
+
+	var id string
+	var idx uint64
+	switch n := uint64(len(a.certs)); {
+	case tgt.Cert != "":
+		id = "file:" + tgt.Cert + "\x00" + tgt.Key
+	case n > 0:
+		idx = (atomic.AddUint64(&a.certIdx, 1) - 1) % n
+		id = "pool:" + strconv.FormatUint(idx, 10)
+	case tgt.SNI == "":
+		return &a.client, nil
+	}
+
+	if tgt.SNI != "" {
+		id += "\x00sni:" + tgt.SNI
+	}
+
+	a.certmu.Lock()
+	defer a.certmu.Unlock()
+
+	if c, ok := a.clients[id]; ok {
+		return c, nil
+	}
+
+	var cert *tls.Certificate
+	if tgt.Cert != "" {
+		keyf := tgt.Key
+		if keyf == "" {
+			keyf = tgt.Cert
+		}
+
+		c, err := tls.LoadX509KeyPair(tgt.Cert, keyf)
+		if err != nil {
+			return nil, err
+		}
+		cert = &c
+	} else if len(a.certs) > 0 {
+		cert = &a.certs[idx]
+	}
+
+	c, err := a.clientWith(cert, tgt.SNI)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.clients == nil {
+		a.clients = map[string]*http.Client{}
+	}
+	a.clients[id] = c
+
+	return c, nil
+}
+
+// clientWith returns a copy of the client of the Attacker, with its own
+// transport, which presents the given certificate, unless nil, and sends the
+// given server name, unless empty.
+func (a *Attacker) clientWith(cert *tls.Certificate, sni string) (*http.Client, error) {
+	tr, ok := a.transport()
+	if !ok {
+		return nil, errors.New("client certificates and server names of targets aren't supported by custom transports")
+	}
+
+	clone := tr.Clone()
+	// Prewarmed connections were set up without the certificate or server name.
+	clone.DialTLS = nil
+
+	if clone.TLSClientConfig == nil {
+		clone.TLSClientConfig = &tls.Config{}
+	}
+
+	if cert != nil {
+		clone.TLSClientConfig.Certificates = []tls.Certificate{*cert}
+		clone.TLSClientConfig.GetClientCertificate = nil
+	}
+
+	if sni != "" {
+		clone.TLSClientConfig.ServerName = sni
+	}
+
+	// Sessions resumed by other clients would authenticate with their
+	// certificates, or belong to other servers.
+	if clone.TLSClientConfig.ClientSessionCache != nil {
+		clone.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+
+	// HTTP/2 connections of the original transport must not be shared.
+	if _, ok := tr.TLSNextProto["h2"]; ok {
+		clone.TLSNextProto = nil
+		if err := http2.ConfigureTransport(clone); err != nil {
+			return nil, err
+		}
+	}
+
+	c := a.client
+	c.Transport = a.wrapTransport(clone)
+	return &c, nil
+}
@@ -0,0 +1,112 @@
+package vegeta
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClientCertificates(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	alice, alicePEM := testClientCert(t, "alice")
+	bob, _ := testClientCert(t, "bob")
+	_, carolPEM := testClientCert(t, "carol")
+
+	dir, err := ioutil.TempDir("", "vegeta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	carolf := filepath.Join(dir, "carol.pem")
+	if err = ioutil.WriteFile(carolf, carolPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	alicef := filepath.Join(dir, "alice.pem")
+	if err = ioutil.WriteFile(alicef, alicePEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	atk := NewAttacker(ClientCertificates(alice, bob), TLSConfig(&tls.Config{InsecureSkipVerify: true}), HTTP2(true))
+	for _, tc := range []struct {
+		tgt  Target
+		want string
+	}{
+		{tgt: Target{Method: "GET", URL: server.URL}, want: "alice"},
+		{tgt: Target{Method: "GET", URL: server.URL}, want: "bob"},
+		{tgt: Target{Method: "GET", URL: server.URL, Cert: carolf}, want: "carol"},
+		{tgt: Target{Method: "GET", URL: server.URL, Cert: alicef, Key: alicef}, want: "alice"},
+		{tgt: Target{Method: "GET", URL: server.URL}, want: "alice"},
+		{tgt: Target{Method: "GET", URL: server.URL}, want: "bob"},
+	} {
+		res := atk.hit(NewStaticTargeter(tc.tgt), "", 1)
+		if res.Error != "" {
+			t.Fatal(res.Error)
+		} else if got := string(res.Body); got != tc.want {
+			t.Errorf("got client certificate of %q, want %q", got, tc.want)
+		}
+	}
+
+	tgt := Target{Method: "GET", URL: server.URL, Cert: filepath.Join(dir, "missing.pem")}
+	if res := atk.hit(NewStaticTargeter(tgt), "", 1); res.Error == "" {
+		t.Error("got no error with a missing client certificate file")
+	}
+}
+
+// testClientCert returns a self-signed client certificate with the given
+// common name, and its PEM encoding with its private key.
+func testClientCert(t testing.TB, cn string) (tls.Certificate, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, append(certPEM, keyPEM...)
+}
@@ -0,0 +1,142 @@
+package vegeta
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// AcceptEncoding returns a functional option which makes an Attacker send the
+// given Accept-Encoding header, e.g. "zstd, gzip", with requests which don't
+// set their own, and decode the bodies of responses encoded with a content
+// coding it supports: gzip, deflate and zstd. Bodies with other codings, like
+// br, are kept as received. Unlike the transparent gzip decompression of
+// net/http, which it replaces, it decodes the responses to requests setting
+// their own Accept-Encoding too. An empty value disables it.
+func AcceptEncoding(value string) func(*Attacker) {
+	return func(a *Attacker) { a.acceptEnc = value }
+}
+
+// WireBytesIn returns a functional option which makes an Attacker count the
+// bytes of response bodies as received, before they're decoded, in the BytesIn
+// of Results, rather than after. Bodies decompressed transparently by net/http,
+// without AcceptEncoding, are counted after.
+func WireBytesIn(wire bool) func(*Attacker) {
+	return func(a *Attacker) { a.wireBytes = wire }
+}
+
+// CompressBody returns a functional option which makes an Attacker compress
+// the bodies of requests with the given content coding, gzip or zstd, and set
+// their Content-Encoding header, e.g. to hit APIs accepting compressed
+// uploads. Targets with their own Compression use it instead. Since bodies
+// are compressed with each request, it costs the Attacker CPU time. An empty
+// coding, or identity, disables it.
+func CompressBody(coding string) func(*Attacker) {
+	return func(a *Attacker) { a.bodyCoding = coding }
+}
+
+// gzipWriters are reused to compress request bodies, since they're costly to
+// allocate.
+var gzipWriters = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+
+// zstdEncoder compresses request bodies concurrently once created.
+var zstdEncoder struct {
+	once sync.Once
+	enc  *zstd.Encoder
+	err  error
+}
+
+// compress compresses the body of the given request, made from the given
+// Target, with the content coding of the Target, or else of the Attacker.
+func (a *Attacker) compress(tgt *Target, req *http.Request) error {
+	coding := a.bodyCoding
+	if tgt.Compression != "" {
+		coding = tgt.Compression
+	}
+
+	if len(tgt.Body) == 0 {
+		return nil
+	}
+
+	var body []byte
+	switch coding = strings.ToLower(coding); coding {
+	case "", "identity":
+		return nil
+	case "gzip":
+		var buf bytes.Buffer
+		zw := gzipWriters.Get().(*gzip.Writer)
+		zw.Reset(&buf)
+		zw.Write(tgt.Body)
+		zw.Close()
+		gzipWriters.Put(zw)
+		body = buf.Bytes()
+	case "zstd":
+		zstdEncoder.once.Do(func() {
+			zstdEncoder.enc, zstdEncoder.err = zstd.NewWriter(nil)
+		})
+		if zstdEncoder.err != nil {
+			return zstdEncoder.err
+		}
+		body = zstdEncoder.enc.EncodeAll(tgt.Body, nil)
+	default:
+		return fmt.Errorf("unsupported compression %q", coding)
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Encoding", coding)
+	return nil
+}
+
+// decoder returns a reader decoding the given body, encoded with the given
+// content coding, unless it isn't supported.
+func decoder(coding string, body io.Reader) (io.ReadCloser, bool, error) {
+	var (
+		rc  io.ReadCloser
+		err error
+	)
+
+	switch strings.ToLower(strings.TrimSpace(coding)) {
+	case "gzip", "x-gzip":
+		rc, err = gzip.NewReader(body)
+	case "deflate":
+		rc, err = zlib.NewReader(body)
+	case "zstd":
+		var d *zstd.Decoder
+		if d, err = zstd.NewReader(body, zstd.WithDecoderConcurrency(1)); err == nil {
+			rc = d.IOReadCloser()
+		}
+	default:
+		return nil, false, nil
+	}
+
+	// Empty bodies, e.g. of HEAD requests, have nothing to decode.
+	if err == io.EOF {
+		return nil, false, nil
+	}
+
+	return rc, err == nil, err
+}
+
+// countingReader counts the bytes read from a reader.
+type countingReader struct {
+	r io.Reader
+	n uint64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += uint64(n)
+	return n, err
+}
@@ -0,0 +1,138 @@
+package vegeta
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestAcceptEncoding(t *testing.T) {
+	t.Parallel()
+
+	plain := []byte(strings.Repeat("kamehameha ", 100))
+
+	var gz, zl bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(plain)
+	gw.Close()
+
+	zw := zlib.NewWriter(&zl)
+	zw.Write(plain)
+	zw.Close()
+
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zst := enc.EncodeAll(plain, nil)
+
+	bodies := map[string][]byte{"gzip": gz.Bytes(), "deflate": zl.Bytes(), "zstd": zst, "br": []byte("not decoded")}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		coding := strings.TrimPrefix(r.URL.Path, "/")
+		w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+		if coding != "" {
+			w.Header().Set("Content-Encoding", coding)
+		}
+		if r.URL.RawQuery == "corrupt" {
+			w.Write([]byte("corrupt body"))
+			return
+		}
+		w.Write(bodies[coding])
+	}))
+	defer server.Close()
+
+	hit := func(atk *Attacker, coding string, header http.Header) *Result {
+		return atk.hit(NewStaticTargeter(Target{Method: "GET", URL: server.URL + "/" + coding, Header: header}), "", 1)
+	}
+
+	atk := NewAttacker(AcceptEncoding("zstd, gzip"))
+	for _, coding := range []string{"gzip", "deflate", "zstd"} {
+		res := hit(atk, coding, nil)
+		if res.Error != "" {
+			t.Errorf("%s: %s", coding, res.Error)
+		} else if !bytes.Equal(res.Body, plain) || res.BytesIn != uint64(len(plain)) {
+			t.Errorf("%s: got %d bytes in of body %.20q, want it decoded", coding, res.BytesIn, res.Body)
+		} else if got := res.Headers.Get("X-Accept-Encoding"); got != "zstd, gzip" {
+			t.Errorf("%s: sent Accept-Encoding %q", coding, got)
+		}
+	}
+
+	if res := hit(atk, "br", http.Header{"Accept-Encoding": {"br"}}); string(res.Body) != "not decoded" {
+		t.Errorf("got br body %q, want it as received", res.Body)
+	} else if got := res.Headers.Get("X-Accept-Encoding"); got != "br" {
+		t.Errorf("got Accept-Encoding %q, want the request's own", got)
+	}
+
+	atk = NewAttacker(AcceptEncoding("zstd"), WireBytesIn(true), MaxBody(10))
+	if res := hit(atk, "zstd", nil); res.BytesIn != uint64(len(zst)) || string(res.Body) != string(plain[:10]) {
+		t.Errorf("got %d wire bytes in of body %q, want %d of the decoded one cut at 10", res.BytesIn, res.Body, len(zst))
+	}
+
+	// Corrupt bodies fail their hits.
+	for _, coding := range []string{"gzip", "deflate", "zstd"} {
+		if res := hit(atk, coding+"?corrupt", nil); res.Error == "" {
+			t.Errorf("%s: got no error decoding a corrupt body", coding)
+		}
+	}
+}
+
+func TestCompressBody(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		coding := r.Header.Get("Content-Encoding")
+		body, ok, err := decoder(coding, r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if !ok {
+			body = r.Body
+		}
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Write(append([]byte(coding+":"), data...))
+	}))
+	defer server.Close()
+
+	plain := []byte(strings.Repeat("galick gun ", 100))
+	hit := func(atk *Attacker, compression string) *Result {
+		tr := NewStaticTargeter(Target{Method: "POST", URL: server.URL, Body: plain, Compression: compression})
+		return atk.hit(tr, "", 1)
+	}
+
+	atk := NewAttacker(CompressBody("gzip"))
+	for _, tc := range []struct {
+		compression string
+		coding      string
+	}{
+		{"", "gzip"},
+		{"zstd", "zstd"},
+		{"identity", ""},
+	} {
+		res := hit(atk, tc.compression)
+		if want := tc.coding + ":" + string(plain); res.Error != "" || string(res.Body) != want {
+			t.Errorf("%q: got %.20q (%s), want %.20q", tc.compression, res.Body, res.Error, want)
+		} else if compressed := res.BytesOut < uint64(len(plain)); compressed != (tc.coding != "") {
+			t.Errorf("%q: got %d bytes out of %d", tc.compression, res.BytesOut, len(plain))
+		}
+	}
+
+	if res := hit(atk, "lzma"); res.Error != `unsupported compression "lzma"` || res.Code != 0 {
+		t.Errorf("got error %q with code %d, want the unsent request's error", res.Error, res.Code)
+	}
+
+	if res := hit(NewAttacker(), ""); string(res.Body) != ":"+string(plain) {
+		t.Errorf("got %.20q without CompressBody, want the body as is", res.Body)
+	}
+}
@@ -0,0 +1,49 @@
+package vegeta
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"strconv"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// SessionCookies returns a functional option which makes an Attacker keep a
+// cookie jar per virtual session, which stores the cookies set by the
+// responses to its requests, including redirects, and sends them with its
+// later ones, like a browser would, e.g. to hit applications with sticky
+// sessions or CSRF protection. The session of a Target is its Session, if
+// set, or else the worker hitting it. Jars are kept until the Attacker is
+// garbage collected.
+func SessionCookies(enabled bool) func(*Attacker) {
+	return func(a *Attacker) {
+		a.jars = nil
+		if enabled {
+			a.jars = map[string]http.CookieJar{}
+		}
+	}
+}
+
+// jarFor returns the cookie jar of the session of the given Target hit by
+// the given worker.
+func (a *Attacker) jarFor(tgt *Target, worker uint64) (http.CookieJar, error) {
+	session := "worker:" + strconv.FormatUint(worker, 10)
+	if tgt.Session != "" {
+		session = "session:" + tgt.Session
+	}
+
+	a.jarmu.Lock()
+	defer a.jarmu.Unlock()
+
+	if jar, ok := a.jars[session]; ok {
+		return jar, nil
+	}
+
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+
+	a.jars[session] = jar
+	return jar, nil
+}
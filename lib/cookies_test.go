@@ -0,0 +1,62 @@
+package vegeta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSessionCookies(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: r.URL.Query().Get("user")})
+			http.Redirect(w, r, "/me", http.StatusFound)
+		case "/me":
+			if c, err := r.Cookie("sid"); err == nil {
+				w.Write([]byte(c.Value))
+			}
+		}
+	}))
+	defer server.Close()
+
+	login := func(user, session string) Target {
+		return Target{Method: "POST", URL: server.URL + "/login?user=" + user, Session: session}
+	}
+	me := Target{Method: "GET", URL: server.URL + "/me"}
+	sessionMe := func(session string) Target {
+		tgt := me
+		tgt.Session = session
+		return tgt
+	}
+
+	atk := NewAttacker(SessionCookies(true))
+	for _, tc := range []struct {
+		tgt    Target
+		worker uint64
+		want   string
+	}{
+		{tgt: login("goku", ""), worker: 1, want: "goku"},
+		{tgt: me, worker: 1, want: "goku"},
+		{tgt: me, worker: 2, want: ""},
+		{tgt: login("vegeta", "saiyan"), worker: 2, want: "vegeta"},
+		{tgt: sessionMe("saiyan"), worker: 3, want: "vegeta"},
+		{tgt: sessionMe("namek"), worker: 1, want: ""},
+		{tgt: me, worker: 1, want: "goku"},
+	} {
+		res := atk.hit(NewStaticTargeter(tc.tgt), "", tc.worker)
+		if res.Error != "" {
+			t.Fatal(res.Error)
+		} else if got := string(res.Body); got != tc.want {
+			t.Errorf("%s %s by worker %d: got session cookie %q, want %q", tc.tgt.URL, tc.tgt.Session, tc.worker, got, tc.want)
+		}
+	}
+
+	atk = NewAttacker()
+	atk.hit(NewStaticTargeter(login("goku", "")), "", 1)
+	if res := atk.hit(NewStaticTargeter(me), "", 1); len(res.Body) > 0 {
+		t.Errorf("got session cookie %q without SessionCookies", res.Body)
+	}
+}
@@ -0,0 +1,220 @@
+package vegeta
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// DiffMetrics are the names of the metrics compared by Diff, in the same
+// format as the metrics of an SLO.
+var DiffMetrics = []string{
+	"requests", "rate", "throughput", "success", "error_rate",
+	"min", "mean", "p50", "p90", "p95", "p99", "max",
+}
+
+// A Delta is the difference of a metric between a baseline and a candidate
+// Metrics.
+type Delta struct {
+	// Metric is the name of the compared metric.
+	Metric string `json:"metric"`
+	// Baseline is the value of the metric in the baseline Metrics.
+	Baseline float64 `json:"baseline"`
+	// Candidate is the value of the metric in the candidate Metrics.
+	Candidate float64 `json:"candidate"`
+	// Delta is the absolute difference between Candidate and Baseline.
+	Delta float64 `json:"delta"`
+	// Percent is Delta as a percentage of Baseline, or nil if Baseline is zero.
+	Percent *float64 `json:"percent"`
+}
+
+// A Comparison holds the Deltas between a baseline and a candidate attack
+// and, if computed, the Significance of their latency difference.
+type Comparison struct {
+	Deltas       []Delta       `json:"deltas"`
+	Significance *Significance `json:"significance,omitempty"`
+}
+
+// Significance is the outcome of a two-sided Mann-Whitney U test of
+// whether the latencies of a candidate attack differ from the ones of
+// a baseline attack, as computed by MannWhitneyU.
+type Significance struct {
+	// U is the U statistic of the candidate latencies.
+	U float64 `json:"u"`
+	// Z is the standard score of U in the normal approximation of its distribution.
+	Z float64 `json:"z"`
+	// P is the two-sided p-value.
+	P float64 `json:"p"`
+	// Effect is the probability that a candidate latency is greater than
+	// a baseline latency, with ties counting half. Values above 0.5 mean
+	// the candidate is slower.
+	Effect float64 `json:"effect"`
+	// Alpha is the significance level P is compared to.
+	Alpha float64 `json:"alpha"`
+	// Significant is true if P is lower than Alpha.
+	Significant bool `json:"significant"`
+}
+
+// MannWhitneyU runs a two-sided Mann-Whitney U test on the given baseline and
+// candidate latency samples with the given significance level. Since it's
+// based on ranks, it makes no assumptions on the distribution of latencies
+// and is robust to their outliers. P is computed from the normal
+// approximation of the U distribution with tie correction, which is
+// accurate for the sample sizes of load tests.
+// It returns nil if any of the samples is empty.
+func MannWhitneyU(baseline, candidate []time.Duration, alpha float64) *Significance {
+	n1, n2 := float64(len(baseline)), float64(len(candidate))
+	if n1 == 0 || n2 == 0 {
+		return nil
+	}
+
+	type sample struct {
+		v         time.Duration
+		candidate bool
+	}
+
+	all := make([]sample, 0, len(baseline)+len(candidate))
+	for _, v := range baseline {
+		all = append(all, sample{v, false})
+	}
+	for _, v := range candidate {
+		all = append(all, sample{v, true})
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// Sum the ranks of the candidate samples, averaging the ranks of ties.
+	var r2, ties float64
+	for i := 0; i < len(all); {
+		j := i + 1
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+
+		rank := float64(i+j+1) / 2 // ranks are 1 based
+		for k := i; k < j; k++ {
+			if all[k].candidate {
+				r2 += rank
+			}
+		}
+
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	n := n1 + n2
+	u := r2 - n2*(n2+1)/2
+	mu := n1 * n2 / 2
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1))))
+
+	s := Significance{U: u, Effect: u / (n1 * n2), Alpha: alpha, P: 1}
+	if sigma > 0 {
+		// Continuity correction towards the mean.
+		d := u - mu
+		switch {
+		case d > 0.5:
+			d -= 0.5
+		case d < -0.5:
+			d += 0.5
+		default:
+			d = 0
+		}
+		s.Z = d / sigma
+		s.P = math.Erfc(math.Abs(s.Z) / math.Sqrt2)
+	}
+
+	s.Significant = s.P < alpha
+	return &s
+}
+
+// Diff returns the Deltas of all DiffMetrics between the given baseline and
+// candidate Metrics. Latency metrics are in nanoseconds and the success and
+// error_rate metrics are ratios.
+func Diff(baseline, candidate *Metrics) []Delta {
+	ds := make([]Delta, 0, len(DiffMetrics))
+	for _, metric := range DiffMetrics {
+		d := Delta{
+			Metric:    metric,
+			Baseline:  metricValue(baseline, metric),
+			Candidate: metricValue(candidate, metric),
+		}
+
+		d.Delta = d.Candidate - d.Baseline
+		if d.Baseline != 0 {
+			pct := d.Delta / math.Abs(d.Baseline) * 100
+			d.Percent = &pct
+		}
+
+		ds = append(ds, d)
+	}
+	return ds
+}
+
+// NewDiffTextReporter returns a Reporter that writes out the given Comparison
+// as aligned, formatted text.
+func NewDiffTextReporter(c *Comparison) Reporter {
+	return func(w io.Writer) error {
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.StripEscape)
+		if _, err := fmt.Fprintln(tw, "Metric\tBaseline\tCandidate\tDelta\tDelta %"); err != nil {
+			return err
+		}
+
+		for _, d := range c.Deltas {
+			pct := "n/a"
+			if d.Percent != nil {
+				pct = signed(strconv.FormatFloat(*d.Percent, 'f', 2, 64)+"%", *d.Percent)
+			}
+
+			_, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+				d.Metric,
+				formatMetric(d.Metric, d.Baseline),
+				formatMetric(d.Metric, d.Candidate),
+				signed(formatMetric(d.Metric, d.Delta), d.Delta),
+				pct,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		if s := c.Significance; s != nil {
+			verdict := "not significant"
+			if s.Significant {
+				verdict = "significant"
+			}
+
+			_, err := fmt.Fprintf(w, "\nLatency Mann-Whitney U test: U=%s, z=%.2f, p=%.4f, effect=%.2f => %s (alpha=%s)\n",
+				formatFloat(s.U), s.Z, s.P, s.Effect, verdict, formatFloat(s.Alpha))
+			return err
+		}
+
+		return nil
+	}
+}
+
+// NewDiffJSONReporter returns a Reporter that writes out the given Comparison
+// as JSON.
+func NewDiffJSONReporter(c *Comparison) Reporter {
+	return func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(c)
+	}
+}
+
+// signed prefixes the formatted value s with a plus sign if v is positive.
+func signed(s string, v float64) string {
+	if v > 0 && !strings.HasPrefix(s, "+") {
+		return "+" + s
+	}
+	return s
+}
@@ -0,0 +1,127 @@
+package vegeta
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	metrics := func(lat time.Duration, errors int) *Metrics {
+		var m Metrics
+		for i := 0; i < 100; i++ {
+			r := Result{Code: 200, Timestamp: time.Unix(int64(i), 0), Latency: lat}
+			if i < errors {
+				r.Code, r.Error = 500, "500 Internal Server Error"
+			}
+			m.Add(&r)
+		}
+		m.Close()
+		return &m
+	}
+
+	baseline, candidate := metrics(10*time.Millisecond, 0), metrics(15*time.Millisecond, 2)
+
+	ds := Diff(baseline, candidate)
+	if got, want := len(ds), len(DiffMetrics); got != want {
+		t.Fatalf("got %d deltas, want %d", got, want)
+	}
+
+	byMetric := map[string]Delta{}
+	for _, d := range ds {
+		byMetric[d.Metric] = d
+	}
+
+	if d := byMetric["p99"]; d.Delta != float64(5*time.Millisecond) || d.Percent == nil || *d.Percent != 50 {
+		t.Errorf("got p99 delta %+v, want +5ms and +50%%", d)
+	}
+
+	if d := byMetric["error_rate"]; d.Percent != nil {
+		t.Errorf("got error_rate percent %v, want nil for zero baseline", *d.Percent)
+	}
+
+	c := Comparison{Deltas: ds, Significance: &Significance{P: 0.001, Alpha: 0.05, Significant: true}}
+
+	var buf bytes.Buffer
+	if err := NewDiffTextReporter(&c).Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"+5ms", "+50.00%", "-2%", "n/a", "p=0.0010", "=> significant (alpha=0.05)"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("text report doesn't contain %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := NewDiffJSONReporter(&c).Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got Comparison
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	} else if len(got.Deltas) != len(ds) {
+		t.Errorf("got %d JSON deltas, want %d", len(got.Deltas), len(ds))
+	} else if got.Significance == nil || !got.Significance.Significant {
+		t.Errorf("got JSON significance %+v, want significant", got.Significance)
+	}
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	t.Parallel()
+
+	durations := func(vs ...int) []time.Duration {
+		ds := make([]time.Duration, len(vs))
+		for i, v := range vs {
+			ds[i] = time.Duration(v) * time.Millisecond
+		}
+		return ds
+	}
+
+	for _, tc := range []struct {
+		name                string
+		baseline, candidate []time.Duration
+		u, p, effect        float64
+		significant         bool
+	}{
+		{
+			// Same as scipy.stats.mannwhitneyu(x, y, method="asymptotic")
+			name:      "slower",
+			baseline:  durations(1, 2, 3, 4, 5),
+			candidate: durations(6, 7, 8, 9, 10),
+			u:         25, p: 0.012185, effect: 1, significant: true,
+		},
+		{
+			name:      "ties",
+			baseline:  durations(1, 2, 2, 3, 4),
+			candidate: durations(2, 3, 3, 4, 1),
+			u:         14, p: 0.829357, effect: 0.56,
+		},
+		{
+			name:      "identical",
+			baseline:  durations(5, 5, 5),
+			candidate: durations(5, 5, 5),
+			u:         4.5, p: 1, effect: 0.5,
+		},
+	} {
+		s := MannWhitneyU(tc.baseline, tc.candidate, 0.05)
+		if s == nil {
+			t.Fatalf("%s: got nil significance", tc.name)
+		}
+
+		if s.U != tc.u || math.Abs(s.P-tc.p) > 1e-5 || s.Effect != tc.effect || s.Significant != tc.significant {
+			t.Errorf("%s: got %+v, want U=%v P=%v Effect=%v Significant=%v",
+				tc.name, s, tc.u, tc.p, tc.effect, tc.significant)
+		}
+	}
+
+	if s := MannWhitneyU(nil, durations(1), 0.05); s != nil {
+		t.Errorf("got %+v for empty baseline, want nil", s)
+	}
+}
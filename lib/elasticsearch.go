@@ -0,0 +1,54 @@
+package vegeta
+
+import (
+	"io"
+	"strings"
+
+	"github.com/mailru/easyjson/jwriter"
+)
+
+// NewElasticsearchEncoder returns an Encoder that writes each Result as a JSON
+// document preceded by its index action, as expected by the Elasticsearch and
+// OpenSearch bulk API. The name of the index of each document is given by the
+// index template, in which {attack} is replaced by the Result's attack name,
+// lower cased and with the characters which aren't allowed in index names
+// replaced by dashes, and {date} by its UTC timestamp date formatted as
+// YYYY.MM.DD.
+//
+//    {"index":{"_index":"vegeta-2020.03.14"}}
+//    {"attack":"","seq":0,"code":200,"timestamp":"2020-03-14T15:09:26.535897932Z",...}
+//
+func NewElasticsearchEncoder(w io.Writer, index string) Encoder {
+	var jw jwriter.Writer
+	return func(r *Result) error {
+		jw.RawString(`{"index":{"_index":`)
+		jw.String(ElasticsearchIndex(index, r))
+		jw.RawString("}}\n")
+		(*jsonResult)(r).MarshalEasyJSON(&jw)
+		if jw.Error != nil {
+			return jw.Error
+		}
+		jw.RawByte('\n')
+		_, err := jw.DumpTo(w)
+		return err
+	}
+}
+
+// ElasticsearchIndex expands the given index template for the given Result.
+// See NewElasticsearchEncoder.
+func ElasticsearchIndex(index string, r *Result) string {
+	if !strings.Contains(index, "{") {
+		return index
+	}
+	return strings.NewReplacer(
+		"{attack}", elasticsearchIndexChars.Replace(strings.ToLower(r.Attack)),
+		"{date}", r.Timestamp.UTC().Format("2006.01.02"),
+	).Replace(index)
+}
+
+// elasticsearchIndexChars replaces spaces and the characters which aren't
+// allowed in index names by dashes.
+var elasticsearchIndexChars = strings.NewReplacer(
+	" ", "-", `\`, "-", "/", "-", "*", "-", "?", "-", `"`, "-",
+	"<", "-", ">", "-", "|", "-", ",", "-", "#", "-",
+)
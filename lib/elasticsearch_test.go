@@ -0,0 +1,62 @@
+package vegeta
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestElasticsearchEncoder(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	enc := NewElasticsearchEncoder(&buf, "vegeta-{attack}-{date}")
+
+	r := Result{
+		Attack:    "Big Bang",
+		Code:      200,
+		Timestamp: time.Date(2020, 3, 14, 15, 9, 26, 0, time.UTC),
+	}
+
+	if err := enc.Encode(&r); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if got, want := len(lines), 2; got != want {
+		t.Fatalf("got %d lines, want %d:\n%s", got, want, buf.String())
+	}
+
+	if got, want := lines[0], `{"index":{"_index":"vegeta-big-bang-2020.03.14"}}`; got != want {
+		t.Errorf("got action %s, want %s", got, want)
+	}
+
+	var got Result
+	if err := NewJSONDecoder(strings.NewReader(lines[1] + "\n")).Decode(&got); err != nil {
+		t.Fatal(err)
+	} else if !got.Equal(r) {
+		t.Errorf("got document %+v, want %+v", got, r)
+	}
+}
+
+func TestElasticsearchIndex(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2020, 3, 14, 23, 59, 0, 0, time.FixedZone("UTC-2", -2*3600))
+	for _, tc := range []struct {
+		index  string
+		attack string
+		want   string
+	}{
+		{"vegeta", "Big Bang", "vegeta"},
+		{"vegeta-{date}", "", "vegeta-2020.03.15"},
+		{"vegeta-{attack}", "Big Bang", "vegeta-big-bang"},
+		{"vegeta-{attack}-{date}", `A\B/C*D?E"F<G>H|I,J#K`, "vegeta-a-b-c-d-e-f-g-h-i-j-k-2020.03.15"},
+		{"{attack}", "checkout_v2.1", "checkout_v2.1"},
+	} {
+		if got := ElasticsearchIndex(tc.index, &Result{Attack: tc.attack, Timestamp: ts}); got != tc.want {
+			t.Errorf("ElasticsearchIndex(%q) of attack %q: got %q, want %q", tc.index, tc.attack, got, tc.want)
+		}
+	}
+}
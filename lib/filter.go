@@ -0,0 +1,466 @@
+package vegeta
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsenart/vegeta/v12/internal/jsonpath"
+)
+
+// A Filter returns true if the given Result matches it.
+type Filter func(*Result) bool
+
+// ParseFilter parses a Filter from an expression over the fields of Results,
+// such as "code >= 500 && latency > 250ms".
+//
+// Expressions compare fields to values with the operators ==, !=, <, <=, >
+// and >=, and string fields to regular expressions with =~ and !~.
+// Comparisons are combined with && and ||, negated with ! and grouped
+// with parentheses.
+//
+// The supported fields are:
+//   - code, seq, bytes_in, bytes_out, weight, worker and attempt, compared to
+//     integers.
+//   - latency, conn_wait, negotiation, retry_delay and tunnel, compared to
+//     durations (e.g. 250ms).
+//   - timestamp, compared to RFC3339 timestamps.
+//   - attack, error, body, method, url, request_body, remote_addr,
+//     local_addr, ip_family, tls_version, tls_cipher_suite, tls_protocol and
+//     trace_id, compared to strings.
+//   - header.<name>, request_header.<name> and label.<key>, the values of
+//     response headers, request headers and labels, compared to strings.
+//   - tls_resumed, retried and continued, compared to true or false.
+//   - JSONPath expressions, e.g. $.items[0].id, the values they select of
+//     JSON response bodies, compared to strings, or numbers with <, <=, >
+//     and >=. Strings are compared unquoted, objects and arrays as compact
+//     JSON and missing values as empty strings. See the jsonpath package.
+//
+// Values can be double quoted, which they must be if they contain spaces,
+// parentheses, quotes or any of the characters !=<>&|~.
+func ParseFilter(expr string) (Filter, error) {
+	p := filterParser{s: expr}
+
+	f, err := p.or()
+	if p.skipSpace(); err == nil && p.pos < len(p.s) {
+		err = p.errorf("unexpected %q", p.s[p.pos:])
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("filter %q: %v", expr, err)
+	}
+
+	return f, nil
+}
+
+// filterOps are the comparison operators of filter expressions, longest first.
+var filterOps = []string{"==", "!=", "<=", ">=", "=~", "!~", "<", ">"}
+
+// filterParser is a recursive descent parser of filter expressions.
+type filterParser struct {
+	s   string
+	pos int
+}
+
+// or parses comparisons combined with && and ||, which binds looser.
+func (p *filterParser) or() (Filter, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("||") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r *Result) bool { return l(r) || right(r) }
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) and() (Filter, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("&&") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r *Result) bool { return l(r) && right(r) }
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) unary() (Filter, error) {
+	switch {
+	case p.accept("!"):
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(r *Result) bool { return !f(r) }, nil
+	case p.accept("("):
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		} else if !p.accept(")") {
+			return nil, p.errorf("missing )")
+		}
+		return f, nil
+	}
+	return p.comparison()
+}
+
+func (p *filterParser) comparison() (Filter, error) {
+	name, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+
+	field, err := parseFilterField(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var op string
+	for _, o := range filterOps {
+		if p.accept(o) {
+			op = o
+			break
+		}
+	}
+
+	if op == "" {
+		return nil, p.errorf("missing comparison operator after %s (one of %s)", name, strings.Join(filterOps, ", "))
+	}
+
+	val, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := field.compare(op, val)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s %s: %v", name, op, val, err)
+	}
+
+	return f, nil
+}
+
+// value parses a field name or value, which is either a double quoted Go
+// string literal or a sequence of characters other than spaces and those
+// of operators.
+func (p *filterParser) value() (string, error) {
+	p.skipSpace()
+
+	if p.pos < len(p.s) && p.s[p.pos] == '"' {
+		end := p.pos + 1
+		for ; end < len(p.s) && p.s[end] != '"'; end++ {
+			if p.s[end] == '\\' {
+				end++
+			}
+		}
+
+		if end >= len(p.s) {
+			return "", p.errorf("unterminated string")
+		}
+
+		v, err := strconv.Unquote(p.s[p.pos : end+1])
+		if err != nil {
+			return "", p.errorf("bad string %s", p.s[p.pos:end+1])
+		}
+
+		p.pos = end + 1
+		return v, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(" \t\r\n()\"!=<>&|~", rune(p.s[p.pos])) {
+		p.pos++
+	}
+
+	if p.pos == start {
+		if p.pos == len(p.s) {
+			return "", p.errorf("unexpected end of expression")
+		}
+		return "", p.errorf("unexpected %q", p.s[p.pos:])
+	}
+
+	return p.s[start:p.pos], nil
+}
+
+// accept consumes the given token if it's next in the expression.
+func (p *filterParser) accept(tok string) bool {
+	p.skipSpace()
+	if !strings.HasPrefix(p.s[p.pos:], tok) {
+		return false
+	}
+
+	// ! is negation only if it isn't the start of the != or !~ operators.
+	if tok == "!" && len(p.s) > p.pos+1 && strings.ContainsRune("=~", rune(p.s[p.pos+1])) {
+		return false
+	}
+
+	p.pos += len(tok)
+	return true
+}
+
+func (p *filterParser) skipSpace() {
+	for p.pos < len(p.s) && strings.ContainsRune(" \t\r\n", rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *filterParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// A filterField gets the value of a Result field compared by filter
+// expressions, which is either an int, a string or a bool.
+type filterField struct {
+	int  func(*Result) int64
+	str  func(*Result) string
+	bool func(*Result) bool
+	// num gets the numeric value of str fields compared with <, <=, > and
+	// >=, if they have one.
+	num func(*Result) (float64, bool)
+	// parse parses the values compared to int fields.
+	parse func(string) (int64, error)
+}
+
+func parseFilterField(name string) (filterField, error) {
+	var f filterField
+
+	switch name {
+	case "code":
+		f.int = func(r *Result) int64 { return int64(r.Code) }
+	case "seq":
+		f.int = func(r *Result) int64 { return int64(r.Seq) }
+	case "bytes_in":
+		f.int = func(r *Result) int64 { return int64(r.BytesIn) }
+	case "bytes_out":
+		f.int = func(r *Result) int64 { return int64(r.BytesOut) }
+	case "weight":
+		f.int = func(r *Result) int64 { return int64(r.Weight) }
+	case "worker":
+		f.int = func(r *Result) int64 { return int64(r.Worker) }
+	case "attempt":
+		f.int = func(r *Result) int64 { return int64(r.Attempt) }
+	case "latency":
+		f.int = func(r *Result) int64 { return int64(r.Latency) }
+		f.parse = parseFilterDuration
+	case "conn_wait":
+		f.int = func(r *Result) int64 { return int64(r.ConnWait) }
+		f.parse = parseFilterDuration
+	case "negotiation":
+		f.int = func(r *Result) int64 { return int64(r.Negotiation) }
+		f.parse = parseFilterDuration
+	case "retry_delay":
+		f.int = func(r *Result) int64 { return int64(r.RetryDelay) }
+		f.parse = parseFilterDuration
+	case "tunnel":
+		f.int = func(r *Result) int64 { return int64(r.Tunnel) }
+		f.parse = parseFilterDuration
+	case "timestamp":
+		f.int = func(r *Result) int64 { return r.Timestamp.UnixNano() }
+		f.parse = func(s string) (int64, error) {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			return t.UnixNano(), err
+		}
+	case "attack":
+		f.str = func(r *Result) string { return r.Attack }
+	case "error":
+		f.str = func(r *Result) string { return r.Error }
+	case "body":
+		f.str = func(r *Result) string { return string(r.Body) }
+	case "method":
+		f.str = func(r *Result) string { return r.Method }
+	case "url":
+		f.str = func(r *Result) string { return r.URL }
+	case "request_body":
+		f.str = func(r *Result) string { return string(r.RequestBody) }
+	case "remote_addr":
+		f.str = func(r *Result) string { return r.RemoteAddr }
+	case "local_addr":
+		f.str = func(r *Result) string { return r.LocalAddr }
+	case "ip_family":
+		f.str = func(r *Result) string { return r.IPFamily }
+	case "trace_id":
+		f.str = func(r *Result) string { return r.TraceID }
+	case "tls_version":
+		f.str = func(r *Result) string { return r.TLSVersion }
+	case "tls_cipher_suite":
+		f.str = func(r *Result) string { return r.TLSCipherSuite }
+	case "tls_protocol":
+		f.str = func(r *Result) string { return r.TLSProtocol }
+	case "tls_resumed":
+		f.bool = func(r *Result) bool { return r.TLSResumed }
+	case "retried":
+		f.bool = func(r *Result) bool { return r.Retried }
+	case "continued":
+		f.bool = func(r *Result) bool { return r.Continued }
+	default:
+		switch i := strings.IndexByte(name, '.'); {
+		case strings.HasPrefix(name, "$"):
+			path, err := jsonpath.Parse(name)
+			if err != nil {
+				return f, err
+			}
+			f.str = func(r *Result) string { return jsonString(bodyJSON(r, path)) }
+			f.num = func(r *Result) (float64, bool) { return jsonNumber(bodyJSON(r, path)) }
+		case i < 0 || i == len(name)-1:
+		case name[:i] == "header":
+			f.str = func(r *Result) string { return r.Headers.Get(name[i+1:]) }
+		case name[:i] == "request_header":
+			f.str = func(r *Result) string { return r.RequestHeaders.Get(name[i+1:]) }
+		case name[:i] == "label":
+			f.str = func(r *Result) string { return r.Labels[name[i+1:]] }
+		}
+	}
+
+	if f.int == nil && f.str == nil && f.bool == nil {
+		return f, fmt.Errorf("unknown field %q", name)
+	}
+
+	if f.int != nil && f.parse == nil {
+		f.parse = func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) }
+	}
+
+	return f, nil
+}
+
+// parseFilterDuration parses the durations compared to duration fields.
+func parseFilterDuration(s string) (int64, error) {
+	d, err := time.ParseDuration(s)
+	return int64(d), err
+}
+
+// compare returns a Filter comparing the field to the given value.
+func (f filterField) compare(op, val string) (Filter, error) {
+	switch {
+	case f.num != nil && strings.ContainsAny(op, "<>"):
+		v, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		get := f.num
+		switch op {
+		case "<":
+			return func(r *Result) bool { n, ok := get(r); return ok && n < v }, nil
+		case "<=":
+			return func(r *Result) bool { n, ok := get(r); return ok && n <= v }, nil
+		case ">":
+			return func(r *Result) bool { n, ok := get(r); return ok && n > v }, nil
+		case ">=":
+			return func(r *Result) bool { n, ok := get(r); return ok && n >= v }, nil
+		}
+	case f.int != nil:
+		v, err := f.parse(val)
+		if err != nil {
+			return nil, err
+		}
+
+		get := f.int
+		switch op {
+		case "==":
+			return func(r *Result) bool { return get(r) == v }, nil
+		case "!=":
+			return func(r *Result) bool { return get(r) != v }, nil
+		case "<":
+			return func(r *Result) bool { return get(r) < v }, nil
+		case "<=":
+			return func(r *Result) bool { return get(r) <= v }, nil
+		case ">":
+			return func(r *Result) bool { return get(r) > v }, nil
+		case ">=":
+			return func(r *Result) bool { return get(r) >= v }, nil
+		}
+	case f.str != nil:
+		get := f.str
+		switch op {
+		case "==":
+			return func(r *Result) bool { return get(r) == val }, nil
+		case "!=":
+			return func(r *Result) bool { return get(r) != val }, nil
+		case "=~", "!~":
+			re, err := regexp.Compile(val)
+			if err != nil {
+				return nil, err
+			}
+			match := op == "=~"
+			return func(r *Result) bool { return re.MatchString(get(r)) == match }, nil
+		}
+	case f.bool != nil:
+		v, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, err
+		}
+
+		get := f.bool
+		switch op {
+		case "==":
+			return func(r *Result) bool { return get(r) == v }, nil
+		case "!=":
+			return func(r *Result) bool { return get(r) != v }, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported operator %s", op)
+}
+
+// bodyJSON returns the value of the JSON body of the given Result selected by
+// the given path, if it has one.
+func bodyJSON(r *Result, path jsonpath.Path) (interface{}, bool) {
+	dec := json.NewDecoder(bytes.NewReader(r.Body))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, false
+	}
+
+	return path.Get(doc)
+}
+
+// jsonString returns the given JSON value as compared to strings.
+func jsonString(v interface{}, ok bool) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	case nil:
+		if ok {
+			return "null"
+		}
+		return ""
+	default:
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+}
+
+// jsonNumber returns the given JSON value as compared to numbers.
+func jsonNumber(v interface{}, ok bool) (float64, bool) {
+	n, isNum := v.(json.Number)
+	if !ok || !isNum {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
@@ -0,0 +1,98 @@
+package vegeta
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseFilter(t *testing.T) {
+	t.Parallel()
+
+	r := Result{
+		Attack:      "checkout",
+		Code:        503,
+		Timestamp:   time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Latency:     300 * time.Millisecond,
+		BytesIn:     1024,
+		Error:       "503 Service Unavailable",
+		Body:        []byte(`{"error": {"code": "busy", "retry": 5, "items": [1, 2.5]}, "ok": false}`),
+		Method:      "POST",
+		URL:         "http://example.com/cart?id=1",
+		Headers:     http.Header{"Retry-After": []string{"5"}},
+		Labels:      map[string]string{"region": "eu"},
+		ConnWait:    20 * time.Millisecond,
+		IPFamily:    "ipv4",
+		Negotiation: 5 * time.Millisecond,
+		Attempt:     2,
+		Retried:     true,
+		RetryDelay:  150 * time.Millisecond,
+		Continued:   true,
+		Tunnel:      40 * time.Millisecond,
+		TraceID:     "4bf92f3577b34da6a3ce929d0e0e4736",
+	}
+
+	for _, tc := range []struct {
+		in    string
+		match bool
+		err   bool
+	}{
+		{in: "code >= 500 && latency > 250ms", match: true},
+		{in: "code>=500&&latency>1s", match: false},
+		{in: "code == 200 || latency > 250ms", match: true},
+		{in: "code == 200 || code == 404 && latency > 0s", match: false},
+		{in: "(code == 200 || code == 503) && latency > 0s", match: true},
+		{in: "!(code < 500)", match: true},
+		{in: "! code != 503", match: true},
+		{in: "bytes_in == 1024 && bytes_out == 0", match: true},
+		{in: "method == POST && attack != checkout", match: false},
+		{in: `url == "http://example.com/cart?id=1"`, match: true},
+		{in: `error =~ "^503 "`, match: true},
+		{in: `error !~ Unavailable`, match: false},
+		{in: "header.retry-after == 5 && header.Location == \"\"", match: true},
+		{in: "label.region == eu && label.build == \"\"", match: true},
+		{in: "timestamp < 2020-01-01T00:00:01Z", match: true},
+		{in: "tls_resumed == false", match: true},
+		{in: "conn_wait > 10ms && conn_wait < 1s", match: true},
+		{in: "ip_family == ipv6", match: false},
+		{in: "negotiation >= 5ms", match: true},
+		{in: "retried == true && attempt == 2 && retry_delay > 100ms", match: true},
+		{in: "continued == false", match: false},
+		{in: "tunnel > 30ms && tunnel < 50ms", match: true},
+		{in: "trace_id == 4bf92f3577b34da6a3ce929d0e0e4736", match: true},
+		{in: "$.error.code == busy && $.ok == false", match: true},
+		{in: "$.error.retry >= 5 && $.error.items[-1] < 3", match: true},
+		{in: `$.error.items == "[1,2.5]" && $.missing == ""`, match: true},
+		{in: "$.error.code > 1 || $.missing < 1", match: false},
+		{in: `$.error.code =~ "^b"`, match: true},
+		{in: "$.error.retry > five", err: true},
+		{in: "$error == 1", err: true},
+		{in: "code >= 500 &&", err: true},
+		{in: "code 500", err: true},
+		{in: "status == 500", err: true},
+		{in: "header. == 5", err: true},
+		{in: "latency > 250", err: true},
+		{in: "code > 5xx", err: true},
+		{in: "method < POST", err: true},
+		{in: "error =~ \"(\"", err: true},
+		{in: "tls_resumed > true", err: true},
+		{in: "(code == 500", err: true},
+		{in: "code == 500)", err: true},
+		{in: `url == "http://`, err: true},
+		{in: "", err: true},
+	} {
+		f, err := ParseFilter(tc.in)
+		if tc.err {
+			if err == nil {
+				t.Errorf("ParseFilter(%q): want error", tc.in)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseFilter(%q): %v", tc.in, err)
+		} else if got := f(&r); got != tc.match {
+			t.Errorf("ParseFilter(%q): got match %t, want %t", tc.in, got, tc.match)
+		}
+	}
+}
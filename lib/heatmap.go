@@ -0,0 +1,132 @@
+package vegeta
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Heatmap is a Report which counts latencies per time bucket of the given
+// Interval and per logarithmic latency bucket, following the 1-2-5 series
+// (1ms, 2ms, 5ms, 10ms, ...). Unlike percentiles, it reveals multimodal
+// latency distributions and periodic stalls.
+type Heatmap struct {
+	// Interval is the duration of each time bucket.
+	Interval time.Duration
+
+	cells map[int64]map[int]uint64
+}
+
+// Add implements the Add method of the Report interface by counting the
+// latency of the given Result in its time and latency bucket.
+func (h *Heatmap) Add(r *Result) {
+	if h.cells == nil {
+		h.cells = map[int64]map[int]uint64{}
+	}
+
+	if h.Interval <= 0 {
+		h.Interval = time.Second
+	}
+
+	ts := r.Timestamp.Truncate(h.Interval).UnixNano()
+	c, ok := h.cells[ts]
+	if !ok {
+		c = map[int]uint64{}
+		h.cells[ts] = c
+	}
+
+	c[heatmapBucket(r.Latency)]++
+}
+
+// heatmapBucket returns the index of the 1-2-5 series latency bucket
+// the given latency falls in, where the bucket i starts at heatmapBound(i).
+func heatmapBucket(latency time.Duration) int {
+	if latency < 1 {
+		latency = 1
+	}
+
+	exp := math.Floor(math.Log10(float64(latency)))
+	i := 3 * int(exp)
+	for _, m := range []float64{2, 5} {
+		if math.Pow(10, exp)*m <= float64(latency) {
+			i++
+		}
+	}
+
+	return i
+}
+
+// heatmapBound returns the lower bound of the ith latency bucket.
+func heatmapBound(i int) time.Duration {
+	return time.Duration(math.Pow(10, float64(i/3)) * []float64{1, 2, 5}[i%3])
+}
+
+// heatmapShades are the characters used to render increasing densities.
+var heatmapShades = []rune{'░', '▒', '▓', '█'}
+
+// NewHeatmapReporter returns a Reporter that writes out a Heatmap as text,
+// with a column per time bucket, from the first to the last, and a row per
+// latency bucket, from the slowest to the fastest. Each cell is shaded by
+// its number of hits relative to the densest cell.
+func NewHeatmapReporter(h *Heatmap) Reporter {
+	return func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		if len(h.cells) == 0 {
+			return bw.Flush()
+		}
+
+		keys := make([]int64, 0, len(h.cells))
+		lo, hi := math.MaxInt32, math.MinInt32
+		var max uint64
+		for ts, c := range h.cells {
+			keys = append(keys, ts)
+			for i, n := range c {
+				if i < lo {
+					lo = i
+				}
+				if i > hi {
+					hi = i
+				}
+				if n > max {
+					max = n
+				}
+			}
+		}
+
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		first, last := keys[0], keys[len(keys)-1]
+
+		labels := make([]string, hi-lo+1)
+		width := 0
+		for i := range labels {
+			if labels[i] = heatmapBound(lo + i).String(); len(labels[i]) > width {
+				width = len(labels[i])
+			}
+		}
+
+		for i := hi; i >= lo; i-- {
+			var row strings.Builder
+			for ts := first; ts <= last; ts += int64(h.Interval) {
+				n := h.cells[ts][i]
+				if n == 0 {
+					row.WriteByte(' ')
+					continue
+				}
+				shade := int(math.Ceil(float64(len(heatmapShades))*float64(n)/float64(max))) - 1
+				row.WriteRune(heatmapShades[shade])
+			}
+			fmt.Fprintf(bw, "%*s │%s\n", width, labels[i-lo], strings.TrimRight(row.String(), " "))
+		}
+
+		columns := int((last-first)/int64(h.Interval)) + 1
+		fmt.Fprintf(bw, "%*s └%s\n", width, "", strings.Repeat("─", columns))
+		fmt.Fprintf(bw, "%*s  %s per column, %s = up to %d hits\n",
+			width, "", h.Interval, string(heatmapShades[len(heatmapShades)-1]), max)
+
+		return bw.Flush()
+	}
+}
@@ -0,0 +1,62 @@
+package vegeta
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeatmapBucket(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		latency time.Duration
+		bound   time.Duration
+	}{
+		{0, 1},
+		{1, 1},
+		{3, 2},
+		{7, 5},
+		{time.Millisecond, time.Millisecond},
+		{1999 * time.Microsecond, time.Millisecond},
+		{2 * time.Millisecond, 2 * time.Millisecond},
+		{49 * time.Millisecond, 20 * time.Millisecond},
+		{50 * time.Millisecond, 50 * time.Millisecond},
+		{3 * time.Second, 2 * time.Second},
+	} {
+		if got := heatmapBound(heatmapBucket(tc.latency)); got != tc.bound {
+			t.Errorf("latency %s: got bucket starting at %s, want %s", tc.latency, got, tc.bound)
+		}
+	}
+}
+
+func TestHeatmapReporter(t *testing.T) {
+	t.Parallel()
+
+	h := Heatmap{Interval: time.Second}
+	start := time.Unix(0, 0)
+	for i := 0; i < 4; i++ {
+		h.Add(&Result{Timestamp: start, Latency: time.Millisecond})
+	}
+	h.Add(&Result{Timestamp: start.Add(2 * time.Second), Latency: 12 * time.Millisecond})
+
+	var buf bytes.Buffer
+	if err := NewHeatmapReporter(&h).Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"10ms │  ░",
+		" 5ms │",
+		" 2ms │",
+		" 1ms │█",
+		"     └───",
+		"      1s per column, █ = up to 4 hits",
+		"",
+	}, "\n")
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
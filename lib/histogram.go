@@ -3,6 +3,8 @@ package vegeta
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -11,29 +13,151 @@ import (
 type Buckets []time.Duration
 
 // Histogram is a bucketed latency Histogram.
+//
+// If a Histogram has no Buckets when the first Result is added, the latencies
+// of all Results are kept and log-spaced Buckets covering their range are
+// derived with LogBuckets when the Histogram is closed.
 type Histogram struct {
 	Buckets Buckets
 	Counts  []uint64
 	Total   uint64
+
+	auto      bool
+	latencies []time.Duration
+	weights   []uint64 // of latencies, only once a sampled Result is added
 }
 
 // Add implements the Add method of the Report interface by finding the right
-// Bucket for the given Result latency and increasing its count by one as well
-// as the total count.
+// Bucket for the given Result latency and increasing its count by one, or by
+// the Weight of sampled Results, as well as the total count.
 func (h *Histogram) Add(r *Result) {
+	w := r.weight()
+	if h.auto || len(h.Buckets) == 0 {
+		h.auto = true
+		if w != 1 {
+			h.weigh()
+		}
+		h.latencies = append(h.latencies, r.Latency)
+		if h.weights != nil {
+			h.weights = append(h.weights, w)
+		}
+		h.Total += w
+		return
+	}
+
+	h.add(r.Latency, w)
+	h.Total += w
+}
+
+// weigh starts keeping the weights of the latencies of an auto Histogram.
+func (h *Histogram) weigh() {
+	if h.weights != nil {
+		return
+	}
+	h.weights = make([]uint64, len(h.latencies), cap(h.latencies))
+	for i := range h.weights {
+		h.weights[i] = 1
+	}
+}
+
+func (h *Histogram) add(latency time.Duration, weight uint64) {
 	if len(h.Counts) != len(h.Buckets) {
 		h.Counts = make([]uint64, len(h.Buckets))
 	}
 
 	var i int
 	for ; i < len(h.Buckets)-1; i++ {
-		if r.Latency >= h.Buckets[i] && r.Latency < h.Buckets[i+1] {
+		if latency >= h.Buckets[i] && latency < h.Buckets[i+1] {
+			break
+		}
+	}
+
+	h.Counts[i] += weight
+}
+
+// Merge merges the counts of the given Histogram, which must have the same
+// Buckets, into h.
+func (h *Histogram) Merge(o *Histogram) {
+	if o.auto {
+		h.auto = true
+		if h.weights != nil || o.weights != nil {
+			h.weigh()
+			o.weigh()
+			h.weights = append(h.weights, o.weights...)
+		}
+		h.latencies = append(h.latencies, o.latencies...)
+	} else if len(o.Counts) > 0 {
+		if len(h.Counts) != len(h.Buckets) {
+			h.Counts = make([]uint64, len(h.Buckets))
+		}
+		for i, n := range o.Counts {
+			h.Counts[i] += n
+		}
+	}
+
+	h.Total += o.Total
+}
+
+// Close implements the Closer interface by deriving the Buckets of a
+// Histogram which had none from the range of its latencies.
+func (h *Histogram) Close() {
+	if !h.auto || len(h.latencies) == 0 {
+		return
+	}
+
+	min, max := h.latencies[0], h.latencies[0]
+	for _, l := range h.latencies {
+		if l < min {
+			min = l
+		}
+		if l > max {
+			max = l
+		}
+	}
+
+	h.Buckets = LogBuckets(min, max)
+	h.Counts = make([]uint64, len(h.Buckets))
+	for i, l := range h.latencies {
+		if h.weights != nil {
+			h.add(l, h.weights[i])
+		} else {
+			h.add(l, 1)
+		}
+	}
+}
+
+// LogBuckets returns log-spaced Buckets covering the given latency range with
+// boundaries in a 1-2-5 sequence (e.g. 1ms, 2ms, 5ms, 10ms, 20ms...), plus
+// a first [0, min) bucket.
+func LogBuckets(min, max time.Duration) Buckets {
+	if min < 1 {
+		min = 1
+	}
+
+	// Largest 1-2-5 boundary lower or equal than min.
+	exp := math.Pow(10, math.Floor(math.Log10(float64(min))))
+	step := 0
+	for _, m := range []float64{2, 5} {
+		if exp*m <= float64(min) {
+			step++
+		}
+	}
+
+	bs := Buckets{0}
+	for {
+		d := time.Duration(exp * []float64{1, 2, 5}[step])
+		if len(bs) > 1 && d > max {
 			break
 		}
+
+		bs = append(bs, d)
+
+		if step++; step == 3 {
+			step, exp = 0, exp*10
+		}
 	}
 
-	h.Total++
-	h.Counts[i]++
+	return bs
 }
 
 // MarshalJSON returns a JSON encoding of the buckets and their counts.
@@ -64,7 +188,14 @@ func (bs Buckets) Nth(i int) (left, right string) {
 }
 
 // UnmarshalText implements the encoding.TextUnmarshaler interface.
+// Besides a list of durations like "[0,1ms,10ms]", it accepts
+// exponential buckets given as "exp(start,factor,count)", e.g.
+// "exp(1ms,2,20)" is "[0,1ms,2ms,4ms,...,524.288s]".
 func (bs *Buckets) UnmarshalText(value []byte) error {
+	if v := string(value); strings.HasPrefix(v, "exp(") && strings.HasSuffix(v, ")") {
+		return bs.unmarshalExp(v[4 : len(v)-1])
+	}
+
 	if len(value) < 2 || value[0] != '[' || value[len(value)-1] != ']' {
 		return fmt.Errorf("bad buckets: %s", value)
 	}
@@ -84,3 +215,35 @@ func (bs *Buckets) UnmarshalText(value []byte) error {
 	}
 	return nil
 }
+
+func (bs *Buckets) unmarshalExp(args string) error {
+	ps := strings.Split(args, ",")
+	if len(ps) != 3 {
+		return fmt.Errorf("bad exponential buckets: exp(%s) must be exp(start,factor,count)", args)
+	}
+
+	start, err := time.ParseDuration(strings.TrimSpace(ps[0]))
+	if err != nil || start <= 0 {
+		return fmt.Errorf("bad exponential buckets start: %q", ps[0])
+	}
+
+	factor, err := strconv.ParseFloat(strings.TrimSpace(ps[1]), 64)
+	if err != nil || factor <= 1 {
+		return fmt.Errorf("bad exponential buckets factor: %q", ps[1])
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(ps[2]))
+	if err != nil || count <= 0 {
+		return fmt.Errorf("bad exponential buckets count: %q", ps[2])
+	}
+
+	*bs = append(*bs, 0)
+	for i, d := 0, float64(start); i < count; i, d = i+1, d*factor {
+		if d > math.MaxInt64 {
+			return fmt.Errorf("bad exponential buckets: exp(%s) overflows", args)
+		}
+		*bs = append(*bs, time.Duration(d))
+	}
+
+	return nil
+}
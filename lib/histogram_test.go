@@ -39,6 +39,32 @@ func TestHistogram_Add(t *testing.T) {
 	}
 }
 
+func TestHistogram_Merge(t *testing.T) {
+	t.Parallel()
+	buckets := []time.Duration{0, 10 * time.Millisecond, 100 * time.Millisecond}
+
+	a, b := Histogram{Buckets: buckets}, Histogram{Buckets: buckets}
+	for _, d := range []time.Duration{5 * time.Millisecond, 50 * time.Millisecond} {
+		a.Add(&Result{Latency: d})
+	}
+	for _, d := range []time.Duration{1 * time.Millisecond, 500 * time.Millisecond, 600 * time.Millisecond} {
+		b.Add(&Result{Latency: d})
+	}
+
+	var got Histogram
+	got.Buckets = buckets
+	got.Merge(&a)
+	got.Merge(&b)
+
+	if want := []uint64{2, 1, 2}; !reflect.DeepEqual(got.Counts, want) {
+		t.Errorf("Counts: got: %v, want: %v", got.Counts, want)
+	}
+
+	if got, want := got.Total, uint64(5); got != want {
+		t.Errorf("Total: got %v, want: %v", got, want)
+	}
+}
+
 func TestBuckets_UnmarshalText(t *testing.T) {
 	t.Parallel()
 	for value, want := range map[string]string{
@@ -67,3 +93,76 @@ func TestBuckets_UnmarshalText(t *testing.T) {
 		}
 	}
 }
+
+func TestHistogram_AutoBuckets(t *testing.T) {
+	t.Parallel()
+
+	var hist Histogram
+	for _, d := range []time.Duration{
+		3 * time.Millisecond,
+		7 * time.Millisecond,
+		15 * time.Millisecond,
+		40 * time.Millisecond,
+	} {
+		hist.Add(&Result{Latency: d})
+	}
+
+	hist.Close()
+
+	ms := time.Millisecond
+	if got, want := hist.Buckets, (Buckets{0, 2 * ms, 5 * ms, 10 * ms, 20 * ms}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Buckets: got: %v, want: %v", got, want)
+	}
+
+	if got, want := hist.Counts, []uint64{0, 1, 1, 1, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Counts: got: %v, want: %v", got, want)
+	}
+
+	if got, want := hist.Total, uint64(4); got != want {
+		t.Errorf("Total: got %v, want: %v", got, want)
+	}
+}
+
+func TestLogBuckets(t *testing.T) {
+	t.Parallel()
+
+	us, ms := time.Microsecond, time.Millisecond
+	for _, tc := range []struct {
+		min, max time.Duration
+		want     Buckets
+	}{
+		{ms, ms, Buckets{0, ms}},
+		{ms, 10 * ms, Buckets{0, ms, 2 * ms, 5 * ms, 10 * ms}},
+		{700 * us, 3 * ms, Buckets{0, 500 * us, ms, 2 * ms}},
+		{0, 3, Buckets{0, 1, 2}},
+	} {
+		if got := LogBuckets(tc.min, tc.max); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("LogBuckets(%v, %v): got %v, want %v", tc.min, tc.max, got, tc.want)
+		}
+	}
+}
+
+func TestBuckets_UnmarshalTextExp(t *testing.T) {
+	t.Parallel()
+
+	ms := time.Millisecond
+
+	var got Buckets
+	if err := got.UnmarshalText([]byte("exp(1ms, 2, 5)")); err != nil {
+		t.Fatal(err)
+	} else if want := (Buckets{0, ms, 2 * ms, 4 * ms, 8 * ms, 16 * ms}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+
+	for _, value := range []string{
+		"exp(1ms,2)",
+		"exp(0,2,5)",
+		"exp(1ms,1,5)",
+		"exp(1ms,2,0)",
+		"exp(1h,10,100)",
+	} {
+		if err := (&Buckets{}).UnmarshalText([]byte(value)); err == nil {
+			t.Errorf("%s: want error, got none", value)
+		}
+	}
+}
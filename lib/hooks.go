@@ -0,0 +1,97 @@
+package vegeta
+
+import (
+	"net/http"
+)
+
+// WithRoundTripper returns a functional option which makes an Attacker send
+// its requests through the http.RoundTripper the given function wraps its
+// transport with, e.g. to trace them, or to replace the transport with a mock
+// one. Since it's applied after all the other options of NewAttacker, which
+// configure the wrapped transport, it can be given in any order among them.
+// The transports of targets with their own client certificate or server
+// name, and of NTLM authenticated connections, are wrapped alike. The
+// functions of several WithRoundTripper options wrap in the order they're
+// given, so that the last one is the outermost.
+func WithRoundTripper(wrap func(http.RoundTripper) http.RoundTripper) func(*Attacker) {
+	return func(a *Attacker) {
+		prev := a.wrap
+		if prev == nil {
+			a.wrap = wrap
+			return
+		}
+		a.wrap = func(rt http.RoundTripper) http.RoundTripper { return wrap(prev(rt)) }
+	}
+}
+
+// BeforeHit returns a functional option which makes an Attacker call the
+// given function with each request, and the Target it was made from, right
+// before it's authorized and sent, failing its hit with the error it returns,
+// if any. The functions of several BeforeHit options are called in the order
+// they're given.
+func BeforeHit(before func(*Target, *http.Request) error) func(*Attacker) {
+	return func(a *Attacker) {
+		prev := a.beforeHit
+		if prev == nil {
+			a.beforeHit = before
+			return
+		}
+
+		a.beforeHit = func(tgt *Target, req *http.Request) error {
+			if err := prev(tgt, req); err != nil {
+				return err
+			}
+			return before(tgt, req)
+		}
+	}
+}
+
+// AfterHit returns a functional option which makes an Attacker call the
+// given function with the Result of each hit, including the attempts which
+// are retried, once it's recorded and before it's sent, so that it can
+// inspect or change it. The functions of several AfterHit options are called
+// in the order they're given.
+func AfterHit(after func(*Result)) func(*Attacker) {
+	return func(a *Attacker) {
+		prev := a.afterHit
+		if prev == nil {
+			a.afterHit = after
+			return
+		}
+
+		a.afterHit = func(r *Result) {
+			prev(r)
+			after(r)
+		}
+	}
+}
+
+// transport returns the transport of the client of the Attacker, before
+// WithRoundTripper wrapped it, if it's an *http.Transport.
+func (a *Attacker) transport() (*http.Transport, bool) {
+	rt := a.client.Transport
+	if a.base != nil {
+		rt = a.base
+	}
+	tr, ok := rt.(*http.Transport)
+	return tr, ok
+}
+
+// wrapTransport returns the given transport wrapped by the functions of the
+// WithRoundTripper options of the Attacker, if any.
+func (a *Attacker) wrapTransport(rt http.RoundTripper) http.RoundTripper {
+	if a.wrap == nil {
+		return rt
+	}
+	return a.wrap(rt)
+}
+
+// closeIdleConnections closes the idle connections of the client of the
+// Attacker, including those of its transport, which wrappers of it may not
+// close.
+func (a *Attacker) closeIdleConnections() {
+	if c, ok := a.base.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+	a.client.CloseIdleConnections()
+}
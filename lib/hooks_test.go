@@ -0,0 +1,134 @@
+package vegeta
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// roundTripperFunc is an http.RoundTripper calling itself.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestWithRoundTripper(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Traceparent") + " " + r.Header.Get("X-Outer")))
+	}))
+	defer server.Close()
+
+	var sent int64
+	tracing := func(rt http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt64(&sent, 1)
+			req.Header.Set("Traceparent", "00-trace")
+			return rt.RoundTrip(req)
+		})
+	}
+	outer := func(rt http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Outer", req.Header.Get("Traceparent")+"-outer")
+			return rt.RoundTrip(req)
+		})
+	}
+
+	// Options configuring the transport work in any order.
+	atk := NewAttacker(WithRoundTripper(tracing), WithRoundTripper(outer), Connections(1))
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+
+	if res := atk.hit(tr, "", 1); string(res.Body) != "00-trace -outer" {
+		t.Errorf("got body %q, want the headers of both wrappers in order", res.Body)
+	}
+
+	if err := atk.Prewarm(1, server.URL); err != nil {
+		t.Errorf("got error prewarming a wrapped transport: %v", err)
+	}
+
+	// Clients of targets' server names wrap their transports alike.
+	sni := NewStaticTargeter(Target{Method: "GET", URL: server.URL, SNI: "example.com"})
+	if res := atk.hit(sni, "", 1); res.Error != "" || atomic.LoadInt64(&sent) != 2 {
+		t.Errorf("got error %q after %d wrapped requests, want 2", res.Error, sent)
+	}
+
+	mock := func(http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusTeapot,
+				Status:     "418 I'm a teapot",
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader("mocked")),
+				Request:    req,
+			}, nil
+		})
+	}
+
+	res := NewAttacker(WithRoundTripper(mock)).hit(NewStaticTargeter(Target{Method: "GET", URL: "http://unreachable.invalid"}), "", 1)
+	if res.Code != http.StatusTeapot || string(res.Body) != "mocked" {
+		t.Errorf("got %d %q from a mock transport, want 418 mocked", res.Code, res.Body)
+	}
+}
+
+func TestHitHooks(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Hooks")))
+	}))
+	defer server.Close()
+
+	before := func(name string) func(*Target, *http.Request) error {
+		return func(tgt *Target, req *http.Request) error {
+			if tgt.URL != server.URL {
+				return errors.New("no target")
+			} else if req.Header.Get("X-Vegeta-Seq") == "1" {
+				return errors.New("skipped")
+			}
+			req.Header.Set("X-Hooks", req.Header.Get("X-Hooks")+name)
+			return nil
+		}
+	}
+
+	var results []string
+	after := func(r *Result) {
+		results = append(results, string(r.Body)+r.Error)
+		if r.Code == http.StatusOK && len(r.Body) == 0 {
+			r.Error = "empty body"
+		}
+	}
+
+	atk := NewAttacker(
+		BeforeHit(before("a")),
+		BeforeHit(before("b")),
+		Authorizer(func(req *http.Request) error {
+			req.Header.Set("X-Hooks", req.Header.Get("X-Hooks")+"+auth")
+			return nil
+		}),
+		AfterHit(after),
+	)
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+
+	if res := atk.hit(tr, "", 1); string(res.Body) != "ab+auth" || res.Error != "" {
+		t.Errorf("got %q (%s), want the hooks called in order before authorization", res.Body, res.Error)
+	}
+
+	if res := atk.hit(tr, "", 1); res.Error != "skipped" || res.Code != 0 {
+		t.Errorf("got error %q with code %d, want the unsent request's error", res.Error, res.Code)
+	}
+
+	if want := []string{"ab+auth", "skipped"}; strings.Join(results, ",") != strings.Join(want, ",") {
+		t.Errorf("got results %q after hits, want %q", results, want)
+	}
+
+	atk = NewAttacker(AfterHit(after))
+	if res := atk.hit(tr, "", 1); res.Error != "empty body" {
+		t.Errorf("got error %q, want the one set after the hit", res.Error)
+	}
+}
@@ -0,0 +1,219 @@
+package vegeta
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"io"
+	"time"
+)
+
+// IndexMagic marks the start and the end of the index footer of gob encoded
+// results files, so that files ending with it can be told to be indexed. Its
+// first byte can't start a gob message, so that the gob Decoder can tell the
+// footer apart from the Results preceding it.
+const IndexMagic = "\x80VGTAIDX"
+
+// indexBlockSize is the number of Results in each indexed block.
+const indexBlockSize = 4096
+
+// ErrNoIndex is returned when decoding a results file without an index footer
+// with NewIndexedDecoder.
+var ErrNoIndex = errors.New("results file has no index")
+
+// An IndexBlock locates a block of consecutive Results in a gob encoded
+// results file with an index footer.
+type IndexBlock struct {
+	Offset   int64     // Offset of the first Result of the block in the file
+	Results  int       // Number of Results in the block
+	Earliest time.Time // Earliest timestamp of the Results in the block
+	Latest   time.Time // Latest timestamp of the Results in the block
+}
+
+// NewIndexedEncoder returns an Encoder which gob encodes Results like the
+// one returned by NewEncoder, after the same version header, and an io.Closer
+// which writes an index footer of the blocks of encoded Results when closed.
+// The footer is skipped by the gob Decoder and lets NewIndexedDecoder seek to
+// the blocks of Results in a time range. It's written as:
+//
+//	IndexMagic | gob encoded []IndexBlock | footer offset (uint64 BE) | IndexMagic
+func NewIndexedEncoder(w io.Writer) (Encoder, io.Closer) {
+	var (
+		offset int64
+		blocks []IndexBlock
+		cw     = &offsetWriter{w, &offset}
+		enc    = gob.NewEncoder(cw)
+	)
+
+	encode := func(r *Result) error {
+		if offset == 0 {
+			if _, err := io.WriteString(cw, gobHeader); err != nil {
+				return err
+			}
+		}
+
+		if n := len(blocks); n == 0 || blocks[n-1].Results == indexBlockSize {
+			blocks = append(blocks, IndexBlock{Offset: offset, Earliest: r.Timestamp, Latest: r.Timestamp})
+		}
+
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+
+		b := &blocks[len(blocks)-1]
+		b.Results++
+		if r.Timestamp.Before(b.Earliest) {
+			b.Earliest = r.Timestamp
+		} else if r.Timestamp.After(b.Latest) {
+			b.Latest = r.Timestamp
+		}
+
+		return nil
+	}
+
+	writeFooter := func() error {
+		var footer bytes.Buffer
+		footer.WriteString(IndexMagic)
+		if err := gob.NewEncoder(&footer).Encode(blocks); err != nil {
+			return err
+		}
+
+		var tail [8]byte
+		binary.BigEndian.PutUint64(tail[:], uint64(offset))
+		footer.Write(tail[:])
+		footer.WriteString(IndexMagic)
+
+		_, err := w.Write(footer.Bytes())
+		return err
+	}
+
+	return encode, closerFunc(writeFooter)
+}
+
+// offsetWriter counts the bytes written through it.
+type offsetWriter struct {
+	io.Writer
+	n *int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	*w.n += int64(n)
+	return n, err
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+// ReadIndex returns the index of the given gob encoded results file contents
+// and the offset of its footer, or ErrNoIndex if it has none.
+func ReadIndex(data []byte) ([]IndexBlock, int64, error) {
+	n := len(data) - 8 - len(IndexMagic)
+	if n < len(IndexMagic) || string(data[n+8:]) != IndexMagic {
+		return nil, 0, ErrNoIndex
+	}
+
+	offset := binary.BigEndian.Uint64(data[n : n+8])
+	if offset > uint64(n-len(IndexMagic)) || string(data[offset:offset+uint64(len(IndexMagic))]) != IndexMagic {
+		return nil, 0, errors.New("index: bad footer offset")
+	}
+
+	var blocks []IndexBlock
+	footer := bytes.NewReader(data[int(offset)+len(IndexMagic) : n])
+	if err := gob.NewDecoder(footer).Decode(&blocks); err != nil {
+		return nil, 0, err
+	}
+
+	for _, b := range blocks {
+		if b.Offset < 0 || b.Offset >= int64(offset) || b.Results < 0 {
+			return nil, 0, errors.New("index: bad block")
+		}
+	}
+
+	return blocks, int64(offset), nil
+}
+
+// NewIndexedDecoder returns a Decoder of the Results in the given contents of
+// a gob encoded results file with an index footer, e.g. a memory mapped file.
+// Results are decoded directly from data, without buffering them, and blocks
+// of Results entirely outside of the time range [from, to) are skipped. Zero
+// from or to times leave the range unbounded on that end. Results in the
+// decoded blocks may still be out of range.
+func NewIndexedDecoder(data []byte, from, to time.Time) (Decoder, error) {
+	blocks, footer, err := ReadIndex(data)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := PeekGobVersion(bufio.NewReader(bytes.NewReader(data[:footer])))
+	if err != nil {
+		return nil, err
+	}
+
+	start := int64(gobHeaderSize(v))
+	rd := &sliceReader{data: data[:footer], off: start}
+	dec := gob.NewDecoder(rd)
+
+	var (
+		primed bool
+		left   int
+	)
+
+	return func(r *Result) error {
+		for left == 0 {
+			if len(blocks) == 0 {
+				return io.EOF
+			}
+
+			b := blocks[0]
+			blocks = blocks[1:]
+
+			if (!from.IsZero() && b.Latest.Before(from)) || (!to.IsZero() && !b.Earliest.Before(to)) {
+				continue
+			}
+
+			// Type definitions are sent by the gob Encoder along with the first
+			// Result, so it must be decoded before seeking past it.
+			if !primed && b.Offset > start {
+				if err := dec.Decode(&Result{}); err != nil {
+					return err
+				}
+			}
+
+			primed = true
+			rd.off, left = b.Offset, b.Results
+		}
+
+		left--
+		return dec.Decode(r)
+	}, nil
+}
+
+// sliceReader is an io.Reader and io.ByteReader of a byte slice. The gob
+// Decoder reads exactly one message at a time from io.ByteReaders, instead of
+// buffering them, which lets sliceReaders be seeked between messages.
+type sliceReader struct {
+	data []byte
+	off  int64
+}
+
+func (r *sliceReader) Read(p []byte) (int, error) {
+	if r.off >= int64(len(r.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.data[r.off:])
+	r.off += int64(n)
+	return n, nil
+}
+
+func (r *sliceReader) ReadByte() (byte, error) {
+	if r.off >= int64(len(r.data)) {
+		return 0, io.EOF
+	}
+	b := r.data[r.off]
+	r.off++
+	return b, nil
+}
@@ -0,0 +1,110 @@
+package vegeta
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIndexedEncoding(t *testing.T) {
+	t.Parallel()
+
+	begin := time.Unix(1e9, 0).UTC()
+	want := make([]Result, 3*indexBlockSize+10)
+	for i := range want {
+		want[i] = Result{
+			Seq:       uint64(i),
+			Code:      200,
+			Timestamp: begin.Add(time.Duration(i) * time.Millisecond),
+			Latency:   time.Duration(i) * time.Microsecond,
+			Method:    "GET",
+			URL:       "http://localhost",
+			Headers:   http.Header{"X-Seq": {"1"}},
+		}
+	}
+
+	var buf bytes.Buffer
+	enc, closer := NewIndexedEncoder(&buf)
+	for i := range want {
+		if err := enc.Encode(&want[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	blocks, _, err := ReadIndex(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	} else if len(blocks) != 4 {
+		t.Fatalf("got %d blocks, want 4", len(blocks))
+	} else if got := blocks[1].Earliest; !got.Equal(want[indexBlockSize].Timestamp) {
+		t.Errorf("got block earliest %v, want %v", got, want[indexBlockSize].Timestamp)
+	}
+
+	decodeAll := func(dec Decoder) (rs []Result) {
+		for {
+			var r Result
+			if err := dec.Decode(&r); err == io.EOF {
+				return rs
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			r.Timestamp = r.Timestamp.UTC()
+			rs = append(rs, r)
+		}
+	}
+
+	t.Run("gob", func(t *testing.T) {
+		if got := decodeAll(NewDecoder(bytes.NewReader(buf.Bytes()))); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %d results, want %d", len(got), len(want))
+		}
+	})
+
+	t.Run("auto", func(t *testing.T) {
+		if got := decodeAll(DecoderFor(bytes.NewReader(buf.Bytes()))); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %d results, want %d", len(got), len(want))
+		}
+	})
+
+	for _, tc := range []struct {
+		name     string
+		from, to time.Time
+		want     []Result
+	}{
+		{"all", time.Time{}, time.Time{}, want},
+		{"from", want[2*indexBlockSize+1].Timestamp, time.Time{}, want[2*indexBlockSize:]},
+		{"to", time.Time{}, want[indexBlockSize].Timestamp, want[:indexBlockSize]},
+		{"range", want[indexBlockSize+1].Timestamp, want[2*indexBlockSize-1].Timestamp, want[indexBlockSize : 2*indexBlockSize]},
+		{"none", want[len(want)-1].Timestamp.Add(time.Second), time.Time{}, nil},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			dec, err := NewIndexedDecoder(buf.Bytes(), tc.from, tc.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := decodeAll(dec)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %d results, want %d", len(got), len(tc.want))
+			}
+		})
+	}
+
+	t.Run("no index", func(t *testing.T) {
+		var plain bytes.Buffer
+		if err := NewEncoder(&plain).Encode(&want[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewIndexedDecoder(plain.Bytes(), time.Time{}, time.Time{}); err != ErrNoIndex {
+			t.Errorf("got error %v, want %v", err, ErrNoIndex)
+		}
+	})
+}
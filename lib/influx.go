@@ -0,0 +1,138 @@
+package vegeta
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// InfluxMeasurement is the name of the InfluxDB measurement that per Result
+// points are written to by the InfluxDB line protocol Encoder.
+const InfluxMeasurement = "vegeta_results"
+
+// NewInfluxEncoder returns an Encoder that writes each Result as a point in the
+// InfluxDB line protocol. The attack name, method, URL and status code are
+// written as tags while the latency (in ns), bytes in and out, sequence number
+// and error are written as fields. The point's timestamp is the Result's
+// timestamp in nanosecond precision.
+func NewInfluxEncoder(w io.Writer) Encoder {
+	var buf []byte
+	return func(r *Result) error {
+		buf = append(buf[:0], InfluxMeasurement...)
+		buf = appendInfluxTag(buf, "attack", r.Attack)
+		buf = appendInfluxTag(buf, "method", r.Method)
+		buf = appendInfluxTag(buf, "url", r.URL)
+		buf = appendInfluxTag(buf, "code", strconv.FormatUint(uint64(r.Code), 10))
+
+		buf = append(buf, " latency="...)
+		buf = strconv.AppendInt(buf, r.Latency.Nanoseconds(), 10)
+		buf = append(buf, "i,bytes_in="...)
+		buf = strconv.AppendUint(buf, r.BytesIn, 10)
+		buf = append(buf, "i,bytes_out="...)
+		buf = strconv.AppendUint(buf, r.BytesOut, 10)
+		buf = append(buf, "i,seq="...)
+		buf = strconv.AppendUint(buf, r.Seq, 10)
+		buf = append(buf, "i,error="...)
+		buf = appendInfluxString(buf, r.Error)
+
+		buf = append(buf, ' ')
+		buf = strconv.AppendInt(buf, r.Timestamp.UnixNano(), 10)
+		buf = append(buf, '\n')
+
+		_, err := w.Write(buf)
+		return err
+	}
+}
+
+// NewInfluxReporter returns a Reporter that writes out Metrics as points in the
+// InfluxDB line protocol: one vegeta_metrics point with the summary metrics
+// and one vegeta_status_codes point per status code, all timestamped with the
+// end of the attack.
+func NewInfluxReporter(m *Metrics) Reporter {
+	return func(w io.Writer) error {
+		ts := strconv.AppendInt(nil, m.End.UnixNano(), 10)
+
+		buf := append([]byte(nil), "vegeta_metrics"...)
+		for i, f := range []struct {
+			key string
+			val int64
+		}{
+			{"requests", int64(m.Requests)},
+			{"duration", m.Duration.Nanoseconds()},
+			{"wait", m.Wait.Nanoseconds()},
+			{"latency_min", m.Latencies.Min.Nanoseconds()},
+			{"latency_mean", m.Latencies.Mean.Nanoseconds()},
+			{"latency_p50", m.Latencies.P50.Nanoseconds()},
+			{"latency_p90", m.Latencies.P90.Nanoseconds()},
+			{"latency_p95", m.Latencies.P95.Nanoseconds()},
+			{"latency_p99", m.Latencies.P99.Nanoseconds()},
+			{"latency_max", m.Latencies.Max.Nanoseconds()},
+			{"bytes_in", int64(m.BytesIn.Total)},
+			{"bytes_out", int64(m.BytesOut.Total)},
+		} {
+			if i == 0 {
+				buf = append(buf, ' ')
+			} else {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, f.key...)
+			buf = append(buf, '=')
+			buf = strconv.AppendInt(buf, f.val, 10)
+			buf = append(buf, 'i')
+		}
+
+		buf = append(buf, ",rate="...)
+		buf = strconv.AppendFloat(buf, m.Rate, 'f', -1, 64)
+		buf = append(buf, ",throughput="...)
+		buf = strconv.AppendFloat(buf, m.Throughput, 'f', -1, 64)
+		buf = append(buf, ",success="...)
+		buf = strconv.AppendFloat(buf, m.Success, 'f', -1, 64)
+		buf = append(buf, ' ')
+		buf = append(buf, ts...)
+		buf = append(buf, '\n')
+
+		codes := make([]string, 0, len(m.StatusCodes))
+		for code := range m.StatusCodes {
+			codes = append(codes, code)
+		}
+
+		sort.Strings(codes)
+
+		for _, code := range codes {
+			buf = append(buf, "vegeta_status_codes"...)
+			buf = appendInfluxTag(buf, "code", code)
+			buf = append(buf, " count="...)
+			buf = strconv.AppendInt(buf, int64(m.StatusCodes[code]), 10)
+			buf = append(buf, "i "...)
+			buf = append(buf, ts...)
+			buf = append(buf, '\n')
+		}
+
+		_, err := w.Write(buf)
+		return err
+	}
+}
+
+var (
+	influxTagEscaper    = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+	influxStringEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, "\n", `\n`)
+)
+
+// appendInfluxTag appends the given tag to buf. Tags with empty values are
+// omitted since they are invalid in the line protocol.
+func appendInfluxTag(buf []byte, key, val string) []byte {
+	if val == "" {
+		return buf
+	}
+	buf = append(buf, ',')
+	buf = append(buf, key...)
+	buf = append(buf, '=')
+	return append(buf, influxTagEscaper.Replace(val)...)
+}
+
+func appendInfluxString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	buf = append(buf, influxStringEscaper.Replace(s)...)
+	return append(buf, '"')
+}
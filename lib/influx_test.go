@@ -0,0 +1,81 @@
+package vegeta
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestInfluxEncoder(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	enc := NewInfluxEncoder(&buf)
+
+	for _, r := range []Result{
+		{
+			Attack:    "big bang",
+			Seq:       1,
+			Code:      200,
+			Timestamp: time.Unix(0, 1e9),
+			Latency:   5 * time.Millisecond,
+			BytesIn:   10,
+			BytesOut:  20,
+			Method:    "GET",
+			URL:       "http://goku/a,b=c",
+		},
+		{
+			Seq:       2,
+			Timestamp: time.Unix(0, 2e9),
+			Latency:   time.Second,
+			Error:     `dial "goku": refused`,
+		},
+	} {
+		r := r
+		if err := enc.Encode(&r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := `vegeta_results,attack=big\ bang,method=GET,url=http://goku/a\,b\=c,code=200 latency=5000000i,bytes_in=10i,bytes_out=20i,seq=1i,error="" 1000000000
+vegeta_results,code=0 latency=1000000000i,bytes_in=0i,bytes_out=0i,seq=2i,error="dial \"goku\": refused" 2000000000
+`
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestInfluxReporter(t *testing.T) {
+	t.Parallel()
+
+	var m Metrics
+	for i := 1; i <= 4; i++ {
+		m.Add(&Result{
+			Code:      uint16(200 + 300*(i%2)),
+			Timestamp: time.Unix(int64(i), 0),
+			Latency:   time.Duration(i) * time.Millisecond,
+			BytesIn:   100,
+		})
+	}
+	m.Close()
+
+	var buf bytes.Buffer
+	if err := NewInfluxReporter(&m).Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if got, want := len(lines), 3; got != want {
+		t.Fatalf("got %d lines, want %d:\n%s", got, want, buf.Bytes())
+	}
+
+	for i, prefix := range []string{
+		"vegeta_metrics requests=4i,duration=3000000000i,",
+		"vegeta_status_codes,code=200 count=2i 4004000000",
+		"vegeta_status_codes,code=500 count=2i 4004000000",
+	} {
+		if !bytes.HasPrefix(lines[i], []byte(prefix)) {
+			t.Errorf("line %d: got %q, want prefix %q", i, lines[i], prefix)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package vegeta
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// DefaultSLOs are the SLOs asserted by the JUnit reporter when none are given:
+// every request must succeed.
+var DefaultSLOs = []SLO{{Metric: "success", Op: ">=", Threshold: 1}}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// NewJUnitReporter returns a Reporter that writes out Metrics as a JUnit XML
+// test suite with a test case per given SLO, failed when the Metrics don't
+// meet it, and the top level metrics as properties. CI systems such as
+// Jenkins or GitLab display these reports natively.
+// If no SLOs are given, DefaultSLOs are asserted.
+func NewJUnitReporter(m *Metrics, slos []SLO) Reporter {
+	if len(slos) == 0 {
+		slos = DefaultSLOs
+	}
+
+	return func(w io.Writer) error {
+		seconds := func(d time.Duration) string {
+			return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+		}
+
+		suite := junitTestSuite{
+			Name:  "vegeta",
+			Tests: len(slos),
+			Time:  seconds(m.Duration + m.Wait),
+			Properties: []junitProperty{
+				{"requests", strconv.FormatUint(m.Requests, 10)},
+				{"rate", strconv.FormatFloat(m.Rate, 'f', 2, 64)},
+				{"throughput", strconv.FormatFloat(m.Throughput, 'f', 2, 64)},
+				{"success", strconv.FormatFloat(m.Success, 'f', -1, 64)},
+				{"latency_min", round(m.Latencies.Min).String()},
+				{"latency_mean", round(m.Latencies.Mean).String()},
+				{"latency_50", round(m.Latencies.P50).String()},
+				{"latency_90", round(m.Latencies.P90).String()},
+				{"latency_95", round(m.Latencies.P95).String()},
+				{"latency_99", round(m.Latencies.P99).String()},
+				{"latency_max", round(m.Latencies.Max).String()},
+				{"bytes_in_total", strconv.FormatUint(m.BytesIn.Total, 10)},
+				{"bytes_out_total", strconv.FormatUint(m.BytesOut.Total, 10)},
+			},
+		}
+
+		if !m.Earliest.IsZero() {
+			suite.Timestamp = m.Earliest.UTC().Format("2006-01-02T15:04:05")
+		}
+
+		for _, slo := range slos {
+			tc := junitTestCase{
+				Name:      slo.String(),
+				ClassName: "vegeta.slo",
+				Time:      suite.Time,
+			}
+
+			if !slo.Check(m) {
+				got := slo.Format(slo.Value(m))
+				tc.Failure = &junitFailure{
+					Message: fmt.Sprintf("%s = %s, want %s %s", slo.Metric, got, slo.Op, slo.Format(slo.Threshold)),
+					Type:    "SLO",
+					Text:    fmt.Sprintf("%s was %s", slo, got),
+				}
+				suite.Failures++
+			}
+
+			suite.Cases = append(suite.Cases, tc)
+		}
+
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+			return err
+		}
+
+		_, err := io.WriteString(w, "\n")
+		return err
+	}
+}
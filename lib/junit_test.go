@@ -0,0 +1,97 @@
+package vegeta
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+	"time"
+)
+
+func TestJUnitReporter(t *testing.T) {
+	t.Parallel()
+
+	var m Metrics
+	for i := 1; i <= 10; i++ {
+		r := Result{
+			Code:      200,
+			Timestamp: time.Unix(int64(i), 0),
+			Latency:   time.Duration(i) * 100 * time.Millisecond,
+		}
+		if i == 10 {
+			r.Code, r.Error = 503, "Service Unavailable"
+		}
+		m.Add(&r)
+	}
+	m.Close()
+
+	var slos []SLO
+	for _, s := range []string{"p50 < 1s", "success >= 99%"} {
+		slo, err := ParseSLO(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slos = append(slos, slo)
+	}
+
+	var buf bytes.Buffer
+	if err := NewJUnitReporter(&m, slos).Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, buf.String())
+	}
+
+	if len(got.Suites) != 1 {
+		t.Fatalf("got %d test suites, want 1", len(got.Suites))
+	}
+
+	suite := got.Suites[0]
+	if suite.Tests != 2 || suite.Failures != 1 || len(suite.Cases) != 2 {
+		t.Fatalf("got %d tests, %d failures and %d cases; want 2, 1 and 2",
+			suite.Tests, suite.Failures, len(suite.Cases))
+	}
+
+	if tc := suite.Cases[0]; tc.Name != "p50 < 1s" || tc.Failure != nil {
+		t.Errorf("got test case %+v, want passed p50 < 1s", tc)
+	}
+
+	if tc := suite.Cases[1]; tc.Name != "success >= 99%" || tc.Failure == nil {
+		t.Errorf("got test case %+v, want failed success >= 99%%", tc)
+	} else if want := "success = 90%, want >= 99%"; tc.Failure.Message != want {
+		t.Errorf("got failure message %q, want %q", tc.Failure.Message, want)
+	}
+
+	props := map[string]string{}
+	for _, p := range suite.Properties {
+		props[p.Name] = p.Value
+	}
+
+	if got, want := props["requests"], "10"; got != want {
+		t.Errorf("got requests property %q, want %q", got, want)
+	}
+}
+
+func TestJUnitReporterDefaultSLOs(t *testing.T) {
+	t.Parallel()
+
+	var m Metrics
+	m.Add(&Result{Code: 200, Timestamp: time.Unix(1, 0)})
+	m.Close()
+
+	var buf bytes.Buffer
+	if err := NewJUnitReporter(&m, nil).Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	suite := got.Suites[0]
+	if suite.Tests != 1 || suite.Failures != 0 || suite.Cases[0].Name != "success >= 100%" {
+		t.Errorf("got %+v, want a single passed success >= 100%% test case", suite)
+	}
+}
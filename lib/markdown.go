@@ -0,0 +1,107 @@
+package vegeta
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// NewMarkdownReporter returns a Reporter that writes out Metrics as
+// GitHub flavored Markdown tables, ready to be pasted into pull request
+// comments. If the Metrics have a Histogram, it's written out as well.
+func NewMarkdownReporter(m *Metrics) Reporter {
+	return NewCustomMarkdownReporter(m, Units{})
+}
+
+// NewCustomMarkdownReporter returns a Reporter like NewMarkdownReporter
+// which formats durations and byte sizes in the given Units.
+func NewCustomMarkdownReporter(m *Metrics, u Units) Reporter {
+	return func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+
+		fmt.Fprintf(bw, "**Requests**\n\n")
+		fmt.Fprintf(bw, "| Total | Rate | Throughput | Success |\n")
+		fmt.Fprintf(bw, "|------:|-----:|-----------:|--------:|\n")
+		fmt.Fprintf(bw, "| %d | %.2f/s | %.2f/s | %.2f%% |\n\n",
+			m.Requests, m.Rate, m.Throughput, m.Success*100)
+
+		fmt.Fprintf(bw, "**Duration**\n\n")
+		fmt.Fprintf(bw, "| Total | Attack | Wait |\n")
+		fmt.Fprintf(bw, "|------:|-------:|-----:|\n")
+		fmt.Fprintf(bw, "| %s | %s | %s |\n\n",
+			u.duration(m.Duration+m.Wait), u.duration(m.Duration), u.duration(m.Wait))
+
+		fmt.Fprintf(bw, "**Latencies**\n\n")
+		fmt.Fprintf(bw, "| Min | Mean | 50 | 90 | 95 | 99 | Max |\n")
+		fmt.Fprintf(bw, "|----:|-----:|---:|---:|---:|---:|----:|\n")
+		fmt.Fprintf(bw, "| %s | %s | %s | %s | %s | %s | %s |\n\n",
+			u.duration(m.Latencies.Min),
+			u.duration(m.Latencies.Mean),
+			u.duration(m.Latencies.P50),
+			u.duration(m.Latencies.P90),
+			u.duration(m.Latencies.P95),
+			u.duration(m.Latencies.P99),
+			u.duration(m.Latencies.Max),
+		)
+
+		fmt.Fprintf(bw, "**Bytes**\n\n")
+		fmt.Fprintf(bw, "|     | Total | Mean |\n")
+		fmt.Fprintf(bw, "|-----|------:|-----:|\n")
+		fmt.Fprintf(bw, "| In  | %s | %s |\n", u.bytes(float64(m.BytesIn.Total)), u.bytes(m.BytesIn.Mean))
+		fmt.Fprintf(bw, "| Out | %s | %s |\n\n", u.bytes(float64(m.BytesOut.Total)), u.bytes(m.BytesOut.Mean))
+
+		codes := make([]string, 0, len(m.StatusCodes))
+		for code := range m.StatusCodes {
+			codes = append(codes, code)
+		}
+
+		sort.Strings(codes)
+
+		fmt.Fprintf(bw, "**Status Codes**\n\n")
+		fmt.Fprintf(bw, "| Code | Count |\n")
+		fmt.Fprintf(bw, "|-----:|------:|\n")
+		for _, code := range codes {
+			fmt.Fprintf(bw, "| %s | %d |\n", code, m.StatusCodes[code])
+		}
+
+		if h := m.Histogram; h != nil {
+			fmt.Fprintf(bw, "\n**Histogram**\n\n")
+			fmt.Fprintf(bw, "| Bucket | # | %% |\n")
+			fmt.Fprintf(bw, "|--------|--:|---:|\n")
+			for i, count := range h.Counts {
+				var ratio float64
+				if h.Total > 0 {
+					ratio = float64(count) / float64(h.Total)
+				}
+				lo, hi := h.Buckets.Nth(i)
+				fmt.Fprintf(bw, "| [%s, %s] | %d | %.2f%% |\n", lo, hi, count, ratio*100)
+			}
+		}
+
+		if len(m.Errors) > 0 {
+			fmt.Fprintf(bw, "\n**Errors**\n\n")
+			for _, e := range m.Errors {
+				fmt.Fprintf(bw, "- %s\n", markdownCode(e))
+			}
+		}
+
+		return bw.Flush()
+	}
+}
+
+// markdownCode returns s as a Markdown code span, delimited by enough
+// backticks to hold any backticks in s.
+func markdownCode(s string) string {
+	delim := "`"
+	for strings.Contains(s, delim) {
+		delim += "`"
+	}
+
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+
+	return delim + s + delim
+}
@@ -0,0 +1,58 @@
+package vegeta
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarkdownReporter(t *testing.T) {
+	t.Parallel()
+
+	m := Metrics{Histogram: &Histogram{Buckets: Buckets{0, 5 * time.Millisecond}}}
+	for i := 1; i <= 10; i++ {
+		r := Result{
+			Code:      200,
+			Timestamp: time.Unix(int64(i), 0),
+			Latency:   time.Duration(i) * time.Millisecond,
+		}
+		if i == 10 {
+			r.Code, r.Error = 500, "Internal `Server` Error"
+		}
+		m.Add(&r)
+	}
+	m.Close()
+
+	var buf bytes.Buffer
+	if err := NewMarkdownReporter(&m).Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.String()
+	for _, want := range []string{
+		"| 10 | 1.11/s | 1.00/s | 90.00% |\n",
+		"| 1ms | 5.5ms | 5.5ms | 9.5ms | 10ms | 10ms | 10ms |\n",
+		"| 200 | 9 |\n| 500 | 1 |\n",
+		"| [0s, 5ms] | 4 | 40.00% |\n| [5ms, +Inf] | 6 | 60.00% |\n",
+		"- ``Internal `Server` Error``\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report doesn't contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestMarkdownCode(t *testing.T) {
+	t.Parallel()
+
+	for in, want := range map[string]string{
+		"EOF":     "`EOF`",
+		"a `b` c": "``a `b` c``",
+		"`a`":     "`` `a` ``",
+	} {
+		if got := markdownCode(in); got != want {
+			t.Errorf("markdownCode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package vegeta
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Metadata describes the attack which produced a stream of Results. Attacks
+// write it as the first record of their result streams, in a Result with
+// only its Metadata and Timestamp set, so that result files are
+// self-describing.
+type Metadata struct {
+	// Attack is the name of the attack.
+	Attack string `json:"attack"`
+	// Rate is the rate of the attack, e.g. "50/1s".
+	Rate string `json:"rate"`
+	// Duration is the duration of the attack, or zero if unbounded.
+	Duration time.Duration `json:"duration"`
+	// Targets is a hash of the targets of the attack, e.g. "sha256:<hex>",
+	// if they were known before it began.
+	Targets string `json:"targets,omitempty"`
+	// Version is the version of vegeta which ran the attack.
+	Version string `json:"version"`
+	// Args are the command line arguments of the attack.
+	Args []string `json:"args"`
+	// Began is the time at which the attack began.
+	Began time.Time `json:"began"`
+	// Annotation is set in the metadata records attacks write after their
+	// first one to mark events of the attack, like pauses.
+	Annotation *Annotation `json:"annotation,omitempty"`
+}
+
+// Annotation marks an event of an attack in its result stream, so that
+// reports can tell apart, for instance, gaps in its results due to pauses.
+type Annotation struct {
+	// Event is the annotated event, e.g. AnnotationPause.
+	Event string `json:"event"`
+	// Time is the time of the event.
+	Time time.Time `json:"time"`
+	// Host is the resource usage of the host of the attack, set in
+	// AnnotationHost annotations, or of that of the Source agent, set in
+	// AnnotationAgent annotations.
+	Host *HostUsage `json:"host,omitempty"`
+	// Source is the address of the agent of AnnotationAgent annotations.
+	Source string `json:"source,omitempty"`
+}
+
+// Annotated events.
+const (
+	AnnotationPause        = "pause"
+	AnnotationResume       = "resume"
+	AnnotationBreakerOpen  = "breaker-open"
+	AnnotationBreakerClose = "breaker-close"
+	AnnotationHost         = "host"
+	AnnotationAgent        = "agent"
+	AnnotationProfile      = "profile"
+)
+
+// HostUsage is the resource usage of the host of an attack, sampled by it
+// periodically, since the generator itself being saturated invalidates the
+// results of an attack. Resources which couldn't be sampled are zero.
+type HostUsage struct {
+	// CPU is the ratio of the CPU time of the host which was busy since the
+	// last sample, from 0 to 1.
+	CPU float64 `json:"cpu"`
+	// Memory is the ratio of the memory of the host which is in use.
+	Memory float64 `json:"memory"`
+	// RSS is the resident memory of the attack process in bytes.
+	RSS uint64 `json:"rss"`
+	// FDs is the number of file descriptors the attack process has open, and
+	// FDLimit the maximum number it can.
+	FDs     uint64 `json:"fds"`
+	FDLimit uint64 `json:"fd_limit"`
+	// NetIn and NetOut are the bytes per second the network interfaces of
+	// the host received and sent since the last sample.
+	NetIn  uint64 `json:"net_in"`
+	NetOut uint64 `json:"net_out"`
+	// Connections is the number of established TCP connections of the host.
+	Connections uint64 `json:"connections"`
+}
+
+// SaturatedRatio is the ratio of a resource of the host of an attack in use
+// beyond which HostUsage.Saturated reports it.
+const SaturatedRatio = 0.9
+
+// Saturated returns the resources of the host which were in use beyond the
+// SaturatedRatio, e.g. "cpu 97%", if any.
+func (u *HostUsage) Saturated() []string {
+	var saturated []string
+	add := func(name string, ratio float64) {
+		if ratio >= SaturatedRatio {
+			saturated = append(saturated, name+" "+strconv.FormatFloat(ratio*100, 'f', 0, 64)+"%")
+		}
+	}
+
+	add("cpu", u.CPU)
+	add("memory", u.Memory)
+	if u.FDLimit > 0 {
+		add("fds", float64(u.FDs)/float64(u.FDLimit))
+	}
+
+	return saturated
+}
+
+// Annotate returns a copy of the Metadata with an Annotation of the given
+// event at the given time, to write in a metadata record at that time.
+func (m *Metadata) Annotate(event string, t time.Time) *Metadata {
+	md := *m
+	md.Annotation = &Annotation{Event: event, Time: t}
+	return &md
+}
+
+// Equal returns true if the given Annotation is equal to the receiver.
+func (a *Annotation) Equal(other *Annotation) bool {
+	if a == nil || other == nil {
+		return a == nil && other == nil
+	}
+
+	if (a.Host == nil) != (other.Host == nil) || a.Host != nil && *a.Host != *other.Host {
+		return false
+	}
+
+	return a.Event == other.Event && a.Source == other.Source && a.Time.Equal(other.Time)
+}
+
+// Equal returns true if the given Metadata is equal to the receiver.
+func (m *Metadata) Equal(other *Metadata) bool {
+	if m == nil || other == nil {
+		return m == nil && other == nil
+	}
+
+	if len(m.Args) != len(other.Args) {
+		return false
+	}
+
+	for i := range m.Args {
+		if m.Args[i] != other.Args[i] {
+			return false
+		}
+	}
+
+	if !m.Annotation.Equal(other.Annotation) {
+		return false
+	}
+
+	return m.Attack == other.Attack &&
+		m.Rate == other.Rate &&
+		m.Duration == other.Duration &&
+		m.Targets == other.Targets &&
+		m.Version == other.Version &&
+		m.Began.Equal(other.Began)
+}
+
+// NewMetadataDecoder returns a Decoder which skips the metadata records of
+// the Results decoded by the given Decoder, passing their Metadata to fn,
+// unless it's nil. Decoders of result streams written by attacks should be
+// wrapped with it before their Results are added to reports.
+func NewMetadataDecoder(dec Decoder, fn func(*Metadata)) Decoder {
+	return func(r *Result) error {
+		for {
+			if err := dec.Decode(r); err != nil || r.Metadata == nil {
+				return err
+			}
+
+			if fn != nil {
+				fn(r.Metadata)
+			}
+
+			*r = Result{} // Decoders may not reset fields absent in the next Result.
+		}
+	}
+}
+
+// metadataJSON returns the JSON encoding of the given Metadata, or an empty
+// string if it's nil, as stored in the CSV and Parquet encodings.
+func metadataJSON(m *Metadata) string {
+	if m == nil {
+		return ""
+	}
+	bs, _ := json.Marshal(m)
+	return string(bs)
+}
+
+// parseMetadata decodes Metadata encoded with metadataJSON.
+func parseMetadata(s string) (*Metadata, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var m Metadata
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
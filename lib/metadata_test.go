@@ -0,0 +1,101 @@
+package vegeta
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMetadataDecoder(t *testing.T) {
+	t.Parallel()
+
+	md := Metadata{
+		Attack:   "checkout",
+		Rate:     "50/1s",
+		Duration: time.Minute,
+		Targets:  "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		Version:  "12.8.4",
+		Args:     []string{"attack", "-name=checkout"},
+		Began:    time.Unix(1e9, 0),
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc(&Result{Timestamp: md.Began, Metadata: &md}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := enc(&Result{Seq: uint64(i), Code: 200, Timestamp: md.Began}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []*Metadata
+	dec := NewMetadataDecoder(NewDecoder(&buf), func(m *Metadata) { got = append(got, m) })
+
+	var seqs []uint64
+	for {
+		var r Result
+		if err := dec(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		} else if r.Metadata != nil {
+			t.Fatalf("got metadata record %+v", r)
+		}
+		seqs = append(seqs, r.Seq)
+	}
+
+	if want := []uint64{0, 1, 2}; !reflect.DeepEqual(seqs, want) {
+		t.Errorf("got seqs %v, want %v", seqs, want)
+	}
+
+	if len(got) != 1 || !got[0].Equal(&md) {
+		t.Errorf("got metadata %+v, want %+v", got, md)
+	}
+}
+
+func TestMetadataAnnotate(t *testing.T) {
+	t.Parallel()
+
+	md := Metadata{Attack: "checkout", Rate: "50/1s", Began: time.Unix(1e9, 0)}
+	at := md.Began.Add(time.Minute)
+	got := md.Annotate(AnnotationPause, at)
+
+	if md.Annotation != nil {
+		t.Errorf("Annotate modified the metadata: %+v", md.Annotation)
+	}
+
+	if want := (Annotation{Event: AnnotationPause, Time: at}); got.Annotation == nil || *got.Annotation != want {
+		t.Errorf("got annotation %+v, want %+v", got.Annotation, want)
+	}
+
+	if got.Equal(&md) {
+		t.Error("annotated metadata equals the original one")
+	}
+
+	if !got.Equal(md.Annotate(AnnotationPause, at)) {
+		t.Error("annotated metadata doesn't equal the same annotation")
+	}
+}
+
+func TestHostUsageSaturated(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		usage HostUsage
+		want  []string
+	}{
+		{HostUsage{CPU: 0.5, Memory: 0.5, FDs: 10, FDLimit: 1024}, nil},
+		{HostUsage{CPU: 0.97, Memory: 0.5}, []string{"cpu 97%"}},
+		{HostUsage{CPU: 0.2, Memory: 0.95, FDs: 1000, FDLimit: 1024}, []string{"memory 95%", "fds 98%"}},
+		{HostUsage{FDs: 1000}, nil},
+	} {
+		if got := tc.usage.Saturated(); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%+v: got saturated %q, want %q", tc.usage, got, tc.want)
+		}
+	}
+}
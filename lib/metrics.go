@@ -1,6 +1,7 @@
 package vegeta
 
 import (
+	"math"
 	"strconv"
 	"time"
 
@@ -46,16 +47,17 @@ type Metrics struct {
 }
 
 // Add implements the Add method of the Report interface by adding the given
-// Result to Metrics.
+// Result to Metrics. Sampled Results are counted as many times as their Weight.
 func (m *Metrics) Add(r *Result) {
 	m.init()
 
-	m.Requests++
-	m.StatusCodes[strconv.Itoa(int(r.Code))]++
-	m.BytesOut.Total += r.BytesOut
-	m.BytesIn.Total += r.BytesIn
+	w := r.weight()
+	m.Requests += w
+	m.StatusCodes[strconv.Itoa(int(r.Code))] += int(w)
+	m.BytesOut.Total += r.BytesOut * w
+	m.BytesIn.Total += r.BytesIn * w
 
-	m.Latencies.Add(r.Latency)
+	m.Latencies.add(r.Latency, w)
 
 	if m.Earliest.IsZero() || m.Earliest.After(r.Timestamp) {
 		m.Earliest = r.Timestamp
@@ -69,8 +71,9 @@ func (m *Metrics) Add(r *Result) {
 		m.End = end
 	}
 
-	if r.Code >= 200 && r.Code < 400 {
-		m.success++
+	// Responses failing assertions aren't successful, whatever their status.
+	if r.Code >= 200 && r.Code < 400 && !failedAssertion(r) {
+		m.success += w
 	}
 
 	if r.Error != "" {
@@ -112,6 +115,55 @@ func (m *Metrics) Close() {
 	m.Latencies.P90 = m.Latencies.Quantile(0.90)
 	m.Latencies.P95 = m.Latencies.Quantile(0.95)
 	m.Latencies.P99 = m.Latencies.Quantile(0.99)
+
+	if m.Histogram != nil {
+		m.Histogram.Close()
+	}
+}
+
+// Merge merges the Results added to the given Metrics into m as if they had
+// been added to m, so that Results can be added to several Metrics
+// concurrently. Neither Metrics must be closed yet. Since latency quantile
+// estimates can't be merged exactly, the latency quantiles of o are
+// approximated. Histograms are merged if both Metrics have one.
+func (m *Metrics) Merge(o *Metrics) {
+	m.init()
+	o.init()
+
+	m.Requests += o.Requests
+	for code, n := range o.StatusCodes {
+		m.StatusCodes[code] += n
+	}
+
+	m.BytesOut.Total += o.BytesOut.Total
+	m.BytesIn.Total += o.BytesIn.Total
+
+	m.Latencies.merge(&o.Latencies)
+
+	if !o.Earliest.IsZero() && (m.Earliest.IsZero() || m.Earliest.After(o.Earliest)) {
+		m.Earliest = o.Earliest
+	}
+
+	if o.Latest.After(m.Latest) {
+		m.Latest = o.Latest
+	}
+
+	if o.End.After(m.End) {
+		m.End = o.End
+	}
+
+	m.success += o.success
+
+	for _, err := range o.Errors {
+		if _, ok := m.errors[err]; !ok {
+			m.errors[err] = struct{}{}
+			m.Errors = append(m.Errors, err)
+		}
+	}
+
+	if m.Histogram != nil && o.Histogram != nil {
+		m.Histogram.Merge(o.Histogram)
+	}
 }
 
 func (m *Metrics) init() {
@@ -148,18 +200,91 @@ type LatencyMetrics struct {
 	Min time.Duration `json:"min"`
 
 	estimator estimator
+	variance  variance
 }
 
 // Add adds the given latency to the latency metrics.
 func (l *LatencyMetrics) Add(latency time.Duration) {
+	l.add(latency, 1)
+}
+
+// add adds the given latency as many times as the given weight.
+func (l *LatencyMetrics) add(latency time.Duration, weight uint64) {
 	l.init()
-	if l.Total += latency; latency > l.Max {
+	if l.Total += latency * time.Duration(weight); latency > l.Max {
 		l.Max = latency
 	}
 	if latency < l.Min || l.Min == 0 {
 		l.Min = latency
 	}
-	l.estimator.Add(float64(latency))
+	if weight == 1 {
+		l.estimator.Add(float64(latency))
+	} else {
+		l.estimator.(*tdigestEstimator).TDigest.Add(float64(latency), float64(weight))
+	}
+	l.variance.add(float64(latency), float64(weight))
+}
+
+// mergeQuantiles is the number of evenly spaced quantiles of another
+// LatencyMetrics which approximate its latency distribution when merging.
+const mergeQuantiles = 1000
+
+func (l *LatencyMetrics) merge(o *LatencyMetrics) {
+	if o.variance.n == 0 {
+		return
+	}
+
+	l.init()
+	l.Total += o.Total
+	if o.Max > l.Max {
+		l.Max = o.Max
+	}
+	if o.Min < l.Min || l.Min == 0 {
+		l.Min = o.Min
+	}
+
+	// t-digests don't expose their centroids to be merged, so the other one
+	// is approximated by its quantiles, weighted by its number of samples.
+	e := l.estimator.(*tdigestEstimator)
+	w := o.variance.n / mergeQuantiles
+	for i := 0; i < mergeQuantiles; i++ {
+		e.TDigest.Add(o.estimator.Get((float64(i)+0.5)/mergeQuantiles), w)
+	}
+
+	l.variance.merge(o.variance)
+}
+
+// StdDev returns the standard deviation of the latencies.
+func (l LatencyMetrics) StdDev() time.Duration {
+	if l.variance.n < 2 {
+		return 0
+	}
+	return time.Duration(math.Sqrt(l.variance.m2 / l.variance.n))
+}
+
+// variance computes the variance of a stream of samples with
+// Welford's numerically stable online algorithm.
+type variance struct {
+	n, mean, m2 float64
+}
+
+func (v *variance) add(x, weight float64) {
+	v.n += weight
+	delta := x - v.mean
+	v.mean += delta * weight / v.n
+	v.m2 += weight * delta * (x - v.mean)
+}
+
+// merge merges the samples of o into v with Chan et al.'s parallel algorithm.
+func (v *variance) merge(o variance) {
+	n := v.n + o.n
+	if n == 0 {
+		return
+	}
+	delta := o.mean - v.mean
+	v.m2 += o.m2 + delta*delta*v.n*o.n/n
+	v.mean += delta * o.n / n
+	v.n = n
 }
 
 // Quantile returns the nth quantile from the latency summary.
@@ -2,6 +2,7 @@ package vegeta
 
 import (
 	"io/ioutil"
+	"math"
 	"math/rand"
 	"reflect"
 	"testing"
@@ -50,6 +51,7 @@ func TestMetrics_Add(t *testing.T) {
 			Max:       duration("10ms"),
 			Min:       duration("1us"),
 			estimator: got.Latencies.estimator,
+			variance:  got.Latencies.variance,
 		},
 		BytesIn:     ByteMetrics{Total: 10240000, Mean: 1024},
 		BytesOut:    ByteMetrics{Total: 5120000, Mean: 512},
@@ -74,6 +76,138 @@ func TestMetrics_Add(t *testing.T) {
 	}
 }
 
+func TestMetrics_Merge(t *testing.T) {
+	t.Parallel()
+
+	codes := []uint16{500, 200, 302}
+	errors := []string{"Internal server error", "", "Timeout"}
+
+	var (
+		want  Metrics
+		parts = make([]Metrics, 4)
+	)
+
+	for i := 1; i <= 10000; i++ {
+		r := Result{
+			Code:      codes[i%len(codes)],
+			Timestamp: time.Unix(int64(i-1), 0),
+			Latency:   time.Duration(i) * time.Microsecond,
+			BytesIn:   1024,
+			BytesOut:  512,
+			Error:     errors[i%len(errors)],
+		}
+		want.Add(&r)
+		parts[i%len(parts)].Add(&r)
+	}
+	want.Close()
+
+	var got Metrics
+	for i := range parts {
+		got.Merge(&parts[i])
+	}
+	got.Close()
+
+	for _, tc := range []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"Requests", got.Requests, want.Requests},
+		{"StatusCodes", got.StatusCodes, want.StatusCodes},
+		{"BytesIn", got.BytesIn, want.BytesIn},
+		{"BytesOut", got.BytesOut, want.BytesOut},
+		{"Earliest", got.Earliest, want.Earliest},
+		{"Latest", got.Latest, want.Latest},
+		{"End", got.End, want.End},
+		{"Rate", got.Rate, want.Rate},
+		{"Success", got.Success, want.Success},
+		{"Total", got.Latencies.Total, want.Latencies.Total},
+		{"Mean", got.Latencies.Mean, want.Latencies.Mean},
+		{"Min", got.Latencies.Min, want.Latencies.Min},
+		{"Max", got.Latencies.Max, want.Latencies.Max},
+		{"Errors", len(got.Errors), len(want.Errors)},
+	} {
+		if !reflect.DeepEqual(tc.got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, tc.got, tc.want)
+		}
+	}
+
+	if got, want := got.Latencies.StdDev(), want.Latencies.StdDev(); math.Abs(float64(got-want)) > float64(time.Microsecond) {
+		t.Errorf("StdDev: got %v, want %v", got, want)
+	}
+
+	for _, q := range []struct {
+		name      string
+		got, want time.Duration
+	}{
+		{"P50", got.Latencies.P50, want.Latencies.P50},
+		{"P90", got.Latencies.P90, want.Latencies.P90},
+		{"P95", got.Latencies.P95, want.Latencies.P95},
+		{"P99", got.Latencies.P99, want.Latencies.P99},
+	} {
+		if err := math.Abs(float64(q.got-q.want)) / float64(q.want); err > 0.01 {
+			t.Errorf("%s: got %v, want %v (%.2f%% error)", q.name, q.got, q.want, err*100)
+		}
+	}
+}
+
+func TestMetrics_Weight(t *testing.T) {
+	t.Parallel()
+
+	var got, want Metrics
+	got.Histogram, want.Histogram = &Histogram{}, &Histogram{}
+	for i := 1; i <= 100; i++ {
+		r := Result{
+			Code:      uint16(200 + 300*(i%2)),
+			Timestamp: time.Unix(int64(i), 0),
+			Latency:   time.Duration(i) * time.Millisecond,
+			BytesIn:   10,
+		}
+
+		for j := 0; j < i%3+1; j++ {
+			want.Add(&r)
+		}
+
+		r.Weight = uint64(i%3 + 1)
+		got.Add(&r)
+	}
+	got.Close()
+	want.Close()
+
+	for _, tc := range []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"Requests", got.Requests, want.Requests},
+		{"StatusCodes", got.StatusCodes, want.StatusCodes},
+		{"BytesIn", got.BytesIn, want.BytesIn},
+		{"Success", got.Success, want.Success},
+		{"Total", got.Latencies.Total, want.Latencies.Total},
+		{"Mean", got.Latencies.Mean, want.Latencies.Mean},
+		{"Histogram", got.Histogram.Counts, want.Histogram.Counts},
+	} {
+		if !reflect.DeepEqual(tc.got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, tc.got, tc.want)
+		}
+	}
+
+	if got, want := got.Latencies.StdDev(), want.Latencies.StdDev(); math.Abs(float64(got-want)) > 1 {
+		t.Errorf("StdDev: got %v, want %v", got, want)
+	}
+
+	// Weighted centroids are interpolated differently by the t-digest.
+	for _, q := range []struct {
+		name      string
+		got, want time.Duration
+	}{
+		{"P50", got.Latencies.P50, want.Latencies.P50},
+		{"P99", got.Latencies.P99, want.Latencies.P99},
+	} {
+		if err := math.Abs(float64(q.got-q.want)) / float64(q.want); err > 0.01 {
+			t.Errorf("%s: got %v, want %v", q.name, q.got, q.want)
+		}
+	}
+}
+
 // https://github.com/tsenart/vegeta/issues/208
 func TestMetrics_NoInfiniteRate(t *testing.T) {
 	t.Parallel()
@@ -0,0 +1,917 @@
+package vegeta
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// NewMsgpackEncoder returns an Encoder that writes Results as MessagePack
+// maps with the same keys as the JSON encoding. Timestamps are written with
+// the MessagePack timestamp extension type, latencies in nanoseconds and
+// bodies as binary.
+func NewMsgpackEncoder(w io.Writer) Encoder {
+	var b msgpackBuffer
+	return func(r *Result) error {
+		// Fields added after headers are only written when set.
+		n := 12
+		for _, set := range []bool{r.Weight != 0, len(r.RequestBody) > 0, len(r.RequestHeaders) > 0, len(r.Labels) > 0, r.RemoteAddr != "", r.LocalAddr != "",
+			r.TLSVersion != "", r.TLSCipherSuite != "", r.TLSProtocol != "", r.TLSResumed, r.Metadata != nil, r.Worker != 0, r.ConnWait != 0,
+			r.IPFamily != "", r.Negotiation != 0, r.Attempt != 0, r.Retried, r.RetryDelay != 0, r.Continued, r.Tunnel != 0, r.TraceID != ""} {
+			if set {
+				n++
+			}
+		}
+
+		b = b[:0]
+		b.mapHeader(n)
+		b.str("attack")
+		b.str(r.Attack)
+		b.str("seq")
+		b.uint(r.Seq)
+		b.str("code")
+		b.uint(uint64(r.Code))
+		b.str("timestamp")
+		b.time(r.Timestamp)
+		b.str("latency")
+		b.int(int64(r.Latency))
+		b.str("bytes_out")
+		b.uint(r.BytesOut)
+		b.str("bytes_in")
+		b.uint(r.BytesIn)
+		b.str("error")
+		b.str(r.Error)
+		b.str("body")
+		b.bin(r.Body)
+		b.str("method")
+		b.str(r.Method)
+		b.str("url")
+		b.str(r.URL)
+		b.str("headers")
+		b.httpHeader(r.Headers)
+
+		if r.Weight != 0 {
+			b.str("weight")
+			b.uint(r.Weight)
+		}
+
+		if len(r.RequestBody) > 0 {
+			b.str("request_body")
+			b.bin(r.RequestBody)
+		}
+
+		if len(r.RequestHeaders) > 0 {
+			b.str("request_headers")
+			b.httpHeader(r.RequestHeaders)
+		}
+
+		if len(r.Labels) > 0 {
+			b.str("labels")
+			b.labels(r.Labels)
+		}
+
+		if r.RemoteAddr != "" {
+			b.str("remote_addr")
+			b.str(r.RemoteAddr)
+		}
+
+		if r.LocalAddr != "" {
+			b.str("local_addr")
+			b.str(r.LocalAddr)
+		}
+
+		if r.TLSVersion != "" {
+			b.str("tls_version")
+			b.str(r.TLSVersion)
+		}
+
+		if r.TLSCipherSuite != "" {
+			b.str("tls_cipher_suite")
+			b.str(r.TLSCipherSuite)
+		}
+
+		if r.TLSProtocol != "" {
+			b.str("tls_protocol")
+			b.str(r.TLSProtocol)
+		}
+
+		if r.TLSResumed {
+			b.str("tls_resumed")
+			b.bool(r.TLSResumed)
+		}
+
+		if r.Metadata != nil {
+			b.str("metadata")
+			b.metadata(r.Metadata)
+		}
+
+		if r.Worker != 0 {
+			b.str("worker")
+			b.uint(r.Worker)
+		}
+
+		if r.ConnWait != 0 {
+			b.str("conn_wait")
+			b.uint(uint64(r.ConnWait))
+		}
+
+		if r.IPFamily != "" {
+			b.str("ip_family")
+			b.str(r.IPFamily)
+		}
+
+		if r.Negotiation != 0 {
+			b.str("negotiation")
+			b.uint(uint64(r.Negotiation))
+		}
+
+		if r.Attempt != 0 {
+			b.str("attempt")
+			b.uint(r.Attempt)
+		}
+
+		if r.Retried {
+			b.str("retried")
+			b.bool(r.Retried)
+		}
+
+		if r.RetryDelay != 0 {
+			b.str("retry_delay")
+			b.uint(uint64(r.RetryDelay))
+		}
+
+		if r.Continued {
+			b.str("continued")
+			b.bool(r.Continued)
+		}
+
+		if r.Tunnel != 0 {
+			b.str("tunnel")
+			b.uint(uint64(r.Tunnel))
+		}
+
+		if r.TraceID != "" {
+			b.str("trace_id")
+			b.str(r.TraceID)
+		}
+
+		_, err := w.Write(b)
+		return err
+	}
+}
+
+// NewMsgpackDecoder returns a Decoder of Results from a stream of MessagePack
+// maps, as written by the Encoder returned by NewMsgpackEncoder. Unknown keys
+// are ignored.
+func NewMsgpackDecoder(r io.Reader) Decoder {
+	rd := msgpackReader{bufio.NewReader(r)}
+	return func(r *Result) error {
+		v, err := rd.value()
+		if err != nil {
+			return err
+		}
+
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("msgpack: got %T, want map", v)
+		}
+
+		*r = Result{}
+		known := 0
+		for k, v := range m {
+			var err error
+			known++
+			switch k {
+			case "attack":
+				r.Attack, err = msgpackString(k, v)
+			case "seq":
+				r.Seq, err = msgpackUint(k, v)
+			case "code":
+				var code uint64
+				code, err = msgpackUint(k, v)
+				r.Code = uint16(code)
+			case "timestamp":
+				r.Timestamp, err = msgpackTime(k, v)
+			case "latency":
+				var latency uint64
+				latency, err = msgpackUint(k, v)
+				r.Latency = time.Duration(latency)
+			case "bytes_out":
+				r.BytesOut, err = msgpackUint(k, v)
+			case "bytes_in":
+				r.BytesIn, err = msgpackUint(k, v)
+			case "error":
+				r.Error, err = msgpackString(k, v)
+			case "body":
+				var body string
+				body, err = msgpackString(k, v)
+				if body != "" {
+					r.Body = []byte(body)
+				}
+			case "method":
+				r.Method, err = msgpackString(k, v)
+			case "url":
+				r.URL, err = msgpackString(k, v)
+			case "headers":
+				r.Headers, err = msgpackHeaders(k, v)
+			case "weight":
+				r.Weight, err = msgpackUint(k, v)
+			case "request_body":
+				var body string
+				body, err = msgpackString(k, v)
+				if body != "" {
+					r.RequestBody = []byte(body)
+				}
+			case "request_headers":
+				r.RequestHeaders, err = msgpackHeaders(k, v)
+			case "labels":
+				r.Labels, err = msgpackLabels(k, v)
+			case "remote_addr":
+				r.RemoteAddr, err = msgpackString(k, v)
+			case "local_addr":
+				r.LocalAddr, err = msgpackString(k, v)
+			case "tls_version":
+				r.TLSVersion, err = msgpackString(k, v)
+			case "tls_cipher_suite":
+				r.TLSCipherSuite, err = msgpackString(k, v)
+			case "tls_protocol":
+				r.TLSProtocol, err = msgpackString(k, v)
+			case "tls_resumed":
+				r.TLSResumed, err = msgpackBool(k, v)
+			case "metadata":
+				r.Metadata, err = msgpackMetadata(k, v)
+			case "worker":
+				r.Worker, err = msgpackUint(k, v)
+			case "conn_wait":
+				var wait uint64
+				wait, err = msgpackUint(k, v)
+				r.ConnWait = time.Duration(wait)
+			case "ip_family":
+				r.IPFamily, err = msgpackString(k, v)
+			case "negotiation":
+				var negotiation uint64
+				negotiation, err = msgpackUint(k, v)
+				r.Negotiation = time.Duration(negotiation)
+			case "attempt":
+				r.Attempt, err = msgpackUint(k, v)
+			case "retried":
+				r.Retried, err = msgpackBool(k, v)
+			case "retry_delay":
+				var delay uint64
+				delay, err = msgpackUint(k, v)
+				r.RetryDelay = time.Duration(delay)
+			case "continued":
+				r.Continued, err = msgpackBool(k, v)
+			case "tunnel":
+				var tunnel uint64
+				tunnel, err = msgpackUint(k, v)
+				r.Tunnel = time.Duration(tunnel)
+			case "trace_id":
+				r.TraceID, err = msgpackString(k, v)
+			default:
+				known--
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if known == 0 {
+			return errors.New("msgpack: map has no Result fields")
+		}
+
+		return nil
+	}
+}
+
+func msgpackString(k string, v interface{}) (string, error) {
+	switch s := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return s, nil
+	case []byte:
+		return string(s), nil
+	default:
+		return "", fmt.Errorf("msgpack: got %T for %q, want string", v, k)
+	}
+}
+
+func msgpackUint(k string, v interface{}) (uint64, error) {
+	switch n := v.(type) {
+	case nil:
+		return 0, nil
+	case uint64:
+		return n, nil
+	case int64:
+		return uint64(n), nil
+	default:
+		return 0, fmt.Errorf("msgpack: got %T for %q, want integer", v, k)
+	}
+}
+
+func msgpackBool(k string, v interface{}) (bool, error) {
+	switch b := v.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return b, nil
+	default:
+		return false, fmt.Errorf("msgpack: got %T for %q, want bool", v, k)
+	}
+}
+
+func msgpackMetadata(k string, v interface{}) (*Metadata, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	fields, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("msgpack: got %T for %q, want map", v, k)
+	}
+
+	var (
+		m   Metadata
+		d   uint64
+		err error
+	)
+
+	for k, v := range fields {
+		switch k {
+		case "attack":
+			m.Attack, err = msgpackString(k, v)
+		case "rate":
+			m.Rate, err = msgpackString(k, v)
+		case "duration":
+			d, err = msgpackUint(k, v)
+			m.Duration = time.Duration(d)
+		case "targets":
+			m.Targets, err = msgpackString(k, v)
+		case "version":
+			m.Version, err = msgpackString(k, v)
+		case "args":
+			args, ok := v.([]interface{})
+			if !ok && v != nil {
+				return nil, fmt.Errorf("msgpack: got %T for %q, want array", v, k)
+			}
+			for _, arg := range args {
+				s, err := msgpackString(k, arg)
+				if err != nil {
+					return nil, err
+				}
+				m.Args = append(m.Args, s)
+			}
+		case "began":
+			m.Began, err = msgpackTime(k, v)
+		case "annotation":
+			m.Annotation, err = msgpackAnnotation(k, v)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &m, nil
+}
+
+func msgpackAnnotation(k string, v interface{}) (*Annotation, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	fields, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("msgpack: got %T for %q, want map", v, k)
+	}
+
+	var (
+		a   Annotation
+		err error
+	)
+
+	for k, v := range fields {
+		switch k {
+		case "event":
+			a.Event, err = msgpackString(k, v)
+		case "time":
+			a.Time, err = msgpackTime(k, v)
+		case "host":
+			a.Host, err = msgpackHostUsage(k, v)
+		case "source":
+			a.Source, err = msgpackString(k, v)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &a, nil
+}
+
+func msgpackHostUsage(k string, v interface{}) (*HostUsage, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	fields, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("msgpack: got %T for %q, want map", v, k)
+	}
+
+	var (
+		u   HostUsage
+		err error
+	)
+
+	for k, v := range fields {
+		switch k {
+		case "cpu":
+			u.CPU, err = msgpackFloat(k, v)
+		case "memory":
+			u.Memory, err = msgpackFloat(k, v)
+		case "rss":
+			u.RSS, err = msgpackUint(k, v)
+		case "fds":
+			u.FDs, err = msgpackUint(k, v)
+		case "fd_limit":
+			u.FDLimit, err = msgpackUint(k, v)
+		case "net_in":
+			u.NetIn, err = msgpackUint(k, v)
+		case "net_out":
+			u.NetOut, err = msgpackUint(k, v)
+		case "connections":
+			u.Connections, err = msgpackUint(k, v)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &u, nil
+}
+
+// msgpackFloat accepts floats as well as integers.
+func msgpackFloat(k string, v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return n, nil
+	case uint64:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	default:
+		return 0, fmt.Errorf("msgpack: got %T for %q, want float", v, k)
+	}
+}
+
+// msgpackTime accepts timestamp extension values as well as integer Unix
+// timestamps in nanoseconds.
+func msgpackTime(k string, v interface{}) (time.Time, error) {
+	if t, ok := v.(time.Time); ok {
+		return t, nil
+	}
+
+	ns, err := msgpackUint(k, v)
+	return time.Unix(0, int64(ns)), err
+}
+
+func msgpackHeaders(k string, v interface{}) (http.Header, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("msgpack: got %T for %q, want map", v, k)
+	}
+
+	hdr := make(http.Header, len(m))
+	for name, vs := range m {
+		values, ok := vs.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("msgpack: got %T for %q header, want array", vs, name)
+		}
+
+		for _, v := range values {
+			s, err := msgpackString(name, v)
+			if err != nil {
+				return nil, err
+			}
+			hdr[name] = append(hdr[name], s)
+		}
+	}
+
+	return hdr, nil
+}
+
+func msgpackLabels(k string, v interface{}) (map[string]string, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("msgpack: got %T for %q, want map", v, k)
+	}
+
+	labels := make(map[string]string, len(m))
+	for name, v := range m {
+		s, err := msgpackString(name, v)
+		if err != nil {
+			return nil, err
+		}
+		labels[name] = s
+	}
+
+	return labels, nil
+}
+
+// msgpackBuffer encodes MessagePack values.
+type msgpackBuffer []byte
+
+func (b *msgpackBuffer) header(fix, max byte, n int, codes ...byte) {
+	switch {
+	case n <= int(max):
+		*b = append(*b, fix|byte(n))
+	case n <= math.MaxUint8 && codes[0] != 0:
+		*b = append(*b, codes[0], byte(n))
+	case n <= math.MaxUint16:
+		*b = append(*b, codes[1], byte(n>>8), byte(n))
+	default:
+		*b = append(*b, codes[2], byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+}
+
+func (b *msgpackBuffer) mapHeader(n int)   { b.header(0x80, 15, n, 0, 0xde, 0xdf) }
+func (b *msgpackBuffer) arrayHeader(n int) { b.header(0x90, 15, n, 0, 0xdc, 0xdd) }
+
+// httpHeader writes the given header as a map of names to arrays of values,
+// sorted by name, or nil.
+func (b *msgpackBuffer) httpHeader(h http.Header) {
+	if h == nil {
+		*b = append(*b, 0xc0)
+		return
+	}
+
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	b.mapHeader(len(names))
+	for _, name := range names {
+		b.str(name)
+		b.arrayHeader(len(h[name]))
+		for _, v := range h[name] {
+			b.str(v)
+		}
+	}
+}
+
+// labels writes the given labels as a map of strings, sorted by key.
+func (b *msgpackBuffer) labels(labels map[string]string) {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	b.mapHeader(len(keys))
+	for _, k := range keys {
+		b.str(k)
+		b.str(labels[k])
+	}
+}
+
+// metadata writes the given Metadata as a map with the same keys as its JSON
+// encoding.
+func (b *msgpackBuffer) metadata(m *Metadata) {
+	if m.Annotation != nil {
+		b.mapHeader(8)
+	} else {
+		b.mapHeader(7)
+	}
+	b.str("attack")
+	b.str(m.Attack)
+	b.str("rate")
+	b.str(m.Rate)
+	b.str("duration")
+	b.int(int64(m.Duration))
+	b.str("targets")
+	b.str(m.Targets)
+	b.str("version")
+	b.str(m.Version)
+	b.str("args")
+	b.arrayHeader(len(m.Args))
+	for _, arg := range m.Args {
+		b.str(arg)
+	}
+	b.str("began")
+	b.time(m.Began)
+	if a := m.Annotation; a != nil {
+		b.str("annotation")
+		n := 2
+		if a.Host != nil {
+			n++
+		}
+		if a.Source != "" {
+			n++
+		}
+		b.mapHeader(n)
+		b.str("event")
+		b.str(a.Event)
+		b.str("time")
+		b.time(a.Time)
+		if u := a.Host; u != nil {
+			b.str("host")
+			b.mapHeader(8)
+			b.str("cpu")
+			b.float(u.CPU)
+			b.str("memory")
+			b.float(u.Memory)
+			b.str("rss")
+			b.uint(u.RSS)
+			b.str("fds")
+			b.uint(u.FDs)
+			b.str("fd_limit")
+			b.uint(u.FDLimit)
+			b.str("net_in")
+			b.uint(u.NetIn)
+			b.str("net_out")
+			b.uint(u.NetOut)
+			b.str("connections")
+			b.uint(u.Connections)
+		}
+		if a.Source != "" {
+			b.str("source")
+			b.str(a.Source)
+		}
+	}
+}
+
+func (b *msgpackBuffer) bool(v bool) {
+	if v {
+		*b = append(*b, 0xc3)
+	} else {
+		*b = append(*b, 0xc2)
+	}
+}
+
+func (b *msgpackBuffer) str(s string) {
+	b.header(0xa0, 31, len(s), 0xd9, 0xda, 0xdb)
+	*b = append(*b, s...)
+}
+
+func (b *msgpackBuffer) bin(p []byte) {
+	switch n := len(p); {
+	case n <= math.MaxUint8:
+		*b = append(*b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		*b = append(*b, 0xc5, byte(n>>8), byte(n))
+	default:
+		*b = append(*b, 0xc6, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	*b = append(*b, p...)
+}
+
+func (b *msgpackBuffer) uint(v uint64) {
+	switch {
+	case v <= 0x7f:
+		*b = append(*b, byte(v))
+	case v <= math.MaxUint8:
+		*b = append(*b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		*b = append(*b, 0xcd, byte(v>>8), byte(v))
+	case v <= math.MaxUint32:
+		*b = append(*b, 0xce, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	default:
+		*b = append(*b, 0xcf)
+		*b = appendUint64(*b, v)
+	}
+}
+
+func (b *msgpackBuffer) float(v float64) {
+	*b = append(*b, 0xcb)
+	*b = appendUint64(*b, math.Float64bits(v))
+}
+
+func (b *msgpackBuffer) int(v int64) {
+	if v >= 0 {
+		b.uint(uint64(v))
+	} else if v >= -32 {
+		*b = append(*b, byte(v))
+	} else {
+		*b = append(*b, 0xd3)
+		*b = appendUint64(*b, uint64(v))
+	}
+}
+
+// time writes t with the timestamp extension type in its most compact form.
+func (b *msgpackBuffer) time(t time.Time) {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	switch {
+	case nsec == 0 && sec>>32 == 0:
+		*b = append(*b, 0xd6, 0xff, byte(sec>>24), byte(sec>>16), byte(sec>>8), byte(sec))
+	case sec>>34 == 0:
+		*b = append(*b, 0xd7, 0xff)
+		*b = appendUint64(*b, nsec<<34|uint64(sec))
+	default:
+		*b = append(*b, 0xc7, 12, 0xff, byte(nsec>>24), byte(nsec>>16), byte(nsec>>8), byte(nsec))
+		*b = appendUint64(*b, uint64(sec))
+	}
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return append(b, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32),
+		byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// msgpackReader decodes MessagePack values.
+type msgpackReader struct {
+	*bufio.Reader
+}
+
+// uint reads a big endian unsigned integer of the given size in bytes.
+func (r msgpackReader) uint(size int) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[8-size:]); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+func (r msgpackReader) bytes(n uint64) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, int64(n)))
+	if err == nil && uint64(len(b)) != n {
+		err = io.ErrUnexpectedEOF
+	}
+	return b, err
+}
+
+// value reads a value, decoding integers as int64 or uint64, floats as
+// float64, strings as string, binaries as []byte, arrays as []interface{},
+// maps with string keys as map[string]interface{}, timestamps as time.Time and other
+// extension types as nil.
+func (r msgpackReader) value() (interface{}, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	var n uint64 // Length of strings, binaries, arrays, maps and extensions.
+	switch {
+	case c <= 0x7f:
+		return uint64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c >= 0xa0 && c <= 0xbf:
+		b, err := r.bytes(uint64(c & 0x1f))
+		return string(b), err
+	case c >= 0x90 && c <= 0x9f:
+		return r.array(uint64(c & 0x0f))
+	case c >= 0x80 && c <= 0x8f:
+		return r.mapping(uint64(c & 0x0f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return r.uint(1 << (c - 0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		v, err := r.uint(size)
+		shift := uint(64 - 8*size)
+		return int64(v<<shift) >> shift, err
+	case 0xca:
+		v, err := r.uint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := r.uint(8)
+		return math.Float64frombits(v), err
+	case 0xd9, 0xda, 0xdb:
+		if n, err = r.uint(1 << (c - 0xd9)); err != nil {
+			return nil, err
+		}
+		b, err := r.bytes(n)
+		return string(b), err
+	case 0xc4, 0xc5, 0xc6:
+		if n, err = r.uint(1 << (c - 0xc4)); err != nil {
+			return nil, err
+		}
+		return r.bytes(n)
+	case 0xdc, 0xdd:
+		if n, err = r.uint(2 << (c - 0xdc)); err != nil {
+			return nil, err
+		}
+		return r.array(n)
+	case 0xde, 0xdf:
+		if n, err = r.uint(2 << (c - 0xde)); err != nil {
+			return nil, err
+		}
+		return r.mapping(n)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return r.ext(1 << (c - 0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		if n, err = r.uint(1 << (c - 0xc7)); err != nil {
+			return nil, err
+		}
+		return r.ext(n)
+	default:
+		return nil, fmt.Errorf("msgpack: invalid type code %#x", c)
+	}
+}
+
+func (r msgpackReader) array(n uint64) ([]interface{}, error) {
+	vs := make([]interface{}, 0, minUint64(n, 1024))
+	for i := uint64(0); i < n; i++ {
+		v, err := r.value()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		vs = append(vs, v)
+	}
+	return vs, nil
+}
+
+func (r msgpackReader) mapping(n uint64) (map[string]interface{}, error) {
+	m := make(map[string]interface{}, minUint64(n, 1024))
+	for i := uint64(0); i < n; i++ {
+		k, err := r.value()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+
+		v, err := r.value()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("msgpack: got %T map key, want string", k)
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+func (r msgpackReader) ext(n uint64) (interface{}, error) {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	data, err := r.bytes(n)
+	if err != nil || int8(typ) != -1 {
+		return nil, err
+	}
+
+	switch len(data) {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0), nil
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(data)
+		return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(nsec)), nil
+	default:
+		return nil, fmt.Errorf("msgpack: invalid timestamp length %d", len(data))
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
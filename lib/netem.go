@@ -0,0 +1,177 @@
+package vegeta
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// Network are the conditions of a network emulated by an Attacker on the
+// connections it dials. The zero value emulates none.
+type Network struct {
+	// Latency is added to the round trip of each exchange on a connection:
+	// to dialing it, and to the first read after each write to it, as if the
+	// data written took it to get a reply.
+	Latency time.Duration
+	// Jitter is the maximum random deviation of the latency added to each
+	// round trip, either way.
+	Jitter time.Duration
+	// Down and Up are the bandwidths of reads from and writes to each
+	// connection in bytes per second, unlimited unless positive.
+	Down, Up int64
+}
+
+// EmulateNetwork returns a functional option which makes an Attacker emulate
+// the given network conditions on the connections it dials, so that clients
+// on mobile networks or in other regions can be simulated without the
+// privileges tc and netem need. They add to those of the actual network.
+func EmulateNetwork(n Network) func(*Attacker) {
+	return func(a *Attacker) {
+		a.netem = nil
+		if n != (Network{}) {
+			a.netem = &netem{Network: n, rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+		}
+	}
+}
+
+// netem emulates a Network on connections.
+type netem struct {
+	Network
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// latency returns the latency of a round trip.
+func (n *netem) latency() time.Duration {
+	if n.Jitter <= 0 {
+		return n.Latency
+	}
+
+	n.mu.Lock()
+	d := n.Latency + time.Duration(n.rnd.Int63n(2*int64(n.Jitter)+1)) - n.Jitter
+	n.mu.Unlock()
+
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// emulate returns the given connection, just dialed with the given context,
+// with the Network emulated on it, once the latency of dialing it passed.
+func (n *netem) emulate(ctx context.Context, conn net.Conn) (net.Conn, error) {
+	t := time.NewTimer(n.latency())
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+		conn.Close()
+		return nil, ctx.Err()
+	}
+
+	return &emulatedConn{
+		Conn: conn,
+		net:  n,
+		down: bandwidth{rate: n.Down},
+		up:   bandwidth{rate: n.Up},
+	}, nil
+}
+
+// emulatedConn is a connection with a Network emulated on it.
+type emulatedConn struct {
+	net.Conn
+	net *netem
+
+	mu    sync.Mutex
+	delay bool // Whether the next read is delayed by the latency of a round trip.
+
+	down, up bandwidth
+}
+
+// The sizes of the chunks that reads and writes are paced in, so that
+// bandwidth is spread over them.
+const netemChunk = 16 << 10
+
+func (c *emulatedConn) Read(p []byte) (int, error) {
+	if c.down.rate > 0 && len(p) > netemChunk {
+		p = p[:netemChunk]
+	}
+
+	// Clients wait for replies with reads in progress before writing, so
+	// the data read is delayed once it arrives rather than beforehand.
+	n, err := c.Conn.Read(p)
+
+	c.mu.Lock()
+	delay := c.delay && n > 0
+	if delay {
+		c.delay = false
+	}
+	c.mu.Unlock()
+
+	if delay {
+		time.Sleep(c.net.latency())
+	}
+
+	c.down.wait(n)
+	return n, err
+}
+
+func (c *emulatedConn) Write(p []byte) (written int, err error) {
+	defer func() {
+		if written > 0 && c.net.Latency > 0 {
+			c.mu.Lock()
+			c.delay = true
+			c.mu.Unlock()
+		}
+	}()
+
+	if c.up.rate <= 0 {
+		return c.Conn.Write(p)
+	}
+
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > netemChunk {
+			chunk = chunk[:netemChunk]
+		}
+
+		c.up.wait(len(chunk))
+
+		n, err := c.Conn.Write(chunk)
+		if written += n; err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// bandwidth paces the bytes transferred over a connection in one direction
+// to a rate in bytes per second.
+type bandwidth struct {
+	rate int64
+	mu   sync.Mutex
+	next time.Time // When the bytes transferred so far are due.
+}
+
+// wait blocks for as long as transferring the given number of bytes takes,
+// after those transferred before.
+func (b *bandwidth) wait(n int) {
+	if b.rate <= 0 || n <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	if now := time.Now(); b.next.Before(now) {
+		b.next = now
+	}
+	b.next = b.next.Add(time.Duration(int64(n) * int64(time.Second) / b.rate))
+	due := b.next
+	b.mu.Unlock()
+
+	time.Sleep(time.Until(due))
+}
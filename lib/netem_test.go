@@ -0,0 +1,79 @@
+package vegeta
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEmulateNetwork(t *testing.T) {
+	t.Parallel()
+
+	const size = 20 << 10
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		if r.URL.Path == "/download" {
+			w.Write(bytes.Repeat([]byte("a"), size))
+		}
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name string
+		net  Network
+		tgt  Target
+		min  time.Duration
+	}{
+		// Dialing and the round trip of the request.
+		{"latency", Network{Latency: 50 * time.Millisecond}, Target{Method: "GET", URL: server.URL}, 100 * time.Millisecond},
+		{"down", Network{Down: 100 << 10}, Target{Method: "GET", URL: server.URL + "/download"}, 200 * time.Millisecond},
+		{"up", Network{Up: 100 << 10}, Target{Method: "POST", URL: server.URL, Body: bytes.Repeat([]byte("a"), size)}, 200 * time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			atk := NewAttacker(EmulateNetwork(tc.net))
+			res := atk.hit(NewStaticTargeter(tc.tgt), "", 1)
+			if res.Error != "" {
+				t.Fatal(res.Error)
+			} else if res.Latency < tc.min {
+				t.Errorf("got latency %s, want at least %s", res.Latency, tc.min)
+			}
+		})
+	}
+
+	if res := NewAttacker(EmulateNetwork(Network{})).hit(NewStaticTargeter(Target{Method: "GET", URL: server.URL}), "", 1); res.Latency > 50*time.Millisecond {
+		t.Errorf("got latency %s without emulation", res.Latency)
+	}
+}
+
+func TestNetemLatency(t *testing.T) {
+	t.Parallel()
+
+	n := netem{
+		Network: Network{Latency: 100 * time.Millisecond, Jitter: 20 * time.Millisecond},
+		rnd:     rand.New(rand.NewSource(1)),
+	}
+
+	var varied bool
+	for i := 0; i < 100; i++ {
+		d := n.latency()
+		if d < 80*time.Millisecond || d > 120*time.Millisecond {
+			t.Fatalf("got latency %s, want 100ms ± 20ms", d)
+		}
+		varied = varied || d != n.Latency
+	}
+
+	if !varied {
+		t.Error("got no jitter")
+	}
+
+	n.Latency = 0
+	for i := 0; i < 100; i++ {
+		if d := n.latency(); d < 0 {
+			t.Fatalf("got negative latency %s", d)
+		}
+	}
+}
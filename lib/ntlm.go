@@ -0,0 +1,252 @@
+package vegeta
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsenart/vegeta/v12/internal/ntlm"
+)
+
+// NTLM returns a functional option which makes an Attacker authenticate its
+// connections with NTLMv2 with the given credentials, when servers ask for it
+// with the NTLM or Negotiate schemes. NTLM authenticates connections rather
+// than requests, with an extra round trip on each new one, which the
+// Negotiation of results records. Since that requires sending all the
+// requests of a handshake on the same connection, over HTTP/1.1 with
+// keep-alive, each connection is used by one request at a time, and client
+// certificates aren't presented. Kerberos isn't supported.
+func NTLM(domain, user, password string) func(*Attacker) {
+	return func(a *Attacker) {
+		a.ntlm = &ntlmTransport{domain: domain, user: user, password: password}
+	}
+}
+
+// ExcludeNegotiation returns a functional option which makes an Attacker
+// leave the time spent authenticating connections, e.g. with NTLM, out of
+// the latency of results, which still record it as their Negotiation.
+func ExcludeNegotiation(exclude bool) func(*Attacker) {
+	return func(a *Attacker) { a.excludeNeg = exclude }
+}
+
+// negotiationKey is the key of the context value of requests in which their
+// negotiation time is added up.
+type negotiationKey struct{}
+
+// ntlmClient returns the client of the Attacker which authenticates its
+// connections with NTLM.
+func (a *Attacker) ntlmClient() (*http.Client, error) {
+	a.certmu.Lock()
+	defer a.certmu.Unlock()
+
+	if a.ntlm.base == nil {
+		tr, ok := a.transport()
+		if !ok {
+			return nil, errors.New("NTLM isn't supported by custom transports")
+		}
+		a.ntlm.base = tr
+		a.ntlm.client = a.client
+		a.ntlm.client.Transport = a.wrapTransport(a.ntlm)
+	}
+
+	return &a.ntlm.client, nil
+}
+
+// ntlmTransport is an http.RoundTripper which authenticates connections with
+// NTLM. Each of its sessions has at most one connection per host, which it
+// uses for one request at a time, so that handshakes aren't interleaved.
+type ntlmTransport struct {
+	domain, user, password string
+
+	base   *http.Transport
+	client http.Client
+
+	mu   sync.Mutex
+	idle []*ntlmSession
+}
+
+type ntlmSession struct {
+	tr     *http.Transport
+	authed map[string]string // Schemes of the authenticated connection to each host.
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *ntlmTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s := t.get()
+
+	res, err := t.roundTrip(s, req)
+	if err != nil {
+		t.put(s)
+		return nil, err
+	}
+
+	// The connection of the session is busy until the body is closed.
+	res.Body = &sessionBody{ReadCloser: res.Body, release: func() { t.put(s) }}
+	return res, nil
+}
+
+func (t *ntlmTransport) roundTrip(s *ntlmSession, req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if scheme, ok := s.authed[host]; ok {
+		res, err := s.tr.RoundTrip(req)
+		if err != nil || res.StatusCode != http.StatusUnauthorized || challenge(res, scheme) == nil {
+			return res, err
+		}
+
+		// The authenticated connection was closed, and its new one isn't.
+		discard(res)
+		delete(s.authed, host)
+
+		if req, err = rewind(req); err != nil {
+			return nil, err
+		}
+	}
+
+	started := time.Now()
+	auth, scheme, err := t.negotiate(s, req)
+	if d, ok := req.Context().Value(negotiationKey{}).(*time.Duration); ok {
+		*d += time.Since(started)
+	}
+
+	if err != nil {
+		return nil, err
+	} else if auth == "" {
+		// The server doesn't ask for authentication, until it challenges.
+		s.authed[host] = "NTLM"
+		return s.tr.RoundTrip(req)
+	}
+
+	if req, err = rewind(req); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", auth)
+
+	res, err := s.tr.RoundTrip(req)
+	if err == nil && res.StatusCode != http.StatusUnauthorized {
+		s.authed[host] = scheme
+	}
+
+	return res, err
+}
+
+// negotiate sends the NEGOTIATE_MESSAGE of a handshake on the connection of
+// the session to the host of the given request, and returns the value of the
+// Authorization header with the AUTHENTICATE_MESSAGE answering the challenge
+// of the server, and its scheme, or nothing if the server didn't challenge.
+func (t *ntlmTransport) negotiate(s *ntlmSession, req *http.Request) (auth, scheme string, err error) {
+	for _, scheme = range []string{"NTLM", "Negotiate"} {
+		// Challenges are asked for without the body.
+		neg := req.Clone(req.Context())
+		neg.Body, neg.GetBody, neg.ContentLength = http.NoBody, nil, 0
+		neg.TransferEncoding = nil
+		neg.Header.Set("Authorization", scheme+" "+base64.StdEncoding.EncodeToString(ntlm.Negotiate()))
+
+		res, err := s.tr.RoundTrip(neg)
+		if err != nil {
+			return "", "", err
+		}
+		discard(res)
+
+		if res.StatusCode != http.StatusUnauthorized {
+			return "", "", nil
+		}
+
+		if token := challenge(res, scheme); len(token) > 0 {
+			msg, err := ntlm.Authenticate(token, t.domain, t.user, t.password)
+			if err != nil {
+				return "", "", err
+			}
+			return scheme + " " + base64.StdEncoding.EncodeToString(msg), scheme, nil
+		}
+	}
+
+	return "", "", errors.New("ntlm: server didn't send a challenge")
+}
+
+// challenge returns the decoded token of the WWW-Authenticate header of the
+// given response with the given scheme, which is empty if the scheme is
+// asked for without one, or nil if it isn't asked for.
+func challenge(res *http.Response, scheme string) []byte {
+	for _, v := range res.Header["Www-Authenticate"] {
+		fields := strings.Fields(v)
+		if len(fields) == 0 || !strings.EqualFold(fields[0], scheme) {
+			continue
+		} else if len(fields) == 1 {
+			return []byte{}
+		}
+
+		token, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			return []byte{}
+		}
+		return token
+	}
+	return nil
+}
+
+// rewind returns a copy of the given request, to be sent again, with a new
+// body.
+func rewind(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	return r, nil
+}
+
+// discard reads the rest of the given response's body, so that its
+// connection is kept alive, and closes it.
+func discard(res *http.Response) {
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+}
+
+func (t *ntlmTransport) get() *ntlmSession {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if n := len(t.idle); n > 0 {
+		s := t.idle[n-1]
+		t.idle = t.idle[:n-1]
+		return s
+	}
+
+	tr := t.base.Clone()
+	tr.MaxConnsPerHost, tr.MaxIdleConnsPerHost = 1, 1
+	// Prewarmed connections weren't authenticated.
+	tr.DialTLS = nil
+	// Handshakes are sent over HTTP/1.1.
+	tr.ForceAttemptHTTP2 = false
+	tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+
+	return &ntlmSession{tr: tr, authed: map[string]string{}}
+}
+
+func (t *ntlmTransport) put(s *ntlmSession) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.idle = append(t.idle, s)
+}
+
+// sessionBody releases the session of a response when its body is closed.
+type sessionBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *sessionBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,136 @@
+package vegeta
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ntlmServer returns a server which requires its connections to be
+// authenticated with NTLM by the given user, with the given delay before
+// challenges.
+func ntlmServer(user string, delay time.Duration) (*httptest.Server, *int) {
+	var (
+		mu         sync.Mutex
+		authed     = map[string]bool{}
+		handshakes int
+	)
+
+	challenge := make([]byte, 48)
+	copy(challenge, "NTLMSSP\x00")
+	binary.LittleEndian.PutUint32(challenge[8:], 2)
+	binary.LittleEndian.PutUint32(challenge[20:], 1) // Unicode
+	copy(challenge[24:], "01234567")
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if authed[r.RemoteAddr] {
+			w.Write([]byte("ok"))
+			return
+		}
+
+		auth := strings.TrimPrefix(r.Header.Get("Authorization"), "NTLM ")
+		msg, _ := base64.StdEncoding.DecodeString(auth)
+
+		switch {
+		case len(msg) >= 12 && binary.LittleEndian.Uint32(msg[8:]) == 1:
+			time.Sleep(delay)
+			handshakes++
+			w.Header().Set("WWW-Authenticate", "NTLM "+base64.StdEncoding.EncodeToString(challenge))
+		case len(msg) >= 64 && binary.LittleEndian.Uint32(msg[8:]) == 3:
+			n, off := binary.LittleEndian.Uint16(msg[36:]), binary.LittleEndian.Uint32(msg[40:])
+			if got := string(msg[off : off+uint32(n)]); got == string(utf16le(user)) {
+				authed[r.RemoteAddr] = true
+				w.Write([]byte("ok"))
+				return
+			}
+		default:
+			w.Header().Set("WWW-Authenticate", "NTLM")
+		}
+
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			mu.Lock()
+			delete(authed, c.RemoteAddr().String())
+			mu.Unlock()
+		}
+	}
+
+	server.Start()
+	return server, &handshakes
+}
+
+func utf16le(s string) []byte {
+	b := make([]byte, 0, 2*len(s))
+	for _, c := range s {
+		b = append(b, byte(c), 0)
+	}
+	return b
+}
+
+func TestNTLM(t *testing.T) {
+	t.Parallel()
+
+	const delay = 50 * time.Millisecond
+	server, handshakes := ntlmServer("goku", delay)
+	defer server.Close()
+
+	atk := NewAttacker(NTLM("CAPSULE", "goku", "kamehameha"))
+	tr := NewStaticTargeter(Target{Method: "POST", URL: server.URL, Body: []byte("punch")})
+
+	// Concurrent hits authenticate a connection each.
+	var wg sync.WaitGroup
+	results := make([]*Result, 4)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = atk.hit(tr, "", 1)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, res := range append(results, atk.hit(tr, "", 1)) {
+		if res.Code != http.StatusOK || string(res.Body) != "ok" {
+			t.Fatalf("got %d %q (%s), want 200 ok", res.Code, res.Body, res.Error)
+		}
+	}
+
+	if *handshakes != len(results) {
+		t.Errorf("got %d handshakes, want %d", *handshakes, len(results))
+	}
+
+	for _, res := range results {
+		if res.Negotiation < delay || res.Latency < res.Negotiation {
+			t.Errorf("got negotiation %v of latency %v, want one of at least %v within it", res.Negotiation, res.Latency, delay)
+		}
+	}
+
+	// Reused connections are already authenticated.
+	if res := atk.hit(tr, "", 1); res.Negotiation != 0 {
+		t.Errorf("got negotiation %v on an authenticated connection", res.Negotiation)
+	}
+
+	atk = NewAttacker(NTLM("CAPSULE", "goku", "kamehameha"), ExcludeNegotiation(true))
+	if res := atk.hit(tr, "", 1); res.Code != http.StatusOK {
+		t.Fatal(res.Error)
+	} else if res.Negotiation < delay || res.Latency >= delay {
+		t.Errorf("got latency %v with negotiation %v, want it excluded", res.Latency, res.Negotiation)
+	}
+
+	atk = NewAttacker(NTLM("CAPSULE", "vegeta", "galick"))
+	if res := atk.hit(tr, "", 1); res.Code != http.StatusUnauthorized {
+		t.Errorf("got %d with the wrong user, want 401", res.Code)
+	}
+}
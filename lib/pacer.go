@@ -295,3 +295,48 @@ func (p LinearPacer) hits(t time.Duration) float64 {
 
 	return (a*math.Pow(x, 2))/2 + b*x
 }
+
+// rampDownPacer paces hits at a rate decreasing linearly from the given one,
+// in hits per second, to zero over the given period, at the end of which it
+// stops the attack.
+type rampDownPacer struct {
+	rate   float64
+	period time.Duration
+}
+
+// Pace determines the length of time to sleep until the next hit is sent.
+func (p rampDownPacer) Pace(elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	if elapsed >= p.period {
+		return 0, true
+	}
+
+	if float64(hits) < p.hits(elapsed) {
+		// Running behind, send next hit immediately.
+		return 0, false
+	}
+
+	// The next hit is due when hits(t) = hits+1, unless the ramp ends first.
+	T := p.period.Seconds()
+	d := 1 - 2*float64(hits+1)/(p.rate*T)
+	if d < 0 {
+		return 0, true
+	}
+
+	due := time.Duration(T * (1 - math.Sqrt(d)) * 1e9)
+	return due - elapsed, false
+}
+
+// Rate returns the rampDownPacer's instantaneous hit rate at the given
+// elapsed duration of the ramp.
+func (p rampDownPacer) Rate(elapsed time.Duration) float64 {
+	if elapsed >= p.period {
+		return 0
+	}
+	return p.rate * (1 - elapsed.Seconds()/p.period.Seconds())
+}
+
+// hits returns the number of hits due during the first t of the ramp.
+func (p rampDownPacer) hits(t time.Duration) float64 {
+	x := t.Seconds()
+	return p.rate*x - p.rate*x*x/(2*p.period.Seconds())
+}
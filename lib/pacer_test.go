@@ -425,3 +425,47 @@ func TestLinearPacer_Rate(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestRampDownPacer(t *testing.T) {
+	t.Parallel()
+
+	p := rampDownPacer{rate: 100, period: 2 * time.Second}
+
+	// Simulate the attack loop, hitting immediately after each wait.
+	var (
+		elapsed time.Duration
+		hits    uint64
+	)
+
+	for {
+		wait, stop := p.Pace(elapsed, hits)
+		if stop {
+			break
+		} else if wait < 0 {
+			t.Fatalf("Pace(%v, %d) = %v, want a wait >= 0", elapsed, hits, wait)
+		}
+		elapsed += wait
+		hits++
+	}
+
+	// The rate decreases from 100/s to zero over 2s: 100 hits.
+	if hits < 99 || hits > 100 {
+		t.Errorf("got %d hits, want 100", hits)
+	} else if elapsed > p.period {
+		t.Errorf("hit at %v, after the ramp-down period of %v", elapsed, p.period)
+	}
+
+	for _, tc := range []struct {
+		elapsed time.Duration
+		rate    float64
+	}{
+		{0, 100},
+		{time.Second, 50},
+		{2 * time.Second, 0},
+		{3 * time.Second, 0},
+	} {
+		if got := p.Rate(tc.elapsed); !floatEqual(got, tc.rate) {
+			t.Errorf("Rate(%v) = %v, want %v", tc.elapsed, got, tc.rate)
+		}
+	}
+}
@@ -0,0 +1,841 @@
+package vegeta
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"time"
+
+	"github.com/golang/snappy"
+)
+
+const parquetMagic = "PAR1"
+
+// Parquet physical types, encodings, compression codecs and page types.
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetUncompressed = 0
+	parquetSnappy       = 1
+
+	parquetDataPage = 0
+)
+
+// Parquet converted types, for readers which don't support logical types.
+const (
+	parquetNone   = -1
+	parquetUTF8   = 0
+	parquetUint16 = 12
+	parquetUint64 = 14
+)
+
+// Row groups are written once their buffered columns reach either limit.
+const (
+	parquetRowGroupBytes = 64 << 20
+	parquetRowGroupRows  = 1 << 20
+)
+
+// parquetColumn is a column of the Parquet schema of Results.
+type parquetColumn struct {
+	name      string
+	typ       int32
+	converted int32
+	logical   func(*thriftWriter) // writes the LogicalType field, if any
+	put       func([]byte, *Result) []byte
+	get       func([]byte, *Result) ([]byte, error)
+}
+
+// parquetColumns are the columns of Parquet encoded Results, one per
+// Result field. All columns are required and PLAIN encoded.
+var parquetColumns = []parquetColumn{
+	{
+		name: "timestamp", typ: parquetInt64, converted: parquetNone, logical: parquetTimestamp,
+		put: func(b []byte, r *Result) []byte { return appendInt64(b, r.Timestamp.UnixNano()) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readInt64(b)
+			r.Timestamp = time.Unix(0, v)
+			return b, err
+		},
+	},
+	{
+		name: "attack", typ: parquetByteArray, converted: parquetUTF8, logical: parquetString,
+		put: func(b []byte, r *Result) []byte { return appendByteArray(b, r.Attack) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readByteArray(b)
+			r.Attack = string(v)
+			return b, err
+		},
+	},
+	{
+		name: "seq", typ: parquetInt64, converted: parquetUint64, logical: parquetUint(64),
+		put: func(b []byte, r *Result) []byte { return appendInt64(b, int64(r.Seq)) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readInt64(b)
+			r.Seq = uint64(v)
+			return b, err
+		},
+	},
+	{
+		name: "code", typ: parquetInt32, converted: parquetUint16, logical: parquetUint(16),
+		put: func(b []byte, r *Result) []byte { return appendInt32(b, int32(r.Code)) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readInt32(b)
+			r.Code = uint16(v)
+			return b, err
+		},
+	},
+	{
+		name: "latency", typ: parquetInt64, converted: parquetNone,
+		put: func(b []byte, r *Result) []byte { return appendInt64(b, int64(r.Latency)) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readInt64(b)
+			r.Latency = time.Duration(v)
+			return b, err
+		},
+	},
+	{
+		name: "bytes_out", typ: parquetInt64, converted: parquetUint64, logical: parquetUint(64),
+		put: func(b []byte, r *Result) []byte { return appendInt64(b, int64(r.BytesOut)) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readInt64(b)
+			r.BytesOut = uint64(v)
+			return b, err
+		},
+	},
+	{
+		name: "bytes_in", typ: parquetInt64, converted: parquetUint64, logical: parquetUint(64),
+		put: func(b []byte, r *Result) []byte { return appendInt64(b, int64(r.BytesIn)) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readInt64(b)
+			r.BytesIn = uint64(v)
+			return b, err
+		},
+	},
+	{
+		name: "error", typ: parquetByteArray, converted: parquetUTF8, logical: parquetString,
+		put: func(b []byte, r *Result) []byte { return appendByteArray(b, r.Error) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readByteArray(b)
+			r.Error = string(v)
+			return b, err
+		},
+	},
+	{
+		name: "body", typ: parquetByteArray, converted: parquetNone,
+		put: func(b []byte, r *Result) []byte { return appendByteArray(b, string(r.Body)) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readByteArray(b)
+			r.Body = append([]byte(nil), v...)
+			return b, err
+		},
+	},
+	{
+		name: "method", typ: parquetByteArray, converted: parquetUTF8, logical: parquetString,
+		put: func(b []byte, r *Result) []byte { return appendByteArray(b, r.Method) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readByteArray(b)
+			r.Method = string(v)
+			return b, err
+		},
+	},
+	{
+		name: "url", typ: parquetByteArray, converted: parquetUTF8, logical: parquetString,
+		put: func(b []byte, r *Result) []byte { return appendByteArray(b, r.URL) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readByteArray(b)
+			r.URL = string(v)
+			return b, err
+		},
+	},
+	{
+		// Headers are stored in their HTTP wire format, as in the CSV encoding.
+		name: "headers", typ: parquetByteArray, converted: parquetUTF8, logical: parquetString,
+		put: func(b []byte, r *Result) []byte { return appendByteArray(b, string(headerBytes(r.Headers))) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readByteArray(b)
+			if err != nil || len(v) == 0 {
+				r.Headers = nil
+				return b, err
+			}
+
+			r.Headers, err = parseHeader(v)
+			return b, err
+		},
+	},
+	{
+		name: "weight", typ: parquetInt64, converted: parquetUint64, logical: parquetUint(64),
+		put: func(b []byte, r *Result) []byte { return appendInt64(b, int64(r.Weight)) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readInt64(b)
+			r.Weight = uint64(v)
+			return b, err
+		},
+	},
+	{
+		name: "request_body", typ: parquetByteArray, converted: parquetNone,
+		put: func(b []byte, r *Result) []byte { return appendByteArray(b, string(r.RequestBody)) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readByteArray(b)
+			r.RequestBody = nil
+			if len(v) > 0 {
+				r.RequestBody = append([]byte(nil), v...)
+			}
+			return b, err
+		},
+	},
+	{
+		name: "request_headers", typ: parquetByteArray, converted: parquetUTF8, logical: parquetString,
+		put: func(b []byte, r *Result) []byte { return appendByteArray(b, string(headerBytes(r.RequestHeaders))) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readByteArray(b)
+			if err != nil {
+				return b, err
+			}
+			r.RequestHeaders, err = parseHeader(v)
+			return b, err
+		},
+	},
+	{
+		// Labels are stored as URL query strings, as in the CSV encoding.
+		name: "labels", typ: parquetByteArray, converted: parquetUTF8, logical: parquetString,
+		put: func(b []byte, r *Result) []byte { return appendByteArray(b, labelsQuery(r.Labels)) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readByteArray(b)
+			if err != nil {
+				return b, err
+			}
+			r.Labels, err = parseLabels(string(v))
+			return b, err
+		},
+	},
+	{
+		name: "remote_addr", typ: parquetByteArray, converted: parquetUTF8, logical: parquetString,
+		put: func(b []byte, r *Result) []byte { return appendByteArray(b, r.RemoteAddr) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readByteArray(b)
+			r.RemoteAddr = string(v)
+			return b, err
+		},
+	},
+	{
+		name: "local_addr", typ: parquetByteArray, converted: parquetUTF8, logical: parquetString,
+		put: func(b []byte, r *Result) []byte { return appendByteArray(b, r.LocalAddr) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readByteArray(b)
+			r.LocalAddr = string(v)
+			return b, err
+		},
+	},
+	{
+		name: "tls_version", typ: parquetByteArray, converted: parquetUTF8, logical: parquetString,
+		put: func(b []byte, r *Result) []byte { return appendByteArray(b, r.TLSVersion) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readByteArray(b)
+			r.TLSVersion = string(v)
+			return b, err
+		},
+	},
+	{
+		name: "tls_cipher_suite", typ: parquetByteArray, converted: parquetUTF8, logical: parquetString,
+		put: func(b []byte, r *Result) []byte { return appendByteArray(b, r.TLSCipherSuite) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readByteArray(b)
+			r.TLSCipherSuite = string(v)
+			return b, err
+		},
+	},
+	{
+		name: "tls_protocol", typ: parquetByteArray, converted: parquetUTF8, logical: parquetString,
+		put: func(b []byte, r *Result) []byte { return appendByteArray(b, r.TLSProtocol) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readByteArray(b)
+			r.TLSProtocol = string(v)
+			return b, err
+		},
+	},
+	{
+		// Booleans are bit packed when PLAIN encoded, so whether the TLS
+		// session was resumed is stored as a 0 or 1 integer instead.
+		name: "tls_resumed", typ: parquetInt32, converted: parquetNone,
+		put: func(b []byte, r *Result) []byte {
+			if r.TLSResumed {
+				return appendInt32(b, 1)
+			}
+			return appendInt32(b, 0)
+		},
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readInt32(b)
+			r.TLSResumed = v != 0
+			return b, err
+		},
+	},
+	{
+		// Metadata is stored JSON encoded, as in the CSV encoding.
+		name: "metadata", typ: parquetByteArray, converted: parquetUTF8, logical: parquetString,
+		put: func(b []byte, r *Result) []byte { return appendByteArray(b, metadataJSON(r.Metadata)) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readByteArray(b)
+			if err != nil {
+				return b, err
+			}
+			r.Metadata, err = parseMetadata(string(v))
+			return b, err
+		},
+	},
+	{
+		name: "worker", typ: parquetInt64, converted: parquetUint64, logical: parquetUint(64),
+		put: func(b []byte, r *Result) []byte { return appendInt64(b, int64(r.Worker)) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readInt64(b)
+			r.Worker = uint64(v)
+			return b, err
+		},
+	},
+	{
+		name: "conn_wait", typ: parquetInt64, converted: parquetNone,
+		put: func(b []byte, r *Result) []byte { return appendInt64(b, int64(r.ConnWait)) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readInt64(b)
+			r.ConnWait = time.Duration(v)
+			return b, err
+		},
+	},
+	{
+		name: "ip_family", typ: parquetByteArray, converted: parquetUTF8, logical: parquetString,
+		put: func(b []byte, r *Result) []byte { return appendByteArray(b, r.IPFamily) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readByteArray(b)
+			r.IPFamily = string(v)
+			return b, err
+		},
+	},
+	{
+		name: "negotiation", typ: parquetInt64, converted: parquetNone,
+		put: func(b []byte, r *Result) []byte { return appendInt64(b, int64(r.Negotiation)) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readInt64(b)
+			r.Negotiation = time.Duration(v)
+			return b, err
+		},
+	},
+	{
+		name: "attempt", typ: parquetInt64, converted: parquetUint64, logical: parquetUint(64),
+		put: func(b []byte, r *Result) []byte { return appendInt64(b, int64(r.Attempt)) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readInt64(b)
+			r.Attempt = uint64(v)
+			return b, err
+		},
+	},
+	{
+		name: "retried", typ: parquetInt32, converted: parquetNone,
+		put: func(b []byte, r *Result) []byte {
+			if r.Retried {
+				return appendInt32(b, 1)
+			}
+			return appendInt32(b, 0)
+		},
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readInt32(b)
+			r.Retried = v != 0
+			return b, err
+		},
+	},
+	{
+		name: "retry_delay", typ: parquetInt64, converted: parquetNone,
+		put: func(b []byte, r *Result) []byte { return appendInt64(b, int64(r.RetryDelay)) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readInt64(b)
+			r.RetryDelay = time.Duration(v)
+			return b, err
+		},
+	},
+	{
+		name: "continued", typ: parquetInt32, converted: parquetNone,
+		put: func(b []byte, r *Result) []byte {
+			if r.Continued {
+				return appendInt32(b, 1)
+			}
+			return appendInt32(b, 0)
+		},
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readInt32(b)
+			r.Continued = v != 0
+			return b, err
+		},
+	},
+	{
+		name: "tunnel", typ: parquetInt64, converted: parquetNone,
+		put: func(b []byte, r *Result) []byte { return appendInt64(b, int64(r.Tunnel)) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readInt64(b)
+			r.Tunnel = time.Duration(v)
+			return b, err
+		},
+	},
+	{
+		name: "trace_id", typ: parquetByteArray, converted: parquetUTF8, logical: parquetString,
+		put: func(b []byte, r *Result) []byte { return appendByteArray(b, r.TraceID) },
+		get: func(b []byte, r *Result) ([]byte, error) {
+			v, b, err := readByteArray(b)
+			r.TraceID = string(v)
+			return b, err
+		},
+	},
+}
+
+// parseHeader parses a header in its HTTP wire format, or nil if it's empty.
+func parseHeader(v []byte) (http.Header, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+
+	pr := textproto.NewReader(bufio.NewReader(bytes.NewReader(v)))
+	hdr, err := pr.ReadMIMEHeader()
+	return http.Header(hdr), err
+}
+
+func parquetString(w *thriftWriter) {
+	w.begin(1) // StringType
+	w.end()
+}
+
+func parquetTimestamp(w *thriftWriter) {
+	w.begin(8) // TimestampType
+	w.bool(1, true)
+	w.begin(2)
+	w.begin(3) // NANOS
+	w.end()
+	w.end()
+	w.end()
+}
+
+func parquetUint(bits byte) func(*thriftWriter) {
+	return func(w *thriftWriter) {
+		w.begin(10) // IntType
+		w.field(1, thriftByte)
+		w.buf = append(w.buf, bits)
+		w.bool(2, false)
+		w.end()
+	}
+}
+
+func appendInt32(b []byte, v int32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendInt64(b []byte, v int64) []byte {
+	return append(appendInt32(b, int32(v)), byte(v>>32), byte(v>>40), byte(v>>48), byte(v>>56))
+}
+
+func appendByteArray(b []byte, v string) []byte {
+	return append(appendInt32(b, int32(len(v))), v...)
+}
+
+var errParquetTruncated = errors.New("parquet: truncated column data")
+
+func readInt32(b []byte) (int32, []byte, error) {
+	if len(b) < 4 {
+		return 0, b, errParquetTruncated
+	}
+	return int32(binary.LittleEndian.Uint32(b)), b[4:], nil
+}
+
+func readInt64(b []byte) (int64, []byte, error) {
+	if len(b) < 8 {
+		return 0, b, errParquetTruncated
+	}
+	return int64(binary.LittleEndian.Uint64(b)), b[8:], nil
+}
+
+func readByteArray(b []byte) ([]byte, []byte, error) {
+	n, b, err := readInt32(b)
+	if err != nil {
+		return nil, b, err
+	} else if n < 0 || int(n) > len(b) {
+		return nil, b, errParquetTruncated
+	}
+	return b[:n], b[n:], nil
+}
+
+// parquetWriter buffers Results column by column and writes them out as
+// Snappy compressed row groups of a Parquet file.
+type parquetWriter struct {
+	w      io.Writer
+	off    int64
+	rows   int
+	size   int
+	cols   [][]byte
+	groups []parquetRowGroup
+	err    error
+}
+
+type parquetRowGroup struct {
+	rows, size int64
+	chunks     []parquetChunk
+}
+
+type parquetChunk struct {
+	offset, size, compressed int64
+}
+
+// NewParquetEncoder returns an Encoder which writes Results as rows of an
+// Apache Parquet file, with a column per Result field, and an io.Closer
+// which must be called once done to write the last row group and the file
+// footer.
+func NewParquetEncoder(w io.Writer) (Encoder, io.Closer) {
+	pw := &parquetWriter{w: w, cols: make([][]byte, len(parquetColumns))}
+	return pw.encode, pw
+}
+
+func (pw *parquetWriter) encode(r *Result) error {
+	if pw.err != nil {
+		return pw.err
+	}
+
+	for i, col := range parquetColumns {
+		n := len(pw.cols[i])
+		pw.cols[i] = col.put(pw.cols[i], r)
+		pw.size += len(pw.cols[i]) - n
+	}
+
+	if pw.rows++; pw.rows >= parquetRowGroupRows || pw.size >= parquetRowGroupBytes {
+		return pw.flush()
+	}
+
+	return nil
+}
+
+func (pw *parquetWriter) write(b []byte) {
+	if pw.err != nil {
+		return
+	}
+	n, err := pw.w.Write(b)
+	pw.off += int64(n)
+	pw.err = err
+}
+
+// flush writes the buffered rows as a row group with a single data page
+// per column.
+func (pw *parquetWriter) flush() error {
+	if pw.off == 0 {
+		pw.write([]byte(parquetMagic))
+	}
+
+	if pw.rows == 0 {
+		return pw.err
+	}
+
+	g := parquetRowGroup{rows: int64(pw.rows)}
+	for i, col := range pw.cols {
+		data := snappy.Encode(nil, col)
+
+		var hdr thriftWriter
+		hdr.begin(0) // PageHeader
+		hdr.i32(1, parquetDataPage)
+		hdr.i32(2, int32(len(col)))
+		hdr.i32(3, int32(len(data)))
+		hdr.begin(5) // DataPageHeader
+		hdr.i32(1, int32(pw.rows))
+		hdr.i32(2, parquetPlain)
+		hdr.i32(3, parquetRLE)
+		hdr.i32(4, parquetRLE)
+		hdr.end()
+		hdr.end()
+
+		c := parquetChunk{
+			offset:     pw.off,
+			size:       int64(len(hdr.buf) + len(col)),
+			compressed: int64(len(hdr.buf) + len(data)),
+		}
+
+		pw.write(hdr.buf)
+		pw.write(data)
+
+		g.chunks = append(g.chunks, c)
+		g.size += c.size
+		pw.cols[i] = col[:0]
+	}
+
+	pw.groups = append(pw.groups, g)
+	pw.rows, pw.size = 0, 0
+
+	return pw.err
+}
+
+// Close writes the buffered rows and the file footer.
+func (pw *parquetWriter) Close() error {
+	if err := pw.flush(); err != nil {
+		return err
+	}
+
+	var rows int64
+	for _, g := range pw.groups {
+		rows += g.rows
+	}
+
+	var meta thriftWriter
+	meta.begin(0) // FileMetaData
+	meta.i32(1, 1)
+
+	meta.list(2, thriftStruct, len(parquetColumns)+1)
+	meta.begin(0) // Root SchemaElement
+	meta.string(4, "schema")
+	meta.i32(5, int32(len(parquetColumns)))
+	meta.end()
+	for _, col := range parquetColumns {
+		meta.begin(0)
+		meta.i32(1, col.typ)
+		meta.i32(3, 0) // REQUIRED
+		meta.string(4, col.name)
+		if col.converted != parquetNone {
+			meta.i32(6, col.converted)
+		}
+		if col.logical != nil {
+			meta.begin(10)
+			col.logical(&meta)
+			meta.end()
+		}
+		meta.end()
+	}
+
+	meta.i64(3, rows)
+
+	meta.list(4, thriftStruct, len(pw.groups))
+	for _, g := range pw.groups {
+		meta.begin(0) // RowGroup
+		meta.list(1, thriftStruct, len(g.chunks))
+		for i, c := range g.chunks {
+			col := parquetColumns[i]
+			meta.begin(0) // ColumnChunk
+			meta.i64(2, c.offset)
+			meta.begin(3) // ColumnMetaData
+			meta.i32(1, col.typ)
+			meta.list(2, thriftI32, 2)
+			meta.zigzag(parquetPlain)
+			meta.zigzag(parquetRLE)
+			meta.list(3, thriftBinary, 1)
+			meta.varint(uint64(len(col.name)))
+			meta.buf = append(meta.buf, col.name...)
+			meta.i32(4, parquetSnappy)
+			meta.i64(5, g.rows)
+			meta.i64(6, c.size)
+			meta.i64(7, c.compressed)
+			meta.i64(9, c.offset)
+			meta.end()
+			meta.end()
+		}
+		meta.i64(2, g.size)
+		meta.i64(3, g.rows)
+		meta.end()
+	}
+
+	meta.string(6, "vegeta")
+	meta.end()
+
+	pw.write(meta.buf)
+	pw.write(appendInt32(nil, int32(len(meta.buf))))
+	pw.write([]byte(parquetMagic))
+
+	return pw.err
+}
+
+// NewParquetDecoder returns a Decoder of Results from an Apache Parquet file
+// as written by the Encoder returned by NewParquetEncoder. Since Parquet
+// metadata is stored at the end of files, the whole file is read into memory
+// on the first call.
+func NewParquetDecoder(r io.Reader) Decoder {
+	var (
+		pr  *parquetReader
+		err error
+	)
+
+	return func(res *Result) error {
+		if pr == nil && err == nil {
+			pr, err = newParquetReader(r)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return pr.decode(res)
+	}
+}
+
+// parquetReader decodes Results from a Parquet file, one row group at a time.
+type parquetReader struct {
+	data    []byte
+	schema  map[string]thriftFields
+	groups  []interface{}
+	rows    int64
+	columns []parquetCursor
+}
+
+type parquetCursor struct {
+	col  *parquetColumn
+	data []byte
+}
+
+func newParquetReader(r io.Reader) (*parquetReader, error) {
+	magic := make([]byte, len(parquetMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	} else if string(magic) != parquetMagic {
+		return nil, errors.New("parquet: bad magic number")
+	}
+
+	rest, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data := append(magic, rest...)
+	if len(data) < 12 || string(data[len(data)-4:]) != parquetMagic {
+		return nil, errors.New("parquet: bad file footer")
+	}
+
+	n := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if n < 0 || n > len(data)-12 {
+		return nil, errors.New("parquet: bad file footer length")
+	}
+
+	tr := thriftReader{buf: data[len(data)-8-n : len(data)-8]}
+	meta, err := tr.fields()
+	if err != nil {
+		return nil, err
+	}
+
+	pr := &parquetReader{
+		data:   data,
+		schema: map[string]thriftFields{},
+		groups: meta.list(4),
+	}
+
+	for _, el := range meta.list(2) {
+		if f, ok := el.(thriftFields); ok {
+			pr.schema[string(f.bytes(4))] = f
+		}
+	}
+
+	return pr, nil
+}
+
+func (pr *parquetReader) decode(r *Result) (err error) {
+	for pr.rows == 0 {
+		if len(pr.groups) == 0 {
+			return io.EOF
+		}
+
+		g, _ := pr.groups[0].(thriftFields)
+		if err = pr.load(g); err != nil {
+			return err
+		}
+		pr.groups = pr.groups[1:]
+	}
+
+	for i := range pr.columns {
+		c := &pr.columns[i]
+		if c.data, err = c.col.get(c.data, r); err != nil {
+			return err
+		}
+	}
+
+	pr.rows--
+	return nil
+}
+
+// load reads and decompresses the data pages of the known columns of
+// the given row group.
+func (pr *parquetReader) load(g thriftFields) error {
+	pr.rows, pr.columns = g.int(3), pr.columns[:0]
+
+	for _, el := range g.list(1) {
+		cc, _ := el.(thriftFields)
+		md := cc.fields(3)
+
+		path := md.list(3)
+		if len(path) != 1 {
+			continue
+		}
+
+		name, _ := path[0].([]byte)
+		col := parquetColumnNamed(string(name))
+		if col == nil {
+			continue
+		}
+
+		if el := pr.schema[col.name]; el.int(3) != 0 {
+			return fmt.Errorf("parquet: unsupported optional or repeated column %q", col.name)
+		} else if md.int(1) != int64(col.typ) {
+			return fmt.Errorf("parquet: unexpected type of column %q", col.name)
+		} else if _, ok := md[11]; ok {
+			return fmt.Errorf("parquet: unsupported dictionary encoding of column %q", col.name)
+		}
+
+		c := parquetCursor{col: col}
+		for values, off := int64(0), md.int(9); values < md.int(5); {
+			if off < 0 || off >= int64(len(pr.data)) {
+				return errParquetTruncated
+			}
+
+			tr := thriftReader{buf: pr.data, off: int(off)}
+			ph, err := tr.fields()
+			if err != nil {
+				return err
+			}
+
+			size := ph.int(3)
+			if size < 0 || int64(tr.off)+size > int64(len(pr.data)) {
+				return errParquetTruncated
+			}
+
+			page := pr.data[tr.off : int64(tr.off)+size]
+			off = int64(tr.off) + size
+
+			dph := ph.fields(5)
+			if ph.int(1) != parquetDataPage || dph == nil {
+				return fmt.Errorf("parquet: unsupported page type in column %q", col.name)
+			} else if dph.int(2) != parquetPlain {
+				return fmt.Errorf("parquet: unsupported encoding of column %q", col.name)
+			}
+
+			switch md.int(4) {
+			case parquetUncompressed:
+			case parquetSnappy:
+				if page, err = snappy.Decode(nil, page); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("parquet: unsupported compression of column %q", col.name)
+			}
+
+			c.data = append(c.data, page...)
+			values += dph.int(1)
+		}
+
+		pr.columns = append(pr.columns, c)
+	}
+
+	return nil
+}
+
+func parquetColumnNamed(name string) *parquetColumn {
+	for i := range parquetColumns {
+		if parquetColumns[i].name == name {
+			return &parquetColumns[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package vegeta
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParquetEncoding(t *testing.T) {
+	t.Parallel()
+
+	want := make([]Result, 5)
+	for i := range want {
+		want[i] = Result{
+			Attack:    "test",
+			Seq:       uint64(i),
+			Code:      uint16(200 + i),
+			Timestamp: time.Unix(0, int64(i)*1e9+7),
+			Latency:   time.Duration(i) * time.Millisecond,
+			BytesOut:  uint64(i * 10),
+			BytesIn:   1 << 63,
+			Method:    "GET",
+			URL:       "http://localhost:8080/" + strings.Repeat("a", i),
+		}
+	}
+
+	want[1].Error = "connection refused"
+	want[2].Body = []byte{0, 1, 2, 255}
+	want[3].Headers = http.Header{"Content-Type": {"text/plain"}, "X-Foo": {"a", "b"}}
+	want[4].Weight = 10
+	want[4].RequestBody = []byte(`{"id":4}`)
+	want[4].RequestHeaders = http.Header{"Content-Type": {"application/json"}}
+	want[4].Labels = map[string]string{"build": "1234", "region": "eu west"}
+	want[4].RemoteAddr = "10.0.0.1:443"
+	want[4].LocalAddr = "10.0.0.2:51234"
+	want[4].TLSVersion = "TLS 1.3"
+	want[4].TLSCipherSuite = "TLS_AES_128_GCM_SHA256"
+	want[4].TLSProtocol = "h2"
+	want[4].TLSResumed = true
+	want[4].Worker = 3
+	want[4].ConnWait = 15 * time.Millisecond
+	want[4].IPFamily = "ipv6"
+	want[4].Negotiation = 30 * time.Millisecond
+	want[4].Attempt = 1
+	want[4].Retried = true
+	want[4].RetryDelay = 120 * time.Millisecond
+	want[4].Continued = true
+	want[4].Tunnel = 25 * time.Millisecond
+	want[4].TraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	want[0].Metadata = &Metadata{
+		Attack:   "checkout",
+		Rate:     "50/1s",
+		Duration: time.Minute,
+		Version:  "12.8.4",
+		Args:     []string{"attack", "-rate=50/1s"},
+		Began:    want[0].Timestamp,
+	}
+
+	for _, tc := range []struct {
+		name string
+		dec  func(io.Reader) Decoder
+	}{
+		{"parquet", NewParquetDecoder},
+		{"auto", DecoderFor},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc, closer := NewParquetEncoder(&buf)
+			for i := range want {
+				if err := enc(&want[i]); err != nil {
+					t.Fatal(err)
+				}
+				if i == 2 { // Force a second row group.
+					if err := closer.(*parquetWriter).flush(); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			if err := closer.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := buf.String(); !strings.HasPrefix(got, parquetMagic) || !strings.HasSuffix(got, parquetMagic) {
+				t.Fatalf("missing magic number in %q", got)
+			}
+
+			dec := tc.dec(&buf)
+			for i := range want {
+				var got Result
+				if err := dec(&got); err != nil {
+					t.Fatalf("result %d: %v", i, err)
+				} else if !got.Equal(want[i]) {
+					t.Fatalf("result %d: got %#v, want %#v", i, got, want[i])
+				}
+			}
+
+			if err := dec(&Result{}); err != io.EOF {
+				t.Fatalf("got error %v, want %v", err, io.EOF)
+			}
+		})
+	}
+}
+
+func TestParquetEncodingEmpty(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	_, closer := NewParquetEncoder(&buf)
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewParquetDecoder(&buf)(&Result{}); err != io.EOF {
+		t.Fatalf("got error %v, want %v", err, io.EOF)
+	}
+}
@@ -0,0 +1,126 @@
+package vegeta
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// PercentileTimeline is a Report which computes latency percentiles per
+// time window of the given Interval, so that latency degradation during
+// an attack is visible as a series.
+type PercentileTimeline struct {
+	// Interval is the duration of each time window.
+	Interval time.Duration
+
+	windows map[int64]*percentileWindow
+}
+
+type percentileWindow struct {
+	requests  uint64
+	latencies LatencyMetrics
+}
+
+// PercentileWindow holds the latency percentiles of a time window.
+type PercentileWindow struct {
+	// Timestamp is the start of the time window.
+	Timestamp time.Time `json:"timestamp"`
+	// Requests is the number of requests in the time window.
+	Requests uint64 `json:"requests"`
+	// P50 is the 50th percentile request latency.
+	P50 time.Duration `json:"50th"`
+	// P95 is the 95th percentile request latency.
+	P95 time.Duration `json:"95th"`
+	// P99 is the 99th percentile request latency.
+	P99 time.Duration `json:"99th"`
+	// Max is the maximum observed request latency.
+	Max time.Duration `json:"max"`
+}
+
+// Add implements the Add method of the Report interface by adding the
+// latency of the given Result to its time window.
+func (p *PercentileTimeline) Add(r *Result) {
+	if p.windows == nil {
+		p.windows = map[int64]*percentileWindow{}
+	}
+
+	if p.Interval <= 0 {
+		p.Interval = time.Second
+	}
+
+	ts := r.Timestamp.Truncate(p.Interval).UnixNano()
+	w, ok := p.windows[ts]
+	if !ok {
+		w = &percentileWindow{}
+		p.windows[ts] = w
+	}
+
+	w.requests++
+	w.latencies.Add(r.Latency)
+}
+
+// Windows returns the latency percentiles of every time window with
+// results, sorted by time.
+func (p *PercentileTimeline) Windows() []PercentileWindow {
+	keys := make([]int64, 0, len(p.windows))
+	for ts := range p.windows {
+		keys = append(keys, ts)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	ws := make([]PercentileWindow, 0, len(keys))
+	for _, ts := range keys {
+		w := p.windows[ts]
+		ws = append(ws, PercentileWindow{
+			Timestamp: time.Unix(0, ts).UTC(),
+			Requests:  w.requests,
+			P50:       w.latencies.Quantile(0.50),
+			P95:       w.latencies.Quantile(0.95),
+			P99:       w.latencies.Quantile(0.99),
+			Max:       w.latencies.Max,
+		})
+	}
+
+	return ws
+}
+
+// NewPercentileTimelineCSVReporter returns a Reporter that writes out the
+// time windows of a PercentileTimeline as CSV, with latencies in nanoseconds.
+func NewPercentileTimelineCSVReporter(p *PercentileTimeline) Reporter {
+	return func(w io.Writer) error {
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"timestamp", "requests", "latency_50", "latency_95", "latency_99", "latency_max"}); err != nil {
+			return err
+		}
+
+		d := func(v time.Duration) string { return strconv.FormatInt(int64(v), 10) }
+		for _, pw := range p.Windows() {
+			err := cw.Write([]string{
+				pw.Timestamp.Format(time.RFC3339Nano),
+				strconv.FormatUint(pw.Requests, 10),
+				d(pw.P50),
+				d(pw.P95),
+				d(pw.P99),
+				d(pw.Max),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		cw.Flush()
+		return cw.Error()
+	}
+}
+
+// NewPercentileTimelineJSONReporter returns a Reporter that writes out the
+// time windows of a PercentileTimeline as a JSON array.
+func NewPercentileTimelineJSONReporter(p *PercentileTimeline) Reporter {
+	return func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(p.Windows())
+	}
+}
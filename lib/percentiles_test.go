@@ -0,0 +1,62 @@
+package vegeta
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentileTimeline(t *testing.T) {
+	t.Parallel()
+
+	p := PercentileTimeline{Interval: time.Second}
+	start := time.Unix(10, 0)
+	for i := 0; i < 100; i++ {
+		p.Add(&Result{Timestamp: start, Latency: time.Millisecond})
+		p.Add(&Result{Timestamp: start.Add(3 * time.Second), Latency: time.Second})
+	}
+
+	ws := p.Windows()
+	if len(ws) != 2 {
+		t.Fatalf("got %d windows, want 2", len(ws))
+	}
+
+	for i, want := range []PercentileWindow{
+		{Timestamp: start.UTC(), Requests: 100, P50: time.Millisecond, P95: time.Millisecond, P99: time.Millisecond, Max: time.Millisecond},
+		{Timestamp: start.Add(3 * time.Second).UTC(), Requests: 100, P50: time.Second, P95: time.Second, P99: time.Second, Max: time.Second},
+	} {
+		if ws[i] != want {
+			t.Errorf("window %d: got %+v, want %+v", i, ws[i], want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := NewPercentileTimelineCSVReporter(&p).Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"timestamp,requests,latency_50,latency_95,latency_99,latency_max",
+		"1970-01-01T00:00:10Z,100,1000000,1000000,1000000,1000000",
+		"1970-01-01T00:00:13Z,100,1000000000,1000000000,1000000000,1000000000",
+		"",
+	}, "\n")
+
+	if got := buf.String(); got != want {
+		t.Errorf("got CSV:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := NewPercentileTimelineJSONReporter(&p).Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []PercentileWindow
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	} else if len(got) != 2 || got[1] != ws[1] {
+		t.Errorf("got JSON windows %+v, want %+v", got, ws)
+	}
+}
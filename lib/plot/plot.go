@@ -24,6 +24,7 @@ type Plot struct {
 	title     string
 	threshold int
 	series    map[string]*labeledSeries
+	usage     map[string]*timeSeries
 	label     Labeler
 }
 
@@ -135,7 +136,7 @@ func Label(l Labeler) Opt {
 // New returns a Plot with the given Opts applied.
 // If no Label opt is given, ErrorLabeler will be used as default.
 func New(opts ...Opt) *Plot {
-	p := &Plot{series: map[string]*labeledSeries{}}
+	p := &Plot{series: map[string]*labeledSeries{}, usage: map[string]*timeSeries{}}
 	for _, opt := range opts {
 		opt(p)
 	}
@@ -157,6 +158,33 @@ func (p *Plot) Add(r *vegeta.Result) error {
 	return s.add(r)
 }
 
+// AddUsage adds the CPU and memory usage of the given source host, sampled at
+// the given time elapsed since the beginning of the given attack, to the Plot
+// time series, which plots it on a second Y axis. Samples of each source must
+// be added in time order, before the Plot is closed.
+func (p *Plot) AddUsage(attack, source string, elapsed time.Duration, u vegeta.HostUsage) error {
+	for _, v := range []struct {
+		label string
+		value float64
+	}{
+		{source + " CPU %", u.CPU * 100},
+		{source + " memory %", u.Memory * 100},
+	} {
+		key := attack + ": " + v.label
+		ts, ok := p.usage[key]
+		if !ok {
+			ts = newTimeSeries(attack, v.label)
+			p.usage[key] = ts
+		}
+
+		// timestamp in ms precision
+		if err := ts.add(uint64(elapsed)/1e6, v.value); err != nil {
+			return fmt.Errorf("usage of %s in %v", source, err)
+		}
+	}
+	return nil
+}
+
 // Close closes the HTML plot for writing.
 func (p *Plot) Close() {
 	for _, as := range p.series {
@@ -164,6 +192,9 @@ func (p *Plot) Close() {
 			ts.data.Finish()
 		}
 	}
+	for _, ts := range p.usage {
+		ts.data.Finish()
+	}
 }
 
 // WriteTo writes the HTML plot to the give io.Writer.
@@ -178,6 +209,11 @@ func (p Plot) WriteTo(w io.Writer) (n int64, err error) {
 		ShowRoller  bool     `json:"showRoller"`
 		LogScale    bool     `json:"logScale"`
 		StrokeWidth float64  `json:"strokeWidth"`
+
+		// Usage series are plotted on a second, linear Y axis.
+		Y2Label string                            `json:"y2label,omitempty"`
+		Series  map[string]map[string]string      `json:"series,omitempty"`
+		Axes    map[string]map[string]interface{} `json:"axes,omitempty"`
 	}
 
 	type plotData struct {
@@ -189,7 +225,7 @@ func (p Plot) WriteTo(w io.Writer) (n int64, err error) {
 		Opts          template.JS
 	}
 
-	dp, labels, err := p.data()
+	dp, labels, usage, err := p.data()
 	if err != nil {
 		return 0, err
 	}
@@ -210,7 +246,19 @@ func (p Plot) WriteTo(w io.Writer) (n int64, err error) {
 		ShowRoller:  true,
 		LogScale:    true,
 		StrokeWidth: 1.3,
-		Colors:      labelColors(labels[1:]),
+		Colors:      labelColors(labels[1 : len(labels)-usage]),
+	}
+
+	if usage > 0 {
+		opts.Y2Label = "Usage (%)"
+		opts.Series = make(map[string]map[string]string, usage)
+		for i, label := range labels[len(labels)-usage:] {
+			opts.Series[label] = map[string]string{"axis": "y2"}
+			opts.Colors = append(opts.Colors, blues[i%len(blues)])
+		}
+		opts.Axes = map[string]map[string]interface{}{
+			"y2": {"logscale": false, "valueRange": []float64{0, 100}},
+		}
 	}
 
 	optsJSON, err := json.MarshalIndent(&opts, "    ", " ")
@@ -259,6 +307,15 @@ var (
 		"#185717",
 		"#053E0A",
 	}
+	blues = []string{
+		"#7FB3E0",
+		"#5E98CC",
+		"#437DB5",
+		"#2C649C",
+		"#1A4C82",
+		"#0C3667",
+		"#02224D",
+	}
 )
 
 func labelColors(labels []string) []string {
@@ -281,9 +338,11 @@ func labelColors(labels []string) []string {
 }
 
 // See http://dygraphs.com/data.html
-func (p *Plot) data() (dataPoints, []string, error) {
+// The usage series, if any, are the last ones, of which it returns the count.
+func (p *Plot) data() (dataPoints, []string, int, error) {
 	var (
 		series []*timeSeries
+		usage  []*timeSeries
 		count  int
 	)
 
@@ -296,8 +355,13 @@ func (p *Plot) data() (dataPoints, []string, error) {
 		}
 	}
 
+	for _, s := range p.usage {
+		usage = append(usage, s)
+		count += s.len
+	}
+
 	var (
-		size   = 1 + len(series)
+		size   = 1 + len(series) + len(usage)
 		nan    = math.NaN()
 		labels = make([]string, size)
 		data   = make(dataPoints, 0, count)
@@ -305,15 +369,17 @@ func (p *Plot) data() (dataPoints, []string, error) {
 
 	labels[0] = "Seconds"
 
-	sort.Slice(series, func(i, j int) bool {
-		return series[i].attack+series[i].label <
-			series[j].attack+series[j].label
-	})
+	for _, ss := range [][]*timeSeries{series, usage} {
+		sort.Slice(ss, func(i, j int) bool {
+			return ss[i].attack+ss[i].label < ss[j].attack+ss[j].label
+		})
+	}
 
+	series = append(series, usage...)
 	for i, s := range series {
 		points, err := lttb.Downsample(s.len, p.threshold, s.iter())
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, 0, err
 		}
 
 		for _, p := range points {
@@ -330,7 +396,7 @@ func (p *Plot) data() (dataPoints, []string, error) {
 
 	sort.Sort(data)
 
-	return data, labels, nil
+	return data, labels, len(usage), nil
 }
 
 func asset(path string) ([]byte, error) {
@@ -65,6 +65,51 @@ func TestPlot(t *testing.T) {
 	}
 }
 
+func TestPlotUsage(t *testing.T) {
+	t.Parallel()
+
+	p := New(Title("TestPlotUsage"))
+	began := time.Unix(0, 0)
+	for i := 0; i < 10; i++ {
+		r := vegeta.Result{Attack: "attack", Seq: uint64(i), Timestamp: began.Add(time.Duration(i) * time.Second)}
+		if err := p.Add(&r); err != nil {
+			t.Fatal(err)
+		}
+
+		u := vegeta.HostUsage{CPU: float64(i) / 10, Memory: 0.5}
+		if err := p.AddUsage("attack", "10.0.0.1:8098", time.Duration(i)*time.Second, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := p.AddUsage("attack", "10.0.0.1:8098", 0, vegeta.HostUsage{}); err == nil {
+		t.Error("got no error adding usage out of order")
+	}
+
+	p.Close()
+
+	data, labels, usage, err := p.data()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"Seconds", "attack: OK", "attack: 10.0.0.1:8098 CPU %", "attack: 10.0.0.1:8098 memory %"}
+	if diff := cmp.Diff(labels, want); diff != "" {
+		t.Error(diff)
+	} else if usage != 2 {
+		t.Errorf("got %d usage series, want 2", usage)
+	} else if len(data) != 30 {
+		t.Errorf("got %d data points, want 30", len(data))
+	}
+
+	var b bytes.Buffer
+	if _, err := p.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Contains(b.Bytes(), []byte(`"axis": "y2"`)) {
+		t.Error("usage series aren't plotted on the second Y axis")
+	}
+}
+
 func TestLabeledSeries(t *testing.T) {
 	t.Parallel()
 
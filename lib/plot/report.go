@@ -0,0 +1,304 @@
+package plot
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// maxErrorSamples is the maximum number of distinct errors listed in a Report.
+const maxErrorSamples = 100
+
+// A Report is a self-contained, single file HTML report of attack Results
+// which combines the summary Metrics, latency and throughput charts,
+// a status code breakdown and samples of the errors returned by the targets.
+type Report struct {
+	plot    *Plot
+	metrics vegeta.Metrics
+	seconds map[int64]*[2]uint64 // OK and ERROR results per second
+	errors  map[string]*ErrorSample
+	err     error
+}
+
+// An ErrorSample is a distinct error in a Report with the number of Results
+// that had it and the first of them.
+type ErrorSample struct {
+	Error string
+	Count uint64
+	First vegeta.Result
+}
+
+// NewReport returns a Report with the given Opts applied to its latency chart.
+// The title defaults to "Vegeta Report".
+func NewReport(opts ...Opt) *Report {
+	return &Report{
+		plot:    New(append([]Opt{Title("Vegeta Report")}, opts...)...),
+		seconds: map[int64]*[2]uint64{},
+		errors:  map[string]*ErrorSample{},
+	}
+}
+
+// Add implements the vegeta.Report interface by adding the given Result to
+// the Report. Errors in adding the Result to the latency chart are returned
+// when writing the Report.
+func (r *Report) Add(res *vegeta.Result) {
+	r.metrics.Add(res)
+
+	if err := r.plot.Add(res); err != nil && r.err == nil {
+		r.err = err
+	}
+
+	sec := res.Timestamp.Unix()
+	counts, ok := r.seconds[sec]
+	if !ok {
+		counts = &[2]uint64{}
+		r.seconds[sec] = counts
+	}
+
+	if res.Error == "" {
+		counts[0]++
+		return
+	}
+
+	counts[1]++
+
+	if s, ok := r.errors[res.Error]; ok {
+		s.Count++
+	} else if len(r.errors) < maxErrorSamples {
+		first := *res
+		first.Body = nil
+		r.errors[res.Error] = &ErrorSample{Error: res.Error, Count: 1, First: first}
+	}
+}
+
+// Close implements the vegeta.Closer interface.
+func (r *Report) Close() {
+	r.metrics.Close()
+	r.plot.Close()
+}
+
+// WriteTo writes the HTML report to the given io.Writer.
+func (r *Report) WriteTo(w io.Writer) (n int64, err error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	type statusCode struct {
+		Code    string
+		Count   int
+		Percent float64
+		Error   bool
+	}
+
+	type reportData struct {
+		Title          string
+		Summary        string
+		StatusCodes    []statusCode
+		Errors         []*ErrorSample
+		DygraphsCSS    template.CSS
+		DygraphsJS     template.JS
+		LatencyData    template.JS
+		LatencyOpts    template.JS
+		ThroughputData template.JS
+		ThroughputOpts template.JS
+	}
+
+	var summary bytes.Buffer
+	if err = vegeta.NewTextReporter(&r.metrics).Report(&summary); err != nil {
+		return 0, err
+	}
+
+	data := reportData{
+		Title:   r.plot.title,
+		Summary: summary.String(),
+	}
+
+	for code, count := range r.metrics.StatusCodes {
+		c, _ := strconv.Atoi(code)
+		data.StatusCodes = append(data.StatusCodes, statusCode{
+			Code:    code,
+			Count:   count,
+			Percent: 100 * float64(count) / float64(r.metrics.Requests),
+			Error:   c < 200 || c >= 400,
+		})
+	}
+
+	sort.Slice(data.StatusCodes, func(i, j int) bool {
+		return data.StatusCodes[i].Code < data.StatusCodes[j].Code
+	})
+
+	for _, s := range r.errors {
+		data.Errors = append(data.Errors, s)
+	}
+
+	sort.Slice(data.Errors, func(i, j int) bool {
+		if data.Errors[i].Count != data.Errors[j].Count {
+			return data.Errors[i].Count > data.Errors[j].Count
+		}
+		return data.Errors[i].Error < data.Errors[j].Error
+	})
+
+	latencies, labels, _, err := r.plot.data()
+	if err != nil {
+		return 0, err
+	}
+
+	latencyOpts := map[string]interface{}{
+		"labels":      labels,
+		"ylabel":      "Latency (ms)",
+		"xlabel":      "Seconds elapsed",
+		"legend":      "always",
+		"showRoller":  true,
+		"logScale":    true,
+		"strokeWidth": 1.3,
+		"colors":      labelColors(labels[1:]),
+	}
+
+	throughputOpts := map[string]interface{}{
+		"labels":       []string{"Seconds", "OK", "ERROR"},
+		"ylabel":       "Requests per second",
+		"xlabel":       "Seconds elapsed",
+		"legend":       "always",
+		"stackedGraph": true,
+		"fillGraph":    true,
+		"strokeWidth":  1.3,
+		"colors":       []string{greens[1], reds[1]},
+	}
+
+	for _, v := range []struct {
+		dst *template.JS
+		src interface{}
+	}{
+		{&data.LatencyOpts, latencyOpts},
+		{&data.ThroughputOpts, throughputOpts},
+	} {
+		bs, err := json.Marshal(v.src)
+		if err != nil {
+			return 0, err
+		}
+		*v.dst = template.JS(bs)
+	}
+
+	data.LatencyData = template.JS(latencies.Append(nil))
+	data.ThroughputData = template.JS(r.throughput().Append(nil))
+
+	css, err := asset("dygraph.css")
+	if err != nil {
+		return 0, err
+	}
+
+	js, err := asset("dygraph.min.js")
+	if err != nil {
+		return 0, err
+	}
+
+	data.DygraphsCSS, data.DygraphsJS = template.CSS(css), template.JS(js)
+
+	cw := countingWriter{w: w}
+	err = reportTemplate.Execute(&cw, &data)
+	return cw.n, err
+}
+
+// throughput returns the number of OK and ERROR results per second
+// elapsed since the first second of the attack.
+func (r *Report) throughput() dataPoints {
+	secs := make([]int64, 0, len(r.seconds))
+	for sec := range r.seconds {
+		secs = append(secs, sec)
+	}
+
+	sort.Slice(secs, func(i, j int) bool { return secs[i] < secs[j] })
+
+	data := make(dataPoints, 0, len(secs))
+	for _, sec := range secs {
+		counts := r.seconds[sec]
+		data = append(data, []float64{
+			float64(sec - secs[0]),
+			float64(counts[0]),
+			float64(counts[1]),
+		})
+	}
+
+	return data
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": func(f float64) string { return strconv.FormatFloat(f, 'f', 2, 64) + "%" },
+	"width":   func(f float64) string { return strconv.FormatFloat(math.Max(f, 0.5), 'f', 2, 64) + "%" },
+	"time":    func(t time.Time) string { return t.UTC().Format(time.RFC3339Nano) },
+}).Parse(`<!doctype html>
+<html>
+<head>
+  <title>{{.Title}}</title>
+  <meta charset="utf-8">
+  <style>{{.DygraphsCSS}}</style>
+  <style>
+    body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292e; }
+    pre, code { font-family: Menlo, Consolas, Courier, monospace; font-size: 13px; }
+    pre { background: #f6f8fa; padding: 1em; overflow-x: auto; }
+    table { border-collapse: collapse; margin-bottom: 1em; }
+    th, td { border: 1px solid #dfe2e5; padding: 4px 10px; text-align: left; vertical-align: top; }
+    td.num { text-align: right; }
+    .chart { width: 100%; height: 400px; margin-bottom: 3em; }
+    .bar { background: #64A550; height: 1em; }
+    .bar.error { background: #CA4E3E; }
+  </style>
+</head>
+<body>
+  <h1>{{.Title}}</h1>
+
+  <h2>Summary</h2>
+  <pre>{{.Summary}}</pre>
+
+  <h2>Latencies</h2>
+  <div id="latencies" class="chart"></div>
+
+  <h2>Throughput</h2>
+  <div id="throughput" class="chart"></div>
+
+  <h2>Status Codes</h2>
+  <table>
+    <tr><th>Code</th><th>Count</th><th>%</th><th style="width: 300px"></th></tr>
+    {{- range .StatusCodes}}
+    <tr>
+      <td class="num">{{.Code}}</td>
+      <td class="num">{{.Count}}</td>
+      <td class="num">{{percent .Percent}}</td>
+      <td><div class="bar{{if .Error}} error{{end}}" style="width: {{width .Percent}}"></div></td>
+    </tr>
+    {{- end}}
+  </table>
+
+  {{- if .Errors}}
+
+  <h2>Errors</h2>
+  <table>
+    <tr><th>Count</th><th>Error</th><th>First occurrence</th></tr>
+    {{- range .Errors}}
+    <tr>
+      <td class="num">{{.Count}}</td>
+      <td><code>{{.Error}}</code></td>
+      <td>
+        <code>{{.First.Method}} {{.First.URL}}</code><br>
+        status {{.First.Code}}, seq {{.First.Seq}}, at {{time .First.Timestamp}}
+      </td>
+    </tr>
+    {{- end}}
+  </table>
+  {{- end}}
+
+  <script>{{.DygraphsJS}}</script>
+  <script>
+  new Dygraph(document.getElementById("latencies"), {{.LatencyData}}, {{.LatencyOpts}});
+  new Dygraph(document.getElementById("throughput"), {{.ThroughputData}}, {{.ThroughputOpts}});
+  </script>
+</body>
+</html>
+`))
@@ -0,0 +1,68 @@
+package plot
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+
+	r := NewReport(Title("<Test> Report"))
+
+	began := time.Unix(0, 0)
+	for i := 0; i < 100; i++ {
+		res := vegeta.Result{
+			Seq:       uint64(i),
+			Method:    "GET",
+			URL:       "http://goku",
+			Code:      200,
+			Timestamp: began.Add(time.Duration(i) * 50 * time.Millisecond),
+			Latency:   time.Duration(i) * time.Millisecond,
+		}
+
+		if i%10 == 0 {
+			res.Code, res.Error = 500, "500 Internal Server Error"
+		} else if i == 99 {
+			res.Code, res.Error = 0, "<dial> timeout"
+		}
+
+		r.Add(&res)
+	}
+
+	r.Close()
+
+	var b bytes.Buffer
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	got := b.String()
+	for _, want := range []string{
+		"<title>&lt;Test&gt; Report</title>",
+		"Requests      [total, rate, throughput]",
+		`<td class="num">200</td>`,
+		`<td class="num">89</td>`,
+		`<td class="num">89.00%</td>`,
+		`<div class="bar error" style="width: 10.00%">`,
+		"<code>500 Internal Server Error</code>",
+		"<code>&lt;dial&gt; timeout</code>",
+		"<code>GET http://goku</code><br>\n        status 500, seq 0, at 1970-01-01T00:00:00Z",
+		// 20 results per second, 2 of which errors in every second but the last
+		"[0,18,2]",
+		"[4,17,3]",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report doesn't contain %q", want)
+		}
+	}
+
+	// Errors are sorted by count.
+	if i, j := strings.Index(got, "Internal Server Error</code>"), strings.Index(got, "timeout</code>"); i > j {
+		t.Errorf("errors not sorted by count")
+	}
+}
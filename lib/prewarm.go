@@ -0,0 +1,158 @@
+package vegeta
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Prewarm establishes n connections to the host of each of the given URLs,
+// completing their TLS handshake for https ones, which the Attacker uses
+// before dialing new ones, so that the first hits of its attacks don't wait
+// for connections to be set up. It returns the first error establishing
+// them, keeping the established ones. It must be called before Attack.
+func (a *Attacker) Prewarm(n int, urls ...string) error {
+	tr, ok := a.transport()
+	if !ok {
+		return errors.New("connections of custom transports can't be prewarmed")
+	}
+
+	hosts := map[warmHost]bool{}
+	for _, s := range urls {
+		u, err := url.Parse(s)
+		if err != nil {
+			return err
+		}
+
+		port := u.Port()
+		switch {
+		case port != "":
+		case u.Scheme == "http":
+			port = "80"
+		case u.Scheme == "https":
+			port = "443"
+		default:
+			continue
+		}
+
+		hosts[warmHost{u.Scheme, net.JoinHostPort(u.Hostname(), port)}] = true
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		first error
+	)
+
+	for h := range hosts {
+		if h.scheme == "https" {
+			tr.DialTLS = a.dialTLS
+		}
+
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(h warmHost) {
+				defer wg.Done()
+
+				dial := a.handshake
+				if h.scheme == "http" {
+					dial = func(ctx context.Context, addr string) (net.Conn, error) {
+						return a.dial(ctx, "tcp", addr)
+					}
+				}
+
+				conn, err := dial(context.Background(), h.addr)
+				if err != nil {
+					mu.Lock()
+					if first == nil {
+						first = err
+					}
+					mu.Unlock()
+					return
+				}
+
+				a.warmmu.Lock()
+				if a.warm == nil {
+					a.warm = map[warmHost][]net.Conn{}
+				}
+				a.warm[h] = append(a.warm[h], conn)
+				a.warmmu.Unlock()
+			}(h)
+		}
+	}
+
+	wg.Wait()
+	return first
+}
+
+// warmHost identifies the host of prewarmed connections.
+type warmHost struct{ scheme, addr string }
+
+// warmConn returns a prewarmed connection to the given address with the given
+// scheme, or nil if there's none left.
+func (a *Attacker) warmConn(scheme, addr string) net.Conn {
+	a.warmmu.Lock()
+	defer a.warmmu.Unlock()
+
+	h := warmHost{scheme, addr}
+	conns := a.warm[h]
+	if len(conns) == 0 {
+		return nil
+	}
+
+	conn := conns[len(conns)-1]
+	a.warm[h] = conns[:len(conns)-1]
+	return conn
+}
+
+// dialTLS dials a TLS connection to the given address, or returns a prewarmed
+// one. It replaces the transport's own TLS dialing once https connections
+// are prewarmed.
+func (a *Attacker) dialTLS(network, addr string) (net.Conn, error) {
+	if conn := a.warmConn("https", addr); conn != nil {
+		return conn, nil
+	}
+	return a.handshake(context.Background(), addr)
+}
+
+// handshake dials the given address and completes a TLS handshake over the
+// connection, as the transport would.
+func (a *Attacker) handshake(ctx context.Context, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := a.dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var c *tls.Config
+	if tr, ok := a.transport(); ok && tr.TLSClientConfig != nil {
+		c = tr.TLSClientConfig.Clone()
+	} else {
+		c = &tls.Config{}
+	}
+
+	if c.ServerName == "" {
+		c.ServerName = host
+	}
+
+	tc := tls.Client(conn, c)
+	if a.client.Timeout > 0 {
+		tc.SetDeadline(time.Now().Add(a.client.Timeout))
+	}
+
+	if err = tc.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	tc.SetDeadline(time.Time{})
+	return tc, nil
+}
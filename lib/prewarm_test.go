@@ -0,0 +1,57 @@
+package vegeta
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPrewarm(t *testing.T) {
+	t.Parallel()
+
+	for _, tls := range []bool{false, true} {
+		var conns int64
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		server.Config.ConnState = func(_ net.Conn, s http.ConnState) {
+			if s == http.StateNew {
+				atomic.AddInt64(&conns, 1)
+			}
+		}
+
+		if tls {
+			server.StartTLS()
+		} else {
+			server.Start()
+		}
+		defer server.Close()
+
+		atk := NewAttacker(RecordTLS(true))
+		if err := atk.Prewarm(3, server.URL+"/a", server.URL+"/b"); err != nil {
+			t.Fatal(err)
+		}
+
+		// The server may not have accepted all connections yet.
+		for i := 0; i < 100 && atomic.LoadInt64(&conns) < 3; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if got, want := atomic.LoadInt64(&conns), int64(3); got != want {
+			t.Errorf("TLS %t: got %d connections, want %d", tls, got, want)
+		}
+
+		tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+		res := atk.hit(tr, "", 1)
+		if res.Error != "" {
+			t.Fatalf("TLS %t: %s", tls, res.Error)
+		} else if tls && res.TLSVersion == "" {
+			t.Errorf("got no TLS version over a prewarmed connection")
+		}
+
+		if got, want := atomic.LoadInt64(&conns), int64(3); got != want {
+			t.Errorf("TLS %t: got %d connections after a hit, want %d", tls, got, want)
+		}
+	}
+}
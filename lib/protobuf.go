@@ -0,0 +1,640 @@
+package vegeta
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Protocol Buffers wire types.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+// maxProtobufSize bounds the size of decoded Result messages so that
+// corrupt or foreign input doesn't cause huge allocations.
+const maxProtobufSize = 1 << 30
+
+// NewProtobufEncoder returns an Encoder that writes Results as a stream of
+// size delimited Protocol Buffers messages, as defined in result.proto.
+func NewProtobufEncoder(w io.Writer) Encoder {
+	var msg, hdr protoBuffer
+	return func(r *Result) error {
+		msg = msg[:0]
+		msg.string(1, r.Attack)
+		msg.uint(2, r.Seq)
+		msg.uint(3, uint64(r.Code))
+		msg.uint(4, uint64(r.Timestamp.UnixNano()))
+		msg.uint(5, uint64(r.Latency))
+		msg.uint(6, r.BytesOut)
+		msg.uint(7, r.BytesIn)
+		msg.string(8, r.Error)
+		msg.string(9, string(r.Body))
+		msg.string(10, r.Method)
+		msg.string(11, r.URL)
+		msg.header(12, r.Headers, &hdr)
+		msg.uint(13, r.Weight)
+		msg.string(14, string(r.RequestBody))
+		msg.header(15, r.RequestHeaders, &hdr)
+		msg.labels(16, r.Labels, &hdr)
+		msg.string(17, r.RemoteAddr)
+		msg.string(18, r.LocalAddr)
+		msg.string(19, r.TLSVersion)
+		msg.string(20, r.TLSCipherSuite)
+		msg.string(21, r.TLSProtocol)
+		if r.TLSResumed {
+			msg.uint(22, 1)
+		}
+		if r.Metadata != nil {
+			msg.metadata(23, r.Metadata, &hdr)
+		}
+		msg.uint(24, r.Worker)
+		msg.uint(25, uint64(r.ConnWait))
+		msg.string(26, r.IPFamily)
+		msg.uint(27, uint64(r.Negotiation))
+		msg.uint(28, r.Attempt)
+		if r.Retried {
+			msg.uint(29, 1)
+		}
+		msg.uint(30, uint64(r.RetryDelay))
+		if r.Continued {
+			msg.uint(31, 1)
+		}
+		msg.uint(32, uint64(r.Tunnel))
+		msg.string(33, r.TraceID)
+
+		var size protoBuffer
+		size.varint(uint64(len(msg)))
+		if _, err := w.Write(size); err != nil {
+			return err
+		}
+
+		_, err := w.Write(msg)
+		return err
+	}
+}
+
+// NewProtobufDecoder returns a Decoder of Results from a stream of size
+// delimited Protocol Buffers messages, as defined in result.proto.
+func NewProtobufDecoder(r io.Reader) Decoder {
+	rd := bufio.NewReader(r)
+	var msg []byte
+	return func(r *Result) error {
+		n, err := binary.ReadUvarint(rd)
+		if err != nil {
+			if err == io.EOF {
+				return err
+			}
+			return fmt.Errorf("protobuf: %v", err)
+		} else if n > maxProtobufSize {
+			return fmt.Errorf("protobuf: message size %d too large", n)
+		}
+
+		if uint64(cap(msg)) < n {
+			msg = make([]byte, n)
+		}
+
+		msg = msg[:n]
+		if _, err = io.ReadFull(rd, msg); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+
+		*r = Result{}
+		return decodeProtobufResult(msg, r)
+	}
+}
+
+var errProtobuf = errors.New("protobuf: malformed Result message")
+
+func decodeProtobufResult(msg protoBuffer, r *Result) error {
+	for len(msg) > 0 {
+		field, typ, err := msg.readKey()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case (field >= 2 && field <= 7 || field == 13 || field == 22 || field == 24 || field == 25 || field >= 27 && field <= 32) && typ != protoVarint,
+			(field == 1 || field >= 8 && field <= 12 || field >= 14 && field <= 21 || field == 23 || field == 26 || field == 33) && typ != protoBytes:
+			return errProtobuf
+		}
+
+		var (
+			u uint64
+			b []byte
+		)
+
+		switch typ {
+		case protoVarint:
+			u, err = msg.readVarint()
+		case protoBytes:
+			b, err = msg.readBytes()
+		default:
+			err = msg.skip(typ)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			r.Attack = string(b)
+		case 2:
+			r.Seq = u
+		case 3:
+			r.Code = uint16(u)
+		case 4:
+			r.Timestamp = time.Unix(0, int64(u))
+		case 5:
+			r.Latency = time.Duration(u)
+		case 6:
+			r.BytesOut = u
+		case 7:
+			r.BytesIn = u
+		case 8:
+			r.Error = string(b)
+		case 9:
+			r.Body = append([]byte(nil), b...)
+		case 10:
+			r.Method = string(b)
+		case 11:
+			r.URL = string(b)
+		case 12:
+			if err = decodeProtobufHeader(b, &r.Headers); err != nil {
+				return err
+			}
+		case 13:
+			r.Weight = u
+		case 14:
+			r.RequestBody = append([]byte(nil), b...)
+		case 15:
+			if err = decodeProtobufHeader(b, &r.RequestHeaders); err != nil {
+				return err
+			}
+		case 16:
+			if err = decodeProtobufLabel(b, &r.Labels); err != nil {
+				return err
+			}
+		case 17:
+			r.RemoteAddr = string(b)
+		case 18:
+			r.LocalAddr = string(b)
+		case 19:
+			r.TLSVersion = string(b)
+		case 20:
+			r.TLSCipherSuite = string(b)
+		case 21:
+			r.TLSProtocol = string(b)
+		case 22:
+			r.TLSResumed = u != 0
+		case 23:
+			if r.Metadata, err = decodeProtobufMetadata(b); err != nil {
+				return err
+			}
+		case 24:
+			r.Worker = u
+		case 25:
+			r.ConnWait = time.Duration(u)
+		case 26:
+			r.IPFamily = string(b)
+		case 27:
+			r.Negotiation = time.Duration(u)
+		case 28:
+			r.Attempt = u
+		case 29:
+			r.Retried = u != 0
+		case 30:
+			r.RetryDelay = time.Duration(u)
+		case 31:
+			r.Continued = u != 0
+		case 32:
+			r.Tunnel = time.Duration(u)
+		case 33:
+			r.TraceID = string(b)
+		}
+	}
+
+	if r.Timestamp.IsZero() {
+		r.Timestamp = time.Unix(0, 0)
+	}
+
+	return nil
+}
+
+func decodeProtobufHeader(msg protoBuffer, h *http.Header) error {
+	var (
+		name   string
+		values []string
+	)
+
+	for len(msg) > 0 {
+		field, typ, err := msg.readKey()
+		if err != nil {
+			return err
+		}
+
+		if typ != protoBytes {
+			if err = msg.skip(typ); err != nil {
+				return err
+			}
+			continue
+		}
+
+		b, err := msg.readBytes()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			name = string(b)
+		case 2:
+			values = append(values, string(b))
+		}
+	}
+
+	if *h == nil {
+		*h = http.Header{}
+	}
+
+	(*h)[name] = append((*h)[name], values...)
+
+	return nil
+}
+
+// decodeProtobufLabel decodes a map entry of labels.
+func decodeProtobufLabel(msg protoBuffer, labels *map[string]string) error {
+	var key, value string
+	for len(msg) > 0 {
+		field, typ, err := msg.readKey()
+		if err != nil {
+			return err
+		}
+
+		if typ != protoBytes {
+			if err = msg.skip(typ); err != nil {
+				return err
+			}
+			continue
+		}
+
+		b, err := msg.readBytes()
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case 1:
+			key = string(b)
+		case 2:
+			value = string(b)
+		}
+	}
+
+	if *labels == nil {
+		*labels = map[string]string{}
+	}
+
+	(*labels)[key] = value
+
+	return nil
+}
+
+func decodeProtobufMetadata(msg protoBuffer) (*Metadata, error) {
+	var m Metadata
+	for len(msg) > 0 {
+		field, typ, err := msg.readKey()
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			u uint64
+			b []byte
+		)
+
+		switch typ {
+		case protoVarint:
+			u, err = msg.readVarint()
+		case protoBytes:
+			b, err = msg.readBytes()
+		default:
+			err = msg.skip(typ)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch field {
+		case 1:
+			m.Attack = string(b)
+		case 2:
+			m.Rate = string(b)
+		case 3:
+			m.Duration = time.Duration(u)
+		case 4:
+			m.Targets = string(b)
+		case 5:
+			m.Version = string(b)
+		case 6:
+			m.Args = append(m.Args, string(b))
+		case 7:
+			m.Began = time.Unix(0, int64(u))
+		case 8:
+			if m.Annotation, err = decodeProtobufAnnotation(b); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &m, nil
+}
+
+func decodeProtobufAnnotation(msg protoBuffer) (*Annotation, error) {
+	var a Annotation
+	for len(msg) > 0 {
+		field, typ, err := msg.readKey()
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			u uint64
+			b []byte
+		)
+
+		switch typ {
+		case protoVarint:
+			u, err = msg.readVarint()
+		case protoBytes:
+			b, err = msg.readBytes()
+		default:
+			err = msg.skip(typ)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch field {
+		case 1:
+			a.Event = string(b)
+		case 2:
+			a.Time = time.Unix(0, int64(u))
+		case 3:
+			if a.Host, err = decodeProtobufHostUsage(b); err != nil {
+				return nil, err
+			}
+		case 4:
+			a.Source = string(b)
+		}
+	}
+
+	return &a, nil
+}
+
+func decodeProtobufHostUsage(msg protoBuffer) (*HostUsage, error) {
+	var h HostUsage
+	for len(msg) > 0 {
+		field, typ, err := msg.readKey()
+		if err != nil {
+			return nil, err
+		}
+
+		var u uint64
+		switch typ {
+		case protoVarint:
+			u, err = msg.readVarint()
+		case protoFixed64:
+			u, err = msg.readFixed64()
+		case protoBytes:
+			_, err = msg.readBytes()
+		default:
+			err = msg.skip(typ)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch field {
+		case 1:
+			h.CPU = math.Float64frombits(u)
+		case 2:
+			h.Memory = math.Float64frombits(u)
+		case 3:
+			h.RSS = u
+		case 4:
+			h.FDs = u
+		case 5:
+			h.FDLimit = u
+		case 6:
+			h.NetIn = u
+		case 7:
+			h.NetOut = u
+		case 8:
+			h.Connections = u
+		}
+	}
+
+	return &h, nil
+}
+
+// protoBuffer encodes and decodes the Protocol Buffers wire format.
+type protoBuffer []byte
+
+func (b *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		*b = append(*b, byte(v)|0x80)
+		v >>= 7
+	}
+	*b = append(*b, byte(v))
+}
+
+// header writes the given header as repeated Header message fields, sorted
+// by name, using buf to encode each one.
+func (b *protoBuffer) header(field int, h http.Header, buf *protoBuffer) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		*buf = (*buf)[:0]
+		buf.string(1, name)
+		for _, v := range h[name] {
+			buf.key(2, protoBytes)
+			buf.bytes(v)
+		}
+		b.key(field, protoBytes)
+		b.bytes(string(*buf))
+	}
+}
+
+// labels writes the given labels as map entry fields, sorted by key, using
+// buf to encode each one.
+func (b *protoBuffer) labels(field int, labels map[string]string, buf *protoBuffer) {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		*buf = (*buf)[:0]
+		buf.string(1, k)
+		buf.string(2, labels[k])
+		b.key(field, protoBytes)
+		b.bytes(string(*buf))
+	}
+}
+
+// metadata writes the given Metadata as a Metadata message field, using buf
+// to encode it.
+func (b *protoBuffer) metadata(field int, m *Metadata, buf *protoBuffer) {
+	*buf = (*buf)[:0]
+	buf.string(1, m.Attack)
+	buf.string(2, m.Rate)
+	buf.uint(3, uint64(m.Duration))
+	buf.string(4, m.Targets)
+	buf.string(5, m.Version)
+	for _, arg := range m.Args {
+		buf.key(6, protoBytes)
+		buf.bytes(arg)
+	}
+	if !m.Began.IsZero() {
+		buf.uint(7, uint64(m.Began.UnixNano()))
+	}
+	if a := m.Annotation; a != nil {
+		var ab protoBuffer
+		ab.string(1, a.Event)
+		if !a.Time.IsZero() {
+			ab.uint(2, uint64(a.Time.UnixNano()))
+		}
+		if h := a.Host; h != nil {
+			var hb protoBuffer
+			hb.double(1, h.CPU)
+			hb.double(2, h.Memory)
+			hb.uint(3, h.RSS)
+			hb.uint(4, h.FDs)
+			hb.uint(5, h.FDLimit)
+			hb.uint(6, h.NetIn)
+			hb.uint(7, h.NetOut)
+			hb.uint(8, h.Connections)
+			ab.key(3, protoBytes)
+			ab.bytes(string(hb))
+		}
+		ab.string(4, a.Source)
+		buf.key(8, protoBytes)
+		buf.bytes(string(ab))
+	}
+	b.key(field, protoBytes)
+	b.bytes(string(*buf))
+}
+
+func (b *protoBuffer) key(field, typ int) {
+	b.varint(uint64(field)<<3 | uint64(typ))
+}
+
+func (b *protoBuffer) bytes(s string) {
+	b.varint(uint64(len(s)))
+	*b = append(*b, s...)
+}
+
+// uint writes a varint field, omitting zero values like proto3 does.
+func (b *protoBuffer) uint(field int, v uint64) {
+	if v != 0 {
+		b.key(field, protoVarint)
+		b.varint(v)
+	}
+}
+
+// double writes a 64-bit field, omitting zero values like proto3 does.
+func (b *protoBuffer) double(field int, v float64) {
+	if v != 0 {
+		b.key(field, protoFixed64)
+		*b = append(*b, make([]byte, 8)...)
+		binary.LittleEndian.PutUint64((*b)[len(*b)-8:], math.Float64bits(v))
+	}
+}
+
+// string writes a length delimited field, omitting empty values like
+// proto3 does.
+func (b *protoBuffer) string(field int, s string) {
+	if s != "" {
+		b.key(field, protoBytes)
+		b.bytes(s)
+	}
+}
+
+func (b *protoBuffer) readVarint() (uint64, error) {
+	v, n := binary.Uvarint(*b)
+	if n <= 0 {
+		return 0, errProtobuf
+	}
+	*b = (*b)[n:]
+	return v, nil
+}
+
+func (b *protoBuffer) readKey() (field, typ int, err error) {
+	v, err := b.readVarint()
+	if err != nil || v>>3 == 0 || v>>3 > 1<<29-1 {
+		return 0, 0, errProtobuf
+	}
+	return int(v >> 3), int(v & 7), nil
+}
+
+func (b *protoBuffer) readFixed64() (uint64, error) {
+	if len(*b) < 8 {
+		return 0, errProtobuf
+	}
+	v := binary.LittleEndian.Uint64(*b)
+	*b = (*b)[8:]
+	return v, nil
+}
+
+func (b *protoBuffer) readBytes() ([]byte, error) {
+	n, err := b.readVarint()
+	if err != nil || n > uint64(len(*b)) {
+		return nil, errProtobuf
+	}
+	v := (*b)[:n]
+	*b = (*b)[n:]
+	return v, nil
+}
+
+// skip skips a field value of a wire type other than varint and bytes.
+func (b *protoBuffer) skip(typ int) error {
+	var n int
+	switch typ {
+	case 1: // 64-bit
+		n = 8
+	case 5: // 32-bit
+		n = 4
+	default: // Groups are deprecated and not supported.
+		return errProtobuf
+	}
+
+	if len(*b) < n {
+		return errProtobuf
+	}
+
+	*b = (*b)[n:]
+	return nil
+}
@@ -1,13 +1,18 @@
 package vegeta
 
 import (
+	"bufio"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
+	"unicode/utf8"
 )
 
 // A Report represents the state a Reporter uses to write out its reports.
@@ -52,63 +57,309 @@ func NewHistogramReporter(h *Histogram) Reporter {
 	}
 }
 
+// histogramBlocks are the unicode blocks used to draw bars with a resolution
+// of an eighth of a character.
+var histogramBlocks = []rune{'▏', '▎', '▍', '▌', '▋', '▊', '▉', '█'}
+
+// NewHistogramBarReporter returns a Reporter that writes out a Histogram as
+// aligned, formatted text with percentage and cumulative percentage columns
+// and unicode block bars scaled so that the largest bucket fills the given
+// width. With color, bars are drawn green up to the 90th percentile, yellow
+// up to the 99th and red beyond it, using ANSI escape codes.
+func NewHistogramBarReporter(h *Histogram, width int, color bool) Reporter {
+	return func(w io.Writer) error {
+		var (
+			los, his = make([]string, len(h.Counts)), make([]string, len(h.Counts))
+			loW, hiW int
+			max      uint64
+		)
+
+		for i, count := range h.Counts {
+			los[i], his[i] = h.Buckets.Nth(i)
+			if n := utf8.RuneCountInString(los[i]); n > loW {
+				loW = n
+			}
+			if n := utf8.RuneCountInString(his[i]); n > hiW {
+				hiW = n
+			}
+			if count > max {
+				max = count
+			}
+		}
+
+		bucketW := loW + hiW + 4
+		if bucketW < len("Bucket") {
+			bucketW = len("Bucket")
+		}
+
+		countW := len(strconv.FormatUint(max, 10))
+		if countW < len("#") {
+			countW = len("#")
+		}
+
+		bw := bufio.NewWriter(w)
+		header := fmt.Sprintf("%-*s  %*s  %7s  %7s  ", bucketW, "Bucket", countW, "#", "%", "Cum %")
+		fmt.Fprintf(bw, "%sHistogram\n", header)
+
+		barW := width - len(header)
+		if barW < 10 {
+			barW = 10
+		}
+
+		var cum uint64
+		for i, count := range h.Counts {
+			var ratio, before, after float64
+			if h.Total > 0 {
+				before = float64(cum) / float64(h.Total)
+				ratio = float64(count) / float64(h.Total)
+				after = float64(cum+count) / float64(h.Total)
+			}
+			cum += count
+
+			bucket := fmt.Sprintf("[%-*s %*s]", loW+1, los[i]+",", hiW, his[i])
+			row := fmt.Sprintf("%-*s  %*d  %6.2f%%  %6.2f%%",
+				bucketW, bucket, countW, count, ratio*100, after*100)
+
+			var eighths int
+			if max > 0 {
+				eighths = int(float64(count) / float64(max) * float64(barW*8))
+			}
+
+			bar := strings.Repeat(string(histogramBlocks[7]), eighths/8)
+			if rem := eighths % 8; rem > 0 {
+				bar += string(histogramBlocks[rem-1])
+			}
+
+			if color && bar != "" {
+				code := "32" // green
+				if before >= 0.99 {
+					code = "31" // red
+				} else if before >= 0.9 {
+					code = "33" // yellow
+				}
+				bar = "\033[" + code + "m" + bar + "\033[0m"
+			}
+
+			if bar != "" {
+				row += "  " + bar
+			}
+
+			fmt.Fprintln(bw, row)
+		}
+
+		return bw.Flush()
+	}
+}
+
 // NewTextReporter returns a Reporter that writes out Metrics as aligned,
 // formatted text.
 func NewTextReporter(m *Metrics) Reporter {
-	const fmtstr = "Requests\t[total, rate, throughput]\t%d, %.2f, %.2f\n" +
-		"Duration\t[total, attack, wait]\t%s, %s, %s\n" +
-		"Latencies\t[min, mean, 50, 90, 95, 99, max]\t%s, %s, %s, %s, %s, %s, %s\n" +
-		"Bytes In\t[total, mean]\t%d, %.2f\n" +
-		"Bytes Out\t[total, mean]\t%d, %.2f\n" +
-		"Success\t[ratio]\t%.2f%%\n" +
-		"Status Codes\t[code:count]\t"
+	rep, _ := NewCustomTextReporter(m, TextOptions{})
+	return rep
+}
+
+// TextColumns are the columns of the text report, in their default order.
+var TextColumns = []string{
+	"requests",
+	"duration",
+	"latencies",
+	"bytes_in",
+	"bytes_out",
+	"success",
+	"status_codes",
+	"errors",
+}
+
+// TextOptions customizes the text report written by NewCustomTextReporter.
+type TextOptions struct {
+	// Columns are the TextColumns to write out, in order. All of them are
+	// written out when empty.
+	Columns []string
+	// Color enables coloring with ANSI escape codes: errors, error status
+	// codes and success ratios below 100% in red, and latencies degraded
+	// relative to the Baseline in yellow.
+	Color bool
+	// Baseline holds the Metrics which latencies are compared against
+	// to color degraded ones.
+	Baseline *Metrics
+	// Tolerance is the relative latency increase over the Baseline above
+	// which a latency is considered degraded, e.g. 0.1 for 10%.
+	Tolerance float64
+	// Units are the units durations and byte sizes are formatted in.
+	Units Units
+}
+
+// NewCustomTextReporter returns a Reporter that writes out the given columns
+// of Metrics as aligned, formatted text, optionally colored. It returns an
+// error if any of the columns isn't one of TextColumns.
+func NewCustomTextReporter(m *Metrics, opts TextOptions) (Reporter, error) {
+	columns := opts.Columns
+	if len(columns) == 0 {
+		columns = TextColumns
+	}
+
+	for _, c := range columns {
+		if !containsString(TextColumns, c) {
+			return nil, fmt.Errorf("unknown text report column %q, want one of %s",
+				c, strings.Join(TextColumns, ", "))
+		}
+	}
+
+	color := func(code, s string, ok bool) string {
+		if !opts.Color || !ok {
+			return s
+		}
+		return "\033[" + code + "m" + s + "\033[0m"
+	}
+
+	red := func(s string, ok bool) string { return color("31", s, ok) }
+
+	latency := func(d time.Duration, base func(*LatencyMetrics) time.Duration) string {
+		degraded := opts.Baseline != nil &&
+			float64(d) > float64(base(&opts.Baseline.Latencies))*(1+opts.Tolerance)
+		return color("33", opts.Units.duration(d), degraded)
+	}
 
 	return func(w io.Writer) (err error) {
 		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.StripEscape)
-		if _, err = fmt.Fprintf(tw, fmtstr,
-			m.Requests, m.Rate, m.Throughput,
-			round(m.Duration+m.Wait),
-			round(m.Duration),
-			round(m.Wait),
-			round(m.Latencies.Min),
-			round(m.Latencies.Mean),
-			round(m.Latencies.P50),
-			round(m.Latencies.P90),
-			round(m.Latencies.P95),
-			round(m.Latencies.P99),
-			round(m.Latencies.Max),
-			m.BytesIn.Total, m.BytesIn.Mean,
-			m.BytesOut.Total, m.BytesOut.Mean,
-			m.Success*100,
-		); err != nil {
-			return err
+		for _, c := range columns {
+			switch c {
+			case "requests":
+				_, err = fmt.Fprintf(tw, "Requests\t[total, rate, throughput]\t%d, %.2f, %.2f\n",
+					m.Requests, m.Rate, m.Throughput)
+			case "duration":
+				_, err = fmt.Fprintf(tw, "Duration\t[total, attack, wait]\t%s, %s, %s\n",
+					opts.Units.duration(m.Duration+m.Wait), opts.Units.duration(m.Duration), opts.Units.duration(m.Wait))
+			case "latencies":
+				_, err = fmt.Fprintf(tw, "Latencies\t[min, mean, 50, 90, 95, 99, max]\t%s, %s, %s, %s, %s, %s, %s\n",
+					latency(m.Latencies.Min, func(l *LatencyMetrics) time.Duration { return l.Min }),
+					latency(m.Latencies.Mean, func(l *LatencyMetrics) time.Duration { return l.Mean }),
+					latency(m.Latencies.P50, func(l *LatencyMetrics) time.Duration { return l.P50 }),
+					latency(m.Latencies.P90, func(l *LatencyMetrics) time.Duration { return l.P90 }),
+					latency(m.Latencies.P95, func(l *LatencyMetrics) time.Duration { return l.P95 }),
+					latency(m.Latencies.P99, func(l *LatencyMetrics) time.Duration { return l.P99 }),
+					latency(m.Latencies.Max, func(l *LatencyMetrics) time.Duration { return l.Max }),
+				)
+			case "bytes_in":
+				_, err = fmt.Fprintf(tw, "Bytes In\t[total, mean]\t%s, %s\n",
+					opts.Units.bytes(float64(m.BytesIn.Total)), opts.Units.bytes(m.BytesIn.Mean))
+			case "bytes_out":
+				_, err = fmt.Fprintf(tw, "Bytes Out\t[total, mean]\t%s, %s\n",
+					opts.Units.bytes(float64(m.BytesOut.Total)), opts.Units.bytes(m.BytesOut.Mean))
+			case "success":
+				_, err = fmt.Fprintf(tw, "Success\t[ratio]\t%s\n",
+					red(fmt.Sprintf("%.2f%%", m.Success*100), m.Success < 1))
+			case "status_codes":
+				codes := make([]string, 0, len(m.StatusCodes))
+				for code := range m.StatusCodes {
+					codes = append(codes, code)
+				}
+
+				sort.Strings(codes)
+
+				line := "Status Codes\t[code:count]\t"
+				for _, code := range codes {
+					bad := code < "200" || code >= "400"
+					line += red(fmt.Sprintf("%s:%d", code, m.StatusCodes[code]), bad) + "  "
+				}
+
+				_, err = fmt.Fprintln(tw, line)
+			case "errors":
+				if _, err = fmt.Fprintln(tw, "Error Set:"); err != nil {
+					return err
+				}
+
+				for _, e := range m.Errors {
+					if _, err = fmt.Fprintln(tw, red(e, true)); err != nil {
+						return err
+					}
+				}
+			}
+
+			if err != nil {
+				return err
+			}
 		}
 
-		codes := make([]string, 0, len(m.StatusCodes))
-		for code := range m.StatusCodes {
-			codes = append(codes, code)
+		return tw.Flush()
+	}, nil
+}
+
+func containsString(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
 		}
+	}
+	return false
+}
 
-		sort.Strings(codes)
+// NewCSVReporter returns a Reporter that writes out Metrics as CSV with a
+// row per metric and the columns timestamp, metric, value and unit, where
+// the timestamp is the end of the attack. The header row is only written
+// on the first report so that periodic reports written to the same
+// output form a single time series table.
+func NewCSVReporter(m *Metrics) Reporter {
+	header := true
+	return func(w io.Writer) error {
+		cw := csv.NewWriter(w)
+		if header {
+			if err := cw.Write([]string{"timestamp", "metric", "value", "unit"}); err != nil {
+				return err
+			}
+			header = false
+		}
 
-		for _, code := range codes {
-			count := m.StatusCodes[code]
-			if _, err = fmt.Fprintf(tw, "%s:%d  ", code, count); err != nil {
+		ts := m.End.UTC().Format(time.RFC3339Nano)
+		row := func(metric, value, unit string) error {
+			return cw.Write([]string{ts, metric, value, unit})
+		}
+
+		u := func(v uint64) string { return strconv.FormatUint(v, 10) }
+		f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+		d := func(v time.Duration) string { return strconv.FormatInt(int64(v), 10) }
+
+		rows := [][3]string{
+			{"requests", u(m.Requests), "count"},
+			{"rate", f(m.Rate), "1/s"},
+			{"throughput", f(m.Throughput), "1/s"},
+			{"success", f(m.Success), "ratio"},
+			{"duration", d(m.Duration), "ns"},
+			{"wait", d(m.Wait), "ns"},
+			{"latency_min", d(m.Latencies.Min), "ns"},
+			{"latency_mean", d(m.Latencies.Mean), "ns"},
+			{"latency_50", d(m.Latencies.P50), "ns"},
+			{"latency_90", d(m.Latencies.P90), "ns"},
+			{"latency_95", d(m.Latencies.P95), "ns"},
+			{"latency_99", d(m.Latencies.P99), "ns"},
+			{"latency_max", d(m.Latencies.Max), "ns"},
+			{"bytes_in_total", u(m.BytesIn.Total), "bytes"},
+			{"bytes_in_mean", f(m.BytesIn.Mean), "bytes"},
+			{"bytes_out_total", u(m.BytesOut.Total), "bytes"},
+			{"bytes_out_mean", f(m.BytesOut.Mean), "bytes"},
+		}
+
+		for _, r := range rows {
+			if err := row(r[0], r[1], r[2]); err != nil {
 				return err
 			}
 		}
 
-		if _, err = fmt.Fprintln(tw, "\nError Set:"); err != nil {
-			return err
+		codes := make([]string, 0, len(m.StatusCodes))
+		for code := range m.StatusCodes {
+			codes = append(codes, code)
 		}
 
-		for _, e := range m.Errors {
-			if _, err = fmt.Fprintln(tw, e); err != nil {
+		sort.Strings(codes)
+
+		for _, code := range codes {
+			if err := row("status_code_"+code, strconv.Itoa(m.StatusCodes[code]), "count"); err != nil {
 				return err
 			}
 		}
 
-		return tw.Flush()
+		cw.Flush()
+		return cw.Error()
 	}
 }
 
@@ -260,6 +511,41 @@ func NewHDRHistogramPlotReporter(m *Metrics) Reporter {
 	}
 }
 
+// NewHGRMReporter returns a Reporter that writes out the latency distribution
+// in the classic HdrHistogram percentile distribution (.hgrm) format, with
+// values in milliseconds, as output by wrk2 and other HdrHistogram based tools.
+func NewHGRMReporter(m *Metrics) Reporter {
+	return func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		fmt.Fprintf(bw, "%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)")
+
+		// Iterate percentiles like HdrHistogram does, with 5 ticks per half
+		// distance to 100%: 0, 10, ..., 50, 55, ..., 75, 77.5, ...
+		total := float64(m.Requests)
+		for p := 0.0; m.Requests > 0; {
+			q := p / 100
+			count := uint64(q*total + 0.5)
+			if q >= 1 || count >= m.Requests {
+				fmt.Fprintf(bw, "%12.3f %2.12f %10d\n", milliseconds(m.Latencies.Max), 1.0, m.Requests)
+				break
+			}
+
+			fmt.Fprintf(bw, "%12.3f %2.12f %10d %14.2f\n",
+				milliseconds(m.Latencies.Quantile(q)), q, count, 1/(1-q))
+
+			half := math.Pow(2, math.Floor(math.Log2(100/(100-p)))+1)
+			p += 100 / (5 * half)
+		}
+
+		fmt.Fprintf(bw, "#[Mean    = %12.3f, StdDeviation   = %12.3f]\n",
+			milliseconds(m.Latencies.Mean), milliseconds(m.Latencies.StdDev()))
+		fmt.Fprintf(bw, "#[Max     = %12.3f, Total count    = %12d]\n",
+			milliseconds(m.Latencies.Max), m.Requests)
+
+		return bw.Flush()
+	}
+}
+
 // milliseconds converts the given duration to a number of
 // fractional milliseconds. Splitting the integer and fraction
 // ourselves guarantees that converting the returned float64 to an
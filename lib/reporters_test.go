@@ -0,0 +1,225 @@
+package vegeta
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVReporter(t *testing.T) {
+	t.Parallel()
+
+	var m Metrics
+	for i := 1; i <= 4; i++ {
+		m.Add(&Result{
+			Code:      200,
+			Timestamp: time.Unix(int64(i), 0),
+			Latency:   time.Second,
+			BytesIn:   10,
+		})
+	}
+	m.Close()
+
+	var buf bytes.Buffer
+	rep := NewCSVReporter(&m)
+	for i := 0; i < 2; i++ {
+		if err := rep.Report(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := rows[0], []string{"timestamp", "metric", "value", "unit"}; !equalStrings(got, want) {
+		t.Fatalf("got header %v, want %v", got, want)
+	}
+
+	// The header row is only written once.
+	if got, want := len(rows), 1+2*18; got != want {
+		t.Fatalf("got %d rows, want %d", got, want)
+	}
+
+	values := map[string]string{}
+	for _, row := range rows[1:] {
+		if got, want := row[0], "1970-01-01T00:00:05Z"; got != want {
+			t.Errorf("got timestamp %q, want %q", got, want)
+		}
+		values[row[1]] = row[2]
+	}
+
+	for metric, want := range map[string]string{
+		"requests":        "4",
+		"success":         "1",
+		"latency_max":     "1000000000",
+		"bytes_in_total":  "40",
+		"status_code_200": "4",
+	} {
+		if got := values[metric]; got != want {
+			t.Errorf("got %s %q, want %q", metric, got, want)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHGRMReporter(t *testing.T) {
+	t.Parallel()
+
+	var m Metrics
+	for i := 1; i <= 1000; i++ {
+		m.Add(&Result{
+			Code:      200,
+			Timestamp: time.Unix(int64(i), 0),
+			Latency:   time.Duration(i) * time.Millisecond,
+		})
+	}
+	m.Close()
+
+	var buf bytes.Buffer
+	if err := NewHGRMReporter(&m).Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if got, want := lines[0], "       Value     Percentile TotalCount 1/(1-Percentile)"; got != want {
+		t.Errorf("got header %q, want %q", got, want)
+	}
+
+	var percentiles []string
+	for _, line := range lines[2:] {
+		if strings.HasPrefix(line, "#") {
+			break
+		}
+		percentiles = append(percentiles, strings.Fields(line)[1])
+	}
+
+	// HdrHistogram's percentile iteration with 5 ticks per half distance.
+	for i, want := range []string{
+		"0.000000000000", "0.100000000000", "0.200000000000", "0.300000000000",
+		"0.400000000000", "0.500000000000", "0.550000000000", "0.600000000000",
+		"0.650000000000", "0.700000000000", "0.750000000000", "0.775000000000",
+	} {
+		if percentiles[i] != want {
+			t.Errorf("got percentile %d = %s, want %s", i, percentiles[i], want)
+		}
+	}
+
+	if got, want := lines[len(lines)-3], "1000.000 1.000000000000       1000"; !strings.HasSuffix(got, want) {
+		t.Errorf("got last percentile line %q, want it to end with %q", got, want)
+	}
+
+	if got, want := lines[len(lines)-2], "#[Mean    =      500.500, StdDeviation   =      288.675]"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got, want := lines[len(lines)-1], "#[Max     =     1000.000, Total count    =         1000]"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestHistogramBarReporter(t *testing.T) {
+	t.Parallel()
+
+	h := Histogram{Buckets: Buckets{0, 2 * time.Millisecond, 10 * time.Millisecond}}
+	for _, b := range []struct {
+		latency time.Duration
+		count   int
+	}{
+		{time.Millisecond, 90},
+		{3 * time.Millisecond, 9},
+		{20 * time.Millisecond, 1},
+	} {
+		for i := 0; i < b.count; i++ {
+			h.Add(&Result{Latency: b.latency})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := NewHistogramBarReporter(&h, 60, false).Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"Bucket         #        %    Cum %  Histogram",
+		"[0s,    2ms]  90   90.00%   90.00%  ████████████████████████",
+		"[2ms,  10ms]   9    9.00%   99.00%  ██▍",
+		"[10ms, +Inf]   1    1.00%  100.00%  ▎",
+		"",
+	}, "\n")
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := NewHistogramBarReporter(&h, 60, true).Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+	for i, code := range []string{"32", "33", "31"} {
+		if want := "\033[" + code + "m"; !strings.Contains(lines[i+1], want) {
+			t.Errorf("got line %q, want it colored with %q", lines[i+1], want)
+		}
+	}
+}
+
+func TestCustomTextReporter(t *testing.T) {
+	t.Parallel()
+
+	var m, baseline Metrics
+	for i := 0; i < 4; i++ {
+		baseline.Add(&Result{Code: 200, Timestamp: time.Unix(int64(i), 0), Latency: 10 * time.Millisecond})
+		m.Add(&Result{Code: 200, Timestamp: time.Unix(int64(i), 0), Latency: 10 * time.Millisecond})
+	}
+	m.Add(&Result{Code: 500, Timestamp: time.Unix(4, 0), Latency: time.Second, Error: "boom"})
+	baseline.Close()
+	m.Close()
+
+	rep, err := NewCustomTextReporter(&m, TextOptions{
+		Columns:   []string{"success", "latencies", "status_codes", "errors"},
+		Color:     true,
+		Baseline:  &baseline,
+		Tolerance: 0.1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = rep.Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"Success       [ratio]                           \033[31m80.00%\033[0m",
+		"Latencies     [min, mean, 50, 90, 95, 99, max]  10ms, \033[33m208ms\033[0m, 10ms, \033[33m1s\033[0m, \033[33m1s\033[0m, \033[33m1s\033[0m, \033[33m1s\033[0m",
+		"Status Codes  [code:count]                      200:4  \033[31m500:1\033[0m  ",
+		"Error Set:",
+		"\033[31mboom\033[0m",
+		"",
+	}, "\n")
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+
+	if _, err = NewCustomTextReporter(&m, TextOptions{Columns: []string{"nope"}}); err == nil {
+		t.Error("got no error for an unknown column")
+	}
+}
@@ -0,0 +1,87 @@
+package vegeta
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// hostCache caches the IP addresses of the hosts an Attacker dials for an
+// interval after which it resolves them again, and hands them out in turn so
+// that new connections are spread across all of them.
+type hostCache struct {
+	interval time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*cachedHost
+}
+
+// cachedHost holds the resolved addresses of a host.
+type cachedHost struct {
+	addrs    []string
+	resolved time.Time
+	next     int
+}
+
+func newHostCache(interval time.Duration) *hostCache {
+	return &hostCache{interval: interval, hosts: map[string]*cachedHost{}}
+}
+
+// lookup returns the addresses of the given host with the given resolver,
+// rotated so that they start with the next one in turn, resolving them again
+// if the interval of the cached ones elapsed. It returns true if they changed
+// since they were last resolved. Failed lookups return the cached addresses,
+// if any.
+func (c *hostCache) lookup(ctx context.Context, r *net.Resolver, host string) (addrs []string, changed bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	h, ok := c.hosts[host]
+	if !ok || time.Since(h.resolved) >= c.interval {
+		if r == nil {
+			r = net.DefaultResolver
+		}
+
+		resolved, err := r.LookupHost(ctx, host)
+		switch {
+		case err != nil && !ok:
+			return nil, false, err
+		case err == nil && !ok:
+			h = &cachedHost{addrs: resolved, resolved: time.Now()}
+			c.hosts[host] = h
+		case err == nil:
+			changed = !sameAddrs(h.addrs, resolved)
+			h.addrs, h.resolved = resolved, time.Now()
+		}
+	}
+
+	n := len(h.addrs)
+	addrs = make([]string, n)
+	for i := range addrs {
+		addrs[i] = h.addrs[(h.next+i)%n]
+	}
+	h.next = (h.next + 1) % n
+
+	return addrs, changed, nil
+}
+
+// sameAddrs returns true if both lists have the same addresses, in any order.
+func sameAddrs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	set := make(map[string]bool, len(a))
+	for _, addr := range a {
+		set[addr] = true
+	}
+
+	for _, addr := range b {
+		if !set[addr] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,82 @@
+package vegeta
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHostCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := newHostCache(time.Hour)
+
+	addrs, changed, err := c.lookup(ctx, nil, "localhost")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(addrs) == 0 || changed {
+		t.Fatalf("got addresses %v, changed %t, want some unchanged ones", addrs, changed)
+	}
+
+	// Cached addresses are handed out in turn.
+	c.hosts["localhost"].addrs = []string{"10.0.0.1", "10.0.0.2"}
+	c.hosts["localhost"].next = 0
+	for _, want := range [][]string{{"10.0.0.1", "10.0.0.2"}, {"10.0.0.2", "10.0.0.1"}} {
+		if addrs, _, _ = c.lookup(ctx, nil, "localhost"); !reflect.DeepEqual(addrs, want) {
+			t.Errorf("got addresses %v, want %v", addrs, want)
+		}
+	}
+
+	// Failed lookups keep the cached addresses.
+	failing := &net.Resolver{
+		PreferGo: true,
+		Dial: func(context.Context, string, string) (net.Conn, error) {
+			return nil, errors.New("unreachable")
+		},
+	}
+
+	c.hosts["vegeta.test"] = &cachedHost{addrs: []string{"10.0.0.3"}}
+	if addrs, changed, err = c.lookup(ctx, failing, "vegeta.test"); err != nil || changed || len(addrs) != 1 {
+		t.Errorf("got addresses %v, changed %t, error %v, want the cached ones", addrs, changed, err)
+	}
+
+	if _, _, err = c.lookup(ctx, failing, "vegeta.invalid"); err == nil {
+		t.Error("got no error looking up an unknown host")
+	}
+
+	// Addresses are resolved again once the interval elapses.
+	c.hosts["localhost"].resolved = time.Time{}
+	if addrs, changed, err = c.lookup(ctx, nil, "localhost"); err != nil || !changed {
+		t.Errorf("got addresses %v, changed %t, error %v, want changed ones", addrs, changed, err)
+	}
+}
+
+func TestDNSRefresh(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// localhost may resolve to ::1 too, which the server doesn't listen on,
+	// in which case the next address is dialed.
+	atk := NewAttacker(DNSRefresh(time.Minute), RecordConnections(true))
+	tr := NewStaticTargeter(Target{Method: "GET", URL: "http://localhost:" + port})
+	for i := 0; i < 3; i++ {
+		if res := atk.hit(tr, "", 1); res.Error != "" {
+			t.Fatal(res.Error)
+		} else if got, want := res.RemoteAddr, server.Listener.Addr().String(); got != want {
+			t.Errorf("got remote address %q, want %q", got, want)
+		}
+	}
+}
@@ -6,9 +6,13 @@ import (
 	"encoding/base64"
 	"encoding/csv"
 	"encoding/gob"
+	"fmt"
 	"io"
+	"math"
+	"math/rand"
 	"net/http"
 	"net/textproto"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -36,6 +40,82 @@ type Result struct {
 	Method    string        `json:"method"`
 	URL       string        `json:"url"`
 	Headers   http.Header   `json:"headers"`
+
+	// Weight is the number of Results a sampled Result stands for, or zero
+	// if it wasn't sampled. See NewSamplingEncoder.
+	Weight uint64 `json:"weight,omitempty"`
+
+	// RequestBody and RequestHeaders are the body and headers of the request
+	// as it was sent, if captured. See CaptureRequest.
+	RequestBody    []byte      `json:"request_body,omitempty"`
+	RequestHeaders http.Header `json:"request_headers,omitempty"`
+
+	// Labels are the key value pairs an attack labeled its Results with,
+	// e.g. a build ID or region. See Labels.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// RemoteAddr and LocalAddr are the addresses of the connection the
+	// request was sent on, if recorded, which together identify it. See
+	// RecordConnections.
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	LocalAddr  string `json:"local_addr,omitempty"`
+
+	// TLSVersion, TLSCipherSuite, TLSProtocol and TLSResumed are the version,
+	// e.g. "TLS 1.3", cipher suite, ALPN negotiated protocol, e.g. "h2", and
+	// whether the session was resumed, of the TLS connection the response was
+	// received on, if recorded. See RecordTLS.
+	TLSVersion     string `json:"tls_version,omitempty"`
+	TLSCipherSuite string `json:"tls_cipher_suite,omitempty"`
+	TLSProtocol    string `json:"tls_protocol,omitempty"`
+	TLSResumed     bool   `json:"tls_resumed,omitempty"`
+
+	// Worker identifies the attacker worker which sent the request, from 1 up
+	// to the number of workers of the attack, or is zero if unknown.
+	Worker uint64 `json:"worker,omitempty"`
+
+	// ConnWait is how long the request waited for a connection from the pool
+	// of its target host, not counting the time spent dialing a new one, if
+	// recorded. It grows when the pool is exhausted, e.g. when it's limited
+	// with MaxConnections. See RecordConnections.
+	ConnWait time.Duration `json:"conn_wait,omitempty"`
+
+	// IPFamily is the IP family, "ipv4" or "ipv6", of the connection the
+	// request was sent on, if recorded, which dual-stack hosts may serve
+	// either of. See RecordConnections and IPVersion.
+	IPFamily string `json:"ip_family,omitempty"`
+
+	// Negotiation is how long authenticating the connection of the request
+	// took, e.g. with NTLM, if it had to be. It's part of the Latency unless
+	// excluded with ExcludeNegotiation. See NTLM.
+	Negotiation time.Duration `json:"negotiation,omitempty"`
+
+	// Attempt is the number of the attempt at the request which the Result
+	// is of, from zero for the first up to the number of retries, which share
+	// its Seq. Retried is whether the attempt was retried, so that it isn't
+	// the outcome of the request, and RetryDelay is how long after the first
+	// attempt it began, spent on the earlier attempts and backing off between
+	// them. See Retries and RequestOutcome.
+	Attempt    uint64        `json:"attempt,omitempty"`
+	Retried    bool          `json:"retried,omitempty"`
+	RetryDelay time.Duration `json:"retry_delay,omitempty"`
+
+	// Continued is whether the interim 100 Continue response to a request
+	// sent with Expect: 100-continue arrived. See ExpectContinue.
+	Continued bool `json:"continued,omitempty"`
+
+	// Tunnel is how long establishing the tunnel to the target host through
+	// the proxy of the request, with CONNECT, took, if recorded, which isn't
+	// counted in its ConnWait. See RecordConnections and Proxy.
+	Tunnel time.Duration `json:"tunnel,omitempty"`
+
+	// TraceID is the W3C trace ID of the traceparent header the request was
+	// sent with, if any, as 32 lower case hex digits, by which it can be
+	// looked up in the tracing backend of the target. See TraceParent.
+	TraceID string `json:"trace_id,omitempty"`
+
+	// Metadata is only set in the metadata records of result streams, which
+	// describe the attacks that wrote them. See NewMetadataDecoder.
+	Metadata *Metadata `json:"metadata,omitempty"`
 }
 
 // End returns the time at which a Result ended.
@@ -54,7 +134,36 @@ func (r Result) Equal(other Result) bool {
 		bytes.Equal(r.Body, other.Body) &&
 		r.Method == other.Method &&
 		r.URL == other.URL &&
-		headerEqual(r.Headers, other.Headers)
+		headerEqual(r.Headers, other.Headers) &&
+		r.Weight == other.Weight &&
+		bytes.Equal(r.RequestBody, other.RequestBody) &&
+		headerEqual(r.RequestHeaders, other.RequestHeaders) &&
+		labelsEqual(r.Labels, other.Labels) &&
+		r.RemoteAddr == other.RemoteAddr &&
+		r.LocalAddr == other.LocalAddr &&
+		r.TLSVersion == other.TLSVersion &&
+		r.TLSCipherSuite == other.TLSCipherSuite &&
+		r.TLSProtocol == other.TLSProtocol &&
+		r.TLSResumed == other.TLSResumed &&
+		r.Worker == other.Worker &&
+		r.ConnWait == other.ConnWait &&
+		r.IPFamily == other.IPFamily &&
+		r.Negotiation == other.Negotiation &&
+		r.Attempt == other.Attempt &&
+		r.Retried == other.Retried &&
+		r.RetryDelay == other.RetryDelay &&
+		r.Continued == other.Continued &&
+		r.Tunnel == other.Tunnel &&
+		r.TraceID == other.TraceID &&
+		r.Metadata.Equal(other.Metadata)
+}
+
+// weight returns the number of Results the Result stands for.
+func (r *Result) weight() uint64 {
+	if r.Weight == 0 {
+		return 1
+	}
+	return r.Weight
 }
 
 func headerEqual(h1, h2 http.Header) bool {
@@ -79,6 +188,18 @@ func headerEqual(h1, h2 http.Header) bool {
 	return true
 }
 
+func labelsEqual(l1, l2 map[string]string) bool {
+	if len(l1) != len(l2) {
+		return false
+	}
+	for key, v1 := range l1 {
+		if v2, ok := l2[key]; !ok || v1 != v2 {
+			return false
+		}
+	}
+	return true
+}
+
 // Results is a slice of Result type elements.
 type Results []Result
 
@@ -105,11 +226,21 @@ type DecoderFactory func(io.Reader) Decoder
 // the given io.Reader and then returns the corresponding Decoder or nil
 // in case of failing to detect a supported encoding.
 func DecoderFor(r io.Reader) Decoder {
+	// Parquet files are detected by their magic number since the Parquet
+	// Decoder needs to read whole files before decoding the first Result.
+	magic := make([]byte, len(parquetMagic))
+	n, _ := io.ReadFull(r, magic)
+	if r = io.MultiReader(bytes.NewReader(magic[:n]), r); string(magic) == parquetMagic {
+		return NewParquetDecoder(r)
+	}
+
 	var buf bytes.Buffer
 	for _, dec := range []DecoderFactory{
 		NewDecoder,
 		NewJSONDecoder,
 		NewCSVDecoder,
+		NewMsgpackDecoder,
+		NewProtobufDecoder,
 	} {
 		rd := io.MultiReader(bytes.NewReader(buf.Bytes()), io.TeeReader(r, &buf))
 		if err := dec(rd).Decode(&Result{}); err == nil {
@@ -141,10 +272,114 @@ func NewRoundRobinDecoder(dec ...Decoder) Decoder {
 	}
 }
 
-// NewDecoder returns a new gob Decoder for the given io.Reader.
+// NewChainDecoder returns a new Decoder that decodes all Results of each
+// of the given Decoders in turn, moving on to the next one once the
+// current one returns io.EOF.
+func NewChainDecoder(dec ...Decoder) Decoder {
+	return func(r *Result) error {
+		for len(dec) > 0 {
+			err := dec[0].Decode(r)
+			if err != io.EOF {
+				return err
+			}
+			dec = dec[1:]
+		}
+		return io.EOF
+	}
+}
+
+// NewFilterDecoder returns a new Decoder that skips the Results decoded by
+// the given Decoder for which keep returns false.
+func NewFilterDecoder(dec Decoder, keep func(*Result) bool) Decoder {
+	return func(r *Result) error {
+		for {
+			if err := dec.Decode(r); err != nil {
+				return err
+			} else if keep(r) {
+				return nil
+			}
+			*r = Result{} // Decoders may not reset fields absent in the next Result.
+		}
+	}
+}
+
+// NewMapDecoder returns a new Decoder that applies the given function to the
+// Results decoded by the given Decoder.
+func NewMapDecoder(dec Decoder, fn func(*Result)) Decoder {
+	return func(r *Result) error {
+		if err := dec.Decode(r); err != nil {
+			return err
+		}
+		fn(r)
+		return nil
+	}
+}
+
+// GobVersion is the version of the gob encoding of Results written by
+// NewEncoder and NewIndexedEncoder, in a header before the first Result.
+// Gob encoded Results without a header, written by older versions of vegeta,
+// are version 1. Decoders read all versions up to GobVersion and fail to
+// read newer ones, instead of silently dropping the fields they don't know.
+const GobVersion = 2
+
+// gobHeaderMagic starts the version header of gob encoded Results, which is
+// followed by the version as a single byte. Like IndexMagic, its first byte
+// can't start a gob message.
+const gobHeaderMagic = "\x80VGTAVER"
+
+// gobHeader is the header written before gob encoded Results.
+var gobHeader = gobHeaderMagic + string(rune(GobVersion))
+
+// PeekGobVersion returns the version of the gob encoded Results read by br,
+// without consuming its header, or an error if it's newer than GobVersion.
+// Inputs without a header are version 1, whether they're gob encoded or not.
+func PeekGobVersion(br *bufio.Reader) (int, error) {
+	hdr, _ := br.Peek(len(gobHeaderMagic) + 1)
+	if len(hdr) <= len(gobHeaderMagic) || string(hdr[:len(gobHeaderMagic)]) != gobHeaderMagic {
+		return 1, nil
+	}
+
+	switch v := int(hdr[len(gobHeaderMagic)]); {
+	case v > GobVersion:
+		return v, fmt.Errorf("gob: results encoded with version %d, newer than the supported version %d", v, GobVersion)
+	case v < 2:
+		return v, fmt.Errorf("gob: bad version %d", v)
+	default:
+		return v, nil
+	}
+}
+
+// gobHeaderSize returns the size of the version header of gob encoded
+// Results of the given version.
+func gobHeaderSize(version int) int {
+	if version < 2 {
+		return 0
+	}
+	return len(gobHeader)
+}
+
+// NewDecoder returns a new gob Decoder for the given io.Reader. It reads all
+// versions of the encoding up to GobVersion and stops decoding at the index
+// footer written by NewIndexedEncoder.
 func NewDecoder(rd io.Reader) Decoder {
-	dec := gob.NewDecoder(rd)
-	return func(r *Result) error { return dec.Decode(r) }
+	br := bufio.NewReader(rd)
+	dec := gob.NewDecoder(br)
+	versioned := false
+	return func(r *Result) error {
+		if !versioned {
+			v, err := PeekGobVersion(br)
+			if err != nil {
+				return err
+			}
+			br.Discard(gobHeaderSize(v))
+			versioned = true
+		}
+
+		if magic, _ := br.Peek(len(IndexMagic)); string(magic) == IndexMagic {
+			return io.EOF
+		}
+		return dec.Decode(r)
+	}
 }
 
 // Decode is an an adapter method calling the Decoder function itself with the
@@ -154,20 +389,62 @@ func (dec Decoder) Decode(r *Result) error { return dec(r) }
 // An Encoder encodes a Result and returns an error in case of failure.
 type Encoder func(*Result) error
 
-// NewEncoder returns a new Result encoder closure for the given io.Writer
-func NewEncoder(r io.Writer) Encoder {
-	enc := gob.NewEncoder(r)
-	return func(r *Result) error { return enc.Encode(r) }
+// NewEncoder returns a new Result encoder closure for the given io.Writer,
+// which gob encodes Results after a header with their GobVersion.
+func NewEncoder(w io.Writer) Encoder {
+	enc := gob.NewEncoder(w)
+	versioned := false
+	return func(r *Result) error {
+		if !versioned {
+			if _, err := io.WriteString(w, gobHeader); err != nil {
+				return err
+			}
+			versioned = true
+		}
+		return enc.Encode(r)
+	}
 }
 
 // Encode is an an adapter method calling the Encoder function itself with the
 // given parameters.
 func (enc Encoder) Encode(r *Result) error { return enc(r) }
 
+// NewSamplingEncoder returns an Encoder which encodes a random sample of the
+// successful Results with the given Encoder, one in 1/rate on average, and
+// all unsuccessful ones. Sampled Results are encoded with the number of
+// Results they stand for as their Weight, which Metrics count them by.
+func NewSamplingEncoder(enc Encoder, rate float64) Encoder {
+	n := uint64(math.Round(1 / rate))
+	if n <= 1 {
+		return enc
+	}
+
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return func(r *Result) error {
+		if r.Error != "" || r.Code < 200 || r.Code >= 400 {
+			return enc(r)
+		} else if rnd.Int63n(int64(n)) != 0 {
+			return nil
+		}
+
+		sampled := *r
+		sampled.Weight = r.weight() * n
+		return enc(&sampled)
+	}
+}
+
 // NewCSVEncoder returns an Encoder that dumps the given *Result as a CSV
 // record. The columns are: UNIX timestamp in ns since epoch,
 // HTTP status code, request latency in ns, bytes out, bytes in,
-// response body, and lastly the error.
+// error, response body, attack name, sequence number, method, URL,
+// response headers, sampling weight, request body, request headers, labels, as
+// a URL query string, remote and local connection addresses, the TLS version,
+// cipher suite, negotiated protocol, whether the session was resumed, the JSON
+// encoded Metadata of metadata records, the worker, the connection wait in ns,
+// the IP family of the connection, the negotiation time in ns, the attempt,
+// whether it was retried, the retry delay in ns, whether the interim 100
+// Continue response arrived, the proxy tunnel time in ns and lastly the
+// trace ID.
 func NewCSVEncoder(w io.Writer) Encoder {
 	enc := csv.NewWriter(w)
 	return func(r *Result) error {
@@ -184,6 +461,27 @@ func NewCSVEncoder(w io.Writer) Encoder {
 			r.Method,
 			r.URL,
 			base64.StdEncoding.EncodeToString(headerBytes(r.Headers)),
+			strconv.FormatUint(r.Weight, 10),
+			base64.StdEncoding.EncodeToString(r.RequestBody),
+			base64.StdEncoding.EncodeToString(headerBytes(r.RequestHeaders)),
+			labelsQuery(r.Labels),
+			r.RemoteAddr,
+			r.LocalAddr,
+			r.TLSVersion,
+			r.TLSCipherSuite,
+			r.TLSProtocol,
+			strconv.FormatBool(r.TLSResumed),
+			metadataJSON(r.Metadata),
+			strconv.FormatUint(r.Worker, 10),
+			strconv.FormatInt(r.ConnWait.Nanoseconds(), 10),
+			r.IPFamily,
+			strconv.FormatInt(r.Negotiation.Nanoseconds(), 10),
+			strconv.FormatUint(r.Attempt, 10),
+			strconv.FormatBool(r.Retried),
+			strconv.FormatInt(r.RetryDelay.Nanoseconds(), 10),
+			strconv.FormatBool(r.Continued),
+			strconv.FormatInt(r.Tunnel.Nanoseconds(), 10),
+			r.TraceID,
 		})
 		if err != nil {
 			return err
@@ -204,16 +502,20 @@ func headerBytes(h http.Header) []byte {
 	return append(hdr.Bytes(), '\r', '\n')
 }
 
-// NewCSVDecoder returns a Decoder that decodes CSV encoded Results.
+// NewCSVDecoder returns a Decoder that decodes CSV encoded Results. Records
+// without the columns following the response headers, as written by older
+// versions, are decoded too.
 func NewCSVDecoder(r io.Reader) Decoder {
 	dec := csv.NewReader(r)
-	dec.FieldsPerRecord = 12
+	dec.FieldsPerRecord = -1
 	dec.TrimLeadingSpace = true
 
 	return func(r *Result) error {
 		rec, err := dec.Read()
 		if err != nil {
 			return err
+		} else if len(rec) < 12 || len(rec) > csvColumns {
+			return csv.ErrFieldCount
 		}
 
 		ts, err := strconv.ParseInt(rec[0], 10, 64)
@@ -255,20 +557,160 @@ func NewCSVDecoder(r io.Reader) Decoder {
 		r.Method = rec[9]
 		r.URL = rec[10]
 
-		if rec[11] != "" {
-			pr := textproto.NewReader(bufio.NewReader(
-				base64.NewDecoder(base64.StdEncoding, strings.NewReader(rec[11]))))
-			hdr, err := pr.ReadMIMEHeader()
+		if r.Headers, err = csvHeader(rec[11]); err != nil {
+			return err
+		}
+
+		if len(rec) > 12 {
+			if r.Weight, err = strconv.ParseUint(rec[12], 10, 64); err != nil {
+				return err
+			}
+		}
+
+		if len(rec) > 14 {
+			if r.RequestBody, err = base64.StdEncoding.DecodeString(rec[13]); err != nil {
+				return err
+			}
+
+			if r.RequestHeaders, err = csvHeader(rec[14]); err != nil {
+				return err
+			}
+		}
+
+		if len(rec) > 15 {
+			if r.Labels, err = parseLabels(rec[15]); err != nil {
+				return err
+			}
+		}
+
+		if len(rec) > 17 {
+			r.RemoteAddr = rec[16]
+			r.LocalAddr = rec[17]
+		}
+
+		if len(rec) > 21 {
+			r.TLSVersion = rec[18]
+			r.TLSCipherSuite = rec[19]
+			r.TLSProtocol = rec[20]
+			if r.TLSResumed, err = strconv.ParseBool(rec[21]); err != nil {
+				return err
+			}
+		}
+
+		if len(rec) > 22 {
+			if r.Metadata, err = parseMetadata(rec[22]); err != nil {
+				return err
+			}
+		}
+
+		if len(rec) > 23 {
+			if r.Worker, err = strconv.ParseUint(rec[23], 10, 64); err != nil {
+				return err
+			}
+		}
+
+		if len(rec) > 24 {
+			wait, err := strconv.ParseInt(rec[24], 10, 64)
+			if err != nil {
+				return err
+			}
+			r.ConnWait = time.Duration(wait)
+		}
+
+		if len(rec) > 25 {
+			r.IPFamily = rec[25]
+		}
+
+		if len(rec) > 26 {
+			negotiation, err := strconv.ParseInt(rec[26], 10, 64)
+			if err != nil {
+				return err
+			}
+			r.Negotiation = time.Duration(negotiation)
+		}
+
+		if len(rec) > 29 {
+			if r.Attempt, err = strconv.ParseUint(rec[27], 10, 64); err != nil {
+				return err
+			}
+			if r.Retried, err = strconv.ParseBool(rec[28]); err != nil {
+				return err
+			}
+			delay, err := strconv.ParseInt(rec[29], 10, 64)
+			if err != nil {
+				return err
+			}
+			r.RetryDelay = time.Duration(delay)
+		}
+
+		if len(rec) > 30 {
+			if r.Continued, err = strconv.ParseBool(rec[30]); err != nil {
+				return err
+			}
+		}
+
+		if len(rec) > 31 {
+			tunnel, err := strconv.ParseInt(rec[31], 10, 64)
 			if err != nil {
 				return err
 			}
-			r.Headers = http.Header(hdr)
+			r.Tunnel = time.Duration(tunnel)
+		}
+
+		if len(rec) > 32 {
+			r.TraceID = rec[32]
 		}
 
 		return err
 	}
 }
 
+// csvColumns is the number of columns written by the CSV Encoder.
+const csvColumns = 33
+
+// labelsQuery encodes the given labels as a URL query string, sorted by key.
+func labelsQuery(labels map[string]string) string {
+	q := make(url.Values, len(labels))
+	for k, v := range labels {
+		q.Set(k, v)
+	}
+	return q.Encode()
+}
+
+// parseLabels decodes labels encoded as a URL query string.
+func parseLabels(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	q, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make(map[string]string, len(q))
+	for k, vs := range q {
+		labels[k] = vs[len(vs)-1]
+	}
+	return labels, nil
+}
+
+// csvHeader decodes a base64 encoded header of a CSV record.
+func csvHeader(col string) (http.Header, error) {
+	if col == "" {
+		return nil, nil
+	}
+
+	pr := textproto.NewReader(bufio.NewReader(
+		base64.NewDecoder(base64.StdEncoding, strings.NewReader(col))))
+	hdr, err := pr.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	return http.Header(hdr), nil
+}
+
 //go:generate easyjson -no_std_marshalers -output_filename results_easyjson.go results.go
 //easyjson:json
 type jsonResult Result
@@ -108,6 +108,120 @@ func easyjsonBd1621b8DecodeGithubComTsenartVegetaV12Lib(in *jlexer.Lexer, out *j
 				}
 				in.Delim('}')
 			}
+		case "weight":
+			out.Weight = uint64(in.Uint64())
+		case "request_body":
+			if in.IsNull() {
+				in.Skip()
+				out.RequestBody = nil
+			} else {
+				out.RequestBody = in.Bytes()
+			}
+		case "request_headers":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				in.Delim('{')
+				if !in.IsDelim('}') {
+					out.RequestHeaders = make(http.Header)
+				} else {
+					out.RequestHeaders = nil
+				}
+				for !in.IsDelim('}') {
+					key := string(in.String())
+					in.WantColon()
+					var v9 []string
+					if in.IsNull() {
+						in.Skip()
+						v9 = nil
+					} else {
+						in.Delim('[')
+						if v9 == nil {
+							if !in.IsDelim(']') {
+								v9 = make([]string, 0, 4)
+							} else {
+								v9 = []string{}
+							}
+						} else {
+							v9 = (v9)[:0]
+						}
+						for !in.IsDelim(']') {
+							var v10 string
+							v10 = string(in.String())
+							v9 = append(v9, v10)
+							in.WantComma()
+						}
+						in.Delim(']')
+					}
+					(out.RequestHeaders)[key] = v9
+					in.WantComma()
+				}
+				in.Delim('}')
+			}
+		case "labels":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				in.Delim('{')
+				if !in.IsDelim('}') {
+					out.Labels = make(map[string]string)
+				} else {
+					out.Labels = nil
+				}
+				for !in.IsDelim('}') {
+					key := string(in.String())
+					in.WantColon()
+					var v14 string
+					v14 = string(in.String())
+					(out.Labels)[key] = v14
+					in.WantComma()
+				}
+				in.Delim('}')
+			}
+		case "remote_addr":
+			out.RemoteAddr = string(in.String())
+		case "local_addr":
+			out.LocalAddr = string(in.String())
+		case "tls_version":
+			out.TLSVersion = string(in.String())
+		case "tls_cipher_suite":
+			out.TLSCipherSuite = string(in.String())
+		case "tls_protocol":
+			out.TLSProtocol = string(in.String())
+		case "tls_resumed":
+			out.TLSResumed = bool(in.Bool())
+		case "worker":
+			out.Worker = uint64(in.Uint64())
+		case "conn_wait":
+			out.ConnWait = time.Duration(in.Int64())
+		case "ip_family":
+			out.IPFamily = string(in.String())
+		case "negotiation":
+			out.Negotiation = time.Duration(in.Int64())
+		case "attempt":
+			out.Attempt = uint64(in.Uint64())
+		case "retried":
+			out.Retried = bool(in.Bool())
+		case "retry_delay":
+			out.RetryDelay = time.Duration(in.Int64())
+		case "continued":
+			out.Continued = bool(in.Bool())
+		case "tunnel":
+			out.Tunnel = time.Duration(in.Int64())
+		case "trace_id":
+			out.TraceID = string(in.String())
+		case "metadata":
+			if in.IsNull() {
+				in.Skip()
+				out.Metadata = nil
+			} else {
+				if out.Metadata == nil {
+					out.Metadata = new(Metadata)
+				}
+				if data := in.Raw(); in.Ok() {
+					in.AddError(json.Unmarshal(data, out.Metadata))
+				}
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -209,6 +323,150 @@ func easyjsonBd1621b8EncodeGithubComTsenartVegetaV12Lib(out *jwriter.Writer, in
 			out.RawByte('}')
 		}
 	}
+	if in.Weight != 0 {
+		const prefix string = ",\"weight\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Weight))
+	}
+	if len(in.RequestBody) != 0 {
+		const prefix string = ",\"request_body\":"
+		out.RawString(prefix)
+		out.Base64Bytes(in.RequestBody)
+	}
+	if len(in.RequestHeaders) != 0 {
+		const prefix string = ",\"request_headers\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('{')
+			v11First := true
+			for v11Name, v11Value := range in.RequestHeaders {
+				if v11First {
+					v11First = false
+				} else {
+					out.RawByte(',')
+				}
+				out.String(string(v11Name))
+				out.RawByte(':')
+				if v11Value == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+					out.RawString("null")
+				} else {
+					out.RawByte('[')
+					for v12, v13 := range v11Value {
+						if v12 > 0 {
+							out.RawByte(',')
+						}
+						out.String(string(v13))
+					}
+					out.RawByte(']')
+				}
+			}
+			out.RawByte('}')
+		}
+	}
+	if len(in.Labels) != 0 {
+		const prefix string = ",\"labels\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('{')
+			v15First := true
+			for v15Name, v15Value := range in.Labels {
+				if v15First {
+					v15First = false
+				} else {
+					out.RawByte(',')
+				}
+				out.String(string(v15Name))
+				out.RawByte(':')
+				out.String(string(v15Value))
+			}
+			out.RawByte('}')
+		}
+	}
+	if in.RemoteAddr != "" {
+		const prefix string = ",\"remote_addr\":"
+		out.RawString(prefix)
+		out.String(string(in.RemoteAddr))
+	}
+	if in.LocalAddr != "" {
+		const prefix string = ",\"local_addr\":"
+		out.RawString(prefix)
+		out.String(string(in.LocalAddr))
+	}
+	if in.TLSVersion != "" {
+		const prefix string = ",\"tls_version\":"
+		out.RawString(prefix)
+		out.String(string(in.TLSVersion))
+	}
+	if in.TLSCipherSuite != "" {
+		const prefix string = ",\"tls_cipher_suite\":"
+		out.RawString(prefix)
+		out.String(string(in.TLSCipherSuite))
+	}
+	if in.TLSProtocol != "" {
+		const prefix string = ",\"tls_protocol\":"
+		out.RawString(prefix)
+		out.String(string(in.TLSProtocol))
+	}
+	if in.TLSResumed {
+		const prefix string = ",\"tls_resumed\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.TLSResumed))
+	}
+	if in.Worker != 0 {
+		const prefix string = ",\"worker\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Worker))
+	}
+	if in.ConnWait != 0 {
+		const prefix string = ",\"conn_wait\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.ConnWait))
+	}
+	if in.IPFamily != "" {
+		const prefix string = ",\"ip_family\":"
+		out.RawString(prefix)
+		out.String(string(in.IPFamily))
+	}
+	if in.Negotiation != 0 {
+		const prefix string = ",\"negotiation\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.Negotiation))
+	}
+	if in.Attempt != 0 {
+		const prefix string = ",\"attempt\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Attempt))
+	}
+	if in.Retried {
+		const prefix string = ",\"retried\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.Retried))
+	}
+	if in.RetryDelay != 0 {
+		const prefix string = ",\"retry_delay\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.RetryDelay))
+	}
+	if in.Continued {
+		const prefix string = ",\"continued\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.Continued))
+	}
+	if in.Tunnel != 0 {
+		const prefix string = ",\"tunnel\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.Tunnel))
+	}
+	if in.TraceID != "" {
+		const prefix string = ",\"trace_id\":"
+		out.RawString(prefix)
+		out.String(string(in.TraceID))
+	}
+	if in.Metadata != nil {
+		const prefix string = ",\"metadata\":"
+		out.RawString(prefix)
+		out.Raw(json.Marshal(in.Metadata))
+	}
 	out.RawByte('}')
 }
 
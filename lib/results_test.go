@@ -1,10 +1,13 @@
 package vegeta
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/gob"
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"math"
 	"math/rand"
 	"net/http"
 	"reflect"
@@ -73,9 +76,13 @@ func TestResultEncoding(t *testing.T) {
 		{"auto-gob", NewEncoder, DecoderFor},
 		{"auto-json", NewJSONEncoder, DecoderFor},
 		{"auto-csv", NewCSVEncoder, DecoderFor},
+		{"auto-msgpack", NewMsgpackEncoder, DecoderFor},
+		{"auto-protobuf", NewProtobufEncoder, DecoderFor},
 		{"gob", NewEncoder, NewDecoder},
 		{"csv", NewCSVEncoder, NewCSVDecoder},
 		{"json", NewJSONEncoder, NewJSONDecoder},
+		{"msgpack", NewMsgpackEncoder, NewMsgpackDecoder},
+		{"protobuf", NewProtobufEncoder, NewProtobufDecoder},
 		{"json-dec-compat", NewJSONEncoder, newStdJSONDecoder},
 		{"json-enc-compat", newStdJSONEncoder, NewJSONDecoder},
 	} {
@@ -99,9 +106,73 @@ func TestResultEncoding(t *testing.T) {
 					Body:      rapid.SliceOf(rapid.Byte()).Draw(t, "body").([]byte),
 					Method: rapid.StringMatching("^(GET|PUT|POST|DELETE|HEAD|OPTIONS)$").
 						Draw(t, "method").(string),
-					URL: rapid.String().Draw(t, "url").(string),
+					URL:    rapid.String().Draw(t, "url").(string),
+					Weight: rapid.Uint64().Draw(t, "weight").(uint64),
+
+					RequestBody: rapid.SliceOf(rapid.Byte()).Draw(t, "request_body").([]byte),
+					Labels:      rapid.MapOf(rapid.String(), rapid.String()).Draw(t, "labels").(map[string]string),
+					RemoteAddr:  rapid.StringMatching(`^(\d{1,3}\.){3}\d{1,3}:\d{1,5}$`).Draw(t, "remote_addr").(string),
+					LocalAddr:   rapid.StringMatching(`^(\d{1,3}\.){3}\d{1,3}:\d{1,5}$`).Draw(t, "local_addr").(string),
+
+					TLSVersion:     rapid.StringMatching(`^(TLS 1\.[0-3])?$`).Draw(t, "tls_version").(string),
+					TLSCipherSuite: rapid.StringMatching(`^(TLS_\w+)?$`).Draw(t, "tls_cipher_suite").(string),
+					TLSProtocol:    rapid.StringMatching(`^(h2|http/1\.1)?$`).Draw(t, "tls_protocol").(string),
+					TLSResumed:     rapid.Boolean().Draw(t, "tls_resumed").(bool),
+					Worker:         rapid.Uint64().Draw(t, "worker").(uint64),
+					ConnWait:       time.Duration(rapid.Int64Min(0).Draw(t, "conn_wait").(int64)),
+					IPFamily:       rapid.SampledFrom([]string{"", "ipv4", "ipv6"}).Draw(t, "ip_family").(string),
+					Negotiation:    time.Duration(rapid.Int64Min(0).Draw(t, "negotiation").(int64)),
+					Attempt:        rapid.Uint64().Draw(t, "attempt").(uint64),
+					Retried:        rapid.Boolean().Draw(t, "retried").(bool),
+					RetryDelay:     time.Duration(rapid.Int64Min(0).Draw(t, "retry_delay").(int64)),
+					Continued:      rapid.Boolean().Draw(t, "continued").(bool),
+					Tunnel:         time.Duration(rapid.Int64Min(0).Draw(t, "tunnel").(int64)),
+					TraceID:        rapid.StringMatching(`^([0-9a-f]{32})?$`).Draw(t, "trace_id").(string),
 				}
 
+				if rapid.Boolean().Draw(t, "metadata").(bool) {
+					want.Metadata = &Metadata{
+						Attack:   rapid.String().Draw(t, "metadata.attack").(string),
+						Rate:     rapid.StringMatching(`^\d+/\d+s$`).Draw(t, "metadata.rate").(string),
+						Duration: time.Duration(rapid.Int64Min(0).Draw(t, "metadata.duration").(int64)),
+						Targets:  rapid.StringMatching(`^(sha256:[0-9a-f]{64})?$`).Draw(t, "metadata.targets").(string),
+						Version:  rapid.String().Draw(t, "metadata.version").(string),
+						Args:     rapid.SliceOf(rapid.String()).Draw(t, "metadata.args").([]string),
+						Began:    time.Unix(rapid.Int64Range(1, 1e8).Draw(t, "metadata.began").(int64), 0),
+					}
+
+					if rapid.Boolean().Draw(t, "metadata.annotation").(bool) {
+						want.Metadata = want.Metadata.Annotate(
+							rapid.SampledFrom([]string{AnnotationPause, AnnotationResume}).Draw(t, "metadata.annotation.event").(string),
+							time.Unix(rapid.Int64Range(1, 1e8).Draw(t, "metadata.annotation.time").(int64), 0),
+						)
+
+						if rapid.Boolean().Draw(t, "metadata.annotation.host").(bool) {
+							want.Metadata.Annotation.Event = AnnotationHost
+							if rapid.Boolean().Draw(t, "metadata.annotation.agent").(bool) {
+								want.Metadata.Annotation.Event = AnnotationAgent
+								want.Metadata.Annotation.Source = rapid.StringMatching(`^[a-z0-9.]+:\d+$`).Draw(t, "metadata.annotation.source").(string)
+							}
+							want.Metadata.Annotation.Host = &HostUsage{
+								CPU:     rapid.Float64Range(0, 1).Draw(t, "metadata.annotation.host.cpu").(float64),
+								Memory:  rapid.Float64Range(0, 1).Draw(t, "metadata.annotation.host.memory").(float64),
+								RSS:     rapid.Uint64().Draw(t, "metadata.annotation.host.rss").(uint64),
+								FDs:     rapid.Uint64().Draw(t, "metadata.annotation.host.fds").(uint64),
+								FDLimit: rapid.Uint64().Draw(t, "metadata.annotation.host.fd_limit").(uint64),
+								NetIn:   rapid.Uint64().Draw(t, "metadata.annotation.host.net_in").(uint64),
+								NetOut:  rapid.Uint64().Draw(t, "metadata.annotation.host.net_out").(uint64),
+
+								Connections: rapid.Uint64().Draw(t, "metadata.annotation.host.connections").(uint64),
+							}
+						}
+					}
+				}
+
+				reqHdrs := rapid.MapOf(
+					rapid.StringMatching(`([\w-]+)`),
+					rapid.SliceOfN(rapid.StringMatching(`\S`), 1, -1),
+				).Draw(t, "request_headers").(map[string][]string)
+
 				if len(hdrs) > 0 {
 					want.Headers = make(http.Header, len(hdrs))
 				}
@@ -112,6 +183,16 @@ func TestResultEncoding(t *testing.T) {
 					}
 				}
 
+				if len(reqHdrs) > 0 {
+					want.RequestHeaders = make(http.Header, len(reqHdrs))
+				}
+
+				for k, vs := range reqHdrs {
+					for _, v := range vs {
+						want.RequestHeaders.Add(k, v)
+					}
+				}
+
 				var buf bytes.Buffer
 				enc := tc.enc(&buf)
 				for j := 0; j < 2; j++ {
@@ -142,6 +223,206 @@ func TestResultEncoding(t *testing.T) {
 	}
 }
 
+func TestChainDecoder(t *testing.T) {
+	t.Parallel()
+
+	var b1, b2 bytes.Buffer
+	enc := []Encoder{NewEncoder(&b1), NewEncoder(&b2)}
+	for i := 0; i < 6; i++ {
+		if err := enc[i/3](&Result{Seq: uint64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := NewChainDecoder(
+		NewDecoder(&b1),
+		NewDecoder(&bytes.Reader{}),
+		NewDecoder(&b2),
+	)
+
+	var got []uint64
+	for {
+		var r Result
+		if err := dec.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r.Seq)
+	}
+
+	if want := []uint64{0, 1, 2, 3, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got seqs %v, want %v", got, want)
+	}
+}
+
+func TestFilterDecoder(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for i := 0; i < 10; i++ {
+		if err := enc.Encode(&Result{Seq: uint64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := NewFilterDecoder(NewDecoder(&buf), func(r *Result) bool {
+		return r.Seq%3 == 0
+	})
+
+	var got []uint64
+	for {
+		var r Result
+		if err := dec.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r.Seq)
+	}
+
+	if want := []uint64{0, 3, 6, 9}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got seqs %v, want %v", got, want)
+	}
+}
+
+func TestMapDecoder(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for i := 0; i < 3; i++ {
+		if err := enc.Encode(&Result{Seq: uint64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := NewMapDecoder(NewDecoder(&buf), func(r *Result) {
+		r.Seq *= 10
+	})
+
+	var got []uint64
+	for {
+		var r Result
+		if err := dec.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r.Seq)
+	}
+
+	if want := []uint64{0, 10, 20}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got seqs %v, want %v", got, want)
+	}
+}
+
+func TestGobVersions(t *testing.T) {
+	t.Parallel()
+
+	want := Result{Seq: 1, Code: 200, Timestamp: time.Unix(1e9, 0).UTC(), Latency: time.Second}
+
+	// Version 1 streams, written before versioning, have no header.
+	var legacy bytes.Buffer
+	if err := gob.NewEncoder(&legacy).Encode(&want); err != nil {
+		t.Fatal(err)
+	}
+
+	var current bytes.Buffer
+	if err := NewEncoder(&current).Encode(&want); err != nil {
+		t.Fatal(err)
+	}
+
+	newer := []byte(gobHeaderMagic + string(rune(GobVersion+1)))
+	newer = append(newer, current.Bytes()[len(gobHeader):]...)
+
+	for _, tc := range []struct {
+		name    string
+		data    []byte
+		version int
+		err     bool
+	}{
+		{"legacy", legacy.Bytes(), 1, false},
+		{"current", current.Bytes(), GobVersion, false},
+		{"newer", newer, GobVersion + 1, true},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			v, err := PeekGobVersion(bufio.NewReader(bytes.NewReader(tc.data)))
+			if v != tc.version {
+				t.Errorf("got version %d, want %d", v, tc.version)
+			} else if (err != nil) != tc.err {
+				t.Errorf("got error %v, want error %t", err, tc.err)
+			}
+
+			var got Result
+			if err = NewDecoder(bytes.NewReader(tc.data)).Decode(&got); (err != nil) != tc.err {
+				t.Fatalf("got decoding error %v, want error %t", err, tc.err)
+			} else if !tc.err && !got.Equal(want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestSamplingEncoder(t *testing.T) {
+	t.Parallel()
+
+	var got Results
+	enc := NewSamplingEncoder(func(r *Result) error { got.Add(r); return nil }, 0.1)
+
+	var want Metrics
+	for i := 0; i < 100000; i++ {
+		r := Result{Code: 200, Seq: uint64(i), Latency: time.Millisecond}
+		if i%100 == 0 {
+			r.Code, r.Error = 500, "Internal Server Error"
+		}
+		want.Add(&r)
+		if err := enc.Encode(&r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want.Close()
+
+	var m Metrics
+	errors := 0
+	for i := range got {
+		if got[i].Code == 500 {
+			errors++
+			if got[i].Weight != 0 {
+				t.Errorf("unsuccessful result %d sampled with weight %d", got[i].Seq, got[i].Weight)
+			}
+		} else if got[i].Weight != 10 {
+			t.Errorf("got weight %d, want 10", got[i].Weight)
+		}
+		m.Add(&got[i])
+	}
+	m.Close()
+
+	if errors != 1000 {
+		t.Errorf("got %d unsuccessful results, want all 1000", errors)
+	}
+
+	if n := len(got) - errors; n < 9000 || n > 10800 {
+		t.Errorf("got %d sampled successful results, want about 9900", n)
+	}
+
+	if got, want := float64(m.Requests), float64(want.Requests); math.Abs(got-want)/want > 0.05 {
+		t.Errorf("got %v requests, want about %v", got, want)
+	}
+
+	if got, want := m.StatusCodes["500"], 1000; got != want {
+		t.Errorf("got %d 500 status codes, want %d", got, want)
+	}
+
+	if got, want := m.Latencies.Mean, want.Latencies.Mean; got != want {
+		t.Errorf("got mean latency %v, want %v", got, want)
+	}
+}
+
 func BenchmarkResultEncodings(b *testing.B) {
 	b.StopTimer()
 	b.ResetTimer()
@@ -168,6 +449,8 @@ func BenchmarkResultEncodings(b *testing.B) {
 		{"gob", NewEncoder, NewDecoder},
 		{"csv", NewCSVEncoder, NewCSVDecoder},
 		{"json", NewJSONEncoder, NewJSONDecoder},
+		{"msgpack", NewMsgpackEncoder, NewMsgpackDecoder},
+		{"protobuf", NewProtobufEncoder, NewProtobufDecoder},
 	} {
 		enc := tc.enc(ioutil.Discard)
 
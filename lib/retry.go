@@ -0,0 +1,77 @@
+package vegeta
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// Retries returns a functional option which makes an Attacker retry requests
+// up to n times while the given retry function returns true for the Result of
+// their last attempt and the error it failed with, if any. It backs off for
+// the given duration before the first retry, doubling it before each next
+// one. All attempts are sent on the results channel of the attack, with the
+// Seq of their request. See Result.Attempt.
+func Retries(n uint64, backoff time.Duration, retry func(*Result, error) bool) func(*Attacker) {
+	return func(a *Attacker) {
+		a.retries, a.retryWait, a.retry = n, backoff, retry
+	}
+}
+
+// backoff waits before the retry of the given attempt, and returns false if
+// the attack was stopped meanwhile.
+func (a *Attacker) backoff(attempt uint64) bool {
+	wait := a.retryWait
+	for i := uint64(0); i < attempt && wait < time.Hour; i++ {
+		wait *= 2
+	}
+
+	if wait <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-a.stopch:
+		return false
+	}
+}
+
+// IsConnectError returns whether the given error of an attempt is one of
+// connecting to its target host, i.e. resolving or dialing it, which
+// requests always fail with before they're sent.
+func IsConnectError(err error) bool {
+	var (
+		opErr  *net.OpError
+		dnsErr *net.DNSError
+	)
+	return errors.As(err, &dnsErr) || errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// IsTimeout returns whether the given error of an attempt is a timeout.
+func IsTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// RequestOutcome is a Filter which only keeps the Results which are the
+// outcome of their request, dropping the attempts which were retried. See
+// SpanAttempts to report requests as the clients which retry them see them,
+// rather than by attempt.
+func RequestOutcome(r *Result) bool {
+	return !r.Retried
+}
+
+// SpanAttempts makes the given Result span all the attempts of its request,
+// from when the first one began, by adding its RetryDelay to its Latency and
+// taking it from its Timestamp. It's a no-op on Results which were never
+// retried, or which already span all their attempts.
+func SpanAttempts(r *Result) {
+	r.Timestamp = r.Timestamp.Add(-r.RetryDelay)
+	r.Latency += r.RetryDelay
+	r.RetryDelay = 0
+}
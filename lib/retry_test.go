@@ -0,0 +1,144 @@
+package vegeta
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetries(t *testing.T) {
+	t.Parallel()
+
+	var hits int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every request fails twice before it succeeds.
+		if atomic.AddInt64(&hits, 1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	const backoff = 10 * time.Millisecond
+	retry503 := func(r *Result, err error) bool { return r.Code == http.StatusServiceUnavailable }
+
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+
+	// attempts returns the results of the given number of hits by a worker.
+	attempts := func(atk *Attacker, hits int) (rs []*Result) {
+		var wg sync.WaitGroup
+		ticks, results := make(chan struct{}, hits), make(chan *Result)
+		for i := 0; i < hits; i++ {
+			ticks <- struct{}{}
+		}
+		close(ticks)
+
+		wg.Add(1)
+		go atk.attack(tr, "", 1, &wg, ticks, results)
+		go func() { wg.Wait(); close(results) }()
+
+		for r := range results {
+			rs = append(rs, r)
+		}
+		return rs
+	}
+
+	results := attempts(NewAttacker(Retries(3, backoff, retry503)), 2)
+
+	if len(results) != 6 {
+		t.Fatalf("got %d results, want 6", len(results))
+	}
+
+	for i, r := range results {
+		attempt := uint64(i % 3)
+		if r.Seq != uint64(i/3) || r.Attempt != attempt || r.Retried != (attempt < 2) {
+			t.Errorf("result %d: got seq %d, attempt %d, retried %t", i, r.Seq, r.Attempt, r.Retried)
+		}
+
+		// Backoffs double, from 10ms before the first retry to 20ms before the second.
+		min := time.Duration(attempt) * backoff
+		if attempt == 2 {
+			min = 3 * backoff
+		}
+		if r.RetryDelay < min {
+			t.Errorf("result %d: got retry delay %v, want at least %v", i, r.RetryDelay, min)
+		}
+
+		if attempt == 2 {
+			first := results[i-2]
+			if got := r.Timestamp.Sub(first.Timestamp); got != r.RetryDelay {
+				t.Errorf("result %d: got retry delay %v, want %v since the first attempt", i, r.RetryDelay, got)
+			}
+		}
+	}
+
+	// Attempts stop at the maximum number of retries.
+	atomic.StoreInt64(&hits, 0)
+	results = attempts(NewAttacker(Retries(1, 0, retry503)), 1)
+	if len(results) != 2 || results[1].Code != http.StatusServiceUnavailable || results[1].Retried {
+		t.Errorf("got %d attempts, want two, the last of which failed and wasn't retried", len(results))
+	}
+}
+
+func TestIsConnectError(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	_, err = http.Get("http://" + addr)
+	if err == nil {
+		t.Fatal("got no error connecting to a closed port")
+	} else if !IsConnectError(err) {
+		t.Errorf("got %v, want a connect error", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	_, err = (&http.Client{Timeout: 10 * time.Millisecond}).Get(server.URL)
+	if err == nil || IsConnectError(err) || !IsTimeout(err) {
+		t.Errorf("got %v, want a timeout which isn't a connect error", err)
+	}
+}
+
+func TestRequestOutcome(t *testing.T) {
+	t.Parallel()
+
+	began := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	attempts := []Result{
+		{Seq: 1, Timestamp: began, Latency: 50 * time.Millisecond, Code: 503, Retried: true},
+		{Seq: 1, Timestamp: began.Add(150 * time.Millisecond), Latency: 30 * time.Millisecond, Code: 200, Attempt: 1, RetryDelay: 150 * time.Millisecond},
+	}
+
+	if RequestOutcome(&attempts[0]) {
+		t.Error("kept a retried attempt")
+	}
+
+	r := attempts[1]
+	if !RequestOutcome(&r) {
+		t.Fatal("dropped the outcome of a request")
+	} else if !r.Equal(attempts[1]) {
+		t.Errorf("got %+v, want the outcome unchanged", r)
+	}
+
+	SpanAttempts(&r)
+	if !r.Timestamp.Equal(began) || r.Latency != 180*time.Millisecond {
+		t.Errorf("got timestamp %v and latency %v, want the span of all attempts", r.Timestamp, r.Latency)
+	}
+
+	// Requests only span their attempts once, however many times they're
+	// made to.
+	if SpanAttempts(&r); r.Latency != 180*time.Millisecond {
+		t.Errorf("got latency %v when spanned again", r.Latency)
+	}
+}
@@ -0,0 +1,81 @@
+package vegeta
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Revalidate returns a functional option which makes an Attacker remember the
+// ETag and Last-Modified validators of the responses to GET and HEAD requests
+// per URL, and revalidate them on later hits of the same URL with the
+// If-None-Match and If-Modified-Since headers, as caching clients do, so that
+// revalidation heavy traffic can be modeled. Targets setting their own
+// conditional headers are sent as they are. Responses to revalidations which
+// are Not Modified are counted under the 304 status code of reports.
+func Revalidate(enabled bool) func(*Attacker) {
+	return func(a *Attacker) {
+		if a.validators = nil; enabled {
+			a.validators = &validatorCache{urls: map[string]validators{}}
+		}
+	}
+}
+
+// validators are those of the last modified response to a URL.
+type validators struct {
+	etag         string
+	lastModified string
+}
+
+// validatorCache holds the validators of URLs.
+type validatorCache struct {
+	mu   sync.RWMutex
+	urls map[string]validators
+}
+
+// revalidated returns the key of the given request in the cache, and makes it
+// conditional on its validators, if it can be, or returns an empty key.
+func (c *validatorCache) revalidated(req *http.Request) string {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return ""
+	} else if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return ""
+	}
+
+	key := req.URL.String()
+
+	c.mu.RLock()
+	v, ok := c.urls[key]
+	c.mu.RUnlock()
+
+	if ok && v.etag != "" {
+		req.Header.Set("If-None-Match", v.etag)
+	}
+
+	if ok && v.lastModified != "" {
+		req.Header.Set("If-Modified-Since", v.lastModified)
+	}
+
+	return key
+}
+
+// store remembers the validators of the given response to the given request,
+// with the given key, if it's modified, i.e. not 304 Not Modified, and not of
+// another URL it was redirected to.
+func (c *validatorCache) store(key string, req *http.Request, r *http.Response) {
+	if key == "" || r.StatusCode < 200 || r.StatusCode >= 300 {
+		return
+	} else if r.Request != nil && r.Request.URL.String() != req.URL.String() {
+		return
+	}
+
+	v := validators{etag: r.Header.Get("ETag"), lastModified: r.Header.Get("Last-Modified")}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if v == (validators{}) {
+		delete(c.urls, key)
+	} else {
+		c.urls[key] = v
+	}
+}
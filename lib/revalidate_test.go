@@ -0,0 +1,75 @@
+package vegeta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRevalidate(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/etag":
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/modified":
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+			if r.Header.Get("If-Modified-Since") != "" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/moved":
+			http.Redirect(w, r, "/etag", http.StatusFound)
+			return
+		}
+		w.Write([]byte("fresh"))
+	}))
+	defer server.Close()
+
+	codes := func(atk *Attacker, tgt Target, n int) (codes []uint16) {
+		tr := NewStaticTargeter(tgt)
+		for i := 0; i < n; i++ {
+			codes = append(codes, atk.hit(tr, "", 1).Code)
+		}
+		return codes
+	}
+
+	atk := NewAttacker(Revalidate(true), CacheBust("_cb", false))
+	for _, tc := range []struct {
+		tgt  Target
+		want []uint16
+	}{
+		{Target{Method: "GET", URL: server.URL + "/etag"}, []uint16{200, 304, 304}},
+		{Target{Method: "GET", URL: server.URL + "/modified"}, []uint16{200, 304}},
+		{Target{Method: "POST", URL: server.URL + "/etag?post"}, []uint16{200, 200}},
+		{Target{Method: "GET", URL: server.URL + "/none"}, []uint16{200, 200}},
+		// Validators of the URLs redirected to aren't the redirecting ones'.
+		{Target{Method: "GET", URL: server.URL + "/moved"}, []uint16{200, 200}},
+		{Target{Method: "GET", URL: server.URL + "/etag?own", Header: http.Header{"If-None-Match": {`"v0"`}}}, []uint16{200, 200}},
+	} {
+		if got := codes(atk, tc.tgt, len(tc.want)); !equalCodes(got, tc.want) {
+			t.Errorf("%s %s: got codes %v, want %v", tc.tgt.Method, tc.tgt.URL, got, tc.want)
+		}
+	}
+
+	if got := codes(NewAttacker(), Target{Method: "GET", URL: server.URL + "/etag"}, 2); !equalCodes(got, []uint16{200, 200}) {
+		t.Errorf("got codes %v without Revalidate, want no revalidations", got)
+	}
+}
+
+func equalCodes(a, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package vegeta
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RotateHeader returns a functional option which makes an Attacker set the
+// given header of each request to the next of the given values, e.g. from a
+// pool of User-Agents, API keys or tenant IDs, so that per client throttling
+// of targets is exercised as by many clients. Values are taken in turn, or
+// at random if random is true. It's applied like a BeforeHit function, so
+// that it replaces the header of targets, and it's ignored without values.
+func RotateHeader(name string, values []string, random bool) func(*Attacker) {
+	if len(values) == 0 {
+		return func(*Attacker) {}
+	}
+
+	next := roundRobin(values)
+	if random {
+		next = randomly(values)
+	}
+
+	name = http.CanonicalHeaderKey(name)
+	return BeforeHit(func(_ *Target, req *http.Request) error {
+		req.Header.Set(name, next())
+		return nil
+	})
+}
+
+// roundRobin returns a function returning the given values in turn.
+func roundRobin(values []string) func() string {
+	var n uint64
+	return func() string {
+		return values[(atomic.AddUint64(&n, 1)-1)%uint64(len(values))]
+	}
+}
+
+// randomly returns a function returning the given values at random.
+func randomly(values []string) func() string {
+	var mu sync.Mutex
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return values[rnd.Intn(len(values))]
+	}
+}
@@ -0,0 +1,46 @@
+package vegeta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRotateHeader(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("User-Agent") + " " + r.Header.Get("X-Tenant")))
+	}))
+	defer server.Close()
+
+	agents := []string{"goku", "vegeta", "gohan"}
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL, Header: http.Header{"X-Tenant": {"target"}}})
+
+	atk := NewAttacker(RotateHeader("user-agent", agents, false), RotateHeader("X-Tenant", []string{"t1"}, false))
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, string(atk.hit(tr, "", 1).Body))
+	}
+
+	if want := "goku t1,vegeta t1,gohan t1,goku t1"; strings.Join(got, ",") != want {
+		t.Errorf("got %q, want the values in turn replacing the target's", got)
+	}
+
+	atk = NewAttacker(RotateHeader("User-Agent", agents, true))
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		seen[string(atk.hit(tr, "", 1).Body)] = true
+	}
+
+	for _, agent := range agents {
+		if !seen[agent+" target"] {
+			t.Errorf("didn't see %q in 100 random hits: %v", agent, seen)
+		}
+	}
+
+	if res := NewAttacker(RotateHeader("X-Tenant", nil, false)).hit(tr, "", 1); !strings.HasSuffix(string(res.Body), " target") {
+		t.Errorf("got %q without values, want the target's header", res.Body)
+	}
+}
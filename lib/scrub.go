@@ -0,0 +1,178 @@
+package vegeta
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// A Scrubber redacts or hashes sensitive values of a Result in place, such
+// as tokens in URL query parameters or headers, so that result files can be
+// shared.
+type Scrubber func(*Result)
+
+// redacted replaces the values of redacted fields.
+const redacted = "REDACTED"
+
+// NewScrubber returns a Scrubber which replaces the values of the given
+// fields to redact with "REDACTED", and those of the given fields to hash
+// with their SHA-256 hash ("sha256:<hex>"), which keeps equal values
+// correlated. Note that hashes of guessable values, like short numeric IDs,
+// can be reversed.
+//
+// The supported fields are:
+//   - url.query.<param> and url.query, the values of a query parameter or
+//     of all of them in URLs. URLs in errors are scrubbed likewise.
+//   - header.<name> and request_header.<name>, the values of response and
+//     request headers.
+//   - body and request_body, the response and request bodies.
+//   - label.<key>, the value of a label.
+//   - metadata.args, the command line arguments of attacks in metadata
+//     records, which may contain secrets passed with -header.
+func NewScrubber(redact, hash []string) (Scrubber, error) {
+	var scrubbers []Scrubber
+	for _, fields := range []struct {
+		names []string
+		fn    func(string) string
+	}{
+		{redact, func(string) string { return redacted }},
+		{hash, scrubHash},
+	} {
+		for _, name := range fields.names {
+			s, err := newFieldScrubber(name, fields.fn)
+			if err != nil {
+				return nil, err
+			}
+			scrubbers = append(scrubbers, s)
+		}
+	}
+
+	return func(r *Result) {
+		for _, s := range scrubbers {
+			s(r)
+		}
+	}, nil
+}
+
+func scrubHash(v string) string {
+	sum := sha256.Sum256([]byte(v))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// newFieldScrubber returns a Scrubber replacing the values of the named
+// field with the result of fn.
+func newFieldScrubber(name string, fn func(string) string) (Scrubber, error) {
+	switch name {
+	case "body":
+		return func(r *Result) {
+			if len(r.Body) > 0 {
+				r.Body = []byte(fn(string(r.Body)))
+			}
+		}, nil
+	case "request_body":
+		return func(r *Result) {
+			if len(r.RequestBody) > 0 {
+				r.RequestBody = []byte(fn(string(r.RequestBody)))
+			}
+		}, nil
+	case "url.query":
+		return func(r *Result) { scrubURL(r, func(string) bool { return true }, fn) }, nil
+	case "metadata.args":
+		return func(r *Result) {
+			if r.Metadata == nil || len(r.Metadata.Args) == 0 {
+				return
+			}
+			md := *r.Metadata
+			md.Args = make([]string, len(r.Metadata.Args))
+			for i, arg := range r.Metadata.Args {
+				md.Args[i] = fn(arg)
+			}
+			r.Metadata = &md
+		}, nil
+	}
+
+	var prefix, key string
+	for _, p := range []string{"url.query.", "header.", "request_header.", "label."} {
+		if strings.HasPrefix(name, p) && len(name) > len(p) {
+			prefix, key = p[:len(p)-1], name[len(p):]
+			break
+		}
+	}
+
+	switch prefix {
+	case "url.query":
+		return func(r *Result) { scrubURL(r, func(k string) bool { return k == key }, fn) }, nil
+	case "header":
+		key = http.CanonicalHeaderKey(key)
+		return func(r *Result) { scrubHeader(r.Headers, key, fn) }, nil
+	case "request_header":
+		key = http.CanonicalHeaderKey(key)
+		return func(r *Result) { scrubHeader(r.RequestHeaders, key, fn) }, nil
+	case "label":
+		return func(r *Result) {
+			if v, ok := r.Labels[key]; ok {
+				labels := make(map[string]string, len(r.Labels))
+				for k, v := range r.Labels {
+					labels[k] = v
+				}
+				labels[key] = fn(v)
+				r.Labels = labels
+			}
+		}, nil
+	}
+
+	return nil, fmt.Errorf("scrub: unknown field %q", name)
+}
+
+func scrubHeader(h http.Header, name string, fn func(string) string) {
+	for i, v := range h[name] {
+		h[name][i] = fn(v)
+	}
+}
+
+// scrubURL replaces the values of the query parameters of the Result's URL
+// matched by match with the result of fn, leaving the rest of the URL as is.
+// The URL is replaced in the Result's error too.
+func scrubURL(r *Result, match func(string) bool, fn func(string) string) {
+	i := strings.IndexByte(r.URL, '?')
+	if i < 0 {
+		return
+	}
+
+	query, fragment := r.URL[i+1:], ""
+	if j := strings.IndexByte(query, '#'); j >= 0 {
+		query, fragment = query[:j], query[j:]
+	}
+
+	params := strings.Split(query, "&")
+	for k, param := range params {
+		key, value := param, ""
+		if j := strings.IndexByte(param, '='); j >= 0 {
+			key, value = param[:j], param[j+1:]
+		}
+
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+
+		if param == "" || !match(name) {
+			continue
+		}
+
+		if v, err := url.QueryUnescape(value); err == nil {
+			value = v
+		}
+
+		params[k] = key + "=" + url.QueryEscape(fn(value))
+	}
+
+	scrubbed := r.URL[:i+1] + strings.Join(params, "&") + fragment
+	if r.Error != "" {
+		r.Error = strings.Replace(r.Error, r.URL, scrubbed, -1)
+	}
+	r.URL = scrubbed
+}
@@ -0,0 +1,94 @@
+package vegeta
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestScrubber(t *testing.T) {
+	t.Parallel()
+
+	newResult := func() *Result {
+		return &Result{
+			URL:            "http://example.com/login?user=jane&token=s3cr%2Ft&page=1#top",
+			Error:          `Get "http://example.com/login?user=jane&token=s3cr%2Ft&page=1#top": EOF`,
+			Body:           []byte(`{"session":"abc"}`),
+			RequestBody:    []byte(`{"password":"hunter2"}`),
+			Headers:        http.Header{"Set-Cookie": []string{"session=abc", "theme=dark"}},
+			RequestHeaders: http.Header{"Authorization": []string{"Bearer xyz"}, "Accept": []string{"*/*"}},
+			Labels:         map[string]string{"customer": "acme", "region": "eu"},
+			Metadata:       &Metadata{Attack: "login", Args: []string{"attack", "-header", "Authorization: Bearer xyz"}},
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		redact []string
+		hash   []string
+		want   func(*Result)
+		err    bool
+	}{
+		{
+			name: "none",
+			want: func(*Result) {},
+		},
+		{
+			name:   "query param",
+			redact: []string{"url.query.token"},
+			want: func(r *Result) {
+				r.URL = "http://example.com/login?user=jane&token=REDACTED&page=1#top"
+				r.Error = `Get "http://example.com/login?user=jane&token=REDACTED&page=1#top": EOF`
+			},
+		},
+		{
+			name: "all query params hashed",
+			hash: []string{"url.query"},
+			want: func(r *Result) {
+				r.URL = "http://example.com/login?" +
+					"user=sha256%3A81f8f6dde88365f3928796ec7aa53f72820b06db8664f5fe76a7eb13e24546a2&" +
+					"token=sha256%3A" + scrubHash("s3cr/t")[len("sha256:"):] + "&" +
+					"page=sha256%3A6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b#top"
+				r.Error = `Get "` + r.URL + `": EOF`
+			},
+		},
+		{
+			name:   "headers, bodies, labels and args",
+			redact: []string{"header.set-cookie", "request_header.Authorization", "body", "label.customer", "metadata.args"},
+			hash:   []string{"request_body"},
+			want: func(r *Result) {
+				r.Headers["Set-Cookie"] = []string{"REDACTED", "REDACTED"}
+				r.RequestHeaders["Authorization"] = []string{"REDACTED"}
+				r.Body = []byte("REDACTED")
+				r.RequestBody = []byte(scrubHash(`{"password":"hunter2"}`))
+				r.Labels = map[string]string{"customer": "REDACTED", "region": "eu"}
+				r.Metadata = &Metadata{Attack: "login", Args: []string{"REDACTED", "REDACTED", "REDACTED"}}
+			},
+		},
+		{name: "unknown field", redact: []string{"cookie"}, err: true},
+		{name: "missing key", hash: []string{"header."}, err: true},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			scrub, err := NewScrubber(tc.redact, tc.hash)
+			if tc.err {
+				if err == nil {
+					t.Fatal("want error")
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			got, want := newResult(), newResult()
+			scrub(got)
+			tc.want(want)
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("\ngot:  %+v\nwant: %+v", got, want)
+			}
+		})
+	}
+}
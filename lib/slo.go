@@ -0,0 +1,190 @@
+package vegeta
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An SLO is a service level objective which asserts a condition on a value
+// of Metrics, such as "p99 < 300ms" or "success >= 99.9%".
+//
+// The supported metrics are:
+//   - min, mean, max and pNN (e.g. p50, p99, p99.9) request latencies,
+//     compared to durations (e.g. 300ms).
+//   - success and error_rate ratios, compared to percentages (e.g. 99.9%)
+//     or ratios (e.g. 0.999).
+//   - requests count and rate and throughput per second, compared to numbers.
+//
+// The supported operators are <, <=, >, >=, == and !=.
+type SLO struct {
+	// Metric is the name of the asserted metric.
+	Metric string
+	// Op is the comparison operator.
+	Op string
+	// Threshold is the value the metric is compared to, in nanoseconds
+	// for latencies and as a ratio for success and error_rate.
+	Threshold float64
+}
+
+var sloOps = []string{"<=", ">=", "==", "!=", "<", ">"}
+
+// ParseSLO parses an SLO from its textual representation.
+func ParseSLO(s string) (SLO, error) {
+	for _, op := range sloOps {
+		i := strings.Index(s, op)
+		if i < 0 {
+			continue
+		}
+
+		slo := SLO{
+			Metric: strings.ToLower(strings.TrimSpace(s[:i])),
+			Op:     op,
+		}
+
+		kind, err := sloMetricKind(slo.Metric)
+		if err != nil {
+			return SLO{}, err
+		}
+
+		val := strings.TrimSpace(s[i+len(op):])
+		if slo.Threshold, err = parseSLOValue(kind, val); err != nil {
+			return SLO{}, fmt.Errorf("slo %q: bad threshold %q: %v", s, val, err)
+		}
+
+		return slo, nil
+	}
+	return SLO{}, fmt.Errorf("slo %q: missing comparison operator (one of %s)", s, strings.Join(sloOps, ", "))
+}
+
+type sloKind int
+
+const (
+	sloDuration sloKind = iota
+	sloRatio
+	sloNumber
+)
+
+func sloMetricKind(metric string) (sloKind, error) {
+	switch metric {
+	case "min", "mean", "max":
+		return sloDuration, nil
+	case "success", "error_rate":
+		return sloRatio, nil
+	case "requests", "rate", "throughput":
+		return sloNumber, nil
+	}
+
+	if q, err := sloQuantile(metric); err == nil && q > 0 && q <= 1 {
+		return sloDuration, nil
+	}
+
+	return 0, fmt.Errorf("slo: unknown metric %q", metric)
+}
+
+// sloQuantile parses a pNN metric name into its quantile.
+func sloQuantile(metric string) (float64, error) {
+	if !strings.HasPrefix(metric, "p") {
+		return 0, fmt.Errorf("bad percentile %q", metric)
+	}
+	p, err := strconv.ParseFloat(metric[1:], 64)
+	return p / 100, err
+}
+
+func parseSLOValue(kind sloKind, s string) (float64, error) {
+	switch kind {
+	case sloDuration:
+		d, err := time.ParseDuration(s)
+		return float64(d), err
+	case sloRatio:
+		if strings.HasSuffix(s, "%") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(s[:len(s)-1]), 64)
+			return v / 100, err
+		}
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// Value returns the value of the SLO's metric in the given Metrics.
+func (s SLO) Value(m *Metrics) float64 { return metricValue(m, s.Metric) }
+
+// metricValue returns the value of the named metric in the given Metrics.
+func metricValue(m *Metrics, metric string) float64 {
+	switch metric {
+	case "min":
+		return float64(m.Latencies.Min)
+	case "mean":
+		return float64(m.Latencies.Mean)
+	case "max":
+		return float64(m.Latencies.Max)
+	case "success":
+		return m.Success
+	case "error_rate":
+		return 1 - m.Success
+	case "requests":
+		return float64(m.Requests)
+	case "rate":
+		return m.Rate
+	case "throughput":
+		return m.Throughput
+	}
+
+	q, _ := sloQuantile(metric)
+	return float64(m.Latencies.Quantile(q))
+}
+
+// Check returns true if the given Metrics meet the SLO.
+func (s SLO) Check(m *Metrics) bool {
+	v := s.Value(m)
+	switch s.Op {
+	case "<":
+		return v < s.Threshold
+	case "<=":
+		return v <= s.Threshold
+	case ">":
+		return v > s.Threshold
+	case ">=":
+		return v >= s.Threshold
+	case "==":
+		return v == s.Threshold
+	case "!=":
+		return v != s.Threshold
+	}
+	return false
+}
+
+// Format returns the given value of the SLO's metric formatted
+// in the SLO's units.
+func (s SLO) Format(v float64) string { return formatMetric(s.Metric, v) }
+
+// formatMetric returns the given value of the named metric formatted in its units.
+func formatMetric(metric string, v float64) string {
+	kind, _ := sloMetricKind(metric)
+	switch kind {
+	case sloDuration:
+		if d := time.Duration(v); d < 0 {
+			return "-" + round(-d).String()
+		}
+		return round(time.Duration(v)).String()
+	case sloRatio:
+		return formatFloat(v*100) + "%"
+	default:
+		return formatFloat(v)
+	}
+}
+
+// formatFloat formats v with up to 4 decimals, without trailing zeros.
+func formatFloat(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 4, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// String returns the textual representation of the SLO.
+func (s SLO) String() string {
+	return s.Metric + " " + s.Op + " " + s.Format(s.Threshold)
+}
@@ -0,0 +1,106 @@
+package vegeta
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSLO(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		in   string
+		want SLO
+		err  bool
+	}{
+		{in: "p99<300ms", want: SLO{"p99", "<", float64(300 * time.Millisecond)}},
+		{in: " P99.9 <= 1s ", want: SLO{"p99.9", "<=", float64(time.Second)}},
+		{in: "success >= 99.9%", want: SLO{"success", ">=", 0.999}},
+		{in: "error_rate<0.01", want: SLO{"error_rate", "<", 0.01}},
+		{in: "rate > 100", want: SLO{"rate", ">", 100}},
+		{in: "requests == 1000", want: SLO{"requests", "==", 1000}},
+		{in: "max != 0s", want: SLO{"max", "!=", 0}},
+		{in: "p99 300ms", err: true},
+		{in: "p0 < 1s", err: true},
+		{in: "p101 < 1s", err: true},
+		{in: "latency < 1s", err: true},
+		{in: "p99 < 300", err: true},
+		{in: "success > lots", err: true},
+	} {
+		got, err := ParseSLO(tc.in)
+		if tc.err {
+			if err == nil {
+				t.Errorf("ParseSLO(%q): want error, got %+v", tc.in, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseSLO(%q): %v", tc.in, err)
+		} else if got.Metric != tc.want.Metric || got.Op != tc.want.Op ||
+			got.Threshold-tc.want.Threshold > 1e-9 || tc.want.Threshold-got.Threshold > 1e-9 {
+			t.Errorf("ParseSLO(%q): got %+v, want %+v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestSLOCheck(t *testing.T) {
+	t.Parallel()
+
+	var m Metrics
+	for i := 1; i <= 100; i++ {
+		r := Result{
+			Code:      200,
+			Timestamp: time.Unix(int64(i), 0),
+			Latency:   time.Duration(i) * time.Millisecond,
+		}
+		if i%10 == 0 {
+			r.Code, r.Error = 500, "Internal Server Error"
+		}
+		m.Add(&r)
+	}
+	m.Close()
+
+	for _, tc := range []struct {
+		slo  string
+		want bool
+	}{
+		{"p50 < 100ms", true},
+		{"p99 < 50ms", false},
+		{"max <= 100ms", true},
+		{"min > 1ms", false},
+		{"success >= 90%", true},
+		{"success > 90%", false},
+		{"error_rate < 5%", false},
+		{"requests == 100", true},
+		{"rate > 2", false},
+	} {
+		slo, err := ParseSLO(tc.slo)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := slo.Check(&m); got != tc.want {
+			t.Errorf("%s: got %t, want %t (value %s)", slo, got, tc.want, slo.Format(slo.Value(&m)))
+		}
+	}
+}
+
+func TestSLOString(t *testing.T) {
+	t.Parallel()
+
+	for in, want := range map[string]string{
+		"p99<300ms":      "p99 < 300ms",
+		"success>=99.9%": "success >= 99.9%",
+		"rate>100":       "rate > 100",
+	} {
+		slo, err := ParseSLO(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := slo.String(); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package vegeta
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Slowest is a Report which keeps the N slowest Results, so that tail
+// latencies can be tied back to concrete requests.
+type Slowest struct {
+	// N is the number of slowest Results to keep.
+	N int
+
+	results resultHeap
+}
+
+// Add implements the Add method of the Report interface by adding the given
+// Result to Slowest if it's one of the N slowest so far.
+func (s *Slowest) Add(r *Result) {
+	if s.N <= 0 {
+		return
+	}
+
+	if len(s.results) == s.N {
+		if r.Latency <= s.results[0].Latency {
+			return
+		}
+		heap.Pop(&s.results)
+	}
+
+	cp := *r
+	cp.Body = nil // Bodies aren't reported and could take up a lot of memory.
+	heap.Push(&s.results, cp)
+}
+
+// Results returns the N slowest Results, sorted by descending latency.
+func (s *Slowest) Results() []Result {
+	rs := append([]Result(nil), s.results...)
+	sort.SliceStable(rs, func(i, j int) bool {
+		return rs[i].Latency > rs[j].Latency
+	})
+	return rs
+}
+
+// resultHeap is a min-heap of Results ordered by latency.
+type resultHeap []Result
+
+func (h resultHeap) Len() int            { return len(h) }
+func (h resultHeap) Less(i, j int) bool  { return h[i].Latency < h[j].Latency }
+func (h resultHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *resultHeap) Push(x interface{}) { *h = append(*h, x.(Result)) }
+
+func (h *resultHeap) Pop() interface{} {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// NewSlowestReporter returns a Reporter that writes out the Results kept
+// by Slowest as aligned, formatted text. The addresses of their connections
+// and their trace IDs are written out too when any of them has one.
+func NewSlowestReporter(s *Slowest) Reporter {
+	return func(w io.Writer) error {
+		rs := s.Results()
+
+		var addrs, traces bool
+		for _, r := range rs {
+			addrs = addrs || r.RemoteAddr != "" || r.LocalAddr != ""
+			traces = traces || r.TraceID != ""
+		}
+
+		cols := []string{"Latency", "Timestamp", "Status", "Attack", "Seq", "Request"}
+		if addrs {
+			cols = append(cols, "Remote", "Local")
+		}
+		if traces {
+			cols = append(cols, "Trace ID")
+		}
+		cols = append(cols, "Error")
+
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.StripEscape)
+		if _, err := fmt.Fprintln(tw, strings.Join(cols, "\t")); err != nil {
+			return err
+		}
+
+		for _, r := range rs {
+			row := []string{
+				round(r.Latency).String(),
+				r.Timestamp.UTC().Format(time.RFC3339Nano),
+				strconv.Itoa(int(r.Code)),
+				r.Attack,
+				strconv.FormatUint(r.Seq, 10),
+				r.Method + " " + r.URL,
+			}
+			if addrs {
+				row = append(row, r.RemoteAddr, r.LocalAddr)
+			}
+			if traces {
+				row = append(row, r.TraceID)
+			}
+			row = append(row, r.Error)
+
+			if _, err := fmt.Fprintln(tw, strings.Join(row, "\t")); err != nil {
+				return err
+			}
+		}
+
+		return tw.Flush()
+	}
+}
@@ -0,0 +1,118 @@
+package vegeta
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlowest(t *testing.T) {
+	t.Parallel()
+
+	s := Slowest{N: 3}
+	for _, i := range rand.New(rand.NewSource(0)).Perm(100) {
+		s.Add(&Result{
+			Seq:     uint64(i),
+			Latency: time.Duration(i) * time.Millisecond,
+			Body:    []byte("big"),
+		})
+	}
+
+	rs := s.Results()
+	if len(rs) != 3 {
+		t.Fatalf("got %d results, want 3", len(rs))
+	}
+
+	for i, r := range rs {
+		if want := uint64(99 - i); r.Seq != want {
+			t.Errorf("got result %d with seq %d, want %d", i, r.Seq, want)
+		}
+		if r.Body != nil {
+			t.Errorf("got result %d with body %q, want none", i, r.Body)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := NewSlowestReporter(&s).Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4:\n%s", len(lines), buf.String())
+	} else if !strings.HasPrefix(lines[1], "99ms") {
+		t.Errorf("got first line %q, want it to start with the slowest latency", lines[1])
+	}
+}
+
+func TestSlowestZero(t *testing.T) {
+	t.Parallel()
+
+	var s Slowest
+	s.Add(&Result{Latency: time.Second})
+	if rs := s.Results(); len(rs) != 0 {
+		t.Errorf("got %d results, want none", len(rs))
+	}
+}
+
+func TestSlowestReporterColumns(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name   string
+		result Result
+		header []string
+		row    []string
+	}{
+		{
+			name:   "plain",
+			result: Result{Code: 200, Method: "GET", URL: "http://localhost/"},
+			header: []string{"Latency", "Timestamp", "Status", "Attack", "Seq", "Request"},
+			row:    []string{"GET", "http://localhost/"},
+		},
+		{
+			name:   "addresses",
+			result: Result{Code: 200, Method: "GET", URL: "http://localhost/", RemoteAddr: "10.0.0.1:80", LocalAddr: "10.0.0.2:5000"},
+			header: []string{"Request", "Remote", "Local", "Error"},
+			row:    []string{"http://localhost/", "10.0.0.1:80", "10.0.0.2:5000"},
+		},
+		{
+			name:   "trace id",
+			result: Result{Code: 500, Method: "GET", URL: "http://localhost/", TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", Error: "500 Internal Server Error"},
+			header: []string{"Request", "Trace", "ID", "Error"},
+			row:    []string{"http://localhost/", "4bf92f3577b34da6a3ce929d0e0e4736", "500", "Internal", "Server", "Error"},
+		},
+		{
+			name:   "both",
+			result: Result{Code: 200, Method: "GET", URL: "http://localhost/", RemoteAddr: "10.0.0.1:80", TraceID: "4bf92f3577b34da6a3ce929d0e0e4736"},
+			header: []string{"Request", "Remote", "Local", "Trace", "ID", "Error"},
+			row:    []string{"http://localhost/", "10.0.0.1:80", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		},
+	} {
+		s := Slowest{N: 2}
+		s.Add(&tc.result)
+		s.Add(&Result{Code: 200, Method: "GET", URL: "http://localhost/fast"})
+
+		var buf bytes.Buffer
+		if err := NewSlowestReporter(&s).Report(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("%s: got %d lines, want 3:\n%s", tc.name, len(lines), buf.String())
+		}
+
+		for i, want := range [][]string{tc.header, tc.row} {
+			if got := strings.Join(strings.Fields(lines[i]), " "); !strings.Contains(got, strings.Join(want, " ")) {
+				t.Errorf("%s: got line %q, want it to contain %q", tc.name, got, strings.Join(want, " "))
+			}
+		}
+
+		if tc.name == "plain" && strings.Contains(lines[0], "Remote") || strings.Contains(lines[0], "Trace") != (tc.result.TraceID != "") {
+			t.Errorf("%s: got header %q", tc.name, lines[0])
+		}
+	}
+}
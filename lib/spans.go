@@ -0,0 +1,179 @@
+package vegeta
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"math/rand"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Span is a timed operation of a hit traced by an Attacker with Spans.
+type Span struct {
+	TraceID    string                 // 32 lower case hex digits.
+	SpanID     string                 // 16 lower case hex digits.
+	ParentID   string                 // Empty unless it has a parent span.
+	Name       string                 // Method of the request, or dns, connect, tls or ttfb.
+	Client     bool                   // Whether it's the client span of the request.
+	Start      time.Time              // When the operation started.
+	End        time.Time              // When the operation ended.
+	Attributes map[string]interface{} // Values are strings, int64s or bools.
+	Error      string                 // Error of the operation, if it failed.
+}
+
+// Spans returns a functional option which makes an Attacker trace the given
+// ratio of its hits, from 0 to 1, with OpenTelemetry spans passed to the given
+// function once each of them is done, so that distributed traces capture the
+// view of the load generator end to end. Each traced hit has a client span of
+// its request, parent of the spans of resolving its host (dns), connecting to
+// it (connect), TLS handshaking (tls) and waiting for the first response byte
+// once the request is written (ttfb). Traced requests are sent with a sampled
+// traceparent header propagating their client span, and their trace ID is
+// recorded as the TraceID of their Result. Requests with a traceparent header,
+// of their target or of TraceParent, continue its trace. The function is
+// called concurrently.
+func Spans(ratio float64, export func([]Span)) func(*Attacker) {
+	return func(a *Attacker) {
+		a.spans, a.export = nil, nil
+		if ratio > 0 && export != nil {
+			a.spans = &traceIDs{
+				sampled: ratio,
+				rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
+			}
+			a.export = export
+		}
+	}
+}
+
+// hitSpans are the spans of a traced hit.
+type hitSpans struct {
+	mu    sync.Mutex
+	req   Span
+	spans []Span
+	open  map[string]int // Indices of the spans in progress by name and key.
+	ids   *traceIDs
+}
+
+// startSpans returns the spans of the given request if it's sampled by the
+// given traceIDs, after setting its traceparent header to propagate its client
+// span and tracing its phases, or nil otherwise.
+func startSpans(ids *traceIDs, req *http.Request) (*hitSpans, *http.Request) {
+	c, ok := parseTraceParent(req.Header.Get("Traceparent"))
+	if !ok {
+		c = ids.next()
+	} else {
+		// Requests continuing traces are traced in the same ratio.
+		c.sampled = ids.next().sampled
+	}
+
+	if !c.sampled {
+		return nil, req
+	}
+
+	id := ids.spanID()
+	h := &hitSpans{
+		req: Span{
+			TraceID: hex.EncodeToString(c.traceID[:]),
+			SpanID:  hex.EncodeToString(id[:]),
+			Name:    req.Method,
+			Client:  true,
+			Start:   time.Now(),
+			Attributes: map[string]interface{}{
+				"http.method": req.Method,
+				"http.url":    req.URL.String(),
+			},
+		},
+		open: map[string]int{},
+		ids:  ids,
+	}
+
+	if ok {
+		h.req.ParentID = hex.EncodeToString(c.parentID[:])
+	}
+
+	c.parentID = id
+	req.Header.Set("Traceparent", c.String())
+
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			h.start("dns", "", map[string]interface{}{"net.peer.name": info.Host})
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) { h.end("dns", "", info.Err) },
+		ConnectStart: func(network, addr string) {
+			h.start("connect", addr, map[string]interface{}{"net.transport": network, "net.peer.addr": addr})
+		},
+		ConnectDone:       func(_, addr string, err error) { h.end("connect", addr, err) },
+		TLSHandshakeStart: func() { h.start("tls", "", nil) },
+		TLSHandshakeDone:  func(_ tls.ConnectionState, err error) { h.end("tls", "", err) },
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				h.start("ttfb", "", nil)
+			}
+		},
+		GotFirstResponseByte: func() { h.end("ttfb", "", nil) },
+	}))
+
+	return h, req
+}
+
+// start starts a child span of the request with the given name and key, which
+// tells apart the spans with the same name in progress at once, such as those
+// of connections dialed to several addresses.
+func (h *hitSpans) start(name, key string, attrs map[string]interface{}) {
+	id := h.ids.spanID()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.open[name+" "+key] = len(h.spans)
+	h.spans = append(h.spans, Span{
+		TraceID:    h.req.TraceID,
+		SpanID:     hex.EncodeToString(id[:]),
+		ParentID:   h.req.SpanID,
+		Name:       name,
+		Start:      time.Now(),
+		Attributes: attrs,
+	})
+}
+
+// end ends the child span in progress with the given name and key.
+func (h *hitSpans) end(name, key string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	i, ok := h.open[name+" "+key]
+	if !ok {
+		return
+	}
+
+	delete(h.open, name+" "+key)
+	h.spans[i].End = time.Now()
+	if err != nil {
+		h.spans[i].Error = err.Error()
+	}
+}
+
+// finish ends the spans of the hit with the given Result, and returns them,
+// client span first. Child spans still in progress end with it.
+func (h *hitSpans) finish(res *Result) []Span {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.req.End = time.Now()
+	h.req.Error = res.Error
+	h.req.Attributes["vegeta.seq"] = int64(res.Seq)
+	if res.Code != 0 {
+		h.req.Attributes["http.status_code"] = int64(res.Code)
+	}
+	if res.Attack != "" {
+		h.req.Attributes["vegeta.attack"] = res.Attack
+	}
+
+	for _, i := range h.open {
+		h.spans[i].End = h.req.End
+	}
+
+	return append([]Span{h.req}, h.spans...)
+}
@@ -0,0 +1,78 @@
+package vegeta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestSpans(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Traceparent")))
+	}))
+	defer server.Close()
+
+	var (
+		mu       sync.Mutex
+		exported [][]Span
+	)
+
+	export := func(spans []Span) {
+		mu.Lock()
+		defer mu.Unlock()
+		exported = append(exported, spans)
+	}
+
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+	res := NewAttacker(Spans(1, export), KeepAlive(false)).hit(tr, "spans", 1)
+
+	if len(exported) != 1 {
+		t.Fatalf("got %d exports, want 1", len(exported))
+	}
+
+	spans := exported[0]
+	req := spans[0]
+	if !req.Client || req.Name != "GET" || req.TraceID != res.TraceID || req.ParentID != "" {
+		t.Errorf("got client span %+v, want a root GET span of trace %q", req, res.TraceID)
+	} else if want := "00-" + req.TraceID + "-" + req.SpanID + "-01"; string(res.Body) != want {
+		t.Errorf("got traceparent %q, want %q", res.Body, want)
+	} else if req.Attributes["http.status_code"] != int64(200) || req.Attributes["vegeta.attack"] != "spans" {
+		t.Errorf("got attributes %v", req.Attributes)
+	}
+
+	names := map[string]bool{}
+	for _, s := range spans[1:] {
+		names[s.Name] = true
+		if s.Client || s.TraceID != req.TraceID || s.ParentID != req.SpanID {
+			t.Errorf("got span %+v, want a child of the client span", s)
+		} else if s.Start.Before(req.Start) || s.End.Before(s.Start) || s.End.After(req.End) {
+			t.Errorf("got %s span from %v to %v, want it within %v to %v", s.Name, s.Start, s.End, req.Start, req.End)
+		}
+	}
+
+	// Hosts of IP addresses aren't resolved, and plain HTTP isn't handshaked.
+	for _, name := range []string{"connect", "ttfb"} {
+		if !names[name] {
+			t.Errorf("got spans %v, want a %s span", names, name)
+		}
+	}
+
+	// Targets' own traces are continued.
+	exported = nil
+	own := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tr = NewStaticTargeter(Target{Method: "GET", URL: server.URL, Header: http.Header{"Traceparent": {own}}})
+	res = NewAttacker(Spans(1, export)).hit(tr, "", 1)
+	if req := exported[0][0]; req.TraceID != own[3:35] || req.ParentID != own[36:52] || res.TraceID != req.TraceID {
+		t.Errorf("got client span %+v, want it continuing the trace of %q", req, own)
+	}
+
+	// Unsampled hits aren't traced.
+	exported = nil
+	tr = NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+	if res = NewAttacker(Spans(0, export)).hit(tr, "", 1); len(exported) != 0 || len(res.Body) != 0 || res.TraceID != "" {
+		t.Errorf("got %d exports and traceparent %q with ratio 0", len(exported), res.Body)
+	}
+}
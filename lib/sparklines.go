@@ -0,0 +1,151 @@
+package vegeta
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Sparklines is a Report which tracks the request rate, 99th percentile
+// latency and error ratio of every attack per time bucket of the given
+// Interval, to summarize them as one line of sparklines per attack.
+type Sparklines struct {
+	// Interval is the duration of each time bucket.
+	Interval time.Duration
+
+	attacks map[string]*sparklineAttack
+}
+
+type sparklineAttack struct {
+	total   sparklineBucket
+	buckets map[int64]*sparklineBucket
+}
+
+type sparklineBucket struct {
+	requests, errors uint64
+	latencies        LatencyMetrics
+}
+
+func (b *sparklineBucket) add(r *Result) {
+	b.requests++
+	if r.Code < 200 || r.Code >= 400 || failedAssertion(r) {
+		b.errors++
+	}
+	b.latencies.Add(r.Latency)
+}
+
+func (b *sparklineBucket) errorRatio() float64 {
+	if b.requests == 0 {
+		return 0
+	}
+	return float64(b.errors) / float64(b.requests)
+}
+
+// Add implements the Add method of the Report interface by adding the given
+// Result to the time bucket of its attack.
+func (s *Sparklines) Add(r *Result) {
+	if s.attacks == nil {
+		s.attacks = map[string]*sparklineAttack{}
+	}
+
+	if s.Interval <= 0 {
+		s.Interval = time.Second
+	}
+
+	a, ok := s.attacks[r.Attack]
+	if !ok {
+		a = &sparklineAttack{buckets: map[int64]*sparklineBucket{}}
+		s.attacks[r.Attack] = a
+	}
+
+	ts := r.Timestamp.Truncate(s.Interval).UnixNano()
+	b, ok := a.buckets[ts]
+	if !ok {
+		b = &sparklineBucket{}
+		a.buckets[ts] = b
+	}
+
+	b.add(r)
+	a.total.add(r)
+}
+
+// sparks are the characters of a sparkline, from the lowest to the highest.
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders the given values as a sparkline scaled to their maximum.
+func sparkline(vs []float64) string {
+	var max float64
+	for _, v := range vs {
+		if v > max {
+			max = v
+		}
+	}
+
+	var b strings.Builder
+	for _, v := range vs {
+		i := 0
+		if max > 0 {
+			i = int(v / max * float64(len(sparks)-1))
+		}
+		b.WriteRune(sparks[i])
+	}
+	return b.String()
+}
+
+// NewSparklinesReporter returns a Reporter that writes out Sparklines as one
+// line per attack with sparklines of its request rate, 99th percentile
+// latency and error ratio over time, each followed by its overall value.
+func NewSparklinesReporter(s *Sparklines) Reporter {
+	return func(w io.Writer) error {
+		names := make([]string, 0, len(s.attacks))
+		for name := range s.attacks {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.StripEscape)
+		for _, name := range names {
+			a := s.attacks[name]
+
+			keys := make([]int64, 0, len(a.buckets))
+			for ts := range a.buckets {
+				keys = append(keys, ts)
+			}
+
+			sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+			first, last := keys[0], keys[len(keys)-1]
+			var rates, p99s, errs []float64
+			for ts := first; ts <= last; ts += int64(s.Interval) {
+				b, ok := a.buckets[ts]
+				if !ok {
+					b = &sparklineBucket{}
+				}
+				rates = append(rates, float64(b.requests)/s.Interval.Seconds())
+				p99s = append(p99s, float64(b.latencies.Quantile(0.99)))
+				errs = append(errs, b.errorRatio())
+			}
+
+			span := time.Duration(last-first) + s.Interval
+			if name == "" {
+				name = "-"
+			}
+
+			_, err := fmt.Fprintf(tw, "%s\trate %s %.2f/s\tp99 %s %s\terrors %s %.2f%%\n",
+				name,
+				sparkline(rates), float64(a.total.requests)/span.Seconds(),
+				sparkline(p99s), round(a.total.latencies.Quantile(0.99)),
+				sparkline(errs), a.total.errorRatio()*100,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return tw.Flush()
+	}
+}
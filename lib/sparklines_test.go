@@ -0,0 +1,41 @@
+package vegeta
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSparklines(t *testing.T) {
+	t.Parallel()
+
+	s := Sparklines{Interval: time.Second}
+	start := time.Unix(0, 0)
+	for sec := 0; sec < 4; sec++ {
+		for i := 0; i <= sec; i++ {
+			code := uint16(200)
+			if sec == 3 {
+				code = 500
+			}
+			s.Add(&Result{
+				Attack:    "api",
+				Code:      code,
+				Timestamp: start.Add(time.Duration(sec) * time.Second),
+				Latency:   time.Duration(sec+1) * 10 * time.Millisecond,
+			})
+		}
+	}
+	s.Add(&Result{Attack: "web", Code: 200, Timestamp: start, Latency: time.Millisecond})
+
+	var buf bytes.Buffer
+	if err := NewSparklinesReporter(&s).Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "api  rate ▂▄▆█ 2.50/s  p99 ▂▄▆█ 40ms  errors ▁▁▁█ 40.00%\n" +
+		"web  rate █ 1.00/s     p99 █ 1ms      errors ▁ 0.00%\n"
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
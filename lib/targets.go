@@ -26,6 +26,40 @@ type Target struct {
 	URL    string      `json:"url"`
 	Body   []byte      `json:"body,omitempty"`
 	Header http.Header `json:"header,omitempty"`
+
+	// Cert and Key are the paths of the PEM encoded TLS client certificate
+	// and private key files to present when hitting the Target, if any. Key
+	// defaults to Cert, which can hold both. See ClientCertificates.
+	Cert string `json:"cert,omitempty"`
+	Key  string `json:"key,omitempty"`
+
+	// SNI is the server name to send in TLS handshakes when hitting the
+	// Target, in place of the host of its URL. See ServerName.
+	SNI string `json:"sni,omitempty"`
+
+	// Session identifies the virtual session the Target is hit in, whose
+	// cookies it shares. See SessionCookies.
+	Session string `json:"session,omitempty"`
+
+	// Assert holds the filter expressions of the Assertions which responses
+	// to the Target must meet. See Assertions.
+	Assert []string `json:"assert,omitempty"`
+
+	// Compression is the content coding the body of the Target is compressed
+	// with when it's sent, gzip or zstd, or identity for none, in place of
+	// the one of the Attacker. See CompressBody.
+	Compression string `json:"compression,omitempty"`
+
+	// BodySource, if set, is the source the body of the Target is streamed
+	// from as it's sent, with the chunked transfer encoding, in place of Body,
+	// so that it needn't fit in memory: a file, as file:path, or a number of
+	// random bytes, as random:size, e.g. random:1GB.
+	BodySource string `json:"body_source,omitempty"`
+
+	// Redirects, if set, is the number of redirects followed when hitting the
+	// Target, in place of that of the Attacker, with NoFollow not following
+	// them but marking them successful. See Redirects.
+	Redirects *int `json:"redirects,omitempty"`
 }
 
 // Request creates an *http.Request out of Target and returns it along with an
@@ -35,6 +69,14 @@ func (t *Target) Request() (*http.Request, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	if t.BodySource != "" {
+		if req.Body, err = openBodySource(t.BodySource); err != nil {
+			return nil, err
+		}
+		req.GetBody = func() (io.ReadCloser, error) { return openBodySource(t.BodySource) }
+		req.ContentLength = -1
+	}
 	for k, vs := range t.Header {
 		req.Header[k] = make([]string, len(vs))
 		copy(req.Header[k], vs)
@@ -56,6 +98,15 @@ func (t *Target) Equal(other *Target) bool {
 		equal := t.Method == other.Method &&
 			t.URL == other.URL &&
 			bytes.Equal(t.Body, other.Body) &&
+			t.Cert == other.Cert &&
+			t.Key == other.Key &&
+			t.SNI == other.SNI &&
+			t.Session == other.Session &&
+			t.Compression == other.Compression &&
+			t.BodySource == other.BodySource &&
+			(t.Redirects == nil) == (other.Redirects == nil) &&
+			(t.Redirects == nil || *t.Redirects == *other.Redirects) &&
+			len(t.Assert) == len(other.Assert) &&
 			len(t.Header) == len(other.Header)
 
 		if !equal {
@@ -74,6 +125,12 @@ func (t *Target) Equal(other *Target) bool {
 			}
 		}
 
+		for i := range t.Assert {
+			if t.Assert[i] != other.Assert[i] {
+				return false
+			}
+		}
+
 		return true
 	}
 }
@@ -114,6 +171,13 @@ func (tr Targeter) Decode(t *Target) error {
 // given io.Reader on every invocation. Each target is one JSON object in its own line.
 //
 // The method and url fields are required. If present, the body field must be base64 encoded.
+// The cert and key fields are the paths of the TLS client certificate and key files of the target.
+// The sni field is the TLS server name of the target, in place of its URL host.
+// The session field identifies the virtual session of the target, whose cookies it shares.
+// The assert field lists the assertion expressions which responses to the target must meet.
+// The compression field is the content coding its body is compressed with: gzip, zstd or identity.
+// The body_source field is the source its body is streamed from: file:path or random:size.
+// The redirects field is the number of redirects to follow, with -1 not following them.
 // The generated [JSON Schema](lib/target.schema.json) defines the format in detail.
 //
 //    {"method":"POST", "url":"https://goku/1", "header":{"Content-Type":["text/plain"], "body": "Rk9P"}
@@ -165,6 +229,10 @@ func NewJSONTargeter(src io.Reader, body []byte, header http.Header) Targeter {
 
 		tgt.Method = t.Method
 		tgt.URL = t.URL
+		tgt.Cert, tgt.Key, tgt.SNI = t.Cert, t.Key, t.SNI
+		tgt.Session, tgt.Assert = t.Session, t.Assert
+		tgt.Compression, tgt.BodySource = t.Compression, t.BodySource
+		tgt.Redirects = t.Redirects
 		if tgt.Body = body; len(t.Body) > 0 {
 			tgt.Body = t.Body
 		}
@@ -83,6 +83,51 @@ func (t *jsonTarget) decode(in *jlexer.Lexer) {
 				}
 				in.Delim('}')
 			}
+		case "cert":
+			t.Cert = string(in.String())
+		case "key":
+			t.Key = string(in.String())
+		case "sni":
+			t.SNI = string(in.String())
+		case "session":
+			t.Session = string(in.String())
+		case "compression":
+			t.Compression = string(in.String())
+		case "body_source":
+			t.BodySource = string(in.String())
+		case "redirects":
+			if in.IsNull() {
+				in.Skip()
+				t.Redirects = nil
+			} else {
+				if t.Redirects == nil {
+					t.Redirects = new(int)
+				}
+				*t.Redirects = int(in.Int())
+			}
+		case "assert":
+			if in.IsNull() {
+				in.Skip()
+				t.Assert = nil
+			} else {
+				in.Delim('[')
+				if t.Assert == nil {
+					if !in.IsDelim(']') {
+						t.Assert = make([]string, 0, 4)
+					} else {
+						t.Assert = []string{}
+					}
+				} else {
+					t.Assert = (t.Assert)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v9 string
+					v9 = string(in.String())
+					t.Assert = append(t.Assert, v9)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -163,5 +208,54 @@ func (t jsonTarget) encode(out *jwriter.Writer) {
 			out.RawByte('}')
 		}
 	}
+	if t.Cert != "" {
+		const prefix string = ",\"cert\":"
+		out.RawString(prefix)
+		out.String(string(t.Cert))
+	}
+	if t.Key != "" {
+		const prefix string = ",\"key\":"
+		out.RawString(prefix)
+		out.String(string(t.Key))
+	}
+	if t.SNI != "" {
+		const prefix string = ",\"sni\":"
+		out.RawString(prefix)
+		out.String(string(t.SNI))
+	}
+	if t.Session != "" {
+		const prefix string = ",\"session\":"
+		out.RawString(prefix)
+		out.String(string(t.Session))
+	}
+	if len(t.Assert) != 0 {
+		const prefix string = ",\"assert\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v10, v11 := range t.Assert {
+				if v10 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v11))
+			}
+			out.RawByte(']')
+		}
+	}
+	if t.Compression != "" {
+		const prefix string = ",\"compression\":"
+		out.RawString(prefix)
+		out.String(string(t.Compression))
+	}
+	if t.BodySource != "" {
+		const prefix string = ",\"body_source\":"
+		out.RawString(prefix)
+		out.String(string(t.BodySource))
+	}
+	if t.Redirects != nil {
+		const prefix string = ",\"redirects\":"
+		out.RawString(prefix)
+		out.Int(int(*t.Redirects))
+	}
 	out.RawByte('}')
 }
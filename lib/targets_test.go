@@ -63,6 +63,8 @@ func TestJSONTargeter(t *testing.T) {
 		return strings.NewReader(s + "\n")
 	}
 
+	follow, noFollow := 3, NoFollow
+
 	for _, tc := range []struct {
 		name string
 		src  io.Reader
@@ -141,6 +143,48 @@ func TestJSONTargeter(t *testing.T) {
 			in:   &Target{},
 			out:  &Target{Method: "GET", URL: "http://goku", Header: http.Header{"x": []string{"foo"}}, Body: []byte("ATTACK!")},
 		},
+		{
+			name: "client certificate",
+			src:  target(`{"method": "GET", "url": "https://goku", "cert": "client.pem", "key": "client.key"}`),
+			in:   &Target{Cert: "other.pem"},
+			out:  &Target{Method: "GET", URL: "https://goku", Cert: "client.pem", Key: "client.key"},
+		},
+		{
+			name: "server name",
+			src:  target(`{"method": "GET", "url": "https://10.0.0.1", "sni": "goku"}`),
+			in:   &Target{SNI: "other"},
+			out:  &Target{Method: "GET", URL: "https://10.0.0.1", SNI: "goku"},
+		},
+		{
+			name: "compression",
+			src:  target(`{"method": "POST", "url": "https://goku", "body": "Rk9P", "compression": "gzip"}`),
+			in:   &Target{Compression: "zstd"},
+			out:  &Target{Method: "POST", URL: "https://goku", Body: []byte("FOO"), Compression: "gzip"},
+		},
+		{
+			name: "body source",
+			src:  target(`{"method": "PUT", "url": "https://goku", "body_source": "random:1GB"}`),
+			in:   &Target{BodySource: "file:old"},
+			out:  &Target{Method: "PUT", URL: "https://goku", BodySource: "random:1GB"},
+		},
+		{
+			name: "redirects",
+			src:  target(`{"method": "GET", "url": "https://goku", "redirects": -1}`),
+			in:   &Target{Redirects: &follow},
+			out:  &Target{Method: "GET", URL: "https://goku", Redirects: &noFollow},
+		},
+		{
+			name: "session",
+			src:  target(`{"method": "GET", "url": "https://goku", "session": "user-1"}`),
+			in:   &Target{Session: "user-2"},
+			out:  &Target{Method: "GET", URL: "https://goku", Session: "user-1"},
+		},
+		{
+			name: "assert",
+			src:  target(`{"method": "GET", "url": "https://goku", "assert": ["code == 200", "$.power > 9000"]}`),
+			in:   &Target{Assert: []string{"code == 201"}},
+			out:  &Target{Method: "GET", URL: "https://goku", Assert: []string{"code == 200", "$.power > 9000"}},
+		},
 		{
 			name: "skips empty lines and surrounding whitespace",
 			src: strings.NewReader(`
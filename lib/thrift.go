@@ -0,0 +1,273 @@
+package vegeta
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Thrift compact protocol types, used to encode Parquet metadata.
+const (
+	thriftStop   = 0
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI16    = 4
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftDouble = 7
+	thriftBinary = 8
+	thriftList   = 9
+	thriftSet    = 10
+	thriftMap    = 11
+	thriftStruct = 12
+)
+
+// thriftWriter writes structs in the Thrift compact protocol.
+type thriftWriter struct {
+	buf []byte
+	ids []int16 // last field id of each open struct
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	w.buf = append(w.buf, make([]byte, binary.MaxVarintLen64)...)
+	n := binary.PutUvarint(w.buf[len(w.buf)-binary.MaxVarintLen64:], v)
+	w.buf = w.buf[:len(w.buf)-binary.MaxVarintLen64+n]
+}
+
+func (w *thriftWriter) zigzag(v int64) {
+	w.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	last := &w.ids[len(w.ids)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.zigzag(int64(id))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.zigzag(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.zigzag(v)
+}
+
+func (w *thriftWriter) bool(id int16, v bool) {
+	if v {
+		w.field(id, thriftTrue)
+	} else {
+		w.field(id, thriftFalse)
+	}
+}
+
+func (w *thriftWriter) string(id int16, s string) {
+	w.field(id, thriftBinary)
+	w.varint(uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+// list writes the header of a list field of n elements of the given type.
+func (w *thriftWriter) list(id int16, typ byte, n int) {
+	w.field(id, thriftList)
+	if n < 15 {
+		w.buf = append(w.buf, byte(n)<<4|typ)
+	} else {
+		w.buf = append(w.buf, 0xf0|typ)
+		w.varint(uint64(n))
+	}
+}
+
+// begin opens a struct, either as a field when id > 0, or as a top level
+// value or list element otherwise.
+func (w *thriftWriter) begin(id int16) {
+	if id > 0 {
+		w.field(id, thriftStruct)
+	}
+	w.ids = append(w.ids, 0)
+}
+
+// end closes the last opened struct.
+func (w *thriftWriter) end() {
+	w.buf = append(w.buf, thriftStop)
+	w.ids = w.ids[:len(w.ids)-1]
+}
+
+// thriftFields is a decoded Thrift struct, keyed by field id. Integers
+// are decoded as int64, binaries as []byte, lists and sets as
+// []interface{} and structs as thriftFields.
+type thriftFields map[int16]interface{}
+
+func (f thriftFields) int(id int16) int64 {
+	v, _ := f[id].(int64)
+	return v
+}
+
+func (f thriftFields) bytes(id int16) []byte {
+	v, _ := f[id].([]byte)
+	return v
+}
+
+func (f thriftFields) list(id int16) []interface{} {
+	v, _ := f[id].([]interface{})
+	return v
+}
+
+func (f thriftFields) fields(id int16) thriftFields {
+	v, _ := f[id].(thriftFields)
+	return v
+}
+
+var errThrift = errors.New("thrift: malformed compact protocol data")
+
+// thriftReader reads values in the Thrift compact protocol.
+type thriftReader struct {
+	buf []byte
+	off int
+}
+
+func (r *thriftReader) byte() (byte, error) {
+	if r.off >= len(r.buf) {
+		return 0, errThrift
+	}
+	b := r.buf[r.off]
+	r.off++
+	return b, nil
+}
+
+func (r *thriftReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.buf[r.off:])
+	if n <= 0 {
+		return 0, errThrift
+	}
+	r.off += n
+	return v, nil
+}
+
+func (r *thriftReader) zigzag() (int64, error) {
+	v, err := r.varint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+// fields reads a struct.
+func (r *thriftReader) fields() (thriftFields, error) {
+	f := thriftFields{}
+	var id int16
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		typ := b & 0x0f
+		if typ == thriftStop {
+			return f, nil
+		}
+
+		if delta := int16(b >> 4); delta != 0 {
+			id += delta
+		} else {
+			v, err := r.zigzag()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+
+		if f[id], err = r.value(typ); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *thriftReader) value(typ byte) (interface{}, error) {
+	switch typ {
+	case thriftTrue:
+		return true, nil
+	case thriftFalse:
+		return false, nil
+	case thriftByte:
+		b, err := r.byte()
+		return int64(int8(b)), err
+	case thriftI16, thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftDouble:
+		if r.off+8 > len(r.buf) {
+			return nil, errThrift
+		}
+		r.off += 8
+		return nil, nil
+	case thriftBinary:
+		n, err := r.varint()
+		if err != nil {
+			return nil, err
+		} else if uint64(len(r.buf)-r.off) < n {
+			return nil, errThrift
+		}
+		b := r.buf[r.off : r.off+int(n)]
+		r.off += int(n)
+		return b, nil
+	case thriftList, thriftSet:
+		b, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		n, elem := uint64(b>>4), b&0x0f
+		if n == 15 {
+			if n, err = r.varint(); err != nil {
+				return nil, err
+			}
+		}
+
+		if n > uint64(len(r.buf)-r.off) { // Every element takes at least a byte.
+			return nil, errThrift
+		}
+
+		vs := make([]interface{}, n)
+		for i := range vs {
+			if elem == thriftTrue || elem == thriftFalse {
+				b, err := r.byte()
+				vs[i] = b == thriftTrue
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if vs[i], err = r.value(elem); err != nil {
+				return nil, err
+			}
+		}
+		return vs, nil
+	case thriftMap:
+		n, err := r.varint()
+		if err != nil || n == 0 {
+			return nil, err
+		}
+
+		kv, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		for i := uint64(0); i < n; i++ {
+			if _, err = r.value(kv >> 4); err != nil {
+				return nil, err
+			}
+			if _, err = r.value(kv & 0x0f); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case thriftStruct:
+		return r.fields()
+	default:
+		return nil, errThrift
+	}
+}
@@ -0,0 +1,162 @@
+package vegeta
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// StatusTimeline is a Report which counts status codes per time bucket of
+// the given Interval, so that it's visible when a given status code
+// started being returned during an attack. The peak resource usage of the
+// hosts added with AddUsage is shown alongside, so that it's visible too
+// when a target host started being saturated.
+type StatusTimeline struct {
+	// Interval is the duration of each time bucket.
+	Interval time.Duration
+
+	buckets map[int64]map[uint16]uint64
+	codes   map[uint16]struct{}
+	usage   map[int64]map[string]HostUsage
+	sources map[string]struct{}
+}
+
+// bucket returns the time bucket of the given time.
+func (t *StatusTimeline) bucket(ts time.Time) int64 {
+	if t.Interval <= 0 {
+		t.Interval = time.Second
+	}
+	return ts.Truncate(t.Interval).UnixNano()
+}
+
+// Add implements the Add method of the Report interface by counting the
+// status code of the given Result in its time bucket.
+func (t *StatusTimeline) Add(r *Result) {
+	if t.buckets == nil {
+		t.buckets = map[int64]map[uint16]uint64{}
+		t.codes = map[uint16]struct{}{}
+	}
+
+	ts := t.bucket(r.Timestamp)
+	b, ok := t.buckets[ts]
+	if !ok {
+		b = map[uint16]uint64{}
+		t.buckets[ts] = b
+	}
+
+	b[r.Code]++
+	t.codes[r.Code] = struct{}{}
+}
+
+// AddUsage adds the resource usage of the given source host, sampled at the
+// given time, to its time bucket, which keeps the peak usage of each source.
+func (t *StatusTimeline) AddUsage(source string, at time.Time, u HostUsage) {
+	if t.usage == nil {
+		t.usage = map[int64]map[string]HostUsage{}
+		t.sources = map[string]struct{}{}
+	}
+
+	ts := t.bucket(at)
+	b, ok := t.usage[ts]
+	if !ok {
+		b = map[string]HostUsage{}
+		t.usage[ts] = b
+	}
+
+	peak := b[source]
+	peak.CPU = math.Max(peak.CPU, u.CPU)
+	peak.Memory = math.Max(peak.Memory, u.Memory)
+	if u.Connections > peak.Connections {
+		peak.Connections = u.Connections
+	}
+
+	b[source] = peak
+	t.sources[source] = struct{}{}
+}
+
+// NewStatusTimelineReporter returns a Reporter that writes out a StatusTimeline
+// as aligned, formatted text with a row per time bucket, from the first to the
+// last, a column per status code, and CPU, memory and connections columns
+// per source host of usage, if any.
+func NewStatusTimelineReporter(t *StatusTimeline) Reporter {
+	return func(w io.Writer) error {
+		codes := make([]int, 0, len(t.codes))
+		for code := range t.codes {
+			codes = append(codes, int(code))
+		}
+
+		sort.Ints(codes)
+
+		sources := make([]string, 0, len(t.sources))
+		for source := range t.sources {
+			sources = append(sources, source)
+		}
+
+		sort.Strings(sources)
+
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.StripEscape)
+		if _, err := fmt.Fprint(tw, "Time\tElapsed"); err != nil {
+			return err
+		}
+
+		for _, code := range codes {
+			if _, err := fmt.Fprint(tw, "\t"+strconv.Itoa(code)); err != nil {
+				return err
+			}
+		}
+
+		for _, source := range sources {
+			if _, err := fmt.Fprintf(tw, "\t%[1]s CPU\t%[1]s Mem\t%[1]s Conns", source); err != nil {
+				return err
+			}
+		}
+
+		if _, err := fmt.Fprintln(tw); err != nil {
+			return err
+		}
+
+		if len(t.buckets) == 0 && len(t.usage) == 0 {
+			return tw.Flush()
+		}
+
+		keys := make([]int64, 0, len(t.buckets)+len(t.usage))
+		for ts := range t.buckets {
+			keys = append(keys, ts)
+		}
+		for ts := range t.usage {
+			keys = append(keys, ts)
+		}
+
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+		first, last := keys[0], keys[len(keys)-1]
+		for ts := first; ts <= last; ts += int64(t.Interval) {
+			row := time.Unix(0, ts).UTC().Format(time.RFC3339Nano) + "\t" +
+				time.Duration(ts-first).String()
+
+			b := t.buckets[ts]
+			for _, code := range codes {
+				row += "\t" + strconv.FormatUint(b[uint16(code)], 10)
+			}
+
+			for _, source := range sources {
+				u, ok := t.usage[ts][source]
+				if !ok {
+					row += "\t-\t-\t-"
+					continue
+				}
+				row += fmt.Sprintf("\t%.0f%%\t%.0f%%\t%d", u.CPU*100, u.Memory*100, u.Connections)
+			}
+
+			if _, err := fmt.Fprintln(tw, row); err != nil {
+				return err
+			}
+		}
+
+		return tw.Flush()
+	}
+}
@@ -0,0 +1,77 @@
+package vegeta
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusTimeline(t *testing.T) {
+	t.Parallel()
+
+	tl := StatusTimeline{Interval: time.Second}
+	began := time.Unix(1584198566, 0)
+	for i := 0; i < 40; i++ {
+		r := Result{Code: 200, Timestamp: began.Add(time.Duration(i) * 100 * time.Millisecond)}
+		if i >= 30 {
+			// Skip the third second to test empty buckets.
+			r.Timestamp = r.Timestamp.Add(time.Second)
+			if i >= 35 {
+				r.Code = 503
+			}
+		}
+		tl.Add(&r)
+	}
+
+	var buf bytes.Buffer
+	if err := NewStatusTimelineReporter(&tl).Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"Time                  Elapsed  200  503",
+		"2020-03-14T15:09:26Z  0s       10   0",
+		"2020-03-14T15:09:27Z  1s       10   0",
+		"2020-03-14T15:09:28Z  2s       10   0",
+		"2020-03-14T15:09:29Z  3s       0    0",
+		"2020-03-14T15:09:30Z  4s       5    5",
+		"",
+	}, "\n")
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestStatusTimelineUsage(t *testing.T) {
+	t.Parallel()
+
+	tl := StatusTimeline{Interval: time.Second}
+	began := time.Unix(1584198566, 0)
+	for i := 0; i < 20; i++ {
+		tl.Add(&Result{Code: 200, Timestamp: began.Add(time.Duration(i) * 100 * time.Millisecond)})
+	}
+
+	tl.AddUsage("target:8098", began, HostUsage{CPU: 0.2, Memory: 0.5, Connections: 10})
+	tl.AddUsage("target:8098", began.Add(500*time.Millisecond), HostUsage{CPU: 0.4, Memory: 0.4, Connections: 5})
+	tl.AddUsage("attacker", began.Add(time.Second), HostUsage{CPU: 0.1, Memory: 0.3, Connections: 2})
+	tl.AddUsage("target:8098", began.Add(2*time.Second), HostUsage{CPU: 0.9, Memory: 0.6, Connections: 80})
+
+	var buf bytes.Buffer
+	if err := NewStatusTimelineReporter(&tl).Report(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"Time                  Elapsed  200  attacker CPU  attacker Mem  attacker Conns  target:8098 CPU  target:8098 Mem  target:8098 Conns",
+		"2020-03-14T15:09:26Z  0s       10   -             -             -               40%              50%              10",
+		"2020-03-14T15:09:27Z  1s       10   10%           30%           2               -                -                -",
+		"2020-03-14T15:09:28Z  2s       0    -             -             -               90%              60%              80",
+		"",
+	}, "\n")
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,121 @@
+package vegeta
+
+import (
+	"encoding/hex"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TraceParent returns a functional option which makes an Attacker send each
+// request with a W3C Trace Context traceparent header of a new trace, whose
+// ID is recorded as the TraceID of its Result, so that slow requests can be
+// looked up in the tracing backend of the target. The given ratio of them,
+// from 0 to 1, is flagged as sampled, which tracing backends keep. Requests
+// with their own traceparent header are sent with it, and its trace ID is
+// recorded.
+func TraceParent(enabled bool, sampled float64) func(*Attacker) {
+	return func(a *Attacker) {
+		a.traces = nil
+		if enabled {
+			a.traces = &traceIDs{
+				sampled: sampled,
+				rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
+			}
+		}
+	}
+}
+
+// traceIDs generates the IDs of traces and their spans.
+type traceIDs struct {
+	sampled float64
+	mu      sync.Mutex
+	rnd     *rand.Rand
+}
+
+// traceContext is the W3C Trace Context of a request.
+type traceContext struct {
+	traceID  [16]byte
+	parentID [8]byte
+	sampled  bool
+}
+
+// String returns the traceparent header value of the traceContext.
+func (c traceContext) String() string {
+	flags := "-00"
+	if c.sampled {
+		flags = "-01"
+	}
+	return "00-" + hex.EncodeToString(c.traceID[:]) + "-" + hex.EncodeToString(c.parentID[:]) + flags
+}
+
+// next returns the traceContext of a new trace.
+func (t *traceIDs) next() traceContext {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var c traceContext
+	for c.traceID == ([16]byte{}) {
+		t.rnd.Read(c.traceID[:])
+	}
+	for c.parentID == ([8]byte{}) {
+		t.rnd.Read(c.parentID[:])
+	}
+	c.sampled = t.rnd.Float64() < t.sampled
+
+	return c
+}
+
+// spanID returns a new span ID.
+func (t *traceIDs) spanID() (id [8]byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id == ([8]byte{}) {
+		t.rnd.Read(id[:])
+	}
+	return id
+}
+
+// inject sets the traceparent header of the given request to that of a new
+// trace, unless it has its own, and returns its trace ID.
+func (t *traceIDs) inject(req *http.Request) string {
+	if id, ok := traceID(req.Header.Get("Traceparent")); ok {
+		return id
+	}
+
+	c := t.next()
+	req.Header.Set("Traceparent", c.String())
+	return hex.EncodeToString(c.traceID[:])
+}
+
+// traceID returns the trace ID of the given traceparent header value, if
+// it's valid.
+func traceID(traceparent string) (string, bool) {
+	c, ok := parseTraceParent(traceparent)
+	if !ok {
+		return "", false
+	}
+	return hex.EncodeToString(c.traceID[:]), true
+}
+
+// parseTraceParent parses the given traceparent header value, formatted as
+// version-trace_id-parent_id-flags, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func parseTraceParent(traceparent string) (c traceContext, ok bool) {
+	if len(traceparent) < 55 || traceparent[2] != '-' || traceparent[35] != '-' || traceparent[52] != '-' {
+		return c, false
+	}
+
+	flags, err := hex.DecodeString(traceparent[53:55])
+	if err != nil {
+		return c, false
+	} else if _, err = hex.Decode(c.traceID[:], []byte(traceparent[3:35])); err != nil {
+		return c, false
+	} else if _, err = hex.Decode(c.parentID[:], []byte(traceparent[36:52])); err != nil {
+		return c, false
+	}
+
+	c.sampled = flags[0]&1 == 1
+	return c, c.traceID != [16]byte{}
+}
@@ -0,0 +1,73 @@
+package vegeta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestTraceParent(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Traceparent")))
+	}))
+	defer server.Close()
+
+	valid := regexp.MustCompile(`^00-([0-9a-f]{32})-[0-9a-f]{16}-0([01])$`)
+	tr := NewStaticTargeter(Target{Method: "GET", URL: server.URL})
+
+	for _, tc := range []struct {
+		sampled float64
+		flag    string
+	}{
+		{1, "1"},
+		{0, "0"},
+	} {
+		atk := NewAttacker(TraceParent(true, tc.sampled))
+		seen := map[string]bool{}
+		for i := 0; i < 10; i++ {
+			res := atk.hit(tr, "", 1)
+			m := valid.FindStringSubmatch(string(res.Body))
+			if m == nil {
+				t.Fatalf("got invalid traceparent %q", res.Body)
+			} else if m[1] != res.TraceID || seen[res.TraceID] {
+				t.Errorf("got trace ID %q of traceparent %q, want it recorded and new", res.TraceID, res.Body)
+			} else if m[2] != tc.flag {
+				t.Errorf("got traceparent %q with ratio %v, want flags 0%s", res.Body, tc.sampled, tc.flag)
+			}
+			seen[res.TraceID] = true
+		}
+	}
+
+	// Targets' own traceparent headers are kept.
+	own := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tr = NewStaticTargeter(Target{Method: "GET", URL: server.URL, Header: http.Header{"Traceparent": {own}}})
+	if res := NewAttacker(TraceParent(true, 1)).hit(tr, "", 1); string(res.Body) != own || res.TraceID != own[3:35] {
+		t.Errorf("got traceparent %q with trace ID %q, want the target's own", res.Body, res.TraceID)
+	}
+
+	if res := NewAttacker(TraceParent(false, 1)).hit(NewStaticTargeter(Target{Method: "GET", URL: server.URL}), "", 1); len(res.Body) != 0 || res.TraceID != "" {
+		t.Errorf("got traceparent %q with trace ID %q when disabled", res.Body, res.TraceID)
+	}
+}
+
+func TestTraceID(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		in string
+		id string
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", ""},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01", ""},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736", ""},
+		{"", ""},
+	} {
+		if id, ok := traceID(tc.in); id != tc.id || ok != (tc.id != "") {
+			t.Errorf("traceID(%q) = %q, %t, want %q", tc.in, id, ok, tc.id)
+		}
+	}
+}
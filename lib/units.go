@@ -0,0 +1,116 @@
+package vegeta
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Units configures the units durations and byte sizes are formatted in by
+// the text and markdown reporters.
+type Units struct {
+	// Duration is the unit all durations are formatted in, e.g. time.Millisecond.
+	// When zero, each duration is formatted in the unit best suited to its value.
+	Duration time.Duration
+	// Bytes is the unit all byte sizes are formatted in, e.g. Megabyte.
+	// When zero, each byte size is formatted in the unit best suited to its value.
+	Bytes ByteUnit
+}
+
+// ByteUnit is a unit of byte sizes.
+type ByteUnit uint64
+
+// Decimal byte size units.
+const (
+	Byte     ByteUnit = 1
+	Kilobyte          = 1000 * Byte
+	Megabyte          = 1000 * Kilobyte
+	Gigabyte          = 1000 * Megabyte
+)
+
+var byteUnits = []struct {
+	unit ByteUnit
+	name string
+}{
+	{Gigabyte, "GB"},
+	{Megabyte, "MB"},
+	{Kilobyte, "KB"},
+	{Byte, "B"},
+}
+
+var durationUnits = []struct {
+	unit time.Duration
+	name string
+}{
+	{time.Hour, "h"},
+	{time.Minute, "m"},
+	{time.Second, "s"},
+	{time.Millisecond, "ms"},
+	{time.Microsecond, "µs"},
+	{time.Nanosecond, "ns"},
+}
+
+// ParseByteUnit parses a byte unit (B, KB, MB or GB), or "auto" for
+// the zero value which scales units per value.
+func ParseByteUnit(s string) (ByteUnit, error) {
+	if s == "auto" {
+		return 0, nil
+	}
+
+	for _, u := range byteUnits {
+		if strings.EqualFold(s, u.name) {
+			return u.unit, nil
+		}
+	}
+
+	return 0, fmt.Errorf("bad byte unit %q, want one of auto, B, KB, MB, GB", s)
+}
+
+// ParseDurationUnit parses a duration unit (ns, us, µs, ms, s, m or h), or
+// "auto" for the zero value which scales units per value.
+func ParseDurationUnit(s string) (time.Duration, error) {
+	if s == "auto" {
+		return 0, nil
+	} else if s == "us" {
+		return time.Microsecond, nil
+	}
+
+	for _, u := range durationUnits {
+		if s == u.name {
+			return u.unit, nil
+		}
+	}
+
+	return 0, fmt.Errorf("bad duration unit %q, want one of auto, ns, us, ms, s, m, h", s)
+}
+
+// duration formats the given duration in the configured unit.
+func (u Units) duration(d time.Duration) string {
+	if u.Duration <= 0 {
+		return round(d).String()
+	}
+
+	for _, du := range durationUnits {
+		if du.unit == u.Duration {
+			return formatFloat(float64(d)/float64(du.unit)) + du.name
+		}
+	}
+
+	return round(d).String()
+}
+
+// bytes formats the given byte size in the configured unit.
+func (u Units) bytes(n float64) string {
+	for _, bu := range byteUnits {
+		if u.Bytes == bu.unit || (u.Bytes == 0 && (n >= float64(bu.unit) || bu.unit == Byte)) {
+			v := n / float64(bu.unit)
+			if bu.unit == Byte && v == float64(uint64(v)) {
+				return strconv.FormatUint(uint64(v), 10) + " B"
+			}
+			return strconv.FormatFloat(v, 'f', 2, 64) + " " + bu.name
+		}
+	}
+
+	return strconv.FormatFloat(n, 'f', 2, 64) + " B"
+}
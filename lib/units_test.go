@@ -0,0 +1,75 @@
+package vegeta
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUnitsBytes(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		units Units
+		in    float64
+		want  string
+	}{
+		{Units{}, 0, "0 B"},
+		{Units{}, 999, "999 B"},
+		{Units{}, 12.5, "12.50 B"},
+		{Units{}, 1000, "1.00 KB"},
+		{Units{}, 1234567, "1.23 MB"},
+		{Units{}, 5e9, "5.00 GB"},
+		{Units{Bytes: Byte}, 1234567, "1234567 B"},
+		{Units{Bytes: Kilobyte}, 1234567, "1234.57 KB"},
+		{Units{Bytes: Gigabyte}, 1234567, "0.00 GB"},
+	} {
+		if got := tc.units.bytes(tc.in); got != tc.want {
+			t.Errorf("%+v.bytes(%v) = %q, want %q", tc.units, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestUnitsDuration(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		units Units
+		in    time.Duration
+		want  string
+	}{
+		{Units{}, 1234567 * time.Nanosecond, "1.235ms"},
+		{Units{}, 2 * time.Second, "2s"},
+		{Units{Duration: time.Millisecond}, 2 * time.Second, "2000ms"},
+		{Units{Duration: time.Millisecond}, 1234567 * time.Nanosecond, "1.2346ms"},
+		{Units{Duration: time.Second}, 1500 * time.Microsecond, "0.0015s"},
+		{Units{Duration: time.Microsecond}, time.Millisecond, "1000µs"},
+	} {
+		if got := tc.units.duration(tc.in); got != tc.want {
+			t.Errorf("%+v.duration(%v) = %q, want %q", tc.units, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseUnits(t *testing.T) {
+	t.Parallel()
+
+	if u, err := ParseByteUnit("mb"); err != nil || u != Megabyte {
+		t.Errorf("ParseByteUnit(mb) = %v, %v, want %v", u, err, Megabyte)
+	}
+
+	if u, err := ParseByteUnit("auto"); err != nil || u != 0 {
+		t.Errorf("ParseByteUnit(auto) = %v, %v, want 0", u, err)
+	}
+
+	if _, err := ParseByteUnit("TB"); err == nil {
+		t.Error("ParseByteUnit(TB) returned no error")
+	}
+
+	if u, err := ParseDurationUnit("us"); err != nil || u != time.Microsecond {
+		t.Errorf("ParseDurationUnit(us) = %v, %v, want %v", u, err, time.Microsecond)
+	}
+
+	if _, err := ParseDurationUnit("d"); err == nil {
+		t.Error("ParseDurationUnit(d) returned no error")
+	}
+}
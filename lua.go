@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// luaScript calls the hooks of a -script in sandboxed Lua interpreters.
+// Interpreters aren't safe for concurrent use, so every hit borrows one from
+// a pool of them, each of which has run the script once.
+type luaScript struct {
+	proto   *lua.FunctionProto
+	timeout time.Duration
+
+	mu   sync.Mutex
+	free []*lua.LState
+}
+
+// luaLibs are the libraries open to scripts, which can't touch the host.
+var luaLibs = []struct {
+	name string
+	open lua.LGFunction
+}{
+	{lua.BaseLibName, lua.OpenBase},
+	{lua.TabLibName, lua.OpenTable},
+	{lua.StringLibName, lua.OpenString},
+	{lua.MathLibName, lua.OpenMath},
+}
+
+// luaUnsafe are the functions of the base library which load files or
+// modules, or write to the standard output carrying the results.
+var luaUnsafe = []string{"dofile", "loadfile", "module", "require", "_printregs"}
+
+// loadScript compiles the Lua script of the given file and runs it once, so
+// that its errors are reported before the attack begins. Calls to its hooks
+// which take longer than the given timeout fail, unless it's zero.
+func loadScript(filename string, timeout time.Duration) (*luaScript, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("script: %v", err)
+	}
+	defer f.Close()
+
+	chunk, err := parse.Parse(f, filename)
+	if err != nil {
+		return nil, fmt.Errorf("script: %v", err)
+	}
+
+	proto, err := lua.Compile(chunk, filename)
+	if err != nil {
+		return nil, fmt.Errorf("script: %v", err)
+	}
+
+	s := &luaScript{proto: proto, timeout: timeout}
+	L, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+	s.put(L)
+
+	return s, nil
+}
+
+// get returns a free interpreter, or a new one which has run the script.
+func (s *luaScript) get() (*lua.LState, error) {
+	s.mu.Lock()
+	if n := len(s.free); n > 0 {
+		L := s.free[n-1]
+		s.free = s.free[:n-1]
+		s.mu.Unlock()
+		return L, nil
+	}
+	s.mu.Unlock()
+
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range luaLibs {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+
+	for _, name := range luaUnsafe {
+		L.SetGlobal(name, lua.LNil)
+	}
+	L.SetGlobal("print", L.NewFunction(luaPrint))
+
+	cancel := s.deadline(L)
+	defer cancel()
+
+	L.Push(L.NewFunctionFromProto(s.proto))
+	if err := L.PCall(0, 0, nil); err != nil {
+		L.Close()
+		return nil, fmt.Errorf("script: %v", err)
+	}
+
+	return L, nil
+}
+
+// put returns the given interpreter to the pool.
+func (s *luaScript) put(L *lua.LState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.free = append(s.free, L)
+}
+
+// deadline interrupts the given interpreter after the timeout, until the
+// returned function is called.
+func (s *luaScript) deadline(L *lua.LState) context.CancelFunc {
+	if s.timeout <= 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	L.SetContext(ctx)
+	return func() {
+		L.RemoveContext()
+		cancel()
+	}
+}
+
+// Close closes all the interpreters of the script.
+func (s *luaScript) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, L := range s.free {
+		L.Close()
+	}
+	s.free = nil
+	return nil
+}
+
+// call calls the hook of the given name, if the script defines one, with the
+// given JSON value as a Lua table, whose given fields are tables even when
+// null or left out, and returns the table it returns, or else the one it was
+// called with, as JSON. Scripts which don't define the hook return a nil value.
+func (s *luaScript) call(hook string, value json.RawMessage, tables ...string) (json.RawMessage, error) {
+	L, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+
+	fn, ok := L.GetGlobal(hook).(*lua.LFunction)
+	if !ok {
+		s.put(L)
+		return nil, nil
+	}
+
+	arg, err := luaValue(L, value, tables)
+	if err != nil {
+		s.put(L)
+		return nil, err
+	}
+
+	cancel := s.deadline(L)
+	err = L.CallByParam(lua.P{Fn: fn, NRet: 1, Protect: true}, arg)
+	cancel()
+
+	// Interpreters are dropped on errors, which may have left them
+	// interrupted half way through.
+	if err != nil {
+		L.Close()
+		return nil, luaError(err)
+	}
+
+	ret := L.Get(-1)
+	L.Pop(1)
+	s.put(L)
+
+	if ret == lua.LNil {
+		ret = arg
+	}
+
+	return jsonValue(ret)
+}
+
+// beforeRequest calls the beforeRequest hook of the script with the given
+// request, as a target table, and applies the target it returns or changes,
+// failing the request with the error it raises, if any.
+func (s *luaScript) beforeRequest(_ *vegeta.Target, req *http.Request) error {
+	tgt, err := requestTarget(req)
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(&tgt)
+	if err != nil {
+		return err
+	}
+
+	if value, err = s.call("beforeRequest", value, "header"); err != nil || value == nil {
+		return err
+	}
+
+	tgt = vegeta.Target{}
+	if err = json.Unmarshal(value, &tgt); err != nil {
+		return fmt.Errorf("script: bad target: %v", err)
+	}
+
+	return setTarget(req, &tgt)
+}
+
+// afterResponse calls the afterResponse hook of the script with the given
+// result, as a table, and merges the one it returns or changes into it, or
+// else fails it with the error it raises, if any.
+func (s *luaScript) afterResponse(r *vegeta.Result) {
+	value, err := json.Marshal(r)
+	if err == nil {
+		value, err = s.call("afterResponse", value, "headers", "request_headers", "labels")
+	}
+
+	if err == nil && value != nil {
+		if err = mergeResult(r, value); err != nil {
+			err = fmt.Errorf("script: bad result: %v", err)
+		}
+	}
+
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// luaBodies are the fields of targets and results whose bytes are base64
+// encoded in JSON, and passed to scripts as they are.
+var luaBodies = []string{"body", "request_body"}
+
+// luaValue returns the Lua table of the given JSON object, with the given
+// fields set to empty tables unless they're objects.
+func luaValue(L *lua.LState, value json.RawMessage, tables []string) (lua.LValue, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(value, &fields); err != nil {
+		return nil, err
+	}
+
+	for _, name := range tables {
+		if _, ok := fields[name].(map[string]interface{}); !ok {
+			fields[name] = map[string]interface{}{}
+		}
+	}
+
+	for _, name := range luaBodies {
+		if s, ok := fields[name].(string); ok {
+			body, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return nil, err
+			}
+			fields[name] = string(body)
+		}
+	}
+
+	return toLua(L, fields), nil
+}
+
+// jsonValue returns the JSON object of the given Lua table.
+func jsonValue(v lua.LValue) (json.RawMessage, error) {
+	if _, ok := v.(*lua.LTable); !ok {
+		return nil, fmt.Errorf("script: got a %s, want a table", v.Type())
+	}
+
+	value, err := fromLua(v)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, _ := value.(map[string]interface{})
+	if fields == nil {
+		fields = map[string]interface{}{}
+	}
+
+	for _, name := range luaBodies {
+		if s, ok := fields[name].(string); ok {
+			fields[name] = base64.StdEncoding.EncodeToString([]byte(s))
+		}
+	}
+
+	return json.Marshal(fields)
+}
+
+func toLua(L *lua.LState, v interface{}) lua.LValue {
+	switch v := v.(type) {
+	case bool:
+		return lua.LBool(v)
+	case float64:
+		return lua.LNumber(v)
+	case string:
+		return lua.LString(v)
+	case []interface{}:
+		t := L.CreateTable(len(v), 0)
+		for _, e := range v {
+			t.Append(toLua(L, e))
+		}
+		return t
+	case map[string]interface{}:
+		t := L.CreateTable(0, len(v))
+		for k, e := range v {
+			t.RawSetString(k, toLua(L, e))
+		}
+		return t
+	default:
+		return lua.LNil
+	}
+}
+
+// fromLua returns the JSON value of the given Lua value. Tables with array
+// elements are arrays, and empty ones are null.
+func fromLua(v lua.LValue) (interface{}, error) {
+	switch v := v.(type) {
+	case *lua.LNilType:
+		return nil, nil
+	case lua.LBool:
+		return bool(v), nil
+	case lua.LNumber:
+		return float64(v), nil
+	case lua.LString:
+		return string(v), nil
+	case *lua.LTable:
+		if n := v.MaxN(); n > 0 {
+			elems := make([]interface{}, n)
+			for i := range elems {
+				e, err := fromLua(v.RawGetInt(i + 1))
+				if err != nil {
+					return nil, err
+				}
+				elems[i] = e
+			}
+			return elems, nil
+		}
+
+		var (
+			fields map[string]interface{}
+			err    error
+		)
+		v.ForEach(func(k, e lua.LValue) {
+			if err != nil {
+				return
+			}
+			name, ok := k.(lua.LString)
+			if !ok {
+				err = fmt.Errorf("script: got a %s key, want a string", k.Type())
+				return
+			}
+			if fields == nil {
+				fields = map[string]interface{}{}
+			}
+			fields[string(name)], err = fromLua(e)
+		})
+		if err != nil || fields == nil {
+			return nil, err
+		}
+		return fields, nil
+	default:
+		return nil, fmt.Errorf("script: can't convert a %s", v.Type())
+	}
+}
+
+// luaError returns the message of the given Lua error, without its stack
+// trace.
+func luaError(err error) error {
+	msg := err.Error()
+	if apiErr, ok := err.(*lua.ApiError); ok {
+		msg = apiErr.Object.String()
+	}
+	return fmt.Errorf("script: %s", strings.TrimSpace(msg))
+}
+
+// luaPrint writes its arguments to the log, since the standard output
+// carries the results of attacks.
+func luaPrint(L *lua.LState) int {
+	args := make([]string, L.GetTop())
+	for i := range args {
+		args[i] = L.ToStringMeta(L.Get(i + 1)).String()
+	}
+	log.Print(strings.Join(args, "\t"))
+	return 0
+}
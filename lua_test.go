@@ -0,0 +1,198 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// writeScript writes the given Lua script to a file and returns its name.
+func writeScript(t *testing.T, dir, name, src string) string {
+	t.Helper()
+	filename := filepath.Join(dir, name+".lua")
+	if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestLuaScript(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "vegeta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := loadScript(writeScript(t, dir, "hooks", `
+		local n = 0
+
+		function beforeRequest(target)
+			n = n + 1
+			target.header["X-Count"] = {tostring(n)}
+			target.body = string.upper(target.body)
+			if target.url == "http://localhost/forbidden" then
+				error("forbidden")
+			end
+		end
+
+		function afterResponse(result)
+			if result.latency > 100e6 then
+				return {error = "slower than 100ms"}
+			end
+			result.labels = {body = result.body}
+		end
+	`), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	tgt := vegeta.Target{Method: "POST", URL: "http://localhost/", Body: []byte("hello")}
+	req, err := tgt.Request()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Targets are changed in place.
+	if err = s.beforeRequest(&tgt, req); err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := ioutil.ReadAll(req.Body)
+	if got, want := string(body), "HELLO"; got != want || req.ContentLength != 5 {
+		t.Errorf("got body %q of length %d, want %q", got, req.ContentLength, want)
+	}
+
+	if got, want := req.Header.Get("X-Count"), "1"; got != want {
+		t.Errorf("got X-Count %q, want %q", got, want)
+	}
+
+	// Errors fail requests.
+	tgt.URL = "http://localhost/forbidden"
+	if req, err = tgt.Request(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.beforeRequest(&tgt, req); err == nil || !strings.Contains(err.Error(), "forbidden") {
+		t.Errorf("got error %v, want the one raised", err)
+	}
+
+	ts := time.Date(2020, 3, 14, 15, 9, 26, 0, time.UTC)
+	for _, tc := range []struct {
+		name    string
+		latency time.Duration
+		want    func(*vegeta.Result)
+	}{
+		{"changed", 10 * time.Millisecond, func(r *vegeta.Result) { r.Labels = map[string]string{"body": "pong"} }},
+		{"failed", 200 * time.Millisecond, func(r *vegeta.Result) { r.Error = "slower than 100ms" }},
+	} {
+		result := func() vegeta.Result {
+			return vegeta.Result{
+				Attack:    "checkout",
+				Seq:       7,
+				Code:      200,
+				Timestamp: ts,
+				Latency:   tc.latency,
+				URL:       "http://localhost/",
+				Body:      []byte("pong"),
+				Headers:   http.Header{"Server": {"nginx"}},
+			}
+		}
+
+		got, want := result(), result()
+		tc.want(&want)
+
+		s.afterResponse(&got)
+		if !got.Equal(want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, want)
+		}
+	}
+}
+
+func TestLuaScriptSandbox(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "vegeta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tc := range []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"no hooks", `x = 1`, ""},
+		{"os", `os.exit(1)`, "attempt to index a non-table object(nil)"},
+		{"io", `io.open("/etc/passwd")`, "attempt to index a non-table object(nil)"},
+		{"dofile", `dofile("/etc/passwd")`, "attempt to call a non-function object"},
+		{"require", `require("os")`, "attempt to call a non-function object"},
+		{"syntax", `function (`, "script: "},
+		{"loop", `while true do end`, "context deadline exceeded"},
+	} {
+		s, err := loadScript(writeScript(t, dir, strings.Replace(tc.name, " ", "-", -1), tc.src), 100*time.Millisecond)
+		if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%s: got error %v, want %q", tc.name, err, tc.err)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		// Scripts without hooks leave requests and results as they are.
+		tgt := vegeta.Target{Method: "GET", URL: "http://localhost/"}
+		req, _ := tgt.Request()
+		if err = s.beforeRequest(&tgt, req); err != nil || req.URL.String() != tgt.URL {
+			t.Errorf("%s: got %s, %v", tc.name, req.URL, err)
+		}
+
+		r := vegeta.Result{Code: 200}
+		if s.afterResponse(&r); !r.Equal(vegeta.Result{Code: 200}) {
+			t.Errorf("%s: got %+v", tc.name, r)
+		}
+
+		s.Close()
+	}
+}
+
+func TestLuaScriptTimeout(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "vegeta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := loadScript(writeScript(t, dir, "loop", `
+		function afterResponse(result)
+			if result.code == 500 then
+				while true do end
+			end
+		end
+	`), 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	r := vegeta.Result{Code: 500}
+	if s.afterResponse(&r); !strings.Contains(r.Error, "context deadline exceeded") {
+		t.Errorf("got error %q, want the one of the timeout", r.Error)
+	}
+
+	// Calls after a timeout go on in a new interpreter.
+	r = vegeta.Result{Code: 200}
+	if s.afterResponse(&r); r.Error != "" {
+		t.Errorf("got error %q, want none", r.Error)
+	}
+}
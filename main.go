@@ -13,11 +13,16 @@ import (
 
 func main() {
 	commands := map[string]command{
-		"attack": attackCmd(),
-		"report": reportCmd(),
-		"plot":   plotCmd(),
-		"encode": encodeCmd(),
-		"dump":   dumpCmd(),
+		"attack":  attackCmd(),
+		"report":  reportCmd(),
+		"plot":    plotCmd(),
+		"encode":  encodeCmd(),
+		"diff":    diffCmd(),
+		"dump":    dumpCmd(),
+		"grafana": grafanaCmd(),
+		"worker":  workerCmd(),
+		"collect": collectCmd(),
+		"agent":   agentCmd(),
 	}
 
 	fs := flag.NewFlagSet("vegeta", flag.ExitOnError)
@@ -92,6 +97,10 @@ func main() {
 	if cmd, ok := commands[args[0]]; !ok {
 		log.Fatalf("Unknown command: %s", args[0])
 	} else if err := cmd.fn(args[1:]); err != nil {
+		if e, ok := err.(*exitError); ok {
+			log.Print(e)
+			os.Exit(e.code)
+		}
 		log.Fatal(err)
 	}
 }
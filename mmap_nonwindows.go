@@ -0,0 +1,18 @@
+// +build !windows
+
+package main
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// mmap maps the given file read-only into memory.
+func mmap(f *os.File, size int64) ([]byte, io.Closer, error) {
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, closerFunc(func() error { return syscall.Munmap(data) }), nil
+}
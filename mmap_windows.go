@@ -0,0 +1,19 @@
+// +build windows
+
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// mmap reads the given file into memory, since memory mapping files isn't
+// supported on Windows.
+func mmap(f *os.File, size int64) ([]byte, io.Closer, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(f, size))
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, multiCloser{}, nil
+}
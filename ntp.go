@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+)
+
+// ntpSamples is the number of queries ntpOffset sends to its server, taking
+// the offset measured by the one with the shortest round trip.
+const ntpSamples = 4
+
+// ntpEpoch is the epoch of NTP timestamps.
+var ntpEpoch = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ntpOffset returns the offset of the local clock from the one of the given
+// NTP server (host or host:port), to add to the local time to get the
+// server's time, as measured with SNTP (RFC 4330).
+func ntpOffset(server string) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+
+	conn, err := net.DialTimeout("udp", server, 5*time.Second)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var (
+		offset time.Duration
+		delay  time.Duration = -1
+	)
+
+	for i := 0; i < ntpSamples; i++ {
+		if err = conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			return 0, err
+		}
+
+		// LI = 0, VN = 4, Mode = 3 (client).
+		req := make([]byte, 48)
+		req[0] = 0x23
+
+		t1 := time.Now()
+		if _, err = conn.Write(req); err != nil {
+			return 0, err
+		}
+
+		resp := make([]byte, 48)
+		if _, err = conn.Read(resp); err != nil {
+			return 0, err
+		}
+		t4 := time.Now()
+
+		if resp[0]&0x7 != 4 {
+			return 0, errors.New("not an NTP server response")
+		} else if resp[1] == 0 {
+			return 0, errors.New("NTP server refused the query")
+		}
+
+		t2, t3 := ntpTime(resp[32:40]), ntpTime(resp[40:48])
+		if d := t4.Sub(t1) - t3.Sub(t2); delay < 0 || d < delay {
+			delay = d
+			offset = (t2.Sub(t1) + t3.Sub(t4)) / 2
+		}
+	}
+
+	return offset, nil
+}
+
+// ntpTime decodes the given 64 bit NTP timestamp.
+func ntpTime(b []byte) time.Time {
+	secs := binary.BigEndian.Uint32(b[:4])
+	frac := binary.BigEndian.Uint32(b[4:])
+	nanos := (uint64(frac) * 1e9) >> 32
+	return ntpEpoch.Add(time.Duration(secs)*time.Second + time.Duration(nanos))
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsenart/vegeta/v12/internal/aws"
+)
+
+// objectStore creates and opens objects in a cloud object store, identified
+// by URLs of the form scheme://bucket/key.
+type objectStore struct {
+	create func(bucket, key string) (uploader, error)
+	open   func(bucket, key string) (io.ReadCloser, error)
+}
+
+// objectStores maps the URL schemes of result files which are written to
+// and read from object stores to their objectStore.
+var objectStores = map[string]objectStore{
+	"s3": {create: newS3Upload, open: openS3Object},
+	"gs": {create: newGCSUpload, open: openGCSObject},
+}
+
+// objectURL returns the objectStore, bucket and key of the given name if
+// it's an object store URL.
+func objectURL(name string) (store objectStore, bucket, key string, ok bool) {
+	u, err := url.Parse(name)
+	if err != nil {
+		return store, "", "", false
+	}
+
+	store, ok = objectStores[u.Scheme]
+	return store, u.Host, strings.TrimPrefix(u.Path, "/"), ok && u.Host != "" && u.Path != ""
+}
+
+// objectPartSize is the size of the parts objects are uploaded in. It's a
+// multiple of the 256 KiB GCS requires and above the 5 MiB minimum of S3.
+const objectPartSize = 8 << 20
+
+// An uploader uploads an object in consecutive parts. The last part, which
+// may be empty, is uploaded with last set to true.
+type uploader interface {
+	upload(part []byte, offset int64, last bool) error
+}
+
+// objectWriter buffers writes into parts which are uploaded in the
+// background, so that writers aren't blocked on each upload.
+type objectWriter struct {
+	buf   []byte
+	parts chan objectPart
+	done  chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+type objectPart struct {
+	data []byte
+	last bool
+}
+
+func newObjectWriter(u uploader) *objectWriter {
+	w := &objectWriter{
+		parts: make(chan objectPart, 1),
+		done:  make(chan struct{}),
+	}
+
+	go func() {
+		defer close(w.done)
+
+		var off int64
+		for p := range w.parts {
+			if w.error() == nil {
+				if err := u.upload(p.data, off, p.last); err != nil {
+					w.mu.Lock()
+					w.err = err
+					w.mu.Unlock()
+				}
+			}
+			off += int64(len(p.data))
+		}
+	}()
+
+	return w
+}
+
+func (w *objectWriter) error() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *objectWriter) Write(p []byte) (int, error) {
+	if err := w.error(); err != nil {
+		return 0, err
+	}
+
+	w.buf = append(w.buf, p...)
+	for len(w.buf) >= objectPartSize {
+		w.parts <- objectPart{data: w.buf[:objectPartSize:objectPartSize]}
+		w.buf = append([]byte(nil), w.buf[objectPartSize:]...)
+	}
+
+	return len(p), nil
+}
+
+// Close uploads the last part and waits for all uploads to finish.
+func (w *objectWriter) Close() error {
+	w.parts <- objectPart{data: w.buf, last: true}
+	close(w.parts)
+	<-w.done
+	return w.error()
+}
+
+// objectClient is an HTTP client which checks object store responses.
+type objectClient struct {
+	http.Client
+	auth func(req *http.Request, body []byte) error
+}
+
+func (c *objectClient) do(method, rawurl string, hdr http.Header, body []byte, ok ...int) (*http.Response, error) {
+	req, err := http.NewRequest(method, rawurl, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for k, vs := range hdr {
+		req.Header[k] = vs
+	}
+
+	if err = c.auth(req, body); err != nil {
+		return nil, err
+	}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, code := range ok {
+		if res.StatusCode == code {
+			return res, nil
+		}
+	}
+
+	if res.StatusCode < 300 && len(ok) == 0 {
+		return res, nil
+	}
+
+	defer res.Body.Close()
+	msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+
+	return nil, fmt.Errorf("%s %s: %s: %s", method, req.URL, res.Status, bytes.TrimSpace(msg))
+}
+
+// newS3Client returns an objectClient for S3 and the URL of the given object.
+// The endpoint can be overridden with the AWS_ENDPOINT_URL_S3 environment
+// variable, e.g. for S3 compatible stores like MinIO, in which case path
+// style URLs are used.
+func newS3Client(bucket, key string) (*objectClient, string, error) {
+	creds, err := aws.LoadCredentials()
+	if err != nil {
+		return nil, "", err
+	}
+
+	region := aws.Region()
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	obj := (&url.URL{Path: "/" + key}).EscapedPath()
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL_S3"); endpoint != "" {
+		obj = strings.TrimSuffix(endpoint, "/") + "/" + bucket + obj
+	} else {
+		obj = "https://" + bucket + ".s3." + region + ".amazonaws.com" + obj
+	}
+
+	signer := aws.Signer{Credentials: creds, Region: region, Service: "s3"}
+	return &objectClient{auth: func(req *http.Request, body []byte) error {
+		signer.Sign(req, body, time.Now())
+		return nil
+	}}, obj, nil
+}
+
+func openS3Object(bucket, key string) (io.ReadCloser, error) {
+	c, obj, err := newS3Client(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.do(http.MethodGet, obj, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+// s3Upload uploads an object to S3 with a multipart upload, or a single
+// PUT request if it fits in one part.
+type s3Upload struct {
+	*objectClient
+	url   string
+	id    string
+	etags []string
+}
+
+func newS3Upload(bucket, key string) (uploader, error) {
+	c, obj, err := newS3Client(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Upload{objectClient: c, url: obj}, nil
+}
+
+func (u *s3Upload) upload(part []byte, offset int64, last bool) error {
+	if last && offset == 0 {
+		res, err := u.do(http.MethodPut, u.url, nil, part)
+		if err != nil {
+			return err
+		}
+		return res.Body.Close()
+	}
+
+	if u.id == "" {
+		res, err := u.do(http.MethodPost, u.url+"?uploads", nil, nil)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+
+		var created struct {
+			UploadID string `xml:"UploadId"`
+		}
+
+		if err = xml.NewDecoder(res.Body).Decode(&created); err != nil {
+			return fmt.Errorf("s3: bad CreateMultipartUpload response: %v", err)
+		}
+
+		u.id = created.UploadID
+	}
+
+	err := u.uploadPart(part, last)
+	if err != nil {
+		// The parts of multipart uploads are stored, and billed, until they're
+		// aborted.
+		res, aerr := u.do(http.MethodDelete, u.url+"?uploadId="+url.QueryEscape(u.id), nil, nil)
+		if aerr != nil {
+			return fmt.Errorf("%v, and aborting the upload failed: %v", err, aerr)
+		}
+		res.Body.Close()
+	}
+
+	return err
+}
+
+// uploadPart uploads the given part of the multipart upload, and completes
+// it if it's the last one.
+func (u *s3Upload) uploadPart(part []byte, last bool) error {
+	if len(part) > 0 {
+		query := url.Values{
+			"partNumber": {strconv.Itoa(len(u.etags) + 1)},
+			"uploadId":   {u.id},
+		}
+
+		res, err := u.do(http.MethodPut, u.url+"?"+query.Encode(), nil, part)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+
+		u.etags = append(u.etags, res.Header.Get("ETag"))
+	}
+
+	if !last {
+		return nil
+	}
+
+	type completedPart struct {
+		PartNumber int
+		ETag       string
+	}
+
+	complete := struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{}
+
+	for i, etag := range u.etags {
+		complete.Parts = append(complete.Parts, completedPart{i + 1, etag})
+	}
+
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+
+	res, err := u.do(http.MethodPost, u.url+"?uploadId="+url.QueryEscape(u.id), nil, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// Errors may be reported in the body of 200 OK responses.
+	var result struct {
+		XMLName xml.Name
+		Message string
+	}
+
+	if err = xml.NewDecoder(res.Body).Decode(&result); err == nil && result.XMLName.Local == "Error" {
+		return fmt.Errorf("s3: CompleteMultipartUpload: %s", result.Message)
+	}
+
+	return nil
+}
+
+// newGCSClient returns an objectClient for Google Cloud Storage and its
+// endpoint, which can be overridden with the STORAGE_EMULATOR_HOST
+// environment variable. Requests are authorized with the access token in
+// the GOOGLE_OAUTH_ACCESS_TOKEN environment variable or, if unset, the one
+// printed by `gcloud auth print-access-token`.
+func newGCSClient() (*objectClient, string, error) {
+	token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	if token == "" {
+		out, err := exec.Command("gcloud", "auth", "print-access-token").Output()
+		if err != nil {
+			return nil, "", errors.New("gcs: no access token in GOOGLE_OAUTH_ACCESS_TOKEN and gcloud failed to print one")
+		}
+		token = strings.TrimSpace(string(out))
+	}
+
+	endpoint := "https://storage.googleapis.com"
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		if endpoint = strings.TrimSuffix(host, "/"); !strings.Contains(endpoint, "://") {
+			endpoint = "http://" + endpoint
+		}
+	}
+
+	return &objectClient{auth: func(req *http.Request, _ []byte) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}}, endpoint, nil
+}
+
+func openGCSObject(bucket, key string) (io.ReadCloser, error) {
+	c, endpoint, err := newGCSClient()
+	if err != nil {
+		return nil, err
+	}
+
+	obj := endpoint + "/storage/v1/b/" + url.PathEscape(bucket) + "/o/" + url.PathEscape(key) + "?alt=media"
+	res, err := c.do(http.MethodGet, obj, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+// gcsUpload uploads an object to GCS with a resumable upload, or a single
+// media upload if it fits in one part.
+type gcsUpload struct {
+	*objectClient
+	url     string
+	session string
+}
+
+func newGCSUpload(bucket, key string) (uploader, error) {
+	c, endpoint, err := newGCSClient()
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{"name": {key}}
+	obj := endpoint + "/upload/storage/v1/b/" + url.PathEscape(bucket) + "/o?" + query.Encode()
+
+	return &gcsUpload{objectClient: c, url: obj}, nil
+}
+
+func (u *gcsUpload) upload(part []byte, offset int64, last bool) error {
+	if last && offset == 0 {
+		hdr := http.Header{"Content-Type": {"application/octet-stream"}}
+		res, err := u.do(http.MethodPost, u.url+"&uploadType=media", hdr, part)
+		if err != nil {
+			return err
+		}
+		return res.Body.Close()
+	}
+
+	if u.session == "" {
+		hdr := http.Header{"X-Upload-Content-Type": {"application/octet-stream"}}
+		res, err := u.do(http.MethodPost, u.url+"&uploadType=resumable", hdr, nil)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+
+		if u.session = res.Header.Get("Location"); u.session == "" {
+			return errors.New("gcs: no resumable upload session URL in response")
+		}
+	}
+
+	total := "*"
+	if last {
+		total = strconv.FormatInt(offset+int64(len(part)), 10)
+	}
+
+	rng := "bytes */" + total
+	if len(part) > 0 {
+		rng = fmt.Sprintf("bytes %d-%d/%s", offset, offset+int64(len(part))-1, total)
+	}
+
+	// Intermediate parts are acknowledged with 308 Resume Incomplete.
+	ok := []int{http.StatusPermanentRedirect}
+	if last {
+		ok = []int{http.StatusOK, http.StatusCreated}
+	}
+
+	res, err := u.do(http.MethodPut, u.session, http.Header{"Content-Range": {rng}}, part, ok...)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// setenv sets the given environment variables until the returned function
+// restores them.
+func setenv(vars map[string]string) func() {
+	old := map[string]*string{}
+	for k, v := range vars {
+		if prev, ok := os.LookupEnv(k); ok {
+			old[k] = &prev
+		} else {
+			old[k] = nil
+		}
+		os.Setenv(k, v)
+	}
+
+	return func() {
+		for k, v := range old {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}
+}
+
+// fakeS3 is a fake S3 API with path style URLs, whose part uploads fail
+// from the failPart-th on, if set.
+type fakeS3 struct {
+	failPart int
+
+	mu       sync.Mutex
+	objects  map[string][]byte
+	uploads  map[string]map[int][]byte
+	requests []string
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		http.Error(w, "unsigned request", http.StatusForbidden)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	q := r.URL.Query()
+	id := q.Get("uploadId")
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+
+	switch _, uploads := q["uploads"]; {
+	case r.Method == http.MethodGet:
+		obj, ok := s.objects[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(obj)
+	case r.Method == http.MethodPut && id == "":
+		s.objects[r.URL.Path] = body
+	case r.Method == http.MethodPost && uploads:
+		id = strconv.Itoa(len(s.uploads) + 1)
+		s.uploads[id] = map[int][]byte{}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut:
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		if s.failPart > 0 && n >= s.failPart {
+			http.Error(w, "<Error><Message>slow down</Message></Error>", http.StatusServiceUnavailable)
+			return
+		}
+		s.uploads[id][n] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, n))
+	case r.Method == http.MethodPost:
+		var obj []byte
+		for n := 1; n <= len(s.uploads[id]); n++ {
+			if !bytes.Contains(body, []byte(fmt.Sprintf(`<PartNumber>%d</PartNumber><ETag>&#34;etag-%d&#34;</ETag>`, n, n))) {
+				http.Error(w, "missing part", http.StatusBadRequest)
+				return
+			}
+			obj = append(obj, s.uploads[id][n]...)
+		}
+		s.objects[r.URL.Path] = obj
+		delete(s.uploads, id)
+		w.Write([]byte("<CompleteMultipartUploadResult></CompleteMultipartUploadResult>"))
+	case r.Method == http.MethodDelete:
+		delete(s.uploads, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}
+}
+
+// fakeGCS is a fake GCS JSON API.
+type fakeGCS struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	sessions map[string][]byte
+	srv      *httptest.Server
+}
+
+func (s *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer t0ken" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	name := "gs://bucket/" + r.URL.Query().Get("name")
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/storage/v1/b/bucket/o/"):
+		obj, ok := s.objects["gs://bucket/"+strings.TrimPrefix(r.URL.Path, "/storage/v1/b/bucket/o/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(obj)
+	case r.Method == http.MethodPost && r.URL.Query().Get("uploadType") == "media":
+		s.objects[name] = body
+	case r.Method == http.MethodPost && r.URL.Query().Get("uploadType") == "resumable":
+		s.sessions[name] = []byte{}
+		w.Header().Set("Location", s.srv.URL+"/session?name="+r.URL.Query().Get("name"))
+	case r.Method == http.MethodPut && r.URL.Path == "/session":
+		obj, ok := s.sessions[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		var first, last int
+		var total string
+		if rng := r.Header.Get("Content-Range"); len(body) == 0 {
+			fmt.Sscanf(rng, "bytes */%s", &total)
+		} else if _, err := fmt.Sscanf(rng, "bytes %d-%d/%s", &first, &last, &total); err != nil || first != len(obj) || last != first+len(body)-1 {
+			http.Error(w, "bad range "+rng, http.StatusBadRequest)
+			return
+		}
+
+		s.sessions[name] = append(obj, body...)
+		if total == "*" {
+			w.WriteHeader(http.StatusPermanentRedirect)
+		} else if total != strconv.Itoa(len(s.sessions[name])) {
+			http.Error(w, "bad total "+total, http.StatusBadRequest)
+		} else {
+			s.objects[name] = s.sessions[name]
+			delete(s.sessions, name)
+		}
+	default:
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}
+}
+
+// writeObject writes the given data to the named object and reads it back.
+func writeObject(name string, data []byte) ([]byte, error) {
+	w, err := create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Writes of odd sizes span parts.
+	for p := data; len(p) > 0; {
+		n := 1<<20 + 7
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err = w.Write(p[:n]); err != nil {
+			w.Close()
+			return nil, err
+		}
+		p = p[n:]
+	}
+
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+
+	r, err := open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
+
+func TestS3Object(t *testing.T) {
+	s3 := &fakeS3{objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}}
+	srv := httptest.NewServer(s3)
+	defer srv.Close()
+
+	defer setenv(map[string]string{
+		"AWS_ENDPOINT_URL_S3":   srv.URL,
+		"AWS_ACCESS_KEY_ID":     "AKID",
+		"AWS_SECRET_ACCESS_KEY": "SECRET",
+		"AWS_REGION":            "eu-west-1",
+	})()
+
+	data := make([]byte, 2*objectPartSize+12345)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	for _, tc := range []struct {
+		name  string
+		size  int
+		parts int
+	}{
+		{"empty", 0, 0},
+		{"single part", 1000, 0},
+		{"exact part", objectPartSize, 1},
+		{"multipart", len(data), 3},
+	} {
+		name := "s3://bucket/attacks/" + strings.Replace(tc.name, " ", "-", -1) + ".bin"
+		got, err := writeObject(name, data[:tc.size])
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		} else if !bytes.Equal(got, data[:tc.size]) {
+			t.Errorf("%s: got %d bytes back, want %d", tc.name, len(got), tc.size)
+		}
+
+		s3.mu.Lock()
+		var parts int
+		for _, req := range s3.requests {
+			if strings.Contains(req, "partNumber=") {
+				parts++
+			}
+		}
+		s3.requests = nil
+		s3.mu.Unlock()
+
+		if parts != tc.parts {
+			t.Errorf("%s: got %d parts, want %d", tc.name, parts, tc.parts)
+		}
+	}
+}
+
+func TestS3ObjectAbort(t *testing.T) {
+	s3 := &fakeS3{failPart: 2, objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}}
+	srv := httptest.NewServer(s3)
+	defer srv.Close()
+
+	defer setenv(map[string]string{
+		"AWS_ENDPOINT_URL_S3":   srv.URL,
+		"AWS_ACCESS_KEY_ID":     "AKID",
+		"AWS_SECRET_ACCESS_KEY": "SECRET",
+	})()
+
+	_, err := writeObject("s3://bucket/results.bin", make([]byte, 3*objectPartSize))
+	if err == nil || !strings.Contains(err.Error(), "503 Service Unavailable") {
+		t.Fatalf("got error %v, want the one of the failed part", err)
+	}
+
+	s3.mu.Lock()
+	defer s3.mu.Unlock()
+
+	if len(s3.uploads) != 0 || len(s3.objects) != 0 {
+		t.Errorf("got %d uploads and %d objects left, want none", len(s3.uploads), len(s3.objects))
+	}
+
+	// No parts are uploaded after the failed one, which is aborted.
+	want := []string{
+		"POST /bucket/results.bin?uploads",
+		"PUT /bucket/results.bin?partNumber=1&uploadId=1",
+		"PUT /bucket/results.bin?partNumber=2&uploadId=1",
+		"DELETE /bucket/results.bin?uploadId=1",
+	}
+
+	if strings.Join(s3.requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got requests\n%s\nwant\n%s", strings.Join(s3.requests, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestGCSObject(t *testing.T) {
+	gcs := &fakeGCS{objects: map[string][]byte{}, sessions: map[string][]byte{}}
+	srv := httptest.NewServer(gcs)
+	defer srv.Close()
+	gcs.srv = srv
+
+	defer setenv(map[string]string{
+		"STORAGE_EMULATOR_HOST":     strings.TrimPrefix(srv.URL, "http://"),
+		"GOOGLE_OAUTH_ACCESS_TOKEN": "t0ken",
+	})()
+
+	data := make([]byte, 2*objectPartSize+12345)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	for _, size := range []int{0, 1000, objectPartSize, len(data)} {
+		name := "gs://bucket/attacks/" + strconv.Itoa(size) + ".bin"
+		got, err := writeObject(name, data[:size])
+		if err != nil {
+			t.Errorf("%d bytes: %v", size, err)
+		} else if !bytes.Equal(got, data[:size]) {
+			t.Errorf("%d bytes: got %d bytes back", size, len(got))
+		}
+	}
+
+	gcs.mu.Lock()
+	defer gcs.mu.Unlock()
+
+	var names []string
+	for name := range gcs.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) != 4 || len(gcs.sessions) != 0 {
+		t.Errorf("got objects %v and %d sessions left", names, len(gcs.sessions))
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"os/signal"
+	"time"
 
 	vegeta "github.com/tsenart/vegeta/v12/lib"
 	"github.com/tsenart/vegeta/v12/lib/plot"
@@ -16,7 +17,9 @@ const plotUsage = `Usage: vegeta plot [options] [<file>...]
 Outputs an HTML time series plot of request latencies over time.
 The X axis represents elapsed time in seconds from the beginning
 of the earliest attack in all input files. The Y axis represents
-request latency in milliseconds.
+request latency in milliseconds. The CPU and memory usage of the
+hosts sampled with attack --host-stats or --agent, if any, is
+plotted on a second Y axis in percent.
 
 Click and drag to select a region to zoom into. Double click to zoom out.
 Choose a different number on the bottom left corner input field
@@ -24,14 +27,20 @@ to change the moving average window size (in data points).
 
 Arguments:
   <file>  A file with vegeta attack results encoded with one of
-          the supported encodings (gob | json | csv) [default: stdin]
+          the supported encodings (gob | json | csv | msgpack | parquet | protobuf) [default: stdin]
 
 Options:
-  --title      Title and header of the resulting HTML page.
+  --title      Title and header of the resulting HTML page. Defaults to
+               one with the name, rate and duration of the attacks
+               recorded in the metadata of the results, if any.
                [default: Vegeta Plot]
   --threshold  Threshold of data points to downsample series to.
                Series with less than --threshold number of data
                points are not downsampled. [default: 4000]
+  --from       Only plot results from the given RFC3339 time or offset
+               from the first result (e.g. 30s).
+  --to         Only plot results until the given RFC3339 time or offset
+               from the first result (e.g. 5m), exclusive.
 
 Examples:
   echo "GET http://:80" | vegeta attack -name=50qps -rate=50 -duration=5s > results.50qps.bin
@@ -40,11 +49,16 @@ Examples:
   vegeta plot results.50qps.bin results.100qps.bin > plot.html
 `
 
+const defaultPlotTitle = "Vegeta Plot"
+
 func plotCmd() command {
 	fs := flag.NewFlagSet("vegeta plot", flag.ExitOnError)
-	title := fs.String("title", "Vegeta Plot", "Title and header of the resulting HTML page")
+	title := fs.String("title", defaultPlotTitle, "Title and header of the resulting HTML page")
 	threshold := fs.Int("threshold", 4000, "Threshold of data points above which series are downsampled.")
 	output := fs.String("output", "stdout", "Output file")
+	var from, to timeFlag
+	fs.Var(&from, "from", "Only plot results from this RFC3339 time or offset from the first result (e.g. 30s)")
+	fs.Var(&to, "to", "Only plot results until this RFC3339 time or offset from the first result (e.g. 5m)")
 
 	fs.Usage = func() {
 		fmt.Fprintln(os.Stderr, plotUsage)
@@ -56,17 +70,25 @@ func plotCmd() command {
 		if len(files) == 0 {
 			files = append(files, "stdin")
 		}
-		return plotRun(files, *threshold, *title, *output)
+		return plotRun(files, *threshold, *title, *output, from, to)
 	}}
 }
 
-func plotRun(files []string, threshold int, title, output string) error {
-	dec, mc, err := decoder(files)
+func plotRun(files []string, threshold int, title, output string, from, to timeFlag) error {
+	// Only absolute time ranges are known before decoding the first result.
+	var mds metadataList
+	inputs, mc, err := inputDecoders(files, from.t, to.t, mds.add)
 	defer mc.Close()
 	if err != nil {
 		return err
 	}
 
+	dec := roundRobin(inputs)
+
+	if from.set || to.set {
+		dec = vegeta.NewFilterDecoder(dec, timeRange(from, to))
+	}
+
 	out, err := file(output, true)
 	if err != nil {
 		return err
@@ -102,8 +124,23 @@ decode:
 		}
 	}
 
+	// The resource usage of the hosts is plotted alongside the latencies.
+	err = mds.usage(func(md *vegeta.Metadata, source string, at time.Time, u vegeta.HostUsage) error {
+		if at.Before(md.Began) {
+			return nil
+		}
+		return p.AddUsage(md.Attack, source, at.Sub(md.Began), u)
+	})
+	if err != nil {
+		return err
+	}
+
 	p.Close()
 
+	if title == defaultPlotTitle && len(mds.list) > 0 {
+		plot.Title(title + ": " + mds.summary())(p)
+	}
+
 	_, err = p.WriteTo(out)
 	return err
 }
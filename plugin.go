@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"plugin"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// plugins are the extensions of an attack loaded from -plugin files, which
+// are Go plugins, built with go build -buildmode=plugin against the same
+// version of vegeta, exporting any of the functions:
+//
+//	func Targeter() (vegeta.Targeter, error)
+//	func Pacer() (vegeta.Pacer, error)
+//	func Sink() (func(*vegeta.Result) error, error)
+//
+// which are called once, before the attack begins.
+type plugins struct {
+	// targeter and pacer, if set, replace the targets read and the -rate.
+	targeter vegeta.Targeter
+	pacer    vegeta.Pacer
+	// sinks are called with every result, after it's written.
+	sinks []func(*vegeta.Result) error
+}
+
+// pluginSymbols are the exported symbols of a plugin, as looked up in a
+// *plugin.Plugin.
+type pluginSymbols interface {
+	Lookup(name string) (plugin.Symbol, error)
+}
+
+// loadPlugins loads the given plugin files, of which at most one exports
+// each of Targeter and Pacer.
+func loadPlugins(paths []string) (*plugins, error) {
+	var pl plugins
+	for _, path := range paths {
+		p, err := openPlugin(path)
+		if err != nil {
+			return nil, fmt.Errorf("error loading plugin: %v", err)
+		}
+
+		if err = pl.add(path, p); err != nil {
+			return nil, err
+		}
+	}
+	return &pl, nil
+}
+
+// add adds the extensions exported by the given symbols of the plugin at
+// path.
+func (pl *plugins) add(path string, p pluginSymbols) error {
+	// Lookup only fails for missing symbols.
+	if sym, err := p.Lookup("Targeter"); err == nil {
+		newTargeter, ok := sym.(func() (vegeta.Targeter, error))
+		if !ok {
+			return fmt.Errorf("plugin %s: Targeter is a %T, want a func() (vegeta.Targeter, error)", path, sym)
+		} else if pl.targeter != nil {
+			return fmt.Errorf("plugin %s: Targeter is exported by another plugin", path)
+		} else if pl.targeter, err = newTargeter(); err != nil {
+			return fmt.Errorf("plugin %s: %v", path, err)
+		}
+	}
+
+	if sym, err := p.Lookup("Pacer"); err == nil {
+		newPacer, ok := sym.(func() (vegeta.Pacer, error))
+		if !ok {
+			return fmt.Errorf("plugin %s: Pacer is a %T, want a func() (vegeta.Pacer, error)", path, sym)
+		} else if pl.pacer != nil {
+			return fmt.Errorf("plugin %s: Pacer is exported by another plugin", path)
+		} else if pl.pacer, err = newPacer(); err != nil {
+			return fmt.Errorf("plugin %s: %v", path, err)
+		}
+	}
+
+	if sym, err := p.Lookup("Sink"); err == nil {
+		newSink, ok := sym.(func() (func(*vegeta.Result) error, error))
+		if !ok {
+			return fmt.Errorf("plugin %s: Sink is a %T, want a func() (func(*vegeta.Result) error, error)", path, sym)
+		}
+
+		sink, err := newSink()
+		if err != nil {
+			return fmt.Errorf("plugin %s: %v", path, err)
+		}
+		pl.sinks = append(pl.sinks, sink)
+	}
+
+	return nil
+}
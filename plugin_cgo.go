@@ -0,0 +1,9 @@
+// +build cgo
+
+package main
+
+import "plugin"
+
+func openPlugin(path string) (pluginSymbols, error) {
+	return plugin.Open(path)
+}
@@ -0,0 +1,11 @@
+// +build !cgo
+
+package main
+
+import "errors"
+
+// openPlugin fails in binaries built without cgo, like the released ones,
+// since Go plugins are loaded with the dynamic linker.
+func openPlugin(path string) (pluginSymbols, error) {
+	return nil, errors.New("this vegeta binary was built without cgo, which Go plugins require: build one with make vegeta-plugins")
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"plugin"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// fakePlugin maps the names of the symbols of a plugin to their values.
+type fakePlugin map[string]plugin.Symbol
+
+func (p fakePlugin) Lookup(name string) (plugin.Symbol, error) {
+	if sym, ok := p[name]; ok {
+		return sym, nil
+	}
+	return nil, errors.New("plugin: symbol " + name + " not found")
+}
+
+func TestPluginsAdd(t *testing.T) {
+	t.Parallel()
+
+	targeter := func() (vegeta.Targeter, error) {
+		return func(tgt *vegeta.Target) error { return nil }, nil
+	}
+	pacer := func() (vegeta.Pacer, error) {
+		return vegeta.ConstantPacer{Freq: 1, Per: time.Second}, nil
+	}
+	sink := func() (func(*vegeta.Result) error, error) {
+		return func(*vegeta.Result) error { return nil }, nil
+	}
+	failing := func() (vegeta.Targeter, error) {
+		return nil, errors.New("no targets")
+	}
+
+	for _, tc := range []struct {
+		name    string
+		plugins []fakePlugin
+		err     string
+		sinks   int
+	}{
+		{name: "no symbols", plugins: []fakePlugin{{}}},
+		{name: "all symbols", plugins: []fakePlugin{{"Targeter": targeter, "Pacer": pacer, "Sink": sink}}, sinks: 1},
+		{name: "sinks of several plugins", plugins: []fakePlugin{{"Sink": sink}, {"Targeter": targeter, "Sink": sink}}, sinks: 2},
+		{
+			name:    "targeter of wrong type",
+			plugins: []fakePlugin{{"Targeter": func() vegeta.Targeter { return nil }}},
+			err:     "plugin 0.so: Targeter is a func() vegeta.Targeter, want a func() (vegeta.Targeter, error)",
+		},
+		{
+			name:    "pacer of wrong type",
+			plugins: []fakePlugin{{"Pacer": vegeta.ConstantPacer{}}},
+			err:     "plugin 0.so: Pacer is a vegeta.ConstantPacer, want a func() (vegeta.Pacer, error)",
+		},
+		{
+			name:    "sink of wrong type",
+			plugins: []fakePlugin{{"Sink": func(*vegeta.Result) error { return nil }}},
+			err:     "plugin 0.so: Sink is a func(*vegeta.Result) error, want a func() (func(*vegeta.Result) error, error)",
+		},
+		{
+			name:    "targeter of several plugins",
+			plugins: []fakePlugin{{"Targeter": targeter}, {"Targeter": targeter}},
+			err:     "plugin 1.so: Targeter is exported by another plugin",
+		},
+		{
+			name:    "pacer of several plugins",
+			plugins: []fakePlugin{{"Pacer": pacer}, {"Pacer": pacer}},
+			err:     "plugin 1.so: Pacer is exported by another plugin",
+		},
+		{
+			name:    "failing constructor",
+			plugins: []fakePlugin{{"Targeter": failing}},
+			err:     "plugin 0.so: no targets",
+		},
+	} {
+		var (
+			pl  plugins
+			err error
+		)
+
+		for i, p := range tc.plugins {
+			if err = pl.add(strconv.Itoa(i)+".so", p); err != nil {
+				break
+			}
+		}
+
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("%s: got error %v, want %q", tc.name, err, tc.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		} else if len(pl.sinks) != tc.sinks {
+			t.Errorf("%s: got %d sinks, want %d", tc.name, len(pl.sinks), tc.sinks)
+		}
+
+		_, targeter := tc.plugins[len(tc.plugins)-1]["Targeter"]
+		if (pl.targeter != nil) != targeter {
+			t.Errorf("%s: got targeter %v, want one: %v", tc.name, pl.targeter != nil, targeter)
+		}
+	}
+}
+
+func TestLoadPluginsError(t *testing.T) {
+	t.Parallel()
+
+	if _, err := loadPlugins([]string{"missing.so"}); err == nil || !strings.HasPrefix(err.Error(), "error loading plugin: ") {
+		t.Errorf("got error %v, want one loading the plugin", err)
+	}
+}
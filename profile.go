@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// profiler captures CPU and heap profiles and a goroutine dump of the attack
+// process each time its -profile-if condition begins to hold, into a
+// directory per capture, and notifies its -profile-webhook of each, e.g. to
+// profile the targets at the same time.
+type profiler struct {
+	cond    *stopCondition
+	dir     string
+	cpu     time.Duration
+	webhook string
+	attack  string
+	client  http.Client
+
+	holding bool
+	busy    bool
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	done    chan struct{}
+}
+
+func newProfiler(opts *attackOpts) *profiler {
+	dir := opts.profileDir
+	if dir == "" {
+		// Profiles are stored next to results written to files.
+		dir = "vegeta-profiles"
+		if opts.outputf != "stdout" && !strings.Contains(opts.outputf, "://") {
+			dir = opts.outputf + ".profiles"
+		}
+	}
+
+	return &profiler{
+		cond:    &opts.profileIf,
+		dir:     dir,
+		cpu:     opts.profileCPU,
+		webhook: opts.profileHook,
+		attack:  opts.name,
+		client:  http.Client{Timeout: 10 * time.Second},
+		done:    make(chan struct{}),
+	}
+}
+
+// check evaluates the condition with the given metrics at the given time,
+// and starts a capture when it begins to hold, unless one is in progress,
+// returning true if it started one.
+func (p *profiler) check(m *vegeta.Metrics, now time.Time) bool {
+	cond, ok := p.cond.check(m, now)
+	if !ok || p.holding {
+		p.holding = ok
+		return false
+	}
+	p.holding = true
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.busy {
+		return false
+	}
+	p.busy = true
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.capture(cond, now)
+
+		p.mu.Lock()
+		p.busy = false
+		p.mu.Unlock()
+	}()
+	return true
+}
+
+// profileEvent is the JSON payload posted to the -profile-webhook.
+type profileEvent struct {
+	Attack    string    `json:"attack"`
+	Condition string    `json:"condition"`
+	Time      time.Time `json:"time"`
+	Dir       string    `json:"dir"`
+	Duration  string    `json:"duration"`
+}
+
+// capture captures the profiles of the attack, logging any errors, since
+// they mustn't fail it.
+func (p *profiler) capture(cond string, at time.Time) {
+	dir := filepath.Join(p.dir, at.UTC().Format("20060102T150405Z"))
+	log.Printf("capturing profiles of the attack in %s since %s", dir, cond)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("error capturing profiles: %v", err)
+		return
+	}
+
+	if p.webhook != "" {
+		go func() {
+			ev := profileEvent{Attack: p.attack, Condition: cond, Time: at, Dir: dir, Duration: p.cpu.String()}
+			body, _ := json.Marshal(&ev)
+			hdr := http.Header{"Content-Type": {"application/json"}}
+			if err := post(&p.client, p.webhook, body, nil, hdr); err != nil {
+				log.Printf("error notifying -profile-webhook: %v", err)
+			}
+		}()
+	}
+
+	// The heap profile is of the live objects as of the last GC.
+	runtime.GC()
+	for _, prof := range []struct {
+		name  string
+		file  string
+		debug int
+	}{
+		{"heap", "heap.pprof", 0},
+		{"goroutine", "goroutines.txt", 2},
+	} {
+		if err := writeProfile(filepath.Join(dir, prof.file), func(f *os.File) error {
+			return pprof.Lookup(prof.name).WriteTo(f, prof.debug)
+		}); err != nil {
+			log.Printf("error capturing %s profile: %v", prof.name, err)
+		}
+	}
+
+	// CPU profiles are cut short when the attack ends, and fail when the
+	// -profile global flag is profiling the whole process already.
+	if err := writeProfile(filepath.Join(dir, "cpu.pprof"), func(f *os.File) error {
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return err
+		}
+		defer pprof.StopCPUProfile()
+
+		select {
+		case <-time.After(p.cpu):
+		case <-p.done:
+		}
+		return nil
+	}); err != nil {
+		log.Printf("error capturing cpu profile: %v", err)
+	}
+}
+
+// close cuts short the capture in progress, if any, and waits for it.
+func (p *profiler) close() {
+	close(p.done)
+	p.wg.Wait()
+}
+
+// writeProfile creates the named file and writes a profile to it.
+func writeProfile(name string, write func(*os.File) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err = write(f); err != nil {
+		f.Close()
+		os.Remove(name)
+		return err
+	}
+	return f.Close()
+}
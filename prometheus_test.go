@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestPromSinkWriteRequest(t *testing.T) {
+	t.Parallel()
+
+	s := &promSink{attacks: map[string]*promAttack{}}
+	for _, r := range []vegeta.Result{
+		{Attack: "b", Code: 200, Latency: 5 * time.Millisecond, BytesIn: 10, BytesOut: 1},
+		{Attack: "a", Code: 200, Latency: time.Millisecond, BytesIn: 10, BytesOut: 1},
+		{Attack: "a", Code: 200, Latency: 10 * time.Millisecond, BytesIn: 20, BytesOut: 2},
+		{Attack: "a", Code: 500, Latency: 300 * time.Millisecond},
+		{Attack: "a", Code: 0, Latency: 20 * time.Second},
+	} {
+		r := r
+		if err := s.encode(&r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Unix(1584198566, 0)
+	req := s.writeRequest(now)
+
+	type sample struct {
+		labels string
+		value  float64
+	}
+
+	var got []sample
+	for _, ts := range req.Timeseries {
+		var labels []string
+		for _, l := range ts.Labels {
+			labels = append(labels, l.Name+"="+l.Value)
+		}
+
+		if len(ts.Samples) != 1 || ts.Samples[0].Timestamp != 1584198566000 {
+			t.Errorf("%v: got samples %v", labels, ts.Samples)
+			continue
+		}
+		got = append(got, sample{strings.Join(labels, ","), ts.Samples[0].Value})
+	}
+
+	// Attacks are sorted by name and the buckets are cumulative, with a latency
+	// on a bucket's upper bound counted in it.
+	want := []sample{
+		{"__name__=vegeta_requests_total,attack=a,code=0", 1},
+		{"__name__=vegeta_requests_total,attack=a,code=200", 2},
+		{"__name__=vegeta_requests_total,attack=a,code=500", 1},
+		{"__name__=vegeta_bytes_in_total,attack=a", 30},
+		{"__name__=vegeta_bytes_out_total,attack=a", 3},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=a,le=0.005", 1},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=a,le=0.01", 2},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=a,le=0.025", 2},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=a,le=0.05", 2},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=a,le=0.1", 2},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=a,le=0.25", 2},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=a,le=0.5", 3},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=a,le=1", 3},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=a,le=2.5", 3},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=a,le=5", 3},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=a,le=10", 3},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=a,le=+Inf", 4},
+		{"__name__=vegeta_request_duration_seconds_sum,attack=a", 20.311},
+		{"__name__=vegeta_request_duration_seconds_count,attack=a", 4},
+		{"__name__=vegeta_requests_total,attack=b,code=200", 1},
+		{"__name__=vegeta_bytes_in_total,attack=b", 10},
+		{"__name__=vegeta_bytes_out_total,attack=b", 1},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=b,le=0.005", 1},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=b,le=0.01", 1},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=b,le=0.025", 1},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=b,le=0.05", 1},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=b,le=0.1", 1},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=b,le=0.25", 1},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=b,le=0.5", 1},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=b,le=1", 1},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=b,le=2.5", 1},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=b,le=5", 1},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=b,le=10", 1},
+		{"__name__=vegeta_request_duration_seconds_bucket,attack=b,le=+Inf", 1},
+		{"__name__=vegeta_request_duration_seconds_sum,attack=b", 0.005},
+		{"__name__=vegeta_request_duration_seconds_count,attack=b", 1},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d samples, want %d: %v", len(got), len(want), got)
+	}
+
+	for i := range want {
+		if got[i].labels != want[i].labels || math.Abs(got[i].value-want[i].value) > 1e-9 {
+			t.Errorf("sample %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPrometheusSink(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		requests []*http.Request
+		bodies   [][]byte
+		status   int
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+		requests, bodies = append(requests, r), append(bodies, body)
+
+		if status != 0 {
+			http.Error(w, "out of order sample", status)
+		}
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		output string
+		hdr    http.Header
+		status int
+		user   string
+		err    string
+	}{
+		{"no auth", "prometheus+" + srv.URL + "/api/v1/write", nil, 0, "", ""},
+		{"basic auth", "prometheus+http://vegeta:s3cret@" + u.Host + "/api/v1/write", nil, 0, "vegeta", ""},
+		{"sink header", "prometheus+" + srv.URL + "/api/v1/write", http.Header{"X-Scope-Orgid": {"tenant"}}, 0, "", ""},
+		{"failed push", "prometheus+" + srv.URL + "/api/v1/write", nil, http.StatusBadRequest, "", "400 Bad Request: out of order sample"},
+	} {
+		mu.Lock()
+		requests, bodies, status = nil, nil, tc.status
+		mu.Unlock()
+
+		enc, closer, err := output(tc.output, "", 0, tc.hdr)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		for i := 0; i < 3; i++ {
+			if err = enc.Encode(&vegeta.Result{Attack: "checkout", Code: 200, Latency: time.Millisecond}); err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+		}
+
+		err = closer.Close()
+		if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%s: got error %v, want %q", tc.name, err, tc.err)
+		}
+
+		mu.Lock()
+		if len(requests) != 1 {
+			t.Fatalf("%s: got %d requests, want 1", tc.name, len(requests))
+		}
+
+		r := requests[0]
+		user, pass, ok := r.BasicAuth()
+		switch {
+		case r.Method != http.MethodPost || r.URL.Path != "/api/v1/write":
+			t.Errorf("%s: got %s %s, want POST /api/v1/write", tc.name, r.Method, r.URL.Path)
+		case r.Header.Get("Content-Type") != "application/x-protobuf" || r.Header.Get("Content-Encoding") != "snappy":
+			t.Errorf("%s: got content type %q and encoding %q", tc.name, r.Header.Get("Content-Type"), r.Header.Get("Content-Encoding"))
+		case r.Header.Get("X-Prometheus-Remote-Write-Version") != "0.1.0":
+			t.Errorf("%s: got remote write version %q", tc.name, r.Header.Get("X-Prometheus-Remote-Write-Version"))
+		case ok != (tc.user != "") || user != tc.user || ok && pass != "s3cret":
+			t.Errorf("%s: got basic auth %q:%q, want user %q", tc.name, user, pass, tc.user)
+		}
+
+		for k := range tc.hdr {
+			if got, want := r.Header.Get(k), tc.hdr.Get(k); got != want {
+				t.Errorf("%s: got header %s %q, want %q", tc.name, k, got, want)
+			}
+		}
+
+		// The body is a snappy compressed WriteRequest of the attack's metrics.
+		body, err := snappy.Decode(nil, bodies[0])
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+
+		for _, want := range []string{"vegeta_requests_total", "vegeta_request_duration_seconds_bucket", "checkout"} {
+			if !bytes.Contains(body, []byte(want)) {
+				t.Errorf("%s: got body %q without %q", tc.name, body, want)
+			}
+		}
+		mu.Unlock()
+	}
+}
+
+func TestPrometheusSinkPush(t *testing.T) {
+	t.Parallel()
+
+	pushing, release := make(chan struct{}, 1), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pushing <- struct{}{}
+		<-release
+	}))
+	defer srv.Close()
+
+	enc, closer, err := output("prometheus+"+srv.URL, "", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = enc.Encode(&vegeta.Result{Attack: "checkout", Code: 200}); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan error)
+	go func() { closed <- closer.Close() }()
+	<-pushing
+
+	// Results are encoded while the metrics are pushed.
+	encoded := make(chan error)
+	go func() { encoded <- enc.Encode(&vegeta.Result{Attack: "checkout", Code: 200}) }()
+
+	select {
+	case err = <-encoded:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("encode blocked by the push of the metrics")
+	}
+
+	close(release)
+	if err = <-closed; err != nil {
+		t.Fatal(err)
+	}
+}
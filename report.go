@@ -1,15 +1,23 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"os/signal"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 
 	vegeta "github.com/tsenart/vegeta/v12/lib"
+	"github.com/tsenart/vegeta/v12/lib/plot"
 )
 
 const reportUsage = `Usage: vegeta report [options] [<file>...]
@@ -18,30 +26,141 @@ Outputs a report of attack results.
 
 Arguments:
   <file>  A file with vegeta attack results encoded with one of
-          the supported encodings (gob | json | csv) [default: stdin]
+          the supported encodings (gob | json | csv | msgpack | parquet | protobuf) [default: stdin]
 
 Options:
-  --type    Which report type to generate (text | json | hist[buckets] | hdrplot).
+  --type    Which report type to generate
+            (text | json | hist[buckets] | hdrplot | hgrm | influx | cloudwatch |
+            junit | markdown | csv | html | slowest[N] | timeline[interval] |
+            heatmap[interval] | percentiles[interval] |
+            percentiles-json[interval] | sparklines[interval]).
             [default: text]
 
+  --columns Columns of the text report, in order (comma separated list of
+            requests, duration, latencies, bytes_in, bytes_out, success,
+            status_codes and errors). [default: all]
+
+  --color   Color the text report with ANSI escape codes: errors, error
+            status codes and success ratios below 100% in red, and latencies
+            degraded relative to the --baseline in yellow. [default: false]
+
+  --baseline   A results file which latencies of the text report are compared
+               against to color degraded ones.
+
+  --tolerance  Relative latency increase over the --baseline above which a
+               latency is considered degraded. [default: 0.1]
+
+  --duration-unit  Unit of all durations in the text and markdown reports
+                   (auto | ns | us | ms | s | m | h). auto formats each
+                   duration in the unit best suited to it. [default: auto]
+
+  --byte-unit      Unit of all byte sizes in the text and markdown reports
+                   (auto | B | KB | MB | GB). auto formats each byte size
+                   in the unit best suited to it. [default: auto]
+
+  --bars    Draw the hist report with unicode block bars and cumulative
+            percentages, scaled to the terminal width and colored by
+            percentile when writing to a terminal. [default: false]
+
   --every   Write the report to --output at every given interval (e.g 100ms)
             The default of 0 means the report will only be written after
-            all results have been processed. [default: 0]
+            all results have been processed. On a terminal, each report is
+            redrawn in place of the previous one, and the final report of
+            all results replaces the last one. [default: 0]
 
   --output  Output file [default: stdout]
 
+  --from    Only report results from the given RFC3339 time or offset from
+            the first result (e.g. 30s), to exclude a ramp up.
+
+  --to      Only report results until the given RFC3339 time or offset from
+            the first result (e.g. 5m), exclusive.
+
+  --attack  Only report results of the attack with the given name.
+
+  --status  Only report results with the given status codes, ranges or
+            classes (comma separated list), e.g. "5xx,429" or "200-299".
+
+  --url-regex  Only report results with target URLs matching the given
+               regular expression.
+
+  --label   Only report results with the given label (key=value), as set
+            with the attack -label flag (repeatable).
+
+  --group-by  Write a report of the given type for each value of the given
+              label, under a "==> label=value <==" heading, in order of
+              values. Results without the label are grouped under an empty
+              value. Given as header:name, e.g. header:X-Cache, results are
+              grouped by the value of that response header instead.
+
+  --by      Report each attempt at requests retried with the attack -retry
+            flag (attempt), or only the outcome of each request (request),
+            as clients see it: its last attempt, spanning all of them, from
+            when the first began. [default: attempt]
+
+  --metadata  Write the metadata recorded by the attacks of the results
+              (name, rate, duration, start time, targets hash, version and
+              arguments) before the final report. [default: false]
+
+  --tui     Render a live, full screen terminal dashboard of the results
+            at every --every interval, which defaults to 1s in this mode.
+
+  --slo     Service level objective asserted by the junit report as a test
+            case, e.g. "p99<300ms" or "success>=99.9%". Supports the min,
+            mean, max and pNN latencies, the success and error_rate ratios
+            and the requests, rate and throughput metrics compared with
+            <, <=, >, >=, == or != (repeatable). [default: success>=100%]
+
+  --webhook Webhook URL notified with a summary of the final metrics of
+            the results and whether they meet the --slo objectives.
+
+  --webhook-format  Webhook payload format (slack | teams | json)
+                    [default: slack]
+
+  --cloudwatch-namespace   CloudWatch namespace of the metrics published
+                           by the cloudwatch report. [default: Vegeta]
+
+  --cloudwatch-dimensions  CloudWatch dimensions (name=value) of the metrics
+                           published by the cloudwatch report (comma separated list)
+
 Examples:
   echo "GET http://:80" | vegeta attack -rate=10/s > results.gob
   echo "GET http://:80" | vegeta attack -rate=100/s | vegeta encode > results.json
   vegeta report results.*
+  vegeta report -from=30s -to=5m results.gob
+  vegeta report -attack=checkout -status=5xx -url-regex='/api/v2/' results.gob
+  vegeta report -label=build=1234 -group-by=region results.*
 `
 
 func reportCmd() command {
 	fs := flag.NewFlagSet("vegeta report", flag.ExitOnError)
-	typ := fs.String("type", "text", "Report type to generate [text, json, hist[buckets], hdrplot]")
-	every := fs.Duration("every", 0, "Report interval")
-	output := fs.String("output", "stdout", "Output file")
-	buckets := fs.String("buckets", "", "Histogram buckets, e.g.: \"[0,1ms,10ms]\"")
+	opts := &reportOpts{cloudwatchNamespace: "Vegeta", labels: labels{}}
+	fs.StringVar(&opts.typ, "type", "text", "Report type to generate [text, json, hist[buckets], hdrplot, hgrm, influx, cloudwatch, junit, markdown, csv, html, slowest[N], timeline[interval], heatmap[interval], percentiles[interval], percentiles-json[interval], sparklines[interval]]")
+	fs.DurationVar(&opts.every, "every", 0, "Report interval")
+	fs.Var(&opts.from, "from", "Only report results from this RFC3339 time or offset from the first result (e.g. 30s)")
+	fs.Var(&opts.to, "to", "Only report results until this RFC3339 time or offset from the first result (e.g. 5m)")
+	fs.StringVar(&opts.attack, "attack", "", "Only report results of the attack with this name")
+	fs.Var(&opts.status, "status", "Only report results with these status codes, ranges or classes, e.g. \"5xx,429\"")
+	fs.Var(&opts.urlRegex, "url-regex", "Only report results with target URLs matching this regular expression")
+	fs.Var(opts.labels, "label", "Only report results with this label, e.g. \"region=eu-west-1\" (repeatable)")
+	fs.StringVar(&opts.groupBy, "group-by", "", "Write a report for each value of this label, or of this response header, given as header:name, e.g. header:X-Cache")
+	fs.StringVar(&opts.by, "by", "attempt", "Report each attempt at requests, or only the outcome of each request, spanning all its attempts [attempt, request]")
+	fs.BoolVar(&opts.metadata, "metadata", false, "Write the metadata of the attacks of the results before the report")
+	fs.StringVar(&opts.output, "output", "stdout", "Output file")
+	fs.BoolVar(&opts.tui, "tui", false, "Render a live terminal dashboard")
+	fs.Var(&opts.columns, "columns", fmt.Sprintf("Columns of the text report [%s] (comma separated list)", strings.Join(vegeta.TextColumns, ", ")))
+	fs.BoolVar(&opts.color, "color", false, "Color the text report with ANSI escape codes")
+	fs.StringVar(&opts.baseline, "baseline", "", "Results file which latencies are compared against to color degraded ones in the text report")
+	fs.Float64Var(&opts.tolerance, "tolerance", 0.1, "Relative latency increase over the -baseline above which latencies are degraded")
+	fs.StringVar(&opts.durationUnit, "duration-unit", "auto", "Unit of durations in the text and markdown reports [auto, ns, us, ms, s, m, h]")
+	fs.StringVar(&opts.byteUnit, "byte-unit", "auto", "Unit of byte sizes in the text and markdown reports [auto, B, KB, MB, GB]")
+	fs.BoolVar(&opts.bars, "bars", false, "Draw the hist report with unicode block bars and cumulative percentages")
+	fs.StringVar(&opts.buckets, "buckets", "", "Histogram buckets, e.g.: \"[0,1ms,10ms]\"")
+	fs.Var(&opts.slos, "slo", "Service level objective asserted by the junit report and reported to the -webhook, e.g. \"p99<300ms\" (repeatable)")
+	fs.StringVar(&opts.webhook, "webhook", "", "Webhook URL notified with the final metrics of the results")
+	fs.StringVar(&opts.webhookFormat, "webhook-format", "slack", fmt.Sprintf("Webhook payload format [%s]", strings.Join(webhookFormats, ", ")))
+	fs.StringVar(&opts.cloudwatchNamespace, "cloudwatch-namespace", opts.cloudwatchNamespace, "CloudWatch namespace of the metrics published by the cloudwatch report")
+	fs.Var(&opts.cloudwatchDimensions, "cloudwatch-dimensions", "CloudWatch dimensions (name=value) of the metrics published by the cloudwatch report (comma separated list)")
 
 	fs.Usage = func() {
 		fmt.Fprintln(os.Stderr, reportUsage)
@@ -49,109 +168,769 @@ func reportCmd() command {
 
 	return command{fs, func(args []string) error {
 		fs.Parse(args)
-		files := fs.Args()
-		if len(files) == 0 {
-			files = append(files, "stdin")
+		opts.files = fs.Args()
+		if len(opts.files) == 0 {
+			opts.files = append(opts.files, "stdin")
 		}
-		return report(files, *typ, *output, *every, *buckets)
+		return report(opts)
 	}}
 }
 
-func report(files []string, typ, output string, every time.Duration, bucketsStr string) error {
+// reportOpts aggregates the report function command options
+type reportOpts struct {
+	files                []string
+	typ                  string
+	output               string
+	every                time.Duration
+	from, to             timeFlag
+	attack               string
+	status               statusList
+	urlRegex             regexpFlag
+	labels               labels
+	groupBy              string
+	by                   string
+	metadata             bool
+	tui                  bool
+	bars                 bool
+	buckets              string
+	slos                 sloList
+	webhook              string
+	webhookFormat        string
+	columns              csl
+	color                bool
+	baseline             string
+	tolerance            float64
+	durationUnit         string
+	byteUnit             string
+	cloudwatchNamespace  string
+	cloudwatchDimensions csl
+}
+
+// filter returns a filter of the results which match all the filtering
+// options, or nil if none are set.
+func (opts *reportOpts) filter() func(*vegeta.Result) bool {
+	var filters []func(*vegeta.Result) bool
+
+	// Requests are filtered by their outcome, which spans all their
+	// attempts by the time it's filtered, see report.
+	if opts.by == "request" {
+		filters = append(filters, vegeta.RequestOutcome)
+	}
+
+	if opts.from.set || opts.to.set {
+		filters = append(filters, timeRange(opts.from, opts.to))
+	}
+
+	if opts.attack != "" {
+		filters = append(filters, func(r *vegeta.Result) bool { return r.Attack == opts.attack })
+	}
+
+	if len(opts.status) > 0 {
+		filters = append(filters, opts.status.match)
+	}
+
+	if re := opts.urlRegex.Regexp; re != nil {
+		filters = append(filters, func(r *vegeta.Result) bool { return re.MatchString(r.URL) })
+	}
+
+	if len(opts.labels) > 0 {
+		filters = append(filters, opts.labels.match)
+	}
+
+	if len(filters) == 0 {
+		return nil
+	}
+
+	return func(r *vegeta.Result) bool {
+		for _, keep := range filters {
+			if !keep(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func report(opts *reportOpts) error {
+	typ := opts.typ
 	if len(typ) < 4 {
 		return fmt.Errorf("invalid report type: %s", typ)
 	}
 
-	dec, mc, err := decoder(files)
+	if opts.by != "attempt" && opts.by != "request" {
+		return fmt.Errorf("invalid -by: %s", opts.by)
+	}
+
+	if opts.tui {
+		if opts.groupBy != "" {
+			return fmt.Errorf("-group-by isn't supported with -tui")
+		}
+		typ = "tui"
+		if opts.every == 0 {
+			opts.every = time.Second
+		}
+	}
+
+	durationUnit, err := vegeta.ParseDurationUnit(opts.durationUnit)
+	if err != nil {
+		return err
+	}
+
+	byteUnit, err := vegeta.ParseByteUnit(opts.byteUnit)
+	if err != nil {
+		return err
+	}
+
+	units := vegeta.Units{Duration: durationUnit, Bytes: byteUnit}
+
+	// Only absolute time ranges are known before decoding the first result.
+	var mds metadataList
+	inputs, mc, err := inputDecoders(opts.files, opts.from.t, opts.to.t, mds.add)
 	defer mc.Close()
 	if err != nil {
 		return err
 	}
 
-	out, err := file(output, true)
+	dec, keep := roundRobin(inputs), opts.filter()
+	if opts.by == "request" {
+		dec = vegeta.NewMapDecoder(dec, vegeta.SpanAttempts)
+	}
+
+	if keep != nil {
+		dec = vegeta.NewFilterDecoder(dec, keep)
+	}
+
+	out, err := file(opts.output, true)
 	if err != nil {
 		return err
 	}
 	defer out.Close()
 
+	rep, report, err := newReport(opts, typ, units, out)
+	if err != nil {
+		return err
+	}
+
+	if opts.groupBy != "" {
+		g := &groups{label: opts.groupBy, newReport: func() (vegeta.Reporter, vegeta.Report, error) {
+			return newReport(opts, typ, units, out)
+		}}
+		rep, report = g.report, g
+	}
+
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, os.Interrupt)
+
+	var ticks <-chan time.Time
+	if opts.every > 0 {
+		ticker := time.NewTicker(opts.every)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
 	var (
-		rep    vegeta.Reporter
-		report vegeta.Report
+		wh *webhook
+		wm vegeta.Metrics
 	)
 
+	if opts.webhook != "" {
+		if wh, err = newWebhook(opts.webhook, opts.webhookFormat, opts.slos); err != nil {
+			return err
+		}
+	}
+
+	// Metrics are mergeable, so when only the final report of several inputs
+	// is written, they're decoded concurrently, unless results are filtered
+	// relative to the first one, which is only defined when decoding in order.
+	var shards []vegeta.Decoder
+	for _, decs := range inputs {
+		shards = append(shards, decs...)
+	}
+
+	fr := newFrames(out)
+	rc, _ := report.(vegeta.Closer)
+	if m, ok := report.(*vegeta.Metrics); ok && opts.every == 0 && len(shards) > 1 && !opts.from.relative() && !opts.to.relative() {
+		if err = decodeParallel(m, shards, keep, sigch); err != nil {
+			return err
+		}
+		if wh != nil {
+			wm.Merge(m)
+		}
+		dec = vegeta.NewChainDecoder()
+	}
+
+decode:
+	for {
+		select {
+		case <-sigch:
+			break decode
+		case <-ticks:
+			if err = fr.next(); err != nil {
+				return err
+			} else if err = writeReport(rep, rc, fr); err != nil {
+				return err
+			}
+		default:
+			var r vegeta.Result
+			if err = dec.Decode(&r); err != nil {
+				if err == io.EOF {
+					break decode
+				}
+				return err
+			}
+
+			report.Add(&r)
+			if wh != nil {
+				wm.Add(&r)
+			}
+		}
+	}
+
+	// The final summary replaces the last periodic report on terminals.
+	if err = fr.next(); err != nil {
+		return err
+	}
+
+	if opts.metadata {
+		if err = mds.write(fr); err != nil {
+			return err
+		}
+	}
+
+	// Timelines show the resource usage of the hosts alongside the results.
+	if t, ok := report.(*vegeta.StatusTimeline); ok {
+		mds.usage(func(_ *vegeta.Metadata, source string, at time.Time, u vegeta.HostUsage) error {
+			t.AddUsage(source, at, u)
+			return nil
+		})
+	}
+
+	// Results of attacks whose host was saturated don't reflect their targets.
+	for _, w := range mds.saturated() {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+	}
+
+	if err = writeReport(rep, rc, fr); err != nil || wh == nil {
+		return err
+	}
+
+	wm.Close()
+	return wh.notify("Vegeta report of "+strings.Join(opts.files, ", "), &wm)
+}
+
+// newReport returns the Reporter and Report of the given report type.
+func newReport(opts *reportOpts, typ string, units vegeta.Units, out *os.File) (rep vegeta.Reporter, report vegeta.Report, err error) {
+	bucketsStr := opts.buckets
 	switch typ {
 	case "plot":
-		return fmt.Errorf("The plot reporter has been deprecated and succeeded by the vegeta plot command")
+		return nil, nil, fmt.Errorf("The plot reporter has been deprecated and succeeded by the vegeta plot command")
 	case "text":
 		var m vegeta.Metrics
-		rep, report = vegeta.NewTextReporter(&m), &m
+		topts := vegeta.TextOptions{
+			Columns:   opts.columns,
+			Color:     opts.color,
+			Tolerance: opts.tolerance,
+			Units:     units,
+		}
+		if opts.baseline != "" {
+			var lats []time.Duration
+			topts.Baseline = &vegeta.Metrics{}
+			if err = metricsOf(opts.baseline, topts.Baseline, &lats); err != nil {
+				return nil, nil, err
+			}
+		}
+		if rep, err = vegeta.NewCustomTextReporter(&m, topts); err != nil {
+			return nil, nil, err
+		}
+		report = &m
 	case "json":
 		var m vegeta.Metrics
 		if bucketsStr != "" {
 			m.Histogram = &vegeta.Histogram{}
 			if err := m.Histogram.Buckets.UnmarshalText([]byte(bucketsStr)); err != nil {
-				return err
+				return nil, nil, err
 			}
 		}
 		rep, report = vegeta.NewJSONReporter(&m), &m
+	case "markdown":
+		var m vegeta.Metrics
+		if bucketsStr != "" {
+			m.Histogram = &vegeta.Histogram{}
+			if err := m.Histogram.Buckets.UnmarshalText([]byte(bucketsStr)); err != nil {
+				return nil, nil, err
+			}
+		}
+		rep, report = vegeta.NewCustomMarkdownReporter(&m, units), &m
 	case "hdrplot":
 		var m vegeta.Metrics
 		rep, report = vegeta.NewHDRHistogramPlotReporter(&m), &m
+	case "hgrm":
+		var m vegeta.Metrics
+		rep, report = vegeta.NewHGRMReporter(&m), &m
+	case "influx":
+		var m vegeta.Metrics
+		rep, report = vegeta.NewInfluxReporter(&m), &m
+	case "csv":
+		var m vegeta.Metrics
+		rep, report = vegeta.NewCSVReporter(&m), &m
+	case "tui":
+		d := &dashboard{}
+		rep, report = d.report, d
+	case "html":
+		r := plot.NewReport()
+		rep = func(w io.Writer) error {
+			_, err := r.WriteTo(w)
+			return err
+		}
+		report = r
+	case "junit":
+		var m vegeta.Metrics
+		rep, report = vegeta.NewJUnitReporter(&m, opts.slos), &m
+	case "cloudwatch":
+		var m vegeta.Metrics
+		if rep, err = newCloudWatchReporter(&m, opts.cloudwatchNamespace, opts.cloudwatchDimensions); err != nil {
+			return nil, nil, err
+		}
+		report = &m
 	default:
 		switch {
 		case strings.HasPrefix(typ, "hist"):
 			var hist vegeta.Histogram
-			if bucketsStr == "" { // Old way
+			if bucketsStr == "" && typ != "hist" { // Old way
 				if len(typ) < 6 {
-					return fmt.Errorf("bad buckets: '%s'", typ[4:])
+					return nil, nil, fmt.Errorf("bad buckets: '%s'", typ[4:])
+				}
+				if bucketsStr = typ[4:]; strings.HasPrefix(bucketsStr, "[exp(") {
+					bucketsStr = bucketsStr[1 : len(bucketsStr)-1]
 				}
-				bucketsStr = typ[4:]
 			}
-			if err := hist.Buckets.UnmarshalText([]byte(bucketsStr)); err != nil {
-				return err
+			// Without buckets, they're derived from the observed latencies.
+			if bucketsStr != "" {
+				if err := hist.Buckets.UnmarshalText([]byte(bucketsStr)); err != nil {
+					return nil, nil, err
+				}
+			}
+			if opts.bars {
+				width, color := histWidth(out)
+				rep, report = vegeta.NewHistogramBarReporter(&hist, width, color), &hist
+			} else {
+				rep, report = vegeta.NewHistogramReporter(&hist), &hist
+			}
+		case strings.HasPrefix(typ, "slowest"):
+			s := vegeta.Slowest{N: 10}
+			if n := strings.TrimPrefix(typ, "slowest"); n != "" {
+				if !strings.HasPrefix(n, "[") || !strings.HasSuffix(n, "]") {
+					return nil, nil, fmt.Errorf("bad slowest count: '%s'", n)
+				} else if s.N, err = strconv.Atoi(n[1 : len(n)-1]); err != nil || s.N <= 0 {
+					return nil, nil, fmt.Errorf("bad slowest count: '%s'", n)
+				}
+			}
+			rep, report = vegeta.NewSlowestReporter(&s), &s
+		case strings.HasPrefix(typ, "timeline"):
+			t := vegeta.StatusTimeline{}
+			if t.Interval, err = reportInterval(typ, "timeline"); err != nil {
+				return nil, nil, err
+			}
+			rep, report = vegeta.NewStatusTimelineReporter(&t), &t
+		case strings.HasPrefix(typ, "heatmap"):
+			h := vegeta.Heatmap{}
+			if h.Interval, err = reportInterval(typ, "heatmap"); err != nil {
+				return nil, nil, err
+			}
+			rep, report = vegeta.NewHeatmapReporter(&h), &h
+		case strings.HasPrefix(typ, "sparklines"):
+			sl := vegeta.Sparklines{}
+			if sl.Interval, err = reportInterval(typ, "sparklines"); err != nil {
+				return nil, nil, err
+			}
+			rep, report = vegeta.NewSparklinesReporter(&sl), &sl
+		case strings.HasPrefix(typ, "percentiles-json"):
+			p := vegeta.PercentileTimeline{}
+			if p.Interval, err = reportInterval(typ, "percentiles-json"); err != nil {
+				return nil, nil, err
+			}
+			rep, report = vegeta.NewPercentileTimelineJSONReporter(&p), &p
+		case strings.HasPrefix(typ, "percentiles"):
+			p := vegeta.PercentileTimeline{}
+			if p.Interval, err = reportInterval(typ, "percentiles"); err != nil {
+				return nil, nil, err
 			}
-			rep, report = vegeta.NewHistogramReporter(&hist), &hist
+			rep, report = vegeta.NewPercentileTimelineCSVReporter(&p), &p
 		default:
-			return fmt.Errorf("unknown report type: %q", typ)
+			return nil, nil, fmt.Errorf("unknown report type: %q", typ)
 		}
 	}
 
-	sigch := make(chan os.Signal, 1)
-	signal.Notify(sigch, os.Interrupt)
+	return rep, report, nil
+}
 
-	var ticks <-chan time.Time
-	if every > 0 {
-		ticker := time.NewTicker(every)
-		defer ticker.Stop()
-		ticks = ticker.C
+// groups is a Report of Results grouped by the value of a label, each group
+// of which is added to its own report of the same type.
+type groups struct {
+	label     string
+	newReport func() (vegeta.Reporter, vegeta.Report, error)
+	values    []string
+	reports   map[string]group
+	err       error
+}
+
+type group struct {
+	rep    vegeta.Reporter
+	report vegeta.Report
+}
+
+// value returns the value of the label, or of the response header given as
+// header:name, of the given result which it's grouped by.
+func (g *groups) value(r *vegeta.Result) string {
+	if name := strings.TrimPrefix(g.label, "header:"); name != g.label {
+		return r.Headers.Get(name)
 	}
+	return r.Labels[g.label]
+}
 
-	rc, _ := report.(vegeta.Closer)
-decode:
-	for {
-		select {
-		case <-sigch:
-			break decode
-		case <-ticks:
-			if err = clear(out); err != nil {
+func (g *groups) Add(r *vegeta.Result) {
+	v := g.value(r)
+	gr, ok := g.reports[v]
+	if !ok {
+		if g.err != nil {
+			return
+		}
+
+		if gr.rep, gr.report, g.err = g.newReport(); g.err != nil {
+			return
+		}
+
+		if g.reports == nil {
+			g.reports = map[string]group{}
+		}
+
+		g.reports[v] = gr
+		g.values = append(g.values, v)
+		sort.Strings(g.values)
+	}
+
+	gr.report.Add(r)
+}
+
+func (g *groups) Close() {
+	for _, gr := range g.reports {
+		if rc, ok := gr.report.(vegeta.Closer); ok {
+			rc.Close()
+		}
+	}
+}
+
+func (g *groups) report(w io.Writer) error {
+	if g.err != nil {
+		return g.err
+	}
+
+	for i, v := range g.values {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
 				return err
-			} else if err = writeReport(rep, rc, out); err != nil {
+			}
+		}
+
+		if _, err := fmt.Fprintf(w, "==> %s=%s <==\n", g.label, v); err != nil {
+			return err
+		}
+
+		if err := g.reports[v].rep.Report(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// metadataList collects the distinct metadata of the attacks of decoded
+// results, which may be decoded concurrently, along with their annotations.
+type metadataList struct {
+	mu          sync.Mutex
+	list        []vegeta.Metadata
+	annotations map[int][]vegeta.Annotation
+}
+
+func (l *metadataList) add(md *vegeta.Metadata) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a := md.Annotation
+	if a != nil {
+		m := *md
+		m.Annotation = nil
+		md = &m
+	}
+
+	// Rotated output files all begin with the metadata of their attack.
+	i := 0
+	for i < len(l.list) && !l.list[i].Equal(md) {
+		i++
+	}
+
+	if i == len(l.list) {
+		l.list = append(l.list, *md)
+	}
+
+	if a == nil {
+		return
+	}
+
+	for _, b := range l.annotations[i] {
+		if b.Event == a.Event && b.Source == a.Source && b.Time.Equal(a.Time) {
+			return
+		}
+	}
+
+	if l.annotations == nil {
+		l.annotations = map[int][]vegeta.Annotation{}
+	}
+	l.annotations[i] = append(l.annotations[i], *a)
+}
+
+// summary returns a summary of the attacks, e.g. "checkout at 50/1s for 5m0s".
+func (l *metadataList) summary() string {
+	attacks := make([]string, 0, len(l.list))
+	for _, md := range l.list {
+		s := md.Attack
+		if s == "" {
+			s = "attack"
+		}
+
+		if s += " at " + md.Rate; md.Duration > 0 {
+			s += " for " + md.Duration.String()
+		}
+
+		attacks = append(attacks, s)
+	}
+	return strings.Join(attacks, ", ")
+}
+
+// saturated returns a warning for each attack whose host was saturated in
+// some of the samples of its resource usage, with the peak usage of the
+// saturated resources.
+func (l *metadataList) saturated() []string {
+	var warnings []string
+	for i, md := range l.list {
+		var (
+			samples, saturated int
+			from, to           time.Time
+			peak               vegeta.HostUsage
+		)
+
+		as := l.annotations[i]
+		sort.Slice(as, func(i, j int) bool { return as[i].Time.Before(as[j].Time) })
+		for _, a := range as {
+			if a.Event != vegeta.AnnotationHost || a.Host == nil {
+				continue
+			}
+
+			if samples++; len(a.Host.Saturated()) == 0 {
+				continue
+			}
+
+			if saturated++; from.IsZero() {
+				from = a.Time
+			}
+			to = a.Time
+
+			u := a.Host
+			peak.CPU = math.Max(peak.CPU, u.CPU)
+			peak.Memory = math.Max(peak.Memory, u.Memory)
+			if u.FDLimit > 0 && (peak.FDLimit == 0 || float64(u.FDs)/float64(u.FDLimit) > float64(peak.FDs)/float64(peak.FDLimit)) {
+				peak.FDs, peak.FDLimit = u.FDs, u.FDLimit
+			}
+		}
+
+		if saturated == 0 {
+			continue
+		}
+
+		name := md.Attack
+		if name == "" {
+			name = "the attack"
+		}
+
+		warnings = append(warnings, fmt.Sprintf(
+			"the host of %s was saturated in %d of %d samples from %s to %s (%s), so its results may not reflect its targets",
+			name, saturated, samples, from.Format(time.RFC3339), to.Format(time.RFC3339), strings.Join(peak.Saturated(), ", "),
+		))
+	}
+	return warnings
+}
+
+// usage calls the given function with each sample of the resource usage of
+// the hosts of each attack, in time order, along with the address of the agent
+// which sampled it, or "attacker" for those of the host of the attack.
+func (l *metadataList) usage(fn func(md *vegeta.Metadata, source string, at time.Time, u vegeta.HostUsage) error) error {
+	for i := range l.list {
+		as := l.annotations[i]
+		sort.Slice(as, func(i, j int) bool { return as[i].Time.Before(as[j].Time) })
+		for _, a := range as {
+			if a.Host == nil {
+				continue
+			}
+
+			source := a.Source
+			if a.Event == vegeta.AnnotationHost {
+				source = "attacker"
+			} else if a.Event != vegeta.AnnotationAgent {
+				continue
+			}
+
+			if err := fn(&l.list[i], source, a.Time, *a.Host); err != nil {
 				return err
 			}
-		default:
+		}
+	}
+	return nil
+}
+
+// write writes the metadata of each attack as a block of aligned fields.
+func (l *metadataList) write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for i, md := range l.list {
+		fmt.Fprintf(tw, "Attack\t%s\n", md.Attack)
+		fmt.Fprintf(tw, "Rate\t%s\n", md.Rate)
+		fmt.Fprintf(tw, "Duration\t%s\n", md.Duration)
+		fmt.Fprintf(tw, "Began\t%s\n", md.Began.Format(time.RFC3339))
+		fmt.Fprintf(tw, "Targets\t%s\n", md.Targets)
+		fmt.Fprintf(tw, "Version\t%s\n", md.Version)
+		fmt.Fprintf(tw, "Args\t%s\n", strings.Join(md.Args, " "))
+
+		as := l.annotations[i]
+		sort.Slice(as, func(i, j int) bool { return as[i].Time.Before(as[j].Time) })
+		for _, a := range as {
+			// Host samples are summarized by saturated and timelines instead.
+			if a.Event != vegeta.AnnotationHost && a.Event != vegeta.AnnotationAgent {
+				fmt.Fprintf(tw, "%s\t%s\n", strings.Title(a.Event), a.Time.Format(time.RFC3339Nano))
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// decodeParallel decodes the given Decoders concurrently, with up to as many
+// goroutines as there are CPUs, each of which adds the Results it decodes to
+// its own Metrics which is then merged into m. Decoding stops early when an
+// interrupt signal is received.
+func decodeParallel(m *vegeta.Metrics, decs []vegeta.Decoder, keep func(*vegeta.Result) bool, sigch <-chan os.Signal) error {
+	queue := make(chan vegeta.Decoder, len(decs))
+	for _, dec := range decs {
+		queue <- dec
+	}
+	close(queue)
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(decs) {
+		workers = len(decs)
+	}
+
+	var (
+		stop     = make(chan struct{})
+		stopOnce sync.Once
+		done     = make(chan struct{})
+	)
+
+	defer close(done)
+	go func() {
+		select {
+		case <-sigch:
+			stopOnce.Do(func() { close(stop) })
+		case <-done:
+		}
+	}()
+
+	type partial struct {
+		m   *vegeta.Metrics
+		err error
+	}
+
+	partials := make(chan partial, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			pm := &vegeta.Metrics{}
+			if m.Histogram != nil {
+				pm.Histogram = &vegeta.Histogram{Buckets: m.Histogram.Buckets}
+			}
+			partials <- partial{pm, decodeMetrics(pm, queue, keep, stop)}
+		}()
+	}
+
+	var err error
+	for i := 0; i < workers; i++ {
+		p := <-partials
+		if p.err != nil && err == nil {
+			err = p.err
+			stopOnce.Do(func() { close(stop) })
+		}
+		m.Merge(p.m)
+	}
+
+	return err
+}
+
+// decodeMetrics adds the Results of the Decoders it receives from the given
+// queue to m, until the queue is drained or stop is closed.
+func decodeMetrics(m *vegeta.Metrics, queue <-chan vegeta.Decoder, keep func(*vegeta.Result) bool, stop <-chan struct{}) error {
+	for dec := range queue {
+		for {
+			select {
+			case <-stop:
+				return nil
+			default:
+			}
+
 			var r vegeta.Result
-			if err = dec.Decode(&r); err != nil {
-				if err == io.EOF {
-					break decode
-				}
+			if err := dec.Decode(&r); err == io.EOF {
+				break
+			} else if err != nil {
 				return err
 			}
 
-			report.Add(&r)
+			if keep == nil || keep(&r) {
+				m.Add(&r)
+			}
 		}
 	}
+	return nil
+}
+
+// reportInterval parses the optional interval of a report type given
+// as name[interval], which defaults to 1s.
+func reportInterval(typ, name string) (time.Duration, error) {
+	i := strings.TrimPrefix(typ, name)
+	if i == "" {
+		return time.Second, nil
+	}
+
+	if !strings.HasPrefix(i, "[") || !strings.HasSuffix(i, "]") {
+		return 0, fmt.Errorf("bad %s interval: '%s'", name, i)
+	}
+
+	d, err := time.ParseDuration(i[1 : len(i)-1])
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("bad %s interval: '%s'", name, i)
+	}
 
-	return writeReport(rep, rc, out)
+	return d, nil
+}
+
+// histWidth returns the width the hist report's bars should fill and whether
+// they should be colored, which they are when writing to a terminal unless
+// NO_COLOR is set. Its width falls back to $COLUMNS or 80 columns otherwise.
+func histWidth(out *os.File) (width int, color bool) {
+	if width, ok := terminalWidth(out); ok {
+		return width, os.Getenv("NO_COLOR") == ""
+	}
+
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width, false
+	}
+
+	return 80, false
 }
 
 func writeReport(r vegeta.Reporter, rc vegeta.Closer, out io.Writer) error {
@@ -161,9 +940,32 @@ func writeReport(r vegeta.Reporter, rc vegeta.Closer, out io.Writer) error {
 	return r.Report(out)
 }
 
-func clear(out io.Writer) error {
-	if f, ok := out.(*os.File); ok && f == os.Stdout {
-		return clearScreen()
+// frames is an io.Writer of periodic reports which, when writing to a
+// terminal, redraws each report in place of the previous one instead of
+// appending it.
+type frames struct {
+	out   io.Writer
+	tty   bool
+	lines int // lines written in the current frame
+}
+
+func newFrames(out *os.File) *frames {
+	fi, err := out.Stat()
+	return &frames{out: out, tty: err == nil && fi.Mode()&os.ModeCharDevice != 0}
+}
+
+func (f *frames) Write(p []byte) (int, error) {
+	f.lines += bytes.Count(p, []byte("\n"))
+	return f.out.Write(p)
+}
+
+// next starts a new frame, erasing the previous one on terminals.
+func (f *frames) next() error {
+	if !f.tty || f.lines == 0 {
+		return nil
 	}
-	return nil
+
+	n := f.lines
+	f.lines = 0
+	return eraseLines(f.out, n)
 }
@@ -3,12 +3,26 @@
 package main
 
 import (
+	"fmt"
+	"io"
 	"os"
-)
 
-var escCodes = []byte("\033[2J\033[0;0H")
+	"golang.org/x/sys/unix"
+)
 
-func clearScreen() error {
-	_, err := os.Stdout.Write(escCodes)
+// eraseLines moves the cursor of the terminal up by n lines and erases
+// everything below it.
+func eraseLines(w io.Writer, n int) error {
+	_, err := fmt.Fprintf(w, "\033[%dA\r\033[J", n)
 	return err
 }
+
+// terminalWidth returns the width in columns of the terminal f refers to,
+// or false if it doesn't refer to a terminal.
+func terminalWidth(f *os.File) (int, bool) {
+	ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 {
+		return 0, false
+	}
+	return int(ws.Col), true
+}
@@ -3,12 +3,21 @@
 package main
 
 import (
+	"io"
 	"os"
 	"os/exec"
 )
 
-func clearScreen() error {
+// eraseLines clears the console, since the cursor can't be moved with
+// ANSI escape codes in all Windows consoles.
+func eraseLines(w io.Writer, n int) error {
 	cmd := exec.Command("cmd", "/c", "cls")
 	cmd.Stdout = os.Stdout
 	return cmd.Run()
 }
+
+// terminalWidth returns the width in columns of the terminal f refers to,
+// or false if it doesn't refer to a terminal or it can't be determined.
+func terminalWidth(f *os.File) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// rotatingEncoder writes Results to consecutively numbered shards of an
+// output name pattern like results-%d.bin, starting a new shard once the
+// current one has reached a given size in encoded bytes.
+type rotatingEncoder struct {
+	pattern  string
+	encoding string
+	size     int64
+	shard    int
+	written  int64
+	enc      vegeta.Encoder
+	closer   io.Closer
+	metadata *vegeta.Result
+}
+
+func newRotatingEncoder(pattern, encoding string, size int64) (*rotatingEncoder, error) {
+	if strings.Count(pattern, "%d") != 1 {
+		return nil, errors.New("rotated outputs must contain one %d placeholder for the shard number")
+	}
+
+	if _, _, err := encoder(encoding, ioutil.Discard); err != nil {
+		return nil, err
+	}
+
+	re := &rotatingEncoder{pattern: pattern, encoding: encoding, size: size, shard: -1}
+	return re, re.rotate()
+}
+
+// Encode encodes the given Result to the current shard, after rotating to
+// the next one if the current one is full. Metadata records are written at
+// the start of every following shard too, except annotated ones.
+func (re *rotatingEncoder) Encode(r *vegeta.Result) error {
+	if r.Metadata != nil && r.Metadata.Annotation == nil {
+		md := *r
+		re.metadata = &md
+	}
+
+	if re.written >= re.size {
+		if err := re.rotate(); err != nil {
+			return err
+		}
+	}
+	return re.enc.Encode(r)
+}
+
+func (re *rotatingEncoder) rotate() error {
+	if err := re.Close(); err != nil {
+		return err
+	}
+
+	re.shard++
+	out, err := create(fmt.Sprintf(re.pattern, re.shard))
+	if err != nil {
+		return err
+	}
+
+	enc, closer, err := encoder(re.encoding, &countingWriter{out, &re.written})
+	if err != nil {
+		out.Close()
+		return err
+	}
+
+	re.enc, re.closer, re.written = enc, multiCloser{closer, out}, 0
+	if re.metadata != nil {
+		return re.enc.Encode(re.metadata)
+	}
+	return nil
+}
+
+// Close closes the current shard.
+func (re *rotatingEncoder) Close() error {
+	if re.closer == nil {
+		return nil
+	}
+
+	err := re.closer.Close()
+	re.closer = nil
+	return err
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	io.Writer
+	n *int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	*w.n += int64(n)
+	return n, err
+}
+
+// shards returns the names of the files matched by the given input name,
+// in shard order, if it's a glob or a rotated output pattern with a %d shard
+// number placeholder. Other names are returned as is.
+func shards(name string) ([]string, error) {
+	if _, _, _, ok := objectURL(name); ok {
+		return []string{name}, nil
+	}
+
+	glob := strings.Replace(name, "%d", "*", -1)
+	if !strings.ContainsAny(glob, "*?[") {
+		return []string{name}, nil
+	}
+
+	names, err := filepath.Glob(glob)
+	if err != nil {
+		return nil, err
+	} else if len(names) == 0 {
+		return nil, fmt.Errorf("no files match %q", name)
+	}
+
+	sort.Slice(names, func(i, j int) bool { return naturalLess(names[i], names[j]) })
+
+	return names, nil
+}
+
+// naturalLess orders strings with their digit sequences compared by their
+// numeric value, so that results-10.bin sorts after results-9.bin.
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		if !isDigit(a[0]) || !isDigit(b[0]) {
+			if a[0] != b[0] {
+				return a[0] < b[0]
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		da, db := digits(a), digits(b)
+		na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+		if len(na) != len(nb) {
+			return len(na) < len(nb)
+		} else if na != nb {
+			return na < nb
+		}
+
+		a, b = a[len(da):], b[len(db):]
+	}
+
+	return len(a) < len(b)
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func digits(s string) string {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i]
+}
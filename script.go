@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/tsenart/vegeta/v12/internal/script"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// scriptHooks calls the hooks of a -hook-command.
+type scriptHooks struct {
+	*script.Process
+}
+
+// beforeRequest calls the beforeRequest hook of the script with the given
+// request, as a JSON target, and applies the target it replies with, if not
+// null, failing the request with the error it replies with, if any.
+func (h scriptHooks) beforeRequest(_ *vegeta.Target, req *http.Request) error {
+	tgt, err := requestTarget(req)
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(&tgt)
+	if err != nil {
+		return err
+	}
+
+	if value, err = h.Call("beforeRequest", value); err != nil {
+		return err
+	} else if isNull(value) {
+		return nil
+	}
+
+	tgt = vegeta.Target{}
+	if err = json.Unmarshal(value, &tgt); err != nil {
+		return err
+	}
+
+	return setTarget(req, &tgt)
+}
+
+// afterResponse calls the afterResponse hook of the script with the given
+// result and merges the one it replies with, if not null, into it, or else
+// fails it with the error it replies with, if any.
+func (h scriptHooks) afterResponse(r *vegeta.Result) {
+	value, err := json.Marshal(r)
+	if err == nil {
+		value, err = h.Call("afterResponse", value)
+	}
+
+	if err == nil && !isNull(value) {
+		err = mergeResult(r, value)
+	}
+
+	if err != nil {
+		r.Error = "script: " + err.Error()
+	}
+}
+
+// mergeResult sets the fields of the given result to those of the given JSON
+// result, keeping those it leaves out. Headers and labels replace the result's
+// rather than being added to them. The result is left as is on errors.
+func mergeResult(r *vegeta.Result, value json.RawMessage) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(value, &fields); err != nil {
+		return err
+	}
+
+	res := *r
+	if _, ok := fields["headers"]; ok {
+		res.Headers = nil
+	}
+	if _, ok := fields["request_headers"]; ok {
+		res.RequestHeaders = nil
+	}
+	if _, ok := fields["labels"]; ok {
+		res.Labels = nil
+	}
+
+	if err := json.Unmarshal(value, &res); err != nil {
+		return err
+	}
+
+	*r = res
+	return nil
+}
+
+// requestTarget returns the target of the given request.
+func requestTarget(req *http.Request) (vegeta.Target, error) {
+	tgt := vegeta.Target{Method: req.Method, URL: req.URL.String(), Header: req.Header}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return tgt, err
+		}
+		if tgt.Body, err = ioutil.ReadAll(body); err != nil {
+			return tgt, err
+		}
+	}
+	return tgt, nil
+}
+
+// setTarget replaces the method, URL, headers and body of the given request
+// with those of the given target.
+func setTarget(req *http.Request, tgt *vegeta.Target) error {
+	r, err := tgt.Request()
+	if err != nil {
+		return err
+	}
+
+	req.Method, req.URL, req.Host, req.Header = r.Method, r.URL, r.Host, r.Header
+	req.Body, req.GetBody, req.ContentLength = r.Body, r.GetBody, r.ContentLength
+	return nil
+}
+
+func isNull(value json.RawMessage) bool {
+	return len(value) == 0 || bytes.Equal(value, []byte("null"))
+}
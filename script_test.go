@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestMergeResult(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2020, 3, 14, 15, 9, 26, 0, time.UTC)
+	result := func() vegeta.Result {
+		return vegeta.Result{
+			Attack:     "checkout",
+			Seq:        7,
+			Code:       200,
+			Timestamp:  ts,
+			Latency:    120 * time.Millisecond,
+			URL:        "http://localhost/",
+			Headers:    http.Header{"Content-Type": {"text/plain"}, "Server": {"nginx"}},
+			Labels:     map[string]string{"region": "eu"},
+			RemoteAddr: "127.0.0.1:80",
+		}
+	}
+
+	for _, tc := range []struct {
+		name  string
+		reply string
+		want  func(*vegeta.Result)
+		err   bool
+	}{
+		{"empty", `{}`, func(*vegeta.Result) {}, false},
+		{
+			"error only",
+			`{"error":"slower than 100ms"}`,
+			func(r *vegeta.Result) { r.Error = "slower than 100ms" },
+			false,
+		},
+		{
+			"headers replaced",
+			`{"headers":{"Server":["envoy"]},"labels":{"tier":"gold"}}`,
+			func(r *vegeta.Result) {
+				r.Headers = http.Header{"Server": {"envoy"}}
+				r.Labels = map[string]string{"tier": "gold"}
+			},
+			false,
+		},
+		{"bad reply", `{"code":"ok","error":"x"}`, func(*vegeta.Result) {}, true},
+		{"not an object", `[1]`, func(*vegeta.Result) {}, true},
+	} {
+		got, want := result(), result()
+		tc.want(&want)
+
+		err := mergeResult(&got, json.RawMessage(tc.reply))
+		if (err != nil) != tc.err {
+			t.Errorf("%s: got error %v, want error %v", tc.name, err, tc.err)
+		}
+
+		// Fields left out of the reply, or all of them on errors, are kept.
+		if !got.Equal(want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// A sinkFactory returns an Encoder that writes Results to the destination
+// identified by the given URL, along with an io.Closer that flushes any
+// buffered Results and releases the resources held by the sink.
+// HTTP based sinks send the given headers with each of their requests.
+type sinkFactory func(u *url.URL, hdr http.Header) (vegeta.Encoder, io.Closer, error)
+
+// sinks maps the URL schemes accepted by the attack -output flag to their
+// sinkFactory. Outputs without a registered scheme are treated as files.
+var sinks = map[string]sinkFactory{
+	"influx+http":         influxSink,
+	"influx+https":        influxSink,
+	"prometheus+http":     prometheusSink,
+	"prometheus+https":    prometheusSink,
+	"elasticsearch+http":  elasticsearchSink,
+	"elasticsearch+https": elasticsearchSink,
+	"opensearch+http":     elasticsearchSink,
+	"opensearch+https":    elasticsearchSink,
+	"kafka":               kafkaSink,
+	"tcp":                 tcpSink,
+}
+
+// output returns an Encoder writing to the given -output destination.
+// Destinations which aren't sink URLs are created as files, compressed
+// according to their extension, and written to with the Encoder of the given
+// encoding. When rotate is positive, files are rotated once they reach that
+// many encoded bytes.
+func output(name, encoding string, rotate int64, hdr http.Header) (vegeta.Encoder, io.Closer, error) {
+	if u, err := url.Parse(name); err == nil {
+		if sink, ok := sinks[u.Scheme]; ok {
+			enc, closer, err := sink(u, hdr)
+			if err != nil {
+				return nil, nil, err
+			} else if u.Scheme == "tcp" {
+				return enc, closer, nil // Collectors write result streams.
+			}
+			return withoutMetadata(enc), closer, nil
+		}
+	}
+
+	if rotate > 0 {
+		re, err := newRotatingEncoder(name, encoding, rotate)
+		if err != nil {
+			return nil, nil, err
+		}
+		return re.Encode, re, nil
+	}
+
+	out, err := create(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	enc, closer, err := encoder(encoding, out)
+	if err != nil {
+		out.Close()
+		return nil, nil, err
+	}
+
+	return enc, multiCloser{closer, out}, nil
+}
+
+// withoutMetadata returns an Encoder which skips metadata records, for outputs
+// of data points rather than result streams.
+func withoutMetadata(enc vegeta.Encoder) vegeta.Encoder {
+	return func(r *vegeta.Result) error {
+		if r.Metadata != nil {
+			return nil
+		}
+		return enc.Encode(r)
+	}
+}
+
+// influxSink writes Results as InfluxDB line protocol points to the write
+// endpoint given by the URL without its "influx+" scheme prefix.
+// e.g. influx+http://localhost:8086/write?db=vegeta
+func influxSink(u *url.URL, hdr http.Header) (vegeta.Encoder, io.Closer, error) {
+	w := newBatchWriter(trimSchemePrefix(u), "text/plain; charset=utf-8", hdr)
+	return vegeta.NewInfluxEncoder(w), w, nil
+}
+
+// elasticsearchSink bulk indexes Results as JSON documents into the
+// Elasticsearch or OpenSearch cluster at the given URL, without its scheme
+// prefix. The URL path is the index name template, which defaults to
+// vegeta-{date}. See vegeta.NewElasticsearchEncoder for its placeholders.
+// e.g. elasticsearch+http://localhost:9200/vegeta-{attack}-{date}
+func elasticsearchSink(u *url.URL, hdr http.Header) (vegeta.Encoder, io.Closer, error) {
+	index := strings.Trim(u.Path, "/")
+	if index == "" {
+		index = "vegeta-{date}"
+	}
+
+	bulk := *u
+	bulk.Path, bulk.RawPath = "/_bulk", ""
+
+	w := newBatchWriter(trimSchemePrefix(&bulk), "application/x-ndjson", hdr)
+	w.check = checkBulkResponse
+
+	return vegeta.NewElasticsearchEncoder(w, index), w, nil
+}
+
+// checkBulkResponse returns an error describing the first failed item
+// of a bulk API response, if any.
+func checkBulkResponse(body io.Reader) error {
+	var res struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+
+	if err := json.NewDecoder(body).Decode(&res); err != nil || !res.Errors {
+		return err
+	}
+
+	for _, item := range res.Items {
+		for action, r := range item {
+			if len(r.Error) > 0 {
+				return fmt.Errorf("bulk %s failed with status %d: %s", action, r.Status, r.Error)
+			}
+		}
+	}
+
+	return errors.New("bulk request failed")
+}
+
+// trimSchemePrefix returns the string form of the given URL with
+// its scheme stripped up to and including the first "+".
+func trimSchemePrefix(u *url.URL) string {
+	v := *u
+	if i := strings.IndexByte(v.Scheme, '+'); i >= 0 {
+		v.Scheme = v.Scheme[i+1:]
+	}
+	return v.String()
+}
+
+const (
+	batchSize     = 1 << 20
+	batchInterval = time.Second
+)
+
+// batchWriter is an io.WriteCloser which buffers writes and POSTs them
+// to an HTTP endpoint whenever the buffer grows over batchSize bytes,
+// every batchInterval and on Close. Errors from a failed POST are returned
+// by the following Write or Close call. Writes go on into a new buffer while
+// the previous one is POSTed.
+type batchWriter struct {
+	url    string
+	ctype  string
+	header http.Header
+	client http.Client
+	check  func(io.Reader) error
+
+	// flushing serializes POSTs, so that batches are sent in order.
+	flushing sync.Mutex
+
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	err  error
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newBatchWriter(url, ctype string, hdr http.Header) *batchWriter {
+	w := &batchWriter{
+		url:    url,
+		ctype:  ctype,
+		header: hdr,
+		client: http.Client{Timeout: 30 * time.Second},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	go w.loop()
+
+	return w
+}
+
+func (w *batchWriter) loop() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.flush()
+		}
+	}
+}
+
+// Write implements the io.Writer interface.
+func (w *batchWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	if err := w.err; err != nil {
+		w.mu.Unlock()
+		return 0, err
+	}
+
+	n, _ := w.buf.Write(p)
+	full := w.buf.Len() >= batchSize
+	w.mu.Unlock()
+
+	if full {
+		w.flush()
+	}
+
+	return n, nil
+}
+
+// Close flushes any buffered data and stops the periodic flushing.
+func (w *batchWriter) Close() error {
+	close(w.stop)
+	<-w.done
+
+	w.flush()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// flush swaps out the buffer under w.mu and POSTs it without holding it, so
+// that writes aren't blocked by the request.
+func (w *batchWriter) flush() {
+	w.flushing.Lock()
+	defer w.flushing.Unlock()
+
+	w.mu.Lock()
+	if w.buf.Len() == 0 || w.err != nil {
+		w.mu.Unlock()
+		return
+	}
+	body := w.buf.Bytes()
+	w.buf = bytes.Buffer{}
+	w.mu.Unlock()
+
+	hdr := http.Header{"Content-Type": []string{w.ctype}}
+	err := post(&w.client, w.url, body, w.check, w.header, hdr)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// post POSTs the given body to the given URL with the union of the given
+// headers and returns an error if the request fails or isn't successful.
+// If check isn't nil, it's called with the body of successful responses.
+func post(c *http.Client, url string, body []byte, check func(io.Reader) error, hdrs ...http.Header) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for _, hdr := range hdrs {
+		for k, vs := range hdr {
+			req.Header[k] = vs
+		}
+	}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("POST %s: %s: %s", url, res.Status, bytes.TrimSpace(body))
+	}
+
+	if check != nil {
+		if err = check(res.Body); err != nil {
+			return fmt.Errorf("POST %s: %v", url, err)
+		}
+	}
+
+	_, err = io.Copy(ioutil.Discard, res.Body)
+	return err
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestCheckBulkResponse(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name string
+		body string
+		err  string
+	}{
+		{"success", `{"took":3,"errors":false,"items":[{"index":{"status":201}}]}`, ""},
+		{
+			"failed item",
+			`{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}}]}`,
+			`bulk index failed with status 400: {"type":"mapper_parsing_exception"}`,
+		},
+		{"failed without details", `{"errors":true,"items":[]}`, "bulk request failed"},
+		{"bad response", `<html>`, "invalid character"},
+	} {
+		err := checkBulkResponse(strings.NewReader(tc.body))
+		if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%s: got error %v, want %q", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestElasticsearchSink(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		requests []*http.Request
+		bodies   []string
+		fail     bool
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+		requests, bodies = append(requests, r), append(bodies, string(body))
+
+		if fail {
+			w.Write([]byte(`{"errors":true,"items":[{"index":{"status":403,"error":{"type":"cluster_block_exception"}}}]}`))
+		} else {
+			w.Write([]byte(`{"errors":false,"items":[{"index":{"status":201}}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	ts := time.Date(2020, 3, 14, 15, 9, 26, 0, time.UTC)
+	for _, tc := range []struct {
+		name   string
+		output string
+		fail   bool
+		index  string
+		err    string
+	}{
+		{"default index", "elasticsearch+" + srv.URL, false, "vegeta-2020.03.14", ""},
+		{"index template", "opensearch+" + srv.URL + "/vegeta-{attack}-{date}", false, "vegeta-big-bang-2020.03.14", ""},
+		{"failed item", "elasticsearch+" + srv.URL + "/vegeta", true, "vegeta", "bulk index failed with status 403"},
+	} {
+		mu.Lock()
+		requests, bodies, fail = nil, nil, tc.fail
+		mu.Unlock()
+
+		hdr := http.Header{"Authorization": {"ApiKey s3cret"}}
+		enc, closer, err := output(tc.output, "", 0, hdr)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		// Metadata records aren't indexed.
+		if err = enc.Encode(&vegeta.Result{Timestamp: ts, Metadata: &vegeta.Metadata{Began: ts}}); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if err = enc.Encode(&vegeta.Result{Attack: "Big Bang", Seq: uint64(i), Code: 200, Timestamp: ts}); err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+		}
+
+		err = closer.Close()
+		if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%s: got error %v, want %q", tc.name, err, tc.err)
+		}
+
+		mu.Lock()
+		if len(requests) != 1 {
+			t.Fatalf("%s: got %d requests, want 1", tc.name, len(requests))
+		}
+
+		r := requests[0]
+		switch {
+		case r.Method != http.MethodPost || r.URL.Path != "/_bulk":
+			t.Errorf("%s: got %s %s, want POST /_bulk", tc.name, r.Method, r.URL.Path)
+		case r.Header.Get("Content-Type") != "application/x-ndjson":
+			t.Errorf("%s: got content type %q", tc.name, r.Header.Get("Content-Type"))
+		case r.Header.Get("Authorization") != "ApiKey s3cret":
+			t.Errorf("%s: got authorization %q", tc.name, r.Header.Get("Authorization"))
+		}
+
+		lines := strings.Split(strings.TrimSpace(bodies[0]), "\n")
+		if len(lines) != 4 {
+			t.Errorf("%s: got %d lines, want 4:\n%s", tc.name, len(lines), bodies[0])
+		}
+
+		for i := 0; i+1 < len(lines); i += 2 {
+			if want := `{"index":{"_index":"` + tc.index + `"}}`; lines[i] != want {
+				t.Errorf("%s: got action %s, want %s", tc.name, lines[i], want)
+			}
+			if !strings.Contains(lines[i+1], `"attack":"Big Bang"`) {
+				t.Errorf("%s: got document %s", tc.name, lines[i+1])
+			}
+		}
+		mu.Unlock()
+	}
+}
+
+func TestBatchWriterFlush(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+
+	posting, release := make(chan struct{}, 1), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		posting <- struct{}{}
+		<-release
+
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, string(body))
+	}))
+	defer srv.Close()
+
+	w := newBatchWriter(srv.URL, "text/plain", nil)
+
+	// A full buffer is POSTed by the write which filled it.
+	full := strings.Repeat("a", batchSize)
+	go w.Write([]byte(full))
+	<-posting
+
+	// Writes go on into a new buffer while it's POSTed.
+	written := make(chan error)
+	go func() {
+		_, err := w.Write([]byte("b"))
+		written <- err
+	}()
+
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("write blocked by the POST of the previous batch")
+	}
+
+	close(release)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 2 || bodies[0] != full || bodies[1] != "b" {
+		t.Errorf("got %d bodies, want the full batch and then b", len(bodies))
+	}
+}
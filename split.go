@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// splitEncoder writes Results to one file per value of a Result field, the
+// attack name or a label, in an output directory. Metadata records are
+// written to the file of their attack when splitting by attack, or at the
+// start of every file otherwise.
+type splitEncoder struct {
+	key      func(*vegeta.Result) string
+	byAttack bool
+	dir      string
+	encoding string
+	outputs  map[string]*splitOutput
+	metadata []*vegeta.Result
+}
+
+type splitOutput struct {
+	enc    vegeta.Encoder
+	closer io.Closer
+}
+
+// newSplitEncoder returns a splitEncoder of Results by the given field,
+// either attack or label.<key>, into files of dir named after its values.
+func newSplitEncoder(field, dir, encoding string) (*splitEncoder, error) {
+	se := &splitEncoder{dir: strings.TrimSuffix(dir, "/"), encoding: encoding}
+
+	switch {
+	case field == "attack":
+		se.key = func(r *vegeta.Result) string { return r.Attack }
+		se.byAttack = true
+	case strings.HasPrefix(field, "label.") && len(field) > len("label."):
+		label := field[len("label."):]
+		se.key = func(r *vegeta.Result) string { return r.Labels[label] }
+	default:
+		return nil, fmt.Errorf("bad split field %q: must be attack or label.<key>", field)
+	}
+
+	if _, _, err := encoder(encoding, ioutil.Discard); err != nil {
+		return nil, err
+	}
+
+	if _, _, _, ok := objectURL(dir); !ok {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	return se, nil
+}
+
+// Encode encodes the given Result to the file of its field value, creating
+// it if needed.
+func (se *splitEncoder) Encode(r *vegeta.Result) error {
+	v := se.key(r)
+	if r.Metadata != nil && se.byAttack {
+		v = r.Metadata.Attack
+	} else if r.Metadata != nil {
+		md := *r
+		se.metadata = append(se.metadata, &md)
+		for _, out := range se.outputs {
+			if err := out.enc.Encode(&md); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	out, ok := se.outputs[v]
+	if !ok {
+		var err error
+		if out, err = se.create(v); err != nil {
+			return err
+		}
+	}
+
+	return out.enc.Encode(r)
+}
+
+func (se *splitEncoder) create(v string) (*splitOutput, error) {
+	name := v
+	if name == "" {
+		name = "_"
+	}
+
+	ext := se.encoding
+	if ext == encodingGob {
+		ext = "bin"
+	}
+
+	f, err := create(se.dir + "/" + url.PathEscape(name) + "." + ext)
+	if err != nil {
+		return nil, err
+	}
+
+	enc, closer, err := encoder(se.encoding, f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	out := &splitOutput{enc: enc, closer: multiCloser{closer, f}}
+	if se.outputs == nil {
+		se.outputs = map[string]*splitOutput{}
+	}
+	se.outputs[v] = out
+
+	for _, md := range se.metadata {
+		if err = enc.Encode(md); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// Close closes all files, returning the first error.
+func (se *splitEncoder) Close() (err error) {
+	for v, out := range se.outputs {
+		if cerr := out.closer.Close(); err == nil {
+			err = cerr
+		}
+		delete(se.outputs, v)
+	}
+	return err
+}
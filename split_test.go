@@ -0,0 +1,189 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestSplitEncoder(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2020, 3, 14, 15, 9, 26, 0, time.UTC)
+
+	// A mixed stream of two attacks, each preceded by its metadata record,
+	// with results of several label values and none.
+	stream := []vegeta.Result{
+		{Attack: "checkout", Metadata: &vegeta.Metadata{Attack: "checkout", Rate: "10/1s", Began: ts}},
+		{Attack: "search/v2", Metadata: &vegeta.Metadata{Attack: "search/v2", Rate: "5/1s", Began: ts}},
+	}
+
+	envs := []string{"prod", "staging", ""}
+	for i := 0; i < 12; i++ {
+		r := vegeta.Result{
+			Attack:    []string{"checkout", "search/v2"}[i%2],
+			Seq:       uint64(i),
+			Code:      200,
+			Timestamp: ts.Add(time.Duration(i) * time.Millisecond),
+		}
+		if env := envs[i%3]; env != "" {
+			r.Labels = map[string]string{"env": env}
+		}
+		stream = append(stream, r)
+	}
+
+	for _, tc := range []struct {
+		field    string
+		encoding string
+		files    map[string]func(*vegeta.Result) bool
+		metadata map[string][]string
+	}{
+		{
+			field:    "attack",
+			encoding: encodingGob,
+			files: map[string]func(*vegeta.Result) bool{
+				"checkout.bin":    func(r *vegeta.Result) bool { return r.Attack == "checkout" },
+				"search%2Fv2.bin": func(r *vegeta.Result) bool { return r.Attack == "search/v2" },
+			},
+			// Metadata records go to the file of their attack.
+			metadata: map[string][]string{
+				"checkout.bin":    {"checkout"},
+				"search%2Fv2.bin": {"search/v2"},
+			},
+		},
+		{
+			field:    "label.env",
+			encoding: encodingJSON,
+			files: map[string]func(*vegeta.Result) bool{
+				"prod.json":    func(r *vegeta.Result) bool { return r.Labels["env"] == "prod" },
+				"staging.json": func(r *vegeta.Result) bool { return r.Labels["env"] == "staging" },
+				"_.json":       func(r *vegeta.Result) bool { return r.Labels["env"] == "" },
+			},
+			// Metadata records go to every file.
+			metadata: map[string][]string{
+				"prod.json":    {"checkout", "search/v2"},
+				"staging.json": {"checkout", "search/v2"},
+				"_.json":       {"checkout", "search/v2"},
+			},
+		},
+	} {
+		dir, err := ioutil.TempDir("", "vegeta")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		se, err := newSplitEncoder(tc.field, dir, tc.encoding)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.field, err)
+		}
+
+		for i := range stream {
+			if err = se.Encode(&stream[i]); err != nil {
+				t.Fatalf("%s: %v", tc.field, err)
+			}
+		}
+
+		if err = se.Close(); err != nil {
+			t.Fatalf("%s: %v", tc.field, err)
+		}
+
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names, want []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		for name := range tc.files {
+			want = append(want, name)
+		}
+		sort.Strings(want)
+
+		if !reflect.DeepEqual(names, want) {
+			t.Errorf("%s: got files %v, want %v", tc.field, names, want)
+		}
+
+		for name, match := range tc.files {
+			var (
+				mu       sync.Mutex
+				metadata []string
+			)
+
+			dec, closer, err := decoder([]string{filepath.Join(dir, name)}, func(md *vegeta.Metadata) {
+				mu.Lock()
+				defer mu.Unlock()
+				metadata = append(metadata, md.Attack)
+			})
+			if err != nil {
+				t.Fatalf("%s: %s: %v", tc.field, name, err)
+			}
+
+			var got []vegeta.Result
+			for {
+				var r vegeta.Result
+				if err = dec.Decode(&r); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("%s: %s: %v", tc.field, name, err)
+				}
+				got = append(got, r)
+			}
+			closer.Close()
+
+			// Every file has the results of its value in the order they were encoded.
+			var results []vegeta.Result
+			for i := range stream {
+				if stream[i].Metadata == nil && match(&stream[i]) {
+					results = append(results, stream[i])
+				}
+			}
+
+			if len(got) != len(results) {
+				t.Errorf("%s: %s: got %d results, want %d", tc.field, name, len(got), len(results))
+				continue
+			}
+
+			for i := range results {
+				if !got[i].Equal(results[i]) {
+					t.Errorf("%s: %s: got result %+v, want %+v", tc.field, name, got[i], results[i])
+				}
+			}
+
+			sort.Strings(metadata)
+			if !reflect.DeepEqual(metadata, tc.metadata[name]) {
+				t.Errorf("%s: %s: got metadata of attacks %q, want %q", tc.field, name, metadata, tc.metadata[name])
+			}
+		}
+	}
+}
+
+func TestNewSplitEncoderErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		field    string
+		encoding string
+		err      string
+	}{
+		{"code", encodingGob, `bad split field "code": must be attack or label.<key>`},
+		{"label.", encodingGob, `bad split field "label.": must be attack or label.<key>`},
+		{"attack", "xml", `unknown encoding "xml"`},
+	} {
+		_, err := newSplitEncoder(tc.field, "testdata/split", tc.encoding)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s %s: got error %v, want %q", tc.field, tc.encoding, err, tc.err)
+		}
+	}
+}
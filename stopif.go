@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// stoppedExitCode is the exit code of attacks stopped early by their -stop-if
+// condition or -breaker.
+const stoppedExitCode = 3
+
+// exitError is an error which exits the command with its code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+// stopCondition implements the flag.Value interface for the -stop-if
+// condition of attacks, e.g. "p99 > 800ms for 30s || error_rate > 5%": a
+// disjunction (||) of conjunctions (&&) of comparisons in the syntax of SLOs,
+// each of which may have to hold for a sustained duration, evaluated over
+// the metrics of the results of the last -stop-window.
+type stopCondition struct {
+	expr  string
+	terms [][]*stopTerm
+}
+
+// stopTerm is a comparison of a stopCondition.
+type stopTerm struct {
+	slo   vegeta.SLO
+	dur   time.Duration
+	since time.Time // When it began to hold, zero unless it holds.
+}
+
+func (c *stopCondition) Set(v string) error {
+	var terms [][]*stopTerm
+	for _, or := range strings.Split(v, "||") {
+		var and []*stopTerm
+		for _, s := range strings.Split(or, "&&") {
+			t := &stopTerm{}
+			if i := strings.LastIndex(s, " for "); i >= 0 {
+				d, err := time.ParseDuration(strings.TrimSpace(s[i+5:]))
+				if err != nil {
+					return fmt.Errorf("bad duration in %q: %v", strings.TrimSpace(s), err)
+				}
+				s, t.dur = s[:i], d
+			}
+
+			slo, err := vegeta.ParseSLO(strings.TrimSpace(s))
+			if err != nil {
+				return err
+			}
+			t.slo = slo
+			and = append(and, t)
+		}
+		terms = append(terms, and)
+	}
+
+	c.expr, c.terms = v, terms
+	return nil
+}
+
+func (c *stopCondition) String() string { return c.expr }
+
+func (c *stopCondition) set() bool { return len(c.terms) > 0 }
+
+// check evaluates the condition with the given metrics at the given time,
+// returning the conjunction which holds, if any.
+func (c *stopCondition) check(m *vegeta.Metrics, now time.Time) (string, bool) {
+	met := ""
+	for _, and := range c.terms {
+		holds := true
+		for _, t := range and {
+			// All terms are evaluated to keep track of how long they hold.
+			if !t.slo.Check(m) {
+				t.since = time.Time{}
+				holds = false
+				continue
+			} else if t.since.IsZero() {
+				t.since = now
+			}
+			holds = holds && now.Sub(t.since) >= t.dur
+		}
+
+		if holds && met == "" {
+			met = and[0].String()
+			for _, t := range and[1:] {
+				met += " && " + t.String()
+			}
+		}
+	}
+	return met, met != ""
+}
+
+func (t *stopTerm) String() string {
+	if t.dur > 0 {
+		return t.slo.String() + " for " + t.dur.String()
+	}
+	return t.slo.String()
+}
+
+// metricsWindow keeps the metrics of the results of the last given period,
+// by second of their timestamps.
+type metricsWindow struct {
+	size    time.Duration
+	buckets map[int64]*vegeta.Metrics
+}
+
+func newMetricsWindow(size time.Duration) *metricsWindow {
+	return &metricsWindow{size: size, buckets: map[int64]*vegeta.Metrics{}}
+}
+
+func (w *metricsWindow) add(r *vegeta.Result) {
+	sec := r.Timestamp.Unix()
+	m, ok := w.buckets[sec]
+	if !ok {
+		m = &vegeta.Metrics{}
+		w.buckets[sec] = m
+	}
+	m.Add(r)
+}
+
+// metrics returns the closed metrics of the results of the window ending at
+// the given time, after dropping older ones.
+func (w *metricsWindow) metrics(now time.Time) *vegeta.Metrics {
+	oldest := now.Add(-w.size).Unix()
+
+	var m vegeta.Metrics
+	for sec, b := range w.buckets {
+		if sec < oldest {
+			delete(w.buckets, sec)
+			continue
+		}
+		m.Merge(b)
+	}
+
+	m.Close()
+	return &m
+}
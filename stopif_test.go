@@ -0,0 +1,203 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestStopConditionSet(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		in   string
+		want [][]string
+		err  bool
+	}{
+		{in: "p99 > 800ms", want: [][]string{{"p99 > 800ms"}}},
+		{in: "p99>800ms for 30s", want: [][]string{{"p99 > 800ms for 30s"}}},
+		{
+			in:   "p99 > 800ms for 30s || error_rate > 5%",
+			want: [][]string{{"p99 > 800ms for 30s"}, {"error_rate > 5%"}},
+		},
+		{
+			in:   "p99 > 1s && success < 99% for 1m || rate < 10 for 10s",
+			want: [][]string{{"p99 > 1s", "success < 99% for 1m0s"}, {"rate < 10 for 10s"}},
+		},
+		{in: "p99 > 800ms for ever", err: true},
+		{in: "p99 > 800ms ||", err: true},
+		{in: "latency > 1s", err: true},
+		{in: "", err: true},
+	} {
+		var c stopCondition
+		err := c.Set(tc.in)
+		if tc.err {
+			if err == nil {
+				t.Errorf("Set(%q): want error, got %v", tc.in, c.terms)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Set(%q): %v", tc.in, err)
+			continue
+		}
+
+		var got [][]string
+		for _, and := range c.terms {
+			var terms []string
+			for _, term := range and {
+				terms = append(terms, term.String())
+			}
+			got = append(got, terms)
+		}
+
+		if len(got) != len(tc.want) {
+			t.Errorf("Set(%q): got %q, want %q", tc.in, got, tc.want)
+			continue
+		}
+
+		for i := range got {
+			if len(got[i]) != len(tc.want[i]) {
+				t.Errorf("Set(%q): got %q, want %q", tc.in, got, tc.want)
+				break
+			}
+			for j := range got[i] {
+				if got[i][j] != tc.want[i][j] {
+					t.Errorf("Set(%q): got %q, want %q", tc.in, got, tc.want)
+				}
+			}
+		}
+
+		if c.String() != tc.in || !c.set() {
+			t.Errorf("Set(%q): got string %q and set %v", tc.in, c.String(), c.set())
+		}
+	}
+}
+
+func TestStopConditionCheck(t *testing.T) {
+	t.Parallel()
+
+	// metrics returns the metrics of 10 results with the given latency, of
+	// which the given number failed.
+	metrics := func(latency time.Duration, failed int) *vegeta.Metrics {
+		var m vegeta.Metrics
+		for i := 0; i < 10; i++ {
+			r := vegeta.Result{Code: 200, Timestamp: time.Unix(int64(i), 0), Latency: latency}
+			if i < failed {
+				r.Code, r.Error = 500, "Internal Server Error"
+			}
+			m.Add(&r)
+		}
+		m.Close()
+		return &m
+	}
+
+	slow, fast, failing := metrics(time.Second, 0), metrics(100*time.Millisecond, 0), metrics(time.Second, 5)
+
+	t0 := time.Unix(0, 0)
+	type step struct {
+		m    *vegeta.Metrics
+		at   time.Duration
+		want string
+	}
+
+	for _, tc := range []struct {
+		cond  string
+		steps []step
+	}{
+		{
+			cond: "p99 > 800ms",
+			steps: []step{
+				{fast, 0, ""},
+				{slow, time.Second, "p99 > 800ms"},
+			},
+		},
+		{
+			cond: "p99 > 800ms for 2s",
+			steps: []step{
+				{slow, 0, ""},
+				{slow, time.Second, ""},
+				{slow, 2 * time.Second, "p99 > 800ms for 2s"},
+				// Durations start over once the term stops holding.
+				{fast, 3 * time.Second, ""},
+				{slow, 4 * time.Second, ""},
+				{slow, 5 * time.Second, ""},
+				{slow, 6 * time.Second, "p99 > 800ms for 2s"},
+			},
+		},
+		{
+			cond: "p99 > 800ms && success < 90%",
+			steps: []step{
+				{slow, 0, ""},
+				{failing, time.Second, "p99 > 800ms && success < 90%"},
+			},
+		},
+		{
+			cond: "p99 > 800ms for 1s && success < 90% || success < 60%",
+			steps: []step{
+				{failing, 0, "success < 60%"},
+				{failing, time.Second, "p99 > 800ms for 1s && success < 90%"},
+				{fast, 2 * time.Second, ""},
+			},
+		},
+		{
+			// Terms of conjunctions which don't hold keep track of how long
+			// they've held.
+			cond: "p99 > 800ms for 2s && success < 90%",
+			steps: []step{
+				{slow, 0, ""},
+				{slow, time.Second, ""},
+				{failing, 2 * time.Second, "p99 > 800ms for 2s && success < 90%"},
+			},
+		},
+	} {
+		var c stopCondition
+		if err := c.Set(tc.cond); err != nil {
+			t.Fatal(err)
+		}
+
+		for i, s := range tc.steps {
+			got, ok := c.check(s.m, t0.Add(s.at))
+			if got != s.want || ok != (s.want != "") {
+				t.Errorf("%q step %d: got %q, %v, want %q", tc.cond, i, got, ok, s.want)
+			}
+		}
+	}
+}
+
+func TestMetricsWindow(t *testing.T) {
+	t.Parallel()
+
+	w := newMetricsWindow(3 * time.Second)
+	t0 := time.Unix(1000, 0)
+	for i := 0; i < 10; i++ {
+		w.add(&vegeta.Result{
+			Code:      200,
+			Timestamp: t0.Add(time.Duration(i) * 500 * time.Millisecond),
+			Latency:   time.Duration(i+1) * time.Millisecond,
+		})
+	}
+
+	for _, tc := range []struct {
+		at       time.Duration
+		requests uint64
+		max      time.Duration
+		buckets  int
+	}{
+		// All results of the last 3s, by second of their timestamps.
+		{4 * time.Second, 8, 10 * time.Millisecond, 4},
+		{5 * time.Second, 6, 10 * time.Millisecond, 3},
+		// Older buckets are evicted.
+		{6500 * time.Millisecond, 4, 10 * time.Millisecond, 2},
+		{7 * time.Second, 2, 10 * time.Millisecond, 1},
+		{8 * time.Second, 0, 0, 0},
+	} {
+		m := w.metrics(t0.Add(tc.at))
+		if m.Requests != tc.requests || m.Latencies.Max != tc.max || len(w.buckets) != tc.buckets {
+			t.Errorf("at %s: got %d requests, max %s and %d buckets, want %d, %s and %d",
+				tc.at, m.Requests, m.Latencies.Max, len(w.buckets), tc.requests, tc.max, tc.buckets)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// dashboardWindow is the rolling window of the dashboard's rate and percentiles.
+	dashboardWindow = 10 * time.Second
+	// dashboardSparkline is the number of frames shown in the latency sparkline.
+	dashboardSparkline = 60
+	// dashboardErrors is the number of most recent errors shown in the error ticker.
+	dashboardErrors = 5
+)
+
+// dashboard is a live, full screen terminal dashboard of attack results
+// rendered at every interval by the report command's -tui mode.
+type dashboard struct {
+	total  vegeta.Metrics
+	window []dashboardSample
+	errors []vegeta.Result
+	spark  []time.Duration
+
+	// mean latency of the results added since the last frame
+	sum   time.Duration
+	count int
+}
+
+type dashboardSample struct {
+	t   time.Time
+	lat time.Duration
+}
+
+// Add implements the vegeta.Report interface.
+func (d *dashboard) Add(r *vegeta.Result) {
+	d.total.Add(r)
+
+	d.window = append(d.window, dashboardSample{r.Timestamp, r.Latency})
+	d.sum += r.Latency
+	d.count++
+
+	if r.Error != "" {
+		if d.errors = append(d.errors, *r); len(d.errors) > dashboardErrors {
+			d.errors = d.errors[1:]
+		}
+	}
+}
+
+// Close implements the vegeta.Closer interface.
+func (d *dashboard) Close() {
+	d.total.Close()
+
+	// Drop the samples which fell out of the rolling window.
+	cutoff := d.total.Latest.Add(-dashboardWindow)
+	window := d.window[:0]
+	for _, s := range d.window {
+		if !s.t.Before(cutoff) {
+			window = append(window, s)
+		}
+	}
+	d.window = window
+
+	var mean time.Duration
+	if d.count > 0 {
+		mean = d.sum / time.Duration(d.count)
+	}
+
+	if d.spark = append(d.spark, mean); len(d.spark) > dashboardSparkline {
+		d.spark = d.spark[1:]
+	}
+
+	d.sum, d.count = 0, 0
+}
+
+// report writes out a frame of the dashboard.
+func (d *dashboard) report(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	m := &d.total
+
+	fmt.Fprintf(bw, "Vegeta — %s elapsed — %d requests — %.2f%% success\n\n",
+		roundDuration(m.Duration+m.Wait), m.Requests, m.Success*100)
+
+	lats := make([]time.Duration, len(d.window))
+	for i, s := range d.window {
+		lats[i] = s.lat
+	}
+
+	sort.Slice(lats, func(i, j int) bool { return lats[i] < lats[j] })
+
+	var rate float64
+	if span := m.Latest.Sub(m.Earliest); span > 0 {
+		if span > dashboardWindow {
+			span = dashboardWindow
+		}
+		rate = float64(len(d.window)) / span.Seconds()
+	}
+
+	window := "[last " + dashboardWindow.String() + "]"
+	fmt.Fprintf(bw, "%-12s %-11s %.2f/s\n", "Rate", window, rate)
+	fmt.Fprintf(bw, "%-12s %-11s 50: %s  90: %s  95: %s  99: %s  max: %s\n", "Latencies", window,
+		quantile(lats, 0.5), quantile(lats, 0.9), quantile(lats, 0.95), quantile(lats, 0.99), quantile(lats, 1))
+	fmt.Fprintf(bw, "%-12s %-11s 50: %s  90: %s  95: %s  99: %s  max: %s\n", "Latencies", "[total]",
+		roundDuration(m.Latencies.P50), roundDuration(m.Latencies.P90), roundDuration(m.Latencies.P95),
+		roundDuration(m.Latencies.P99), roundDuration(m.Latencies.Max))
+
+	codes := make([]string, 0, len(m.StatusCodes))
+	for code := range m.StatusCodes {
+		codes = append(codes, code)
+	}
+
+	sort.Strings(codes)
+
+	fmt.Fprintf(bw, "%-12s %-11s", "Status Codes", "[total]")
+	for _, code := range codes {
+		fmt.Fprintf(bw, " %s:%d ", code, m.StatusCodes[code])
+	}
+
+	var max time.Duration
+	for _, v := range d.spark {
+		if v > max {
+			max = v
+		}
+	}
+
+	fmt.Fprintf(bw, "\n\nMean latency %s (max %s)\n", sparkline(d.spark, max), roundDuration(max))
+
+	fmt.Fprintf(bw, "\nRecent errors\n")
+	for i := len(d.errors) - 1; i >= 0; i-- {
+		r := d.errors[i]
+		fmt.Fprintf(bw, "  %s  %3d  %s\n", r.Timestamp.Format("15:04:05.000"), r.Code, r.Error)
+	}
+
+	return bw.Flush()
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline returns a sparkline of the given values scaled to max.
+func sparkline(vs []time.Duration, max time.Duration) string {
+	var b strings.Builder
+	for _, v := range vs {
+		i := 0
+		if max > 0 {
+			i = int(float64(v) / float64(max) * float64(len(sparks)-1))
+		}
+		b.WriteRune(sparks[i])
+	}
+	return b.String()
+}
+
+// quantile returns the q quantile of the given sorted latencies.
+func quantile(lats []time.Duration, q float64) time.Duration {
+	if len(lats) == 0 {
+		return 0
+	}
+	return roundDuration(lats[int(q*float64(len(lats)-1))])
+}
+
+// roundDuration rounds d to a precision suited for display.
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// webhookFormats are the supported webhook payload formats.
+var webhookFormats = []string{"slack", "teams", "json"}
+
+// webhook posts a summary of the final Metrics of an attack or report, and
+// whether they meet the given SLOs, to a URL with a payload compatible with
+// Slack or Microsoft Teams incoming webhooks, or as plain JSON.
+type webhook struct {
+	url    string
+	format string
+	slos   []vegeta.SLO
+	client http.Client
+}
+
+func newWebhook(url, format string, slos []vegeta.SLO) (*webhook, error) {
+	for _, f := range webhookFormats {
+		if f == format {
+			return &webhook{
+				url:    url,
+				format: format,
+				slos:   slos,
+				client: http.Client{Timeout: 30 * time.Second},
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("webhook format %q isn't one of [%s]", format, strings.Join(webhookFormats, ", "))
+}
+
+// webhookSLO is the outcome of an SLO check included in webhook payloads.
+type webhookSLO struct {
+	SLO   string `json:"slo"`
+	Value string `json:"value"`
+	Pass  bool   `json:"pass"`
+}
+
+// notify posts the webhook payload for the given Metrics.
+func (wh *webhook) notify(title string, m *vegeta.Metrics) error {
+	body, err := wh.payload(title, m)
+	if err != nil {
+		return err
+	}
+
+	hdr := http.Header{"Content-Type": {"application/json"}}
+	if err = post(&wh.client, wh.url, body, nil, hdr); err != nil {
+		return fmt.Errorf("webhook: %v", err)
+	}
+
+	return nil
+}
+
+func (wh *webhook) payload(title string, m *vegeta.Metrics) ([]byte, error) {
+	pass := true
+	slos := make([]webhookSLO, len(wh.slos))
+	for i, slo := range wh.slos {
+		slos[i] = webhookSLO{
+			SLO:   slo.String(),
+			Value: slo.Format(slo.Value(m)),
+			Pass:  slo.Check(m),
+		}
+		pass = pass && slos[i].Pass
+	}
+
+	if len(slos) > 0 {
+		if pass {
+			title += ": PASSED"
+		} else {
+			title += ": FAILED"
+		}
+	}
+
+	lines := []string{
+		fmt.Sprintf("Requests %d at %.2f/s over %s, %.2f%% success",
+			m.Requests, m.Rate, roundDuration(m.Duration), m.Success*100),
+		fmt.Sprintf("Latencies mean %s, 50th %s, 95th %s, 99th %s, max %s",
+			roundDuration(m.Latencies.Mean), roundDuration(m.Latencies.P50),
+			roundDuration(m.Latencies.P95), roundDuration(m.Latencies.P99),
+			roundDuration(m.Latencies.Max)),
+	}
+
+	for _, s := range slos {
+		outcome := "PASS"
+		if !s.Pass {
+			outcome = "FAIL"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s (%s)", outcome, s.SLO, s.Value))
+	}
+
+	var payload interface{}
+	switch wh.format {
+	case "slack":
+		payload = map[string]string{
+			"text": "*" + title + "*\n" + strings.Join(lines, "\n"),
+		}
+	case "teams":
+		color := "2EB886"
+		if !pass {
+			color = "D00000"
+		}
+		payload = map[string]string{
+			"@type":      "MessageCard",
+			"@context":   "http://schema.org/extensions",
+			"themeColor": color,
+			"summary":    title,
+			"title":      title,
+			"text":       strings.Join(lines, "  \n"), // Markdown line breaks
+		}
+	default:
+		payload = struct {
+			Title   string          `json:"title"`
+			Pass    bool            `json:"pass"`
+			SLOs    []webhookSLO    `json:"slos"`
+			Metrics *vegeta.Metrics `json:"metrics"`
+		}{title, pass, slos, m}
+	}
+
+	return json.Marshal(payload)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestWebhookPayload(t *testing.T) {
+	t.Parallel()
+
+	m := &vegeta.Metrics{
+		Requests: 1000,
+		Rate:     100,
+		Duration: 10*time.Second + 123456*time.Microsecond,
+		Success:  0.99,
+		Latencies: vegeta.LatencyMetrics{
+			Mean: 12345678 * time.Nanosecond,
+			P50:  10 * time.Millisecond,
+			P95:  50 * time.Millisecond,
+			P99:  120 * time.Millisecond,
+			Max:  250 * time.Millisecond,
+		},
+	}
+
+	slos := func(ss ...string) []vegeta.SLO {
+		parsed := make([]vegeta.SLO, 0, len(ss))
+		for _, s := range ss {
+			slo, err := vegeta.ParseSLO(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed = append(parsed, slo)
+		}
+		return parsed
+	}
+
+	summary := []string{
+		"Requests 1000 at 100.00/s over 10.123s, 99.00% success",
+		"Latencies mean 12.35ms, 50th 10ms, 95th 50ms, 99th 120ms, max 250ms",
+	}
+
+	passed := slos("max < 1s", "success >= 99%")
+	failed := slos("max < 1s", "success >= 99.9%")
+
+	for _, tc := range []struct {
+		name   string
+		format string
+		slos   []vegeta.SLO
+		want   map[string]interface{}
+	}{
+		{
+			name:   "slack without slos",
+			format: "slack",
+			want:   map[string]interface{}{"text": "*Attack*\n" + strings.Join(summary, "\n")},
+		},
+		{
+			name:   "slack passed",
+			format: "slack",
+			slos:   passed,
+			want: map[string]interface{}{
+				"text": "*Attack: PASSED*\n" + strings.Join(append(summary, "PASS max < 1s (250ms)", "PASS success >= 99% (99%)"), "\n"),
+			},
+		},
+		{
+			name:   "slack failed",
+			format: "slack",
+			slos:   failed,
+			want: map[string]interface{}{
+				"text": "*Attack: FAILED*\n" + strings.Join(append(summary, "PASS max < 1s (250ms)", "FAIL success >= 99.9% (99%)"), "\n"),
+			},
+		},
+		{
+			name:   "teams passed",
+			format: "teams",
+			slos:   passed,
+			want: map[string]interface{}{
+				"@type":      "MessageCard",
+				"@context":   "http://schema.org/extensions",
+				"themeColor": "2EB886",
+				"summary":    "Attack: PASSED",
+				"title":      "Attack: PASSED",
+				"text":       strings.Join(append(summary, "PASS max < 1s (250ms)", "PASS success >= 99% (99%)"), "  \n"),
+			},
+		},
+		{
+			name:   "teams failed",
+			format: "teams",
+			slos:   failed,
+			want: map[string]interface{}{
+				"@type":      "MessageCard",
+				"@context":   "http://schema.org/extensions",
+				"themeColor": "D00000",
+				"summary":    "Attack: FAILED",
+				"title":      "Attack: FAILED",
+				"text":       strings.Join(append(summary, "PASS max < 1s (250ms)", "FAIL success >= 99.9% (99%)"), "  \n"),
+			},
+		},
+		{
+			name:   "json without slos",
+			format: "json",
+			want:   map[string]interface{}{"title": "Attack", "pass": true, "slos": []interface{}{}},
+		},
+		{
+			name:   "json passed",
+			format: "json",
+			slos:   passed,
+			want: map[string]interface{}{
+				"title": "Attack: PASSED",
+				"pass":  true,
+				"slos": []interface{}{
+					map[string]interface{}{"slo": "max < 1s", "value": "250ms", "pass": true},
+					map[string]interface{}{"slo": "success >= 99%", "value": "99%", "pass": true},
+				},
+			},
+		},
+		{
+			name:   "json failed",
+			format: "json",
+			slos:   failed,
+			want: map[string]interface{}{
+				"title": "Attack: FAILED",
+				"pass":  false,
+				"slos": []interface{}{
+					map[string]interface{}{"slo": "max < 1s", "value": "250ms", "pass": true},
+					map[string]interface{}{"slo": "success >= 99.9%", "value": "99%", "pass": false},
+				},
+			},
+		},
+	} {
+		wh, err := newWebhook("http://localhost", tc.format, tc.slos)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		body, err := wh.payload("Attack", m)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		var got map[string]interface{}
+		if err = json.Unmarshal(body, &got); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		// The JSON payload has the full metrics of the attack.
+		if tc.format == "json" {
+			metrics, ok := got["metrics"].(map[string]interface{})
+			if !ok || metrics["requests"] != float64(m.Requests) {
+				t.Errorf("%s: got metrics %v", tc.name, got["metrics"])
+			}
+			delete(got, "metrics")
+		}
+
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestWebhookNotify(t *testing.T) {
+	t.Parallel()
+
+	var (
+		contentType string
+		body        []byte
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+		if r.URL.Path == "/fail" {
+			http.Error(w, "invalid_payload", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	wh, err := newWebhook(srv.URL+"/hook", "slack", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &vegeta.Metrics{Requests: 1}
+	if err = wh.notify("Attack", m); err != nil {
+		t.Fatal(err)
+	}
+
+	want, _ := wh.payload("Attack", m)
+	if contentType != "application/json" || string(body) != string(want) {
+		t.Errorf("got %s body %s, want %s", contentType, body, want)
+	}
+
+	wh.url = srv.URL + "/fail"
+	if err = wh.notify("Attack", m); err == nil || !strings.HasPrefix(err.Error(), "webhook: POST "+wh.url+": 400 Bad Request: invalid_payload") {
+		t.Errorf("got error %v, want the one of the failed request", err)
+	}
+
+	if _, err = newWebhook(srv.URL, "discord", nil); err == nil || err.Error() != `webhook format "discord" isn't one of [slack, teams, json]` {
+		t.Errorf("got error %v, want one of the unknown format", err)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+const workerUsage = `Usage: vegeta worker [options]
+
+Runs a worker of distributed attacks, which the attack command started with
+--distributed coordinates. Workers receive the attack's targets, body and
+options, except those of its outputs and webhook, along with their share of
+its rate, and stream their results back to the attack, which writes them to
+its output with a "node" label set to the address of their worker.
+
+All workers start attacking at the same time, two seconds after the attack
+command is run, so their clocks should be synchronized, e.g. with NTP. Files
+referenced by targets, like @file bodies, are read on the workers. Workers run
+one attack at a time.
+
+Anyone who can reach a worker can make it attack any target, so workers
+should only be reachable by trusted hosts. They listen on localhost by
+default, and require a --token, which the attack command sends with
+--worker-token, to listen on other addresses. Workers only accept the attack
+options which shape its requests, refusing those which run commands, load
+code, serve, read or write files on their hosts, like --hook-command,
+--key-signer, --plugin, --control, --cert, --rotate-header, --profile-if or
+--tls-keylog.
+
+Options:
+  --listen  Address to listen on [default: localhost:8099]
+  --token   Token required from attacks, unless listening on localhost
+            [default: none]
+
+Examples:
+  vegeta worker -listen :8099 -token "$TOKEN"
+  echo "GET http://target/" | vegeta attack -distributed 10.0.1.1:8099,10.0.2.1:8099 \
+    -worker-token "$TOKEN" -rate 60000 -duration 60s > results.bin`
+
+func workerCmd() command {
+	fs := flag.NewFlagSet("vegeta worker", flag.ExitOnError)
+	listen := fs.String("listen", "localhost:8099", "Address to listen on")
+	token := fs.String("token", "", "Token required from attacks, unless listening on localhost")
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, workerUsage)
+	}
+
+	return command{fs, func(args []string) error {
+		fs.Parse(args)
+		return worker(*listen, *token)
+	}}
+}
+
+func worker(listen, token string) error {
+	if token == "" && !loopback(listen) {
+		return fmt.Errorf("-token is required to listen on %s, which isn't localhost", listen)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/attack", &workerHandler{token: token})
+	log.Printf("vegeta worker listening on %s", listen)
+	return http.ListenAndServe(listen, mux)
+}
+
+// workerHandler runs the attacks planned by the coordinators of distributed
+// attacks, one at a time, and streams back their gob encoded results.
+type workerHandler struct {
+	token string
+	busy  int32
+}
+
+func (h *workerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	auth := []byte(r.Header.Get("Authorization"))
+	if h.token != "" && subtle.ConstantTimeCompare(auth, []byte("Bearer "+h.token)) != 1 {
+		http.Error(w, "bad worker token", http.StatusUnauthorized)
+		return
+	}
+
+	if !atomic.CompareAndSwapInt32(&h.busy, 0, 1) {
+		http.Error(w, "busy with another attack", http.StatusConflict)
+		return
+	}
+	defer atomic.StoreInt32(&h.busy, 0)
+
+	var plan workerPlan
+	if err := json.NewDecoder(r.Body).Decode(&plan); err != nil {
+		http.Error(w, "bad plan: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fs, opts := attackFlags(flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := fs.Parse(plan.Args); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var denied []string
+	fs.Visit(func(f *flag.Flag) {
+		if !workerFlags[f.Name] {
+			denied = append(denied, "-"+f.Name)
+		}
+	})
+
+	if len(denied) > 0 {
+		http.Error(w, "flags not allowed: "+strings.Join(denied, ", "), http.StatusBadRequest)
+		return
+	}
+
+	for _, f := range []struct {
+		name *string
+		data []byte
+	}{
+		{&opts.targetsf, plan.Targets},
+		{&opts.bodyf, plan.Body},
+	} {
+		if len(f.data) == 0 && f.name == &opts.bodyf {
+			continue
+		}
+
+		tmp, err := tempFile(f.data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer os.Remove(tmp)
+		*f.name = tmp
+	}
+
+	log.Printf("attack from %s: %s", r.RemoteAddr, strings.Join(plan.Args, " "))
+
+	fw := &flushWriter{w: w}
+	fw.f, _ = w.(http.Flusher)
+	opts.out, opts.start, opts.stop = fw, plan.Start, r.Context().Done()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if err := attack(opts); err != nil {
+		log.Printf("attack from %s: %v", r.RemoteAddr, err)
+		if fw.n == 0 {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}
+}
+
+// workerFlags are the flags of the attack command which workers accept from
+// coordinators. Those which run commands, load code, serve, read or write
+// files on the host of the attack, like -hook-command, -key-signer, -plugin,
+// -control, -cert, -rotate-header, -profile-if or -tls-keylog, are left out,
+// since anyone who can reach a worker could then run any command on it or
+// send its files to any target, as are the coordinatorFlags other than -rate.
+var workerFlags = map[string]bool{
+	"accept-encoding":      true,
+	"assert":               true,
+	"attack-header":        true,
+	"bytes-in":             true,
+	"cache-bust":           true,
+	"cache-bust-header":    true,
+	"capture-request":      true,
+	"chunked":              true,
+	"compress-body":        true,
+	"connect-to":           true,
+	"connections":          true,
+	"dns-refresh":          true,
+	"drain":                true,
+	"duration":             true,
+	"exclude-negotiation":  true,
+	"expect-continue":      true,
+	"fallback-delay":       true,
+	"format":               true,
+	"h2c":                  true,
+	"header":               true,
+	"hook-timeout":         true,
+	"host-alias":           true,
+	"host-stats":           true,
+	"http2":                true,
+	"insecure":             true,
+	"ipv4":                 true,
+	"ipv6":                 true,
+	"keep-body-on":         true,
+	"keep-body-regex":      true,
+	"keep-body-size":       true,
+	"keepalive":            true,
+	"label":                true,
+	"laddr":                true,
+	"lport":                true,
+	"max-body":             true,
+	"max-connections":      true,
+	"max-conns-per-host":   true,
+	"max-workers":          true,
+	"name":                 true,
+	"net-down":             true,
+	"net-jitter":           true,
+	"net-latency":          true,
+	"net-up":               true,
+	"ntlm":                 true,
+	"ntp":                  true,
+	"oauth2-client-id":     true,
+	"oauth2-client-secret": true,
+	"oauth2-refresh-token": true,
+	"oauth2-scopes":        true,
+	"oauth2-token-url":     true,
+	"otlp-endpoint":        true,
+	"otlp-header":          true,
+	"otlp-sampled":         true,
+	"prewarm":              true,
+	"proxy":                true,
+	"proxy-header":         true,
+	"ramp-down":            true,
+	"rate":                 true,
+	"record-connections":   true,
+	"record-headers":       true,
+	"record-tls":           true,
+	"redirects":            true,
+	"resolvers":            true,
+	"retry":                true,
+	"retry-backoff":        true,
+	"retry-on":             true,
+	"revalidate":           true,
+	"rotate-header-order":  true,
+	"sample":               true,
+	"seq-header":           true,
+	"session-cookies":      true,
+	"sigv4":                true,
+	"sni":                  true,
+	"tcp-keepalive":        true,
+	"tcp-nodelay":          true,
+	"tcp-rcvbuf":           true,
+	"tcp-sndbuf":           true,
+	"timeout":              true,
+	"tls-ciphers":          true,
+	"tls-max-version":      true,
+	"tls-min-version":      true,
+	"tls-resumption":       true,
+	"trace-sampled":        true,
+	"traceparent":          true,
+	"workers":              true,
+}
+
+// loopback returns true if the given listen address is on localhost only.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	} else if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// tempFile writes the given data to a new temporary file and returns its name.
+func tempFile(data []byte) (string, error) {
+	f, err := ioutil.TempFile("", "vegeta-worker-")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), f.Close()
+}
+
+// flushWriter flushes every write to an HTTP response, so that results are
+// streamed as they're encoded.
+type flushWriter struct {
+	w io.Writer
+	f http.Flusher
+	n int64
+}
+
+func (w *flushWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	if w.f != nil {
+		w.f.Flush()
+	}
+	return n, err
+}